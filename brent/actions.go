@@ -0,0 +1,112 @@
+package brent
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/acorn-io/brent/pkg/apierror"
+	"github.com/acorn-io/brent/pkg/attributes"
+	"github.com/acorn-io/brent/pkg/types"
+	"github.com/acorn-io/schemer/validation"
+	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apiserver/pkg/authentication/user"
+	"k8s.io/apiserver/pkg/authorization/authorizer"
+)
+
+// ActionEvent describes an invocation of an action or link of a subresource through brent.
+type ActionEvent struct {
+	User        user.Info
+	Verb        string
+	Resource    schema.GroupVersionResource
+	Subresource string
+	Namespace   string
+	Name        string
+	Allowed     bool
+	// Reason is the reason given by the authorizer, if any.
+	Reason string
+}
+
+// ActionAuditor is called for every action and link invoked through brent, whether it was allowed or not.
+type ActionAuditor func(ctx context.Context, event ActionEvent)
+
+func logActionEvent(_ context.Context, event ActionEvent) {
+	var username string
+	if event.User != nil {
+		username = event.User.GetName()
+	}
+	logrus.WithFields(logrus.Fields{
+		"user":        username,
+		"verb":        event.Verb,
+		"resource":    event.Resource.GroupResource().String(),
+		"subresource": event.Subresource,
+		"namespace":   event.Namespace,
+		"name":        event.Name,
+		"allowed":     event.Allowed,
+		"reason":      event.Reason,
+	}).Info("Subresource invoked")
+}
+
+// handler returns the handler of an action (verb create) or link (verb get) of a subresource. The request is
+// authorized for the subresource as a SubjectAccessReview for it would be, audited and then proxied to the API server.
+// Access to the schema alone isn't enough, a user may be allowed to get an object but not to invoke its actions.
+//
+// The authorizer is the one of the server config, not the union the server builds from it and the delegated cluster
+// authorization, so subresources the server's authorizer has no opinion on are denied here even if the cluster would
+// allow them.
+func (s *subResources) handler(subResource, verb string) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		apiOp := types.GetAPIContext(req.Context())
+		if apiOp == nil || apiOp.Schema == nil {
+			rw.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		gvr := attributes.GVR(apiOp.Schema)
+		namespace, name := apiOp.Namespace, apiOp.Name
+		u, _ := apiOp.GetUserInfo()
+		event := ActionEvent{
+			User:        u,
+			Verb:        verb,
+			Resource:    gvr,
+			Subresource: subResource,
+			Namespace:   namespace,
+			Name:        name,
+		}
+
+		decision, reason, err := s.authorizer.Authorize(req.Context(), authorizer.AttributesRecord{
+			User:            u,
+			Verb:            verb,
+			Namespace:       namespace,
+			APIGroup:        gvr.Group,
+			APIVersion:      gvr.Version,
+			Resource:        gvr.Resource,
+			Subresource:     subResource,
+			Name:            name,
+			ResourceRequest: true,
+		})
+		if err != nil {
+			logrus.Errorf("Failed to authorize %s of %s/%s for %s/%s: %v", verb, gvr.Resource, subResource, namespace, name, err)
+		}
+		event.Allowed = err == nil && decision == authorizer.DecisionAllow
+		event.Reason = reason
+		s.auditor(req.Context(), event)
+
+		if !event.Allowed {
+			apiOp.WriteError(apierror.NewAPIError(validation.PermissionDenied,
+				fmt.Sprintf("can not %s %s/%s of %s", verb, gvr.Resource, subResource, name)))
+			return
+		}
+
+		proxied := req.Clone(req.Context())
+		proxied.URL.Path = s.subResourcePath(subResource, gvr, namespace, name)
+		proxied.URL.RawPath = ""
+		query := proxied.URL.Query()
+		query.Del("action")
+		query.Del("link")
+		proxied.URL.RawQuery = query.Encode()
+		proxied.RequestURI = ""
+		s.k8sHandler.ServeHTTP(rw, proxied)
+	})
+}
@@ -0,0 +1,89 @@
+package brent
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/acorn-io/brent/pkg/apierror"
+	"github.com/acorn-io/brent/pkg/attributes"
+	"github.com/acorn-io/brent/pkg/types"
+	"github.com/acorn-io/schemer"
+	"github.com/acorn-io/schemer/validation"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apiserver/pkg/authentication/user"
+	"k8s.io/apiserver/pkg/authorization/authorizer"
+	"k8s.io/apiserver/pkg/endpoints/request"
+)
+
+// getLogsAuthorizer lets users get the log subresource of things, but not create it.
+type getLogsAuthorizer struct{}
+
+func (getLogsAuthorizer) Authorize(_ context.Context, attr authorizer.Attributes) (authorizer.Decision, string, error) {
+	if attr.GetResource() == "things" && attr.GetSubresource() == "log" && attr.GetVerb() == "get" {
+		return authorizer.DecisionAllow, "may read logs", nil
+	}
+	return authorizer.DecisionNoOpinion, "", nil
+}
+
+func TestSubResourceHandler(t *testing.T) {
+	var (
+		events  []ActionEvent
+		proxied []string
+	)
+	s := &subResources{
+		k8sHandler: http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			proxied = append(proxied, req.Method+" "+req.URL.String())
+			rw.WriteHeader(http.StatusOK)
+		}),
+		authorizer: getLogsAuthorizer{},
+		auditor: func(_ context.Context, event ActionEvent) {
+			events = append(events, event)
+		},
+	}
+	apiSchema := &types.APISchema{Schema: &schemas.Schema{ID: "example.com.thing"}}
+	attributes.SetGroup(apiSchema, "example.com")
+	attributes.SetVersion(apiSchema, "v1")
+	attributes.SetResource(apiSchema, "things")
+	alice := &user.DefaultInfo{Name: "alice"}
+
+	serve := func(method, verb string) (*httptest.ResponseRecorder, error) {
+		var handled error
+		req := httptest.NewRequest(method, "/v1/example.com.things/default/one?link=log&follow=true", nil)
+		req = req.WithContext(request.WithUser(req.Context(), alice))
+		apiOp := types.StoreAPIContext(&types.APIRequest{
+			Schema:    apiSchema,
+			Namespace: "default",
+			Name:      "one",
+			Request:   req,
+			ErrorHandler: func(_ *types.APIRequest, err error) {
+				handled = err
+			},
+		})
+		rw := httptest.NewRecorder()
+		s.handler("log", verb).ServeHTTP(rw, apiOp.Request)
+		return rw, handled
+	}
+
+	// the link is allowed and proxied to the subresource
+	rw, err := serve(http.MethodGet, "get")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, rw.Code)
+	assert.Equal(t, []string{"GET /apis/example.com/v1/namespaces/default/things/one/log?follow=true"}, proxied)
+
+	// the action is denied although the user may get the subresource
+	_, err = serve(http.MethodPost, "create")
+	if assert.Error(t, err) {
+		assert.Equal(t, validation.PermissionDenied, err.(*apierror.APIError).Code)
+	}
+	assert.Len(t, proxied, 1)
+
+	// both are audited
+	gvr := schema.GroupVersionResource{Group: "example.com", Version: "v1", Resource: "things"}
+	assert.Equal(t, []ActionEvent{
+		{User: alice, Verb: "get", Resource: gvr, Subresource: "log", Namespace: "default", Name: "one", Allowed: true, Reason: "may read logs"},
+		{User: alice, Verb: "create", Resource: gvr, Subresource: "log", Namespace: "default", Name: "one"},
+	}, events)
+}
@@ -19,6 +19,7 @@ import (
 	"k8s.io/apimachinery/pkg/api/meta"
 	"k8s.io/apiserver/pkg/authentication/authenticator"
 	"k8s.io/apiserver/pkg/authentication/user"
+	"k8s.io/apiserver/pkg/authorization/authorizer"
 	genericapiserver "k8s.io/apiserver/pkg/server"
 	"k8s.io/client-go/discovery"
 	"k8s.io/client-go/rest"
@@ -34,6 +35,12 @@ type Config struct {
 	RESTConfig *rest.Config
 	MinkConfig *mserver.Config
 	Authz      authz.BindingAuthorizer
+	// Localizer, if set, translates the descriptions and printer columns of the schemas shown to clients, see
+	// Translations for one driven by a map.
+	Localizer Localizer
+	// ActionAuditor is called for every action and link of a subresource invoked through brent, by default they are
+	// logged. Actions and links are authorized with MinkConfig.Authorization, or Authz if it isn't set.
+	ActionAuditor ActionAuditor
 }
 
 func Handler(ctx context.Context, cfg *Config) (http.Handler, genericapiserver.PostStartHookFunc, error) {
@@ -107,7 +114,12 @@ func start(ctx context.Context, hookContext genericapiserver.PostStartHookContex
 		return nil, err
 	}
 
-	subResources, err := newSubResources(s.BaseSchemas, k8sHandler, cfg.MinkConfig.APIGroups)
+	subResourceAuth := authorizer.Authorizer(bindingAuth)
+	if cfg.MinkConfig.Authorization != nil {
+		subResourceAuth = cfg.MinkConfig.Authorization
+	}
+
+	subResources, err := newSubResources(s.BaseSchemas, k8sHandler, subResourceAuth, cfg.ActionAuditor, cfg.MinkConfig.APIGroups)
 	if err != nil {
 		return nil, err
 	}
@@ -129,6 +141,9 @@ func start(ctx context.Context, hookContext genericapiserver.PostStartHookContex
 			} else {
 				//attributes.SetVerbs(apiSchema, nil)
 			}
+			if cfg.Localizer != nil {
+				cfg.Localizer.Localize(apiSchema)
+			}
 		},
 	})
 
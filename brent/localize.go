@@ -0,0 +1,72 @@
+package brent
+
+import (
+	"github.com/acorn-io/brent/pkg/attributes"
+	"github.com/acorn-io/brent/pkg/resources/common"
+	"github.com/acorn-io/brent/pkg/types"
+)
+
+// Localizer changes what brent displays for a schema, such as its description, the descriptions of its fields and
+// the names of its printer columns. It is called for every schema served to a client and must only change
+// presentation, field names and column fields are part of the API and are left alone.
+type Localizer interface {
+	Localize(schema *types.APISchema)
+}
+
+type LocalizerFunc func(schema *types.APISchema)
+
+func (l LocalizerFunc) Localize(schema *types.APISchema) {
+	l(schema)
+}
+
+// SchemaTranslation holds the displayed strings of one schema. Empty strings and missing keys keep the original text.
+type SchemaTranslation struct {
+	Description string
+	// Fields maps resource field names to their translated description.
+	Fields map[string]string
+	// Columns maps printer column names, as returned by the API server in tables, to their translated name.
+	Columns map[string]string
+	// ColumnDescriptions maps printer column names to their translated description.
+	ColumnDescriptions map[string]string
+}
+
+// Translations is a Localizer driven by a bundle of translations keyed by schema ID, for example "apps" or
+// "acorn.io.app".
+type Translations map[string]SchemaTranslation
+
+func (t Translations) Localize(schema *types.APISchema) {
+	translation, ok := t[schema.ID]
+	if !ok {
+		return
+	}
+
+	if translation.Description != "" {
+		schema.Description = translation.Description
+	}
+
+	for name, description := range translation.Fields {
+		field, ok := schema.ResourceFields[name]
+		if !ok || description == "" {
+			continue
+		}
+		field.Description = description
+		schema.ResourceFields[name] = field
+	}
+
+	columns, ok := attributes.Columns(schema).([]common.ColumnDefinition)
+	if !ok || len(translation.Columns)+len(translation.ColumnDescriptions) == 0 {
+		return
+	}
+	// The columns are shared with the schema every other client is served from, so translate a copy.
+	localized := make([]common.ColumnDefinition, len(columns))
+	for i, column := range columns {
+		if name := translation.Columns[column.Name]; name != "" {
+			column.Name = name
+		}
+		if description := translation.ColumnDescriptions[columns[i].Name]; description != "" {
+			column.Description = description
+		}
+		localized[i] = column
+	}
+	attributes.SetColumns(schema, localized)
+}
@@ -8,6 +8,8 @@ import (
 	"github.com/acorn-io/brent/pkg/attributes"
 	"github.com/acorn-io/brent/pkg/types"
 	"github.com/acorn-io/schemer"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apiserver/pkg/authorization/authorizer"
 	serverrest "k8s.io/apiserver/pkg/registry/rest"
 	genericapiserver "k8s.io/apiserver/pkg/server"
 )
@@ -16,6 +18,8 @@ type subResources struct {
 	defs       map[string]*linkActions
 	k8sHandler http.Handler
 	schemas    *types.APISchemas
+	authorizer authorizer.Authorizer
+	auditor    ActionAuditor
 }
 
 type linkActions struct {
@@ -25,11 +29,16 @@ type linkActions struct {
 	Formatter       types.Formatter
 }
 
-func newSubResources(schemas *types.APISchemas, k8sHandler http.Handler, apiGroups []*genericapiserver.APIGroupInfo) (*subResources, error) {
+func newSubResources(schemas *types.APISchemas, k8sHandler http.Handler, authorizer authorizer.Authorizer, auditor ActionAuditor, apiGroups []*genericapiserver.APIGroupInfo) (*subResources, error) {
+	if auditor == nil {
+		auditor = logActionEvent
+	}
 	s := &subResources{
 		defs:       map[string]*linkActions{},
 		k8sHandler: k8sHandler,
 		schemas:    schemas,
+		authorizer: authorizer,
+		auditor:    auditor,
 	}
 
 	stores := map[string]serverrest.Storage{}
@@ -73,18 +82,23 @@ func (s *subResources) build(stores map[string]serverrest.Storage) error {
 			def.ResourceActions[subResource] = schemas.Action{
 				Input: input.ID,
 			}
+			def.LinkHandlers[subResource] = s.handler(subResource, "get")
+			def.ActionHandlers[subResource] = s.handler(subResource, "create")
 		} else if _, ok := v.(serverrest.Getter); ok {
+			def.LinkHandlers[subResource] = s.handler(subResource, "get")
 			if _, ok := v.(serverrest.Creater); ok {
 				def.ResourceActions[subResource] = schemas.Action{
 					Input:  input.ID,
 					Output: input.ID,
 				}
+				def.ActionHandlers[subResource] = s.handler(subResource, "create")
 			}
 		} else {
 			def.ResourceActions[subResource] = schemas.Action{
 				Input:  input.ID,
 				Output: input.ID,
 			}
+			def.ActionHandlers[subResource] = s.handler(subResource, "create")
 		}
 
 		formatter := func(request *types.APIRequest, resource *types.RawResource) {
@@ -111,16 +125,19 @@ func (s *subResources) build(stores map[string]serverrest.Storage) error {
 }
 
 func (s *subResources) subResourceURL(name string, apiContext *types.APIRequest, resource *types.RawResource) string {
-	var nsPath string
 	ns, resourceName, ok := strings.Cut(resource.ID, "/")
 	if !ok {
-		resourceName = ns
-	} else {
-		nsPath = fmt.Sprintf("namespaces/%s/", ns)
+		ns, resourceName = "", ns
+	}
+	return apiContext.URLBuilder.RelativeToRoot(s.subResourcePath(name, attributes.GVR(apiContext.Schema), ns, resourceName))
+}
+
+func (s *subResources) subResourcePath(subResource string, gvr schema.GroupVersionResource, namespace, name string) string {
+	var nsPath string
+	if namespace != "" {
+		nsPath = fmt.Sprintf("namespaces/%s/", namespace)
 	}
-	gvr := attributes.GVR(apiContext.Schema)
-	return apiContext.URLBuilder.RelativeToRoot(fmt.Sprintf("/apis/%s/v1/%s%s/%s/%s",
-		gvr.Group, nsPath, gvr.Resource, resourceName, name))
+	return fmt.Sprintf("/apis/%s/v1/%s%s/%s/%s", gvr.Group, nsPath, gvr.Resource, name, subResource)
 }
 
 func (s *subResources) Customize(apiSchema *types.APISchema) {
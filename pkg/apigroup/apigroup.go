@@ -1,35 +1,137 @@
 package apigroup
 
 import (
+	"fmt"
+	"slices"
+
 	"github.com/acorn-io/mink/pkg/serializer"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	runtimeserializer "k8s.io/apimachinery/pkg/runtime/serializer"
+	"k8s.io/apimachinery/pkg/version"
 	"k8s.io/apiserver/pkg/registry/rest"
 	genericapiserver "k8s.io/apiserver/pkg/server"
 )
 
+// DefaultGroupPriority is the priority of groups in aggregated discovery unless set in the GroupPriorities of the
+// server config, it is the priority the API server gives groups it has no priority for.
+const DefaultGroupPriority = 1000
+
 type AddToScheme func(*runtime.Scheme) error
 
-func ForStores(scheme AddToScheme, stores map[string]rest.Storage, groupVersion schema.GroupVersion) (*genericapiserver.APIGroupInfo, error) {
+type Option func(*options)
+
+type options struct {
+	versions  map[string]map[string]rest.Storage
+	preferred string
+	protobuf  bool
+}
+
+// WithVersion serves stores at another version of the group.
+func WithVersion(version string, stores map[string]rest.Storage) Option {
+	return func(o *options) {
+		o.versions[version] = stores
+	}
+}
+
+// WithPreferredVersion sets the version clients should use. By default the version passed to ForStores is preferred.
+// The other versions are ordered as Kubernetes orders versions, GA before beta before alpha.
+func WithPreferredVersion(version string) Option {
+	return func(o *options) {
+		o.preferred = version
+	}
+}
+
+// WithProtobuf serves the group as application/vnd.kubernetes.protobuf as well as JSON and YAML, which clients of
+// built-in Kubernetes types such as controller-runtime and the kubelet ask for first. Every object and list served by
+// the stores must implement proto.Marshaler, ForStores fails otherwise. By default groups other than the core group
+// are not served as protobuf.
+func WithProtobuf() Option {
+	return func(o *options) {
+		o.protobuf = true
+	}
+}
+
+// protoMarshaler is the proto.Marshaler interface the protobuf serializer requires.
+type protoMarshaler interface {
+	Marshal() ([]byte, error)
+}
+
+// checkProtobuf returns an error if an object or list of the stores can't be encoded as protobuf.
+func checkProtobuf(versions map[string]map[string]rest.Storage) error {
+	for version, stores := range versions {
+		for name, store := range stores {
+			objs := []runtime.Object{store.New()}
+			if lister, ok := store.(rest.Lister); ok {
+				objs = append(objs, lister.NewList())
+			}
+			for _, obj := range objs {
+				if _, ok := obj.(protoMarshaler); !ok {
+					return fmt.Errorf("%T of %s in version %s does not support protobuf", obj, name, version)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func ForStores(scheme AddToScheme, stores map[string]rest.Storage, groupVersion schema.GroupVersion, opts ...Option) (*genericapiserver.APIGroupInfo, error) {
+	o := options{
+		versions: map[string]map[string]rest.Storage{
+			groupVersion.Version: stores,
+		},
+		preferred: groupVersion.Version,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if _, ok := o.versions[o.preferred]; !ok {
+		return nil, fmt.Errorf("preferred version %s of group %s has no stores", o.preferred, groupVersion.Group)
+	}
+	if o.protobuf {
+		if err := checkProtobuf(o.versions); err != nil {
+			return nil, fmt.Errorf("group %s can not be served as protobuf: %w", groupVersion.Group, err)
+		}
+	}
+
 	newScheme := runtime.NewScheme()
 	if err := scheme(newScheme); err != nil {
 		return nil, err
 	}
 
-	for _, store := range stores {
-		newScheme.AddKnownTypes(schema.GroupVersion{
-			Group:   groupVersion.Group,
-			Version: runtime.APIVersionInternal,
-		}, store.New())
+	for _, stores := range o.versions {
+		for _, store := range stores {
+			newScheme.AddKnownTypes(schema.GroupVersion{
+				Group:   groupVersion.Group,
+				Version: runtime.APIVersionInternal,
+			}, store.New())
+		}
 	}
 
 	codecs := runtimeserializer.NewCodecFactory(newScheme)
 	parameterCodec := runtime.NewParameterCodec(newScheme)
 	apiGroupInfo := genericapiserver.NewDefaultAPIGroupInfo(groupVersion.Group, newScheme, parameterCodec, codecs)
-	apiGroupInfo.VersionedResourcesStorageMap[groupVersion.Version] = stores
-	if groupVersion.Group != "" {
+	apiGroupInfo.PrioritizedVersions = prioritizedVersions(groupVersion.Group, o.preferred, o.versions)
+	for version, stores := range o.versions {
+		apiGroupInfo.VersionedResourcesStorageMap[version] = stores
+	}
+	if groupVersion.Group != "" && !o.protobuf {
 		apiGroupInfo.NegotiatedSerializer = serializer.NewNoProtobufSerializer(apiGroupInfo.NegotiatedSerializer)
 	}
 	return &apiGroupInfo, nil
 }
+
+// prioritizedVersions returns the served versions of the group, the preferred one first. Versions the scheme
+// knows but that have no stores are left out, so discovery never prefers a version that isn't served.
+func prioritizedVersions(group, preferred string, versions map[string]map[string]rest.Storage) []schema.GroupVersion {
+	result := make([]schema.GroupVersion, 0, len(versions))
+	for version := range versions {
+		if version != preferred {
+			result = append(result, schema.GroupVersion{Group: group, Version: version})
+		}
+	}
+	slices.SortFunc(result, func(a, b schema.GroupVersion) int {
+		return -version.CompareKubeAwareVersionStrings(a.Version, b.Version)
+	})
+	return append([]schema.GroupVersion{{Group: group, Version: preferred}}, result...)
+}
@@ -0,0 +1,136 @@
+// Package credential is a client-go exec credential plugin for the bearer tokens of mink servers, so that kubeconfigs
+// get their token from a command rather than embedding it. A command of the embedding binary, such as
+// "mink auth print-token", calls PrintToken with the source of its tokens, and kubeconfigs run it with the ExecConfig
+// of NewExecConfig. client-go runs the command again once the token expires or the server rejects it, which picks up
+// rotated tokens without editing the kubeconfig.
+package credential
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clientauthenticationv1 "k8s.io/client-go/pkg/apis/clientauthentication/v1"
+	clientauthenticationv1beta1 "k8s.io/client-go/pkg/apis/clientauthentication/v1beta1"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+)
+
+// ExecInfoEnv is the environment variable client-go passes the ExecCredential of the request in.
+const ExecInfoEnv = "KUBERNETES_EXEC_INFO"
+
+// TokenSource returns the bearer token of a mink server and when it expires, the zero time if it doesn't.
+type TokenSource interface {
+	Token(ctx context.Context) (string, time.Time, error)
+}
+
+// TokenSourceFunc is a TokenSource implemented by a function, such as one exchanging a login for a short-lived token.
+type TokenSourceFunc func(ctx context.Context) (string, time.Time, error)
+
+func (f TokenSourceFunc) Token(ctx context.Context) (string, time.Time, error) {
+	return f(ctx)
+}
+
+// StaticToken returns a source of a token that doesn't expire, such as the token of an authn.StaticToken.
+func StaticToken(token string) TokenSource {
+	return TokenSourceFunc(func(context.Context) (string, time.Time, error) {
+		if token == "" {
+			return "", time.Time{}, fmt.Errorf("the token is empty")
+		}
+		return token, time.Time{}, nil
+	})
+}
+
+// FileToken returns a source of the token stored in the file at path, without surrounding whitespace. The token is
+// reported to expire after lifetime, so that client-go reads the file again that often and picks up a rotated token.
+// A zero lifetime leaves reading it again to the server rejecting the token.
+func FileToken(path string, lifetime time.Duration) TokenSource {
+	return TokenSourceFunc(func(context.Context) (string, time.Time, error) {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return "", time.Time{}, fmt.Errorf("reading token: %w", err)
+		}
+		token := strings.TrimSpace(string(data))
+		if token == "" {
+			return "", time.Time{}, fmt.Errorf("the token file %s is empty", path)
+		}
+		var expiry time.Time
+		if lifetime > 0 {
+			expiry = time.Now().Add(lifetime)
+		}
+		return token, expiry, nil
+	})
+}
+
+// ExecCredential returns the ExecCredential with the token of source, in the apiVersion client-go asked for in
+// KUBERNETES_EXEC_INFO, v1 if it didn't.
+func ExecCredential(ctx context.Context, source TokenSource) (*clientauthenticationv1.ExecCredential, error) {
+	apiVersion, err := requestedAPIVersion()
+	if err != nil {
+		return nil, err
+	}
+
+	token, expiry, err := source.Token(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// v1beta1 credentials have the same fields as v1 ones
+	result := &clientauthenticationv1.ExecCredential{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: apiVersion,
+			Kind:       "ExecCredential",
+		},
+		Status: &clientauthenticationv1.ExecCredentialStatus{
+			Token: token,
+		},
+	}
+	if !expiry.IsZero() {
+		result.Status.ExpirationTimestamp = &metav1.Time{Time: expiry}
+	}
+	return result, nil
+}
+
+func requestedAPIVersion() (string, error) {
+	info := os.Getenv(ExecInfoEnv)
+	if info == "" {
+		return clientauthenticationv1.SchemeGroupVersion.String(), nil
+	}
+
+	var request metav1.TypeMeta
+	if err := json.Unmarshal([]byte(info), &request); err != nil {
+		return "", fmt.Errorf("invalid %s: %w", ExecInfoEnv, err)
+	}
+	switch request.APIVersion {
+	case "":
+		return clientauthenticationv1.SchemeGroupVersion.String(), nil
+	case clientauthenticationv1.SchemeGroupVersion.String(), clientauthenticationv1beta1.SchemeGroupVersion.String():
+		return request.APIVersion, nil
+	}
+	return "", fmt.Errorf("unsupported ExecCredential apiVersion %s", request.APIVersion)
+}
+
+// PrintToken writes the ExecCredential with the token of source to w, normally stdout, as client-go expects of exec
+// credential plugins.
+func PrintToken(ctx context.Context, w io.Writer, source TokenSource) error {
+	credential, err := ExecCredential(ctx, source)
+	if err != nil {
+		return err
+	}
+	return json.NewEncoder(w).Encode(credential)
+}
+
+// NewExecConfig returns the exec section of a kubeconfig user that gets its token by running command with args, which
+// must print it with PrintToken.
+func NewExecConfig(command string, args ...string) *clientcmdapi.ExecConfig {
+	return &clientcmdapi.ExecConfig{
+		Command:         command,
+		Args:            args,
+		APIVersion:      clientauthenticationv1.SchemeGroupVersion.String(),
+		InteractiveMode: clientcmdapi.NeverExecInteractiveMode,
+	}
+}
@@ -0,0 +1,37 @@
+package credential
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	clientauthenticationv1beta1 "k8s.io/client-go/pkg/apis/clientauthentication/v1beta1"
+)
+
+func TestPrintToken(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(path, []byte("secret\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv(ExecInfoEnv, `{"apiVersion":"client.authentication.k8s.io/v1beta1","kind":"ExecCredential","spec":{"interactive":false}}`)
+
+	var out bytes.Buffer
+	if err := PrintToken(context.Background(), &out, FileToken(path, time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	var credential clientauthenticationv1beta1.ExecCredential
+	if err := json.Unmarshal(out.Bytes(), &credential); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "client.authentication.k8s.io/v1beta1", credential.APIVersion)
+	assert.Equal(t, "secret", credential.Status.Token)
+	assert.WithinDuration(t, time.Now().Add(time.Hour), credential.Status.ExpirationTimestamp.Time, time.Minute)
+
+	t.Setenv(ExecInfoEnv, `{"apiVersion":"client.authentication.k8s.io/v2"}`)
+	assert.Error(t, PrintToken(context.Background(), &out, StaticToken("secret")))
+}
@@ -4,7 +4,7 @@ import (
 	"net/http"
 	"strings"
 
-	"github.com/sirupsen/logrus"
+	"github.com/acorn-io/mink/pkg/logging"
 	"k8s.io/apiserver/pkg/authentication/authenticator"
 	"k8s.io/apiserver/pkg/authentication/user"
 )
@@ -36,7 +36,7 @@ func (a *StaticToken) AuthenticateRequest(req *http.Request) (*authenticator.Res
 			UID:    a.userName,
 			Groups: a.groups,
 		}
-		logrus.Debugf("Authenticated %s", resp.User.GetName())
+		logging.Default().Debugf("Authenticated %s", resp.User.GetName())
 		// Delete header, not needed anymore
 		req.Header.Del("Authorization")
 		return resp, true, nil
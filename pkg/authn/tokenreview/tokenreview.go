@@ -0,0 +1,109 @@
+package tokenreview
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/acorn-io/mink/pkg/logging"
+	"github.com/acorn-io/mink/pkg/stores"
+	"github.com/acorn-io/mink/pkg/types"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apiserver/pkg/authentication/authenticator"
+	"k8s.io/apiserver/pkg/authentication/user"
+	"k8s.io/apiserver/pkg/registry/rest"
+	"k8s.io/apiserver/pkg/storage/names"
+)
+
+// NewStore returns the storage for authentication.k8s.io TokenReviews, which lets other services hand the
+// authentication of bearer tokens to this server. Tokens are checked with authenticator, normally the same one
+// the server is configured with. apiAudiences are the audiences checked when a review doesn't name any.
+func NewStore(authenticator authenticator.Request, scheme *runtime.Scheme, apiAudiences ...string) rest.Storage {
+	strategy := &Strategy{
+		Authenticator: authenticator,
+		APIAudiences:  apiAudiences,
+	}
+	return stores.NewBuilder(scheme, &authenticationv1.TokenReview{}).
+		WithPrepareCreate(strategy).
+		WithCreate(strategy).Build()
+}
+
+type Strategy struct {
+	Authenticator authenticator.Request
+	APIAudiences  []string
+}
+
+func (s *Strategy) PrepareForCreate(ctx context.Context, obj runtime.Object) {
+	review := obj.(*authenticationv1.TokenReview)
+	if review.Name == "" && review.GenerateName == "" {
+		review.GenerateName = "tokenreview-"
+		review.Name = names.SimpleNameGenerator.GenerateName(review.GenerateName)
+	}
+}
+
+func (s *Strategy) NamespaceScoped() bool {
+	return false
+}
+
+func (s *Strategy) Create(ctx context.Context, object types.Object) (types.Object, error) {
+	review := object.(*authenticationv1.TokenReview)
+	if review.Spec.Token == "" {
+		return nil, apierrors.NewBadRequest("token is required for TokenReview in authentication")
+	}
+
+	audiences := review.Spec.Audiences
+	if len(audiences) == 0 {
+		audiences = s.APIAudiences
+	}
+	if len(audiences) > 0 {
+		ctx = authenticator.WithAudiences(ctx, audiences)
+	}
+
+	// The authenticators work on requests, so present the token the way a client would.
+	req := (&http.Request{Header: http.Header{}}).WithContext(ctx)
+	req.Header.Set("Authorization", "Bearer "+review.Spec.Token)
+
+	review.Status = authenticationv1.TokenReviewStatus{}
+	if s.Authenticator == nil {
+		return review, nil
+	}
+
+	resp, ok, err := s.Authenticator.AuthenticateRequest(req)
+	if err != nil {
+		review.Status.Error = err.Error()
+	}
+	// The anonymous authenticator accepts any request, that doesn't make the token valid.
+	if !ok || resp == nil || resp.User == nil || resp.User.GetName() == user.Anonymous {
+		logging.Default().Debugf("TokenReview '%s': token not authenticated", review.GetName())
+		return review, nil
+	}
+
+	if len(audiences) > 0 && len(resp.Audiences) > 0 {
+		matched := authenticator.Audiences(audiences).Intersect(resp.Audiences)
+		if len(matched) == 0 {
+			review.Status.Error = fmt.Sprintf("token audiences %q is invalid for the target audiences %q", resp.Audiences, audiences)
+			return review, nil
+		}
+		review.Status.Audiences = matched
+	} else {
+		review.Status.Audiences = audiences
+	}
+
+	review.Status.Authenticated = true
+	review.Status.User = authenticationv1.UserInfo{
+		Username: resp.User.GetName(),
+		UID:      resp.User.GetUID(),
+		Groups:   resp.User.GetGroups(),
+		Extra:    map[string]authenticationv1.ExtraValue{},
+	}
+	for k, v := range resp.User.GetExtra() {
+		review.Status.User.Extra[k] = v
+	}
+	return review, nil
+}
+
+func (s *Strategy) New() types.Object {
+	return &authenticationv1.TokenReview{}
+}
@@ -0,0 +1,120 @@
+package tokenreview
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apiserver/pkg/authentication/authenticator"
+	"k8s.io/apiserver/pkg/authentication/user"
+)
+
+// testAuthenticator accepts the tokens alice, bound to the audiences api and other, anonymous, which is what the
+// anonymous authenticator returns for any token, and unbound, which isn't bound to any audience.
+var testAuthenticator = authenticator.RequestFunc(func(req *http.Request) (*authenticator.Response, bool, error) {
+	switch strings.TrimPrefix(req.Header.Get("Authorization"), "Bearer ") {
+	case "alice":
+		return &authenticator.Response{
+			Audiences: authenticator.Audiences{"api", "other"},
+			User: &user.DefaultInfo{
+				Name:   "alice",
+				UID:    "alice-uid",
+				Groups: []string{"admins", user.AllAuthenticated},
+				Extra:  map[string][]string{"tenant": {"a"}},
+			},
+		}, true, nil
+	case "anonymous":
+		return &authenticator.Response{User: &user.DefaultInfo{Name: user.Anonymous, Groups: []string{user.AllUnauthenticated}}}, true, nil
+	case "unbound":
+		return &authenticator.Response{User: &user.DefaultInfo{Name: "bob"}}, true, nil
+	}
+	return nil, false, errors.New("invalid token")
+})
+
+func review(token string, audiences ...string) *authenticationv1.TokenReview {
+	return &authenticationv1.TokenReview{Spec: authenticationv1.TokenReviewSpec{Token: token, Audiences: audiences}}
+}
+
+func TestCreate(t *testing.T) {
+	s := &Strategy{Authenticator: testAuthenticator, APIAudiences: []string{"api"}}
+	ctx := context.Background()
+
+	_, err := s.Create(ctx, review(""))
+	assert.True(t, apierrors.IsBadRequest(err), "expected bad request, got %v", err)
+
+	for _, test := range []struct {
+		name   string
+		review *authenticationv1.TokenReview
+		status authenticationv1.TokenReviewStatus
+	}{
+		{
+			name:   "invalid",
+			review: review("mallory"),
+			status: authenticationv1.TokenReviewStatus{Error: "invalid token"},
+		},
+		{
+			name:   "anonymous",
+			review: review("anonymous"),
+		},
+		{
+			name:   "default audiences",
+			review: review("alice"),
+			status: authenticationv1.TokenReviewStatus{
+				Authenticated: true,
+				Audiences:     []string{"api"},
+				User: authenticationv1.UserInfo{
+					Username: "alice",
+					UID:      "alice-uid",
+					Groups:   []string{"admins", user.AllAuthenticated},
+					Extra:    map[string]authenticationv1.ExtraValue{"tenant": {"a"}},
+				},
+			},
+		},
+		{
+			name:   "audience intersection",
+			review: review("alice", "other", "unknown"),
+			status: authenticationv1.TokenReviewStatus{
+				Authenticated: true,
+				Audiences:     []string{"other"},
+				User: authenticationv1.UserInfo{
+					Username: "alice",
+					UID:      "alice-uid",
+					Groups:   []string{"admins", user.AllAuthenticated},
+					Extra:    map[string]authenticationv1.ExtraValue{"tenant": {"a"}},
+				},
+			},
+		},
+		{
+			name:   "audience mismatch",
+			review: review("alice", "unknown"),
+			status: authenticationv1.TokenReviewStatus{
+				Error: `token audiences ["api" "other"] is invalid for the target audiences ["unknown"]`,
+			},
+		},
+		{
+			name:   "token without audiences",
+			review: review("unbound", "unknown"),
+			status: authenticationv1.TokenReviewStatus{
+				Authenticated: true,
+				Audiences:     []string{"unknown"},
+				User: authenticationv1.UserInfo{
+					Username: "bob",
+					Extra:    map[string]authenticationv1.ExtraValue{},
+				},
+			},
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			result, err := s.Create(ctx, test.review)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, test.status, result.(*authenticationv1.TokenReview).Status)
+		})
+	}
+}
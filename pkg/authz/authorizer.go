@@ -2,9 +2,11 @@ package authz
 
 import (
 	"context"
+	"time"
 
 	"github.com/acorn-io/mink/pkg/authz/binding"
-	"github.com/sirupsen/logrus"
+	"github.com/acorn-io/mink/pkg/logging"
+	"github.com/acorn-io/mink/pkg/strategy"
 	"k8s.io/apiserver/pkg/authentication/user"
 	"k8s.io/apiserver/pkg/authorization/authorizer"
 	kclient "sigs.k8s.io/controller-runtime/pkg/client"
@@ -24,6 +26,10 @@ type BindingAuthorizer interface {
 type Authorizer struct {
 	Client    kclient.Client
 	Providers []BindingProvider
+	// ScopedList allows users to list and watch across all namespaces when they can list in at least one namespace.
+	// The results are limited to the namespaces they can list in, which requires ListScopeMiddleware, such lists are
+	// denied without it, and by the middleware if the storage of the resource doesn't read the scope.
+	ScopedList bool
 }
 
 // AppendBindingProviders adds a new binding provider to the authorizer.
@@ -33,16 +39,17 @@ func (a *Authorizer) AppendBindingProviders(providers ...BindingProvider) {
 	}
 }
 
-// Bindings is used by steve to provide all rules for the current user.
+// Bindings is used by steve to provide all rules for the current user. Bindings that aren't active are left out.
 func (a *Authorizer) Bindings(ctx context.Context, user user.Info) (result []binding.Binding, _ error) {
+	now := time.Now()
 	for _, provider := range a.Providers {
 		bindings, err := provider.ForUser(ctx, a.Client, user)
 		if err != nil {
 			return nil, err
 		}
-		for _, binding := range bindings {
-			if binding.MatchesUser(user) {
-				result = append(result, binding)
+		for _, b := range bindings {
+			if b.MatchesUser(user) && binding.IsActive(b, now) {
+				result = append(result, b)
 			}
 		}
 	}
@@ -51,14 +58,15 @@ func (a *Authorizer) Bindings(ctx context.Context, user user.Info) (result []bin
 
 // Authorize is called by k8s.
 func (a *Authorizer) Authorize(ctx context.Context, attr authorizer.Attributes) (authorized authorizer.Decision, reason string, err error) {
+	now := time.Now()
 	for _, provider := range a.Providers {
 		bindings, err := provider.ForAttributes(ctx, a.Client, attr.GetUser(), attr)
 		if err != nil {
 			return authorizer.DecisionDeny, "error", err
 		}
-		for _, binding := range bindings {
-			if binding.MatchesUser(attr.GetUser()) {
-				for _, rule := range binding.GetRules() {
+		for _, b := range bindings {
+			if b.MatchesUser(attr.GetUser()) && binding.IsActive(b, now) {
+				for _, rule := range b.GetRules() {
 					if rule.Matches(attr) {
 						return authorizer.DecisionAllow, "", nil
 					}
@@ -67,6 +75,21 @@ func (a *Authorizer) Authorize(ctx context.Context, attr authorizer.Attributes)
 		}
 	}
 
-	logrus.Debugf("Rejecting %s to %s %s", attr.GetUser().GetName(), attr.GetVerb(), attr.GetPath())
+	if a.ScopedList && isClusterList(attr) {
+		_, namespaces, err := a.listNamespaces(ctx, attr)
+		if err != nil {
+			return authorizer.DecisionDeny, "error", err
+		}
+		if len(namespaces) > 0 {
+			if !strategy.SetListScope(ctx, &strategy.ListScope{Namespaces: namespaces}) {
+				logging.Default().Warnf("Rejecting %s to %s %s across namespaces, the list scope middleware isn't installed",
+					attr.GetUser().GetName(), attr.GetVerb(), attr.GetPath())
+				return authorizer.DecisionDeny, "list scope not available", nil
+			}
+			return authorizer.DecisionAllow, "scoped to bound namespaces", nil
+		}
+	}
+
+	logging.Default().Debugf("Rejecting %s to %s %s", attr.GetUser().GetName(), attr.GetVerb(), attr.GetPath())
 	return authorizer.DecisionDeny, "", nil
 }
@@ -1,7 +1,11 @@
 package binding
 
 import (
+	"fmt"
+	"path"
+	"regexp"
 	"strings"
+	"time"
 
 	"k8s.io/apimachinery/pkg/util/sets"
 	"k8s.io/apiserver/pkg/authentication/user"
@@ -27,11 +31,51 @@ type Binding interface {
 	GetID() string
 }
 
+// TimeBound is implemented by bindings that only grant their rules for a period of time, such as temporary elevated
+// access. A zero time leaves that end of the period open. Bindings that don't implement it are always active.
+type TimeBound interface {
+	GetNotBefore() time.Time
+	GetNotAfter() time.Time
+}
+
+// IsActive returns whether the binding grants its rules at now.
+func IsActive(binding Binding, now time.Time) bool {
+	timeBound, ok := binding.(TimeBound)
+	if !ok {
+		return true
+	}
+	if notBefore := timeBound.GetNotBefore(); !notBefore.IsZero() && now.Before(notBefore) {
+		return false
+	}
+	return !IsExpired(binding, now)
+}
+
+// IsExpired returns whether the period of the binding has ended at now, after which it never grants its rules again.
+func IsExpired(binding Binding, now time.Time) bool {
+	timeBound, ok := binding.(TimeBound)
+	if !ok {
+		return false
+	}
+	notAfter := timeBound.GetNotAfter()
+	return !notAfter.IsZero() && !now.Before(notAfter)
+}
+
 type DefaultBinding struct {
 	Name   string
 	Users  sets.Set[string]
 	Groups sets.Set[string]
 	Rules  []Rule
+	// NotBefore and NotAfter, if set, limit the binding to the period from NotBefore up to but excluding NotAfter.
+	NotBefore time.Time
+	NotAfter  time.Time
+}
+
+func (b *DefaultBinding) GetNotBefore() time.Time {
+	return b.NotBefore
+}
+
+func (b *DefaultBinding) GetNotAfter() time.Time {
+	return b.NotAfter
 }
 
 func (b *DefaultBinding) GetUsers() sets.Set[string] {
@@ -80,14 +124,71 @@ type Rule interface {
 	GetPaths() []string
 }
 
+// DefaultRule matches requests whose attributes are in every one of its lists. An entry of a list matches a value if
+// it is equal to it, if it is "*", or if it ends with "*" and the value starts with the rest of it. A "*" anywhere
+// else is matched literally, Validate rejects it. An empty list of sub-resources matches requests without one, empty
+// resource names and name patterns match every name, other empty lists match nothing.
 type DefaultRule struct {
 	Namespaces    []string
 	APIGroups     []string
 	Resources     []string
 	SubResources  []string
 	ResourceNames []string
-	Verbs         []string
-	Paths         []string
+	// NamePatterns match resource names with globs or regular expressions. A name matches the rule if it matches
+	// ResourceNames or NamePatterns.
+	NamePatterns []NamePattern
+	Verbs        []string
+	Paths        []string
+}
+
+// NamePattern matches resource names with either a glob, with the syntax of path.Match, or a regular expression,
+// which must match the whole name.
+type NamePattern struct {
+	Glob   string `json:"glob,omitempty"`
+	Regexp string `json:"regexp,omitempty"`
+
+	re *regexp.Regexp
+}
+
+// Validate checks the pattern and compiles its regular expression, patterns that haven't been validated compile it
+// on every match.
+func (p *NamePattern) Validate() error {
+	switch {
+	case p.Glob != "" && p.Regexp != "":
+		return fmt.Errorf("name pattern has both a glob %q and a regexp %q", p.Glob, p.Regexp)
+	case p.Glob != "":
+		if _, err := path.Match(p.Glob, ""); err != nil {
+			return fmt.Errorf("invalid name glob %q: %w", p.Glob, err)
+		}
+	case p.Regexp != "":
+		re, err := compileNameRegexp(p.Regexp)
+		if err != nil {
+			return fmt.Errorf("invalid name regexp %q: %w", p.Regexp, err)
+		}
+		p.re = re
+	default:
+		return fmt.Errorf("name pattern has no glob or regexp")
+	}
+	return nil
+}
+
+func (p *NamePattern) Matches(name string) bool {
+	if p.Glob != "" {
+		ok, _ := path.Match(p.Glob, name)
+		return ok
+	}
+	re := p.re
+	if re == nil {
+		var err error
+		if re, err = compileNameRegexp(p.Regexp); err != nil {
+			return false
+		}
+	}
+	return re.MatchString(name)
+}
+
+func compileNameRegexp(expr string) (*regexp.Regexp, error) {
+	return regexp.Compile("^(?:" + expr + ")$")
 }
 
 func (r *DefaultRule) GetNamespaces() []string {
@@ -110,6 +211,10 @@ func (r *DefaultRule) GetResourceNames() []string {
 	return r.ResourceNames
 }
 
+func (r *DefaultRule) GetNamePatterns() []NamePattern {
+	return r.NamePatterns
+}
+
 func (r *DefaultRule) GetVerbs() []string {
 	return r.Verbs
 }
@@ -118,6 +223,7 @@ func (r *DefaultRule) GetPaths() []string {
 	return r.Paths
 }
 
+// Matches returns whether str matches an entry of allowed, entries are matched as described for DefaultRule.
 func Matches(str string, allowed []string) bool {
 	for _, allow := range allowed {
 		if allow == all || str == allow {
@@ -141,7 +247,7 @@ func (r *DefaultRule) Matches(attr authorizer.Attributes) bool {
 	if len(r.SubResources) == 0 && len(attr.GetSubresource()) > 0 && !Matches(all, r.Resources) {
 		return false
 	}
-	if len(r.ResourceNames) > 0 && !Matches(attr.GetName(), r.ResourceNames) {
+	if (len(r.ResourceNames) > 0 || len(r.NamePatterns) > 0) && !r.matchesName(attr.GetName()) {
 		return false
 	}
 	return Matches(attr.GetNamespace(), r.Namespaces) &&
@@ -150,6 +256,47 @@ func (r *DefaultRule) Matches(attr authorizer.Attributes) bool {
 		Matches(attr.GetResource(), r.Resources)
 }
 
+func (r *DefaultRule) matchesName(name string) bool {
+	if Matches(name, r.ResourceNames) {
+		return true
+	}
+	for i := range r.NamePatterns {
+		if r.NamePatterns[i].Matches(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// Validate checks the rule and compiles its name patterns. It should be called when the rule is created, so that
+// mistakes are reported rather than silently matching nothing, or more than intended.
+func (r *DefaultRule) Validate() error {
+	for _, list := range []struct {
+		name   string
+		values []string
+	}{
+		{name: "namespaces", values: r.Namespaces},
+		{name: "apiGroups", values: r.APIGroups},
+		{name: "resources", values: r.Resources},
+		{name: "subResources", values: r.SubResources},
+		{name: "resourceNames", values: r.ResourceNames},
+		{name: "verbs", values: r.Verbs},
+		{name: "paths", values: r.Paths},
+	} {
+		for _, value := range list.values {
+			if i := strings.Index(value, all); i >= 0 && i != len(value)-len(all) {
+				return fmt.Errorf("%s entry %q has a * that isn't at the end, it would be matched literally", list.name, value)
+			}
+		}
+	}
+	for i := range r.NamePatterns {
+		if err := r.NamePatterns[i].Validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 type forUser struct {
 	Binding
 	username string
@@ -171,6 +318,14 @@ func (f *forUser) MatchesUser(user user.Info) bool {
 	return f.username == user.GetName()
 }
 
+func (f *forUser) GetNotBefore() time.Time {
+	return notBefore(f.Binding)
+}
+
+func (f *forUser) GetNotAfter() time.Time {
+	return notAfter(f.Binding)
+}
+
 // ForUser will create a new binding that will match just this user and will ignore the MatchUser behavior of the passed
 // in binding
 func ForUser(username string, binding Binding) Binding {
@@ -223,6 +378,14 @@ func (f *forNamespaceBinding) GetID() string {
 	return f.Binding.GetID() + " namespace:" + f.namespace
 }
 
+func (f *forNamespaceBinding) GetNotBefore() time.Time {
+	return notBefore(f.Binding)
+}
+
+func (f *forNamespaceBinding) GetNotAfter() time.Time {
+	return notAfter(f.Binding)
+}
+
 func (f *forNamespaceBinding) GetRules() []Rule {
 	rules := f.Binding.GetRules()
 	result := make([]Rule, 0, len(rules))
@@ -231,3 +394,17 @@ func (f *forNamespaceBinding) GetRules() []Rule {
 	}
 	return result
 }
+
+func notBefore(binding Binding) time.Time {
+	if timeBound, ok := binding.(TimeBound); ok {
+		return timeBound.GetNotBefore()
+	}
+	return time.Time{}
+}
+
+func notAfter(binding Binding) time.Time {
+	if timeBound, ok := binding.(TimeBound); ok {
+		return timeBound.GetNotAfter()
+	}
+	return time.Time{}
+}
@@ -0,0 +1,60 @@
+package binding
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apiserver/pkg/authorization/authorizer"
+)
+
+func TestResourceNameMatching(t *testing.T) {
+	rule := &DefaultRule{
+		Namespaces:    All,
+		APIGroups:     All,
+		Resources:     All,
+		Verbs:         All,
+		ResourceNames: []string{"exact", "prefix-*"},
+		NamePatterns: []NamePattern{
+			{Glob: "app-?"},
+			{Regexp: "db-[0-9]+"},
+		},
+	}
+	if err := rule.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, expected := range map[string]bool{
+		"exact":      true,
+		"exact-not":  false,
+		"prefix-":    true,
+		"prefix-foo": true,
+		"app-a":      true,
+		"app-ab":     false,
+		"db-12":      true,
+		// regular expressions match the whole name
+		"db-12-backup": false,
+		"my-db-12":     false,
+	} {
+		assert.Equal(t, expected, rule.Matches(authorizer.AttributesRecord{
+			Verb:            "get",
+			Namespace:       "default",
+			Resource:        "configmaps",
+			Name:            name,
+			ResourceRequest: true,
+		}), name)
+	}
+}
+
+func TestValidateRule(t *testing.T) {
+	for _, rule := range []*DefaultRule{
+		{ResourceNames: []string{"app-*-config"}},
+		{Resources: []string{"*s"}},
+		{NamePatterns: []NamePattern{{Glob: "app-["}}},
+		{NamePatterns: []NamePattern{{Regexp: "app-("}}},
+		{NamePatterns: []NamePattern{{Glob: "app-*", Regexp: "app-.*"}}},
+		{NamePatterns: []NamePattern{{}}},
+	} {
+		assert.Error(t, rule.Validate())
+	}
+	assert.NoError(t, (&DefaultRule{Resources: All, ResourceNames: []string{"app-*"}}).Validate())
+}
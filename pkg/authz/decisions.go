@@ -0,0 +1,255 @@
+package authz
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/acorn-io/mink/pkg/logging"
+	"k8s.io/apiserver/pkg/authorization/authorizer"
+)
+
+const (
+	defaultDecisionBufferSize     = 10000
+	defaultDecisionBatchSize      = 100
+	defaultDecisionFlushInterval  = time.Second
+	defaultDecisionPublishTimeout = 10 * time.Second
+)
+
+// Decision is an authorization decision as published to a DecisionSink.
+type Decision struct {
+	Time            time.Time `json:"time"`
+	User            string    `json:"user"`
+	Groups          []string  `json:"groups,omitempty"`
+	Verb            string    `json:"verb"`
+	ResourceRequest bool      `json:"resourceRequest"`
+	Namespace       string    `json:"namespace,omitempty"`
+	APIGroup        string    `json:"apiGroup,omitempty"`
+	Resource        string    `json:"resource,omitempty"`
+	Subresource     string    `json:"subresource,omitempty"`
+	Name            string    `json:"name,omitempty"`
+	Path            string    `json:"path,omitempty"`
+	// Decision is Allow, Deny or NoOpinion.
+	Decision string `json:"decision"`
+	Reason   string `json:"reason,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+// DecisionSink delivers authorization decisions to an external system, such as a SIEM. A batch that fails to publish
+// is logged and dropped, decisions are not retried.
+type DecisionSink interface {
+	Publish(ctx context.Context, decisions []Decision) error
+}
+
+type DecisionSinkFunc func(ctx context.Context, decisions []Decision) error
+
+func (d DecisionSinkFunc) Publish(ctx context.Context, decisions []Decision) error {
+	return d(ctx, decisions)
+}
+
+// DecisionWebhookSink posts the decisions of a batch as a JSON array to URL. Any response other than 2xx is a failure.
+type DecisionWebhookSink struct {
+	URL    string
+	Client *http.Client
+	// Header is added to every request, for example for authentication.
+	Header http.Header
+}
+
+func (w *DecisionWebhookSink) Publish(ctx context.Context, decisions []Decision) error {
+	data, err := json.Marshal(decisions)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.URL, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	for k, v := range w.Header {
+		req.Header[k] = v
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	client := w.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("publishing %d decisions to %s: unexpected status %s", len(decisions), w.URL, resp.Status)
+	}
+	return nil
+}
+
+type DecisionPublisherConfig struct {
+	Sink DecisionSink
+	// SampleRate is the fraction of the decisions allowing a request that are published, from 0 to 1. Every other
+	// decision denies the request and is always published, so the zero value publishes denials only.
+	SampleRate float64
+	// BufferSize is the number of decisions waiting to be published, decisions made while the buffer is full are
+	// dropped. The default is 10000.
+	BufferSize int
+	// BatchSize is the maximum number of decisions published at once. The default is 100.
+	BatchSize int
+	// FlushInterval is how long a decision waits for a batch to fill before it is published. The default is one
+	// second.
+	FlushInterval time.Duration
+	// PublishTimeout is the deadline of a call to the sink. The default is ten seconds.
+	PublishTimeout time.Duration
+}
+
+// DecisionPublisher publishes authorization decisions to a sink in the background. Recording a decision never
+// blocks the request it was made for: decisions are buffered, published in batches, and dropped if the sink falls
+// behind for long enough to fill the buffer.
+type DecisionPublisher struct {
+	config    DecisionPublisherConfig
+	decisions chan Decision
+	dropped   atomic.Uint64
+}
+
+// NewDecisionPublisher returns a publisher of the decisions of the authorizers returned by Authorizer, it publishes
+// them once Start is called.
+func NewDecisionPublisher(config DecisionPublisherConfig) (*DecisionPublisher, error) {
+	if config.Sink == nil {
+		return nil, errors.New("the decision publisher requires a sink")
+	}
+	if config.SampleRate < 0 || config.SampleRate > 1 {
+		return nil, fmt.Errorf("invalid sample rate %v, it must be between 0 and 1", config.SampleRate)
+	}
+	if config.BufferSize <= 0 {
+		config.BufferSize = defaultDecisionBufferSize
+	}
+	if config.BatchSize <= 0 {
+		config.BatchSize = defaultDecisionBatchSize
+	}
+	if config.FlushInterval <= 0 {
+		config.FlushInterval = defaultDecisionFlushInterval
+	}
+	if config.PublishTimeout <= 0 {
+		config.PublishTimeout = defaultDecisionPublishTimeout
+	}
+	return &DecisionPublisher{
+		config:    config,
+		decisions: make(chan Decision, config.BufferSize),
+	}, nil
+}
+
+// Authorizer returns an authorizer that records the decisions of next.
+func (p *DecisionPublisher) Authorizer(next authorizer.Authorizer) authorizer.Authorizer {
+	return authorizer.AuthorizerFunc(func(ctx context.Context, attr authorizer.Attributes) (authorizer.Decision, string, error) {
+		decision, reason, err := next.Authorize(ctx, attr)
+		p.Record(attr, decision, reason, err)
+		return decision, reason, err
+	})
+}
+
+// Record queues a decision to be published if it is sampled. It doesn't block, the decision is dropped if the buffer
+// is full.
+func (p *DecisionPublisher) Record(attr authorizer.Attributes, decision authorizer.Decision, reason string, err error) {
+	if decision == authorizer.DecisionAllow && err == nil && rand.Float64() >= p.config.SampleRate {
+		return
+	}
+
+	d := Decision{
+		Time:            time.Now(),
+		Verb:            attr.GetVerb(),
+		ResourceRequest: attr.IsResourceRequest(),
+		Namespace:       attr.GetNamespace(),
+		APIGroup:        attr.GetAPIGroup(),
+		Resource:        attr.GetResource(),
+		Subresource:     attr.GetSubresource(),
+		Name:            attr.GetName(),
+		Decision:        decisionString(decision),
+		Reason:          reason,
+	}
+	if !d.ResourceRequest {
+		d.Path = attr.GetPath()
+	}
+	if user := attr.GetUser(); user != nil {
+		d.User = user.GetName()
+		d.Groups = user.GetGroups()
+	}
+	if err != nil {
+		d.Error = err.Error()
+	}
+
+	select {
+	case p.decisions <- d:
+	default:
+		p.dropped.Add(1)
+	}
+}
+
+// Dropped returns the number of decisions dropped because the buffer was full.
+func (p *DecisionPublisher) Dropped() uint64 {
+	return p.dropped.Load()
+}
+
+// Start publishes the recorded decisions until ctx is done, then publishes the decisions still buffered.
+func (p *DecisionPublisher) Start(ctx context.Context) {
+	batch := make([]Decision, 0, p.config.BatchSize)
+	ticker := time.NewTicker(p.config.FlushInterval)
+	defer ticker.Stop()
+
+	var reportedDropped uint64
+	flush := func() {
+		if dropped := p.dropped.Load(); dropped != reportedDropped {
+			logging.Default().Warnf("Dropped %d authorization decisions because the publisher fell behind", dropped-reportedDropped)
+			reportedDropped = dropped
+		}
+		if len(batch) == 0 {
+			return
+		}
+		// the sink still gets the last batches after ctx is done
+		publishCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), p.config.PublishTimeout)
+		defer cancel()
+		if err := p.config.Sink.Publish(publishCtx, batch); err != nil {
+			logging.Default().Errorf("Failed to publish %d authorization decisions: %v", len(batch), err)
+		}
+		// the sink may keep the batch
+		batch = make([]Decision, 0, p.config.BatchSize)
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			for {
+				select {
+				case d := <-p.decisions:
+					if batch = append(batch, d); len(batch) == p.config.BatchSize {
+						flush()
+					}
+				default:
+					flush()
+					return
+				}
+			}
+		case d := <-p.decisions:
+			if batch = append(batch, d); len(batch) == p.config.BatchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		}
+	}
+}
+
+func decisionString(decision authorizer.Decision) string {
+	switch decision {
+	case authorizer.DecisionAllow:
+		return "Allow"
+	case authorizer.DecisionDeny:
+		return "Deny"
+	default:
+		return "NoOpinion"
+	}
+}
@@ -0,0 +1,55 @@
+package authz
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apiserver/pkg/authentication/user"
+	"k8s.io/apiserver/pkg/authorization/authorizer"
+)
+
+func TestDecisionPublisher(t *testing.T) {
+	var published []Decision
+	publisher, err := NewDecisionPublisher(DecisionPublisherConfig{
+		Sink: DecisionSinkFunc(func(_ context.Context, decisions []Decision) error {
+			published = append(published, decisions...)
+			return nil
+		}),
+		BufferSize: 2,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a := publisher.Authorizer(authorizer.AuthorizerFunc(func(_ context.Context, attr authorizer.Attributes) (authorizer.Decision, string, error) {
+		if attr.GetVerb() == "get" {
+			return authorizer.DecisionAllow, "", nil
+		}
+		return authorizer.DecisionDeny, "read only", nil
+	}))
+	for _, verb := range []string{"get", "delete", "update", "patch"} {
+		decision, _, _ := a.Authorize(context.Background(), authorizer.AttributesRecord{
+			User:            &user.DefaultInfo{Name: "test"},
+			Verb:            verb,
+			Namespace:       "default",
+			Resource:        "pods",
+			ResourceRequest: true,
+		})
+		assert.Equal(t, verb == "get", decision == authorizer.DecisionAllow)
+	}
+	// the allowed get isn't sampled and the patch doesn't fit in the buffer
+	assert.Equal(t, uint64(1), publisher.Dropped())
+
+	// the buffered decisions are published when the publisher stops
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	publisher.Start(ctx)
+	if assert.Len(t, published, 2) {
+		assert.Equal(t, "delete", published[0].Verb)
+		assert.Equal(t, "update", published[1].Verb)
+		assert.Equal(t, "Deny", published[1].Decision)
+		assert.Equal(t, "read only", published[1].Reason)
+		assert.Equal(t, "test", published[1].User)
+	}
+}
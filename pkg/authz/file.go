@@ -0,0 +1,238 @@
+package authz
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/acorn-io/mink/pkg/authz/binding"
+	"github.com/acorn-io/mink/pkg/logging"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/apiserver/pkg/authentication/user"
+	"k8s.io/apiserver/pkg/authorization/authorizer"
+	kclient "sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
+)
+
+const defaultPolicyReloadInterval = 10 * time.Second
+
+// Policy is the YAML or JSON document read by a FileBindingProvider.
+type Policy struct {
+	Roles    []PolicyRole    `json:"roles,omitempty"`
+	Bindings []PolicyBinding `json:"bindings,omitempty"`
+}
+
+// PolicyRole is a named set of rules that bindings grant.
+type PolicyRole struct {
+	Name  string       `json:"name"`
+	Rules []PolicyRule `json:"rules"`
+}
+
+// PolicyRule is a binding.DefaultRule. Its lists allow everything with "*" and prefixes with a trailing "*". Unlike
+// those of a DefaultRule, empty Namespaces allow every namespace, the namespace of a binding limits them further.
+// Rules are validated when the policy is loaded, see binding.DefaultRule.Validate.
+type PolicyRule struct {
+	Namespaces    []string `json:"namespaces,omitempty"`
+	APIGroups     []string `json:"apiGroups,omitempty"`
+	Resources     []string `json:"resources,omitempty"`
+	SubResources  []string `json:"subResources,omitempty"`
+	ResourceNames []string `json:"resourceNames,omitempty"`
+	// NamePatterns match resource names with globs or regular expressions, see binding.NamePattern.
+	NamePatterns []binding.NamePattern `json:"namePatterns,omitempty"`
+	Verbs        []string              `json:"verbs,omitempty"`
+	Paths        []string              `json:"paths,omitempty"`
+}
+
+// PolicyBinding grants the rules of roles to the users in Users, if set, who are members of one of Groups, if set. At
+// least one of them must be set. If Namespace is set the rules only match requests in that namespace. NotBefore and
+// NotAfter are RFC 3339 times that limit the binding to a period, for access that should lapse on its own.
+type PolicyBinding struct {
+	Name      string     `json:"name"`
+	Users     []string   `json:"users,omitempty"`
+	Groups    []string   `json:"groups,omitempty"`
+	Roles     []string   `json:"roles"`
+	Namespace string     `json:"namespace,omitempty"`
+	NotBefore *time.Time `json:"notBefore,omitempty"`
+	NotAfter  *time.Time `json:"notAfter,omitempty"`
+}
+
+// FileBindingProvider provides the bindings of a policy file, so that the access of system users can be kept under
+// version control instead of compiled into BindingProviders. The file is the source of truth: it is read again when it
+// changes, and bindings removed from it stop matching. A file that fails to load is logged and the bindings read
+// last are kept. Expired bindings are dropped when the file is checked for changes.
+type FileBindingProvider struct {
+	path string
+
+	lock     sync.RWMutex
+	bindings []binding.Binding
+	modTime  time.Time
+	size     int64
+}
+
+// NewFileBindingProvider reads the policy file at path, it fails if the file can't be read or is invalid. The file is
+// checked for changes, and expired bindings are dropped, every ten seconds until ctx is done.
+func NewFileBindingProvider(ctx context.Context, path string) (*FileBindingProvider, error) {
+	f := &FileBindingProvider{
+		path: path,
+	}
+	if err := f.Reload(); err != nil {
+		return nil, err
+	}
+	go wait.UntilWithContext(ctx, func(context.Context) {
+		if err := f.Reload(); err != nil {
+			logging.Default().Errorf("Failed to reload the policy file %s, keeping the previous policy: %v", f.path, err)
+		}
+		f.removeExpired(time.Now())
+	}, defaultPolicyReloadInterval)
+	return f, nil
+}
+
+// Reload reads the policy file again if it changed since it was read last.
+func (f *FileBindingProvider) Reload() error {
+	info, err := os.Stat(f.path)
+	if err != nil {
+		return err
+	}
+
+	f.lock.RLock()
+	unchanged := info.ModTime().Equal(f.modTime) && info.Size() == f.size
+	f.lock.RUnlock()
+	if unchanged {
+		return nil
+	}
+
+	data, err := os.ReadFile(f.path)
+	if err != nil {
+		return err
+	}
+	policy := &Policy{}
+	if err := yaml.UnmarshalStrict(data, policy); err != nil {
+		return fmt.Errorf("invalid policy file %s: %w", f.path, err)
+	}
+	bindings, err := policy.Compile()
+	if err != nil {
+		return fmt.Errorf("invalid policy file %s: %w", f.path, err)
+	}
+
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.bindings = bindings
+	f.modTime = info.ModTime()
+	f.size = info.Size()
+	logging.Default().Infof("Loaded %d bindings from the policy file %s", len(bindings), f.path)
+	return nil
+}
+
+// removeExpired drops the bindings that have expired at now. The Authorizer already ignores them, this keeps them from
+// piling up in the provider and logs when temporary access ends.
+func (f *FileBindingProvider) removeExpired(now time.Time) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	var kept []binding.Binding
+	for _, b := range f.bindings {
+		if binding.IsExpired(b, now) {
+			logging.Default().Infof("Binding %s of the policy file %s expired", b.GetID(), f.path)
+			continue
+		}
+		kept = append(kept, b)
+	}
+	f.bindings = kept
+}
+
+func (f *FileBindingProvider) ForUser(_ context.Context, _ kclient.Client, _ user.Info) ([]binding.Binding, error) {
+	f.lock.RLock()
+	defer f.lock.RUnlock()
+	return f.bindings, nil
+}
+
+func (f *FileBindingProvider) ForAttributes(_ context.Context, _ kclient.Client, _ user.Info, _ authorizer.Attributes) ([]binding.Binding, error) {
+	f.lock.RLock()
+	defer f.lock.RUnlock()
+	return f.bindings, nil
+}
+
+// Compile returns the bindings of the policy, it fails if a binding refers to an unknown role or matches every user.
+func (p *Policy) Compile() ([]binding.Binding, error) {
+	roles := map[string][]binding.Rule{}
+	for _, role := range p.Roles {
+		if role.Name == "" {
+			return nil, fmt.Errorf("a role has no name")
+		}
+		if _, ok := roles[role.Name]; ok {
+			return nil, fmt.Errorf("role %s is defined twice", role.Name)
+		}
+		rules := make([]binding.Rule, 0, len(role.Rules))
+		for _, rule := range role.Rules {
+			namespaces := rule.Namespaces
+			if len(namespaces) == 0 {
+				namespaces = binding.All
+			}
+			defaultRule := &binding.DefaultRule{
+				Namespaces:    namespaces,
+				APIGroups:     rule.APIGroups,
+				Resources:     rule.Resources,
+				SubResources:  rule.SubResources,
+				ResourceNames: rule.ResourceNames,
+				NamePatterns:  rule.NamePatterns,
+				Verbs:         rule.Verbs,
+				Paths:         rule.Paths,
+			}
+			if err := defaultRule.Validate(); err != nil {
+				return nil, fmt.Errorf("invalid rule of role %s: %w", role.Name, err)
+			}
+			rules = append(rules, defaultRule)
+		}
+		roles[role.Name] = rules
+	}
+
+	names := sets.New[string]()
+	result := make([]binding.Binding, 0, len(p.Bindings))
+	for _, b := range p.Bindings {
+		if b.Name == "" {
+			return nil, fmt.Errorf("a binding has no name")
+		}
+		if names.Has(b.Name) {
+			return nil, fmt.Errorf("binding %s is defined twice", b.Name)
+		}
+		names.Insert(b.Name)
+		// a binding.DefaultBinding without users and groups matches everyone, which is never what a policy means
+		if len(b.Users) == 0 && len(b.Groups) == 0 {
+			return nil, fmt.Errorf("binding %s has no users or groups", b.Name)
+		}
+
+		var rules []binding.Rule
+		for _, role := range b.Roles {
+			roleRules, ok := roles[role]
+			if !ok {
+				return nil, fmt.Errorf("binding %s refers to unknown role %s", b.Name, role)
+			}
+			rules = append(rules, roleRules...)
+		}
+
+		defaultBinding := &binding.DefaultBinding{
+			Name:   b.Name,
+			Users:  sets.New(b.Users...),
+			Groups: sets.New(b.Groups...),
+			Rules:  rules,
+		}
+		if b.NotBefore != nil {
+			defaultBinding.NotBefore = *b.NotBefore
+		}
+		if b.NotAfter != nil {
+			defaultBinding.NotAfter = *b.NotAfter
+			if !defaultBinding.NotAfter.After(defaultBinding.NotBefore) {
+				return nil, fmt.Errorf("binding %s is never active, notAfter is not after notBefore", b.Name)
+			}
+		}
+
+		var bound binding.Binding = defaultBinding
+		if b.Namespace != "" {
+			bound = binding.ForNamespaceBinding(b.Namespace, bound)
+		}
+		result = append(result, bound)
+	}
+	return result, nil
+}
@@ -0,0 +1,141 @@
+package authz
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apiserver/pkg/authentication/user"
+	"k8s.io/apiserver/pkg/authorization/authorizer"
+)
+
+func TestFileBindingProvider(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "policy.yaml")
+	write := func(policy string, age time.Duration) {
+		if err := os.WriteFile(path, []byte(policy), 0600); err != nil {
+			t.Fatal(err)
+		}
+		// the file is reloaded when its modification time changes
+		modTime := time.Now().Add(-age)
+		if err := os.Chtimes(path, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(`
+roles:
+- name: reader
+  rules:
+  - apiGroups: ["*"]
+    resources: ["*"]
+    verbs: ["get", "list", "watch"]
+bindings:
+- name: system-readers
+  groups: ["system:readers"]
+  roles: ["reader"]
+  namespace: default
+`, time.Hour)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	provider, err := NewFileBindingProvider(ctx, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := &Authorizer{Providers: []BindingProvider{provider}}
+
+	authorize := func(namespace string) authorizer.Decision {
+		decision, _, err := a.Authorize(ctx, authorizer.AttributesRecord{
+			User:            &user.DefaultInfo{Name: "reader", Groups: []string{"system:readers"}},
+			Verb:            "get",
+			Namespace:       namespace,
+			Resource:        "pods",
+			ResourceRequest: true,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return decision
+	}
+	assert.Equal(t, authorizer.DecisionAllow, authorize("default"))
+	assert.Equal(t, authorizer.DecisionDeny, authorize("other"))
+
+	// an invalid policy keeps the previous one
+	write(`bindings: [{name: everyone, roles: [reader]}]`, time.Minute)
+	assert.Error(t, provider.Reload())
+	assert.Equal(t, authorizer.DecisionAllow, authorize("default"))
+
+	// bindings removed from the file stop matching
+	write(`roles: []`, 0)
+	assert.NoError(t, provider.Reload())
+	assert.Equal(t, authorizer.DecisionDeny, authorize("default"))
+}
+
+func TestTimeBoundBindings(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "policy.yaml")
+	now := time.Now()
+	policy := `
+roles:
+- name: admin
+  rules:
+  - apiGroups: ["*"]
+    resources: ["*"]
+    verbs: ["*"]
+bindings:
+- name: expired
+  users: ["expired"]
+  roles: ["admin"]
+  notAfter: ` + now.Add(-time.Minute).Format(time.RFC3339) + `
+- name: current
+  users: ["current"]
+  roles: ["admin"]
+  notBefore: ` + now.Add(-time.Minute).Format(time.RFC3339) + `
+  notAfter: ` + now.Add(time.Hour).Format(time.RFC3339) + `
+- name: future
+  users: ["future"]
+  roles: ["admin"]
+  notBefore: ` + now.Add(time.Hour).Format(time.RFC3339) + `
+`
+	if err := os.WriteFile(path, []byte(policy), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	provider, err := NewFileBindingProvider(ctx, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := &Authorizer{Providers: []BindingProvider{provider}}
+
+	authorize := func(username string) authorizer.Decision {
+		decision, _, err := a.Authorize(ctx, authorizer.AttributesRecord{
+			User:            &user.DefaultInfo{Name: username},
+			Verb:            "delete",
+			Namespace:       "default",
+			Resource:        "pods",
+			ResourceRequest: true,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return decision
+	}
+	assert.Equal(t, authorizer.DecisionDeny, authorize("expired"))
+	assert.Equal(t, authorizer.DecisionAllow, authorize("current"))
+	assert.Equal(t, authorizer.DecisionDeny, authorize("future"))
+
+	// expired bindings are dropped, bindings that haven't started yet are kept
+	provider.removeExpired(now)
+	bindings, err := provider.ForUser(ctx, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, b := range bindings {
+		names = append(names, b.GetID())
+	}
+	assert.Equal(t, []string{"current", "future"}, names)
+}
@@ -0,0 +1,140 @@
+package authz
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/acorn-io/mink/pkg/strategy"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apiserver/pkg/authorization/authorizer"
+	"k8s.io/apiserver/pkg/endpoints/handlers/responsewriters"
+	"k8s.io/apiserver/pkg/endpoints/request"
+	"k8s.io/apiserver/pkg/endpoints/responsewriter"
+	"k8s.io/client-go/kubernetes/scheme"
+)
+
+// ListScoper is implemented by authorizers that can limit cluster wide lists and watches to the namespaces a user
+// is allowed to see. server.New installs the middleware around the handler chain for such authorizers.
+type ListScoper interface {
+	ListScopeMiddleware(next http.Handler) http.Handler
+}
+
+func isClusterList(attr authorizer.Attributes) bool {
+	return attr.IsResourceRequest() && attr.GetNamespace() == "" && attr.GetName() == "" &&
+		(attr.GetVerb() == "list" || attr.GetVerb() == "watch")
+}
+
+// listNamespaces returns true if the user may list the resource across all namespaces, otherwise the namespaces the
+// user may list it in. Namespace patterns in rules can't be enumerated and are ignored.
+func (a *Authorizer) listNamespaces(ctx context.Context, attr authorizer.Attributes) (bool, []string, error) {
+	bindings, err := a.Bindings(ctx, attr.GetUser())
+	if err != nil {
+		return false, nil, err
+	}
+
+	namespaces := sets.New[string]()
+	for _, binding := range bindings {
+		for _, rule := range binding.GetRules() {
+			if rule.Matches(attr) {
+				return true, nil, nil
+			}
+			for _, namespace := range rule.GetNamespaces() {
+				if strings.Contains(namespace, "*") || namespaces.Has(namespace) {
+					continue
+				}
+				if rule.Matches(withNamespace(attr, namespace)) {
+					namespaces.Insert(namespace)
+				}
+			}
+		}
+	}
+
+	return false, sets.List(namespaces), nil
+}
+
+// ListScopeMiddleware lets Authorize limit cluster wide lists and watches to the namespaces the user is bound to if
+// ScopedList is enabled. It must wrap the whole handler chain of the API server, so the request context it creates is
+// the one authorized, server.New installs it for authorizers that implement ListScoper.
+//
+// Only the storage of mink stores reads the scope. If a scoped request is served by anything else, such as a custom
+// rest.Storage or a delegated API server, the response is replaced with 403 Forbidden instead of returning objects
+// outside the scope.
+func (a *Authorizer) ListScopeMiddleware(next http.Handler) http.Handler {
+	if !a.ScopedList {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		req = req.WithContext(strategy.WithListScopeSlot(req.Context()))
+		next.ServeHTTP(responsewriter.WrapForHTTP1Or2(&scopeEnforcer{ResponseWriter: w, req: req}), req)
+	})
+}
+
+// scopeEnforcer checks that the list scope of the request was read when the response starts, and writes 403 Forbidden
+// instead of the response if it wasn't.
+type scopeEnforcer struct {
+	http.ResponseWriter
+	req              *http.Request
+	checked, allowed bool
+}
+
+func (s *scopeEnforcer) Unwrap() http.ResponseWriter {
+	return s.ResponseWriter
+}
+
+func (s *scopeEnforcer) check() bool {
+	if s.checked {
+		return s.allowed
+	}
+	s.checked = true
+	s.allowed = strategy.ListScopeEnforced(s.req.Context())
+	if !s.allowed {
+		var gr schema.GroupResource
+		if info, ok := request.RequestInfoFrom(s.req.Context()); ok {
+			gr = schema.GroupResource{Group: info.APIGroup, Resource: info.Resource}
+		}
+		responsewriters.ErrorNegotiated(apierrors.NewForbidden(gr, "", errors.New("the storage of this resource can't limit lists to the namespaces you are bound to")),
+			scheme.Codecs, schema.GroupVersion{Version: "v1"}, s.ResponseWriter, s.req)
+	}
+	return s.allowed
+}
+
+func (s *scopeEnforcer) WriteHeader(code int) {
+	if s.check() {
+		s.ResponseWriter.WriteHeader(code)
+	}
+}
+
+func (s *scopeEnforcer) Write(data []byte) (int, error) {
+	if !s.check() {
+		return len(data), nil
+	}
+	return s.ResponseWriter.Write(data)
+}
+
+func (s *scopeEnforcer) Flush() {
+	if s.check() {
+		if flusher, ok := s.ResponseWriter.(http.Flusher); ok {
+			flusher.Flush()
+		}
+	}
+}
+
+type namespacedAttributes struct {
+	authorizer.Attributes
+	namespace string
+}
+
+func (n namespacedAttributes) GetNamespace() string {
+	return n.namespace
+}
+
+func withNamespace(attr authorizer.Attributes, namespace string) authorizer.Attributes {
+	return namespacedAttributes{
+		Attributes: attr,
+		namespace:  namespace,
+	}
+}
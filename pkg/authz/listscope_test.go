@@ -0,0 +1,134 @@
+package authz
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/acorn-io/mink/pkg/authz/binding"
+	"github.com/acorn-io/mink/pkg/strategy"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apiserver/pkg/authentication/user"
+	"k8s.io/apiserver/pkg/authorization/authorizer"
+	kclient "sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+type staticBindings []binding.Binding
+
+func (s staticBindings) ForUser(context.Context, kclient.Client, user.Info) ([]binding.Binding, error) {
+	return s, nil
+}
+
+func (s staticBindings) ForAttributes(context.Context, kclient.Client, user.Info, authorizer.Attributes) ([]binding.Binding, error) {
+	return s, nil
+}
+
+func TestScopedList(t *testing.T) {
+	a := &Authorizer{
+		ScopedList: true,
+		Providers: []BindingProvider{staticBindings{&binding.DefaultBinding{
+			Name:  "reader",
+			Users: sets.New("reader"),
+			Rules: []binding.Rule{&binding.DefaultRule{
+				Namespaces: []string{"a", "b"},
+				APIGroups:  []string{""},
+				Resources:  []string{"pods"},
+				Verbs:      []string{"list"},
+			}},
+		}}},
+	}
+	list := authorizer.AttributesRecord{
+		User:            &user.DefaultInfo{Name: "reader"},
+		Verb:            "list",
+		Resource:        "pods",
+		ResourceRequest: true,
+	}
+
+	// without the middleware the scope can't be enforced, so the list is denied
+	decision, _, err := a.Authorize(context.Background(), list)
+	assert.NoError(t, err)
+	assert.Equal(t, authorizer.DecisionDeny, decision)
+
+	var (
+		scope    *strategy.ListScope
+		decided  authorizer.Decision
+		handlers = a.ListScopeMiddleware(http.HandlerFunc(func(_ http.ResponseWriter, req *http.Request) {
+			decided, _, err = a.Authorize(req.Context(), list)
+			scope = strategy.ListScopeFromContext(req.Context())
+		}))
+	)
+	handlers.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/v1/pods", nil))
+	assert.NoError(t, err)
+	assert.Equal(t, authorizer.DecisionAllow, decided)
+	if assert.NotNil(t, scope) {
+		assert.Equal(t, []string{"a", "b"}, scope.Namespaces)
+	}
+
+	// users that can't list in any namespace are denied, and their requests aren't scoped
+	list.User = &user.DefaultInfo{Name: "other"}
+	handlers.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/v1/pods", nil))
+	assert.NoError(t, err)
+	assert.Equal(t, authorizer.DecisionDeny, decided)
+	assert.Nil(t, scope)
+}
+
+func TestScopedListNotConsumed(t *testing.T) {
+	a := &Authorizer{
+		ScopedList: true,
+		Providers: []BindingProvider{staticBindings{&binding.DefaultBinding{
+			Name:  "reader",
+			Users: sets.New("reader"),
+			Rules: []binding.Rule{&binding.DefaultRule{
+				Namespaces: []string{"a"},
+				APIGroups:  []string{""},
+				Resources:  []string{"pods"},
+				Verbs:      []string{"list"},
+			}},
+		}}},
+	}
+	serve := func(consume bool) *httptest.ResponseRecorder {
+		rw := httptest.NewRecorder()
+		a.ListScopeMiddleware(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			decision, _, err := a.Authorize(req.Context(), authorizer.AttributesRecord{
+				User:            &user.DefaultInfo{Name: "reader"},
+				Verb:            "list",
+				Resource:        "pods",
+				ResourceRequest: true,
+			})
+			if err != nil || decision != authorizer.DecisionAllow {
+				rw.WriteHeader(http.StatusForbidden)
+				return
+			}
+			if consume {
+				strategy.ListScopeFromContext(req.Context())
+			}
+			_, _ = rw.Write([]byte(`{"kind":"PodList"}`))
+		})).ServeHTTP(rw, httptest.NewRequest(http.MethodGet, "/api/v1/pods", nil))
+		return rw
+	}
+
+	// storage that reads the scope serves the list
+	rw := serve(true)
+	assert.Equal(t, http.StatusOK, rw.Code)
+	assert.JSONEq(t, `{"kind":"PodList"}`, rw.Body.String())
+
+	// storage that ignores the scope would return every namespace, the response is replaced
+	rw = serve(false)
+	assert.Equal(t, http.StatusForbidden, rw.Code)
+	status := &metav1.Status{}
+	if assert.NoError(t, json.Unmarshal(rw.Body.Bytes(), status)) {
+		assert.Equal(t, metav1.StatusReasonForbidden, status.Reason)
+	}
+
+	// requests that aren't scoped are left alone
+	rw = httptest.NewRecorder()
+	a.ListScopeMiddleware(http.HandlerFunc(func(rw http.ResponseWriter, _ *http.Request) {
+		_, _ = rw.Write([]byte(`{"kind":"Pod"}`))
+	})).ServeHTTP(rw, httptest.NewRequest(http.MethodGet, "/api/v1/namespaces/b/pods/one", nil))
+	assert.Equal(t, http.StatusOK, rw.Code)
+	assert.JSONEq(t, `{"kind":"Pod"}`, rw.Body.String())
+}
@@ -4,9 +4,9 @@ import (
 	"context"
 	"strings"
 
+	"github.com/acorn-io/mink/pkg/logging"
 	"github.com/acorn-io/mink/pkg/stores"
 	"github.com/acorn-io/mink/pkg/types"
-	"github.com/sirupsen/logrus"
 	authorizationv1 "k8s.io/api/authorization/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apiserver/pkg/authentication/user"
@@ -51,7 +51,7 @@ func (s *Strategy) Create(ctx context.Context, object types.Object) (types.Objec
 	)
 
 	if autoApprove(review) {
-		logrus.Debugf("SubjectAccessReview '%s' #%s: auto approved", review.GetName(), review.GetUID())
+		logging.Default().Debugf("SubjectAccessReview '%s' #%s: auto approved", review.GetName(), review.GetUID())
 		decision = authorizer.DecisionAllow
 	} else if s.Authorizer != nil && review.Spec.NonResourceAttributes == nil && review.Spec.ResourceAttributes != nil {
 		user := &user.DefaultInfo{
@@ -0,0 +1,114 @@
+package subjectaccessreview
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apiserver/pkg/authorization/authorizer"
+	"k8s.io/apiserver/pkg/endpoints/handlers/responsewriters"
+	"k8s.io/apiserver/pkg/endpoints/request"
+	"k8s.io/client-go/kubernetes/scheme"
+)
+
+const (
+	maxBatchSize = 1000
+	// maxBatchBodySize is the size limit of the posted BatchReview, the same as the limit of the API server.
+	maxBatchBodySize = 3 * 1024 * 1024
+)
+
+// BatchReview is a list of resource attributes to authorize for the requesting user.
+type BatchReview struct {
+	Items []authorizationv1.ResourceAttributes `json:"items"`
+}
+
+// BatchReviewResult holds the decision for each item of a BatchReview, in the same order.
+type BatchReviewResult struct {
+	Items []authorizationv1.SubjectAccessReviewStatus `json:"items"`
+}
+
+// NewBatchHandler returns a handler that authorizes many resource attributes for the requesting user in one request,
+// for clients such as UIs that need to know which actions are available on dozens of resources at once. The handler
+// must be served behind authentication, see server.Config.PathHandlers.
+func NewBatchHandler(authorizer Authorizer) http.Handler {
+	return &batchHandler{
+		authorizer: authorizer,
+	}
+}
+
+type batchHandler struct {
+	authorizer Authorizer
+}
+
+func (b *batchHandler) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		writeError(rw, req, apierrors.FromObject(&metav1.Status{
+			Status:  metav1.StatusFailure,
+			Code:    http.StatusMethodNotAllowed,
+			Reason:  metav1.StatusReasonMethodNotAllowed,
+			Message: "only POST is supported",
+		}))
+		return
+	}
+
+	user, ok := request.UserFrom(req.Context())
+	if !ok {
+		writeError(rw, req, apierrors.NewUnauthorized("no user found for request"))
+		return
+	}
+
+	var input BatchReview
+	if err := json.NewDecoder(http.MaxBytesReader(rw, req.Body, maxBatchBodySize)).Decode(&input); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			writeError(rw, req, apierrors.NewRequestEntityTooLargeError(
+				fmt.Sprintf("batch review is larger than %d bytes", maxBatchBodySize)))
+			return
+		}
+		writeError(rw, req, apierrors.NewBadRequest(fmt.Sprintf("invalid batch review: %v", err)))
+		return
+	}
+	if len(input.Items) > maxBatchSize {
+		writeError(rw, req, apierrors.NewRequestEntityTooLargeError(
+			fmt.Sprintf("batch review has %d items, at most %d are allowed", len(input.Items), maxBatchSize)))
+		return
+	}
+
+	result := BatchReviewResult{
+		Items: make([]authorizationv1.SubjectAccessReviewStatus, 0, len(input.Items)),
+	}
+	for _, attr := range input.Items {
+		var status authorizationv1.SubjectAccessReviewStatus
+		decision, reason, err := b.authorizer.Authorize(req.Context(), authorizer.AttributesRecord{
+			User:            user,
+			Verb:            attr.Verb,
+			Namespace:       attr.Namespace,
+			APIGroup:        attr.Group,
+			APIVersion:      attr.Version,
+			Resource:        attr.Resource,
+			Subresource:     attr.Subresource,
+			Name:            attr.Name,
+			ResourceRequest: true,
+		})
+		if err != nil {
+			decision = authorizer.DecisionDeny
+			status.EvaluationError = err.Error()
+		}
+		status.Reason = reason
+		status.Allowed = decision == authorizer.DecisionAllow
+		status.Denied = decision == authorizer.DecisionDeny
+		result.Items = append(result.Items, status)
+	}
+
+	responsewriters.WriteRawJSON(http.StatusOK, result, rw)
+}
+
+// writeError writes err as a Status, like the errors of the API server.
+func writeError(rw http.ResponseWriter, req *http.Request, err error) {
+	responsewriters.ErrorNegotiated(err, scheme.Codecs, schema.GroupVersion{Version: "v1"}, rw, req)
+}
@@ -0,0 +1,66 @@
+package subjectaccessreview
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apiserver/pkg/authentication/user"
+	"k8s.io/apiserver/pkg/authorization/authorizer"
+	"k8s.io/apiserver/pkg/endpoints/request"
+)
+
+type getAuthorizer struct{}
+
+func (getAuthorizer) Authorize(_ context.Context, attr authorizer.Attributes) (authorizer.Decision, string, error) {
+	if attr.GetVerb() == "get" {
+		return authorizer.DecisionAllow, "", nil
+	}
+	return authorizer.DecisionNoOpinion, "", nil
+}
+
+func TestBatchHandler(t *testing.T) {
+	handler := NewBatchHandler(getAuthorizer{})
+	serve := func(method, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/batchreview", strings.NewReader(body))
+		req = req.WithContext(request.WithUser(req.Context(), &user.DefaultInfo{Name: "alice"}))
+		rw := httptest.NewRecorder()
+		handler.ServeHTTP(rw, req)
+		return rw
+	}
+
+	rw := serve(http.MethodPost, `{"items":[{"verb":"get","resource":"things"},{"verb":"delete","resource":"things"}]}`)
+	assert.Equal(t, http.StatusOK, rw.Code)
+	result := &BatchReviewResult{}
+	if assert.NoError(t, json.Unmarshal(rw.Body.Bytes(), result)) && assert.Len(t, result.Items, 2) {
+		assert.True(t, result.Items[0].Allowed)
+		assert.False(t, result.Items[1].Allowed)
+	}
+
+	// errors are Statuses, bodies are limited before they are decoded
+	for _, test := range []struct {
+		name, method, body string
+		reason             metav1.StatusReason
+	}{
+		{name: "method", method: http.MethodGet, reason: metav1.StatusReasonMethodNotAllowed},
+		{name: "invalid", method: http.MethodPost, body: `{"items":`, reason: metav1.StatusReasonBadRequest},
+		{name: "too many items", method: http.MethodPost, body: `{"items":[` + strings.Repeat(`{},`, maxBatchSize) + `{}]}`,
+			reason: metav1.StatusReasonRequestEntityTooLarge},
+		{name: "too large", method: http.MethodPost, body: `{"items":[{"name":"` + strings.Repeat("a", maxBatchBodySize) + `"}]}`,
+			reason: metav1.StatusReasonRequestEntityTooLarge},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			rw := serve(test.method, test.body)
+			status := &metav1.Status{}
+			if assert.NoError(t, json.Unmarshal(rw.Body.Bytes(), status)) {
+				assert.Equal(t, test.reason, status.Reason)
+				assert.Equal(t, int32(rw.Code), status.Code)
+			}
+		})
+	}
+}
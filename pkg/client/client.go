@@ -0,0 +1,111 @@
+package client
+
+import (
+	"context"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/rest"
+	kclient "sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// New returns a client for the types registered in scheme that talks to a mink server. Mink servers don't serve
+// protobuf, so the client always uses JSON.
+func New(cfg *rest.Config, scheme *runtime.Scheme) (kclient.WithWatch, error) {
+	cfg = rest.CopyConfig(cfg)
+	cfg.ContentType = runtime.ContentTypeJSON
+	cfg.AcceptContentTypes = runtime.ContentTypeJSON
+	return kclient.NewWithWatch(cfg, kclient.Options{
+		Scheme: scheme,
+	})
+}
+
+// Typed is a client for a single resource type, so callers get back concrete types instead of having to pass in
+// and type assert objects for every call.
+type Typed[T kclient.Object, L kclient.ObjectList] struct {
+	client    kclient.WithWatch
+	obj       T
+	list      L
+	namespace string
+}
+
+// NewTyped returns a typed client for the type of obj and list. obj and list are only used as prototypes and are
+// never modified.
+func NewTyped[T kclient.Object, L kclient.ObjectList](c kclient.WithWatch, obj T, list L) *Typed[T, L] {
+	return &Typed[T, L]{
+		client: c,
+		obj:    obj,
+		list:   list,
+	}
+}
+
+// Namespace returns a copy of the client that operates in the given namespace.
+func (t Typed[T, L]) Namespace(namespace string) *Typed[T, L] {
+	t.namespace = namespace
+	return &t
+}
+
+func (t *Typed[T, L]) newObj() T {
+	return t.obj.DeepCopyObject().(T)
+}
+
+func (t *Typed[T, L]) newList() L {
+	return t.list.DeepCopyObject().(L)
+}
+
+func (t *Typed[T, L]) Get(ctx context.Context, name string, opts ...kclient.GetOption) (T, error) {
+	obj := t.newObj()
+	err := t.client.Get(ctx, kclient.ObjectKey{Namespace: t.namespace, Name: name}, obj, opts...)
+	return obj, err
+}
+
+func (t *Typed[T, L]) List(ctx context.Context, opts ...kclient.ListOption) (L, error) {
+	list := t.newList()
+	err := t.client.List(ctx, list, t.listOptions(opts)...)
+	return list, err
+}
+
+func (t *Typed[T, L]) Watch(ctx context.Context, opts ...kclient.ListOption) (watch.Interface, error) {
+	return t.client.Watch(ctx, t.newList(), t.listOptions(opts)...)
+}
+
+func (t *Typed[T, L]) Create(ctx context.Context, obj T, opts ...kclient.CreateOption) (T, error) {
+	obj = obj.DeepCopyObject().(T)
+	t.defaultNamespace(obj)
+	err := t.client.Create(ctx, obj, opts...)
+	return obj, err
+}
+
+func (t *Typed[T, L]) Update(ctx context.Context, obj T, opts ...kclient.UpdateOption) (T, error) {
+	obj = obj.DeepCopyObject().(T)
+	t.defaultNamespace(obj)
+	err := t.client.Update(ctx, obj, opts...)
+	return obj, err
+}
+
+func (t *Typed[T, L]) UpdateStatus(ctx context.Context, obj T, opts ...kclient.SubResourceUpdateOption) (T, error) {
+	obj = obj.DeepCopyObject().(T)
+	t.defaultNamespace(obj)
+	err := t.client.Status().Update(ctx, obj, opts...)
+	return obj, err
+}
+
+func (t *Typed[T, L]) Delete(ctx context.Context, name string, opts ...kclient.DeleteOption) error {
+	obj := t.newObj()
+	obj.SetName(name)
+	obj.SetNamespace(t.namespace)
+	return t.client.Delete(ctx, obj, opts...)
+}
+
+func (t *Typed[T, L]) listOptions(opts []kclient.ListOption) []kclient.ListOption {
+	if t.namespace == "" {
+		return opts
+	}
+	return append([]kclient.ListOption{kclient.InNamespace(t.namespace)}, opts...)
+}
+
+func (t *Typed[T, L]) defaultNamespace(obj T) {
+	if obj.GetNamespace() == "" {
+		obj.SetNamespace(t.namespace)
+	}
+}
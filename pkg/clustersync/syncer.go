@@ -0,0 +1,310 @@
+// Package clustersync mirrors objects stored by mink into a Kubernetes cluster, so mink can be the source of truth of
+// objects that are ultimately applied to clusters.
+package clustersync
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/acorn-io/mink/pkg/logging"
+	"github.com/acorn-io/mink/pkg/strategy"
+	"github.com/acorn-io/mink/pkg/types"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/apiserver/pkg/storage"
+	"k8s.io/client-go/util/retry"
+	kclient "sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+)
+
+const (
+	// SyncedByLabel is set on every object in the target cluster to the name of the syncer that owns it. Objects
+	// without it, or with the name of another syncer, are never changed or deleted.
+	SyncedByLabel = "mink.acorn.io/synced-by"
+	// SourceUIDAnnotation is the UID of the mink object a target object was created from.
+	SourceUIDAnnotation = "mink.acorn.io/source-uid"
+)
+
+// Transform changes an object before it is applied to the target cluster, for example to drop fields the cluster
+// doesn't know about. The object has its metadata reduced to name, namespace, labels and annotations.
+type Transform func(ctx context.Context, obj *unstructured.Unstructured) error
+
+// Syncer applies the objects of one type stored in a strategy to a target cluster with server side apply, and
+// deletes them from it when they are deleted. Objects are applied as they are, in the same namespace, so the type
+// must be served by the target cluster.
+type Syncer struct {
+	name   string
+	scheme *runtime.Scheme
+	gvk    schema.GroupVersionKind
+	source strategy.CompleteStrategy
+	target kclient.WithWatch
+
+	selector   labels.Selector
+	syncStatus bool
+	transform  Transform
+}
+
+type Option func(*Syncer)
+
+// WithSelector limits the synced objects to those with matching labels. Objects that stop matching are deleted from
+// the target cluster.
+func WithSelector(selector labels.Selector) Option {
+	return func(s *Syncer) {
+		s.selector = selector
+	}
+}
+
+// WithStatusSync copies the status of the objects in the target cluster back to the mink objects.
+func WithStatusSync() Option {
+	return func(s *Syncer) {
+		s.syncStatus = true
+	}
+}
+
+// WithTransform sets the transform applied to every object before it is applied.
+func WithTransform(transform Transform) Option {
+	return func(s *Syncer) {
+		s.transform = transform
+	}
+}
+
+// New returns a syncer of the objects of the type of obj, stored in source, to target. The name identifies the
+// syncer in the target cluster and is the field manager of the applied objects, it must not change between restarts
+// or objects synced before are orphaned.
+func New(name string, scheme *runtime.Scheme, obj types.Object, source strategy.CompleteStrategy, target kclient.WithWatch, opts ...Option) (*Syncer, error) {
+	gvk, err := apiutil.GVKForObject(obj, scheme)
+	if err != nil {
+		return nil, err
+	}
+	s := &Syncer{
+		name:     name,
+		scheme:   scheme,
+		gvk:      gvk,
+		source:   source,
+		target:   target,
+		selector: labels.Everything(),
+	}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(s)
+		}
+	}
+	return s, nil
+}
+
+// Start syncs until ctx is done. Every time the watch of the source is (re)established all objects are applied and
+// objects synced before whose source is gone are deleted.
+func (s *Syncer) Start(ctx context.Context) {
+	go s.loop(ctx, "objects", s.syncObjects)
+	if s.syncStatus {
+		go s.loop(ctx, "status", s.syncStatuses)
+	}
+}
+
+func (s *Syncer) loop(ctx context.Context, what string, sync func(ctx context.Context) error) {
+	for {
+		if err := sync(ctx); err != nil && ctx.Err() == nil {
+			logging.Default().Errorf("Failed to sync %s of %s with [%s]: %v", what, s.gvk.Kind, s.name, err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(time.Second):
+		}
+	}
+}
+
+func (s *Syncer) syncObjects(ctx context.Context) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	list, err := s.source.List(ctx, "", storage.ListOptions{Predicate: storage.Everything})
+	if err != nil {
+		return err
+	}
+	objs, err := meta.ExtractList(list)
+	if err != nil {
+		return err
+	}
+
+	synced := map[kclient.ObjectKey]bool{}
+	for _, obj := range objs {
+		obj := obj.(types.Object)
+		if !s.selected(obj) {
+			continue
+		}
+		if err := s.apply(ctx, obj); err != nil {
+			return err
+		}
+		synced[kclient.ObjectKeyFromObject(obj)] = true
+	}
+	if err := s.prune(ctx, synced); err != nil {
+		return err
+	}
+
+	events, err := s.source.Watch(ctx, "", storage.ListOptions{
+		ResourceVersion: list.GetResourceVersion(),
+		Predicate:       storage.Everything,
+	})
+	if err != nil {
+		return err
+	}
+
+	for event := range events {
+		switch event.Type {
+		case watch.Added, watch.Modified:
+			obj := event.Object.(types.Object)
+			if s.selected(obj) {
+				err = s.apply(ctx, obj)
+			} else {
+				err = s.delete(ctx, obj)
+			}
+		case watch.Deleted:
+			err = s.delete(ctx, event.Object.(types.Object))
+		case watch.Error:
+			return apierrors.FromObject(event.Object)
+		}
+		if err != nil {
+			// the next watch applies everything again
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Syncer) selected(obj types.Object) bool {
+	return obj.GetDeletionTimestamp().IsZero() && s.selector.Matches(labels.Set(obj.GetLabels()))
+}
+
+func (s *Syncer) apply(ctx context.Context, obj types.Object) error {
+	data, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+	if err != nil {
+		return err
+	}
+	target := &unstructured.Unstructured{Object: data}
+	delete(target.Object, "status")
+	delete(target.Object, "metadata")
+	target.SetGroupVersionKind(s.gvk)
+	target.SetNamespace(obj.GetNamespace())
+	target.SetName(obj.GetName())
+
+	targetLabels := map[string]string{}
+	for k, v := range obj.GetLabels() {
+		targetLabels[k] = v
+	}
+	targetLabels[SyncedByLabel] = s.name
+	target.SetLabels(targetLabels)
+
+	annotations := map[string]string{}
+	for k, v := range obj.GetAnnotations() {
+		annotations[k] = v
+	}
+	annotations[SourceUIDAnnotation] = string(obj.GetUID())
+	target.SetAnnotations(annotations)
+
+	if s.transform != nil {
+		if err := s.transform(ctx, target); err != nil {
+			return err
+		}
+	}
+	return s.target.Patch(ctx, target, kclient.Apply, kclient.FieldOwner(s.name), kclient.ForceOwnership)
+}
+
+// delete deletes the target object of obj if this syncer owns it.
+func (s *Syncer) delete(ctx context.Context, obj types.Object) error {
+	target := &unstructured.Unstructured{}
+	target.SetGroupVersionKind(s.gvk)
+	err := s.target.Get(ctx, kclient.ObjectKeyFromObject(obj), target)
+	if apierrors.IsNotFound(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if target.GetLabels()[SyncedByLabel] != s.name {
+		return nil
+	}
+	uid := target.GetUID()
+	return kclient.IgnoreNotFound(s.target.Delete(ctx, target, kclient.Preconditions{UID: &uid}))
+}
+
+// prune deletes the objects this syncer owns in the target cluster that aren't in synced.
+func (s *Syncer) prune(ctx context.Context, synced map[kclient.ObjectKey]bool) error {
+	list := &unstructured.UnstructuredList{}
+	list.SetGroupVersionKind(s.gvk.GroupVersion().WithKind(s.gvk.Kind + "List"))
+	if err := s.target.List(ctx, list, kclient.MatchingLabels{SyncedByLabel: s.name}); err != nil {
+		return err
+	}
+	for i := range list.Items {
+		if synced[kclient.ObjectKeyFromObject(&list.Items[i])] {
+			continue
+		}
+		if err := kclient.IgnoreNotFound(s.target.Delete(ctx, &list.Items[i])); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Syncer) syncStatuses(ctx context.Context) error {
+	list := &unstructured.UnstructuredList{}
+	list.SetGroupVersionKind(s.gvk.GroupVersion().WithKind(s.gvk.Kind + "List"))
+	w, err := s.target.Watch(ctx, list, kclient.MatchingLabels{SyncedByLabel: s.name})
+	if err != nil {
+		return err
+	}
+	defer w.Stop()
+
+	for event := range w.ResultChan() {
+		switch event.Type {
+		case watch.Added, watch.Modified:
+			if err := s.copyStatus(ctx, event.Object.(*unstructured.Unstructured)); err != nil {
+				return err
+			}
+		case watch.Error:
+			return apierrors.FromObject(event.Object)
+		}
+	}
+	return nil
+}
+
+// copyStatus sets the status of the source object of target to the status of target.
+func (s *Syncer) copyStatus(ctx context.Context, target *unstructured.Unstructured) error {
+	status, ok := target.Object["status"]
+	if !ok {
+		return nil
+	}
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		obj, err := s.source.Get(ctx, target.GetNamespace(), target.GetName())
+		if err != nil {
+			return err
+		}
+		if string(obj.GetUID()) != target.GetAnnotations()[SourceUIDAnnotation] {
+			// the target was created from an object since deleted, it is pruned or replaced
+			return nil
+		}
+
+		data, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+		if err != nil {
+			return err
+		}
+		if equality.Semantic.DeepEqual(data["status"], status) {
+			return nil
+		}
+		data["status"] = status
+
+		updated := s.source.New()
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(data, updated); err != nil {
+			return fmt.Errorf("converting status of %s/%s: %w", target.GetNamespace(), target.GetName(), err)
+		}
+		_, err = s.source.UpdateStatus(ctx, updated)
+		return err
+	})
+	return kclient.IgnoreNotFound(err)
+}
@@ -0,0 +1,162 @@
+package clustersync
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/acorn-io/mink/pkg/db"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	ktypes "k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	kclient "sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+)
+
+// applyAsUpdate stands in for server side apply, which the fake client doesn't support, by creating or replacing the
+// object.
+func applyAsUpdate(ctx context.Context, c kclient.WithWatch, obj kclient.Object, patch kclient.Patch, opts ...kclient.PatchOption) error {
+	if patch.Type() != ktypes.ApplyPatchType {
+		return c.Patch(ctx, obj, patch, opts...)
+	}
+	existing := &unstructured.Unstructured{}
+	existing.SetGroupVersionKind(obj.GetObjectKind().GroupVersionKind())
+	if err := c.Get(ctx, kclient.ObjectKeyFromObject(obj), existing); apierrors.IsNotFound(err) {
+		return c.Create(ctx, obj)
+	} else if err != nil {
+		return err
+	}
+	obj.SetResourceVersion(existing.GetResourceVersion())
+	obj.SetUID(existing.GetUID())
+	return c.Update(ctx, obj)
+}
+
+func newTargetPod(name string, podLabels map[string]string) *unstructured.Unstructured {
+	pod := &unstructured.Unstructured{}
+	pod.SetGroupVersionKind(corev1.SchemeGroupVersion.WithKind("Pod"))
+	pod.SetNamespace("default")
+	pod.SetName(name)
+	pod.SetLabels(podLabels)
+	return pod
+}
+
+func TestSyncer(t *testing.T) {
+	f, err := db.NewFactory(scheme.Scheme, "sqlite://file:"+t.Name()+"?mode=memory&cache=shared")
+	if err != nil {
+		t.Fatal(err)
+	}
+	source, err := f.NewDBStrategy(&corev1.Pod{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// objects of an empty scheme are kept unstructured, as they are by a client of a real cluster
+	target := interceptor.NewClient(fake.NewClientBuilder().WithScheme(runtime.NewScheme()).
+		WithObjects(newTargetPod("stale", map[string]string{SyncedByLabel: "test"}), newTargetPod("foreign", nil)).
+		Build(), interceptor.Funcs{Patch: applyAsUpdate})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	for _, pod := range []*corev1.Pod{
+		{ObjectMeta: metav1.ObjectMeta{Name: "selected", Namespace: "default", Labels: map[string]string{"sync": "true"}}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "ignored", Namespace: "default"}},
+	} {
+		if _, err := source.Create(ctx, pod); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	syncer, err := New("test", scheme.Scheme, &corev1.Pod{}, source, target,
+		WithSelector(labels.SelectorFromSet(labels.Set{"sync": "true"})),
+		WithStatusSync(),
+		WithTransform(func(_ context.Context, obj *unstructured.Unstructured) error {
+			annotations := obj.GetAnnotations()
+			annotations["transformed"] = "true"
+			obj.SetAnnotations(annotations)
+			return nil
+		}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	syncer.Start(ctx)
+
+	getTarget := func(name string) (*unstructured.Unstructured, error) {
+		pod := newTargetPod(name, nil)
+		return pod, target.Get(ctx, kclient.ObjectKeyFromObject(pod), pod)
+	}
+	waitFor := func(description string, done func() bool) {
+		t.Helper()
+		for i := 0; i < 500; i++ {
+			if done() {
+				return
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		t.Fatalf("timed out waiting for %s", description)
+	}
+
+	// selected objects are applied, objects synced before are pruned, others are left alone
+	waitFor("the selected pod to be applied", func() bool {
+		_, err := getTarget("selected")
+		return err == nil
+	})
+	selected, _ := getTarget("selected")
+	sourcePod, err := source.Get(ctx, "default", "selected")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "test", selected.GetLabels()[SyncedByLabel])
+	assert.Equal(t, "true", selected.GetLabels()["sync"])
+	assert.Equal(t, string(sourcePod.GetUID()), selected.GetAnnotations()[SourceUIDAnnotation])
+	assert.Equal(t, "true", selected.GetAnnotations()["transformed"])
+	_, err = getTarget("stale")
+	assert.True(t, apierrors.IsNotFound(err), "expected the stale pod to be pruned, got %v", err)
+	_, err = getTarget("ignored")
+	assert.True(t, apierrors.IsNotFound(err), "expected the ignored pod not to be applied, got %v", err)
+	_, err = getTarget("foreign")
+	assert.NoError(t, err)
+
+	// changes are applied as they are watched
+	sourcePod.(*corev1.Pod).Spec.NodeName = "node1"
+	if _, err := source.Update(ctx, sourcePod); err != nil {
+		t.Fatal(err)
+	}
+	waitFor("the change to be applied", func() bool {
+		selected, err := getTarget("selected")
+		nodeName, _, _ := unstructured.NestedString(selected.Object, "spec", "nodeName")
+		return err == nil && nodeName == "node1"
+	})
+
+	// the status of the target is copied back to the source
+	selected, _ = getTarget("selected")
+	selected.Object["status"] = map[string]any{"phase": "Running"}
+	if err := target.Status().Update(ctx, selected); err != nil {
+		t.Fatal(err)
+	}
+	waitFor("the status to be copied", func() bool {
+		sourcePod, err := source.Get(ctx, "default", "selected")
+		return err == nil && sourcePod.(*corev1.Pod).Status.Phase == corev1.PodRunning
+	})
+
+	// objects that stop matching the selector are deleted from the target
+	sourcePod, err = source.Get(ctx, "default", "selected")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sourcePod.SetLabels(nil)
+	if _, err := source.Update(ctx, sourcePod); err != nil {
+		t.Fatal(err)
+	}
+	waitFor("the deselected pod to be deleted", func() bool {
+		_, err := getTarget("selected")
+		return apierrors.IsNotFound(err)
+	})
+	_, err = getTarget("foreign")
+	assert.NoError(t, err)
+}
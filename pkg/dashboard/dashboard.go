@@ -0,0 +1,479 @@
+// Package dashboard serves a small read-only JSON API for user interfaces: the schemas of the resources the server
+// serves, counts of their objects and summaries of the objects, with a simple search by name. It queries the server
+// through its loopback client as the requesting user, so users only see the objects they are allowed to list. It is
+// a lightweight alternative to the brent module, which pulls in the whole steve API.
+package dashboard
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/acorn-io/mink/pkg/loopback"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apiserver/pkg/server"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/metadata"
+	"k8s.io/client-go/rest"
+)
+
+const (
+	defaultLimit = 100
+	maxLimit     = 1000
+	// maxSearchScanned is the number of objects a search request reads at most, searches of large servers continue
+	// over several requests.
+	maxSearchScanned = 10000
+)
+
+// Schema is a resource that can be listed.
+type Schema struct {
+	// ID is the resource and group, such as deployments.apps, or only the resource for the core group.
+	ID         string   `json:"id"`
+	Group      string   `json:"group,omitempty"`
+	Version    string   `json:"version"`
+	Kind       string   `json:"kind"`
+	Resource   string   `json:"resource"`
+	Namespaced bool     `json:"namespaced"`
+	Verbs      []string `json:"verbs"`
+	ShortNames []string `json:"shortNames,omitempty"`
+	Categories []string `json:"categories,omitempty"`
+}
+
+func (s Schema) gvr() schema.GroupVersionResource {
+	return schema.GroupVersionResource{Group: s.Group, Version: s.Version, Resource: s.Resource}
+}
+
+// Count is the number of objects of a schema the user can list.
+type Count struct {
+	Schema string `json:"schema"`
+	Count  int    `json:"count"`
+	// Namespaces are the counts per namespace of namespaced schemas.
+	Namespaces map[string]int `json:"namespaces,omitempty"`
+	// Error is set if the objects could not be listed, for example because the user isn't allowed to.
+	Error string `json:"error,omitempty"`
+}
+
+// Summary is the part of an object shown in lists.
+type Summary struct {
+	Schema          string            `json:"schema"`
+	Name            string            `json:"name"`
+	Namespace       string            `json:"namespace,omitempty"`
+	UID             types.UID         `json:"uid"`
+	ResourceVersion string            `json:"resourceVersion"`
+	Created         metav1.Time       `json:"created"`
+	Labels          map[string]string `json:"labels,omitempty"`
+	// State is derived from the deletion timestamp, the Ready or Available condition or the phase of the object:
+	// removing, ready, not-ready, the lower case phase, or active if it has none of them.
+	State string `json:"state"`
+	// Message is the message of the condition State was derived from.
+	Message string `json:"message,omitempty"`
+}
+
+type SummaryList struct {
+	Items []Summary `json:"items"`
+	// Continue is passed as the continue parameter to get the next page, it is empty on the last page.
+	Continue string `json:"continue,omitempty"`
+}
+
+// Dashboard serves the dashboard API. Its handlers answer 503 until PostStart ran.
+type Dashboard struct {
+	loopback *loopback.Client
+}
+
+func New() *Dashboard {
+	return &Dashboard{
+		loopback: loopback.New("dashboard"),
+	}
+}
+
+// PostStart takes the loopback client configuration of the server, it must be called from the post start hook of the
+// server, see server.Config.PostStartFunc.
+func (d *Dashboard) PostStart(ctx server.PostStartHookContext) error {
+	return d.loopback.PostStart(ctx)
+}
+
+// PathHandlers returns the handlers of the API under prefix, such as /dashboard, to be added to
+// server.Config.PathHandlers. The authorizer must allow the paths as non-resource URLs.
+//
+//   - GET <prefix>/schemas lists the schemas.
+//   - GET <prefix>/counts?namespace= counts the objects of every schema.
+//   - GET <prefix>/objects?schema=&namespace=&limit=&continue= lists the summaries of the objects of a schema.
+//   - GET <prefix>/search?q=&namespace=&limit=&continue= lists the summaries of the objects of any schema whose name
+//     contains q. A page can have less than limit items and a continue token when the search read too many objects.
+func (d *Dashboard) PathHandlers(prefix string) map[string]http.Handler {
+	prefix = strings.TrimSuffix(prefix, "/")
+	return map[string]http.Handler{
+		prefix + "/schemas": d.handler(d.schemas),
+		prefix + "/counts":  d.handler(d.counts),
+		prefix + "/objects": d.handler(d.objects),
+		prefix + "/search":  d.handler(d.search),
+	}
+}
+
+type clients struct {
+	loopback *loopback.Client
+	user     *rest.Config
+}
+
+func (d *Dashboard) handler(serve func(req *http.Request, c clients) (any, error)) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodGet {
+			http.Error(rw, "only GET is supported", http.StatusMethodNotAllowed)
+			return
+		}
+
+		userConfig, err := d.loopback.ForRequest(req)
+		var result any
+		if err == nil {
+			result, err = serve(req, clients{loopback: d.loopback, user: userConfig})
+		}
+		if err != nil {
+			code := http.StatusInternalServerError
+			var status apierrors.APIStatus
+			if errors.As(err, &status) && status.Status().Code != 0 {
+				code = int(status.Status().Code)
+			}
+			http.Error(rw, err.Error(), code)
+			return
+		}
+
+		rw.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(rw).Encode(result); err != nil {
+			http.Error(rw, err.Error(), http.StatusInternalServerError)
+		}
+	})
+}
+
+// listSchemas returns the schemas of the preferred versions of the resources that can be listed, sorted by ID.
+func listSchemas(c clients) ([]Schema, error) {
+	lists, err := c.loopback.PreferredResources()
+	if err != nil {
+		return nil, err
+	}
+
+	var result []Schema
+	for _, list := range lists {
+		gv, err := schema.ParseGroupVersion(list.GroupVersion)
+		if err != nil {
+			continue
+		}
+		for _, resource := range list.APIResources {
+			if strings.Contains(resource.Name, "/") || !sets.New(resource.Verbs...).Has("list") {
+				continue
+			}
+			id := resource.Name
+			if gv.Group != "" {
+				id += "." + gv.Group
+			}
+			result = append(result, Schema{
+				ID:         id,
+				Group:      gv.Group,
+				Version:    gv.Version,
+				Kind:       resource.Kind,
+				Resource:   resource.Name,
+				Namespaced: resource.Namespaced,
+				Verbs:      resource.Verbs,
+				ShortNames: resource.ShortNames,
+				Categories: resource.Categories,
+			})
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].ID < result[j].ID
+	})
+	return result, nil
+}
+
+func (d *Dashboard) schemas(_ *http.Request, c clients) (any, error) {
+	return listSchemas(c)
+}
+
+func (d *Dashboard) counts(req *http.Request, c clients) (any, error) {
+	schemas, err := listSchemas(c)
+	if err != nil {
+		return nil, err
+	}
+	client, err := metadata.NewForConfig(c.user)
+	if err != nil {
+		return nil, err
+	}
+
+	namespace := req.URL.Query().Get("namespace")
+	result := make([]Count, len(schemas))
+	var wg sync.WaitGroup
+	for i, s := range schemas {
+		wg.Add(1)
+		go func(i int, s Schema) {
+			defer wg.Done()
+			result[i] = count(req.Context(), client, s, namespace)
+		}(i, s)
+	}
+	wg.Wait()
+	return result, nil
+}
+
+func count(ctx context.Context, client metadata.Interface, s Schema, namespace string) Count {
+	result := Count{
+		Schema: s.ID,
+	}
+	if s.Namespaced {
+		result.Namespaces = map[string]int{}
+	}
+
+	opts := metav1.ListOptions{Limit: maxLimit}
+	for {
+		list, err := resourceFor(client, s, namespace).List(ctx, opts)
+		if err != nil {
+			result.Error = err.Error()
+			return result
+		}
+		result.Count += len(list.Items)
+		for _, item := range list.Items {
+			if s.Namespaced {
+				result.Namespaces[item.Namespace]++
+			}
+		}
+		if list.Continue == "" {
+			return result
+		}
+		opts.Continue = list.Continue
+	}
+}
+
+func resourceFor(client metadata.Interface, s Schema, namespace string) metadata.ResourceInterface {
+	if s.Namespaced && namespace != "" {
+		return client.Resource(s.gvr()).Namespace(namespace)
+	}
+	return client.Resource(s.gvr())
+}
+
+func limitFrom(req *http.Request) (int64, error) {
+	v := req.URL.Query().Get("limit")
+	if v == "" {
+		return defaultLimit, nil
+	}
+	limit, err := strconv.ParseInt(v, 10, 64)
+	if err != nil || limit <= 0 || limit > maxLimit {
+		return 0, apierrors.NewBadRequest(fmt.Sprintf("limit must be between 1 and %d", maxLimit))
+	}
+	return limit, nil
+}
+
+func (d *Dashboard) objects(req *http.Request, c clients) (any, error) {
+	query := req.URL.Query()
+	limit, err := limitFrom(req)
+	if err != nil {
+		return nil, err
+	}
+
+	schemas, err := listSchemas(c)
+	if err != nil {
+		return nil, err
+	}
+	var s *Schema
+	for i := range schemas {
+		if schemas[i].ID == query.Get("schema") {
+			s = &schemas[i]
+		}
+	}
+	if s == nil {
+		return nil, apierrors.NewNotFound(schema.GroupResource{Resource: "schemas"}, query.Get("schema"))
+	}
+
+	client, err := dynamic.NewForConfig(c.user)
+	if err != nil {
+		return nil, err
+	}
+	var resource dynamic.ResourceInterface = client.Resource(s.gvr())
+	if namespace := query.Get("namespace"); s.Namespaced && namespace != "" {
+		resource = client.Resource(s.gvr()).Namespace(namespace)
+	}
+	list, err := resource.List(req.Context(), metav1.ListOptions{
+		Limit:    limit,
+		Continue: query.Get("continue"),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	result := SummaryList{
+		Items:    make([]Summary, 0, len(list.Items)),
+		Continue: list.GetContinue(),
+	}
+	for i := range list.Items {
+		result.Items = append(result.Items, summarize(s.ID, &list.Items[i]))
+	}
+	return result, nil
+}
+
+// search lists the objects whose name contains the query, case-insensitively, in the order of the schemas. It only
+// reads metadata, so the summaries have no conditions to derive a state from other than removing.
+func (d *Dashboard) search(req *http.Request, c clients) (any, error) {
+	query := req.URL.Query()
+	q := strings.ToLower(query.Get("q"))
+	if q == "" {
+		return nil, apierrors.NewBadRequest("the q parameter is required")
+	}
+	limit, err := limitFrom(req)
+	if err != nil {
+		return nil, err
+	}
+	from, err := decodeSearchContinue(query.Get("continue"))
+	if err != nil {
+		return nil, err
+	}
+
+	schemas, err := listSchemas(c)
+	if err != nil {
+		return nil, err
+	}
+	client, err := metadata.NewForConfig(c.user)
+	if err != nil {
+		return nil, err
+	}
+
+	return searchSchemas(schemas, q, limit, from, func(s Schema, opts metav1.ListOptions) (*metav1.PartialObjectMetadataList, error) {
+		return resourceFor(client, s, query.Get("namespace")).List(req.Context(), opts)
+	})
+}
+
+// searchContinue is where a search resumes: the page of the schema listed with Continue, after the first Skip
+// objects of the page.
+type searchContinue struct {
+	Schema   string `json:"schema"`
+	Continue string `json:"continue,omitempty"`
+	Skip     int    `json:"skip,omitempty"`
+}
+
+func (c searchContinue) encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeSearchContinue(v string) (searchContinue, error) {
+	var result searchContinue
+	if v == "" {
+		return result, nil
+	}
+	data, err := base64.RawURLEncoding.DecodeString(v)
+	if err == nil {
+		err = json.Unmarshal(data, &result)
+	}
+	if err != nil || result.Schema == "" {
+		return result, apierrors.NewBadRequest("invalid continue parameter")
+	}
+	return result, nil
+}
+
+// searchSchemas lists the schemas, starting at from, a page of maxLimit objects at a time, until limit objects
+// matched or maxSearchScanned objects were scanned. The result continues where the search stopped, so it can have
+// less than limit items and still not be the last page.
+func searchSchemas(schemas []Schema, q string, limit int64, from searchContinue,
+	list func(s Schema, opts metav1.ListOptions) (*metav1.PartialObjectMetadataList, error)) (SummaryList, error) {
+	result := SummaryList{
+		Items: []Summary{},
+	}
+
+	i := 0
+	if from.Schema != "" {
+		i = sort.Search(len(schemas), func(i int) bool {
+			return schemas[i].ID >= from.Schema
+		})
+		if i == len(schemas) || schemas[i].ID != from.Schema {
+			from = searchContinue{}
+		}
+	}
+
+	scanned := 0
+	for ; i < len(schemas); i++ {
+		s := schemas[i]
+		page := searchContinue{Schema: s.ID}
+		if from.Schema == s.ID {
+			page = from
+		}
+		for {
+			objects, err := list(s, metav1.ListOptions{Limit: maxLimit, Continue: page.Continue})
+			if apierrors.IsForbidden(err) || apierrors.IsMethodNotSupported(err) {
+				break
+			} else if err != nil {
+				return result, err
+			}
+			for j := page.Skip; j < len(objects.Items); j++ {
+				item := &objects.Items[j]
+				scanned++
+				if strings.Contains(strings.ToLower(item.Name), q) {
+					result.Items = append(result.Items, summarize(s.ID, item))
+				}
+				if int64(len(result.Items)) == limit || scanned == maxSearchScanned {
+					if j+1 < len(objects.Items) {
+						page.Skip = j + 1
+						result.Continue = page.encode()
+					} else if objects.Continue != "" {
+						result.Continue = searchContinue{Schema: s.ID, Continue: objects.Continue}.encode()
+					} else if i+1 < len(schemas) {
+						result.Continue = searchContinue{Schema: schemas[i+1].ID}.encode()
+					}
+					return result, nil
+				}
+			}
+			if objects.Continue == "" {
+				break
+			}
+			page = searchContinue{Schema: s.ID, Continue: objects.Continue}
+		}
+	}
+	return result, nil
+}
+
+func summarize(schemaID string, obj metav1.Object) Summary {
+	summary := Summary{
+		Schema:          schemaID,
+		Name:            obj.GetName(),
+		Namespace:       obj.GetNamespace(),
+		UID:             obj.GetUID(),
+		ResourceVersion: obj.GetResourceVersion(),
+		Created:         obj.GetCreationTimestamp(),
+		Labels:          obj.GetLabels(),
+		State:           "active",
+	}
+	if !obj.GetDeletionTimestamp().IsZero() {
+		summary.State = "removing"
+		return summary
+	}
+
+	u, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		return summary
+	}
+	conditions, _, _ := unstructured.NestedSlice(u.Object, "status", "conditions")
+	for _, conditionType := range []string{"Ready", "Available"} {
+		for _, c := range conditions {
+			condition, _ := c.(map[string]any)
+			if condition["type"] != conditionType {
+				continue
+			}
+			summary.State = "not-ready"
+			if condition["status"] == string(metav1.ConditionTrue) {
+				summary.State = "ready"
+			}
+			summary.Message, _ = condition["message"].(string)
+			return summary
+		}
+	}
+	if phase, _, _ := unstructured.NestedString(u.Object, "status", "phase"); phase != "" {
+		summary.State = strings.ToLower(phase)
+	}
+	return summary
+}
@@ -0,0 +1,128 @@
+package dashboard
+
+import (
+	"fmt"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestSummarize(t *testing.T) {
+	for _, test := range []struct {
+		name    string
+		status  map[string]any
+		deleted bool
+		state   string
+		message string
+	}{
+		{name: "no status", state: "active"},
+		{name: "phase", status: map[string]any{"phase": "Running"}, state: "running"},
+		{
+			name: "ready condition wins over phase",
+			status: map[string]any{
+				"phase": "Running",
+				"conditions": []any{
+					map[string]any{"type": "Available", "status": "True"},
+					map[string]any{"type": "Ready", "status": "False", "message": "waiting"},
+				},
+			},
+			state:   "not-ready",
+			message: "waiting",
+		},
+		{
+			name:   "available condition",
+			status: map[string]any{"conditions": []any{map[string]any{"type": "Available", "status": "True"}}},
+			state:  "ready",
+		},
+		{name: "deleted", status: map[string]any{"phase": "Running"}, deleted: true, state: "removing"},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			obj := &unstructured.Unstructured{Object: map[string]any{}}
+			obj.SetName("test")
+			if test.status != nil {
+				obj.Object["status"] = test.status
+			}
+			if test.deleted {
+				now := metav1.Now()
+				obj.SetDeletionTimestamp(&now)
+			}
+
+			summary := summarize("pods", obj)
+			assert.Equal(t, "pods", summary.Schema)
+			assert.Equal(t, "test", summary.Name)
+			assert.Equal(t, test.state, summary.State)
+			assert.Equal(t, test.message, summary.Message)
+		})
+	}
+}
+
+func TestSearchSchemas(t *testing.T) {
+	// every tenth object of the first and the last schema matches, the one in between can't be listed
+	schemas := []Schema{{ID: "configmaps"}, {ID: "forbidden"}, {ID: "secrets"}}
+	objects := map[string]int{"configmaps": 2500, "secrets": 25000}
+	var lists int
+	list := func(s Schema, opts metav1.ListOptions) (*metav1.PartialObjectMetadataList, error) {
+		lists++
+		if s.ID == "forbidden" {
+			return nil, apierrors.NewForbidden(schema.GroupResource{Resource: s.ID}, "", fmt.Errorf("denied"))
+		}
+		start := 0
+		if opts.Continue != "" {
+			start, _ = strconv.Atoi(opts.Continue)
+		}
+		result := &metav1.PartialObjectMetadataList{}
+		for i := start; i < objects[s.ID] && i < start+int(opts.Limit); i++ {
+			name := fmt.Sprintf("other-%d", i)
+			if i%10 == 0 {
+				name = fmt.Sprintf("match-%d", i)
+			}
+			result.Items = append(result.Items, metav1.PartialObjectMetadata{ObjectMeta: metav1.ObjectMeta{Name: name}})
+		}
+		if end := start + int(opts.Limit); end < objects[s.ID] {
+			result.Continue = strconv.Itoa(end)
+		}
+		return result, nil
+	}
+
+	seen := map[string]bool{}
+	var (
+		from  searchContinue
+		pages int
+	)
+	for {
+		result, err := searchSchemas(schemas, "match", defaultLimit, from, list)
+		require.NoError(t, err)
+		assert.LessOrEqual(t, len(result.Items), defaultLimit)
+		for _, item := range result.Items {
+			key := item.Schema + "/" + item.Name
+			assert.False(t, seen[key], "%s returned twice", key)
+			seen[key] = true
+		}
+		pages++
+		if result.Continue == "" {
+			break
+		}
+		from, err = decodeSearchContinue(result.Continue)
+		require.NoError(t, err)
+	}
+	assert.Len(t, seen, 250+2500)
+	assert.Equal(t, 28, pages)
+
+	// a search for a name that never matches stops after maxSearchScanned objects
+	lists = 0
+	result, err := searchSchemas(schemas, "nothing", defaultLimit, searchContinue{}, list)
+	require.NoError(t, err)
+	assert.Empty(t, result.Items)
+	assert.NotEmpty(t, result.Continue)
+	// three pages of configmaps, the forbidden list and 7500 secrets
+	assert.Equal(t, 3+1+8, lists)
+
+	_, err = decodeSearchContinue("not a token")
+	assert.True(t, apierrors.IsBadRequest(err))
+}
@@ -39,16 +39,70 @@ func (jsonQuery JSONQueryExpression) Build(builder clause.Builder) {
 		return
 	}
 	switch stmt.Dialector.Name() {
-	case "mysql", "sqlite":
+	case "sqlite":
+		if jsonQuery.exists {
+			// sqlite has no JSON_EXISTS, JSON_TYPE returns NULL for paths that don't exist
+			builder.WriteString("(JSON_TYPE(")
+			builder.WriteQuoted(jsonQuery.column)
+			builder.WriteByte(',')
+			builder.AddVar(stmt, jsonQueryJoin(jsonQuery.path))
+			builder.WriteString(") IS NOT NULL)")
+		} else {
+			// see SQLiteJSONText
+			builder.WriteString("CASE JSON_TYPE(")
+			builder.WriteQuoted(jsonQuery.column)
+			builder.WriteByte(',')
+			builder.AddVar(stmt, jsonQueryJoin(jsonQuery.path))
+			builder.WriteString(") WHEN 'true' THEN 'true' WHEN 'false' THEN 'false' ELSE CAST(JSON_EXTRACT(")
+			builder.WriteQuoted(jsonQuery.column)
+			builder.WriteByte(',')
+			builder.AddVar(stmt, jsonQueryJoin(jsonQuery.path))
+			builder.WriteString(") AS TEXT) END")
+		}
+	case "mysql":
+		// Only use functions that MySQL, MariaDB and TiDB all support. The extracted value is unquoted, so it
+		// compares equal to plain strings on all of them.
+		if jsonQuery.exists {
+			builder.WriteString("JSON_CONTAINS_PATH(")
+			builder.WriteQuoted(jsonQuery.column)
+			builder.WriteString(",'one',")
+			builder.AddVar(stmt, jsonQueryJoin(jsonQuery.path))
+			builder.WriteString(")")
+		} else {
+			builder.WriteString("JSON_UNQUOTE(JSON_EXTRACT(")
+			builder.WriteQuoted(jsonQuery.column)
+			builder.WriteByte(',')
+			builder.AddVar(stmt, jsonQueryJoin(jsonQuery.path))
+			builder.WriteString("))")
+		}
+	case "sqlserver":
+		if jsonQuery.exists {
+			// JSON_VALUE is NULL for objects, arrays and null, so look the key up in its parent instead
+			builder.WriteString("EXISTS (SELECT 1 FROM OPENJSON(")
+			builder.WriteQuoted(jsonQuery.column)
+			builder.WriteByte(',')
+			builder.AddVar(stmt, jsonQueryJoin(jsonQuery.path[:len(jsonQuery.path)-1]))
+			builder.WriteString(") WHERE [key] = ")
+			builder.AddVar(stmt, jsonQuery.path[len(jsonQuery.path)-1])
+			builder.WriteString(")")
+		} else {
+			builder.WriteString("JSON_VALUE(")
+			builder.WriteQuoted(jsonQuery.column)
+			builder.WriteByte(',')
+			builder.AddVar(stmt, jsonQueryJoin(jsonQuery.path))
+			builder.WriteString(")")
+		}
+	case "oracle":
+		// Oracle only accepts the path as a literal, not as a bind variable
 		if jsonQuery.exists {
 			builder.WriteString("JSON_EXISTS(")
 		} else {
-			builder.WriteString("JSON_EXTRACT(")
+			builder.WriteString("JSON_VALUE(")
 		}
 		builder.WriteQuoted(jsonQuery.column)
-		builder.WriteByte(',')
-		builder.AddVar(stmt, jsonQueryJoin(jsonQuery.path))
-		builder.WriteString(")")
+		builder.WriteString(",'")
+		builder.WriteString(strings.ReplaceAll(jsonQueryJoin(jsonQuery.path), "'", "''"))
+		builder.WriteString("')")
 	case "postgres":
 		if jsonQuery.exists {
 			builder.WriteString("jsonb_exists(")
@@ -66,7 +120,23 @@ func (jsonQuery JSONQueryExpression) Build(builder clause.Builder) {
 
 const prefix = "$."
 
+// SQLiteJSONText returns the sqlite expression extracting the value at path of the JSON column as text, like the JSON
+// functions of the other databases do. JSON_EXTRACT returns numbers as numbers and booleans as 1 and 0, which never
+// compare equal to the strings of selectors.
+func SQLiteJSONText(column, path string) string {
+	return "CASE JSON_TYPE(" + column + "," + path + ") WHEN 'true' THEN 'true' WHEN 'false' THEN 'false' " +
+		"ELSE CAST(JSON_EXTRACT(" + column + "," + path + ") AS TEXT) END"
+}
+
+// JSONPath returns the path of keys as it is passed to the JSON functions of sqlite, MySQL, SQL Server and Oracle.
+func JSONPath(keys ...string) string {
+	return jsonQueryJoin(keys)
+}
+
 func jsonQueryJoin(keys []string) string {
+	if len(keys) == 0 {
+		return "$"
+	}
 	if len(keys) == 1 {
 		return prefix + keys[0]
 	}
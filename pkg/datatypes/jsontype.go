@@ -0,0 +1,52 @@
+package datatypes
+
+import (
+	"context"
+	"database/sql/driver"
+
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+// JSON is gorm.io/datatypes.JSON with column types for SQL Server and Oracle, which store JSON as text.
+type JSON []byte
+
+func (j JSON) Value() (driver.Value, error) {
+	return datatypes.JSON(j).Value()
+}
+
+func (j *JSON) Scan(value any) error {
+	return (*datatypes.JSON)(j).Scan(value)
+}
+
+func (j JSON) MarshalJSON() ([]byte, error) {
+	return datatypes.JSON(j).MarshalJSON()
+}
+
+func (j *JSON) UnmarshalJSON(b []byte) error {
+	return (*datatypes.JSON)(j).UnmarshalJSON(b)
+}
+
+func (j JSON) String() string {
+	return string(j)
+}
+
+func (JSON) GormDataType() string {
+	return datatypes.JSON{}.GormDataType()
+}
+
+func (JSON) GormDBDataType(db *gorm.DB, field *schema.Field) string {
+	switch db.Dialector.Name() {
+	case "sqlserver":
+		return "NVARCHAR(MAX)"
+	case "oracle":
+		return "CLOB"
+	}
+	return datatypes.JSON{}.GormDBDataType(db, field)
+}
+
+func (j JSON) GormValue(ctx context.Context, db *gorm.DB) clause.Expr {
+	return datatypes.JSON(j).GormValue(ctx, db)
+}
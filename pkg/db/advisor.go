@@ -0,0 +1,383 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/acorn-io/mink/pkg/datatypes"
+	"gorm.io/gorm/clause"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+const (
+	selectorColumnPrefix     = "sel_"
+	defaultAdvisorMinQueries = 100
+	defaultAdvisorMinAverage = 50 * time.Millisecond
+	defaultAdvisorInterval   = 10 * time.Minute
+)
+
+// SelectorType is the kind of selector a key is used in.
+type SelectorType string
+
+const (
+	SelectorTypeLabel SelectorType = "label"
+	SelectorTypeField SelectorType = "field"
+)
+
+// IndexAdvisorConfig configures when the index advisor recommends indexing a selector key.
+type IndexAdvisorConfig struct {
+	// MinQueries is the number of queries that must have used a key before it is recommended. The default is 100.
+	MinQueries uint64
+	// MinAverage is the average duration of those queries above which the key is recommended. The default is 50ms.
+	MinAverage time.Duration
+	// AutoCreate adds the generated columns and indexes of recommended keys to the table, checking every Interval.
+	// Adding a column can lock or rewrite the table, depending on the database.
+	AutoCreate bool
+	// Interval is the time between checks for AutoCreate. The default is 10 minutes.
+	Interval time.Duration
+}
+
+// SelectorStats are the queries of a table that used a label or field selector key since the server started.
+type SelectorStats struct {
+	Table   string
+	Type    SelectorType
+	Key     string
+	Queries uint64
+	Total   time.Duration
+	Max     time.Duration
+	// Indexed is true if the key is served by a generated column.
+	Indexed bool
+}
+
+func (s SelectorStats) Average() time.Duration {
+	if s.Queries == 0 {
+		return 0
+	}
+	return s.Total / time.Duration(s.Queries)
+}
+
+// IndexRecommendation is a selector key worth indexing, with the statements that index it.
+type IndexRecommendation struct {
+	SelectorStats
+	// Column is the generated column holding the values of the key.
+	Column string
+	// Statements add the column and its index, they are empty if the database or key isn't supported.
+	Statements []string
+}
+
+type selectorKey struct {
+	selectorType SelectorType
+	key          string
+}
+
+type indexAdvisor struct {
+	config IndexAdvisorConfig
+
+	statsLock sync.Mutex
+	stats     map[selectorKey]*SelectorStats
+}
+
+// WithIndexAdvisor records which label and field selector keys queries of the table use and how long they take, so
+// generated columns and indexes can be recommended for the most expensive ones, or created if config.AutoCreate is
+// set. Queries use the generated columns of keys once they exist, whichever server created them.
+func WithIndexAdvisor(config IndexAdvisorConfig) StrategyOption {
+	if config.MinQueries == 0 {
+		config.MinQueries = defaultAdvisorMinQueries
+	}
+	if config.MinAverage == 0 {
+		config.MinAverage = defaultAdvisorMinAverage
+	}
+	if config.Interval == 0 {
+		config.Interval = defaultAdvisorInterval
+	}
+	return func(s *Strategy) {
+		if g, ok := s.db.(*GormDB); ok {
+			g.advisor = &indexAdvisor{
+				config: config,
+				stats:  map[selectorKey]*SelectorStats{},
+			}
+		}
+	}
+}
+
+// selectorKeys returns the keys of the selectors of criteria that can be indexed.
+func selectorKeys(criteria Criteria) (result []selectorKey) {
+	if criteria.LabelSelector != nil {
+		reqs, _ := criteria.LabelSelector.Requirements()
+		for _, req := range reqs {
+			if req.Key() != "" {
+				result = append(result, selectorKey{selectorType: SelectorTypeLabel, key: req.Key()})
+			}
+		}
+	}
+	if criteria.FieldSelector != nil {
+		for _, req := range criteria.FieldSelector.Requirements() {
+			if _, _, ok := fieldSelectorPath(req.Field); ok {
+				result = append(result, selectorKey{selectorType: SelectorTypeField, key: req.Field})
+			}
+		}
+	}
+	return result
+}
+
+func (g *GormDB) recordSelectors(criteria Criteria, duration time.Duration) {
+	if g.advisor == nil {
+		return
+	}
+	keys := selectorKeys(criteria)
+	if len(keys) == 0 {
+		return
+	}
+
+	g.advisor.statsLock.Lock()
+	defer g.advisor.statsLock.Unlock()
+	for _, key := range keys {
+		stats := g.advisor.stats[key]
+		if stats == nil {
+			stats = &SelectorStats{
+				Table: g.tableName,
+				Type:  key.selectorType,
+				Key:   key.key,
+			}
+			g.advisor.stats[key] = stats
+		}
+		stats.Queries++
+		stats.Total += duration
+		if duration > stats.Max {
+			stats.Max = duration
+		}
+	}
+}
+
+// selectorColumn returns the expression selector keys are compared with, the generated column of the key if it
+// exists or else the JSON path of the key in column.
+func (g *GormDB) selectorColumn(selectorType SelectorType, key, column string, path ...string) any {
+	if name := selectorColumnName(selectorType, key); g.hasSelectorColumn(name) {
+		return clause.Column{Name: name}
+	}
+	return datatypes.JSONQuery(column).Value(path...)
+}
+
+// selectorColumnName returns a column name that is valid on every supported database and unique per key.
+func selectorColumnName(selectorType SelectorType, key string) string {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(string(selectorType) + ":" + key))
+
+	sanitized := []byte(strings.ToLower(key))
+	for i, c := range sanitized {
+		if (c < 'a' || c > 'z') && (c < '0' || c > '9') {
+			sanitized[i] = '_'
+		}
+	}
+	if len(sanitized) > 30 {
+		sanitized = sanitized[:30]
+	}
+	return fmt.Sprintf("%s%c_%08x_%s", selectorColumnPrefix, selectorType[0], h.Sum32(), sanitized)
+}
+
+// SelectorStats returns the selector keys used by queries of the table, or nil if the index advisor isn't enabled.
+func (g *GormDB) SelectorStats() []SelectorStats {
+	if g.advisor == nil {
+		return nil
+	}
+
+	g.advisor.statsLock.Lock()
+	result := make([]SelectorStats, 0, len(g.advisor.stats))
+	for key, stats := range g.advisor.stats {
+		s := *stats
+		s.Indexed = g.hasSelectorColumn(selectorColumnName(key.selectorType, key.key))
+		result = append(result, s)
+	}
+	g.advisor.statsLock.Unlock()
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Total > result[j].Total
+	})
+	return result
+}
+
+// IndexRecommendations returns the selector keys that aren't indexed and whose queries exceed the thresholds of the
+// index advisor, the most expensive first.
+func (g *GormDB) IndexRecommendations() []IndexRecommendation {
+	var result []IndexRecommendation
+	for _, stats := range g.SelectorStats() {
+		if stats.Indexed || stats.Queries < g.advisor.config.MinQueries || stats.Average() < g.advisor.config.MinAverage {
+			continue
+		}
+		column := selectorColumnName(stats.Type, stats.Key)
+		result = append(result, IndexRecommendation{
+			SelectorStats: stats,
+			Column:        column,
+			Statements:    g.indexStatements(stats.Type, stats.Key, column),
+		})
+	}
+	return result
+}
+
+// mysqlFieldColumnLength is the number of characters of the values of a field held by its generated column on mysql.
+// Values that are longer are truncated, which keeps the column and its index small.
+const mysqlFieldColumnLength = 255
+
+func sqlString(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
+
+func (g *GormDB) indexStatements(selectorType SelectorType, key, column string) []string {
+	if g.db == nil {
+		return nil
+	}
+
+	jsonColumn, path := "metadata", []string{"labels", key}
+	if selectorType == SelectorTypeField {
+		var ok bool
+		if jsonColumn, path, ok = fieldSelectorPath(key); !ok {
+			return nil
+		}
+	}
+
+	var columnDefinition string
+	switch g.db.Dialector.Name() {
+	case "sqlite":
+		columnDefinition = fmt.Sprintf("TEXT GENERATED ALWAYS AS (%s) VIRTUAL",
+			datatypes.SQLiteJSONText(g.quote(jsonColumn), sqlString(datatypes.JSONPath(path...))))
+	case "mysql":
+		value := fmt.Sprintf("JSON_UNQUOTE(JSON_EXTRACT(%s,%s))", g.quote(jsonColumn), sqlString(datatypes.JSONPath(path...)))
+		if selectorType == SelectorTypeLabel {
+			columnDefinition = fmt.Sprintf("VARCHAR(63) GENERATED ALWAYS AS (%s) VIRTUAL", value)
+			break
+		}
+		// field values have no length limit, a value too long for the column would fail the write of its object, so
+		// the column only holds their beginning, see truncatedSelectorColumn
+		columnDefinition = fmt.Sprintf("VARCHAR(%d) GENERATED ALWAYS AS (LEFT(%s,%d)) VIRTUAL",
+			mysqlFieldColumnLength, value, mysqlFieldColumnLength)
+	case "postgres":
+		args := make([]string, 0, len(path))
+		for _, p := range path {
+			args = append(args, sqlString(p))
+		}
+		columnDefinition = fmt.Sprintf("TEXT GENERATED ALWAYS AS (jsonb_extract_path_text(%s,%s)) STORED",
+			g.quote(jsonColumn), strings.Join(args, ","))
+	default:
+		return nil
+	}
+
+	return []string{
+		fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", g.quote(g.tableName), g.quote(column), columnDefinition),
+		fmt.Sprintf("CREATE INDEX %s ON %s (%s)", g.quote(g.selectorIndexName(column)), g.quote(g.tableName), g.quote(column)),
+	}
+}
+
+// selectorIndexName returns the name of the index of a generated selector column, unique per table.
+func (g *GormDB) selectorIndexName(column string) string {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(g.tableName))
+	return fmt.Sprintf("idx_%s_%08x", column, h.Sum32())
+}
+
+// truncatedSelectorColumn returns true if the generated column of the selector key only holds the first
+// mysqlFieldColumnLength characters of the values, and the values of a query can be longer.
+func (g *GormDB) truncatedSelectorColumn(selectorType SelectorType, value string) bool {
+	return selectorType == SelectorTypeField && g.db.Dialector.Name() == "mysql" &&
+		utf8.RuneCountInString(value) > mysqlFieldColumnLength
+}
+
+// CreateIndex runs the statements of the recommendation, after which queries use its column.
+func (g *GormDB) CreateIndex(ctx context.Context, recommendation IndexRecommendation) error {
+	if g.advisor == nil || len(recommendation.Statements) == 0 {
+		return fmt.Errorf("indexing %s selector key %s of %s is not supported", recommendation.Type, recommendation.Key, g.tableName)
+	}
+	for _, statement := range recommendation.Statements {
+		if err := g.db.WithContext(ctx).Exec(statement).Error; err != nil {
+			return err
+		}
+	}
+
+	g.addSelectorColumn(recommendation.Column)
+	return nil
+}
+
+// refreshColumns reads the generated selector columns of the table, including those created by other servers.
+func (g *GormDB) refreshColumns(ctx context.Context) error {
+	columnTypes, err := g.db.WithContext(ctx).Migrator().ColumnTypes(g.tableName)
+	if err != nil {
+		return err
+	}
+
+	columns := map[string]bool{}
+	for _, columnType := range columnTypes {
+		if strings.HasPrefix(columnType.Name(), selectorColumnPrefix) {
+			columns[columnType.Name()] = true
+		}
+	}
+
+	g.columnsLock.Lock()
+	g.columns = columns
+	g.columnsLock.Unlock()
+	return nil
+}
+
+func (g *GormDB) hasSelectorColumn(name string) bool {
+	g.columnsLock.RLock()
+	defer g.columnsLock.RUnlock()
+	return g.columns[name]
+}
+
+func (g *GormDB) addSelectorColumn(name string) {
+	g.columnsLock.Lock()
+	defer g.columnsLock.Unlock()
+	if g.columns == nil {
+		g.columns = map[string]bool{}
+	}
+	g.columns[name] = true
+}
+
+func (g *GormDB) advise(ctx context.Context) {
+	for {
+		if err := g.refreshColumns(ctx); err != nil {
+			g.log.Errorf("Failed to read the columns of [%s]: %v", g.tableName, err)
+		} else if g.advisor.config.AutoCreate {
+			for _, recommendation := range g.IndexRecommendations() {
+				if len(recommendation.Statements) == 0 {
+					continue
+				}
+				if err := g.CreateIndex(ctx, recommendation); err != nil {
+					g.log.Errorf("Failed to index %s selector key [%s] of [%s]: %v", recommendation.Type, recommendation.Key, g.tableName, err)
+					continue
+				}
+				g.log.Infof("Indexed %s selector key [%s] of [%s] in column [%s], average query time was %s",
+					recommendation.Type, recommendation.Key, g.tableName, recommendation.Column, recommendation.Average())
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(wait.Jitter(g.advisor.config.Interval, 0.1)):
+		}
+	}
+}
+
+// IndexRecommendations returns the recommendations of the index advisors of the tables of the strategies created by
+// the factory.
+func (f *Factory) IndexRecommendations() []IndexRecommendation {
+	f.strategiesLock.Lock()
+	strategies := f.strategies
+	f.strategiesLock.Unlock()
+
+	var result []IndexRecommendation
+	for _, s := range strategies {
+		if g, ok := s.db.(*GormDB); ok {
+			result = append(result, g.IndexRecommendations()...)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Total > result[j].Total
+	})
+	return result
+}
@@ -0,0 +1,66 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// Backend stores the tables of a factory somewhere other than a SQL database, such as a key-value store or memory.
+// The strategies created by the factory translate between objects and records, enforce resource versions and
+// serve watches on top of the DB of each table, see DB for what a DB must provide. Strategy options that tune the
+// SQL database have no effect on other backends, and the factory features built on SQL tables, such as queues,
+// publishers and the global sequence, fail with ErrSQLRequired.
+type Backend interface {
+	// NewDB returns the DB of the table storing the objects of the given kind. It is called once per strategy and
+	// started with the strategy.
+	NewDB(tableName string, gvk schema.GroupVersionKind) (DB, error)
+	// Ping checks that the backend is reachable, for the health check of the factory.
+	Ping(ctx context.Context) error
+}
+
+// OpenBackend opens a Backend from a DSN, without the prefix it was registered with.
+type OpenBackend func(dsn string) (Backend, error)
+
+// ErrSQLRequired is returned by factory features that are only supported with a SQL database.
+var ErrSQLRequired = errors.New("only supported by SQL databases")
+
+var (
+	backendsLock sync.RWMutex
+	backends     = map[string]OpenBackend{}
+)
+
+// RegisterBackend makes NewFactory open DSNs starting with prefix, such as "memory://", with open instead of a SQL
+// database. It is typically called from the init function of the package implementing the backend, and panics if
+// the prefix is already registered.
+func RegisterBackend(prefix string, open OpenBackend) {
+	backendsLock.Lock()
+	defer backendsLock.Unlock()
+	if _, ok := backends[prefix]; ok {
+		panic(fmt.Sprintf("backend %s is already registered", prefix))
+	}
+	backends[prefix] = open
+}
+
+func openBackend(dsn string) (Backend, bool, error) {
+	backendsLock.RLock()
+	defer backendsLock.RUnlock()
+	for prefix, open := range backends {
+		if strings.HasPrefix(dsn, prefix) {
+			b, err := open(strings.TrimPrefix(dsn, prefix))
+			return b, true, err
+		}
+	}
+	return nil, false, nil
+}
+
+func (f *Factory) requireSQL(feature string) error {
+	if f.DB == nil {
+		return fmt.Errorf("%s: %w", feature, ErrSQLRequired)
+	}
+	return nil
+}
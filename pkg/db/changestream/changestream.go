@@ -0,0 +1,244 @@
+// Package changestream serves the change stream of the tables of a db.Factory over gRPC, for consumers outside of
+// Kubernetes that want a binary feed of changes without speaking the watch protocol. The service is described by
+// changestream.proto.
+package changestream
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative changestream.proto
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/acorn-io/mink/pkg/db"
+	"github.com/acorn-io/mink/pkg/db/errtypes"
+	"github.com/acorn-io/mink/pkg/strategy"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/apiserver/pkg/authentication/authenticator"
+	"k8s.io/apiserver/pkg/authorization/authorizer"
+)
+
+const (
+	defaultBatchSize    = 100
+	defaultPollInterval = time.Second
+)
+
+type Config struct {
+	Factory *db.Factory
+	// Resources are the tables served, mapped to the resource a client must be allowed to watch to stream the
+	// changes of the table. Changes of other tables are not served.
+	Resources map[string]schema.GroupResource
+	// Authenticator authenticates clients by the headers of their calls, such as the bearer token in the
+	// authorization header.
+	Authenticator authenticator.Request
+	Authorizer    authorizer.Authorizer
+	// Partitions extract the partition ID of each call from its headers, like db.PartitionMiddleware does for HTTP
+	// requests. Calls with a partition ID only stream the changes of their partition.
+	Partitions *db.PartitionOptions
+	// Scrubbers, keyed by table, remove fields from the objects of the changes of the table before they are streamed,
+	// like the Scrubber of the watches of the resource, see db.Factory.ScrubChanges.
+	Scrubbers map[string]strategy.Scrubber
+	// PollInterval is how often a stream that has caught up checks for new changes. The default is one second.
+	PollInterval time.Duration
+}
+
+type server struct {
+	UnimplementedChangeStreamServer
+	Config
+}
+
+// NewServer returns a gRPC server serving the change stream. Every call is authenticated and must be allowed to watch
+// the resource of the table, in the namespace of the request or in all namespaces. opts are passed to grpc.NewServer,
+// for example the transport credentials.
+func NewServer(config Config, opts ...grpc.ServerOption) (*grpc.Server, error) {
+	if config.Factory == nil {
+		return nil, errors.New("the change stream requires a factory")
+	}
+	if config.Authenticator == nil || config.Authorizer == nil {
+		return nil, errors.New("the change stream requires an authenticator and an authorizer")
+	}
+	if config.PollInterval <= 0 {
+		config.PollInterval = defaultPollInterval
+	}
+
+	s := grpc.NewServer(opts...)
+	RegisterChangeStreamServer(s, &server{Config: config})
+	return s, nil
+}
+
+// Watch calls the Watch method of the change stream served on conn, and calls handle with every change until the
+// context is canceled, or handle or the stream fails.
+func Watch(ctx context.Context, conn grpc.ClientConnInterface, req *WatchRequest, handle func(*db.ChangeEvent) error, opts ...grpc.CallOption) error {
+	stream, err := NewChangeStreamClient(conn).Watch(ctx, req, opts...)
+	if err != nil {
+		return err
+	}
+	for {
+		event, err := stream.Recv()
+		if err != nil {
+			return err
+		}
+		if err := handle(&db.ChangeEvent{
+			Type:            watch.EventType(event.Type),
+			Table:           event.Table,
+			ResourceVersion: uint(event.ResourceVersion),
+			Object:          event.Object,
+		}); err != nil {
+			return err
+		}
+	}
+}
+
+func (s *server) Watch(req *WatchRequest, stream ChangeStream_WatchServer) error {
+	ctx := stream.Context()
+	resource, ok := s.Resources[req.Table]
+	if !ok {
+		return status.Errorf(codes.NotFound, "table %q is not served", req.Table)
+	}
+	selector, err := labels.Parse(req.LabelSelector)
+	if err != nil {
+		return status.Errorf(codes.InvalidArgument, "invalid label selector: %v", err)
+	}
+	httpReq, err := s.authorize(ctx, req, resource)
+	if err != nil {
+		return err
+	}
+	if s.Partitions != nil {
+		partitionID, err := s.Partitions.PartitionID(httpReq)
+		if err != nil {
+			return status.Error(codes.InvalidArgument, err.Error())
+		}
+		if partitionID == "" && s.Partitions.Required {
+			return status.Error(codes.InvalidArgument, "partition ID is required")
+		}
+		if partitionID != "" {
+			ctx = db.ContextWithPartitionID(ctx, partitionID)
+		}
+	}
+
+	position := uint(req.ResourceVersion)
+	if position == 0 {
+		if position, err = s.Factory.LatestChange(ctx, req.Table); err != nil {
+			return toStatus(err)
+		}
+	}
+
+	scrubber := s.Scrubbers[req.Table]
+	for {
+		batch, err := s.Factory.ReadChanges(ctx, req.Table, position, defaultBatchSize)
+		if err != nil {
+			return toStatus(err)
+		}
+		if scrubber != nil {
+			if err := s.Factory.ScrubChanges(ctx, scrubber, batch.Events); err != nil {
+				return toStatus(err)
+			}
+		}
+		for i := range batch.Events {
+			event := &batch.Events[i]
+			if ok, err := matches(event, req.Namespace, selector); err != nil {
+				return status.Errorf(codes.Internal, "invalid object: %v", err)
+			} else if !ok {
+				continue
+			}
+			if err := stream.Send(&ChangeEvent{
+				Type:            string(event.Type),
+				Table:           event.Table,
+				ResourceVersion: uint64(event.ResourceVersion),
+				Object:          event.Object,
+			}); err != nil {
+				return err
+			}
+		}
+
+		if batch.Position != position {
+			position = batch.Position
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return status.FromContextError(ctx.Err()).Err()
+		case <-time.After(s.PollInterval):
+		}
+	}
+}
+
+// authorize authenticates the caller with the headers of the call and checks it may watch resource. It returns the
+// headers of the call as an HTTP request.
+func (s *server) authorize(ctx context.Context, req *WatchRequest, resource schema.GroupResource) (*http.Request, error) {
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodGet, "/", nil)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	md, _ := metadata.FromIncomingContext(ctx)
+	for key, values := range md {
+		for _, value := range values {
+			httpReq.Header.Add(key, value)
+		}
+	}
+
+	resp, ok, err := s.Authenticator.AuthenticateRequest(httpReq)
+	if err != nil || !ok {
+		return nil, status.Error(codes.Unauthenticated, "unauthenticated")
+	}
+
+	decision, reason, err := s.Authorizer.Authorize(ctx, authorizer.AttributesRecord{
+		User:            resp.User,
+		Verb:            "watch",
+		Namespace:       req.Namespace,
+		APIGroup:        resource.Group,
+		Resource:        resource.Resource,
+		ResourceRequest: true,
+	})
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "authorizing: %v", err)
+	}
+	if decision != authorizer.DecisionAllow {
+		return nil, status.Errorf(codes.PermissionDenied, "%s may not watch %s: %s", resp.User.GetName(), resource, reason)
+	}
+	return httpReq, nil
+}
+
+func matches(event *db.ChangeEvent, namespace string, selector labels.Selector) (bool, error) {
+	if namespace == "" && selector.Empty() {
+		return true, nil
+	}
+	var obj struct {
+		Metadata struct {
+			Namespace string            `json:"namespace"`
+			Labels    map[string]string `json:"labels"`
+		} `json:"metadata"`
+	}
+	if err := json.Unmarshal(event.Object, &obj); err != nil {
+		return false, err
+	}
+	if namespace != "" && obj.Metadata.Namespace != namespace {
+		return false, nil
+	}
+	return selector.Matches(labels.Set(obj.Metadata.Labels)), nil
+}
+
+func toStatus(err error) error {
+	if errors.Is(err, db.ErrUnknownTable) {
+		return status.Error(codes.NotFound, err.Error())
+	}
+	if errors.Is(err, errtypes.ErrCompacted) {
+		return status.Error(codes.OutOfRange, err.Error())
+	}
+	if errors.Is(err, errtypes.ErrPartitionRequired) {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return status.FromContextError(err).Err()
+	}
+	return status.Error(codes.Internal, fmt.Sprintf("reading changes: %v", err))
+}
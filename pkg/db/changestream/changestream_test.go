@@ -0,0 +1,248 @@
+package changestream
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/acorn-io/mink/pkg/authn"
+	"github.com/acorn-io/mink/pkg/db"
+	"github.com/acorn-io/mink/pkg/strategy"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apiserver/pkg/authorization/authorizer"
+	"k8s.io/apiserver/pkg/storage"
+	"k8s.io/client-go/kubernetes/scheme"
+)
+
+func newTestFactory(t *testing.T) (*db.Factory, strategy.CompleteStrategy) {
+	f, err := db.NewFactory(scheme.Scheme, "sqlite://file:"+t.Name()+"?mode=memory&cache=shared")
+	if err != nil {
+		t.Fatal(err)
+	}
+	store, err := f.NewDBStrategy(&corev1.Pod{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(store.Destroy)
+	return f, store
+}
+
+// serve serves the change stream of the pod table with config, allowing the admin to watch pods in the allowed
+// namespace, and returns a connection to it.
+func serve(t *testing.T, config Config) *grpc.ClientConn {
+	config.Resources = map[string]schema.GroupResource{"pod": {Resource: "pods"}}
+	config.Authenticator = authn.NewStaticToken("admin", "token")
+	config.Authorizer = authorizer.AuthorizerFunc(func(_ context.Context, a authorizer.Attributes) (authorizer.Decision, string, error) {
+		if a.GetVerb() == "watch" && a.GetResource() == "pods" && a.GetNamespace() == "allowed" {
+			return authorizer.DecisionAllow, "", nil
+		}
+		return authorizer.DecisionDeny, "", nil
+	})
+	server, err := NewServer(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	listener := bufconn.Listen(1 << 20)
+	go func() {
+		_ = server.Serve(listener)
+	}()
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufconn", grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = conn.Close()
+	})
+	return conn
+}
+
+func TestWatch(t *testing.T) {
+	f, store := newTestFactory(t)
+	conn := serve(t, Config{Factory: f})
+
+	ctx := context.Background()
+	var first string
+	for _, pod := range []*corev1.Pod{
+		{ObjectMeta: metav1.ObjectMeta{Name: "before", Namespace: "allowed", Labels: map[string]string{"app": "a"}}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "match1", Namespace: "allowed", Labels: map[string]string{"app": "a"}}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "other-namespace", Namespace: "other", Labels: map[string]string{"app": "a"}}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "other-label", Namespace: "allowed", Labels: map[string]string{"app": "b"}}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "match2", Namespace: "allowed", Labels: map[string]string{"app": "a"}}},
+	} {
+		created, err := store.Create(ctx, pod)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if first == "" {
+			first = created.GetResourceVersion()
+		}
+	}
+	rv, err := strconv.ParseUint(first, 10, 64)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the changes after the first pod are streamed
+	authCtx := metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer token")
+	req := &WatchRequest{Table: "pod", Namespace: "allowed", LabelSelector: "app=a", ResourceVersion: rv}
+	errDone := errors.New("done")
+	var names []string
+	err = Watch(authCtx, conn, req, func(event *db.ChangeEvent) error {
+		assert.Equal(t, "ADDED", string(event.Type))
+		pod := &corev1.Pod{}
+		if err := json.Unmarshal(event.Object, pod); err != nil {
+			return err
+		}
+		names = append(names, pod.Name)
+		if len(names) == 2 {
+			return errDone
+		}
+		return nil
+	})
+	assert.ErrorIs(t, err, errDone)
+	assert.Equal(t, []string{"match1", "match2"}, names)
+
+	noEvents := func(*db.ChangeEvent) error {
+		return errors.New("unexpected event")
+	}
+	err = Watch(ctx, conn, req, noEvents)
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	err = Watch(authCtx, conn, &WatchRequest{Table: "pod", Namespace: "other"}, noEvents)
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	err = Watch(authCtx, conn, &WatchRequest{Table: "secret", Namespace: "allowed"}, noEvents)
+	assert.Equal(t, codes.NotFound, status.Code(err))
+}
+
+func TestWatchCompacted(t *testing.T) {
+	f, store := newTestFactory(t)
+	ctx := context.Background()
+	var rvs []uint64
+	for _, name := range []string{"a", "b", "c"} {
+		created, err := store.Create(ctx, &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "allowed"}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		rv, err := strconv.ParseUint(created.GetResourceVersion(), 10, 64)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rvs = append(rvs, rv)
+	}
+	// wait for the watch loop to pass the changes, it would fill the gap left by the deleted change otherwise
+	watchCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	events, err := store.Watch(watchCtx, "allowed", storage.ListOptions{
+		ResourceVersion: strconv.FormatUint(rvs[0], 10),
+		Predicate:       storage.Everything,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for seen := 0; seen < 2; seen++ {
+		select {
+		case <-events:
+		case <-time.After(5 * time.Second):
+			t.Fatal("changes were not watched")
+		}
+	}
+
+	// the change after the first pod is gone, as if garbage collected, and the table is compacted past it
+	if err := f.DB.Table("pod").Where("id = ?", rvs[1]).Delete(&db.Record{}).Error; err != nil {
+		t.Fatal(err)
+	}
+	// a strategy assumes everything before it started is compacted
+	compacted, err := db.NewFactory(scheme.Scheme, "sqlite://file:"+t.Name()+"?mode=memory&cache=shared")
+	if err != nil {
+		t.Fatal(err)
+	}
+	compactedStore, err := compacted.NewDBStrategy(&corev1.Pod{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(compactedStore.Destroy)
+	conn := serve(t, Config{Factory: compacted})
+
+	authCtx := metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer token")
+	err = Watch(authCtx, conn, &WatchRequest{Table: "pod", Namespace: "allowed", ResourceVersion: rvs[0]}, func(*db.ChangeEvent) error {
+		return errors.New("unexpected event")
+	})
+	assert.Equal(t, codes.OutOfRange, status.Code(err), "expected out of range, got %v", err)
+}
+
+func TestWatchPartitionAndScrub(t *testing.T) {
+	f, store := newTestFactory(t)
+	conn := serve(t, Config{
+		Factory: f,
+		Partitions: &db.PartitionOptions{
+			Sources:  []db.PartitionSource{db.PartitionFromHeader("X-Partition")},
+			Required: true,
+		},
+		Scrubbers: map[string]strategy.Scrubber{"pod": strategy.NewPrefixScrubber("secret.")},
+	})
+
+	ctx := context.Background()
+	var first string
+	for i, partitionID := range []string{"tenant-b", "tenant-a", "tenant-b", "tenant-a"} {
+		created, err := store.Create(db.ContextWithPartitionID(ctx, partitionID), &corev1.Pod{ObjectMeta: metav1.ObjectMeta{
+			Name:      partitionID + "-" + strconv.Itoa(i),
+			Namespace: "allowed",
+			Labels:    map[string]string{"secret.example.com/key": "value", "app": "a"},
+		}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if first == "" {
+			first = created.GetResourceVersion()
+		}
+	}
+	rv, err := strconv.ParseUint(first, 10, 64)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := &WatchRequest{Table: "pod", Namespace: "allowed", ResourceVersion: rv}
+
+	authCtx := metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer token")
+	err = Watch(authCtx, conn, req, func(*db.ChangeEvent) error {
+		return errors.New("unexpected event")
+	})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err), "expected invalid argument, got %v", err)
+
+	errDone := errors.New("done")
+	var pods []*corev1.Pod
+	err = Watch(metadata.AppendToOutgoingContext(authCtx, "x-partition", "tenant-a"), conn, req, func(event *db.ChangeEvent) error {
+		pod := &corev1.Pod{}
+		if err := json.Unmarshal(event.Object, pod); err != nil {
+			return err
+		}
+		pods = append(pods, pod)
+		if len(pods) == 2 {
+			return errDone
+		}
+		return nil
+	})
+	assert.ErrorIs(t, err, errDone)
+	for _, pod := range pods {
+		assert.Contains(t, pod.Name, "tenant-a-")
+		assert.Equal(t, map[string]string{"app": "a"}, pod.Labels)
+	}
+}
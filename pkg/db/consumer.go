@@ -0,0 +1,377 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/acorn-io/mink/pkg/db/errtypes"
+	"github.com/acorn-io/mink/pkg/strategy"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apiserver/pkg/authorization/authorizer"
+	"k8s.io/apiserver/pkg/endpoints/request"
+)
+
+const (
+	consumerCursorsTableName = "mink_consumer_cursors"
+	defaultConsumeLimit      = 100
+	maxConsumeLimit          = 1000
+)
+
+// ErrUnknownTable is returned for changes of a table the factory has no strategy for.
+var ErrUnknownTable = errors.New("the factory has no strategy for the table")
+
+// ConsumerCursor is the position of a consumer in a table. The consumer has processed the changes up to and
+// including Position.
+type ConsumerCursor struct {
+	// Name is the consumer name and table, separated by a slash.
+	Name     string `gorm:"primaryKey;size:255"`
+	Position uint
+	Updated  time.Time
+}
+
+// ChangeBatch is a batch of changes of a table read by a Consumer. Position is committed once the events have been
+// processed, it can be past the last event if the batch ended with records that aren't changes of objects.
+type ChangeBatch struct {
+	Table    string        `json:"table"`
+	Events   []ChangeEvent `json:"events"`
+	Position uint          `json:"position"`
+}
+
+// Consumer reads the changes of the tables of a factory at its own pace, as opposed to a Publisher pushing them. Its
+// position in every table is stored under its name in a cursor table, so a consumer that restarts, or a server that
+// restarts under it, continues where it committed last. Delivery is at least once, changes read but not committed
+// are read again. Instances sharing a name share the position, like the members of a consumer group with a single
+// partition per table.
+type Consumer struct {
+	factory *Factory
+	name    string
+}
+
+// NewConsumer returns the consumer with the given name. A new consumer starts with the changes made after it first
+// reads a table.
+func (f *Factory) NewConsumer(name string) (*Consumer, error) {
+	if err := f.migrateConsumerCursors(); err != nil {
+		return nil, err
+	}
+	return f.consumer(name)
+}
+
+func (f *Factory) migrateConsumerCursors() error {
+	if err := f.requireSQL("consumers"); err != nil {
+		return err
+	}
+	if f.AutoMigrate {
+		return f.DB.Table(consumerCursorsTableName).AutoMigrate(&ConsumerCursor{})
+	}
+	return nil
+}
+
+func (f *Factory) consumer(name string) (*Consumer, error) {
+	if name == "" || strings.Contains(name, "/") {
+		return nil, fmt.Errorf("invalid consumer name %q", name)
+	}
+	return &Consumer{
+		factory: f,
+		name:    name,
+	}, nil
+}
+
+// changeTable returns the strategy and database of the table named table, compared case-insensitively.
+func (f *Factory) changeTable(table string) (*Strategy, *GormDB, error) {
+	f.strategiesLock.Lock()
+	strategies := f.strategies
+	f.strategiesLock.Unlock()
+
+	for _, s := range strategies {
+		if g, ok := s.db.(*GormDB); ok && g.db != nil && strings.EqualFold(g.tableName, table) {
+			return s, g, nil
+		}
+	}
+	return nil, nil, fmt.Errorf("%w: %s", ErrUnknownTable, table)
+}
+
+// changePartition returns the partition of the changes of s read with ctx, see PartitionIDFromContext. Without a
+// partition the changes of every partition are read, unless the strategy requires one.
+func changePartition(ctx context.Context, s *Strategy) (string, error) {
+	partitionID := PartitionIDFromContext(ctx)
+	if s.partitionIDRequired && partitionID == "" {
+		return "", newPartitionRequiredError()
+	}
+	return partitionID, nil
+}
+
+// ReadChanges returns up to limit changes of table after position, for readers keeping track of their position
+// themselves. If changes after position were deleted by compaction before they were read, it fails with a
+// CompactionError, see errtypes.ErrCompacted, and the reader has to start over. If ctx has a partition ID only the
+// changes of that partition are returned.
+func (f *Factory) ReadChanges(ctx context.Context, table string, position uint, limit int) (*ChangeBatch, error) {
+	s, g, err := f.changeTable(table)
+	if err != nil {
+		return nil, err
+	}
+	partitionID, err := changePartition(ctx, s)
+	if err != nil {
+		return nil, err
+	}
+	return readChangeBatch(ctx, s, g, position, limit, "", partitionID)
+}
+
+// ScrubChanges removes fields from the objects of events with scrubber, as they are removed from the objects served
+// by the API. The objects are decoded into the type of the strategy of their table.
+func (f *Factory) ScrubChanges(ctx context.Context, scrubber strategy.Scrubber, events []ChangeEvent) error {
+	for i := range events {
+		s, _, err := f.changeTable(events[i].Table)
+		if err != nil {
+			return err
+		}
+		obj := s.newObj()
+		if err := json.Unmarshal(events[i].Object, obj); err != nil {
+			return err
+		}
+		scrubber.Scrub(ctx, obj)
+		data, err := json.Marshal(obj)
+		if err != nil {
+			return err
+		}
+		events[i].Object = data
+	}
+	return nil
+}
+
+// LatestChange returns the position of the latest change of table, reading from it returns the changes made after
+// the call.
+func (f *Factory) LatestChange(ctx context.Context, table string) (uint, error) {
+	_, g, err := f.changeTable(table)
+	if err != nil {
+		return 0, err
+	}
+	return g.getMaxID(ctx)
+}
+
+// Next returns up to limit changes of table after the committed position, without moving it. If ctx has a partition
+// ID only the changes of that partition are returned, the consumer has a separate position in each partition.
+func (c *Consumer) Next(ctx context.Context, table string, limit int) (*ChangeBatch, error) {
+	s, g, err := c.factory.changeTable(table)
+	if err != nil {
+		return nil, err
+	}
+	partitionID, err := changePartition(ctx, s)
+	if err != nil {
+		return nil, err
+	}
+	position, err := c.position(ctx, g, partitionID)
+	if err != nil {
+		return nil, err
+	}
+	return readChangeBatch(ctx, s, g, position, limit, c.name, partitionID)
+}
+
+func readChangeBatch(ctx context.Context, s *Strategy, g *GormDB, position uint, limit int, reader, partitionID string) (*ChangeBatch, error) {
+	events, position, err := readChanges(ctx, s, g, position, limit, reader, partitionID)
+	if err != nil {
+		return nil, err
+	}
+	if events == nil {
+		events = []ChangeEvent{}
+	}
+	return &ChangeBatch{
+		Table:    g.tableName,
+		Events:   events,
+		Position: position,
+	}, nil
+}
+
+// cursorName returns the name of the cursor of the consumer in the table and partition.
+func (c *Consumer) cursorName(g *GormDB, partitionID string) string {
+	if partitionID == "" {
+		return c.name + "/" + g.tableName
+	}
+	return c.name + "/" + g.tableName + "/" + partitionID
+}
+
+// position returns the committed position in the table and partition, creating the cursor at the latest change if
+// there is none.
+func (c *Consumer) position(ctx context.Context, g *GormDB, partitionID string) (uint, error) {
+	db := c.factory.DB.WithContext(ctx).Table(consumerCursorsTableName)
+	cursorName := c.cursorName(g, partitionID)
+
+	cursor := &ConsumerCursor{}
+	err := db.Where("name = ?", cursorName).Take(cursor).Error
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return cursor.Position, err
+	}
+
+	position, err := g.getMaxID(ctx)
+	if err != nil {
+		return 0, err
+	}
+	// another instance may have created the cursor meanwhile, then its position is read back
+	err = c.factory.DB.WithContext(ctx).Table(consumerCursorsTableName).Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&ConsumerCursor{
+			Name:     cursorName,
+			Position: position,
+			Updated:  time.Now().UTC(),
+		}).Error
+	if err != nil {
+		return 0, err
+	}
+	err = c.factory.DB.WithContext(ctx).Table(consumerCursorsTableName).Where("name = ?", cursorName).Take(cursor).Error
+	return cursor.Position, err
+}
+
+// Commit records that the changes of table up to and including position have been processed, in the partition of
+// ctx if it has one. The position never moves back, committing an older position than the current one does nothing.
+func (c *Consumer) Commit(ctx context.Context, table string, position uint) error {
+	s, g, err := c.factory.changeTable(table)
+	if err != nil {
+		return err
+	}
+	partitionID, err := changePartition(ctx, s)
+	if err != nil {
+		return err
+	}
+	if _, err := c.position(ctx, g, partitionID); err != nil {
+		return err
+	}
+	return c.factory.DB.WithContext(ctx).Table(consumerCursorsTableName).
+		Where("name = ? AND position < ?", c.cursorName(g, partitionID), position).
+		Updates(map[string]any{
+			"position": position,
+			"updated":  time.Now().UTC(),
+		}).Error
+}
+
+// ConsumerHandlerConfig configures the HTTP handler of the consumers of a factory, see NewConsumerHandler.
+type ConsumerHandlerConfig struct {
+	Factory *Factory
+	// Resources are the tables served, mapped to the resource the caller must be allowed to watch in all namespaces to
+	// read the changes of the table. Changes of other tables are not served.
+	Resources map[string]schema.GroupResource
+	// Authorizer authorizes the user of each request, which the server authenticated.
+	Authorizer authorizer.Authorizer
+	// Scrubbers, keyed by table, remove fields from the objects of the changes of the table before they are served,
+	// like the Scrubber of the watches of the resource, see Factory.ScrubChanges.
+	Scrubbers map[string]strategy.Scrubber
+}
+
+// NewConsumerHandler serves the consumers of the factory over HTTP, for external integrations, as one of the
+// PathHandlers of the server. The consumer and table are given by the consumer and table query parameters. GET returns
+// the next ChangeBatch, with at most limit events, and POST commits the position given by the position query
+// parameter. Reading requires the permission to watch the resource of the table, committing also the permission to
+// update its consumers subresource, named after the consumer, so that callers can't move the positions of other
+// consumers. Requests with a partition ID read and commit the changes of their partition only.
+func NewConsumerHandler(config ConsumerHandlerConfig) (http.Handler, error) {
+	if config.Factory == nil || config.Authorizer == nil {
+		return nil, errors.New("the consumer handler requires a factory and an authorizer")
+	}
+	f := config.Factory
+	if err := f.migrateConsumerCursors(); err != nil {
+		return nil, err
+	}
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		query := req.URL.Query()
+		consumer, err := f.consumer(query.Get("consumer"))
+		if err != nil {
+			http.Error(rw, err.Error(), http.StatusBadRequest)
+			return
+		}
+		table := query.Get("table")
+		resource, ok := config.Resources[table]
+		if !ok {
+			http.Error(rw, fmt.Sprintf("table %q is not served", table), http.StatusNotFound)
+			return
+		}
+		if !config.authorize(rw, req, "watch", resource, "", "") {
+			return
+		}
+
+		switch req.Method {
+		case http.MethodGet:
+			limit := defaultConsumeLimit
+			if l := query.Get("limit"); l != "" {
+				if limit, err = strconv.Atoi(l); err != nil || limit <= 0 {
+					http.Error(rw, fmt.Sprintf("invalid limit %q", l), http.StatusBadRequest)
+					return
+				}
+				limit = min(limit, maxConsumeLimit)
+			}
+			batch, err := consumer.Next(req.Context(), table, limit)
+			if err != nil {
+				writeConsumerError(rw, err)
+				return
+			}
+			if scrubber := config.Scrubbers[table]; scrubber != nil {
+				if err := f.ScrubChanges(req.Context(), scrubber, batch.Events); err != nil {
+					writeConsumerError(rw, err)
+					return
+				}
+			}
+			rw.Header().Set("Content-Type", "application/json")
+			_ = json.NewEncoder(rw).Encode(batch)
+		case http.MethodPost:
+			if !config.authorize(rw, req, "update", resource, "consumers", consumer.name) {
+				return
+			}
+			position, err := strconv.ParseUint(query.Get("position"), 10, 64)
+			if err != nil {
+				http.Error(rw, fmt.Sprintf("invalid position %q", query.Get("position")), http.StatusBadRequest)
+				return
+			}
+			if err := consumer.Commit(req.Context(), table, uint(position)); err != nil {
+				writeConsumerError(rw, err)
+				return
+			}
+			rw.WriteHeader(http.StatusNoContent)
+		default:
+			http.Error(rw, "method not allowed", http.StatusMethodNotAllowed)
+		}
+	}), nil
+}
+
+// authorize checks that the user of req may use verb on the resource in all namespaces, it writes the error and
+// returns false if not.
+func (c *ConsumerHandlerConfig) authorize(rw http.ResponseWriter, req *http.Request, verb string, resource schema.GroupResource, subresource, name string) bool {
+	u, ok := request.UserFrom(req.Context())
+	if !ok {
+		http.Error(rw, "unauthenticated", http.StatusUnauthorized)
+		return false
+	}
+	decision, reason, err := c.Authorizer.Authorize(req.Context(), authorizer.AttributesRecord{
+		User:            u,
+		Verb:            verb,
+		APIGroup:        resource.Group,
+		Resource:        resource.Resource,
+		Subresource:     subresource,
+		Name:            name,
+		ResourceRequest: true,
+	})
+	if err != nil {
+		http.Error(rw, fmt.Sprintf("authorizing: %v", err), http.StatusInternalServerError)
+		return false
+	}
+	if decision != authorizer.DecisionAllow {
+		http.Error(rw, fmt.Sprintf("%s may not %s %s: %s", u.GetName(), verb, resource, reason), http.StatusForbidden)
+		return false
+	}
+	return true
+}
+
+func writeConsumerError(rw http.ResponseWriter, err error) {
+	if errors.Is(err, ErrUnknownTable) {
+		http.Error(rw, err.Error(), http.StatusNotFound)
+		return
+	}
+	if errors.Is(err, errtypes.ErrPartitionRequired) {
+		http.Error(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+	http.Error(rw, err.Error(), http.StatusInternalServerError)
+}
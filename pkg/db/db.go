@@ -5,32 +5,38 @@ import (
 	"context"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"os"
+	"slices"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/acorn-io/broadcaster"
 	"github.com/acorn-io/mink/pkg/channel"
 	"github.com/acorn-io/mink/pkg/datatypes"
-	"github.com/sirupsen/logrus"
+	"github.com/acorn-io/mink/pkg/db/errtypes"
+	"github.com/acorn-io/mink/pkg/logging"
+	"github.com/acorn-io/mink/pkg/metrics"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/selection"
+	"k8s.io/apimachinery/pkg/util/uuid"
 	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/apiserver/pkg/storage/value"
-	"k8s.io/klog/v2"
 )
 
 const (
 	defaultDeleteRetainCount     = 1000
 	defaultCompactionRetainCount = 1000
-	deleteBatchSize              = 1000
-	compactBatchSize             = 1000
+	defaultDeleteBatchSize       = 1000
+	defaultCompactBatchSize      = 1000
 	watchLoopSleep               = 2 * time.Second
-	defaultGCIntervalSeconds     = 1800
+	defaultGCInterval            = 1800 * time.Second
+	defaultBookmarkInterval      = time.Minute
 )
 
 type GormDB struct {
@@ -40,22 +46,90 @@ type GormDB struct {
 	trigger      chan struct{}
 	broadcaster  *broadcaster.Broadcaster[Record]
 	transformers map[schema.GroupKind]value.Transformer
+	// partitionKeys is set by WithPartitionKeys.
+	partitionKeys PartitionKeyProvider
 
 	compactionLock sync.RWMutex
 	compaction     uint
 	lastIDLock     sync.Mutex
 	lastID         uint
+
+	snapshotTTL  time.Duration
+	snapshotLock sync.Mutex
+	snapshot     *watchSnapshot
+	// cache is set by WithWatchCache.
+	cache *watchCache
+
+	classDBs map[QueryClass]*gorm.DB
+	tidb     bool
+
+	compactRetain    uint
+	deleteRetain     int
+	gcInterval       time.Duration
+	compactBatchSize int
+	deleteBatchSize  int
+
+	watchBufferSize int
+	watchOverflow   WatchOverflowPolicy
+
+	configuredBookmarkInterval time.Duration
+	// progressRequested is set by RequestWatchProgress until the watch loop sends a bookmark.
+	progressRequested atomic.Bool
+	// terminated is closed by TerminateWatches.
+	terminated    chan struct{}
+	terminateOnce sync.Once
+
+	gcLock      sync.Mutex
+	lastGC      time.Time
+	lastGCError error
+
+	// id identifies this server to the others writing the table, replicas is set by WithReplicas.
+	id       string
+	replicas bool
+
+	// ids allocates the IDs of new records, if nil the database assigns them.
+	ids            IDAllocator
+	globalSequence bool
+	// userColumns is set by WithUserTracking.
+	userColumns bool
+
+	// log is set by WithLogger.
+	log logging.Logger
+
+	// advisor is set by WithIndexAdvisor.
+	advisor *indexAdvisor
+	// fieldIndexes are the field selector keys declared by WithFieldIndexes.
+	fieldIndexes []string
+	// columns are the generated selector columns of the table, see selectorColumn.
+	columnsLock sync.RWMutex
+	columns     map[string]bool
+
+	// settingsLock guards the settings changed at runtime with GormDB.SetRuntimeSettings.
+	settingsLock sync.RWMutex
+	settings     RuntimeSettings
 }
 
 func NewDB(tableName string, gvk schema.GroupVersionKind, db *gorm.DB, transformers map[schema.GroupKind]value.Transformer) *GormDB {
-	return &GormDB{
-		gvk:          gvk,
-		db:           db,
-		tableName:    tableName,
-		trigger:      make(chan struct{}, 1),
-		broadcaster:  broadcaster.New[Record](),
-		transformers: transformers,
+	g := &GormDB{
+		gvk:                        gvk,
+		tableName:                  tableName,
+		trigger:                    make(chan struct{}, 1),
+		terminated:                 make(chan struct{}),
+		broadcaster:                broadcaster.New[Record](),
+		transformers:               transformers,
+		compactRetain:              defaultCompactionRetainCount,
+		deleteRetain:               defaultDeleteRetainCount,
+		gcInterval:                 defaultGCInterval,
+		compactBatchSize:           defaultCompactBatchSize,
+		deleteBatchSize:            defaultDeleteBatchSize,
+		watchBufferSize:            defaultWatchBufferSize,
+		watchOverflow:              WatchOverflowResync,
+		configuredBookmarkInterval: defaultBookmarkInterval,
+		id:                         string(uuid.NewUUID()),
+		log:                        logging.Default(),
 	}
+	g.db = g.withTableLogger(db)
+	return g
 }
 
 func (g *GormDB) triggerWatchLoop() {
@@ -71,15 +145,24 @@ func (g *GormDB) sendBookmark(ctx context.Context, lastID uint) {
 	}
 }
 
-func (g *GormDB) readEvents(ctx context.Context, init bool, lastID uint) (uint, error) {
-	if init {
-		id, err := g.getMaxID(ctx)
-		if err != nil {
-			return 0, err
-		}
-		lastID = id
-	}
+// RequestWatchProgress makes the watch loop send a bookmark with the latest resource version to the watches of the
+// table that allow them as soon as it has read the records written so far, rather than at the next bookmark interval.
+func (g *GormDB) RequestWatchProgress(context.Context) error {
+	g.progressRequested.Store(true)
+	g.triggerWatchLoop()
+	return nil
+}
 
+// TerminateWatches ends the watches of the table, including those started from now on, as the server is shutting
+// down. Each watch first gets a bookmark with the ID of the last record it got, so that its client resumes it from
+// there, unless it is still sending the initial state of the objects, from which it can't be resumed.
+func (g *GormDB) TerminateWatches() {
+	g.terminateOnce.Do(func() {
+		close(g.terminated)
+	})
+}
+
+func (g *GormDB) readEvents(ctx context.Context, lastID uint) (uint, error) {
 	records, err := g.since(ctx, lastID)
 	if err != nil {
 		return 0, err
@@ -88,6 +171,10 @@ func (g *GormDB) readEvents(ctx context.Context, init bool, lastID uint) (uint,
 	// After this point never return an error because we need to record the
 	// last ID sent in memory
 
+	pending := metrics.BroadcastPending.WithLabelValues(g.tableName)
+	pending.Set(float64(len(records)))
+	defer pending.Set(0)
+
 	for _, record := range records {
 		if record.ID != lastID+1 {
 			g.fill(ctx, lastID+1)
@@ -103,7 +190,9 @@ func (g *GormDB) readEvents(ctx context.Context, init bool, lastID uint) (uint,
 				g.compactionLock.Unlock()
 			}
 		}
+		g.applyToCache(record)
 		g.broadcaster.C <- record
+		pending.Dec()
 		lastID = record.ID
 	}
 
@@ -111,53 +200,52 @@ func (g *GormDB) readEvents(ctx context.Context, init bool, lastID uint) (uint,
 }
 
 func (g *GormDB) Start(ctx context.Context) (err error) {
+	if g.db != nil {
+		if err := g.addOptionalColumns(ctx); err != nil {
+			return err
+		}
+	}
+	if g.ids != nil && g.db != nil {
+		if err := g.ids.Start(ctx, g.db, g.tableName); err != nil {
+			return err
+		}
+	}
+	if g.globalSequence && g.db != nil {
+		if err := startGlobalSequence(ctx, g.db); err != nil {
+			return err
+		}
+	}
+	if len(g.fieldIndexes) > 0 && g.db != nil {
+		if err := g.createFieldIndexes(ctx); err != nil {
+			return err
+		}
+	}
 	// assume everything is compacted upfront
 	g.compaction, err = g.getMaxID(ctx)
 	if err != nil {
 		return err
 	}
+	if g.cache != nil {
+		g.cache.seen = g.compaction
+	}
 	if g.db != nil {
-		go g.broadcaster.Start(ctx)
-		go g.watchLoop(ctx)
+		// The watch loop is the only writer to the broadcaster, so it shuts the broadcaster down when it exits
+		// rather than racing a close on ctx against its own sends.
+		go g.broadcaster.Start(context.Background())
+		go func(lastID uint) {
+			defer g.broadcaster.Shutdown()
+			// start reading events from the ID found above, so records written before the first poll are not lost
+			g.watchLoop(ctx, lastID)
+		}(g.compaction)
 		go g.gc(ctx)
-	}
-	return nil
-}
-
-func (g *GormDB) getEnv(key string, def uint) uint {
-	envNames := []string{
-		key + "_" + strings.ToUpper(g.tableName),
-		key + "_" + g.tableName,
-		key,
-	}
-	for _, envName := range envNames {
-		s := os.Getenv(envName)
-		if s != "" {
-			i, err := strconv.Atoi(s)
-			if err != nil {
-				panic(fmt.Sprintf("Invalid value %s=%s: %v", envName, s, err))
-			}
-			return uint(i)
+		if g.advisor != nil {
+			go g.advise(ctx)
 		}
 	}
-
-	return def
-}
-
-func (g *GormDB) getCompactRetainCount() uint {
-	return g.getEnv("MINK_COMPACT_RETAIN", defaultCompactionRetainCount)
-}
-
-func (g *GormDB) getDeleteRetainCount() int {
-	return int(g.getEnv("MINK_DELETE_RETAIN", defaultDeleteRetainCount))
+	return nil
 }
 
 func (g *GormDB) gc(ctx context.Context) {
-	if g.getCompactRetainCount() == 0 {
-		logrus.Debugf("Compaction and deletion disabled for [%s]", g.tableName)
-		return
-	}
-
 	var (
 		lastSuccessCompaction uint
 		// first loop is less delay
@@ -170,13 +258,18 @@ func (g *GormDB) gc(ctx context.Context) {
 		case <-time.After(delay):
 		}
 
-		delay = wait.Jitter(time.Duration(g.getEnv("MINK_GC_INTERVAL_SECONDS", defaultGCIntervalSeconds))*time.Second, 0)
+		config := g.gcSettings()
+		delay = wait.Jitter(config.interval, 0)
+		if config.compactRetain == 0 {
+			g.log.Debugf("Compaction and deletion disabled for [%s]", g.tableName)
+			continue
+		}
 
 		if lastSuccessCompaction == 0 {
-			logrus.Debugf("Starting compaction goroutine for [%s]", g.tableName)
+			g.log.Debugf("Starting compaction goroutine for [%s]", g.tableName)
 			minID, err := g.getMinID(ctx)
 			if err != nil {
-				logrus.Errorf("failed to get minimum ID for compaction: %v", err)
+				g.log.Errorf("failed to get minimum ID for compaction: %v", err)
 			}
 			lastSuccessCompaction = minID
 		}
@@ -185,16 +278,32 @@ func (g *GormDB) gc(ctx context.Context) {
 		nextCompactionID := g.lastID
 		g.lastIDLock.Unlock()
 
-		if nextCompactionID < g.getCompactRetainCount() {
+		if nextCompactionID < config.compactRetain {
 			continue
 		}
-		nextCompactionID -= g.getCompactRetainCount()
+		nextCompactionID -= config.compactRetain
+
+		var epoch uint
+		if g.replicas {
+			var err error
+			epoch, err = g.acquireGCLease(ctx)
+			if errors.Is(err, errNotLeader) {
+				g.log.Debugf("Skipping compaction [%s], another server holds the lease", g.tableName)
+				continue
+			} else if err != nil {
+				g.log.Errorf("Failed to acquire the garbage collection lease of [%s]: %v", g.tableName, err)
+				g.recordGC(err)
+				continue
+			}
+		}
 
-		if cont, err := g.markCompaction(ctx, nextCompactionID); err != nil {
-			logrus.Errorf("Failed to write compaction record [%s] %d: %v", g.tableName, nextCompactionID, err)
+		if cont, err := g.markCompaction(ctx, nextCompactionID, epoch); err != nil {
+			g.log.Errorf("Failed to write compaction record [%s] %d: %v", g.tableName, nextCompactionID, err)
+			g.recordGC(err)
 			continue
 		} else if !cont {
-			logrus.Debugf("Skipping compaction [%s]", g.tableName)
+			g.log.Debugf("Skipping compaction [%s]", g.tableName)
+			g.recordGC(nil)
 			continue
 		}
 
@@ -207,53 +316,17 @@ func (g *GormDB) gc(ctx context.Context) {
 		// Make sure peers know about compaction change
 		time.Sleep(2 * watchLoopSleep)
 
-		for lastSuccessCompaction < nextCompactionID {
-			var (
-				records []Record
-				ids     []uint
-			)
-
-			nextBatch := lastSuccessCompaction + compactBatchSize
-			if nextBatch > nextCompactionID {
-				nextBatch = nextCompactionID
-			}
-
-			logrus.Debugf("Running compaction [%s] %d => %d", g.tableName, lastSuccessCompaction, nextBatch)
-			db := g.newQuery(ctx).
-				Select("id", "name", "removed", "previous").
-				Where("id >= ? and id < ?", lastSuccessCompaction, nextBatch).Scan(&records)
-			if db.Error != nil {
-				logrus.Errorf("Failed running compaction [%s] %d => %d: %v", g.tableName, lastSuccessCompaction, nextBatch,
-					db.Error)
-				continue
-			}
-
-			for _, record := range records {
-				if record.Previous != nil {
-					ids = append(ids, *record.Previous)
-				}
-				// delete fill records or removed
-				if record.Name == "" || record.Removed != nil {
-					ids = append(ids, record.ID)
-				}
-			}
-
-			db = g.newQuery(ctx).
-				Where("garbage is FALSE and id in (?)", ids).
-				Update("garbage", true)
-			if db.Error != nil {
-				logrus.Errorf("Failed updating compaction [%s] %d => %d: %v", g.tableName, lastSuccessCompaction, nextBatch,
-					db.Error)
-			} else if db.RowsAffected > 0 {
-				logrus.Debugf("compacted [%s] [%d] rows", g.tableName, db.RowsAffected)
-			}
-
-			lastSuccessCompaction = nextBatch
+		start := time.Now()
+		lastSuccessCompaction = g.compact(ctx, lastSuccessCompaction, nextCompactionID)
+		metrics.CompactionDuration.WithLabelValues(g.tableName).Observe(time.Since(start).Seconds())
+		if lastSuccessCompaction < nextCompactionID {
+			g.recordGC(fmt.Errorf("compaction stopped at %d of %d", lastSuccessCompaction, nextCompactionID))
+		} else {
+			g.recordGC(nil)
 		}
 
-		deleteCount := g.getDeleteRetainCount()
-		if deleteCount == 0 {
-			logrus.Debugf("Deletion disabled for [%s]", g.tableName)
+		if config.deleteRetain == 0 {
+			g.log.Debugf("Deletion disabled for [%s]", g.tableName)
 			continue
 		}
 
@@ -264,22 +337,24 @@ func (g *GormDB) gc(ctx context.Context) {
 
 			db := g.newQuery(ctx).
 				Select("id").
-				Where("garbage IS TRUE").
+				Where("garbage = ?", true).
 				Order("id ASC").
-				Limit(deleteCount + deleteBatchSize).
+				Limit(config.deleteRetain + config.deleteBatchSize).
 				Scan(&ids)
 			if db.Error != nil {
-				logrus.Errorf("Failed finding deletion [%s]: %v", g.tableName, db.Error)
+				g.log.Errorf("Failed finding deletion [%s]: %v", g.tableName, db.Error)
 				continue
 			}
 
-			if len(ids) > deleteCount {
-				ids = ids[:len(ids)-deleteCount]
-				logrus.Debugf("Deleting [%d] records for [%s]: %v", len(ids), g.tableName, ids)
+			if len(ids) > config.deleteRetain {
+				ids = ids[:len(ids)-config.deleteRetain]
+				g.log.Debugf("Deleting [%d] records for [%s]: %v", len(ids), g.tableName, ids)
 				db := g.newQuery(ctx).
 					Delete("id in ?", ids)
 				if db.Error != nil {
-					logrus.Errorf("Failed running deletion [%s]: %v", g.tableName, db.Error)
+					g.log.Errorf("Failed running deletion [%s]: %v", g.tableName, db.Error)
+				} else {
+					metrics.DeletedRows.WithLabelValues(g.tableName).Add(float64(db.RowsAffected))
 				}
 			} else {
 				break
@@ -287,12 +362,8 @@ func (g *GormDB) gc(ctx context.Context) {
 		}
 	}
 }
-func (g *GormDB) watchLoop(ctx context.Context) {
-	var (
-		lastID uint
-		init   = true
-	)
-
+func (g *GormDB) watchLoop(ctx context.Context, lastID uint) {
+	lastBookmark := time.Now()
 	for {
 		// set last id for compaction
 		g.lastIDLock.Lock()
@@ -302,25 +373,92 @@ func (g *GormDB) watchLoop(ctx context.Context) {
 		select {
 		case <-ctx.Done():
 			return
-		case <-time.After(watchLoopSleep):
-		case <-time.After(time.Minute):
-			g.sendBookmark(ctx, lastID)
-			continue
+		case <-time.After(min(watchLoopSleep, g.bookmarkInterval())):
 		case <-g.trigger:
 		}
-		id, err := g.readEvents(ctx, init, lastID)
+		id, err := g.readEvents(ctx, lastID)
 		if err != nil {
-			klog.Infof("failed to initialize watcher: %v", err)
+			g.log.Infof("failed to read events: %v", err)
 			continue
 		}
-		init = false
 		lastID = id
+
+		if g.progressRequested.Swap(false) || time.Since(lastBookmark) >= g.bookmarkInterval() {
+			g.sendBookmark(ctx, lastID)
+			lastBookmark = time.Now()
+		}
+	}
+}
+
+// compact marks the records superseded by the records with IDs in [from, to) as garbage, in batches. It returns the
+// ID compaction got to, which is to unless a batch failed.
+func (g *GormDB) compact(ctx context.Context, from, to uint) uint {
+	batchSize := uint(g.gcSettings().compactBatchSize)
+	for from < to {
+		var (
+			records []Record
+			ids     []uint
+		)
+
+		nextBatch := from + batchSize
+		if nextBatch > to {
+			nextBatch = to
+		}
+
+		g.log.Debugf("Running compaction [%s] %d => %d", g.tableName, from, nextBatch)
+		db := g.newQuery(ctx).
+			Select("id", "name", "removed", "previous").
+			Where("id >= ? and id < ?", from, nextBatch).Scan(&records)
+		if db.Error != nil {
+			g.log.Errorf("Failed running compaction [%s] %d => %d: %v", g.tableName, from, nextBatch,
+				db.Error)
+			return from
+		}
+
+		for _, record := range records {
+			if record.Previous != nil {
+				ids = append(ids, *record.Previous)
+			}
+			// delete fill records or removed
+			if record.Name == "" || record.Removed != nil {
+				ids = append(ids, record.ID)
+			}
+		}
+
+		db = g.newQuery(ctx).
+			Where("garbage = ? and id in (?)", false, ids).
+			Update("garbage", true)
+		if db.Error != nil {
+			g.log.Errorf("Failed updating compaction [%s] %d => %d: %v", g.tableName, from, nextBatch,
+				db.Error)
+		} else if db.RowsAffected > 0 {
+			g.log.Debugf("compacted [%s] [%d] rows", g.tableName, db.RowsAffected)
+			metrics.CompactedRows.WithLabelValues(g.tableName).Add(float64(db.RowsAffected))
+		}
+
+		from = nextBatch
 	}
+	return from
 }
 
-func (g *GormDB) markCompaction(ctx context.Context, id uint) (bool, error) {
+// markCompaction writes a compaction record for id, unless the table is already compacted that far. With replicas
+// this server must hold the garbage collection lease at epoch.
+func (g *GormDB) markCompaction(ctx context.Context, id, epoch uint) (bool, error) {
 	cont := false
 	err := g.Transaction(ctx, func(ctx context.Context) error {
+		if g.replicas {
+			if err := g.checkGCLease(ctx, epoch); err != nil {
+				return err
+			}
+			compaction, err := g.getCompaction(ctx)
+			if err != nil {
+				return err
+			}
+			if id <= compaction {
+				// another server compacted further while this one's watch loop lagged behind
+				return nil
+			}
+		}
 		var lastRecord Record
 		resp := g.newQuery(ctx).Last(&lastRecord)
 		if resp.Error != nil {
@@ -332,7 +470,7 @@ func (g *GormDB) markCompaction(ctx context.Context, id uint) (bool, error) {
 			return nil
 		}
 		cont = true
-		logrus.Debugf("Inserting compaction record for [%s] [%d]", g.tableName, id)
+		g.log.Debugf("Inserting compaction record for [%s] [%d]", g.tableName, id)
 		return g.Insert(ctx, &Record{
 			Namespace: strconv.FormatUint(uint64(id), 10),
 		})
@@ -344,8 +482,11 @@ func (g *GormDB) fill(ctx context.Context, id uint) {
 	err := g.Insert(ctx, &Record{
 		ID: id,
 	})
-	if err != nil {
-		klog.Infof("failed to insert fill record for ID %d: %v", id, err)
+	if errtypes.IsUniqueConstraintErr(err) {
+		// the write committed after all, or another server filled the gap first
+		g.log.Debugf("Record %d of [%s] exists, not filling it", id, g.tableName)
+	} else if err != nil {
+		g.log.Infof("failed to insert fill record for ID %d: %v", id, err)
 	}
 }
 
@@ -355,7 +496,10 @@ func (g *GormDB) since(ctx context.Context, id uint) ([]Record, error) {
 	resp := db.Table(g.tableName).Model(records).Where("id > ?", id).Order("id ASC").Find(&records)
 
 	for i := range records {
-		if err := g.decryptData(ctx, &records[i]); err != nil {
+		if err := g.decryptData(ctx, &records[i]); errors.Is(err, ErrKeyDestroyed) {
+			// the watches skip it like a fill record
+			records[i] = Record{ID: records[i].ID}
+		} else if err != nil {
 			return records, err
 		}
 	}
@@ -364,15 +508,26 @@ func (g *GormDB) since(ctx context.Context, id uint) ([]Record, error) {
 }
 
 func (g *GormDB) initializeWatch(ctx context.Context, criteria WatchCriteria, result chan<- Record) error {
+	if criteria.After != 0 {
+		return g.replay(ctx, criteria, result)
+	}
+	if ok, err := g.initializeWatchFromCache(ctx, criteria, result); ok || err != nil {
+		return err
+	}
+	if g.snapshotTTL > 0 {
+		return g.initializeWatchFromSnapshot(ctx, criteria, result)
+	}
+
 	var (
-		before = uint(0)
-		after  = criteria.After
+		before uint
+		after  uint
 	)
 
 	for {
 		resp, newBefore, err := g.Get(ctx, Criteria{
 			Name:                  criteria.Name,
 			Namespace:             criteria.Namespace,
+			Namespaces:            criteria.Namespaces,
 			After:                 after,
 			LabelSelector:         criteria.LabelSelector,
 			FieldSelector:         criteria.FieldSelector,
@@ -384,7 +539,8 @@ func (g *GormDB) initializeWatch(ctx context.Context, criteria WatchCriteria, re
 		if err != nil {
 			return err
 		}
-		for _, record := range resp {
+		for _, record := range readable(resp) {
+			record.InitialState = true
 			result <- record
 		}
 		if len(resp) == 1000 {
@@ -398,6 +554,42 @@ func (g *GormDB) initializeWatch(ctx context.Context, criteria WatchCriteria, re
 	return nil
 }
 
+// replay sends every record written after criteria.After in order, including the intermediate versions of objects
+// that changed more than once and objects that have since been removed.
+func (g *GormDB) replay(ctx context.Context, criteria WatchCriteria, result chan<- Record) error {
+	after := criteria.After
+	for {
+		var records []Record
+		query := g.newQuery(ctx).Where("id > ?", after)
+		if criteria.Namespace != nil {
+			g.whereEquals(query, "namespace", *criteria.Namespace)
+		} else if criteria.Namespaces != nil {
+			query.Where("namespace IN ?", criteria.Namespaces)
+		}
+		if criteria.Name != "" {
+			query.Where("name = ?", criteria.Name)
+		}
+		if criteria.PartitionID != "" {
+			query.Where("partition_id = ?", criteria.PartitionID)
+		}
+		if err := query.Order("id ASC").Limit(1000).Find(&records).Error; err != nil {
+			return err
+		}
+		for i := range records {
+			if err := g.decryptData(ctx, &records[i]); errors.Is(err, ErrKeyDestroyed) {
+				continue
+			} else if err != nil {
+				return err
+			}
+			result <- records[i]
+		}
+		if len(records) < 1000 {
+			return nil
+		}
+		after = records[len(records)-1].ID
+	}
+}
+
 // validateCriteria should be called while holding the g.compactionLock
 func (g *GormDB) validateCriteria(before, after uint) error {
 	if before != 0 && before < g.compaction {
@@ -410,12 +602,19 @@ func (g *GormDB) validateCriteria(before, after uint) error {
 }
 
 func (g *GormDB) Watch(ctx context.Context, criteria WatchCriteria) (chan Record, error) {
+	if criteria.After != 0 {
+		if err := g.refreshCompaction(ctx); err != nil {
+			return nil, err
+		}
+	}
+
 	var (
-		lastID     uint
+		// records up to the resource version the watch starts after may still be broadcast, they are skipped
+		lastID     = criteria.After
 		sub        = g.broadcaster.Subscribe()
 		result     = make(chan Record)
 		initialize = make(chan Record)
-		merged     = channel.Concat(initialize, sub.C)
+		merged     = channel.Concat(initialize, g.bufferSubscription(sub))
 	)
 
 	// this will be released after the initializeWatch is done
@@ -433,10 +632,15 @@ func (g *GormDB) Watch(ctx context.Context, criteria WatchCriteria) (chan Record
 		return nil, err
 	}
 
+	watchers := metrics.Watchers.WithLabelValues(g.tableName)
+	watchers.Inc()
 	go func() {
 		defer close(result)
 		defer sub.Close()
+		defer watchers.Dec()
 
+		// initialState is set while the watch sends the initial state of the objects
+		var initialState bool
 		for {
 			select {
 			case <-ctx.Done():
@@ -446,26 +650,55 @@ func (g *GormDB) Watch(ctx context.Context, criteria WatchCriteria) (chan Record
 					}
 				}()
 				return
+			case <-g.terminated:
+				go func() {
+					for range merged {
+					}
+				}()
+				if resume := max(lastID, criteria.After); resume != 0 && !initialState {
+					select {
+					case result <- Record{ID: resume}:
+					case <-ctx.Done():
+					}
+				}
+				return
 			case rec, ok := <-merged:
 				if !ok {
 					// This means that both initialize and sub.C have been closed.
 					return
 				}
-				if lastID != 0 && rec.ID <= lastID {
+				if rec.Overflowed {
+					if g.watchOverflow != WatchOverflowClose {
+						result <- rec
+					}
+					go func() {
+						for range merged {
+						}
+					}()
+					return
+				}
+				// bookmarks repeat the ID of the last record when nothing changed since
+				if lastID != 0 && (rec.ID < lastID || rec.ID == lastID && rec.Name != "") {
 					continue
 				}
 				lastID = rec.ID
+				initialState = rec.InitialState
 				result <- rec
 			}
 		}
 	}()
 
 	go func() {
+		start := time.Now()
 		err := g.initializeWatch(ctx, criteria, initialize)
+		metrics.OperationDuration.WithLabelValues(g.tableName, "watch").Observe(time.Since(start).Seconds())
 		g.compactionLock.RUnlock()
 		close(initialize)
 		if err != nil {
-			logrus.Errorf("error initializing watch for kind %s: %v", g.gvk.Kind, err)
+			// a watch whose client went away stops initializing, that is not worth an error
+			if ctx.Err() == nil {
+				g.log.Errorf("error initializing watch for kind %s: %v", g.gvk.Kind, err)
+			}
 			sub.Close()
 		}
 	}()
@@ -473,6 +706,16 @@ func (g *GormDB) Watch(ctx context.Context, criteria WatchCriteria) (chan Record
 	return result, nil
 }
 
+// bufferSubscription returns the records of sub through a buffer, see bufferRecords. A watch that overflows its
+// buffer is closed.
+func (g *GormDB) bufferSubscription(sub *broadcaster.Subscription[Record]) chan Record {
+	return bufferRecords(sub.C, g.watchBufferSize, func() {
+		metrics.WatchOverflows.WithLabelValues(g.tableName).Inc()
+		g.log.Warnf("closing watch of %s that fell more than %d events behind", g.tableName, g.watchBufferSize)
+		sub.Close()
+	})
+}
+
 func (g *GormDB) getMinID(ctx context.Context) (uint, error) {
 	var (
 		records []Record
@@ -480,7 +723,7 @@ func (g *GormDB) getMinID(ctx context.Context) (uint, error) {
 	)
 	db := g.getDB(ctx).WithContext(ctx)
 	result := db.Table(g.tableName).Model(records).Select("id").
-		Where("garbage IS FALSE").
+		Where("garbage = ?", false).
 		Order("id ASC").
 		Limit(1).
 		Scan(&max)
@@ -505,9 +748,18 @@ func (g *GormDB) find(ctx context.Context, db *gorm.DB, criteria Criteria) (resu
 	if err != nil {
 		return nil, 0, err
 	}
+	if !criteria.ignoreCompactionCheck && (criteria.Before != 0 || criteria.After != 0) {
+		if err := g.refreshCompaction(ctx); err != nil {
+			return nil, 0, err
+		}
+	}
 	if !criteria.ignoreCompactionCheck {
+		after := criteria.After
+		if criteria.continued {
+			after = 0
+		}
 		g.compactionLock.RLock()
-		if err := g.validateCriteria(criteria.Before, criteria.After); err != nil {
+		if err := g.validateCriteria(criteria.Before, after); err != nil {
 			g.compactionLock.RUnlock()
 			return nil, 0, err
 		}
@@ -521,7 +773,11 @@ func (g *GormDB) find(ctx context.Context, db *gorm.DB, criteria Criteria) (resu
 	}
 
 	for i := range result {
-		if err := g.decryptData(ctx, &result[i]); err != nil {
+		if err := g.decryptData(ctx, &result[i]); errors.Is(err, ErrKeyDestroyed) {
+			// The record stays in the result, so callers paging through the records see where the page ends.
+			result[i].Data = nil
+			result[i].shredded = true
+		} else if err != nil {
 			return result, resourceVersion, err
 		}
 	}
@@ -534,20 +790,27 @@ func (g *GormDB) finalize(ctx context.Context, db *gorm.DB, criteria Criteria) (
 	if err != nil {
 		return nil, 0, err
 	}
-	return db.Joins(
-		fmt.Sprintf("join (?) j on j.id = %s.id", g.quote(g.tableName)), joinQuery), resourceVersion, nil
+	// The order is applied to the outer query, not all databases allow ordering a subquery. The columns aren't listed,
+	// the optional columns of Record may not exist, see omittedColumns.
+	return db.Select(g.quote(g.tableName)+".*").Joins(
+		fmt.Sprintf("join (?) j on j.id = %s.id", g.quote(g.tableName)), joinQuery).
+		Order(g.quote(g.tableName) + ".id ASC"), resourceVersion, nil
 }
 
 func (g *GormDB) possibleIDs(ctx context.Context, criteria Criteria) (*gorm.DB, uint, error) {
 	query := g.newQuery(ctx).Select("namespace", "name", "max(id) AS id").
-		Group("namespace").Group("name").
-		Order("id ASC")
+		Group("namespace").Group("name")
 
 	if criteria.Namespace != nil {
-		query.Where("namespace = ?", *criteria.Namespace)
+		g.whereEquals(query, "namespace", *criteria.Namespace)
+	} else if criteria.Namespaces != nil {
+		query.Where("namespace IN ?", criteria.Namespaces)
 	}
 	if criteria.Name != "" {
 		query.Where("name = ?", criteria.Name)
+	} else if g.emptyIsNull() {
+		// don't pick up fill or compaction records
+		query.Where("name IS NOT NULL")
 	} else {
 		// don't pick up fill or compaction records
 		query.Where("name != ?", "")
@@ -572,7 +835,7 @@ func (g *GormDB) possibleIDs(ctx context.Context, criteria Criteria) (*gorm.DB,
 		query.Where("id <= ?", criteria.Before)
 	}
 	if !criteria.IncludeGC {
-		query.Where("garbage IS FALSE")
+		query.Where("garbage = ?", false)
 	}
 	if criteria.PartitionID != "" {
 		query.Where("partition_id = ?", criteria.PartitionID)
@@ -581,12 +844,32 @@ func (g *GormDB) possibleIDs(ctx context.Context, criteria Criteria) (*gorm.DB,
 	return query, criteria.Before, nil
 }
 
+// emptyIsNull returns true for databases that store empty strings as NULL, so they can't be compared with "".
+func (g *GormDB) emptyIsNull() bool {
+	return g.db != nil && g.db.Dialector.Name() == "oracle"
+}
+
+func (g *GormDB) whereEquals(query *gorm.DB, column, value string) {
+	if value == "" && g.emptyIsNull() {
+		query.Where(column + " IS NULL")
+	} else {
+		query.Where(column+" = ?", value)
+	}
+}
+
 func (g *GormDB) newQuery(ctx context.Context) *gorm.DB {
 	var records []Record
 	return g.getDB(ctx).WithContext(ctx).Table(g.tableName).Model(records)
 }
 
 func (g *GormDB) Get(ctx context.Context, criteria Criteria) ([]Record, uint, error) {
+	start := time.Now()
+	if criteria.cached {
+		if result, resourceVersion, ok, err := g.getCached(ctx, criteria); ok || err != nil {
+			metrics.OperationDuration.WithLabelValues(g.tableName, "get").Observe(time.Since(start).Seconds())
+			return result, resourceVersion, g.contextError(ctx, "get", err)
+		}
+	}
 	query := g.newQuery(ctx)
 
 	if criteria.Limit != 0 {
@@ -601,47 +884,93 @@ func (g *GormDB) Get(ctx context.Context, criteria Criteria) ([]Record, uint, er
 		reqs, ok := criteria.LabelSelector.Requirements()
 		if ok {
 			for _, req := range reqs {
-				l := datatypes.JSONQuery("metadata").Value("labels", req.Key())
+				l := g.selectorColumn(SelectorTypeLabel, req.Key(), "metadata", "labels", req.Key())
+				exists := datatypes.JSONQuery("metadata").Value("labels", req.Key()).Exists()
 				if req.Operator() == selection.Equals && req.Key() != "" && req.Values().Len() == 1 {
 					query.Where("? = ?", l, req.Values().List()[0])
 				}
 				if req.Operator() == selection.In && req.Key() != "" {
 					query.Where("? in ?", l, req.Values().List())
 				}
+				// like Kubernetes, negative requirements match objects that don't have the label
 				if req.Operator() == selection.NotIn && req.Key() != "" {
-					query.Where("? not in ?", l, req.Values().List())
+					query.Where("(? IS NULL OR ? not in ?)", l, l, req.Values().List())
 				}
 				if req.Operator() == selection.NotEquals && req.Key() != "" && req.Values().Len() == 1 {
-					query.Where("? = ?", l, req.Values().List()[0])
+					query.Where("(? IS NULL OR ? <> ?)", l, l, req.Values().List()[0])
 				}
 				if req.Operator() == selection.Exists && req.Key() != "" {
-					query.Where(l.Exists())
+					query.Where(exists)
 				}
 				if req.Operator() == selection.DoesNotExist && req.Key() != "" {
-					query.Where("not ?", l.Exists())
+					query.Where("not ?", exists)
 				}
 			}
 		} else {
-			query.Where(false)
+			query.Where("1 = 0")
 		}
 	}
 
 	if criteria.FieldSelector != nil {
 		for _, req := range criteria.FieldSelector.Requirements() {
-			if req.Field == "metadata.name" || req.Field == "metadata.namespace" {
+			column, path, ok := fieldSelectorPath(req.Field)
+			if !ok {
 				continue
 			}
-			if req.Operator == selection.Equals && req.Field != "" {
-				parts := strings.Split(req.Field, ".")
-				if parts[0] == "metadata" {
-					continue
+			f := g.selectorColumn(SelectorTypeField, req.Field, column, path...)
+			// Values are compared as strings, as Kubernetes does. A field that isn't set is the empty string.
+			switch req.Operator {
+			case selection.Equals, selection.DoubleEquals:
+				if req.Value == "" {
+					query.Where("(? IS NULL OR ? = ?)", f, f, "")
+				} else if _, indexed := f.(clause.Column); indexed && g.truncatedSelectorColumn(SelectorTypeField, req.Value) {
+					// the column narrows the records down to those starting with the value, which are then
+					// compared by their whole value
+					prefix := string([]rune(req.Value)[:mysqlFieldColumnLength])
+					query.Where("? = ? AND ? = ?", f, prefix, datatypes.JSONQuery(column).Value(path...), req.Value)
+				} else {
+					query.Where("? = ?", f, req.Value)
+				}
+			case selection.NotEquals:
+				if req.Value == "" {
+					query.Where("(? IS NOT NULL AND ? <> ?)", f, f, "")
+				} else {
+					if _, indexed := f.(clause.Column); indexed && g.truncatedSelectorColumn(SelectorTypeField, req.Value) {
+						f = datatypes.JSONQuery(column).Value(path...)
+					}
+					query.Where("(? IS NULL OR ? <> ?)", f, f, req.Value)
 				}
-				query.Where("? = ?", datatypes.JSONQuery("data").Value(parts...), req.Value)
 			}
 		}
 	}
 
-	return g.find(ctx, query, criteria)
+	result, resourceVersion, err := g.find(ctx, query, criteria)
+	metrics.OperationDuration.WithLabelValues(g.tableName, "get").Observe(time.Since(start).Seconds())
+	if err == nil {
+		g.recordSelectors(criteria, time.Since(start))
+	}
+	return result, resourceVersion, g.contextError(ctx, "get", err)
+}
+
+// fieldSelectorPath returns the JSON column and path a field selector of the fields outside of metadata, such as
+// spec.nodeName or status.phase, refers to. Metadata fields are matched by the caller.
+func fieldSelectorPath(field string) (string, []string, bool) {
+	parts := strings.Split(field, ".")
+	for _, part := range parts {
+		if part == "" {
+			return "", nil, false
+		}
+	}
+	switch parts[0] {
+	case "metadata", "apiVersion", "kind":
+		return "", nil, false
+	case "status":
+		if len(parts) == 1 {
+			return "", nil, false
+		}
+		return "status", parts[1:], true
+	}
+	return "data", parts, true
 }
 
 func (g *GormDB) quote(s string) string {
@@ -657,34 +986,188 @@ func (g *GormDB) getDB(ctx context.Context) *gorm.DB {
 	if ok {
 		return db
 	}
+	if db, ok := g.classDBs[QueryClassFromContext(ctx)]; ok {
+		return db
+	}
 	return g.db
 }
 
 func (g *GormDB) Transaction(ctx context.Context, do func(ctx context.Context) error) error {
-	return g.getDB(ctx).Transaction(func(tx *gorm.DB) error {
-		return do(context.WithValue(ctx, dbKey{}, tx))
+	if _, ok := ctx.Value(dbKey{}).(*gorm.DB); ok {
+		// nested in another transaction, which commits it
+		return g.getDB(ctx).Transaction(func(tx *gorm.DB) error {
+			return do(context.WithValue(ctx, dbKey{}, tx))
+		})
+	}
+
+	var hooks *afterCommitHooks
+	err := g.retry(ctx, func() error {
+		// hooks of an attempt that was rolled back are dropped with it
+		hooks = &afterCommitHooks{}
+		return g.getDB(ctx).Transaction(func(tx *gorm.DB) error {
+			return do(context.WithValue(context.WithValue(ctx, dbKey{}, tx), afterCommitKey{}, hooks))
+		})
 	})
+	if err == nil {
+		hooks.run()
+	}
+	return g.contextError(ctx, "transaction", err)
+}
+
+type afterCommitKey struct{}
+
+type afterCommitHooks struct {
+	lock  sync.Mutex
+	hooks []func()
+}
+
+func (a *afterCommitHooks) run() {
+	a.lock.Lock()
+	hooks := a.hooks
+	a.hooks = nil
+	a.lock.Unlock()
+	for _, hook := range hooks {
+		hook()
+	}
+}
+
+// afterCommit runs hook once the transaction of ctx has committed, or right away if ctx isn't in a transaction. The
+// hook is not run if the transaction is rolled back.
+func (g *GormDB) afterCommit(ctx context.Context, hook func()) {
+	hooks, ok := ctx.Value(afterCommitKey{}).(*afterCommitHooks)
+	if !ok {
+		hook()
+		return
+	}
+	hooks.lock.Lock()
+	hooks.hooks = append(hooks.hooks, hook)
+	hooks.lock.Unlock()
 }
 
 func (g *GormDB) Insert(ctx context.Context, rec *Record) error {
-	defer g.triggerWatchLoop()
+	// the watch loop would not see the record before the transaction it's inserted in commits
+	defer g.afterCommit(ctx, g.triggerWatchLoop)
+	defer func(start time.Time) {
+		metrics.OperationDuration.WithLabelValues(g.tableName, "insert").Observe(time.Since(start).Seconds())
+	}(time.Now())
 	if err := g.encryptData(ctx, rec); err != nil {
 		return err
 	}
-	return g.getDB(ctx).Transaction(func(tx *gorm.DB) error {
-		tx = tx.WithContext(ctx)
-		if rec.Previous != nil {
-			db := tx.Table(g.tableName).Where("id = ?", *rec.Previous).
-				Update("latest", false)
-			if db.Error != nil {
-				return db.Error
+	id := rec.ID
+	err := g.retry(ctx, func() error {
+		// a failed attempt may have assigned an ID
+		rec.ID = id
+		return g.getDB(ctx).Transaction(func(tx *gorm.DB) error {
+			tx = tx.WithContext(ctx)
+			if err := g.allocateID(ctx, tx, rec); err != nil {
+				return err
+			}
+			if rec.Previous != nil {
+				db := tx.Table(g.tableName).Where("id = ?", *rec.Previous).
+					Update("latest", false)
+				if db.Error != nil {
+					return db.Error
+				}
 			}
+			if rec.Name != "" {
+				rec.Latest = true
+			}
+			return tx.Table(g.tableName).Omit(g.omittedColumns()...).Create(rec).Error
+		})
+	})
+	return g.contextError(ctx, "insert", err)
+}
+
+var _ BatchInserter = (*GormDB)(nil)
+
+// insertBatchSize is the number of records InsertBatch writes per statement.
+const insertBatchSize = 100
+
+func (g *GormDB) InsertBatch(ctx context.Context, recs []*Record) error {
+	defer g.afterCommit(ctx, g.triggerWatchLoop)
+	defer func(start time.Time) {
+		metrics.OperationDuration.WithLabelValues(g.tableName, "insert_batch").Observe(time.Since(start).Seconds())
+	}(time.Now())
+	ids := make([]uint, len(recs))
+	var previous []uint
+	for i, rec := range recs {
+		if err := g.encryptData(ctx, rec); err != nil {
+			return err
 		}
-		if rec.Name != "" {
-			rec.Latest = true
+		ids[i] = rec.ID
+		if rec.Previous != nil {
+			previous = append(previous, *rec.Previous)
 		}
-		return tx.Table(g.tableName).Create(rec).Error
+	}
+	err := g.retry(ctx, func() error {
+		return g.getDB(ctx).Transaction(func(tx *gorm.DB) error {
+			tx = tx.WithContext(ctx)
+			for i, rec := range recs {
+				// a failed attempt may have assigned an ID
+				rec.ID = ids[i]
+				if err := g.allocateID(ctx, tx, rec); err != nil {
+					return err
+				}
+				if rec.Name != "" {
+					rec.Latest = true
+				}
+			}
+			for chunk := range slices.Chunk(previous, insertBatchSize) {
+				db := tx.Table(g.tableName).Where("id IN ?", chunk).Update("latest", false)
+				if db.Error != nil {
+					return db.Error
+				}
+			}
+			return tx.Table(g.tableName).Omit(g.omittedColumns()...).CreateInBatches(recs, insertBatchSize).Error
+		})
 	})
+	return g.contextError(ctx, "insert", err)
+}
+
+// addOptionalColumns adds the columns of the options of the table it doesn't have yet, see globalSequenceColumn and
+// userColumns.
+func (g *GormDB) addOptionalColumns(ctx context.Context) error {
+	db := g.db.WithContext(ctx).Table(g.tableName)
+	if g.globalSequence {
+		if err := db.AutoMigrate(&globalSequenceColumn{}); err != nil {
+			return fmt.Errorf("adding the global sequence column to %s: %w", g.tableName, err)
+		}
+	}
+	if g.userColumns {
+		if err := db.AutoMigrate(&userColumns{}); err != nil {
+			return fmt.Errorf("adding the user columns to %s: %w", g.tableName, err)
+		}
+	}
+	return nil
+}
+
+// omittedColumns returns the optional columns of Record the table doesn't have.
+func (g *GormDB) omittedColumns() []string {
+	var omitted []string
+	if !g.globalSequence {
+		omitted = append(omitted, "global_sequence")
+	}
+	if !g.userColumns {
+		omitted = append(omitted, "created_by", "updated_by")
+	}
+	return omitted
+}
+
+func (g *GormDB) allocateID(ctx context.Context, tx *gorm.DB, rec *Record) (err error) {
+	if g.globalSequence && rec.Name != "" {
+		rec.GlobalSequence, err = (&SequenceTable{}).Allocate(ctx, tx, globalSequenceName)
+		if err != nil {
+			return err
+		}
+	}
+	if g.ids == nil {
+		return nil
+	}
+	if rec.ID != 0 {
+		return g.ids.Reserve(ctx, tx, g.tableName, rec.ID)
+	}
+	rec.ID, err = g.ids.Allocate(ctx, tx, g.tableName)
+	return err
 }
 
 // uid is here to fulfill the value.Context interface for the transformer.
@@ -699,38 +1182,78 @@ func (u uid) AuthenticatedData() []byte {
 func (g *GormDB) encryptData(ctx context.Context, rec *Record) error {
 	gk := schema.GroupKind{Group: rec.APIGroup, Kind: rec.Kind}
 
-	if t, exists := g.transformers[gk]; exists {
-		logrus.Debugf("Encrypting data for record %s in namespace %s", rec.Name, rec.Namespace)
+	partitionKey, err := g.partitionTransformer(ctx, rec)
+	if err != nil {
+		return err
+	}
+	t, exists := g.transformers[gk]
+	if partitionKey != nil {
+		t, exists = partitionKey, true
+	}
+
+	if exists {
+		g.log.Debugf("Encrypting data for record %s in namespace %s", rec.Name, rec.Namespace)
 		encryptedData, err := t.TransformToStorage(ctx, []byte(rec.Data.String()), uid(rec.UID))
 		if err != nil {
 			return err
 		}
 
-		rec.Data, err = json.Marshal(map[string]string{
+		encrypted := map[string]string{
 			"e": base64.StdEncoding.EncodeToString(encryptedData),
-		})
+		}
+		if partitionKey != nil {
+			// the key of the partition is needed to decrypt the data
+			encrypted["p"] = "1"
+		}
+		rec.Data, err = json.Marshal(encrypted)
 		return err
 	}
 	return nil
 }
 
+// decryptData decrypts the data of rec. It returns ErrKeyDestroyed if the data was encrypted with the key of a
+// partition that has been destroyed.
 func (g *GormDB) decryptData(ctx context.Context, rec *Record) error {
+	_, err := g.decrypt(ctx, rec)
+	return err
+}
+
+// decrypt decrypts the data of rec and returns whether its transformer reports it as stale, because it was encrypted
+// with a key that isn't the write key anymore.
+func (g *GormDB) decrypt(ctx context.Context, rec *Record) (bool, error) {
 	gk := schema.GroupKind{Group: rec.APIGroup, Kind: rec.Kind}
 
-	if t, exists := g.transformers[gk]; exists {
-		logrus.Debugf("Decrypting data for record %s in namespace %s", rec.Name, rec.Namespace)
-		m := map[string]string{}
-		if err := json.Unmarshal(rec.Data, &m); err != nil {
-			// If it doesn't unmarshal, then it wasn't encrypted by the transformer, so just return
-			return nil
-		}
-		data, err := base64.StdEncoding.DecodeString(m["e"])
+	t, exists := g.transformers[gk]
+	if !exists && g.partitionKeys == nil {
+		return false, nil
+	}
+
+	m := map[string]string{}
+	if err := json.Unmarshal(rec.Data, &m); err != nil || m["e"] == "" {
+		// If it doesn't unmarshal, then it wasn't encrypted by the transformer, so just return
+		return false, nil
+	}
+	if m["p"] != "" {
+		partitionKey, err := g.partitionTransformer(ctx, rec)
 		if err != nil {
-			return err
+			return false, err
 		}
+		if partitionKey == nil {
+			return false, fmt.Errorf("no key for partition %s of record %d", rec.PartitionID, rec.ID)
+		}
+		t, exists = partitionKey, true
+	}
+	if !exists {
+		return false, nil
+	}
 
-		rec.Data, _, err = t.TransformFromStorage(ctx, data, uid(rec.UID))
-		return err
+	g.log.Debugf("Decrypting data for record %s in namespace %s", rec.Name, rec.Namespace)
+	data, err := base64.StdEncoding.DecodeString(m["e"])
+	if err != nil {
+		return false, err
 	}
-	return nil
+
+	var stale bool
+	rec.Data, stale, err = t.TransformFromStorage(ctx, data, uid(rec.UID))
+	return stale, err
 }
@@ -1,15 +1,13 @@
 package db
 
 import (
-	"fmt"
-
 	"github.com/acorn-io/mink/pkg/db/errtypes"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 )
 
 const (
-	OptimisticLockErrorMsg = "the object has been modified; please apply your changes to the latest version and try again"
+	OptimisticLockErrorMsg = errtypes.OptimisticLockErrorMsg
 )
 
 func newConflict(gvk schema.GroupVersionKind, name string, err error) error {
@@ -37,23 +35,22 @@ func newNotFound(gvk schema.GroupVersionKind, name string) error {
 }
 
 func newCompactionError(requested, current uint) error {
-	return apierrors.NewResourceExpired(fmt.Sprintf("resource version %d before current compaction %d", requested, current))
+	return errtypes.NewCompactionError(requested, current)
 }
 
 func newResourceVersionMismatch(gvk schema.GroupVersionKind, name string) error {
-	return apierrors.NewConflict(schema.GroupResource{
-		Group:    gvk.Group,
-		Resource: gvk.Kind,
-	}, name, fmt.Errorf(OptimisticLockErrorMsg))
+	return errtypes.NewResourceVersionMismatchError(gvk.GroupKind(), name, nil)
 }
 
+// translateDuplicateEntryErr turns the unique constraint violation of two concurrent writes to the same object into
+// the conflict the losing writer would have gotten had it read the object after the winner.
 func translateDuplicateEntryErr(err error, gvk schema.GroupVersionKind, objName string) error {
 	if errtypes.IsUniqueConstraintErr(err) {
-		return newConflict(gvk, objName, fmt.Errorf("object has been modified, please apply your changes to the latest version and try again"))
+		return errtypes.NewResourceVersionMismatchError(gvk.GroupKind(), objName, err)
 	}
 	return err
 }
 
 func newPartitionRequiredError() error {
-	return apierrors.NewInternalError(fmt.Errorf("partition ID required"))
+	return errtypes.NewPartitionRequiredError()
 }
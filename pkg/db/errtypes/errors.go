@@ -0,0 +1,97 @@
+package errtypes
+
+import (
+	"errors"
+	"fmt"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// OptimisticLockErrorMsg is the message of a ResourceVersionMismatchError, it matches the one used by Kubernetes.
+const OptimisticLockErrorMsg = "the object has been modified; please apply your changes to the latest version and try again"
+
+// Sentinel errors for use with errors.Is. The typed errors below match them, so callers that don't need the details
+// can write errors.Is(err, errtypes.ErrCompacted).
+var (
+	ErrCompacted               = errors.New("resource version compacted")
+	ErrPartitionRequired       = errors.New("partition ID required")
+	ErrResourceVersionMismatch = errors.New("resource version mismatch")
+	// ErrDuplicateEntry is returned by the Insert of backends other than SQL databases for a record whose Previous is
+	// already the Previous of another record.
+	ErrDuplicateEntry = errors.New("duplicate entry")
+)
+
+// The typed errors embed the *apierrors.StatusError returned to clients, so the apierrors helpers such as
+// apierrors.IsConflict keep working on them.
+
+// CompactionError is returned when a list or watch asks for a resource version that has been compacted. The request
+// should be restarted from the current state, typically by listing again.
+type CompactionError struct {
+	*apierrors.StatusError
+	Requested uint
+	Current   uint
+}
+
+func NewCompactionError(requested, current uint) *CompactionError {
+	return &CompactionError{
+		StatusError: apierrors.NewResourceExpired(fmt.Sprintf("resource version %d before current compaction %d", requested, current)),
+		Requested:   requested,
+		Current:     current,
+	}
+}
+
+func (e *CompactionError) Is(target error) bool {
+	return target == ErrCompacted
+}
+
+// PartitionRequiredError is returned when a strategy requires a partition ID and the request has none. Retrying the
+// request won't help.
+type PartitionRequiredError struct {
+	*apierrors.StatusError
+}
+
+func NewPartitionRequiredError() *PartitionRequiredError {
+	return &PartitionRequiredError{
+		StatusError: apierrors.NewInternalError(ErrPartitionRequired),
+	}
+}
+
+func (e *PartitionRequiredError) Is(target error) bool {
+	return target == ErrPartitionRequired
+}
+
+// ResourceVersionMismatchError is returned when an update or delete lost a race with another write to the same
+// object. The change can be retried after reading the latest version of the object.
+type ResourceVersionMismatchError struct {
+	*apierrors.StatusError
+	GroupKind schema.GroupKind
+	Name      string
+	// Err is the database error that revealed the conflict, if any.
+	Err error
+}
+
+func NewResourceVersionMismatchError(gk schema.GroupKind, name string, err error) *ResourceVersionMismatchError {
+	return &ResourceVersionMismatchError{
+		StatusError: apierrors.NewConflict(schema.GroupResource{
+			Group:    gk.Group,
+			Resource: gk.Kind,
+		}, name, errors.New(OptimisticLockErrorMsg)),
+		GroupKind: gk,
+		Name:      name,
+		Err:       err,
+	}
+}
+
+func (e *ResourceVersionMismatchError) Is(target error) bool {
+	return target == ErrResourceVersionMismatch
+}
+
+func (e *ResourceVersionMismatchError) Unwrap() error {
+	return e.Err
+}
+
+// IsRetryable returns true if err is a conflict that can be resolved by reading the object again and retrying.
+func IsRetryable(err error) bool {
+	return errors.Is(err, ErrResourceVersionMismatch)
+}
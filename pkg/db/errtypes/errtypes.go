@@ -9,6 +9,9 @@ import (
 )
 
 func IsUniqueConstraintErr(err error) bool {
+	if errors.Is(err, ErrDuplicateEntry) {
+		return true
+	}
 	if mysqlErr := (*mysql.MySQLError)(nil); errors.As(err, &mysqlErr) && mysqlErr.Number == 1062 { // error 1062 is a duplicate entry error
 		return true
 	}
@@ -5,16 +5,19 @@ import (
 	"database/sql"
 	"fmt"
 	"net/http"
+	"slices"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/acorn-io/mink/pkg/db/glogrus"
+	"github.com/acorn-io/mink/pkg/logging"
 	"github.com/acorn-io/mink/pkg/strategy"
 	"github.com/acorn-io/mink/pkg/types"
 	"github.com/glebarez/sqlite"
-	"github.com/sirupsen/logrus"
 	"gorm.io/driver/mysql"
 	"gorm.io/driver/postgres"
+	"gorm.io/driver/sqlserver"
 	"gorm.io/gorm"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
@@ -28,9 +31,25 @@ type Factory struct {
 	SQLDB               *sql.DB
 	schema              *runtime.Scheme
 	migrationTimeout    time.Duration
+	pool                PoolOptions
+	dialectPools        map[string]PoolOptions
 	AutoMigrate         bool
 	transformers        map[schema.GroupKind]value.Transformer
 	partitionIDRequired bool
+	strategyOptions     []StrategyOption
+	tableOptions        string
+	queryClassConns     map[QueryClass]int
+	replicas            bool
+	migrations          []Migration
+	migrationDryRun     bool
+	log                 logging.Logger
+	// QueryClassDBs are the dedicated connection pools configured with WithQueryClassPool.
+	QueryClassDBs map[QueryClass]*gorm.DB
+	// Backend is set instead of DB and SQLDB for DSNs of a registered backend, see RegisterBackend.
+	Backend Backend
+
+	strategiesLock sync.Mutex
+	strategies     []*Strategy
 }
 
 type FactoryOption func(*Factory)
@@ -64,6 +83,14 @@ func WithEncryptionConfiguration(ctx context.Context, configPath string, apiServ
 	}, nil
 }
 
+// WithFactoryLogger sets the logger of the factory and of its queues, publishers, database queries and tables,
+// unless WithLogger sets another one for a table. The default is logging.Default.
+func WithFactoryLogger(logger logging.Logger) FactoryOption {
+	return func(f *Factory) {
+		f.log = logger
+	}
+}
+
 // WithPartitionIDRequired will configure the all DB strategies created from this factory to require a partition ID when querying the database.
 func WithPartitionIDRequired() FactoryOption {
 	return func(f *Factory) {
@@ -71,6 +98,25 @@ func WithPartitionIDRequired() FactoryOption {
 	}
 }
 
+// WithStrategyOptions will apply the given options to all DB strategies created from this factory.
+func WithStrategyOptions(opts ...StrategyOption) FactoryOption {
+	return func(f *Factory) {
+		f.strategyOptions = append(f.strategyOptions, opts...)
+	}
+}
+
+// WithQueryClassPool gives queries of the given class a dedicated connection pool of maxOpenConns connections, so
+// that a flood of queries in one class can't exhaust the connections available to the others. Dedicated pools are
+// not supported for sqlite, which is limited to a single connection.
+func WithQueryClassPool(class QueryClass, maxOpenConns int) FactoryOption {
+	return func(f *Factory) {
+		if f.queryClassConns == nil {
+			f.queryClassConns = map[QueryClass]int{}
+		}
+		f.queryClassConns[class] = maxOpenConns
+	}
+}
+
 func NewFactory(schema *runtime.Scheme, dsn string, opts ...FactoryOption) (*Factory, error) {
 	f := &Factory{
 		AutoMigrate: true,
@@ -83,49 +129,140 @@ func NewFactory(schema *runtime.Scheme, dsn string, opts ...FactoryOption) (*Fac
 		}
 	}
 
+	if backend, ok, err := openBackend(dsn); err != nil {
+		return nil, err
+	} else if ok {
+		f.Backend = backend
+		return f, nil
+	}
+
+	db, sqlDB, dialect, err := openDB(dsn)
+	if err != nil {
+		return nil, err
+	}
+	f.poolOptions(dialect).apply(sqlDB)
+	f.setGormLogger(db)
+	f.DB = db
+	f.SQLDB = sqlDB
+
+	if dialect == "tidb" {
+		f.tableOptions = tidbTableOptions
+		f.strategyOptions = append(f.strategyOptions, WithTiDB())
+	}
+
+	if len(f.queryClassConns) > 0 {
+		if dialect == "sqlite" {
+			f.logger().Warnf("Dedicated query class connection pools are not supported for sqlite, ignoring")
+		} else {
+			f.QueryClassDBs = map[QueryClass]*gorm.DB{}
+			for class, conns := range f.queryClassConns {
+				classDB, classSQLDB, _, err := openDB(dsn)
+				if err != nil {
+					f.Destroy()
+					return nil, err
+				}
+				classPool := f.poolOptions(dialect)
+				classPool.MaxOpenConns = conns
+				classPool.MaxIdleConns = min(classPool.MaxIdleConns, conns)
+				classPool.apply(classSQLDB)
+				f.setGormLogger(classDB)
+				f.QueryClassDBs[class] = classDB
+			}
+			f.strategyOptions = append(f.strategyOptions, WithQueryClassDBs(f.QueryClassDBs))
+		}
+	}
+
+	if len(f.migrations) > 0 {
+		ctx := context.Background()
+		if f.migrationTimeout != 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, f.migrationTimeout)
+			defer cancel()
+		}
+		if _, err := f.Migrate(ctx, f.migrationDryRun); err != nil {
+			return nil, err
+		}
+	}
+	return f, nil
+}
+
+// Destroy closes the connection pools of the factory, including the dedicated pools of query classes. The strategies
+// of the factory can't be used after.
+func (f *Factory) Destroy() {
+	for class, classDB := range f.QueryClassDBs {
+		if sqlDB, err := classDB.DB(); err == nil {
+			if err := sqlDB.Close(); err != nil {
+				f.logger().Warnf("Failed to close the connection pool of query class [%s]: %v", class, err)
+			}
+		}
+	}
+	if f.SQLDB != nil {
+		if err := f.SQLDB.Close(); err != nil {
+			f.logger().Warnf("Failed to close the connection pool: %v", err)
+		}
+	}
+}
+
+// dialectors maps DSN prefixes to the gorm dialectors of databases whose drivers are only compiled in with a build
+// tag, see oracle.go.
+var dialectors = map[string]func(dsn string) gorm.Dialector{}
+
+func lookupDialector(dsn string) (func(string) gorm.Dialector, string, bool) {
+	for prefix, open := range dialectors {
+		if strings.HasPrefix(dsn, prefix) {
+			return open, prefix, true
+		}
+	}
+	return nil, "", false
+}
+
+// openDB opens the database of dsn and returns its dialect: "mysql", "tidb", "postgres", "sqlserver", "sqlite", or the
+// prefix of a registered dialector without "://". The pool is left to the caller, see PoolOptions.
+func openDB(dsn string) (*gorm.DB, *sql.DB, string, error) {
 	var (
 		gdb                    gorm.Dialector
-		pool                   bool
+		dialect                string
 		skipDefaultTransaction bool
 	)
-	if strings.HasPrefix(dsn, "sqlite://") {
+	if open, prefix, ok := lookupDialector(dsn); ok {
+		dialect = strings.TrimSuffix(prefix, "://")
+		gdb = open(strings.TrimPrefix(dsn, prefix))
+	} else if strings.HasPrefix(dsn, "sqlite://") {
+		dialect = "sqlite"
 		skipDefaultTransaction = true
 		gdb = sqlite.Open(strings.TrimPrefix(dsn, "sqlite://"))
 	} else if strings.HasPrefix(dsn, "postgres://") {
-		pool = true
+		dialect = "postgres"
 		gdb = postgres.Open(dsn)
+	} else if strings.HasPrefix(dsn, "sqlserver://") {
+		dialect = "sqlserver"
+		gdb = sqlserver.Open(dsn)
+	} else if strings.HasPrefix(dsn, "tidb://") {
+		// TiDB speaks the MySQL protocol
+		dialect = "tidb"
+		gdb = mysql.Open(strings.TrimPrefix(dsn, "tidb://"))
 	} else {
 		dsn = strings.TrimPrefix(dsn, "mysql://")
-		pool = true
+		dialect = "mysql"
 		gdb = mysql.Open(dsn)
 	}
 	db, err := gorm.Open(gdb, &gorm.Config{
 		SkipDefaultTransaction: skipDefaultTransaction,
 		Logger: glogrus.New(glogrus.Config{
-			SlowThreshold:             200 * time.Millisecond,
+			SlowThreshold:             slowQueryThreshold,
 			IgnoreRecordNotFoundError: true,
 			LogSQL:                    true,
 		}),
 	})
 	if err != nil {
-		return nil, err
+		return nil, nil, "", err
 	}
 
 	sqlDB, err := db.DB()
 	if err != nil {
-		return nil, err
+		return nil, nil, "", err
 	}
-	sqlDB.SetConnMaxLifetime(time.Minute * 3)
-	if pool {
-		sqlDB.SetMaxIdleConns(5)
-		sqlDB.SetMaxOpenConns(5)
-	} else {
-		sqlDB.SetMaxIdleConns(1)
-		sqlDB.SetMaxOpenConns(1)
-	}
-	f.DB = db
-	f.SQLDB = sqlDB
-	return f, nil
+	return db, sqlDB, dialect, nil
 }
 
 func (f *Factory) Scheme() *runtime.Scheme {
@@ -137,9 +274,14 @@ func (f *Factory) Name() string {
 }
 
 func (f *Factory) Check(req *http.Request) error {
-	err := f.SQLDB.PingContext(req.Context())
+	var err error
+	if f.Backend != nil {
+		err = f.Backend.Ping(req.Context())
+	} else {
+		err = f.SQLDB.PingContext(req.Context())
+	}
 	if err != nil {
-		logrus.Warnf("Failed to ping database: %v", err)
+		f.logger().Warnf("Failed to ping database: %v", err)
 	}
 
 	return err
@@ -149,19 +291,34 @@ type TableNamer interface {
 	TableName() string
 }
 
-func (f *Factory) NewDBStrategy(obj types.Object) (strategy.CompleteStrategy, error) {
-	gvk, err := apiutil.GVKForObject(obj, f.schema)
+func defaultTableName(gvk schema.GroupVersionKind, obj types.Object) string {
+	if tn, ok := obj.(TableNamer); ok {
+		return tn.TableName()
+	}
+	return strings.ToLower(gvk.Kind)
+}
+
+// NewDBStrategy returns a strategy storing the objects of the type of obj, in addition to the options of the factory
+// opts are applied to it. obj may be an *unstructured.Unstructured with its apiVersion and kind set, to store objects
+// of types without Go types, which don't need to be registered in the scheme.
+func (f *Factory) NewDBStrategy(obj types.Object, opts ...StrategyOption) (strategy.CompleteStrategy, error) {
+	return f.NewDBStrategyForScheme(f.schema, obj, "", opts...)
+}
+
+// NewDBStrategyForScheme is NewDBStrategy for types registered in a scheme other than the factory's, such as types
+// defined while the server is running, whose registration must not race with readers of the factory's scheme. The
+// objects are stored in tableName, or if it is empty in the table NewDBStrategy would pick.
+func (f *Factory) NewDBStrategyForScheme(scheme *runtime.Scheme, obj types.Object, tableName string, opts ...StrategyOption) (strategy.CompleteStrategy, error) {
+	gvk, err := apiutil.GVKForObject(obj, scheme)
 	if err != nil {
 		return nil, err
 	}
 
-	var (
-		tableName string
-	)
-	if f.DB != nil {
-		tableName = strings.ToLower(gvk.Kind)
-		if tn, ok := obj.(TableNamer); ok {
-			tableName = tn.TableName()
+	if f.DB == nil {
+		tableName = ""
+	} else {
+		if tableName == "" {
+			tableName = defaultTableName(gvk, obj)
 		}
 		if f.AutoMigrate {
 			ctx := context.Background()
@@ -172,9 +329,21 @@ func (f *Factory) NewDBStrategy(obj types.Object) (strategy.CompleteStrategy, er
 				defer cancel()
 			}
 
-			if err := f.DB.WithContext(ctx).Table(tableName).AutoMigrate(&Record{}); err != nil {
+			db := f.DB.WithContext(ctx)
+			if f.tableOptions != "" {
+				db = db.Set("gorm:table_options", f.tableOptions)
+			}
+			if err := db.Table(tableName).AutoMigrate(&Record{}); err != nil {
+				return nil, err
+			}
+			if err := filterPreviousIndex(db, tableName); err != nil {
 				return nil, err
 			}
+			if f.replicas {
+				if err := f.DB.WithContext(ctx).Table(gcLeasesTableName).AutoMigrate(&GCLease{}); err != nil {
+					return nil, err
+				}
+			}
 
 			// Migrate from old index names.
 			migrator := f.DB.WithContext(ctx).Table(tableName).Migrator()
@@ -191,9 +360,71 @@ func (f *Factory) NewDBStrategy(obj types.Object) (strategy.CompleteStrategy, er
 
 		}
 	}
-	s, err := NewStrategy(f.schema, obj, tableName, f.DB, f.transformers, f.partitionIDRequired)
-	if err != nil {
-		return nil, err
+	var s *Strategy
+	if f.Backend != nil {
+		if tableName == "" {
+			tableName = defaultTableName(gvk, obj)
+		}
+		db, err := f.Backend.NewDB(tableName, gvk)
+		if err != nil {
+			return nil, err
+		}
+		s, err = NewStrategyForDB(scheme, obj, db, f.partitionIDRequired, slices.Concat([]StrategyOption{WithLogger(f.logger())}, f.strategyOptions, opts)...)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		s, err = NewStrategy(scheme, obj, tableName, f.DB, f.transformers, f.partitionIDRequired, slices.Concat([]StrategyOption{WithLogger(f.logger())}, f.strategyOptions, opts)...)
+		if err != nil {
+			return nil, err
+		}
 	}
+	f.strategiesLock.Lock()
+	f.strategies = append(f.strategies, s)
+	f.strategiesLock.Unlock()
 	return s, nil
 }
+
+// filterPreviousIndex replaces the unique index on the previous column of table on SQL Server with one ignoring NULLs.
+// SQL Server, unlike the other databases, allows only one NULL in a unique index, and every create, fill and
+// compaction record has one. The index is only replaced if it isn't filtered yet.
+func filterPreviousIndex(db *gorm.DB, table string) error {
+	if db.Dialector.Name() != "sqlserver" {
+		return nil
+	}
+	index, statements := previousIndexStatements(db, table)
+	var filtered []bool
+	err := db.Raw("SELECT has_filter FROM sys.indexes WHERE name = ? AND object_id = OBJECT_ID(?)", index, table).
+		Scan(&filtered).Error
+	if err != nil {
+		return fmt.Errorf("reading the index %s of %s: %w", index, table, err)
+	}
+	if len(filtered) == 0 {
+		// there is nothing to drop
+		statements = statements[1:]
+	} else if filtered[0] {
+		return nil
+	}
+	for _, statement := range statements {
+		if err := db.Exec(statement).Error; err != nil {
+			return fmt.Errorf("filtering the index %s of %s: %w", index, table, err)
+		}
+	}
+	return nil
+}
+
+// previousIndexStatements returns the name of the unique index on the previous column of table, and the SQL Server
+// statements dropping it and creating it again without NULLs.
+func previousIndexStatements(db *gorm.DB, table string) (string, []string) {
+	index := db.NamingStrategy.IndexName(table, "previous")
+	quote := func(s string) string {
+		buf := &strings.Builder{}
+		db.Dialector.QuoteTo(buf, s)
+		return buf.String()
+	}
+	return index, []string{
+		fmt.Sprintf("DROP INDEX %s ON %s", quote(index), quote(table)),
+		fmt.Sprintf("CREATE UNIQUE INDEX %s ON %s (%s) WHERE %s IS NOT NULL",
+			quote(index), quote(table), quote("previous"), quote("previous")),
+	}
+}
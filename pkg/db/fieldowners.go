@@ -0,0 +1,183 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/acorn-io/mink/pkg/db/errtypes"
+	"github.com/acorn-io/mink/pkg/types"
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/duration"
+	"k8s.io/apiserver/pkg/endpoints/request"
+)
+
+// FieldOwnersAnnotation holds the FieldOwners of an object as JSON when field ownership is tracked. It is maintained
+// by the strategy, values sent by clients are replaced.
+const FieldOwnersAnnotation = "mink.acorn.io/field-owners"
+
+// FieldOwner records the last change of a field.
+type FieldOwner struct {
+	// Manager is the name of the user that changed the field, empty if the change wasn't made by a request.
+	Manager string      `json:"manager,omitempty"`
+	Time    metav1.Time `json:"time"`
+	// After is the resource version the change was applied to, every later version contains it. It is zero for fields
+	// set when the object was created.
+	After uint `json:"after,omitempty"`
+}
+
+// FieldOwners maps field paths, such as spec.image, to their last change. The fields tracked are the top-level fields
+// of the object other than metadata and status, and the fields of those that are objects.
+type FieldOwners map[string]FieldOwner
+
+// WithFieldOwnership records which user last changed which field of an object in the FieldOwnersAnnotation, and
+// names the fields changed since the version a client sent in the conflict errors of its updates. It is a lightweight
+// alternative to server-side apply to help debug objects with multiple writers.
+func WithFieldOwnership() StrategyOption {
+	return func(s *Strategy) {
+		s.trackFieldOwners = true
+	}
+}
+
+// GetFieldOwners returns the field owners recorded on obj, or nil if there are none.
+func GetFieldOwners(obj metav1.Object) (FieldOwners, error) {
+	value, ok := obj.GetAnnotations()[FieldOwnersAnnotation]
+	if !ok {
+		return nil, nil
+	}
+	owners := FieldOwners{}
+	return owners, json.Unmarshal([]byte(value), &owners)
+}
+
+// trackedFields returns the values of the fields recorded in FieldOwners by their path.
+func trackedFields(data map[string]any) map[string]any {
+	fields := map[string]any{}
+	for key, value := range data {
+		switch key {
+		case "apiVersion", "kind", "metadata", "status":
+			continue
+		}
+		if nested, ok := value.(map[string]any); ok {
+			for nestedKey, nestedValue := range nested {
+				fields[key+"."+nestedKey] = nestedValue
+			}
+			continue
+		}
+		fields[key] = value
+	}
+	return fields
+}
+
+func recordFieldOwners(rec *Record) FieldOwners {
+	var metadata metav1.ObjectMeta
+	if err := json.Unmarshal(rec.Metadata, &metadata); err != nil {
+		return nil
+	}
+	// annotations that can't be read are dropped, the ownership starts over
+	owners, _ := GetFieldOwners(&metadata)
+	return owners
+}
+
+// setFieldOwners records the fields obj changes compared to existing, which is nil for new objects, in the
+// FieldOwnersAnnotation of obj.
+func (s *Strategy) setFieldOwners(ctx context.Context, obj types.Object, existing *Record) error {
+	if !s.trackFieldOwners {
+		return nil
+	}
+
+	owners := FieldOwners{}
+	oldFields := map[string]any{}
+	change := FieldOwner{
+		Time: metav1.NewTime(time.Now().UTC().Truncate(time.Second)),
+	}
+	if u, ok := request.UserFrom(ctx); ok {
+		change.Manager = u.GetName()
+	}
+	if existing != nil {
+		for path, owner := range recordFieldOwners(existing) {
+			owners[path] = owner
+		}
+		oldData := map[string]any{}
+		if len(existing.Data) > 0 {
+			if err := json.Unmarshal(existing.Data, &oldData); err != nil {
+				return err
+			}
+		}
+		oldFields = trackedFields(oldData)
+		change.After = existing.ID
+	}
+
+	newData, err := toMap(obj)
+	if err != nil {
+		return err
+	}
+	newFields := trackedFields(newData)
+	for path, value := range newFields {
+		if oldValue, ok := oldFields[path]; !ok || !equality.Semantic.DeepEqual(oldValue, value) {
+			owners[path] = change
+		}
+	}
+	for path := range oldFields {
+		if _, ok := newFields[path]; !ok {
+			owners[path] = change
+		}
+	}
+
+	data, err := json.Marshal(owners)
+	if err != nil {
+		return err
+	}
+	annotations := make(map[string]string, len(obj.GetAnnotations())+1)
+	for k, v := range obj.GetAnnotations() {
+		annotations[k] = v
+	}
+	annotations[FieldOwnersAnnotation] = string(data)
+	obj.SetAnnotations(annotations)
+	return nil
+}
+
+// newFieldOwnerConflict returns the conflict of an update of obj based on an older version than existing, naming the
+// fields that changed since that version.
+func newFieldOwnerConflict(gvk schema.GroupVersionKind, obj types.Object, existing *Record) error {
+	err := errtypes.NewResourceVersionMismatchError(gvk.GroupKind(), obj.GetName(), nil)
+	since, parseErr := strconv.ParseUint(obj.GetResourceVersion(), 10, 64)
+	if parseErr != nil {
+		return err
+	}
+
+	owners := recordFieldOwners(existing)
+	paths := make([]string, 0, len(owners))
+	for path, owner := range owners {
+		if uint64(owner.After) >= since {
+			paths = append(paths, path)
+		}
+	}
+	if len(paths) == 0 {
+		return err
+	}
+	sort.Strings(paths)
+
+	messages := make([]string, 0, len(paths))
+	for _, path := range paths {
+		owner := owners[path]
+		message := "last changed"
+		if owner.Manager != "" {
+			message += " by " + owner.Manager
+		}
+		message += " " + duration.HumanDuration(time.Since(owner.Time.Time)) + " ago"
+		messages = append(messages, path+" "+message)
+		err.ErrStatus.Details.Causes = append(err.ErrStatus.Details.Causes, metav1.StatusCause{
+			Type:    metav1.CauseTypeFieldManagerConflict,
+			Field:   path,
+			Message: message,
+		})
+	}
+	err.ErrStatus.Message = fmt.Sprintf("%s: %s", err.ErrStatus.Message, strings.Join(messages, ", "))
+	return err
+}
@@ -4,6 +4,7 @@ package glogrus
 import (
 	"context"
 	"errors"
+	"strings"
 	"sync"
 	"time"
 
@@ -11,6 +12,8 @@ import (
 	"gorm.io/gorm"
 	glogger "gorm.io/gorm/logger"
 	gutils "gorm.io/gorm/utils"
+	"k8s.io/apiserver/pkg/audit"
+	"k8s.io/apiserver/pkg/endpoints/request"
 )
 
 // Config is used to configure a gorm Logger that wraps a logrus.Logger.
@@ -83,7 +86,7 @@ func (l *Logger) Trace(ctx context.Context, begin time.Time, fc func() (string,
 		"elapsed":  elapsed,
 		"affected": affected,
 		"caller":   gutils.FileWithLineNum(),
-	})
+	}).WithFields(RequestFields(ctx))
 
 	if l.logSQL {
 		// Add the SQL query to all log levels if the logger is set to Trace.
@@ -103,6 +106,29 @@ func (l *Logger) Trace(ctx context.Context, begin time.Time, fc func() (string,
 	log.Trace("sql query executed")
 }
 
+// RequestFields returns the fields identifying the API request ctx belongs to, so that queries can be attributed to
+// the requests and users they are made for: "requestID", the audit ID the API server returns in the Audit-Id header,
+// "user", the name of the user making the request, and "gvr", the group, version and resource of the request, with
+// its subresource if any, such as "apps/v1/deployments/status". Fields the context doesn't have are omitted, there
+// are none for queries made outside of requests, such as those of garbage collection.
+func RequestFields(ctx context.Context) logrus.Fields {
+	fields := logrus.Fields{}
+	if auditID, ok := audit.AuditIDFrom(ctx); ok && auditID != "" {
+		fields["requestID"] = string(auditID)
+	}
+	if user, ok := request.UserFrom(ctx); ok && user != nil {
+		fields["user"] = user.GetName()
+	}
+	if info, ok := request.RequestInfoFrom(ctx); ok && info.IsResourceRequest {
+		gvr := []string{info.APIGroup, info.APIVersion, info.Resource, info.Subresource}
+		if gvr[0] == "" {
+			gvr = gvr[1:]
+		}
+		fields["gvr"] = strings.TrimSuffix(strings.Join(gvr, "/"), "/")
+	}
+	return fields
+}
+
 // complete ensures that the Logger is fully initialized.
 // It's idempotent and should be called at the beginning of every method exported by Logger.
 func (l *Logger) complete() {
@@ -0,0 +1,39 @@
+package glogrus
+
+import (
+	"context"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apiserver/pkg/audit"
+	"k8s.io/apiserver/pkg/authentication/user"
+	"k8s.io/apiserver/pkg/endpoints/request"
+)
+
+func TestRequestFields(t *testing.T) {
+	assert.Empty(t, RequestFields(context.Background()))
+
+	ctx := audit.WithAuditContext(context.Background())
+	audit.WithAuditID(ctx, "8c1d2f")
+	ctx = request.WithUser(ctx, &user.DefaultInfo{Name: "alice"})
+	ctx = request.WithRequestInfo(ctx, &request.RequestInfo{
+		IsResourceRequest: true,
+		APIGroup:          "apps",
+		APIVersion:        "v1",
+		Resource:          "deployments",
+		Subresource:       "status",
+	})
+	assert.Equal(t, logrus.Fields{
+		"requestID": "8c1d2f",
+		"user":      "alice",
+		"gvr":       "apps/v1/deployments/status",
+	}, RequestFields(ctx))
+
+	ctx = request.WithRequestInfo(ctx, &request.RequestInfo{
+		IsResourceRequest: true,
+		APIVersion:        "v1",
+		Resource:          "pods",
+	})
+	assert.Equal(t, "v1/pods", RequestFields(ctx)["gvr"])
+}
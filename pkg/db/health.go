@@ -0,0 +1,82 @@
+package db
+
+import (
+	"context"
+	"time"
+)
+
+// TableHealth is the state of the storage of one table as seen by this server.
+type TableHealth struct {
+	Table string
+	// Error is set if the table could not be queried.
+	Error string
+	// LatestID is the ID of the last record read by the watch loop, CompactedID the ID compacted up to.
+	LatestID    uint
+	CompactedID uint
+	GCEnabled   bool
+	// LastGC is when garbage collection last ran, LastGCError the error it failed with, if it did.
+	LastGC      time.Time
+	LastGCError string
+}
+
+func (h TableHealth) Healthy() bool {
+	return h.Error == "" && h.LastGCError == ""
+}
+
+func (g *GormDB) recordGC(err error) {
+	g.gcLock.Lock()
+	defer g.gcLock.Unlock()
+	g.lastGC = time.Now()
+	g.lastGCError = err
+}
+
+// Health queries the table and returns its state.
+func (g *GormDB) Health(ctx context.Context) TableHealth {
+	h := TableHealth{
+		Table:     g.tableName,
+		GCEnabled: g.db != nil && g.gcSettings().compactRetain > 0,
+	}
+
+	g.lastIDLock.Lock()
+	h.LatestID = g.lastID
+	g.lastIDLock.Unlock()
+
+	g.compactionLock.RLock()
+	h.CompactedID = g.compaction
+	g.compactionLock.RUnlock()
+
+	g.gcLock.Lock()
+	h.LastGC = g.lastGC
+	if g.lastGCError != nil {
+		h.LastGCError = g.lastGCError.Error()
+	}
+	g.gcLock.Unlock()
+
+	if g.db != nil {
+		if _, err := g.getMaxID(ctx); err != nil {
+			h.Error = err.Error()
+		}
+	}
+	return h
+}
+
+// Health returns the state of the table the strategy stores its objects in.
+func (s *Strategy) Health(ctx context.Context) TableHealth {
+	if g, ok := s.db.(*GormDB); ok {
+		return g.Health(ctx)
+	}
+	return TableHealth{}
+}
+
+// TableHealth returns the state of the tables of the strategies created by the factory.
+func (f *Factory) TableHealth(ctx context.Context) []TableHealth {
+	f.strategiesLock.Lock()
+	strategies := f.strategies
+	f.strategiesLock.Unlock()
+
+	result := make([]TableHealth, 0, len(strategies))
+	for _, s := range strategies {
+		result = append(result, s.Health(ctx))
+	}
+	return result
+}
@@ -0,0 +1,141 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const sequencesTableName = "mink_sequences"
+
+// IDAllocator assigns the IDs of the records of a table, which are the resource versions of their objects. The watch
+// loop reads records in ID order and takes a missing ID for a write that hasn't committed yet, after a while it
+// inserts a fill record in its place. By default the database assigns the IDs with AUTO_INCREMENT, which leaves gaps
+// for rolled back writes and, with multiple writers, can commit a higher ID before a lower one.
+type IDAllocator interface {
+	// Start prepares the allocator for the table, it is called once before any record is inserted.
+	Start(ctx context.Context, db *gorm.DB, tableName string) error
+	// Allocate returns the ID of a new record. It is called in the transaction inserting the record and must be
+	// rolled back with it.
+	Allocate(ctx context.Context, tx *gorm.DB, tableName string) (uint, error)
+	// Reserve makes sure id is never allocated, it is called in the transaction inserting a record with a given ID,
+	// such as a fill record.
+	Reserve(ctx context.Context, tx *gorm.DB, tableName string, id uint) error
+}
+
+// WithIDAllocator sets the allocator of the IDs of the records of the table. Every server writing the table must use
+// the same allocator.
+func WithIDAllocator(allocator IDAllocator) StrategyOption {
+	return func(s *Strategy) {
+		if g, ok := s.db.(*GormDB); ok {
+			g.ids = allocator
+		}
+	}
+}
+
+// Sequence is the last ID allocated for a table by a SequenceTable.
+type Sequence struct {
+	Name   string `gorm:"primaryKey;size:255"`
+	LastID uint
+}
+
+// SequenceTable allocates IDs from a row per table in a sequence table that is updated in the transaction inserting
+// the record. The row stays locked until the transaction ends, so records are committed in ID order and a rolled back
+// write gives its ID back, which leaves no gaps for the watch loop to fill. The price is that the writes to a table
+// are serialized.
+type SequenceTable struct{}
+
+func NewSequenceTable() *SequenceTable {
+	return &SequenceTable{}
+}
+
+func (s *SequenceTable) Start(ctx context.Context, db *gorm.DB, tableName string) error {
+	db = db.WithContext(ctx)
+	if err := db.Table(sequencesTableName).AutoMigrate(&Sequence{}); err != nil {
+		return err
+	}
+
+	var last Record
+	if err := db.Table(tableName).Select("max(id) AS id").Scan(&last).Error; err != nil {
+		return err
+	}
+	// another server may have added the row first, or records may have been inserted without the allocator since
+	err := db.Table(sequencesTableName).Clauses(clause.OnConflict{DoNothing: true}).Create(&Sequence{
+		Name:   tableName,
+		LastID: last.ID,
+	}).Error
+	if err != nil {
+		return err
+	}
+	return s.Reserve(ctx, db, tableName, last.ID)
+}
+
+func (s *SequenceTable) Allocate(ctx context.Context, tx *gorm.DB, tableName string) (uint, error) {
+	tx = tx.WithContext(ctx)
+	resp := tx.Table(sequencesTableName).
+		Where("name = ?", tableName).
+		UpdateColumn("last_id", gorm.Expr("last_id + 1"))
+	if resp.Error != nil {
+		return 0, resp.Error
+	}
+	if resp.RowsAffected == 0 {
+		return 0, fmt.Errorf("no sequence for table %s", tableName)
+	}
+
+	var sequence Sequence
+	err := tx.Table(sequencesTableName).Where("name = ?", tableName).Take(&sequence).Error
+	return sequence.LastID, err
+}
+
+func (s *SequenceTable) Reserve(ctx context.Context, tx *gorm.DB, tableName string, id uint) error {
+	return tx.WithContext(ctx).Table(sequencesTableName).
+		Where("name = ? AND last_id < ?", tableName, id).
+		UpdateColumn("last_id", id).Error
+}
+
+const globalSequenceName = "mink:global"
+
+// GlobalSequenceAnnotation is the position of the write of an object in the sequence shared by all tables of strategies
+// created WithGlobalSequence, as a decimal number. Unlike resource versions it can be compared across tables: of two
+// writes the one with the lower sequence committed first, so consumers merging the watches of several tables can order
+// their events and know that after seeing every event up to a sequence they hold a consistent snapshot of all of them.
+// Records written before the option was enabled have no sequence.
+const GlobalSequenceAnnotation = "mink.acorn.io/sequence"
+
+// WithGlobalSequence numbers every write of the table from a sequence shared with every other table using it, see
+// GlobalSequenceAnnotation. The sequence is a row of the sequence table locked until the writing transaction commits,
+// so it serializes the writes of all those tables.
+func WithGlobalSequence() StrategyOption {
+	return func(s *Strategy) {
+		if g, ok := s.db.(*GormDB); ok {
+			g.globalSequence = true
+		}
+	}
+}
+
+func startGlobalSequence(ctx context.Context, db *gorm.DB) error {
+	db = db.WithContext(ctx)
+	if err := db.Table(sequencesTableName).AutoMigrate(&Sequence{}); err != nil {
+		return err
+	}
+	return db.Table(sequencesTableName).Clauses(clause.OnConflict{DoNothing: true}).Create(&Sequence{
+		Name: globalSequenceName,
+	}).Error
+}
+
+// GlobalSequence returns the sequence of the last committed write of the tables created WithGlobalSequence, every
+// write with a lower sequence has committed too.
+func (f *Factory) GlobalSequence(ctx context.Context) (uint, error) {
+	if err := f.requireSQL("the global sequence"); err != nil {
+		return 0, err
+	}
+	var sequence Sequence
+	err := f.DB.WithContext(ctx).Table(sequencesTableName).Where("name = ?", globalSequenceName).Take(&sequence).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return 0, nil
+	}
+	return sequence.LastID, err
+}
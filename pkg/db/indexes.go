@@ -0,0 +1,81 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// WithFieldIndexes indexes the field selector keys of the table, such as spec.appName or status.phase, the way
+// field indexers index the caches of controller-runtime. Each field gets a generated column holding its value and an
+// index, which list and watch queries selecting by the field use instead of extracting it from the JSON of every
+// record. The columns are created when the strategy starts if they don't exist, they are the same columns the index
+// advisor creates, see WithIndexAdvisor.
+//
+// Metadata fields can't be indexed. On mysql, whose generated columns have a length limit, the columns hold the first
+// 255 characters of the values, queries for longer values compare the records the index finds by their whole value.
+// Fields are not indexed on databases without generated columns, queries on those extract the field.
+func WithFieldIndexes(fields ...string) StrategyOption {
+	return func(s *Strategy) {
+		if g, ok := s.db.(*GormDB); ok {
+			g.fieldIndexes = append(g.fieldIndexes, fields...)
+		}
+	}
+}
+
+// WithTableFieldIndexes indexes the field selector keys of the table named table, see WithFieldIndexes. Table names
+// are compared case-insensitively.
+func WithTableFieldIndexes(table string, fields ...string) FactoryOption {
+	return WithStrategyOptions(func(s *Strategy) {
+		if g, ok := s.db.(*GormDB); ok && strings.EqualFold(g.tableName, table) {
+			WithFieldIndexes(fields...)(s)
+		}
+	})
+}
+
+// createFieldIndexes creates the generated columns and indexes of the fields declared by WithFieldIndexes that
+// don't exist yet, including the indexes of columns whose index failed to be created.
+func (g *GormDB) createFieldIndexes(ctx context.Context) error {
+	for _, field := range g.fieldIndexes {
+		if _, _, ok := fieldSelectorPath(field); !ok {
+			return fmt.Errorf("field %s of %s can't be indexed, only fields outside of metadata can", field, g.tableName)
+		}
+	}
+
+	if err := g.refreshColumns(ctx); err != nil {
+		return fmt.Errorf("reading the columns of %s: %w", g.tableName, err)
+	}
+
+	migrator := g.db.WithContext(ctx).Migrator()
+	for _, field := range g.fieldIndexes {
+		column := selectorColumnName(SelectorTypeField, field)
+		statements := g.indexStatements(SelectorTypeField, field, column)
+		if len(statements) == 0 {
+			g.log.Warnf("Indexing field [%s] of [%s] is not supported on %s, queries selecting by it extract it from every record",
+				field, g.tableName, g.db.Dialector.Name())
+			continue
+		}
+
+		// another server starting at the same time may create the column or the index first
+		created := false
+		if !g.hasSelectorColumn(column) {
+			if err := g.db.WithContext(ctx).Exec(statements[0]).Error; err != nil {
+				if refreshErr := g.refreshColumns(ctx); refreshErr != nil || !g.hasSelectorColumn(column) {
+					return fmt.Errorf("indexing field %s of %s: %w", field, g.tableName, err)
+				}
+			}
+			created = true
+		}
+		if index := g.selectorIndexName(column); !migrator.HasIndex(g.tableName, index) {
+			if err := g.db.WithContext(ctx).Exec(statements[1]).Error; err != nil && !migrator.HasIndex(g.tableName, index) {
+				return fmt.Errorf("indexing field %s of %s: %w", field, g.tableName, err)
+			}
+			created = true
+		}
+		g.addSelectorColumn(column)
+		if created {
+			g.log.Infof("Indexed field [%s] of [%s] in column [%s]", field, g.tableName, column)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,168 @@
+//go:build integration
+
+package db
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/acorn-io/mink/pkg/strategy"
+	"github.com/acorn-io/mink/pkg/strategy/strategytest"
+	"github.com/acorn-io/mink/pkg/strategy/stresstest"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/apiserver/pkg/storage"
+	"k8s.io/client-go/kubernetes/scheme"
+)
+
+// The integration tests run the conformance and stress suites through NewFactory against the database in
+// MINK_TEST_DSN. scripts/integration.sh runs them against every supported database in docker.
+func newIntegrationStore(t *testing.T) strategy.CompleteStrategy {
+	dsn := os.Getenv("MINK_TEST_DSN")
+	if dsn == "" {
+		t.Skip("MINK_TEST_DSN is not set")
+	}
+
+	f, err := NewFactory(scheme.Scheme, dsn, WithMigrationTimeout(time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = f.SQLDB.Close()
+	})
+
+	// every test starts with an empty table
+	if err := f.DB.Migrator().DropTable("pod"); err != nil {
+		t.Fatal(err)
+	}
+	s, err := f.NewDBStrategy(&corev1.Pod{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(s.Destroy)
+	return s
+}
+
+func TestIntegrationConformance(t *testing.T) {
+	strategytest.Run(t, newIntegrationStore)
+}
+
+func TestIntegrationStress(t *testing.T) {
+	stresstest.Run(t, newIntegrationStore(t), stresstest.Options{})
+}
+
+// TestIntegrationReplicas runs two servers, each with its own factory and GormDB, against the database in
+// MINK_TEST_DSN, as scripts/integration.sh does for Postgres and the other databases.
+func TestIntegrationReplicas(t *testing.T) {
+	dsn := os.Getenv("MINK_TEST_DSN")
+	if dsn == "" {
+		t.Skip("MINK_TEST_DSN is not set")
+	}
+
+	newReplica := func() *Strategy {
+		f, err := NewFactory(scheme.Scheme, dsn, WithMigrationTimeout(time.Minute), WithReplicas())
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() {
+			_ = f.SQLDB.Close()
+		})
+		s, err := f.NewDBStrategy(&corev1.Pod{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(s.Destroy)
+		return s.(*Strategy)
+	}
+
+	first := newReplica()
+	if err := first.db.(*GormDB).db.Migrator().DropTable("pod", gcLeasesTableName); err != nil {
+		t.Fatal(err)
+	}
+	first.Destroy()
+	first, second := newReplica(), newReplica()
+	g1, g2 := first.db.(*GormDB), second.db.(*GormDB)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	// writes through either server are watched in order by both
+	events, err := second.Watch(ctx, "", storage.ListOptions{Predicate: storage.Everything})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			s := first
+			if i%2 == 1 {
+				s = second
+			}
+			_, err := s.Create(ctx, &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      fmt.Sprintf("pod%d", i),
+					Namespace: "default",
+				},
+			})
+			assert.NoError(t, err)
+		}(i)
+	}
+	wg.Wait()
+
+	var lastID uint64
+	for i := 0; i < 20; i++ {
+		select {
+		case event := <-events:
+			assert.Equal(t, watch.Added, event.Type)
+			id, err := strconv.ParseUint(event.Object.(*corev1.Pod).ResourceVersion, 10, 64)
+			assert.NoError(t, err)
+			assert.Greater(t, id, lastID)
+			lastID = id
+		case <-ctx.Done():
+			t.Fatal("timed out waiting for events")
+		}
+	}
+
+	// only one server runs garbage collection at a time
+	epoch, err := g1.acquireGCLease(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = g2.acquireGCLease(ctx)
+	assert.ErrorIs(t, err, errNotLeader)
+
+	compactTo := uint(lastID) - 5
+	cont, err := g1.markCompaction(ctx, compactTo, epoch)
+	assert.NoError(t, err)
+	assert.True(t, cont)
+
+	// the other server honors the compaction point before its watch loop read the compaction record
+	_, err = second.Watch(ctx, "", storage.ListOptions{
+		ResourceVersion: strconv.FormatUint(uint64(compactTo-1), 10),
+		Predicate:       storage.Everything,
+	})
+	assert.True(t, apierrors.IsResourceExpired(err), "expected expired resource version, got %v", err)
+
+	// once the lease expires the other server takes over and the previous owner is fenced off
+	err = g1.db.WithContext(ctx).Table(gcLeasesTableName).Where("name = ?", g1.tableName).
+		Update("lease_until", time.Now().Add(-time.Minute)).Error
+	if err != nil {
+		t.Fatal(err)
+	}
+	newEpoch, err := g2.acquireGCLease(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Greater(t, newEpoch, epoch)
+	_, err = g1.markCompaction(ctx, compactTo+1, epoch)
+	assert.ErrorIs(t, err, errLeaseLost)
+}
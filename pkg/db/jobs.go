@@ -0,0 +1,480 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/acorn-io/mink/pkg/datatypes"
+	"github.com/acorn-io/mink/pkg/logging"
+	"gorm.io/gorm"
+	"k8s.io/apimachinery/pkg/util/uuid"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+const (
+	jobsTableName             = "mink_jobs"
+	defaultJobWorkers         = 1
+	defaultJobPollInterval    = 5 * time.Second
+	defaultJobLease           = 5 * time.Minute
+	defaultJobMaxAttempts     = 5
+	defaultJobRetryBackoff    = 10 * time.Second
+	defaultJobMaxRetryBackoff = 30 * time.Minute
+)
+
+// Job is a unit of asynchronous work in a Queue.
+type Job struct {
+	ID    uint
+	Queue string `gorm:"index:,composite:idx_jobs_claim"`
+	// CoalesceKey is set for jobs added with Coalesce, see there.
+	CoalesceKey string `gorm:"index"`
+	Payload     datatypes.JSON
+	Attempts    int
+	MaxAttempts int
+	// RunAt is the earliest time the job is run, it is pushed back when the job is retried.
+	RunAt time.Time `gorm:"index:,composite:idx_jobs_claim"`
+	// ClaimedBy and ClaimedUntil are set while a worker runs the job. A job whose claim has expired, because its
+	// worker went away, is claimed again.
+	ClaimedBy    string
+	ClaimedUntil *time.Time
+	LastError    string
+	// Dead is set once a job failed MaxAttempts times, dead jobs are kept until they are retried or deleted.
+	Dead    bool `gorm:"index:,composite:idx_jobs_claim;not null;default:0"`
+	Created time.Time
+	Updated time.Time
+}
+
+// Unmarshal decodes the payload of the job into v.
+func (j *Job) Unmarshal(v any) error {
+	return json.Unmarshal(j.Payload, v)
+}
+
+// JobHandler runs a job. A job that returns an error is retried with exponential backoff until it has been
+// attempted MaxAttempts times, after which it is dead-lettered. Handlers must be idempotent, a job may run more than
+// once if its worker goes away before the job is marked done.
+type JobHandler func(ctx context.Context, job *Job) error
+
+// Queue is a queue of jobs stored in the database. Jobs are enqueued in the transaction of the context, so work can be
+// scheduled atomically with the object changes that trigger it, see Factory.Transaction. Every server running the
+// queue claims jobs from it, each job is run by one worker at a time.
+type Queue struct {
+	db   *gorm.DB
+	name string
+	id   string
+
+	workers         int
+	pollInterval    time.Duration
+	lease           time.Duration
+	maxAttempts     int
+	retryBackoff    time.Duration
+	maxRetryBackoff time.Duration
+
+	trigger chan struct{}
+	log     logging.Logger
+}
+
+type QueueOption func(*Queue)
+
+// WithJobWorkers sets the number of jobs run concurrently by this server. The default is 1.
+func WithJobWorkers(workers int) QueueOption {
+	return func(q *Queue) {
+		q.workers = workers
+	}
+}
+
+// WithJobPollInterval sets how often the queue is checked for jobs. Jobs enqueued by this server are picked up
+// immediately. The default is 5 seconds.
+func WithJobPollInterval(interval time.Duration) QueueOption {
+	return func(q *Queue) {
+		q.pollInterval = interval
+	}
+}
+
+// WithJobLease sets how long a worker may run a job before the job is considered abandoned and is claimed again.
+// The default is 5 minutes, the context passed to the JobHandler is canceled when it expires.
+func WithJobLease(lease time.Duration) QueueOption {
+	return func(q *Queue) {
+		q.lease = lease
+	}
+}
+
+// WithJobMaxAttempts sets the number of attempts of jobs that are enqueued without WithMaxAttempts. The default is 5.
+func WithJobMaxAttempts(attempts int) QueueOption {
+	return func(q *Queue) {
+		q.maxAttempts = attempts
+	}
+}
+
+// WithJobRetryBackoff sets the delay before the first retry of a failed job, it doubles with every attempt up to
+// max. The defaults are 10 seconds and 30 minutes.
+func WithJobRetryBackoff(initial, max time.Duration) QueueOption {
+	return func(q *Queue) {
+		q.retryBackoff = initial
+		q.maxRetryBackoff = max
+	}
+}
+
+// NewQueue returns the job queue with the given name. The jobs of every queue are stored in one table, which is
+// created if the factory auto migrates.
+func (f *Factory) NewQueue(name string, opts ...QueueOption) (*Queue, error) {
+	if err := f.requireSQL("job queues"); err != nil {
+		return nil, err
+	}
+	if f.AutoMigrate {
+		ctx := context.Background()
+		if f.migrationTimeout != 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, f.migrationTimeout)
+			defer cancel()
+		}
+		db := f.DB.WithContext(ctx)
+		if f.tableOptions != "" {
+			db = db.Set("gorm:table_options", f.tableOptions)
+		}
+		if err := db.Table(jobsTableName).AutoMigrate(&Job{}); err != nil {
+			return nil, err
+		}
+	}
+	return newQueue(f.DB, name, append([]QueueOption{withQueueLogger(f.logger())}, opts...)...), nil
+}
+
+func withQueueLogger(logger logging.Logger) QueueOption {
+	return func(q *Queue) {
+		q.log = logger
+	}
+}
+
+func newQueue(db *gorm.DB, name string, opts ...QueueOption) *Queue {
+	q := &Queue{
+		db:              db,
+		name:            name,
+		id:              string(uuid.NewUUID()),
+		workers:         defaultJobWorkers,
+		pollInterval:    defaultJobPollInterval,
+		lease:           defaultJobLease,
+		maxAttempts:     defaultJobMaxAttempts,
+		retryBackoff:    defaultJobRetryBackoff,
+		maxRetryBackoff: defaultJobMaxRetryBackoff,
+		trigger:         make(chan struct{}, 1),
+		log:             logging.Default(),
+	}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(q)
+		}
+	}
+	return q
+}
+
+// Transaction runs do in a database transaction. Objects created, updated or deleted through the strategies of the
+// factory and jobs enqueued with the context passed to do are committed together, or not at all if do returns an
+// error.
+func (f *Factory) Transaction(ctx context.Context, do func(ctx context.Context) error) error {
+	if err := f.requireSQL("transactions across tables"); err != nil {
+		return err
+	}
+	if db, ok := ctx.Value(dbKey{}).(*gorm.DB); ok {
+		return db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			return do(context.WithValue(ctx, dbKey{}, tx))
+		})
+	}
+
+	hooks := &afterCommitHooks{}
+	err := f.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return do(context.WithValue(context.WithValue(ctx, dbKey{}, tx), afterCommitKey{}, hooks))
+	})
+	if err == nil {
+		hooks.run()
+	}
+	return err
+}
+
+func (q *Queue) getDB(ctx context.Context) *gorm.DB {
+	if db, ok := ctx.Value(dbKey{}).(*gorm.DB); ok {
+		return db.Table(jobsTableName)
+	}
+	return q.db.WithContext(ctx).Table(jobsTableName)
+}
+
+type EnqueueOption func(*Job)
+
+// WithRunAt delays the job until the given time.
+func WithRunAt(t time.Time) EnqueueOption {
+	return func(j *Job) {
+		j.RunAt = t
+	}
+}
+
+// WithMaxAttempts sets the number of times the job is attempted before it is dead-lettered.
+func WithMaxAttempts(attempts int) EnqueueOption {
+	return func(j *Job) {
+		j.MaxAttempts = attempts
+	}
+}
+
+// Enqueue adds a job with payload, encoded as JSON, to the queue. If ctx carries a transaction the job is only
+// visible to workers once it commits.
+func (q *Queue) Enqueue(ctx context.Context, payload any, opts ...EnqueueOption) (*Job, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now().UTC()
+	job := &Job{
+		Queue:       q.name,
+		Payload:     data,
+		MaxAttempts: q.maxAttempts,
+		RunAt:       now,
+		Created:     now,
+		Updated:     now,
+	}
+	for _, opt := range opts {
+		opt(job)
+	}
+	if err := q.getDB(ctx).Create(job).Error; err != nil {
+		return nil, err
+	}
+
+	select {
+	case q.trigger <- struct{}{}:
+	default:
+	}
+	return job, nil
+}
+
+// Coalesce adds a job with payload under key like Enqueue, unless the queue has a pending job with the same key that
+// isn't claimed by a worker. Then merge is called with that job and its payload is replaced by the one merge returns
+// instead, or the job is deleted if merge returns nil. The returned job is nil in that case. A job that is claimed
+// while merge runs is left alone and payload is added as a job of its own.
+func (q *Queue) Coalesce(ctx context.Context, key string, payload any, merge func(pending *Job) (any, error), opts ...EnqueueOption) (*Job, error) {
+	now := time.Now().UTC()
+	var pending []Job
+	err := q.getDB(ctx).
+		Where("queue = ? AND coalesce_key = ? AND dead = ? AND attempts < max_attempts", q.name, key, false).
+		Where("claimed_until IS NULL OR claimed_until < ?", now).
+		Order("id DESC").
+		Limit(1).
+		Find(&pending).Error
+	if err != nil {
+		return nil, err
+	}
+	if len(pending) == 0 {
+		return q.Enqueue(ctx, payload, append(opts, withCoalesceKey(key))...)
+	}
+
+	job := pending[0]
+	merged, err := merge(&job)
+	if err != nil {
+		return nil, err
+	}
+	db := q.getDB(ctx).Where("id = ? AND (claimed_until IS NULL OR claimed_until < ?)", job.ID, now)
+	if merged == nil {
+		db = db.Delete(&Job{})
+	} else {
+		data, err := json.Marshal(merged)
+		if err != nil {
+			return nil, err
+		}
+		job.Payload = data
+		job.Updated = now
+		db = db.Updates(map[string]any{
+			"payload": job.Payload,
+			"updated": now,
+		})
+	}
+	if db.Error != nil {
+		return nil, db.Error
+	}
+	if db.RowsAffected == 0 {
+		// claimed in the meantime
+		return q.Enqueue(ctx, payload, append(opts, withCoalesceKey(key))...)
+	}
+	if merged == nil {
+		return nil, nil
+	}
+	return &job, nil
+}
+
+func withCoalesceKey(key string) EnqueueOption {
+	return func(j *Job) {
+		j.CoalesceKey = key
+	}
+}
+
+// DeadLetters returns the jobs of the queue that failed too often.
+func (q *Queue) DeadLetters(ctx context.Context) (result []Job, err error) {
+	err = q.getDB(ctx).Where("queue = ? AND dead = ?", q.name, true).Order("id ASC").Find(&result).Error
+	return
+}
+
+// Retry requeues a dead job with a fresh set of attempts.
+func (q *Queue) Retry(ctx context.Context, id uint) error {
+	db := q.getDB(ctx).Where("id = ? AND queue = ? AND dead = ?", id, q.name, true).Updates(map[string]any{
+		"dead":          false,
+		"attempts":      0,
+		"run_at":        time.Now().UTC(),
+		"claimed_by":    "",
+		"claimed_until": nil,
+		"updated":       time.Now().UTC(),
+	})
+	if db.Error != nil {
+		return db.Error
+	}
+	if db.RowsAffected == 0 {
+		return fmt.Errorf("job %d is not a dead job of queue %s", id, q.name)
+	}
+	return nil
+}
+
+// Run runs the jobs of the queue with handler until ctx is done.
+func (q *Queue) Run(ctx context.Context, handler JobHandler) {
+	work := make(chan *Job)
+	for i := 0; i < q.workers; i++ {
+		go func() {
+			for job := range work {
+				q.run(ctx, job, handler)
+			}
+		}()
+	}
+	defer close(work)
+
+	for {
+		q.buryAbandoned(ctx)
+		for {
+			job, err := q.claim(ctx)
+			if err != nil {
+				q.log.Errorf("Failed to claim job of queue [%s]: %v", q.name, err)
+				break
+			}
+			if job == nil {
+				break
+			}
+			select {
+			case work <- job:
+			case <-ctx.Done():
+				return
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(wait.Jitter(q.pollInterval, 0.2)):
+		case <-q.trigger:
+		}
+	}
+}
+
+// claim claims the next job that is due. Candidates are claimed with a conditional update, so that of the servers
+// racing for a job only one gets it without relying on row locking, which not every database supports the same way.
+func (q *Queue) claim(ctx context.Context) (*Job, error) {
+	for {
+		now := time.Now().UTC()
+		var candidates []Job
+		err := q.getDB(ctx).
+			Where("queue = ? AND dead = ? AND run_at <= ? AND attempts < max_attempts", q.name, false, now).
+			Where("claimed_until IS NULL OR claimed_until < ?", now).
+			Order("run_at ASC").Order("id ASC").
+			Limit(q.workers).
+			Find(&candidates).Error
+		if err != nil || len(candidates) == 0 {
+			return nil, err
+		}
+
+		until := now.Add(q.lease)
+		for _, job := range candidates {
+			db := q.getDB(ctx).
+				Where("id = ? AND (claimed_until IS NULL OR claimed_until < ?)", job.ID, now).
+				Updates(map[string]any{
+					"claimed_by":    q.id,
+					"claimed_until": until,
+					"attempts":      gorm.Expr("attempts + 1"),
+					"updated":       now,
+				})
+			if db.Error != nil {
+				return nil, db.Error
+			}
+			if db.RowsAffected == 1 {
+				job.ClaimedBy = q.id
+				job.ClaimedUntil = &until
+				job.Attempts++
+				return &job, nil
+			}
+		}
+		// another server claimed every candidate, look again
+	}
+}
+
+// buryAbandoned dead-letters jobs whose last attempt was abandoned by its worker.
+func (q *Queue) buryAbandoned(ctx context.Context) {
+	now := time.Now().UTC()
+	err := q.getDB(ctx).
+		Where("queue = ? AND dead = ? AND attempts >= max_attempts AND claimed_until < ?", q.name, false, now).
+		Updates(map[string]any{
+			"dead":       true,
+			"last_error": "abandoned by worker",
+			"updated":    now,
+		}).Error
+	if err != nil {
+		q.log.Errorf("Failed to dead-letter abandoned jobs of queue [%s]: %v", q.name, err)
+	}
+}
+
+func (q *Queue) run(ctx context.Context, job *Job, handler JobHandler) {
+	jobCtx, cancel := context.WithDeadline(ctx, *job.ClaimedUntil)
+	err := runJob(jobCtx, job, handler)
+	cancel()
+
+	if ctx.Err() != nil {
+		// shutting down, the claim expires and another worker picks the job up
+		return
+	}
+
+	now := time.Now().UTC()
+	db := q.getDB(ctx).Where("id = ? AND claimed_by = ?", job.ID, q.id)
+	if err == nil {
+		err = db.Delete(&Job{}).Error
+		if err != nil {
+			q.log.Errorf("Failed to delete job %d of queue [%s]: %v", job.ID, q.name, err)
+		}
+		return
+	}
+
+	updates := map[string]any{
+		"claimed_by":    "",
+		"claimed_until": nil,
+		"last_error":    err.Error(),
+		"updated":       now,
+	}
+	if job.Attempts >= job.MaxAttempts {
+		q.log.Warnf("Job %d of queue [%s] failed %d times, dead-lettering it: %v", job.ID, q.name, job.Attempts, err)
+		updates["dead"] = true
+	} else {
+		q.log.Debugf("Job %d of queue [%s] failed, retrying: %v", job.ID, q.name, err)
+		updates["run_at"] = now.Add(q.backoff(job.Attempts))
+	}
+	if err := db.Updates(updates).Error; err != nil {
+		q.log.Errorf("Failed to update job %d of queue [%s]: %v", job.ID, q.name, err)
+	}
+}
+
+func (q *Queue) backoff(attempts int) time.Duration {
+	backoff := q.retryBackoff
+	for i := 1; i < attempts && backoff < q.maxRetryBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > q.maxRetryBackoff {
+		backoff = q.maxRetryBackoff
+	}
+	return backoff
+}
+
+func runJob(ctx context.Context, job *Job, handler JobHandler) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("job panicked: %v", r)
+		}
+	}()
+	return handler(ctx, job)
+}
@@ -0,0 +1,139 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"slices"
+	"time"
+
+	"github.com/acorn-io/mink/pkg/db/glogrus"
+	"github.com/acorn-io/mink/pkg/logging"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	glogger "gorm.io/gorm/logger"
+)
+
+const slowQueryThreshold = 200 * time.Millisecond
+
+// WithLogger sets the logger of the table, such as of its garbage collection and watches. The default is the logger
+// of the factory, see WithFactoryLogger.
+func WithLogger(logger logging.Logger) StrategyOption {
+	return func(s *Strategy) {
+		if g, ok := s.db.(*GormDB); ok && logger != nil {
+			g.log = logger
+		}
+	}
+}
+
+// setGormLogger logs the queries of db with the logger of the factory if WithFactoryLogger set one, rather than with
+// the standard logrus logger.
+func (f *Factory) setGormLogger(db *gorm.DB) {
+	if f.log != nil {
+		db.Logger = &gormLogger{log: f.log}
+	}
+}
+
+// logger returns the logger set by WithFactoryLogger, or the default logger.
+func (f *Factory) logger() logging.Logger {
+	if f.log == nil {
+		return logging.Default()
+	}
+	return f.log
+}
+
+// gormLogger is the gorm logger of factories with a logger, it logs what glogrus logs with the settings of openDB.
+type gormLogger struct {
+	log logging.Logger
+}
+
+func (l *gormLogger) LogMode(glogger.LogLevel) glogger.Interface {
+	return l
+}
+
+func (l *gormLogger) Info(_ context.Context, s string, args ...any) {
+	l.log.Infof(s, args...)
+}
+
+func (l *gormLogger) Warn(_ context.Context, s string, args ...any) {
+	l.log.Warnf(s, args...)
+}
+
+func (l *gormLogger) Error(_ context.Context, s string, args ...any) {
+	l.log.Errorf(s, args...)
+}
+
+func (l *gormLogger) Trace(ctx context.Context, begin time.Time, fc func() (string, int64), err error) {
+	elapsed := time.Since(begin)
+	switch {
+	case err != nil && !errors.Is(err, gorm.ErrRecordNotFound):
+		sql, affected := fc()
+		withRequest(ctx, l.log).Errorf("sql query error after %s, %d rows affected: %v: %s", elapsed, affected, err, sql)
+	case elapsed > slowQueryThreshold:
+		sql, affected := fc()
+		withRequest(ctx, l.log).Infof("sql query slow, took %s, %d rows affected: %s", elapsed, affected, sql)
+	}
+}
+
+// withRequest adds the fields of the API request ctx belongs to, see glogrus.RequestFields.
+func withRequest(ctx context.Context, log logging.Logger) logging.Logger {
+	fields := glogrus.RequestFields(ctx)
+	if len(fields) == 0 {
+		return log
+	}
+	keysAndValues := make([]any, 0, 2*len(fields))
+	for _, key := range slices.Sorted(maps.Keys(fields)) {
+		keysAndValues = append(keysAndValues, key, fields[key])
+	}
+	return log.WithValues(keysAndValues...)
+}
+
+// tableLogger logs the queries of a table with the logger of the factory, unless the runtime settings of the table
+// override the SQL log level or slow query threshold, see RuntimeSettings.SQLLogLevel.
+type tableLogger struct {
+	glogger.Interface
+	g *GormDB
+}
+
+// withTableLogger returns a session of db logging its queries with a tableLogger of the table.
+func (g *GormDB) withTableLogger(db *gorm.DB) *gorm.DB {
+	if db == nil {
+		return nil
+	}
+	return db.Session(&gorm.Session{
+		Logger: &tableLogger{
+			Interface: db.Logger,
+			g:         g,
+		},
+	})
+}
+
+func (l *tableLogger) Trace(ctx context.Context, begin time.Time, fc func() (string, int64), err error) {
+	level, slowThreshold, ok := l.g.sqlLogSettings()
+	if !ok {
+		l.Interface.Trace(ctx, begin, fc, err)
+		return
+	}
+
+	elapsed := time.Since(begin)
+	log := func() logging.Logger {
+		sql, affected := fc()
+		return withRequest(ctx, l.g.log).WithValues("table", l.g.tableName, "elapsed", elapsed,
+			"affected", affected, "sql", sql)
+	}
+	// the level of the table replaces the level of the logger, so messages it allows are logged at info or error
+	switch {
+	case err != nil && !errors.Is(err, gorm.ErrRecordNotFound):
+		if level >= logrus.ErrorLevel {
+			log().Errorf("sql query error: %v", err)
+		}
+	case elapsed > slowThreshold:
+		if level >= logrus.WarnLevel {
+			log().Infof("sql query slow")
+		}
+	default:
+		if level >= logrus.DebugLevel {
+			log().Infof("sql query executed")
+		}
+	}
+}
@@ -0,0 +1,35 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/acorn-io/mink/pkg/metrics"
+	"gorm.io/gorm"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+// contextError records operations that failed because ctx is done. A passed request deadline is returned as a
+// timeout, so the client gets a 504 rather than an internal error, other errors are returned unchanged.
+func (g *GormDB) contextError(ctx context.Context, operation string, err error) error {
+	if err == nil || ctx.Err() == nil || apierrors.IsTimeout(err) {
+		return err
+	}
+	if _, ok := ctx.Value(dbKey{}).(*gorm.DB); ok {
+		// the enclosing transaction records it
+		return err
+	}
+
+	reason := "canceled"
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		reason = "deadline"
+	}
+	metrics.AbortedOperations.WithLabelValues(g.tableName, operation, reason).Inc()
+	g.log.Debugf("Storage %s on [%s] aborted: %v", operation, g.tableName, err)
+
+	if reason == "deadline" {
+		return apierrors.NewTimeoutError(fmt.Sprintf("%s on %s did not complete before the request deadline", operation, g.tableName), 0)
+	}
+	return err
+}
@@ -0,0 +1,159 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/acorn-io/mink/pkg/db/errtypes"
+	"gorm.io/gorm"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+const migrationsTableName = "mink_migrations"
+
+// Migration is a named step migrating the data of the database, such as backfilling a column added to the records of
+// a table. AutoMigrate only adds tables, columns and indexes, migrations do everything else.
+type Migration struct {
+	// Name identifies the migration in the migration history, it must not change once the migration has run.
+	Name string
+	// Migrate runs the migration in the transaction tx, which records it as run when it commits. The tables of
+	// strategies may not exist yet, migrations run before any strategy is created.
+	Migrate func(ctx context.Context, tx *gorm.DB) error
+}
+
+// MigrationRecord is a migration that has run, as stored in the migration history.
+type MigrationRecord struct {
+	Name    string `gorm:"primaryKey;size:255"`
+	Applied time.Time
+	// Duration is how long the migration took, in milliseconds.
+	Duration int64
+}
+
+// WithMigrations registers migrations that NewFactory runs in order, skipping those that have run before. Each
+// migration runs in its own transaction and is recorded in the mink_migrations table when it succeeds, NewFactory
+// fails with the error of the first migration that fails. Servers starting at the same time run every migration
+// once, the others wait for it to finish.
+func WithMigrations(migrations ...Migration) FactoryOption {
+	return func(f *Factory) {
+		f.migrations = append(f.migrations, migrations...)
+	}
+}
+
+// WithMigrationDryRun makes NewFactory run the pending migrations in transactions that are rolled back, to check that
+// they succeed without changing the database. They are not recorded and run again on the next start. Schema changes
+// are not rolled back on databases that commit them right away, such as mysql.
+func WithMigrationDryRun() FactoryOption {
+	return func(f *Factory) {
+		f.migrationDryRun = true
+	}
+}
+
+var (
+	errMigrationDryRun = errors.New("dry run")
+	errMigrationRan    = errors.New("migration ran")
+)
+
+// Migrate runs the migrations registered with WithMigrations that haven't run yet, in order, and returns the names of
+// those it ran. With dryRun they are rolled back, see WithMigrationDryRun.
+func (f *Factory) Migrate(ctx context.Context, dryRun bool) ([]string, error) {
+	if len(f.migrations) == 0 {
+		return nil, nil
+	}
+	if err := f.requireSQL("migrations"); err != nil {
+		return nil, err
+	}
+
+	names := sets.New[string]()
+	for _, migration := range f.migrations {
+		if migration.Name == "" || migration.Migrate == nil {
+			return nil, fmt.Errorf("migration %q has no name or no Migrate function", migration.Name)
+		}
+		if names.Has(migration.Name) {
+			return nil, fmt.Errorf("migration %s is registered twice", migration.Name)
+		}
+		names.Insert(migration.Name)
+	}
+
+	if f.AutoMigrate {
+		db := f.DB.WithContext(ctx)
+		if f.tableOptions != "" {
+			db = db.Set("gorm:table_options", f.tableOptions)
+		}
+		if err := db.Table(migrationsTableName).AutoMigrate(&MigrationRecord{}); err != nil {
+			return nil, err
+		}
+	}
+
+	history, err := f.MigrationHistory(ctx)
+	if err != nil {
+		return nil, err
+	}
+	applied := sets.New[string]()
+	for _, record := range history {
+		applied.Insert(record.Name)
+	}
+
+	var ran []string
+	for _, migration := range f.migrations {
+		if applied.Has(migration.Name) {
+			continue
+		}
+		ok, err := f.runMigration(ctx, migration, dryRun)
+		if err != nil {
+			return ran, fmt.Errorf("migration %s failed: %w", migration.Name, err)
+		}
+		if ok {
+			ran = append(ran, migration.Name)
+		}
+	}
+	return ran, nil
+}
+
+// runMigration runs migration unless another server ran it first, it returns true if it ran.
+func (f *Factory) runMigration(ctx context.Context, migration Migration, dryRun bool) (bool, error) {
+	start := time.Now()
+	err := f.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// recording the migration first makes a server running it at the same time wait for this transaction
+		record := &MigrationRecord{
+			Name:    migration.Name,
+			Applied: start,
+		}
+		if err := tx.Table(migrationsTableName).Create(record).Error; errtypes.IsUniqueConstraintErr(err) {
+			return errMigrationRan
+		} else if err != nil {
+			return err
+		}
+		if err := migration.Migrate(ctx, tx); err != nil {
+			return err
+		}
+		if dryRun {
+			return errMigrationDryRun
+		}
+		return tx.Table(migrationsTableName).Where("name = ?", record.Name).
+			Update("duration", time.Since(start).Milliseconds()).Error
+	})
+	switch {
+	case errors.Is(err, errMigrationDryRun):
+		f.logger().Infof("Dry run of migration [%s] succeeded in %s, it was rolled back", migration.Name, time.Since(start))
+		return true, nil
+	case errors.Is(err, errMigrationRan):
+		f.logger().Infof("Migration [%s] was run by another server", migration.Name)
+		return false, nil
+	case err != nil:
+		return false, err
+	}
+	f.logger().Infof("Ran migration [%s] in %s", migration.Name, time.Since(start))
+	return true, nil
+}
+
+// MigrationHistory returns the migrations that have run, in the order they ran.
+func (f *Factory) MigrationHistory(ctx context.Context) ([]MigrationRecord, error) {
+	if err := f.requireSQL("migrations"); err != nil {
+		return nil, err
+	}
+	var history []MigrationRecord
+	err := f.DB.WithContext(ctx).Table(migrationsTableName).Order("applied, name").Find(&history).Error
+	return history, err
+}
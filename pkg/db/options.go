@@ -0,0 +1,329 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The environment variables read by OptionsFromEnv, without their prefix. The garbage collection and bookmark
+// settings can be set for a single table by appending _<TABLE NAME> to the variable, for example
+// MINK_COMPACT_RETAIN_APPS, which takes precedence over the unsuffixed variable.
+const (
+	// EnvMaxOpenConns is the maximum number of open connections to the database, see WithMaxOpenConns.
+	EnvMaxOpenConns = "MAX_OPEN_CONNS"
+	// EnvMaxIdleConns is the maximum number of idle connections to the database, see WithMaxIdleConns.
+	EnvMaxIdleConns = "MAX_IDLE_CONNS"
+	// EnvConnMaxLifetime is a duration such as 5m, see WithConnMaxLifetime.
+	EnvConnMaxLifetime = "CONN_MAX_LIFETIME"
+	// EnvConnMaxIdleTime is a duration such as 1m, see WithConnMaxIdleTime.
+	EnvConnMaxIdleTime = "CONN_MAX_IDLE_TIME"
+	// EnvMigrationTimeout is a duration such as 5m, see WithMigrationTimeout.
+	EnvMigrationTimeout = "MIGRATION_TIMEOUT"
+	// EnvEncryptionConfig is the path of an EncryptionConfiguration file, see WithEncryptionConfiguration.
+	EnvEncryptionConfig = "ENCRYPTION_CONFIG"
+	// EnvEncryptionAPIServerID is the API server ID passed to KMS plugins of the encryption configuration.
+	EnvEncryptionAPIServerID = "ENCRYPTION_API_SERVER_ID"
+	// EnvCompactRetain is the number of records kept before compaction, see WithCompactionRetain.
+	EnvCompactRetain = "COMPACT_RETAIN"
+	// EnvDeleteRetain is the number of compacted records kept before deletion, see WithDeleteRetain.
+	EnvDeleteRetain = "DELETE_RETAIN"
+	// EnvGCIntervalSeconds is the number of seconds between garbage collection runs, see WithGCInterval.
+	EnvGCIntervalSeconds = "GC_INTERVAL_SECONDS"
+	// EnvBookmarkIntervalSeconds is the number of seconds between bookmarks, see WithBookmarkInterval.
+	EnvBookmarkIntervalSeconds = "BOOKMARK_INTERVAL_SECONDS"
+	// EnvIDAllocation is how record IDs are allocated, "auto_increment" (the default) or "sequence" for a
+	// SequenceTable, see WithIDAllocator.
+	EnvIDAllocation = "ID_ALLOCATION"
+	// EnvReplicas is true if multiple servers write the database, see WithReplicas.
+	EnvReplicas = "REPLICAS"
+	// EnvMigrationDryRun is true to roll back the migrations run at startup, see WithMigrationDryRun.
+	EnvMigrationDryRun = "MIGRATION_DRY_RUN"
+)
+
+// WithCompactionRetain sets the number of records that are kept when the table is compacted, which is how far back
+// a watch can start. The default is 1000. Zero disables compaction and deletion.
+func WithCompactionRetain(count uint) StrategyOption {
+	return func(s *Strategy) {
+		if g, ok := s.db.(*GormDB); ok {
+			g.compactRetain = count
+		}
+	}
+}
+
+// WithDeleteRetain sets the number of compacted records that are kept before being deleted. The default is 1000.
+// Zero disables deletion.
+func WithDeleteRetain(count int) StrategyOption {
+	return func(s *Strategy) {
+		if g, ok := s.db.(*GormDB); ok {
+			g.deleteRetain = count
+		}
+	}
+}
+
+// WithGCInterval sets the time between compaction and deletion runs. The default is 30 minutes.
+func WithGCInterval(interval time.Duration) StrategyOption {
+	return func(s *Strategy) {
+		if g, ok := s.db.(*GormDB); ok {
+			g.gcInterval = interval
+		}
+	}
+}
+
+// GCOptions configure the garbage collection of a table. Compaction marks the records superseded by a newer version
+// of their object as garbage, except for the latest CompactRetain records, which is how far back a watch can start.
+// Deletion then removes the garbage, except for the latest DeleteRetain records. Zero fields keep the current value.
+type GCOptions struct {
+	// CompactRetain is the number of records kept when the table is compacted. The default is 1000.
+	CompactRetain uint
+	// DeleteRetain is the number of compacted records kept before they are deleted. The default is 1000.
+	DeleteRetain int
+	// Interval is the time between compaction and deletion runs. The default is 30 minutes.
+	Interval time.Duration
+	// CompactBatchSize is the number of records compacted per statement. The default is 1000.
+	CompactBatchSize int
+	// DeleteBatchSize is the number of records deleted per statement. The default is 1000.
+	DeleteBatchSize int
+	// DisableCompaction turns off compaction, and with it deletion.
+	DisableCompaction bool
+	// DisableDeletion turns off deletion, compacted records are kept.
+	DisableDeletion bool
+}
+
+// WithGCOptions sets the garbage collection options of the table. Unlike WithCompactionRetain and WithDeleteRetain,
+// zero values keep the defaults and the Disable fields turn compaction and deletion off.
+func WithGCOptions(opts GCOptions) StrategyOption {
+	return func(s *Strategy) {
+		g, ok := s.db.(*GormDB)
+		if !ok {
+			return
+		}
+		if opts.CompactRetain != 0 {
+			g.compactRetain = opts.CompactRetain
+		}
+		if opts.DeleteRetain != 0 {
+			g.deleteRetain = opts.DeleteRetain
+		}
+		if opts.Interval != 0 {
+			g.gcInterval = opts.Interval
+		}
+		if opts.CompactBatchSize != 0 {
+			g.compactBatchSize = opts.CompactBatchSize
+		}
+		if opts.DeleteBatchSize != 0 {
+			g.deleteBatchSize = opts.DeleteBatchSize
+		}
+		if opts.DisableCompaction {
+			g.compactRetain = 0
+		}
+		if opts.DisableDeletion {
+			g.deleteRetain = 0
+		}
+	}
+}
+
+// WithTableGCOptions sets the garbage collection options of the table named table, they are applied after the
+// options for all tables. Table names are compared case-insensitively.
+func WithTableGCOptions(table string, opts GCOptions) FactoryOption {
+	return WithStrategyOptions(func(s *Strategy) {
+		if g, ok := s.db.(*GormDB); ok && strings.EqualFold(g.tableName, table) {
+			WithGCOptions(opts)(s)
+		}
+	})
+}
+
+// WithWatchBuffer sets the number of events buffered for each watch of the table, and what happens to a watch whose
+// buffer is full. The default is 10000 events and WatchOverflowResync. Zero and empty values keep the default.
+func WithWatchBuffer(size int, policy WatchOverflowPolicy) StrategyOption {
+	return func(s *Strategy) {
+		g, ok := s.db.(*GormDB)
+		if !ok {
+			return
+		}
+		if size > 0 {
+			g.watchBufferSize = size
+		}
+		if policy != "" {
+			g.watchOverflow = policy
+		}
+	}
+}
+
+// WithBookmarkInterval sets the time between the bookmarks sent to the watches of the table that allow them, which
+// advance the resource version the clients resume from even if nothing changes. The default is one minute. Intervals
+// shorter than two seconds make the table polled for changes that often. Bookmarks are also sent on demand, see
+// Strategy.RequestWatchProgress.
+func WithBookmarkInterval(interval time.Duration) StrategyOption {
+	return func(s *Strategy) {
+		if g, ok := s.db.(*GormDB); ok && interval > 0 {
+			g.configuredBookmarkInterval = interval
+		}
+	}
+}
+
+// WithListKind sets the kind of the lists of objects. The default is the kind of the objects followed by "List", it
+// usually only needs to be set for unstructured objects whose list kind doesn't follow that convention.
+func WithListKind(kind string) StrategyOption {
+	return func(s *Strategy) {
+		s.listKind = kind
+	}
+}
+
+// OptionsFromEnv returns the factory options configured by the environment variables listed above, each prefixed
+// with prefix, for example "MINK_". Variables that are not set leave the defaults in place. An error is returned if
+// a variable can't be parsed or the encryption configuration can't be loaded.
+func OptionsFromEnv(ctx context.Context, prefix string) ([]FactoryOption, error) {
+	var opts []FactoryOption
+
+	if v, ok := lookupEnv(prefix + EnvMaxOpenConns); ok {
+		i, err := strconv.Atoi(v)
+		if err != nil || i <= 0 {
+			return nil, fmt.Errorf("invalid value %s%s=%s: must be a positive integer", prefix, EnvMaxOpenConns, v)
+		}
+		opts = append(opts, WithMaxOpenConns(i))
+	}
+
+	if v, ok := lookupEnv(prefix + EnvMaxIdleConns); ok {
+		i, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value %s%s=%s: must be an integer", prefix, EnvMaxIdleConns, v)
+		}
+		opts = append(opts, WithMaxIdleConns(i))
+	}
+
+	for _, setting := range []struct {
+		name string
+		opt  func(time.Duration) FactoryOption
+	}{
+		{name: EnvConnMaxLifetime, opt: WithConnMaxLifetime},
+		{name: EnvConnMaxIdleTime, opt: WithConnMaxIdleTime},
+	} {
+		if v, ok := lookupEnv(prefix + setting.name); ok {
+			d, err := time.ParseDuration(v)
+			if err != nil {
+				return nil, fmt.Errorf("invalid value %s%s=%s: %w", prefix, setting.name, v, err)
+			}
+			opts = append(opts, setting.opt(d))
+		}
+	}
+
+	if v, ok := lookupEnv(prefix + EnvMigrationTimeout); ok {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value %s%s=%s: %w", prefix, EnvMigrationTimeout, v, err)
+		}
+		opts = append(opts, WithMigrationTimeout(d))
+	}
+
+	if v, ok := lookupEnv(prefix + EnvEncryptionConfig); ok {
+		apiServerID, _ := lookupEnv(prefix + EnvEncryptionAPIServerID)
+		opt, err := WithEncryptionConfiguration(ctx, v, apiServerID)
+		if err != nil {
+			return nil, fmt.Errorf("loading %s%s=%s: %w", prefix, EnvEncryptionConfig, v, err)
+		}
+		opts = append(opts, opt)
+	}
+
+	if v, ok := lookupEnv(prefix + EnvReplicas); ok {
+		replicas, err := strconv.ParseBool(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value %s%s=%s: %w", prefix, EnvReplicas, v, err)
+		}
+		if replicas {
+			opts = append(opts, WithReplicas())
+		}
+	}
+
+	if v, ok := lookupEnv(prefix + EnvMigrationDryRun); ok {
+		dryRun, err := strconv.ParseBool(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value %s%s=%s: %w", prefix, EnvMigrationDryRun, v, err)
+		}
+		if dryRun {
+			opts = append(opts, WithMigrationDryRun())
+		}
+	}
+
+	var strategyOpts []StrategyOption
+	if v, ok := lookupEnv(prefix + EnvIDAllocation); ok {
+		switch v {
+		case "auto_increment":
+		case "sequence":
+			strategyOpts = append(strategyOpts, WithIDAllocator(NewSequenceTable()))
+		default:
+			return nil, fmt.Errorf("invalid value %s%s=%s: must be auto_increment or sequence", prefix, EnvIDAllocation, v)
+		}
+	}
+	for _, setting := range []struct {
+		name string
+		opt  func(uint) StrategyOption
+	}{
+		{name: EnvCompactRetain, opt: WithCompactionRetain},
+		{name: EnvDeleteRetain, opt: func(i uint) StrategyOption { return WithDeleteRetain(int(i)) }},
+		{name: EnvGCIntervalSeconds, opt: func(i uint) StrategyOption { return WithGCInterval(time.Duration(i) * time.Second) }},
+		{name: EnvBookmarkIntervalSeconds, opt: func(i uint) StrategyOption { return WithBookmarkInterval(time.Duration(i) * time.Second) }},
+	} {
+		values, err := uintsFromEnv(prefix + setting.name)
+		if err != nil {
+			return nil, err
+		}
+		if len(values) > 0 {
+			strategyOpts = append(strategyOpts, perTable(values, setting.opt))
+		}
+	}
+	if len(strategyOpts) > 0 {
+		opts = append(opts, WithStrategyOptions(strategyOpts...))
+	}
+
+	return opts, nil
+}
+
+func lookupEnv(key string) (string, bool) {
+	v := os.Getenv(key)
+	return v, v != ""
+}
+
+// uintsFromEnv returns the values of key and of key_<TABLE> for every table it is set for. The value for all tables
+// is stored under the empty string and table names are upper case.
+func uintsFromEnv(key string) (map[string]uint, error) {
+	result := map[string]uint{}
+	for _, env := range os.Environ() {
+		name, v, _ := strings.Cut(env, "=")
+		if v == "" {
+			continue
+		}
+		var table string
+		if name != key {
+			var ok bool
+			if table, ok = strings.CutPrefix(name, key+"_"); !ok || table == "" {
+				continue
+			}
+		}
+		i, err := strconv.ParseUint(v, 10, 0)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value %s=%s: %w", name, v, err)
+		}
+		result[strings.ToUpper(table)] = uint(i)
+	}
+	return result, nil
+}
+
+// perTable applies the value for the strategy's table, falling back to the value for all tables.
+func perTable(values map[string]uint, opt func(uint) StrategyOption) StrategyOption {
+	return func(s *Strategy) {
+		g, ok := s.db.(*GormDB)
+		if !ok {
+			return
+		}
+		v, ok := values[strings.ToUpper(g.tableName)]
+		if !ok {
+			v, ok = values[""]
+		}
+		if ok {
+			opt(v)(s)
+		}
+	}
+}
@@ -0,0 +1,49 @@
+//go:build oracle
+
+// Oracle support is only compiled in with the oracle build tag so that the default binary doesn't link the godror
+// driver, which requires cgo and the Oracle Instant Client libraries at runtime. The driver is required in go.mod, but
+// only linked into binaries built with the tag:
+//
+//	go build -tags oracle ./...
+//
+// DSNs of the form oracle://user/password@host:port/service are then handed to godror without the prefix.
+
+package db
+
+import (
+	"github.com/cengsin/oracle"
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+func init() {
+	dialectors["oracle://"] = func(dsn string) gorm.Dialector {
+		return oracleDialector{
+			Dialector: oracle.Open(dsn),
+		}
+	}
+}
+
+// oracleDialector maps auto increment primary keys to identity columns so that Record.ID is assigned by the
+// database in insert order, as the watch loop expects. Oracle has no AUTO_INCREMENT and the driver would otherwise
+// create a plain NUMBER column.
+type oracleDialector struct {
+	gorm.Dialector
+}
+
+// Migrator hands the migrator this dialector, it would otherwise ask the wrapped one for column types.
+func (d oracleDialector) Migrator(db *gorm.DB) gorm.Migrator {
+	m := d.Dialector.Migrator(db)
+	if om, ok := m.(oracle.Migrator); ok {
+		om.Dialector = d
+		return om
+	}
+	return m
+}
+
+func (d oracleDialector) DataTypeOf(field *schema.Field) string {
+	if field.PrimaryKey && field.AutoIncrement {
+		return "NUMBER(20) GENERATED BY DEFAULT ON NULL AS IDENTITY"
+	}
+	return d.Dialector.DataTypeOf(field)
+}
@@ -0,0 +1,259 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/acorn-io/mink/pkg/types"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apiserver/pkg/endpoints/handlers/responsewriters"
+	"k8s.io/apiserver/pkg/endpoints/request"
+	"k8s.io/apiserver/pkg/storage"
+	"k8s.io/client-go/kubernetes/scheme"
+)
+
+// requestInfoResolver classifies requests the API server hasn't parsed yet, when PartitionMiddleware runs in
+// server.Config.Middleware. It uses the same API prefixes as the API server.
+var requestInfoResolver = &request.RequestInfoFactory{
+	APIPrefixes:          sets.NewString("api", "apis"),
+	GrouplessAPIPrefixes: sets.NewString("api"),
+}
+
+// PartitionSource extracts the partition ID from a request. It returns an empty string if the request does not
+// carry a partition ID in this source. A source may rewrite the request, for example to strip a URL prefix, the
+// request it is given is a copy owned by the middleware.
+type PartitionSource func(req *http.Request) (string, error)
+
+// PartitionFromHeader reads the partition ID from the given request header.
+func PartitionFromHeader(header string) PartitionSource {
+	return func(req *http.Request) (string, error) {
+		values := req.Header.Values(header)
+		if len(values) > 1 {
+			return "", fmt.Errorf("multiple values for header %s", header)
+		}
+		if len(values) == 0 {
+			return "", nil
+		}
+		return strings.TrimSpace(values[0]), nil
+	}
+}
+
+// PartitionFromUserExtra reads the partition ID from the extra attributes of the authenticated user, which is
+// where authenticators put token claims. The middleware must run after authentication, see
+// server.Config.AuthenticatedMiddleware.
+func PartitionFromUserExtra(key string) PartitionSource {
+	return func(req *http.Request) (string, error) {
+		u, ok := request.UserFrom(req.Context())
+		if !ok {
+			return "", nil
+		}
+		values := u.GetExtra()[key]
+		if len(values) > 1 {
+			return "", fmt.Errorf("multiple values for user attribute %s", key)
+		}
+		if len(values) == 0 {
+			return "", nil
+		}
+		return values[0], nil
+	}
+}
+
+// PartitionFromPathPrefix reads the partition ID from a URL of the form <prefix>/<partition>/... and strips both from
+// the path, so /partitions/p1/apis/example.com/v1/things is served as /apis/example.com/v1/things in partition p1.
+// The path has to be rewritten before the API server parses it, so the middleware must be added to
+// server.Config.Middleware.
+func PartitionFromPathPrefix(prefix string) PartitionSource {
+	prefix = "/" + strings.Trim(prefix, "/") + "/"
+	return func(req *http.Request) (string, error) {
+		rest, ok := strings.CutPrefix(req.URL.Path, prefix)
+		if !ok {
+			return "", nil
+		}
+		id, rest, _ := strings.Cut(rest, "/")
+		req.URL.Path = "/" + rest
+		req.URL.RawPath = ""
+		req.RequestURI = req.URL.RequestURI()
+		return id, nil
+	}
+}
+
+// ValidatePartitionID is the default validation used by PartitionMiddleware, it requires partition IDs to be DNS-1123
+// labels.
+func ValidatePartitionID(id string) error {
+	if errs := validation.IsDNS1123Label(id); len(errs) > 0 {
+		return fmt.Errorf("invalid partition ID %q: %s", id, strings.Join(errs, ", "))
+	}
+	return nil
+}
+
+type PartitionOptions struct {
+	// Sources are consulted in order and the first non-empty partition ID is used. If different sources return
+	// different partition IDs, the request is rejected.
+	Sources []PartitionSource
+	// Required rejects requests without a partition ID. Non-resource requests, such as /healthz, /readyz, /openapi
+	// and discovery, are exempt wherever the middleware runs.
+	Required bool
+	// Validate checks the partition ID, it defaults to ValidatePartitionID.
+	Validate func(id string) error
+}
+
+// PartitionID returns the partition ID of req from the first source that has one, or an error if the sources
+// disagree or the partition ID is invalid. It returns an empty partition ID if no source has one, regardless of
+// Required.
+func (o PartitionOptions) PartitionID(req *http.Request) (string, error) {
+	var partitionID string
+	for _, source := range o.Sources {
+		id, err := source(req)
+		if err != nil {
+			return "", err
+		}
+		if id == "" {
+			continue
+		}
+		if partitionID != "" && partitionID != id {
+			return "", fmt.Errorf("conflicting partition IDs %q and %q", partitionID, id)
+		}
+		partitionID = id
+	}
+	if partitionID == "" {
+		return "", nil
+	}
+
+	validate := o.Validate
+	if validate == nil {
+		validate = ValidatePartitionID
+	}
+	if err := validate(partitionID); err != nil {
+		return "", err
+	}
+	return partitionID, nil
+}
+
+// PartitionMiddleware extracts the partition ID of each request and stores it in the request context for
+// PartitionIDFromContext. Requests with a missing, conflicting or invalid partition ID are rejected with a 400
+// Status.
+func PartitionMiddleware(opts PartitionOptions) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			req = req.Clone(req.Context())
+
+			partitionID, err := opts.PartitionID(req)
+			if err != nil {
+				responsewriters.ErrorNegotiated(apierrors.NewBadRequest(err.Error()), scheme.Codecs, schema.GroupVersion{Version: "v1"}, w, req)
+				return
+			}
+
+			if partitionID == "" {
+				if opts.Required && !isNonResourceRequest(req) {
+					responsewriters.ErrorNegotiated(apierrors.NewBadRequest("partition ID is required"), scheme.Codecs, schema.GroupVersion{Version: "v1"}, w, req)
+					return
+				}
+				next.ServeHTTP(w, req)
+				return
+			}
+
+			next.ServeHTTP(w, req.WithContext(ContextWithPartitionID(req.Context(), partitionID)))
+		})
+	}
+}
+
+// isNonResourceRequest uses the RequestInfo of the API server if it has parsed req already, and parses req itself
+// otherwise.
+func isNonResourceRequest(req *http.Request) bool {
+	if info, ok := request.RequestInfoFrom(req.Context()); ok {
+		return !info.IsResourceRequest
+	}
+	info, err := requestInfoResolver.NewRequestInfo(req)
+	return err == nil && !info.IsResourceRequest
+}
+
+// PartitionAssigner decides the partition ID of an object when it is created, for example from a tenant field in
+// its spec or from the identity of the requesting user. It is given the partition ID from the request context,
+// which may be empty, and returns the partition ID to store the object in. The partition of an object can't
+// change, updates for which the assigner returns a different partition are rejected.
+type PartitionAssigner func(ctx context.Context, obj types.Object, partitionID string) (string, error)
+
+// WithPartitionAssigner sets the PartitionAssigner used by the strategy. Without one, objects are stored in the
+// partition from the request context.
+func WithPartitionAssigner(assign PartitionAssigner) StrategyOption {
+	return func(s *Strategy) {
+		s.assignPartition = assign
+	}
+}
+
+func (s *Strategy) partitionFor(ctx context.Context, obj types.Object, partitionID string) (string, error) {
+	if s.assignPartition == nil {
+		return partitionID, nil
+	}
+	return s.assignPartition(ctx, obj, partitionID)
+}
+
+// WithPartitionLabel stores the partition ID of every object in the label key of its metadata, so consumers reading
+// the table directly and label selectors evaluated by the database can rely on it. Objects served by the strategy
+// don't have the label, clients only see their own partition, and values sent by clients are ignored. Label
+// selectors on key still match as if the label was set.
+func WithPartitionLabel(key string) StrategyOption {
+	return func(s *Strategy) {
+		s.partitionLabel = key
+	}
+}
+
+// setPartitionLabel sets the partition label in the metadata of a record about to be written.
+func (s *Strategy) setPartitionLabel(rec *Record) error {
+	if s.partitionLabel == "" {
+		return nil
+	}
+	metadata := map[string]any{}
+	if len(rec.Metadata) > 0 {
+		if err := json.Unmarshal(rec.Metadata, &metadata); err != nil {
+			return err
+		}
+	}
+	labels, _ := metadata["labels"].(map[string]any)
+	if labels == nil {
+		labels = map[string]any{}
+	}
+	labels[s.partitionLabel] = rec.PartitionID
+	metadata["labels"] = labels
+	data, err := json.Marshal(metadata)
+	if err != nil {
+		return err
+	}
+	rec.Metadata = data
+	return nil
+}
+
+// removePartitionLabel removes the partition label from metadata read from or sent to the strategy.
+func (s *Strategy) removePartitionLabel(metadata map[string]any) {
+	if s.partitionLabel == "" {
+		return
+	}
+	if labels, ok := metadata["labels"].(map[string]any); ok {
+		delete(labels, s.partitionLabel)
+		if len(labels) == 0 {
+			delete(metadata, "labels")
+		}
+	}
+}
+
+// matches is p.Matches for an object of the given partition, with the partition label set for the label selector.
+func (s *Strategy) matches(p storage.SelectionPredicate, obj types.Object, partitionID string) (bool, error) {
+	if s.partitionLabel == "" {
+		return p.Matches(obj)
+	}
+	labels := obj.GetLabels()
+	withPartition := make(map[string]string, len(labels)+1)
+	for k, v := range labels {
+		withPartition[k] = v
+	}
+	withPartition[s.partitionLabel] = partitionID
+	obj.SetLabels(withPartition)
+	defer obj.SetLabels(labels)
+	return p.Matches(obj)
+}
@@ -0,0 +1,52 @@
+package db
+
+import (
+	"context"
+	"errors"
+
+	"k8s.io/apiserver/pkg/storage/value"
+)
+
+// ErrKeyDestroyed is returned by a PartitionKeyProvider for a partition whose key has been destroyed. The records of
+// the partition can't be decrypted anymore, they are left out of gets, lists and watches as if they had been deleted,
+// which is how a partition is crypto-shredded.
+var ErrKeyDestroyed = errors.New("the encryption key of the partition has been destroyed")
+
+// PartitionKeyProvider selects the key encrypting the data of the records of a partition, for example to give every
+// tenant its own key.
+type PartitionKeyProvider interface {
+	// TransformerForPartition returns the transformer encrypting the records of the partition, or nil to use the
+	// transformer of the encryption configuration. It returns ErrKeyDestroyed, possibly wrapped, if the key of the
+	// partition has been destroyed.
+	TransformerForPartition(ctx context.Context, partitionID string) (value.Transformer, error)
+}
+
+// WithPartitionKeys encrypts the records of every partition with the transformer selected by provider. Records that
+// are not in a partition, and partitions the provider has no transformer for, are encrypted as configured with
+// WithEncryptionConfiguration. Pass it to WithStrategyOptions to use the provider for every table.
+func WithPartitionKeys(provider PartitionKeyProvider) StrategyOption {
+	return func(s *Strategy) {
+		if g, ok := s.db.(*GormDB); ok {
+			g.partitionKeys = provider
+		}
+	}
+}
+
+// partitionTransformer returns the transformer of the partition of rec, or nil if the partition has none.
+func (g *GormDB) partitionTransformer(ctx context.Context, rec *Record) (value.Transformer, error) {
+	if g.partitionKeys == nil || rec.PartitionID == "" {
+		return nil, nil
+	}
+	return g.partitionKeys.TransformerForPartition(ctx, rec.PartitionID)
+}
+
+// readable returns the records that are not shredded.
+func readable(records []Record) []Record {
+	result := records[:0:0]
+	for _, rec := range records {
+		if !rec.shredded {
+			result = append(result, rec)
+		}
+	}
+	return result
+}
@@ -0,0 +1,112 @@
+package db
+
+import (
+	"database/sql"
+	"time"
+)
+
+// PoolOptions configure the connection pool of the database, see sql.DB. Zero fields keep the default.
+type PoolOptions struct {
+	// MaxOpenConns is the maximum number of open connections.
+	MaxOpenConns int
+	// MaxIdleConns is the maximum number of idle connections, by default MaxOpenConns. A negative value keeps no idle
+	// connections.
+	MaxIdleConns int
+	// ConnMaxLifetime is how long a connection is reused before it is closed, a negative value reuses connections
+	// forever.
+	ConnMaxLifetime time.Duration
+	// ConnMaxIdleTime is how long a connection stays idle before it is closed, a negative value keeps idle
+	// connections forever.
+	ConnMaxIdleTime time.Duration
+}
+
+// defaultPoolOptions are the pool options of every dialect unless set with the pool options of the factory. sqlite
+// is limited to a single connection regardless, see Factory.poolOptions.
+var defaultPoolOptions = PoolOptions{
+	MaxOpenConns:    5,
+	ConnMaxLifetime: 3 * time.Minute,
+}
+
+// WithMaxOpenConns sets the maximum number of open connections to the database. The default is 5. It is ignored for
+// sqlite, which is limited to a single connection.
+func WithMaxOpenConns(maxOpenConns int) FactoryOption {
+	return func(f *Factory) {
+		f.pool.MaxOpenConns = maxOpenConns
+	}
+}
+
+// WithMaxIdleConns sets the maximum number of idle connections to the database, see PoolOptions.MaxIdleConns. It is
+// ignored for sqlite.
+func WithMaxIdleConns(maxIdleConns int) FactoryOption {
+	return func(f *Factory) {
+		f.pool.MaxIdleConns = maxIdleConns
+	}
+}
+
+// WithConnMaxLifetime sets how long a connection to the database is reused before it is closed. The default is three
+// minutes.
+func WithConnMaxLifetime(lifetime time.Duration) FactoryOption {
+	return func(f *Factory) {
+		f.pool.ConnMaxLifetime = lifetime
+	}
+}
+
+// WithConnMaxIdleTime sets how long a connection to the database stays idle before it is closed. By default idle
+// connections are closed when they reach their lifetime, see WithConnMaxLifetime.
+func WithConnMaxIdleTime(idleTime time.Duration) FactoryOption {
+	return func(f *Factory) {
+		f.pool.ConnMaxIdleTime = idleTime
+	}
+}
+
+// WithDialectPoolOptions sets the pool options used when the DSN of the factory is of the given dialect, "mysql",
+// "tidb", "postgres" or "sqlserver", or the prefix of a registered dialector without "://", such as "oracle". Options
+// set with WithMaxOpenConns and the other pool options take precedence. This lets a server whose DSN is configured at
+// deployment size its pool for each database it may run against.
+func WithDialectPoolOptions(dialect string, opts PoolOptions) FactoryOption {
+	return func(f *Factory) {
+		if f.dialectPools == nil {
+			f.dialectPools = map[string]PoolOptions{}
+		}
+		f.dialectPools[dialect] = opts
+	}
+}
+
+// poolOptions returns the pool options of the factory for dialect.
+func (f *Factory) poolOptions(dialect string) PoolOptions {
+	opts := f.pool
+	opts.defaultTo(f.dialectPools[dialect])
+	opts.defaultTo(defaultPoolOptions)
+	if dialect == "sqlite" {
+		opts.MaxOpenConns = 1
+		opts.MaxIdleConns = 1
+	}
+	return opts
+}
+
+func (o *PoolOptions) defaultTo(defaults PoolOptions) {
+	if o.MaxOpenConns == 0 {
+		o.MaxOpenConns = defaults.MaxOpenConns
+	}
+	if o.MaxIdleConns == 0 {
+		o.MaxIdleConns = defaults.MaxIdleConns
+	}
+	if o.ConnMaxLifetime == 0 {
+		o.ConnMaxLifetime = defaults.ConnMaxLifetime
+	}
+	if o.ConnMaxIdleTime == 0 {
+		o.ConnMaxIdleTime = defaults.ConnMaxIdleTime
+	}
+}
+
+// apply configures the pool of sqlDB.
+func (o PoolOptions) apply(sqlDB *sql.DB) {
+	maxIdleConns := o.MaxIdleConns
+	if maxIdleConns == 0 {
+		maxIdleConns = o.MaxOpenConns
+	}
+	sqlDB.SetMaxOpenConns(o.MaxOpenConns)
+	sqlDB.SetMaxIdleConns(maxIdleConns)
+	sqlDB.SetConnMaxLifetime(max(o.ConnMaxLifetime, 0))
+	sqlDB.SetConnMaxIdleTime(max(o.ConnMaxIdleTime, 0))
+}
@@ -0,0 +1,371 @@
+package db
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+	"k8s.io/apimachinery/pkg/util/uuid"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/apimachinery/pkg/watch"
+)
+
+const (
+	publishCursorsTableName     = "mink_publish_cursors"
+	defaultPublishBatchSize     = 100
+	defaultPublishPollInterval  = 2 * time.Second
+	defaultPublishLease         = 30 * time.Second
+	defaultPublishRetryInterval = 10 * time.Second
+)
+
+// ChangeEvent is a create, update or delete of an object, as published to a Sink.
+type ChangeEvent struct {
+	// Type is ADDED, MODIFIED or DELETED.
+	Type            watch.EventType `json:"type"`
+	Table           string          `json:"table"`
+	ResourceVersion uint            `json:"resourceVersion"`
+	// PartitionID is the partition of the object, empty if the table isn't partitioned.
+	PartitionID string `json:"partitionID,omitempty"`
+	// Object is the object after the change, or before it was deleted, as served by the API.
+	Object json.RawMessage `json:"object"`
+}
+
+// Sink delivers change events to an external system, such as a message broker. Publish is retried with the same
+// events until it succeeds, so sinks must tolerate duplicates. Events of one table are published in order.
+type Sink interface {
+	Publish(ctx context.Context, events []ChangeEvent) error
+}
+
+type SinkFunc func(ctx context.Context, events []ChangeEvent) error
+
+func (s SinkFunc) Publish(ctx context.Context, events []ChangeEvent) error {
+	return s(ctx, events)
+}
+
+// WebhookSink posts the events of a batch as a JSON array to URL. Any response other than 2xx is a failure.
+type WebhookSink struct {
+	URL    string
+	Client *http.Client
+	// Header is added to every request, for example for authentication.
+	Header http.Header
+}
+
+func (w *WebhookSink) Publish(ctx context.Context, events []ChangeEvent) error {
+	data, err := json.Marshal(events)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.URL, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	for k, v := range w.Header {
+		req.Header[k] = v
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	client := w.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook %s returned %s", w.URL, resp.Status)
+	}
+	return nil
+}
+
+// PublishCursor is the position of a publisher in a table. Events up to and including Position have been delivered.
+type PublishCursor struct {
+	// Name is the publisher name and table, separated by a slash.
+	Name     string `gorm:"primaryKey;size:255"`
+	Position uint
+	// Owner is the server publishing the table until LeaseUntil, only one server publishes a table at a time.
+	Owner      string
+	LeaseUntil time.Time
+	Updated    time.Time
+}
+
+// Publisher tails the tables of the strategies of a factory and publishes their changes to a Sink. Delivery is at
+// least once: the position of every table is stored in a cursor table after the sink accepted the events, and after a
+// restart publishing continues from there. Changes that are compacted and deleted before they are published are lost,
+// so the garbage collection settings must leave the publisher enough time.
+type Publisher struct {
+	factory *Factory
+	db      *gorm.DB
+	name    string
+	id      string
+	sink    Sink
+
+	batchSize     int
+	pollInterval  time.Duration
+	lease         time.Duration
+	retryInterval time.Duration
+}
+
+type PublisherOption func(*Publisher)
+
+// WithPublishBatchSize sets the maximum number of events passed to a single Publish call. The default is 100.
+func WithPublishBatchSize(size int) PublisherOption {
+	return func(p *Publisher) {
+		p.batchSize = size
+	}
+}
+
+// WithPublishPollInterval sets how often tables are checked for changes. The default is 2 seconds.
+func WithPublishPollInterval(interval time.Duration) PublisherOption {
+	return func(p *Publisher) {
+		p.pollInterval = interval
+	}
+}
+
+// WithPublishRetryInterval sets the delay before events the sink failed to publish are published again. The default
+// is 10 seconds.
+func WithPublishRetryInterval(interval time.Duration) PublisherOption {
+	return func(p *Publisher) {
+		p.retryInterval = interval
+	}
+}
+
+// NewPublisher returns a publisher of the changes of the tables of the factory to sink. The name identifies the
+// position of the publisher, a publisher started with the same name continues where the last one stopped. A new
+// publisher starts with the changes made after it is first run.
+func (f *Factory) NewPublisher(name string, sink Sink, opts ...PublisherOption) (*Publisher, error) {
+	if err := f.requireSQL("publishers"); err != nil {
+		return nil, err
+	}
+	if f.AutoMigrate {
+		if err := f.DB.Table(publishCursorsTableName).AutoMigrate(&PublishCursor{}); err != nil {
+			return nil, err
+		}
+	}
+	p := &Publisher{
+		factory:       f,
+		db:            f.DB,
+		name:          name,
+		id:            string(uuid.NewUUID()),
+		sink:          sink,
+		batchSize:     defaultPublishBatchSize,
+		pollInterval:  defaultPublishPollInterval,
+		lease:         defaultPublishLease,
+		retryInterval: defaultPublishRetryInterval,
+	}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(p)
+		}
+	}
+	return p, nil
+}
+
+// Run publishes the changes of every table of the factory until ctx is done. Tables of strategies created after Run
+// is called are not published.
+func (p *Publisher) Run(ctx context.Context) {
+	p.factory.strategiesLock.Lock()
+	strategies := p.factory.strategies
+	p.factory.strategiesLock.Unlock()
+
+	var wg sync.WaitGroup
+	for _, s := range strategies {
+		g, ok := s.db.(*GormDB)
+		if !ok || g.db == nil {
+			continue
+		}
+		wg.Add(1)
+		go func(s *Strategy, g *GormDB) {
+			defer wg.Done()
+			p.publishTable(ctx, s, g)
+		}(s, g)
+	}
+	wg.Wait()
+}
+
+func (p *Publisher) publishTable(ctx context.Context, s *Strategy, g *GormDB) {
+	cursorName := p.name + "/" + g.tableName
+	for {
+		delay := p.pollInterval
+		published, err := p.publishBatch(ctx, cursorName, s, g)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			g.log.Errorf("Failed to publish changes of [%s] with [%s]: %v", g.tableName, p.name, err)
+			delay = p.retryInterval
+		} else if published {
+			delay = 0
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(wait.Jitter(delay, 0.1)):
+		}
+	}
+}
+
+var errNotLeader = errors.New("another server is publishing")
+
+// acquire returns the cursor of the table if this server holds, or could take, its lease.
+func (p *Publisher) acquire(ctx context.Context, cursorName string, g *GormDB) (*PublishCursor, error) {
+	now := time.Now().UTC()
+	db := p.db.WithContext(ctx).Table(publishCursorsTableName)
+
+	cursor := &PublishCursor{}
+	err := db.Where("name = ?", cursorName).Take(cursor).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		position, err := g.getMaxID(ctx)
+		if err != nil {
+			return nil, err
+		}
+		cursor = &PublishCursor{
+			Name:       cursorName,
+			Position:   position,
+			Owner:      p.id,
+			LeaseUntil: now.Add(p.lease),
+			Updated:    now,
+		}
+		// if another server inserted the cursor first this fails and the next round reads it
+		return cursor, p.db.WithContext(ctx).Table(publishCursorsTableName).Create(cursor).Error
+	} else if err != nil {
+		return nil, err
+	}
+
+	if cursor.Owner != p.id && cursor.LeaseUntil.After(now) {
+		return nil, errNotLeader
+	}
+	resp := p.db.WithContext(ctx).Table(publishCursorsTableName).
+		Where("name = ? AND (owner = ? OR lease_until < ?)", cursorName, p.id, now).
+		Updates(map[string]any{
+			"owner":       p.id,
+			"lease_until": now.Add(p.lease),
+			"updated":     now,
+		})
+	if resp.Error != nil {
+		return nil, resp.Error
+	}
+	if resp.RowsAffected == 0 {
+		return nil, errNotLeader
+	}
+	cursor.Owner = p.id
+	return cursor, nil
+}
+
+// publishBatch publishes the next batch of changes of the table, it returns true if there may be more.
+func (p *Publisher) publishBatch(ctx context.Context, cursorName string, s *Strategy, g *GormDB) (bool, error) {
+	cursor, err := p.acquire(ctx, cursorName, g)
+	if errors.Is(err, errNotLeader) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+
+	events, position, err := readChanges(ctx, s, g, cursor.Position, p.batchSize, p.name, "")
+	if err != nil || position == cursor.Position {
+		return false, err
+	}
+
+	if len(events) > 0 {
+		if err := p.sink.Publish(ctx, events); err != nil {
+			return false, err
+		}
+	}
+
+	err = p.db.WithContext(ctx).Table(publishCursorsTableName).
+		Where("name = ? AND owner = ?", cursorName, p.id).
+		Updates(map[string]any{
+			"position": position,
+			"updated":  time.Now().UTC(),
+		}).Error
+	return true, err
+}
+
+// readChanges returns the events of up to limit records of the table after position, and the position of the last
+// record read. It stops early at gaps in the record IDs that may be filled by writes yet to commit, records that were
+// garbage collected are skipped and logged as lost to consumer, or fail the read with a CompactionError if consumer
+// is empty. Unless partitionID is empty, only the changes of that partition are returned.
+func readChanges(ctx context.Context, s *Strategy, g *GormDB, position uint, limit int, consumer, partitionID string) ([]ChangeEvent, uint, error) {
+	var records []Record
+	err := g.db.WithContext(ctx).Table(g.tableName).
+		Where("id > ?", position).
+		Order("id ASC").
+		Limit(limit).
+		Find(&records).Error
+	if err != nil || len(records) == 0 {
+		return nil, position, err
+	}
+
+	g.compactionLock.RLock()
+	compaction := g.compaction
+	g.compactionLock.RUnlock()
+
+	// A gap in the IDs is either a record deleted by garbage collection or a write that hasn't committed yet, the watch
+	// loop fills the latter in. Stop at gaps after the compaction point, they may still be filled.
+	expected := position + 1
+	for i, record := range records {
+		if record.ID != expected {
+			if expected > compaction {
+				records = records[:i]
+				break
+			}
+			if consumer == "" {
+				return nil, position, newCompactionError(position, compaction)
+			}
+			g.log.Warnf("Changes of [%s] from %d to %d were deleted before [%s] read them", g.tableName, expected, record.ID-1, consumer)
+		}
+		expected = record.ID + 1
+	}
+	if len(records) == 0 {
+		return nil, position, nil
+	}
+
+	events := make([]ChangeEvent, 0, len(records))
+	for i := range records {
+		record := &records[i]
+		if record.Name == "" {
+			// compaction and fill records
+			continue
+		}
+		if partitionID != "" && record.PartitionID != partitionID {
+			continue
+		}
+		if err := g.decryptData(ctx, record); errors.Is(err, ErrKeyDestroyed) {
+			continue
+		} else if err != nil {
+			return nil, position, err
+		}
+		obj, err := s.recordToMap(record)
+		if err != nil {
+			return nil, position, err
+		}
+		data, err := json.Marshal(obj)
+		if err != nil {
+			return nil, position, err
+		}
+
+		event := ChangeEvent{
+			Type:            watch.Modified,
+			Table:           g.tableName,
+			ResourceVersion: record.ID,
+			PartitionID:     record.PartitionID,
+			Object:          data,
+		}
+		if record.Create {
+			event.Type = watch.Added
+		} else if record.Removed != nil {
+			event.Type = watch.Deleted
+		}
+		events = append(events, event)
+	}
+
+	return events, records[len(records)-1].ID, nil
+}
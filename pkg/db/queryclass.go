@@ -0,0 +1,81 @@
+package db
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"strings"
+
+	"gorm.io/gorm"
+	"k8s.io/apiserver/pkg/authentication/user"
+	"k8s.io/apiserver/pkg/endpoints/request"
+)
+
+// QueryClass identifies the connection pool a request's queries are run against.
+type QueryClass string
+
+const (
+	QueryClassSystem QueryClass = "system"
+	QueryClassUser   QueryClass = "user"
+)
+
+type queryClassKey struct{}
+
+func ContextWithQueryClass(ctx context.Context, class QueryClass) context.Context {
+	if class == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, queryClassKey{}, class)
+}
+
+func QueryClassFromContext(ctx context.Context) QueryClass {
+	class, _ := ctx.Value(queryClassKey{}).(QueryClass)
+	return class
+}
+
+// QueryClassifier assigns a query class to an authenticated request.
+type QueryClassifier func(req *http.Request) QueryClass
+
+// DefaultQueryClassifier treats cluster admins and "system:" users, such as controllers and service accounts, as
+// system traffic and everything else, including anonymous and unauthenticated requests, as user traffic.
+func DefaultQueryClassifier(req *http.Request) QueryClass {
+	u, ok := request.UserFrom(req.Context())
+	if !ok || u.GetName() == user.Anonymous || slices.Contains(u.GetGroups(), user.AllUnauthenticated) {
+		return QueryClassUser
+	}
+	if strings.HasPrefix(u.GetName(), "system:") {
+		return QueryClassSystem
+	}
+	for _, group := range u.GetGroups() {
+		if group == user.SystemPrivilegedGroup {
+			return QueryClassSystem
+		}
+	}
+	return QueryClassUser
+}
+
+// QueryClassMiddleware stores the class of each request in its context. It needs the request user, so it should be
+// added to server.Config.AuthenticatedMiddleware. If classifier is nil, DefaultQueryClassifier is used.
+func QueryClassMiddleware(classifier QueryClassifier) func(http.Handler) http.Handler {
+	if classifier == nil {
+		classifier = DefaultQueryClassifier
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			next.ServeHTTP(w, req.WithContext(ContextWithQueryClass(req.Context(), classifier(req))))
+		})
+	}
+}
+
+// WithQueryClassDBs runs the queries of requests in the given classes against a dedicated connection pool. Queries
+// without a class, or with a class that has no pool, use the default connection pool.
+func WithQueryClassDBs(dbs map[QueryClass]*gorm.DB) StrategyOption {
+	return func(s *Strategy) {
+		if g, ok := s.db.(*GormDB); ok {
+			g.classDBs = make(map[QueryClass]*gorm.DB, len(dbs))
+			for class, db := range dbs {
+				g.classDBs[class] = g.withTableLogger(db)
+			}
+		}
+	}
+}
@@ -0,0 +1,158 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+const defaultReEncryptBatchSize = 100
+
+// ReEncryptResult is the outcome of the re-encryption of a table.
+type ReEncryptResult struct {
+	Table string
+	GVK   schema.GroupVersionKind
+	// Records is the number of records read.
+	Records int
+	// ReEncrypted is the number of records written again with the current key.
+	ReEncrypted int
+	// Shredded is the number of records left alone because the key of their partition was destroyed.
+	Shredded int
+}
+
+// ReEncrypt encrypts the records of the tables of the given kinds, or of every table of the factory if none are given,
+// with the current write key, so that a key being rotated out of the encryption configuration can be removed. That
+// is, records encrypted with a key that isn't the first of their provider anymore, records that aren't encrypted but
+// should be, such as those written before encryption was configured, and records whose encryption doesn't match their
+// partition key, see WithPartitionKeys. Every record is rewritten in place, the history kept for watches included,
+// without changing its resource version, so clients see no changes.
+//
+// Records are read in batches and progress is logged after each batch. Only the tables of strategies created by the
+// factory can be re-encrypted, an error is returned for kinds none of them store.
+func (f *Factory) ReEncrypt(ctx context.Context, gvks ...schema.GroupVersionKind) ([]ReEncryptResult, error) {
+	if err := f.requireSQL("re-encryption"); err != nil {
+		return nil, err
+	}
+
+	f.strategiesLock.Lock()
+	strategies := f.strategies
+	f.strategiesLock.Unlock()
+
+	var tables []*GormDB
+	if len(gvks) == 0 {
+		for _, s := range strategies {
+			if g, ok := s.db.(*GormDB); ok {
+				tables = append(tables, g)
+			}
+		}
+	}
+	for _, gvk := range gvks {
+		var found bool
+		for _, s := range strategies {
+			if g, ok := s.db.(*GormDB); ok && g.gvk.GroupKind() == gvk.GroupKind() {
+				tables = append(tables, g)
+				found = true
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("no table of the factory stores %s", gvk.GroupKind())
+		}
+	}
+
+	var result []ReEncryptResult
+	for _, g := range tables {
+		tableResult, err := g.reEncrypt(ctx, defaultReEncryptBatchSize)
+		if err != nil {
+			return result, fmt.Errorf("re-encrypting %s: %w", g.tableName, err)
+		}
+		result = append(result, tableResult)
+	}
+	return result, nil
+}
+
+func (g *GormDB) reEncrypt(ctx context.Context, batchSize int) (ReEncryptResult, error) {
+	result := ReEncryptResult{
+		Table: g.tableName,
+		GVK:   g.gvk,
+	}
+
+	var total int64
+	if err := g.db.WithContext(ctx).Table(g.tableName).Count(&total).Error; err != nil {
+		return result, err
+	}
+
+	var lastID uint
+	for {
+		var records []Record
+		err := g.db.WithContext(ctx).Table(g.tableName).
+			Select("id", "kind", "api_group", "name", "namespace", "uid", "partition_id", "data").
+			Where("id > ?", lastID).
+			Order("id").
+			Limit(batchSize).
+			Find(&records).Error
+		if err != nil {
+			return result, err
+		}
+		if len(records) == 0 {
+			break
+		}
+		lastID = records[len(records)-1].ID
+
+		for i := range records {
+			result.Records++
+			rewrite, err := g.needsReEncryption(ctx, &records[i])
+			if errors.Is(err, ErrKeyDestroyed) {
+				result.Shredded++
+				continue
+			} else if err != nil {
+				return result, fmt.Errorf("decrypting record %d: %w", records[i].ID, err)
+			}
+			if !rewrite {
+				continue
+			}
+			if err := g.encryptData(ctx, &records[i]); err != nil {
+				return result, fmt.Errorf("encrypting record %d: %w", records[i].ID, err)
+			}
+			err = g.db.WithContext(ctx).Table(g.tableName).Where("id = ?", records[i].ID).Update("data", records[i].Data).Error
+			if err != nil {
+				return result, err
+			}
+			result.ReEncrypted++
+		}
+
+		g.log.Infof("Re-encryption of [%s]: read %d of about %d records, re-encrypted %d", g.tableName, result.Records,
+			total, result.ReEncrypted)
+	}
+	return result, nil
+}
+
+// needsReEncryption decrypts rec and returns whether encrypting it again would change how it is encrypted.
+func (g *GormDB) needsReEncryption(ctx context.Context, rec *Record) (bool, error) {
+	var encrypted map[string]string
+	if err := json.Unmarshal(rec.Data, &encrypted); err != nil || encrypted["e"] == "" {
+		encrypted = nil
+	}
+
+	partitionKey, err := g.partitionTransformer(ctx, rec)
+	if err != nil {
+		return false, err
+	}
+	stale, err := g.decrypt(ctx, rec)
+	if err != nil {
+		return false, err
+	}
+	if stale {
+		return true, nil
+	}
+
+	if partitionKey != nil {
+		return encrypted["p"] == "", nil
+	}
+	if _, ok := g.transformers[schema.GroupKind{Group: rec.APIGroup, Kind: rec.Kind}]; ok {
+		return encrypted == nil || encrypted["p"] != "", nil
+	}
+	return false, nil
+}
@@ -0,0 +1,162 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Running multiple servers against one database
+//
+// Every server runs its own watch loop over each table, so all of them see every write in ID order, fill the same
+// gaps and learn about compaction from the compaction records in the table. Writes are safe without coordination:
+// the unique index on the previous record rejects the losing write of a race, and a fill record can only take an ID
+// whose write was rolled back, as the insert of a fill waits for an uncommitted record with the same ID and then fails.
+//
+// Garbage collection is not safe without coordination. Servers whose watch loops are at different points would write
+// compaction records going back and forth, compact the same records and delete them concurrently, and a server could
+// still allow a watch from a resource version another server just compacted. WithReplicas makes garbage collection
+// of each table run on one server at a time, holding a lease in the mink_gc_leases table. The lease has an epoch that
+// is checked in the transaction writing the compaction record, so a server that lost its lease while it was paused
+// can't write one. Servers also read the compaction point from the table before serving a list or watch from a given
+// resource version, rather than trusting the compaction records their watch loop has read so far.
+
+const gcLeasesTableName = "mink_gc_leases"
+
+var errLeaseLost = errors.New("lost the garbage collection lease")
+
+// GCLease is held by the server running garbage collection of a table when the factory was created WithReplicas.
+type GCLease struct {
+	// Name is the table name.
+	Name  string `gorm:"primaryKey;size:255"`
+	Owner string
+	// Epoch is incremented whenever the lease changes owner, it fences writes of previous owners.
+	Epoch      uint
+	LeaseUntil time.Time
+	Updated    time.Time
+}
+
+// WithReplicas makes the strategies of the factory safe for multiple servers writing the same database, see the
+// description above. All servers must use it.
+func WithReplicas() FactoryOption {
+	return func(f *Factory) {
+		f.replicas = true
+		f.strategyOptions = append(f.strategyOptions, withReplicas())
+	}
+}
+
+func withReplicas() StrategyOption {
+	return func(s *Strategy) {
+		if g, ok := s.db.(*GormDB); ok {
+			g.replicas = true
+		}
+	}
+}
+
+// gcLease returns how long a garbage collection lease lasts. It is renewed every garbage collection run.
+func (g *GormDB) gcLease() time.Duration {
+	return 2*g.gcSettings().interval + time.Minute
+}
+
+// acquireGCLease returns the epoch of the garbage collection lease of the table if this server holds, or could take,
+// it. It returns errNotLeader if another server holds it.
+func (g *GormDB) acquireGCLease(ctx context.Context) (uint, error) {
+	now := time.Now().UTC()
+	db := g.db.WithContext(ctx)
+
+	lease := &GCLease{}
+	err := db.Table(gcLeasesTableName).Where("name = ?", g.tableName).Take(lease).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		lease = &GCLease{
+			Name:       g.tableName,
+			Owner:      g.id,
+			Epoch:      1,
+			LeaseUntil: now.Add(g.gcLease()),
+			Updated:    now,
+		}
+		// if another server inserted the lease first this fails and the next run reads it
+		return lease.Epoch, db.Table(gcLeasesTableName).Create(lease).Error
+	} else if err != nil {
+		return 0, err
+	}
+
+	if lease.Owner != g.id && lease.LeaseUntil.After(now) {
+		return 0, errNotLeader
+	}
+	epoch := lease.Epoch
+	if lease.Owner != g.id {
+		epoch++
+	}
+	resp := db.Table(gcLeasesTableName).
+		Where("name = ? AND owner = ? AND epoch = ?", g.tableName, lease.Owner, lease.Epoch).
+		Updates(map[string]any{
+			"owner":       g.id,
+			"epoch":       epoch,
+			"lease_until": now.Add(g.gcLease()),
+			"updated":     now,
+		})
+	if resp.Error != nil {
+		return 0, resp.Error
+	}
+	if resp.RowsAffected == 0 {
+		return 0, errNotLeader
+	}
+	return epoch, nil
+}
+
+// checkGCLease fails with errLeaseLost unless this server still holds the lease at epoch. Called in a transaction it
+// locks the lease until the transaction ends, so a new owner can only take over after it.
+func (g *GormDB) checkGCLease(ctx context.Context, epoch uint) error {
+	now := time.Now().UTC()
+	resp := g.getDB(ctx).WithContext(ctx).Table(gcLeasesTableName).
+		Where("name = ? AND owner = ? AND epoch = ? AND lease_until >= ?", g.tableName, g.id, epoch, now).
+		Updates(map[string]any{
+			"lease_until": now.Add(g.gcLease()),
+			"updated":     now,
+		})
+	if resp.Error != nil {
+		return resp.Error
+	}
+	if resp.RowsAffected == 0 {
+		return errLeaseLost
+	}
+	return nil
+}
+
+// getCompaction returns the ID of the latest compaction record of the table, zero if there is none.
+func (g *GormDB) getCompaction(ctx context.Context) (uint, error) {
+	var record Record
+	query := g.newQuery(ctx).Select("namespace")
+	if g.emptyIsNull() {
+		query.Where("name IS NULL AND namespace IS NOT NULL")
+	} else {
+		query.Where("name = ? AND namespace != ?", "", "")
+	}
+	err := query.Order("id DESC").Limit(1).Scan(&record).Error
+	if err != nil || record.Namespace == "" {
+		return 0, err
+	}
+	compaction, err := strconv.ParseUint(record.Namespace, 10, 64)
+	return uint(compaction), err
+}
+
+// refreshCompaction raises the compaction point to the latest one written by any server. It only queries the table
+// for strategies created WithReplicas, a single server knows its own compaction point.
+func (g *GormDB) refreshCompaction(ctx context.Context) error {
+	if !g.replicas || g.db == nil {
+		return nil
+	}
+	compaction, err := g.getCompaction(ctx)
+	if err != nil {
+		return err
+	}
+	g.compactionLock.Lock()
+	if compaction > g.compaction {
+		g.compaction = compaction
+	}
+	g.compactionLock.Unlock()
+	return nil
+}
@@ -0,0 +1,152 @@
+package db
+
+import (
+	"strings"
+	"time"
+
+	"github.com/acorn-io/mink/pkg/strategy"
+	"github.com/sirupsen/logrus"
+)
+
+// RuntimeSettings override the settings the tables were configured with while the server runs. Nil fields keep the
+// configured value, so setting an empty RuntimeSettings restores the configuration.
+type RuntimeSettings struct {
+	// CompactRetain overrides WithCompactionRetain.
+	CompactRetain *uint
+	// DeleteRetain overrides WithDeleteRetain.
+	DeleteRetain *int
+	// GCInterval overrides WithGCInterval, it takes effect after the next run.
+	GCInterval *time.Duration
+	// CompactBatchSize and DeleteBatchSize override the batch sizes of GCOptions.
+	CompactBatchSize *int
+	DeleteBatchSize  *int
+	// BookmarkInterval overrides WithBookmarkInterval.
+	BookmarkInterval *time.Duration
+	// SQLLogLevel replaces the level of the logger for the logs of the SQL queries of the table: at error failed
+	// queries are logged, at warn and info slow queries too, at debug and trace every query, and at lower levels
+	// none. By default failed and slow queries are logged if the level of the logger allows.
+	SQLLogLevel *logrus.Level
+	// SlowQueryThreshold is how long a query of the table takes before it is logged as slow. The default is 200ms.
+	SlowQueryThreshold *time.Duration
+}
+
+func (g *GormDB) SetRuntimeSettings(settings RuntimeSettings) {
+	g.settingsLock.Lock()
+	defer g.settingsLock.Unlock()
+	g.settings = settings
+}
+
+// gcConfig is the garbage collection configuration of a table with the runtime settings applied. Zero retain counts
+// disable compaction or deletion.
+type gcConfig struct {
+	compactRetain    uint
+	deleteRetain     int
+	interval         time.Duration
+	compactBatchSize int
+	deleteBatchSize  int
+}
+
+func (g *GormDB) gcSettings() gcConfig {
+	g.settingsLock.RLock()
+	defer g.settingsLock.RUnlock()
+
+	config := gcConfig{
+		compactRetain:    g.compactRetain,
+		deleteRetain:     g.deleteRetain,
+		interval:         g.gcInterval,
+		compactBatchSize: g.compactBatchSize,
+		deleteBatchSize:  g.deleteBatchSize,
+	}
+	if g.settings.CompactRetain != nil {
+		config.compactRetain = *g.settings.CompactRetain
+	}
+	if g.settings.DeleteRetain != nil {
+		config.deleteRetain = *g.settings.DeleteRetain
+	}
+	if g.settings.GCInterval != nil && *g.settings.GCInterval > 0 {
+		config.interval = *g.settings.GCInterval
+	}
+	if g.settings.CompactBatchSize != nil && *g.settings.CompactBatchSize > 0 {
+		config.compactBatchSize = *g.settings.CompactBatchSize
+	}
+	if g.settings.DeleteBatchSize != nil && *g.settings.DeleteBatchSize > 0 {
+		config.deleteBatchSize = *g.settings.DeleteBatchSize
+	}
+	return config
+}
+
+func (g *GormDB) bookmarkInterval() time.Duration {
+	g.settingsLock.RLock()
+	defer g.settingsLock.RUnlock()
+	if g.settings.BookmarkInterval != nil && *g.settings.BookmarkInterval > 0 {
+		return *g.settings.BookmarkInterval
+	}
+	return g.configuredBookmarkInterval
+}
+
+// sqlLogSettings returns the SQL log level and slow query threshold of the table, or false if the runtime settings
+// don't override them.
+func (g *GormDB) sqlLogSettings() (logrus.Level, time.Duration, bool) {
+	g.settingsLock.RLock()
+	defer g.settingsLock.RUnlock()
+	if g.settings.SQLLogLevel == nil && g.settings.SlowQueryThreshold == nil {
+		return 0, 0, false
+	}
+	level, slowThreshold := logrus.InfoLevel, slowQueryThreshold
+	if g.settings.SQLLogLevel != nil {
+		level = *g.settings.SQLLogLevel
+	}
+	if g.settings.SlowQueryThreshold != nil && *g.settings.SlowQueryThreshold > 0 {
+		slowThreshold = *g.settings.SlowQueryThreshold
+	}
+	return level, slowThreshold, true
+}
+
+// SetRuntimeSettings changes the settings of the table the strategy stores its objects in.
+func (s *Strategy) SetRuntimeSettings(settings RuntimeSettings) {
+	if g, ok := s.db.(*GormDB); ok {
+		g.SetRuntimeSettings(settings)
+	}
+}
+
+// SetRuntimeSettings changes the settings of the tables of the strategies created by the factory.
+func (f *Factory) SetRuntimeSettings(settings RuntimeSettings) {
+	f.strategiesLock.Lock()
+	strategies := f.strategies
+	f.strategiesLock.Unlock()
+
+	for _, s := range strategies {
+		s.SetRuntimeSettings(settings)
+	}
+}
+
+var _ strategy.WatchTerminator = (*Factory)(nil)
+
+// TerminateWatches ends the watches of the strategies created by the factory after sending them a final bookmark, see
+// strategy.WatchTerminator.
+func (f *Factory) TerminateWatches() {
+	f.strategiesLock.Lock()
+	strategies := f.strategies
+	f.strategiesLock.Unlock()
+
+	for _, s := range strategies {
+		s.TerminateWatches()
+	}
+}
+
+// SetTableRuntimeSettings changes the settings of the table named table, which is compared case-insensitively. It
+// returns false if the factory created no strategy for the table.
+func (f *Factory) SetTableRuntimeSettings(table string, settings RuntimeSettings) bool {
+	f.strategiesLock.Lock()
+	strategies := f.strategies
+	f.strategiesLock.Unlock()
+
+	found := false
+	for _, s := range strategies {
+		if g, ok := s.db.(*GormDB); ok && strings.EqualFold(g.tableName, table) {
+			g.SetRuntimeSettings(settings)
+			found = true
+		}
+	}
+	return found
+}
@@ -0,0 +1,205 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"slices"
+	"sort"
+	"time"
+
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// watchSnapshot is the latest state of every live object in a table at a single resource version. It is shared
+// by all watches started within the snapshot TTL so that a reconnect storm only scans the table once.
+type watchSnapshot struct {
+	records         []Record
+	resourceVersion uint
+	created         time.Time
+}
+
+// WithWatchSnapshotTTL enables serving the initial state of new watches from a snapshot of the table that is
+// shared by all watches started within ttl of each other. A ttl of zero, the default, disables the snapshot and
+// every watch scans the table itself.
+func WithWatchSnapshotTTL(ttl time.Duration) StrategyOption {
+	return func(s *Strategy) {
+		if g, ok := s.db.(*GormDB); ok {
+			g.snapshotTTL = ttl
+		}
+	}
+}
+
+// getSnapshot returns the shared snapshot, building it if it expired. The snapshot is built with a context detached
+// from the one of the watch asking for it, so that the watch going away doesn't fail it for the others waiting on it.
+func (g *GormDB) getSnapshot(ctx context.Context) (*watchSnapshot, error) {
+	ctx = context.WithoutCancel(ctx)
+
+	g.snapshotLock.Lock()
+	defer g.snapshotLock.Unlock()
+
+	if g.snapshot != nil && time.Since(g.snapshot.created) < g.snapshotTTL {
+		return g.snapshot, nil
+	}
+
+	var (
+		snapshot = &watchSnapshot{
+			created: time.Now(),
+		}
+		before, after uint
+	)
+	for {
+		resp, newBefore, err := g.Get(ctx, Criteria{
+			After:                 after,
+			Before:                before,
+			Limit:                 1000,
+			ignoreCompactionCheck: true,
+		})
+		if err != nil {
+			return nil, err
+		}
+		if before == 0 {
+			snapshot.resourceVersion = newBefore
+		}
+		snapshot.records = append(snapshot.records, readable(resp)...)
+		if len(resp) == 1000 {
+			before = newBefore
+			after = resp[len(resp)-1].ID
+		} else {
+			break
+		}
+	}
+
+	sort.Slice(snapshot.records, func(i, j int) bool {
+		return snapshot.records[i].ID < snapshot.records[j].ID
+	})
+
+	g.snapshot = snapshot
+	return snapshot, nil
+}
+
+// initializeWatchFromSnapshot sends the records of the shared snapshot that match the criteria followed by
+// everything that changed between the snapshot and now, so the watcher sees the same state it would have seen from
+// a fresh scan. The changes are read without the label selector and filtered after they are applied, so that an
+// object whose labels stopped matching since the snapshot is sent as removed.
+func (g *GormDB) initializeWatchFromSnapshot(ctx context.Context, criteria WatchCriteria, result chan<- Record) error {
+	snapshot, err := g.getSnapshot(ctx)
+	if err != nil {
+		return err
+	}
+
+	// sent is the last version sent of every object the watcher currently sees
+	sent := map[string]Record{}
+	for _, record := range snapshot.records {
+		if !snapshotMatches(record, criteria) {
+			continue
+		}
+		record.InitialState = true
+		sent[snapshotKey(record)] = record
+		result <- record
+	}
+
+	var (
+		before uint
+		after  = snapshot.resourceVersion
+	)
+	for {
+		resp, newBefore, err := g.Get(ctx, Criteria{
+			Name:                  criteria.Name,
+			Namespace:             criteria.Namespace,
+			Namespaces:            criteria.Namespaces,
+			After:                 after,
+			Before:                before,
+			FieldSelector:         criteria.FieldSelector,
+			Limit:                 1000,
+			IncludeDeleted:        true,
+			ignoreCompactionCheck: true,
+			PartitionID:           criteria.PartitionID,
+		})
+		if err != nil {
+			return err
+		}
+		for _, record := range readable(resp) {
+			var (
+				key        = snapshotKey(record)
+				last, seen = sent[key]
+				matches    = snapshotMatches(record, criteria)
+			)
+			switch {
+			case record.Removed != nil:
+				// objects created and removed since the snapshot were never seen by this watcher
+				if !seen {
+					continue
+				}
+				delete(sent, key)
+				if !matches {
+					record = removedAs(last, record)
+				}
+			case matches:
+				if !seen {
+					record.InitialState = true
+				}
+				sent[key] = record
+			case seen:
+				// the object no longer matches, the watcher is told it is gone
+				delete(sent, key)
+				record = removedAs(last, record)
+			default:
+				continue
+			}
+			result <- record
+		}
+		if len(resp) == 1000 {
+			before = newBefore
+			after = resp[len(resp)-1].ID
+		} else {
+			break
+		}
+	}
+
+	return nil
+}
+
+// removedAs returns the last version of an object sent to a watcher as removed by record. The last version is sent
+// because it is the one that matched the watcher's label selector.
+func removedAs(last, record Record) Record {
+	last.ID = record.ID
+	last.Removed = &record.Updated
+	last.InitialState = false
+	last.Create = false
+	return last
+}
+
+func snapshotKey(record Record) string {
+	return record.PartitionID + "/" + record.Namespace + "/" + record.Name
+}
+
+// snapshotMatches filters snapshot records in memory the same way Get filters them in SQL. Field selectors are
+// left to the caller because they apply to the decoded object.
+func snapshotMatches(record Record, criteria WatchCriteria) bool {
+	if criteria.Name != "" && record.Name != criteria.Name {
+		return false
+	}
+	if criteria.Namespace != nil && record.Namespace != *criteria.Namespace {
+		return false
+	}
+	if criteria.Namespace == nil && criteria.Namespaces != nil && !slices.Contains(criteria.Namespaces, record.Namespace) {
+		return false
+	}
+	if criteria.PartitionID != "" && record.PartitionID != criteria.PartitionID {
+		return false
+	}
+	if criteria.LabelSelector != nil && !criteria.LabelSelector.Empty() {
+		var metadata struct {
+			Labels map[string]string `json:"labels,omitempty"`
+		}
+		if len(record.Metadata) > 0 {
+			if err := json.Unmarshal(record.Metadata, &metadata); err != nil {
+				return false
+			}
+		}
+		if !criteria.LabelSelector.Matches(labels.Set(metadata.Labels)) {
+			return false
+		}
+	}
+	return true
+}
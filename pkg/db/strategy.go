@@ -5,14 +5,20 @@ import (
 	"context"
 	"encoding/base64"
 	"encoding/json"
+	"fmt"
 	"net/http"
+	"slices"
 	"strconv"
 	"time"
 
+	"github.com/acorn-io/mink/pkg/db/errtypes"
+	"github.com/acorn-io/mink/pkg/strategy"
 	"github.com/acorn-io/mink/pkg/types"
 	"gorm.io/gorm"
 	apierror "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/util/uuid"
@@ -27,42 +33,77 @@ type Strategy struct {
 	db                  DB
 	obj                 runtime.Object
 	objList             runtime.Object
+	listKind            string
 	gvk                 schema.GroupVersionKind
 	partitionIDRequired bool
+	assignPartition     PartitionAssigner
+	trackFieldOwners    bool
+	trackUsers          bool
+	partitionLabel      string
 
 	dbCtx    context.Context
 	dbCancel func()
 }
 
+// StrategyOption configures optional behavior of a Strategy and the database it is backed by.
+type StrategyOption func(*Strategy)
+
 type cont struct {
 	ID uint `json:"id,omitempty"`
+	// ResourceVersion is the resource version of the first page, every page is read at it.
+	ResourceVersion uint `json:"rv,omitempty"`
 }
 
-func NewStrategy(scheme *runtime.Scheme, obj runtime.Object, tableName string, db *gorm.DB, transformers map[schema.GroupKind]value.Transformer, partitionIDRequired bool) (*Strategy, error) {
+func NewStrategy(scheme *runtime.Scheme, obj runtime.Object, tableName string, db *gorm.DB, transformers map[schema.GroupKind]value.Transformer, partitionIDRequired bool, opts ...StrategyOption) (*Strategy, error) {
 	gvk, err := apiutil.GVKForObject(obj, scheme)
 	if err != nil {
 		return nil, err
 	}
+	return newStrategy(scheme, obj, gvk, NewDB(tableName, gvk, db, transformers), partitionIDRequired, opts...)
+}
 
-	// test we can create objects
-	_, err = scheme.New(gvk)
+// NewStrategyForDB returns a strategy storing the objects of the type of obj in db, a table of a Backend other than
+// a SQL database.
+func NewStrategyForDB(scheme *runtime.Scheme, obj runtime.Object, db DB, partitionIDRequired bool, opts ...StrategyOption) (*Strategy, error) {
+	gvk, err := apiutil.GVKForObject(obj, scheme)
 	if err != nil {
 		return nil, err
 	}
+	return newStrategy(scheme, obj, gvk, db, partitionIDRequired, opts...)
+}
+
+func newStrategy(scheme *runtime.Scheme, obj runtime.Object, gvk schema.GroupVersionKind, db DB, partitionIDRequired bool, opts ...StrategyOption) (*Strategy, error) {
+	_, unstructuredObj := obj.(*unstructured.Unstructured)
+	if !unstructuredObj {
+		// test we can create objects
+		if _, err := scheme.New(gvk); err != nil {
+			return nil, err
+		}
+	}
 
-	objList, err := scheme.New(schema.GroupVersionKind{
-		Group:   gvk.Group,
-		Version: gvk.Version,
-		Kind:    gvk.Kind + "List",
-	})
 	s := &Strategy{
 		scheme:              scheme,
-		db:                  NewDB(tableName, gvk, db, transformers),
+		db:                  db,
 		gvk:                 gvk,
 		obj:                 obj,
-		objList:             objList,
+		listKind:            gvk.Kind + "List",
 		partitionIDRequired: partitionIDRequired,
 	}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(s)
+		}
+	}
+
+	listGVK := gvk.GroupVersion().WithKind(s.listKind)
+	if unstructuredObj {
+		list := &unstructured.UnstructuredList{}
+		list.SetGroupVersionKind(listGVK)
+		s.objList = list
+	} else {
+		s.objList, _ = scheme.New(listGVK)
+	}
+
 	s.dbCtx, s.dbCancel = context.WithCancel(context.Background())
 	return s, s.db.Start(s.dbCtx)
 }
@@ -90,6 +131,42 @@ func (s *Strategy) Get(ctx context.Context, namespace, name string) (types.Objec
 	if err != nil {
 		return nil, err
 	}
+	records = readable(records)
+	if len(records) == 0 {
+		return nil, newNotFound(s.gvk, name)
+	}
+
+	obj := s.obj.DeepCopyObject()
+	return obj.(types.Object), s.recordIntoObject(&records[0], obj)
+}
+
+var _ strategy.RevisionGetter = (*Strategy)(nil)
+
+// GetRevision returns the object as it was at resourceVersion from the history kept until compaction.
+func (s *Strategy) GetRevision(ctx context.Context, namespace, name, resourceVersion string) (types.Object, error) {
+	partitionID := PartitionIDFromContext(ctx)
+	if s.partitionIDRequired && partitionID == "" {
+		return nil, newPartitionRequiredError()
+	}
+
+	rv, err := strconv.ParseUint(resourceVersion, 10, 64)
+	if err != nil || rv == 0 {
+		return nil, apierror.NewBadRequest(fmt.Sprintf("invalid resource version %q", resourceVersion))
+	}
+	if err := s.checkResourceVersion(ctx, uint(rv)); err != nil {
+		return nil, err
+	}
+
+	records, _, err := s.db.Get(ctx, Criteria{
+		Name:        name,
+		Namespace:   strptr(namespace),
+		Before:      uint(rv),
+		PartitionID: partitionID,
+	})
+	if err != nil {
+		return nil, err
+	}
+	records = readable(records)
 	if len(records) == 0 {
 		return nil, newNotFound(s.gvk, name)
 	}
@@ -118,6 +195,7 @@ func (s *Strategy) GetToList(ctx context.Context, namespace, name string) (types
 
 	list.SetResourceVersion(strconv.FormatUint(uint64(resourceVersionInt), 10))
 
+	records = readable(records)
 	if len(records) == 0 {
 		return list, nil
 	}
@@ -129,6 +207,28 @@ func (s *Strategy) GetToList(ctx context.Context, namespace, name string) (types
 	return list, meta.SetList(list, []runtime.Object{obj})
 }
 
+var _ strategy.WatchProgressRequester = (*Strategy)(nil)
+
+// RequestWatchProgress sends a bookmark with the latest resource version to the watches of the strategy that allow
+// bookmarks, soon rather than at the next bookmark interval, see WithBookmarkInterval. It fails if the DB of the
+// strategy doesn't support it.
+func (s *Strategy) RequestWatchProgress(ctx context.Context) error {
+	if p, ok := s.db.(strategy.WatchProgressRequester); ok {
+		return p.RequestWatchProgress(ctx)
+	}
+	return fmt.Errorf("watch progress requests are not supported by the database of %s", s.gvk.Kind)
+}
+
+var _ strategy.WatchTerminator = (*Strategy)(nil)
+
+// TerminateWatches ends the watches of the strategy after sending them a final bookmark, see
+// strategy.WatchTerminator. It does nothing if the DB of the strategy doesn't support it.
+func (s *Strategy) TerminateWatches() {
+	if t, ok := s.db.(strategy.WatchTerminator); ok {
+		t.TerminateWatches()
+	}
+}
+
 func (s *Strategy) Watch(ctx context.Context, namespace string, opts storage.ListOptions) (<-chan watch.Event, error) {
 	partitionID := PartitionIDFromContext(ctx)
 	if s.partitionIDRequired && partitionID == "" {
@@ -137,6 +237,7 @@ func (s *Strategy) Watch(ctx context.Context, namespace string, opts storage.Lis
 
 	criteria := WatchCriteria{
 		Namespace:     nilOnEmpty(namespace),
+		Namespaces:    scopedNamespaces(ctx, nilOnEmpty(namespace)),
 		LabelSelector: opts.Predicate.Label,
 		FieldSelector: opts.Predicate.Field,
 		PartitionID:   partitionID,
@@ -145,10 +246,16 @@ func (s *Strategy) Watch(ctx context.Context, namespace string, opts storage.Lis
 	if ok {
 		criteria.Name = name
 	}
-	if opts.ResourceVersion != "" {
+	// The resource version follows the semantics of the Kubernetes API:
+	//   unset: send the current state as ADDED events, then every change after it
+	//   "0":   the same as unset, the most recent state satisfies "any" state
+	//   other: replay every change after the given resource version, or 410 Gone if it has been compacted
+	switch opts.ResourceVersion {
+	case "", "0":
+	default:
 		after, err := strconv.ParseUint(opts.ResourceVersion, 10, 64)
 		if err != nil {
-			return nil, err
+			return nil, apierror.NewBadRequest(fmt.Sprintf("invalid resource version %q: %v", opts.ResourceVersion, err))
 		}
 		criteria.After = uint(after)
 	}
@@ -162,6 +269,15 @@ func (s *Strategy) Watch(ctx context.Context, namespace string, opts storage.Lis
 		defer close(result)
 
 		for record := range records {
+			if record.Overflowed {
+				status := apierror.NewResourceExpired("the watch fell too far behind, list and watch again").Status()
+				result <- watch.Event{
+					Type:   watch.Error,
+					Object: &status,
+				}
+				continue
+			}
+
 			obj := s.newObj()
 			if record.Name == "" {
 				obj.SetResourceVersion(strconv.FormatUint(uint64(record.ID), 10))
@@ -186,11 +302,15 @@ func (s *Strategy) Watch(ctx context.Context, namespace string, opts storage.Lis
 				continue
 			}
 
+			if criteria.Namespaces != nil && !slices.Contains(criteria.Namespaces, record.Namespace) {
+				continue
+			}
+
 			event := watch.Event{}
 			match := true
 			err := s.recordIntoObject(&record, obj)
 			if err == nil {
-				match, err = opts.Predicate.Matches(obj)
+				match, err = s.matches(opts.Predicate, obj, record.PartitionID)
 			}
 			if err != nil {
 				event.Type = watch.Error
@@ -201,7 +321,7 @@ func (s *Strategy) Watch(ctx context.Context, namespace string, opts storage.Lis
 				event.Object = &status
 				result <- event
 			} else if match {
-				if record.Create {
+				if record.InitialState || record.Create {
 					event.Type = watch.Added
 					event.Object = obj
 				} else if record.Removed != nil {
@@ -219,6 +339,17 @@ func (s *Strategy) Watch(ctx context.Context, namespace string, opts storage.Lis
 	return result, nil
 }
 
+// scopedNamespaces returns the namespaces a request across all namespaces is limited to by the list scope, if any.
+func scopedNamespaces(ctx context.Context, namespace *string) []string {
+	if namespace != nil {
+		return nil
+	}
+	if scope := strategy.ListScopeFromContext(ctx); scope != nil {
+		return scope.Namespaces
+	}
+	return nil
+}
+
 func (s *Strategy) New() types.Object {
 	return s.obj.DeepCopyObject().(types.Object)
 }
@@ -228,6 +359,11 @@ func (s *Strategy) NewList() types.ObjectList {
 }
 
 func (s *Strategy) newObj() types.Object {
+	if _, ok := s.obj.(*unstructured.Unstructured); ok {
+		obj := &unstructured.Unstructured{}
+		obj.SetGroupVersionKind(s.gvk)
+		return obj
+	}
 	obj, err := s.scheme.New(s.gvk)
 	if err != nil {
 		panic("failed to create object for watch: " + err.Error())
@@ -270,10 +406,33 @@ func (s *Strategy) list(ctx context.Context, namespace *string, partitionID stri
 
 	criteria := Criteria{
 		Namespace:     namespace,
+		Namespaces:    scopedNamespaces(ctx, namespace),
 		Limit:         opts.Predicate.Limit,
 		LabelSelector: opts.Predicate.Label,
 		FieldSelector: opts.Predicate.Field,
 		PartitionID:   partitionID,
+		// like Kubernetes, a list of resource version 0 may be served from the cache, unless it is paged or selects
+		// fields, which the cache leaves to the database
+		cached: opts.ResourceVersion == "0" && opts.Predicate.Continue == "" && opts.Predicate.Limit == 0 &&
+			(opts.Predicate.Field == nil || opts.Predicate.Field.Empty()),
+	}
+
+	var requested uint
+	if opts.ResourceVersion != "" && opts.ResourceVersion != "0" {
+		rv, err := strconv.ParseUint(opts.ResourceVersion, 10, 64)
+		if err != nil {
+			return nil, apierror.NewBadRequest(fmt.Sprintf("invalid resource version %q: %v", opts.ResourceVersion, err))
+		}
+		requested = uint(rv)
+	}
+	// Like Kubernetes, a paginated list of a resource version without a match is a list of exactly that version.
+	exact := requested != 0 && (opts.ResourceVersionMatch == metav1.ResourceVersionMatchExact ||
+		opts.ResourceVersionMatch == "" && opts.Predicate.Limit != 0)
+	if exact {
+		if err := s.checkResourceVersion(ctx, requested); err != nil {
+			return nil, err
+		}
+		criteria.Before = requested
 	}
 
 	if opts.Predicate.Continue != "" {
@@ -286,36 +445,58 @@ func (s *Strategy) list(ctx context.Context, namespace *string, partitionID stri
 			return nil, err
 		}
 		criteria.After = cont.ID
-		criteria.ignoreCompactionCheck = criteria.After != 0
+		if cont.ResourceVersion != 0 {
+			// an expired continue token fails like an expired resource version
+			criteria.Before = cont.ResourceVersion
+			criteria.continued = true
+		} else {
+			criteria.ignoreCompactionCheck = criteria.After != 0
+		}
 	}
 
 	records, resourceVersionInt, err := s.db.Get(ctx, criteria)
 	if err != nil {
 		return nil, err
 	}
+	if !exact && requested > resourceVersionInt {
+		return nil, storage.NewTooLargeResourceVersionError(uint64(requested), uint64(resourceVersionInt), 1)
+	}
 
-	var objs []runtime.Object
+	var (
+		objs []runtime.Object
+		// lastMatched is set if the object of the last record is in objs
+		lastMatched bool
+	)
 	for _, rec := range records {
+		lastMatched = false
+		if rec.shredded {
+			continue
+		}
 		obj := s.obj.DeepCopyObject()
 		err := s.recordIntoObject(&rec, obj)
 		if err != nil {
 			return nil, err
 		}
-		if ok, err := opts.Predicate.Matches(obj); err != nil {
+		if ok, err := s.matches(opts.Predicate, obj.(types.Object), rec.PartitionID); err != nil {
 			return nil, err
 		} else if ok {
 			objs = append(objs, obj)
+			lastMatched = true
 		}
 	}
 
 	if opts.Predicate.Limit != 0 && int64(len(records)) == opts.Predicate.Limit {
 		data, err := json.Marshal(&cont{
-			ID: records[len(records)-2].ID,
+			ID:              records[len(records)-2].ID,
+			ResourceVersion: resourceVersionInt,
 		})
 		if err != nil {
 			return nil, err
 		}
-		objs = objs[0 : len(objs)-1]
+		// the last record only tells if there are more, it is the first of the next page
+		if lastMatched {
+			objs = objs[0 : len(objs)-1]
+		}
 		result.Continue = base64.StdEncoding.EncodeToString(data)
 		result.RemainingCount = &[]int64{1}[0]
 	}
@@ -325,6 +506,23 @@ func (s *Strategy) list(ctx context.Context, namespace *string, partitionID stri
 	return result, nil
 }
 
+// checkResourceVersion returns the error of a list of exactly the resource version rv if it is newer than the latest
+// one. Older resource versions that have been compacted fail when they are read.
+func (s *Strategy) checkResourceVersion(ctx context.Context, rv uint) error {
+	g, ok := s.db.(*GormDB)
+	if !ok || g.db == nil {
+		return nil
+	}
+	latest, err := g.getMaxID(ctx)
+	if err != nil {
+		return err
+	}
+	if rv > latest {
+		return storage.NewTooLargeResourceVersionError(uint64(rv), uint64(latest), 1)
+	}
+	return nil
+}
+
 func (s *Strategy) getExisting(ctx context.Context, gvk schema.GroupVersionKind, namespace *string, name, partitionID string) (*Record, error) {
 	existing, _, err := s.db.Get(ctx, Criteria{
 		Name:              name,
@@ -338,7 +536,8 @@ func (s *Strategy) getExisting(ctx context.Context, gvk schema.GroupVersionKind,
 	if err != nil {
 		return nil, err
 	}
-	if len(existing) == 0 {
+	// the object of a shredded record can't be read any more, so it is gone as far as writes are concerned
+	if len(existing) == 0 || existing[0].shredded {
 		return nil, newNotFound(gvk, name)
 	}
 	return &existing[0], nil
@@ -348,6 +547,110 @@ func (s *Strategy) Delete(ctx context.Context, obj types.Object) (types.Object,
 	return s.Update(ctx, obj)
 }
 
+var _ strategy.CollectionDeleter = (*Strategy)(nil)
+
+// DeleteCollection deletes the objects matching opts in one transaction, so either all of them are deleted or none
+// are, and the watches see the removals together once it commits. The removal records are written in one batch if the
+// DB is a BatchInserter. If an object is written in between, the objects are listed and deleted again.
+func (s *Strategy) DeleteCollection(ctx context.Context, namespace string, opts strategy.DeleteCollectionOptions) (result types.ObjectList, err error) {
+	partitionID := PartitionIDFromContext(ctx)
+	if s.partitionIDRequired && partitionID == "" {
+		return nil, newPartitionRequiredError()
+	}
+
+	for attempt := 1; ; attempt++ {
+		err = s.db.Transaction(ctx, func(ctx context.Context) error {
+			result, err = s.deleteCollection(ctx, nilOnEmpty(namespace), partitionID, opts)
+			return err
+		})
+		if errtypes.IsRetryable(err) && attempt < maxPatchAttempts {
+			continue
+		}
+		return result, err
+	}
+}
+
+func (s *Strategy) deleteCollection(ctx context.Context, namespace *string, partitionID string, opts strategy.DeleteCollectionOptions) (types.ObjectList, error) {
+	records, _, err := s.db.Get(ctx, Criteria{
+		Namespace:         namespace,
+		Namespaces:        scopedNamespaces(ctx, namespace),
+		LabelSelector:     opts.Predicate.Label,
+		FieldSelector:     opts.Predicate.Field,
+		PartitionID:       partitionID,
+		NoResourceVersion: true,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		now     = metav1.Now()
+		deleted []runtime.Object
+		removed []*Record
+	)
+	for i := range records {
+		existing := &records[i]
+		if existing.shredded {
+			continue
+		}
+		obj := s.newObj()
+		if err := s.recordIntoObject(existing, obj); err != nil {
+			return nil, err
+		}
+		if !obj.GetDeletionTimestamp().IsZero() {
+			continue
+		}
+		if ok, err := s.matches(opts.Predicate, obj, existing.PartitionID); err != nil {
+			return nil, err
+		} else if !ok {
+			continue
+		}
+		if opts.Check != nil {
+			if ok, err := opts.Check(ctx, obj); err != nil {
+				return nil, err
+			} else if !ok {
+				continue
+			}
+		}
+
+		obj.SetDeletionTimestamp(&now)
+		rec, err := s.updatedRecord(ctx, s.gvk, false, obj, existing, partitionID)
+		if err != nil {
+			return nil, err
+		}
+		deleted = append(deleted, obj)
+		removed = append(removed, rec)
+	}
+
+	if err := s.insertAll(ctx, removed); err != nil {
+		return nil, translateDuplicateEntryErr(err, s.gvk, "")
+	}
+	for i, rec := range removed {
+		if err := s.recordIntoObject(rec, deleted[i]); err != nil {
+			return nil, err
+		}
+	}
+
+	list := s.NewList()
+	return list, meta.SetList(list, deleted)
+}
+
+// insertAll inserts recs in one batch if the DB supports it, otherwise one at a time.
+func (s *Strategy) insertAll(ctx context.Context, recs []*Record) error {
+	if len(recs) == 0 {
+		return nil
+	}
+	if batch, ok := s.db.(BatchInserter); ok {
+		return batch.InsertBatch(ctx, recs)
+	}
+	for _, rec := range recs {
+		if err := s.db.Insert(ctx, rec); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (s *Strategy) UpdateStatus(ctx context.Context, obj types.Object) (types.Object, error) {
 	newObj, err := s.update(ctx, true, obj)
 	return newObj, translateDuplicateEntryErr(err, s.gvk, obj.GetName())
@@ -358,6 +661,34 @@ func (s *Strategy) Update(ctx context.Context, obj types.Object) (types.Object,
 	return newObj, translateDuplicateEntryErr(err, s.gvk, obj.GetName())
 }
 
+// maxPatchAttempts is the number of times Patch applies a patch before it returns the conflict.
+const maxPatchAttempts = 5
+
+var _ strategy.Patcher = (*Strategy)(nil)
+
+// Patch applies patch to the latest version of the object and updates it with the result. If another write got in
+// between, the patch is applied again to the new version, unless the patch set the resource version itself.
+func (s *Strategy) Patch(ctx context.Context, namespace, name string, patch strategy.PatchFunc) (types.Object, error) {
+	for attempt := 1; ; attempt++ {
+		existing, err := s.Get(ctx, namespace, name)
+		if err != nil {
+			return nil, err
+		}
+		resourceVersion := existing.GetResourceVersion()
+
+		obj, err := patch(existing)
+		if err != nil {
+			return nil, err
+		}
+
+		newObj, err := s.Update(ctx, obj)
+		if errtypes.IsRetryable(err) && obj.GetResourceVersion() == resourceVersion && attempt < maxPatchAttempts {
+			continue
+		}
+		return newObj, err
+	}
+}
+
 func strptr(s string) *string {
 	return &s
 }
@@ -386,13 +717,37 @@ func (s *Strategy) update(ctx context.Context, status bool, obj types.Object) (t
 	}
 
 	if obj.GetResourceVersion() != strconv.FormatUint(uint64(existing.ID), 10) {
+		if s.trackFieldOwners {
+			return nil, newFieldOwnerConflict(gvk, obj, existing)
+		}
 		return nil, newResourceVersionMismatch(gvk, obj.GetName())
 	}
 
+	newRecord, err := s.updatedRecord(ctx, gvk, status, obj, existing, partitionID)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.db.Insert(ctx, newRecord)
+	if err != nil {
+		return nil, err
+	}
+
+	return obj, s.recordIntoObject(newRecord, obj)
+}
+
+// updatedRecord returns the record of the write of obj over existing, the latest record of the object.
+func (s *Strategy) updatedRecord(ctx context.Context, gvk schema.GroupVersionKind, status bool, obj types.Object, existing *Record, partitionID string) (*Record, error) {
 	if err := storage.NewUIDPreconditions(existing.UID).Check(obj.GetName(), obj); err != nil {
 		return nil, newConflict(gvk, obj.GetName(), err)
 	}
 
+	if !status {
+		if err := s.setFieldOwners(ctx, obj, existing); err != nil {
+			return nil, err
+		}
+	}
+
 	newRecord, err := s.objectToRecord(obj)
 	if err != nil {
 		return nil, err
@@ -405,6 +760,20 @@ func (s *Strategy) update(ctx context.Context, status bool, obj types.Object) (t
 	newRecord.UID = existing.UID
 	newRecord.PartitionID = existing.PartitionID
 	newRecord.Updated = time.Now()
+	s.setUsers(ctx, newRecord, existing)
+	if err := s.setPartitionLabel(newRecord); err != nil {
+		return nil, err
+	}
+	if !status {
+		assigned, err := s.partitionFor(ctx, obj, partitionID)
+		if err != nil {
+			return nil, err
+		}
+		if s.assignPartition != nil && assigned != existing.PartitionID {
+			return nil, apierror.NewBadRequest(fmt.Sprintf("the partition of %s %s can not be changed from %q to %q",
+				gvk.Kind, obj.GetName(), existing.PartitionID, assigned))
+		}
+	}
 	if status {
 		newRecord.Generation = existing.Generation
 		newRecord.Data = existing.Data
@@ -426,12 +795,7 @@ func (s *Strategy) update(ctx context.Context, status bool, obj types.Object) (t
 		}
 	}
 
-	err = s.db.Insert(ctx, newRecord)
-	if err != nil {
-		return nil, err
-	}
-
-	return obj, s.recordIntoObject(newRecord, obj)
+	return newRecord, nil
 }
 
 func (s *Strategy) Create(ctx context.Context, obj types.Object) (result types.Object, err error) {
@@ -443,7 +807,10 @@ func (s *Strategy) Create(ctx context.Context, obj types.Object) (result types.O
 }
 
 func (s *Strategy) create(ctx context.Context, obj types.Object) (types.Object, error) {
-	partitionID := PartitionIDFromContext(ctx)
+	partitionID, err := s.partitionFor(ctx, obj, PartitionIDFromContext(ctx))
+	if err != nil {
+		return nil, err
+	}
 	if s.partitionIDRequired && partitionID == "" {
 		return nil, newPartitionRequiredError()
 	}
@@ -461,6 +828,10 @@ func (s *Strategy) create(ctx context.Context, obj types.Object) (types.Object,
 		return nil, err
 	}
 
+	if err := s.setFieldOwners(ctx, obj, nil); err != nil {
+		return nil, err
+	}
+
 	record, err := s.objectToRecord(obj)
 	if err != nil {
 		return nil, err
@@ -476,6 +847,10 @@ func (s *Strategy) create(ctx context.Context, obj types.Object) (types.Object,
 	}
 
 	record.PartitionID = partitionID
+	s.setUsers(ctx, record, nil)
+	if err := s.setPartitionLabel(record); err != nil {
+		return nil, err
+	}
 
 	err = s.db.Insert(ctx, record)
 	if err != nil {
@@ -524,6 +899,7 @@ func (s *Strategy) recordToMap(rec *Record) (map[string]any, error) {
 	data["kind"] = kind
 	data["apiVersion"] = apiVersion
 
+	s.removePartitionLabel(metadata)
 	metadata["uid"] = rec.UID
 	metadata["resourceVersion"] = strconv.Itoa(int(rec.ID))
 	metadata["name"] = rec.Name
@@ -533,12 +909,30 @@ func (s *Strategy) recordToMap(rec *Record) (map[string]any, error) {
 	if rec.Deleted != nil {
 		metadata["deletionTimestamp"] = rec.Deleted.Format(time.RFC3339)
 	}
+	if rec.GlobalSequence != 0 {
+		setAnnotation(metadata, GlobalSequenceAnnotation, strconv.FormatUint(uint64(rec.GlobalSequence), 10))
+	}
+	if rec.CreatedBy != "" {
+		setAnnotation(metadata, CreatedByAnnotation, rec.CreatedBy)
+	}
+	if rec.UpdatedBy != "" {
+		setAnnotation(metadata, UpdatedByAnnotation, rec.UpdatedBy)
+	}
 
 	data["metadata"] = metadata
 
 	return data, nil
 }
 
+func setAnnotation(metadata map[string]any, key, value string) {
+	annotations, _ := metadata["annotations"].(map[string]any)
+	if annotations == nil {
+		annotations = map[string]any{}
+	}
+	annotations[key] = value
+	metadata["annotations"] = annotations
+}
+
 func (s *Strategy) recordIntoObject(rec *Record, obj runtime.Object) error {
 	recordMap, err := s.recordToMap(rec)
 	if err != nil {
@@ -548,6 +942,12 @@ func (s *Strategy) recordIntoObject(rec *Record, obj runtime.Object) error {
 	if err != nil {
 		return err
 	}
+	if o, ok := obj.(types.Object); ok && s.partitionLabel != "" {
+		// the written object is decoded into the one sent by the client, which may have the label
+		if labels := o.GetLabels(); labels != nil {
+			delete(labels, s.partitionLabel)
+		}
+	}
 	return json.Unmarshal(d, obj)
 }
 
@@ -572,6 +972,16 @@ func (s *Strategy) objectToRecord(obj types.Object) (*Record, error) {
 	delete(metadata, "deletionTimestamp")
 	delete(metadata, "name")
 	delete(metadata, "namespace")
+	s.removePartitionLabel(metadata)
+	if annotations, ok := metadata["annotations"].(map[string]any); ok {
+		// these are served from the record, clients send back the ones they read
+		for _, key := range []string{GlobalSequenceAnnotation, CreatedByAnnotation, UpdatedByAnnotation} {
+			delete(annotations, key)
+		}
+		if len(annotations) == 0 {
+			delete(metadata, "annotations")
+		}
+	}
 
 	metadataData, err := json.Marshal(metadata)
 	if err != nil {
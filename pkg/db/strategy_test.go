@@ -2,26 +2,67 @@ package db
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/acorn-io/mink/pkg/db/errtypes"
+	"github.com/acorn-io/mink/pkg/logging"
+	"github.com/acorn-io/mink/pkg/metrics"
+	"github.com/acorn-io/mink/pkg/strategy"
+	"github.com/acorn-io/mink/pkg/strategy/strategytest"
+	"github.com/acorn-io/mink/pkg/strategy/stresstest"
+	"github.com/acorn-io/mink/pkg/strategy/translation"
+	minktypes "github.com/acorn-io/mink/pkg/types"
+	"github.com/go-logr/logr/funcr"
+	"github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/mysql"
 	"gorm.io/driver/sqlite"
+	"gorm.io/driver/sqlserver"
 	"gorm.io/gorm"
 	"gorm.io/gorm/logger"
 	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metainternalversion "k8s.io/apimachinery/pkg/apis/meta/internalversion"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/apiserver/pkg/authentication/user"
+	"k8s.io/apiserver/pkg/authorization/authorizer"
+	"k8s.io/apiserver/pkg/endpoints/request"
 	"k8s.io/apiserver/pkg/storage"
+	"k8s.io/apiserver/pkg/storage/value"
 	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/component-base/metrics/testutil"
 )
 
-func newTestStore(t *testing.T) *Strategy {
-	_ = os.Remove("test.db")
-	db, err := gorm.Open(sqlite.Open("memory:test.db"), &gorm.Config{
+func newTestStore(t *testing.T, opts ...StrategyOption) *Strategy {
+	s, err := NewStrategy(scheme.Scheme, &corev1.Pod{}, "pod", newTestDB(t, "pod"), nil, false, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(s.Destroy)
+	return s
+}
+
+func newTestDB(t *testing.T, table string) *gorm.DB {
+	// Every test gets its own in-memory database
+	db, err := gorm.Open(sqlite.Open("file:"+t.Name()+"?mode=memory&cache=shared"), &gorm.Config{
 		SkipDefaultTransaction: true,
 		Logger: logger.New(log.New(os.Stdout, "\r\n", log.LstdFlags), logger.Config{
 			LogLevel: logger.Info,
@@ -31,16 +72,23 @@ func newTestStore(t *testing.T) *Strategy {
 		t.Fatal(err)
 	}
 
-	err = db.Table("pod").AutoMigrate(&Record{})
+	sqlDB, err := db.DB()
 	if err != nil {
 		t.Fatal(err)
 	}
+	sqlDB.SetMaxOpenConns(1)
 
-	s, err := NewStrategy(scheme.Scheme, &corev1.Pod{}, "pod", db, nil, false)
+	err = db.Table(table).AutoMigrate(&Record{})
 	if err != nil {
 		t.Fatal(err)
 	}
-	return s
+
+	// the in-memory database is dropped once its last connection is closed, cleanups run in reverse order so this
+	// runs after the strategy is destroyed
+	t.Cleanup(func() {
+		_ = sqlDB.Close()
+	})
+	return db
 }
 
 func TestGet(t *testing.T) {
@@ -94,6 +142,197 @@ func TestCreate(t *testing.T) {
 	assert.True(t, apierrors.IsAlreadyExists(err))
 }
 
+func TestUnstructured(t *testing.T) {
+	widget := &unstructured.Unstructured{}
+	widget.SetGroupVersionKind(schema.GroupVersionKind{Group: "example.com", Version: "v1", Kind: "Widget"})
+	store, err := NewStrategy(scheme.Scheme, widget, "widget", newTestDB(t, "widget"), nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(store.Destroy)
+
+	obj := store.New().(*unstructured.Unstructured)
+	obj.SetName("test-name")
+	obj.SetNamespace("test-namespace")
+	_ = unstructured.SetNestedField(obj.Object, "blue", "spec", "color")
+	if _, err := store.Create(context.Background(), obj); err != nil {
+		t.Fatal(err)
+	}
+
+	newObj, err := store.Get(context.Background(), "test-namespace", "test-name")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := newObj.(*unstructured.Unstructured)
+	assert.Equal(t, "Widget", got.GetKind())
+	assert.Equal(t, obj.GetUID(), got.GetUID())
+	color, _, _ := unstructured.NestedString(got.Object, "spec", "color")
+	assert.Equal(t, "blue", color)
+
+	list, err := store.List(context.Background(), "test-namespace", storage.ListOptions{Predicate: storage.Everything})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "WidgetList", list.GetObjectKind().GroupVersionKind().Kind)
+	assert.Len(t, list.(*unstructured.UnstructuredList).Items, 1)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events, err := store.Watch(ctx, "test-namespace", storage.ListOptions{Predicate: storage.Everything})
+	if err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case event := <-events:
+		assert.Equal(t, watch.Added, event.Type)
+		assert.Equal(t, "test-name", event.Object.(*unstructured.Unstructured).GetName())
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for watch event")
+	}
+}
+
+func TestVersionStrategy(t *testing.T) {
+	widget := &unstructured.Unstructured{}
+	widget.SetGroupVersionKind(schema.GroupVersionKind{Group: "example.com", Version: "v1", Kind: "Widget"})
+	hub, err := NewStrategy(scheme.Scheme, widget, "widget", newTestDB(t, "widget"), nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(hub.Destroy)
+
+	// v2 renames spec.color to spec.colour
+	widgetV2 := &unstructured.Unstructured{}
+	widgetV2.SetGroupVersionKind(schema.GroupVersionKind{Group: "example.com", Version: "v2", Kind: "Widget"})
+	rename := func(from, to string) translation.ConverterFunc {
+		return func(_ context.Context, in, out runtime.Object) error {
+			obj := in.(*unstructured.Unstructured).DeepCopy()
+			color, _, _ := unstructured.NestedString(obj.Object, "spec", from)
+			unstructured.RemoveNestedField(obj.Object, "spec", from)
+			_ = unstructured.SetNestedField(obj.Object, color, "spec", to)
+			out.(*unstructured.Unstructured).Object = obj.Object
+			return nil
+		}
+	}
+	v2 := translation.NewVersionStrategy(widgetV2, hub, translation.ConverterFunc(func(ctx context.Context, in, out runtime.Object) error {
+		if in.GetObjectKind().GroupVersionKind().Version == "v1" {
+			return rename("color", "colour")(ctx, in, out)
+		}
+		return rename("colour", "color")(ctx, in, out)
+	}))
+
+	obj := v2.New().(*unstructured.Unstructured)
+	obj.SetName("test-name")
+	obj.SetNamespace("test-namespace")
+	_ = unstructured.SetNestedField(obj.Object, "blue", "spec", "colour")
+	created, err := v2.Create(context.Background(), obj)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "example.com/v2", created.(*unstructured.Unstructured).GetAPIVersion())
+
+	// the hub version is stored and shares the UID and resource version
+	stored, err := hub.Get(context.Background(), "test-namespace", "test-name")
+	if err != nil {
+		t.Fatal(err)
+	}
+	color, _, _ := unstructured.NestedString(stored.(*unstructured.Unstructured).Object, "spec", "color")
+	assert.Equal(t, "blue", color)
+	assert.Equal(t, created.GetUID(), stored.GetUID())
+	assert.Equal(t, created.GetResourceVersion(), stored.GetResourceVersion())
+
+	list, err := v2.List(context.Background(), "test-namespace", storage.ListOptions{Predicate: storage.Everything})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "WidgetList", list.GetObjectKind().GroupVersionKind().Kind)
+	if items := list.(*unstructured.UnstructuredList).Items; assert.Len(t, items, 1) {
+		colour, _, _ := unstructured.NestedString(items[0].Object, "spec", "colour")
+		assert.Equal(t, "blue", colour)
+		assert.Equal(t, "example.com/v2", items[0].GetAPIVersion())
+	}
+}
+
+func TestFieldOwnership(t *testing.T) {
+	store := newTestStore(t, WithFieldOwnership())
+	alice := request.WithUser(context.Background(), &user.DefaultInfo{Name: "alice"})
+	bob := request.WithUser(context.Background(), &user.DefaultInfo{Name: "bob"})
+
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-name",
+			Namespace: "test-namespace",
+		},
+		Spec: corev1.PodSpec{
+			NodeName: "node1",
+		},
+	}
+	if _, err := store.Create(alice, pod); err != nil {
+		t.Fatal(err)
+	}
+	owners, err := GetFieldOwners(pod)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "alice", owners["spec.nodeName"].Manager)
+
+	stale := pod.DeepCopy()
+	pod.Spec.NodeName = "node2"
+	if _, err := store.Update(bob, pod); err != nil {
+		t.Fatal(err)
+	}
+	owners, err = GetFieldOwners(pod)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "bob", owners["spec.nodeName"].Manager)
+	assert.Equal(t, "alice", owners["spec.containers"].Manager)
+
+	stale.Spec.Hostname = "test"
+	_, err = store.Update(alice, stale)
+	assert.True(t, apierrors.IsConflict(err))
+	assert.True(t, errtypes.IsRetryable(err))
+	assert.Contains(t, err.Error(), "spec.nodeName last changed by bob")
+	assert.NotContains(t, err.Error(), "spec.containers")
+}
+
+func TestUserTracking(t *testing.T) {
+	store := newTestStore(t, WithUserTracking())
+	// only tables tracking users have the columns
+	migrator := store.db.(*GormDB).db.Table("pod").Migrator()
+	assert.True(t, migrator.HasColumn(&userColumns{}, "created_by"))
+	assert.False(t, migrator.HasColumn(&globalSequenceColumn{}, "global_sequence"))
+	alice := request.WithUser(context.Background(), &user.DefaultInfo{Name: "alice"})
+	bob := request.WithUser(context.Background(), &user.DefaultInfo{Name: "bob"})
+
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-name",
+			Namespace: "test-namespace",
+		},
+	}
+	if _, err := store.Create(alice, pod); err != nil {
+		t.Fatal(err)
+	}
+	created := pod.GetResourceVersion()
+	assert.Equal(t, "alice", pod.Annotations[CreatedByAnnotation])
+	assert.Equal(t, "alice", pod.Annotations[UpdatedByAnnotation])
+
+	// clients can't set the users, the annotations they send back are replaced
+	pod.Annotations[CreatedByAnnotation] = "mallory"
+	pod.Spec.NodeName = "node1"
+	if _, err := store.Update(bob, pod); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "alice", pod.Annotations[CreatedByAnnotation])
+	assert.Equal(t, "bob", pod.Annotations[UpdatedByAnnotation])
+
+	revision, err := store.GetRevision(context.Background(), "test-namespace", "test-name", created)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "alice", revision.GetAnnotations()[UpdatedByAnnotation])
+}
+
 func TestLabels(t *testing.T) {
 	store := newTestStore(t)
 	for i := range []*struct{}{nil, nil, nil} {
@@ -253,3 +492,2154 @@ func TestUpdate(t *testing.T) {
 	assert.Equal(t, "", pod.Status.Message)
 	assert.Equal(t, pod.UID, newPod.UID)
 }
+
+func TestUpdateConflictError(t *testing.T) {
+	store := newTestStore(t)
+	created, err := store.Create(context.Background(), &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-name",
+			Namespace: "test-namespace",
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := store.Update(context.Background(), created.DeepCopyObject().(*corev1.Pod)); err != nil {
+		t.Fatal(err)
+	}
+	_, err = store.Update(context.Background(), created.DeepCopyObject().(*corev1.Pod))
+
+	var mismatch *errtypes.ResourceVersionMismatchError
+	assert.True(t, errors.As(err, &mismatch))
+	assert.Equal(t, "test-name", mismatch.Name)
+	assert.True(t, errors.Is(err, errtypes.ErrResourceVersionMismatch))
+	assert.True(t, errtypes.IsRetryable(err))
+	assert.True(t, apierrors.IsConflict(err))
+}
+
+func TestWatchResourceVersion(t *testing.T) {
+	store := newTestStore(t)
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-name",
+			Namespace: "test-namespace",
+		},
+	}
+	obj, err := store.Create(context.Background(), pod)
+	if err != nil {
+		t.Fatal(err)
+	}
+	created := obj.(*corev1.Pod)
+
+	updated := created.DeepCopy()
+	updated.Spec.NodeName = "test"
+	if _, err := store.Update(context.Background(), updated); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := store.Create(context.Background(), &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-name2",
+			Namespace: "test-namespace",
+		},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, rv := range []string{"", "0"} {
+		events := watchEvents(t, store, rv, 2)
+		assert.Equal(t, watch.Added, events[0].Type)
+		assert.Equal(t, "test-name", events[0].Object.(*corev1.Pod).Name)
+		assert.Equal(t, "test", events[0].Object.(*corev1.Pod).Spec.NodeName)
+		assert.Equal(t, watch.Added, events[1].Type)
+		assert.Equal(t, "test-name2", events[1].Object.(*corev1.Pod).Name)
+	}
+
+	events := watchEvents(t, store, created.ResourceVersion, 2)
+	assert.Equal(t, watch.Modified, events[0].Type)
+	assert.Equal(t, "test-name", events[0].Object.(*corev1.Pod).Name)
+	assert.Equal(t, watch.Added, events[1].Type)
+	assert.Equal(t, "test-name2", events[1].Object.(*corev1.Pod).Name)
+
+	_, err = store.Watch(context.Background(), "", storage.ListOptions{
+		ResourceVersion: "invalid",
+		Predicate:       storage.Everything,
+	})
+	assert.True(t, apierrors.IsBadRequest(err))
+}
+
+func TestWatchProgress(t *testing.T) {
+	store := newTestStore(t, WithBookmarkInterval(time.Hour))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	w, err := store.Watch(ctx, "", storage.ListOptions{
+		Predicate: storage.SelectionPredicate{
+			Label:               labels.Everything(),
+			Field:               fields.Everything(),
+			AllowWatchBookmarks: true,
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	obj, err := store.Create(ctx, &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-name",
+			Namespace: "test-namespace",
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if event := <-w; event.Type != watch.Added {
+		t.Fatalf("expected the pod to be added, got %s", event.Type)
+	}
+
+	if err := store.RequestWatchProgress(ctx); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case event := <-w:
+		assert.Equal(t, watch.Bookmark, event.Type)
+		assert.Equal(t, obj.(*corev1.Pod).ResourceVersion, event.Object.(*corev1.Pod).ResourceVersion)
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the bookmark")
+	}
+}
+
+func TestWatchAdapterRequestsProgress(t *testing.T) {
+	store := newTestStore(t, WithBookmarkInterval(time.Hour))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	obj, err := store.Create(ctx, &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-name",
+			Namespace: "test-namespace",
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resourceVersion := obj.(*corev1.Pod).ResourceVersion
+
+	// a watch that allows bookmarks gets one with the latest resource version without waiting for the interval
+	w, err := strategy.NewWatch(store).Watch(ctx, &metainternalversion.ListOptions{
+		ResourceVersion:     resourceVersion,
+		AllowWatchBookmarks: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Stop()
+	select {
+	case event := <-w.ResultChan():
+		assert.Equal(t, watch.Bookmark, event.Type)
+		assert.Equal(t, resourceVersion, event.Object.(*corev1.Pod).ResourceVersion)
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the bookmark")
+	}
+}
+
+func TestWatchAdapterCoalesces(t *testing.T) {
+	store := newTestStore(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// the delay never runs out, so held back events are only sent ahead of other events
+	watcher := strategy.NewWatch(store)
+	watcher.CoalesceDelay = time.Hour
+	w, err := watcher.Watch(ctx, &metainternalversion.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Stop()
+
+	nextEvent := func() watch.Event {
+		t.Helper()
+		select {
+		case event := <-w.ResultChan():
+			return event
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for an event")
+			return watch.Event{}
+		}
+	}
+
+	obj, err := store.Create(ctx, &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-name",
+			Namespace: "test-namespace",
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, watch.Added, nextEvent().Type)
+
+	for i := 0; i < 3; i++ {
+		obj.(*corev1.Pod).Labels = map[string]string{"update": strconv.Itoa(i)}
+		obj, err = store.Update(ctx, obj.(*corev1.Pod))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := store.Create(ctx, &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "other-name",
+			Namespace: "test-namespace",
+		},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	// the three updates collapse into the last one, which is sent before the addition that follows them
+	event := nextEvent()
+	assert.Equal(t, watch.Modified, event.Type)
+	assert.Equal(t, obj.(*corev1.Pod).ResourceVersion, event.Object.(*corev1.Pod).ResourceVersion)
+	event = nextEvent()
+	assert.Equal(t, watch.Added, event.Type)
+	assert.Equal(t, "other-name", event.Object.(*corev1.Pod).Name)
+
+	// a watch with the delay overridden to zero gets every update
+	w2, err := watcher.Watch(strategy.WithWatchCoalesceDelay(ctx, 0), &metainternalversion.ListOptions{
+		ResourceVersion: event.Object.(*corev1.Pod).ResourceVersion,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w2.Stop()
+	for i := 0; i < 2; i++ {
+		obj.(*corev1.Pod).Labels = map[string]string{"again": strconv.Itoa(i)}
+		obj, err = store.Update(ctx, obj.(*corev1.Pod))
+		if err != nil {
+			t.Fatal(err)
+		}
+		select {
+		case event := <-w2.ResultChan():
+			assert.Equal(t, watch.Modified, event.Type)
+			assert.Equal(t, obj.(*corev1.Pod).ResourceVersion, event.Object.(*corev1.Pod).ResourceVersion)
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for an uncoalesced event")
+		}
+	}
+
+	// with a short delay a held back update is sent once the delay runs out
+	watcher.CoalesceDelay = 10 * time.Millisecond
+	w3, err := watcher.Watch(ctx, &metainternalversion.ListOptions{ResourceVersion: obj.(*corev1.Pod).ResourceVersion})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w3.Stop()
+	obj.(*corev1.Pod).Labels = map[string]string{"last": "true"}
+	obj, err = store.Update(ctx, obj.(*corev1.Pod))
+	if err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case event := <-w3.ResultChan():
+		assert.Equal(t, watch.Modified, event.Type)
+		assert.Equal(t, obj.(*corev1.Pod).ResourceVersion, event.Object.(*corev1.Pod).ResourceVersion)
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the delayed event")
+	}
+}
+
+func TestWatchAdapterPaces(t *testing.T) {
+	store := newTestStore(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	watcher := strategy.NewWatch(store)
+	watcher.Rate = strategy.WatchRate{EventsPerSecond: 20, Burst: 1}
+	w, err := watcher.Watch(ctx, &metainternalversion.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Stop()
+
+	nextEvent := func(w watch.Interface) watch.Event {
+		t.Helper()
+		select {
+		case event, ok := <-w.ResultChan():
+			if !ok {
+				t.Fatal("the watch ended")
+			}
+			return event
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for an event")
+			return watch.Event{}
+		}
+	}
+	create := func(name string) minktypes.Object {
+		t.Helper()
+		obj, err := store.Create(ctx, &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: "test-namespace",
+			},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return obj
+	}
+
+	// events beyond the burst wait for the limit
+	start := time.Now()
+	for i := 0; i < 5; i++ {
+		create("paced-" + strconv.Itoa(i))
+	}
+	var resourceVersion string
+	for i := 0; i < 5; i++ {
+		event := nextEvent(w)
+		assert.Equal(t, watch.Added, event.Type)
+		assert.Equal(t, "paced-"+strconv.Itoa(i), event.Object.(*corev1.Pod).Name)
+		resourceVersion = event.Object.(*corev1.Pod).ResourceVersion
+	}
+	assert.GreaterOrEqual(t, time.Since(start), 150*time.Millisecond)
+
+	// held back modifications of an object collapse into the latest, which moves behind the events of other objects
+	slow, err := watcher.Watch(strategy.WithWatchRate(ctx, strategy.WatchRate{EventsPerSecond: 4, Burst: 1}),
+		&metainternalversion.ListOptions{ResourceVersion: resourceVersion})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer slow.Stop()
+	create("first")
+	assert.Equal(t, "first", nextEvent(slow).Object.(*corev1.Pod).Name)
+	obj := create("changing")
+	create("other")
+	for i := 0; i < 10; i++ {
+		obj.(*corev1.Pod).Labels = map[string]string{"update": strconv.Itoa(i)}
+		obj, err = store.Update(ctx, obj.(*corev1.Pod))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	event := nextEvent(slow)
+	assert.Equal(t, "other", event.Object.(*corev1.Pod).Name)
+	event = nextEvent(slow)
+	assert.Equal(t, watch.Added, event.Type)
+	assert.Equal(t, obj.GetResourceVersion(), event.Object.(*corev1.Pod).ResourceVersion)
+	assert.Equal(t, "9", event.Object.(*corev1.Pod).Labels["update"])
+	select {
+	case event := <-slow.ResultChan():
+		t.Fatalf("unexpected event %s of %s", event.Type, event.Object.(*corev1.Pod).Name)
+	case <-time.After(500 * time.Millisecond):
+	}
+
+	// a watch that holds back too many events expires
+	w2, err := watcher.Watch(strategy.WithWatchRate(ctx, strategy.WatchRate{EventsPerSecond: 0.1, MaxPending: 2}),
+		&metainternalversion.ListOptions{ResourceVersion: obj.GetResourceVersion()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w2.Stop()
+	create("expired")
+	assert.Equal(t, watch.Added, nextEvent(w2).Type)
+	for i := 0; i < 3; i++ {
+		create("expired-" + strconv.Itoa(i))
+	}
+	event = nextEvent(w2)
+	if assert.Equal(t, watch.Error, event.Type) {
+		assert.True(t, apierrors.IsResourceExpired(apierrors.FromObject(event.Object)))
+	}
+	select {
+	case _, ok := <-w2.ResultChan():
+		assert.False(t, ok, "expected the watch to end")
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the watch to end")
+	}
+}
+
+func TestTerminateWatches(t *testing.T) {
+	store := newTestStore(t, WithBookmarkInterval(time.Hour))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	obj, err := store.Create(ctx, &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-name",
+			Namespace: "test-namespace",
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	w, err := store.Watch(ctx, "", storage.ListOptions{
+		ResourceVersion: obj.(*corev1.Pod).ResourceVersion,
+		Predicate: storage.SelectionPredicate{
+			Label:               labels.Everything(),
+			Field:               fields.Everything(),
+			AllowWatchBookmarks: true,
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	obj.(*corev1.Pod).Labels = map[string]string{"updated": "true"}
+	updated, err := store.Update(ctx, obj.(*corev1.Pod))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if event := <-w; event.Type != watch.Modified {
+		t.Fatalf("expected the pod to be modified, got %s", event.Type)
+	}
+
+	store.TerminateWatches()
+	var events []watch.Event
+	timeout := time.After(5 * time.Second)
+	for done := false; !done; {
+		select {
+		case event, ok := <-w:
+			if ok {
+				events = append(events, event)
+			}
+			done = !ok
+		case <-timeout:
+			t.Fatal("timed out waiting for the watch to end")
+		}
+	}
+	if assert.Len(t, events, 1) {
+		assert.Equal(t, watch.Bookmark, events[0].Type)
+		assert.Equal(t, updated.(*corev1.Pod).ResourceVersion, events[0].Object.(*corev1.Pod).ResourceVersion)
+	}
+}
+
+func watchEvents(t *testing.T, store *Strategy, resourceVersion string, count int) []watch.Event {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	c, err := store.Watch(ctx, "", storage.ListOptions{
+		ResourceVersion: resourceVersion,
+		Predicate:       storage.Everything,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var events []watch.Event
+	for len(events) < count {
+		select {
+		case event := <-c:
+			events = append(events, event)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out waiting for watch events with resource version %q, got %d of %d", resourceVersion,
+				len(events), count)
+		}
+	}
+	return events
+}
+
+func TestConformance(t *testing.T) {
+	strategytest.Run(t, func(t *testing.T) strategy.CompleteStrategy {
+		return newTestStore(t)
+	})
+}
+
+func TestStress(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping stress test in short mode")
+	}
+	// The verifier reads every event of a burst of thousands of writes, the default buffer must hold them.
+	stresstest.Run(t, newTestStore(t), stresstest.Options{})
+}
+
+func TestOptionsFromEnv(t *testing.T) {
+	t.Setenv("TEST_MAX_OPEN_CONNS", "20")
+	t.Setenv("TEST_CONN_MAX_IDLE_TIME", "1m")
+	t.Setenv("TEST_MIGRATION_TIMEOUT", "5m")
+	t.Setenv("TEST_COMPACT_RETAIN", "10")
+	t.Setenv("TEST_COMPACT_RETAIN_POD", "20")
+	t.Setenv("TEST_GC_INTERVAL_SECONDS_pod", "60")
+
+	opts, err := OptionsFromEnv(context.Background(), "TEST_")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := &Factory{}
+	for _, opt := range opts {
+		opt(f)
+	}
+	assert.Equal(t, 20, f.pool.MaxOpenConns)
+	assert.Equal(t, time.Minute, f.pool.ConnMaxIdleTime)
+	assert.Equal(t, 5*time.Minute, f.migrationTimeout)
+
+	for table, expected := range map[string]*GormDB{
+		"pod":  {compactRetain: 20, deleteRetain: defaultDeleteRetainCount, gcInterval: time.Minute},
+		"node": {compactRetain: 10, deleteRetain: defaultDeleteRetainCount, gcInterval: defaultGCInterval},
+	} {
+		g := NewDB(table, corev1.SchemeGroupVersion.WithKind("Pod"), nil, nil)
+		s := &Strategy{db: g}
+		for _, opt := range f.strategyOptions {
+			opt(s)
+		}
+		assert.Equal(t, expected.compactRetain, g.compactRetain, table)
+		assert.Equal(t, expected.deleteRetain, g.deleteRetain, table)
+		assert.Equal(t, expected.gcInterval, g.gcInterval, table)
+	}
+
+	t.Setenv("TEST_DELETE_RETAIN", "lots")
+	_, err = OptionsFromEnv(context.Background(), "TEST_")
+	assert.Error(t, err)
+}
+
+func TestPoolOptions(t *testing.T) {
+	f := &Factory{}
+	for _, opt := range []FactoryOption{
+		WithMaxIdleConns(-1),
+		WithDialectPoolOptions("postgres", PoolOptions{MaxOpenConns: 20, MaxIdleConns: 10, ConnMaxIdleTime: time.Minute}),
+	} {
+		opt(f)
+	}
+
+	assert.Equal(t, PoolOptions{MaxOpenConns: 20, MaxIdleConns: -1, ConnMaxLifetime: 3 * time.Minute,
+		ConnMaxIdleTime: time.Minute}, f.poolOptions("postgres"))
+	assert.Equal(t, PoolOptions{MaxOpenConns: 5, MaxIdleConns: -1, ConnMaxLifetime: 3 * time.Minute},
+		f.poolOptions("oracle"))
+	assert.Equal(t, PoolOptions{MaxOpenConns: 1, MaxIdleConns: 1, ConnMaxLifetime: 3 * time.Minute},
+		f.poolOptions("sqlite"))
+}
+
+func TestDefaultQueryClassifier(t *testing.T) {
+	for _, test := range []struct {
+		name  string
+		user  user.Info
+		class QueryClass
+	}{
+		{name: "no user", class: QueryClassUser},
+		{name: "user", user: &user.DefaultInfo{Name: "alice", Groups: []string{user.AllAuthenticated}}, class: QueryClassUser},
+		{name: "service account", user: &user.DefaultInfo{Name: "system:serviceaccount:default:default"}, class: QueryClassSystem},
+		{name: "cluster admin", user: &user.DefaultInfo{Name: "admin", Groups: []string{user.SystemPrivilegedGroup}}, class: QueryClassSystem},
+		{name: "anonymous", user: &user.DefaultInfo{Name: user.Anonymous, Groups: []string{user.AllUnauthenticated}}, class: QueryClassUser},
+		{name: "unauthenticated", user: &user.DefaultInfo{Name: "system:unknown", Groups: []string{user.AllUnauthenticated}}, class: QueryClassUser},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if test.user != nil {
+				req = req.WithContext(request.WithUser(req.Context(), test.user))
+			}
+			assert.Equal(t, test.class, DefaultQueryClassifier(req))
+		})
+	}
+}
+
+func TestDestroyFactory(t *testing.T) {
+	f, err := NewFactory(scheme.Scheme, "sqlite://file:"+t.Name()+"?mode=memory&cache=shared")
+	if err != nil {
+		t.Fatal(err)
+	}
+	classDB, classSQLDB, _, err := openDB("sqlite://file:" + t.Name() + "?mode=memory&cache=shared")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.QueryClassDBs = map[QueryClass]*gorm.DB{QueryClassUser: classDB}
+
+	f.Destroy()
+	assert.Error(t, f.SQLDB.Ping())
+	assert.Error(t, classSQLDB.Ping())
+}
+
+func TestGCOptions(t *testing.T) {
+	f := &Factory{}
+	for _, opt := range []FactoryOption{
+		WithStrategyOptions(WithGCOptions(GCOptions{CompactRetain: 5, DeleteBatchSize: 10})),
+		WithTableGCOptions("Node", GCOptions{DisableDeletion: true}),
+	} {
+		opt(f)
+	}
+
+	dbs := map[string]*GormDB{}
+	for _, table := range []string{"pod", "node"} {
+		g := NewDB(table, corev1.SchemeGroupVersion.WithKind("Pod"), nil, nil)
+		s := &Strategy{db: g}
+		for _, opt := range f.strategyOptions {
+			opt(s)
+		}
+		f.strategies = append(f.strategies, s)
+		dbs[table] = g
+	}
+
+	assert.Equal(t, gcConfig{
+		compactRetain:    5,
+		deleteRetain:     defaultDeleteRetainCount,
+		interval:         defaultGCInterval,
+		compactBatchSize: defaultCompactBatchSize,
+		deleteBatchSize:  10,
+	}, dbs["pod"].gcSettings())
+	assert.Equal(t, 0, dbs["node"].gcSettings().deleteRetain)
+
+	batchSize := 50
+	assert.True(t, f.SetTableRuntimeSettings("pod", RuntimeSettings{CompactBatchSize: &batchSize}))
+	assert.False(t, f.SetTableRuntimeSettings("missing", RuntimeSettings{}))
+	assert.Equal(t, 50, dbs["pod"].gcSettings().compactBatchSize)
+	assert.Equal(t, defaultCompactBatchSize, dbs["node"].gcSettings().compactBatchSize)
+}
+
+func TestSQLLogSettings(t *testing.T) {
+	var (
+		lock     sync.Mutex
+		messages []string
+	)
+	log := logging.Logr(funcr.New(func(_, args string) {
+		lock.Lock()
+		defer lock.Unlock()
+		messages = append(messages, args)
+	}, funcr.Options{}))
+	logged := func(message string) bool {
+		lock.Lock()
+		defer lock.Unlock()
+		for _, m := range messages {
+			if strings.Contains(m, message) && strings.Contains(m, `"table"="pod"`) {
+				return true
+			}
+		}
+		return false
+	}
+
+	store := newTestStore(t, WithLogger(log))
+	trace := logrus.TraceLevel
+	store.SetRuntimeSettings(RuntimeSettings{SQLLogLevel: &trace})
+	if _, err := store.Get(context.Background(), "test-namespace", "missing"); !apierrors.IsNotFound(err) {
+		t.Fatal(err)
+	}
+	assert.True(t, logged("sql query executed"))
+
+	errorLevel := logrus.ErrorLevel
+	slow := time.Nanosecond
+	store.SetRuntimeSettings(RuntimeSettings{SQLLogLevel: &errorLevel, SlowQueryThreshold: &slow})
+	lock.Lock()
+	messages = nil
+	lock.Unlock()
+	if _, err := store.Get(context.Background(), "test-namespace", "missing"); !apierrors.IsNotFound(err) {
+		t.Fatal(err)
+	}
+	assert.False(t, logged("sql query"))
+
+	infoLevel := logrus.InfoLevel
+	store.SetRuntimeSettings(RuntimeSettings{SQLLogLevel: &infoLevel, SlowQueryThreshold: &slow})
+	if _, err := store.Get(context.Background(), "test-namespace", "missing"); !apierrors.IsNotFound(err) {
+		t.Fatal(err)
+	}
+	assert.True(t, logged("sql query slow"))
+}
+
+func TestRequestDeadline(t *testing.T) {
+	store := newTestStore(t)
+
+	ctx, cancel := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancel()
+	_, err := store.List(ctx, "", storage.ListOptions{Predicate: storage.Everything})
+	assert.True(t, apierrors.IsTimeout(err), "expected timeout, got %v", err)
+
+	// a watch whose client disconnects while it is initializing is closed
+	ctx, cancel = context.WithCancel(context.Background())
+	events, err := store.Watch(ctx, "", storage.ListOptions{Predicate: storage.Everything})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cancel()
+	select {
+	case _, ok := <-events:
+		for ok {
+			_, ok = <-events
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("watch was not closed after its context was canceled")
+	}
+}
+
+func TestPartitionAssigner(t *testing.T) {
+	store := newTestStore(t, WithPartitionAssigner(func(_ context.Context, obj minktypes.Object, partitionID string) (string, error) {
+		if tenant := obj.GetLabels()["tenant"]; tenant != "" {
+			return tenant, nil
+		}
+		return partitionID, nil
+	}))
+
+	created, err := store.Create(context.Background(), &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-name",
+			Namespace: "test-namespace",
+			Labels: map[string]string{
+				"tenant": "tenant-a",
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = store.Get(ContextWithPartitionID(context.Background(), "tenant-b"), "test-namespace", "test-name")
+	assert.True(t, apierrors.IsNotFound(err), "expected not found, got %v", err)
+	obj, err := store.Get(ContextWithPartitionID(context.Background(), "tenant-a"), "test-namespace", "test-name")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, created.GetUID(), obj.GetUID())
+
+	moved := obj.DeepCopyObject().(*corev1.Pod)
+	moved.Labels["tenant"] = "tenant-b"
+	_, err = store.Update(context.Background(), moved)
+	assert.True(t, apierrors.IsBadRequest(err), "expected bad request, got %v", err)
+
+	obj.(*corev1.Pod).Spec.NodeName = "node"
+	_, err = store.Update(context.Background(), obj)
+	assert.NoError(t, err)
+}
+
+func TestPartitionLabel(t *testing.T) {
+	store := newTestStore(t, WithPartitionLabel("tenant"))
+	ctx := ContextWithPartitionID(context.Background(), "tenant-a")
+
+	pod, err := store.Create(ctx, &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-name",
+			Namespace: "test-namespace",
+			Labels: map[string]string{
+				"tenant": "tenant-b",
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Empty(t, pod.GetLabels())
+
+	records, _, err := store.db.Get(ctx, Criteria{Name: "test-name", Namespace: strptr("test-namespace"), PartitionID: "tenant-a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if assert.Len(t, records, 1) {
+		assert.JSONEq(t, `{"labels":{"tenant":"tenant-a"}}`, string(records[0].Metadata))
+	}
+
+	for tenant, count := range map[string]int{"tenant-a": 1, "tenant-b": 0} {
+		result, err := store.List(ctx, "", storage.ListOptions{
+			Predicate: storage.SelectionPredicate{
+				Label:    labels.SelectorFromSet(labels.Set{"tenant": tenant}),
+				GetAttrs: storage.DefaultNamespaceScopedAttr,
+			},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Len(t, result.(*corev1.PodList).Items, count, tenant)
+	}
+}
+
+func TestPartitionMiddleware(t *testing.T) {
+	var served string
+	handler := PartitionMiddleware(PartitionOptions{
+		Sources:  []PartitionSource{PartitionFromPathPrefix("partitions"), PartitionFromHeader("X-Partition")},
+		Required: true,
+	})(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		partitionID := PartitionIDFromContext(req.Context())
+		served = partitionID + " " + req.URL.Path
+		rw.WriteHeader(http.StatusOK)
+	}))
+
+	for _, test := range []struct {
+		name, path, header, served, message string
+	}{
+		{name: "path prefix", path: "/partitions/p1/apis/example.com/v1/things", served: "p1 /apis/example.com/v1/things"},
+		{name: "header", path: "/api/v1/pods", header: "p2", served: "p2 /api/v1/pods"},
+		{name: "missing", path: "/apis/example.com/v1/namespaces/default/things", message: "partition ID is required"},
+		{name: "conflicting", path: "/partitions/p1/api/v1/pods", header: "p2", message: `conflicting partition IDs "p1" and "p2"`},
+		{name: "invalid", path: "/api/v1/pods", header: "P_1", message: `invalid partition ID "P_1"`},
+		// the API server hasn't parsed these requests yet, the middleware recognizes them as non-resource requests
+		{name: "healthz", path: "/healthz", served: " /healthz"},
+		{name: "readyz", path: "/readyz", served: " /readyz"},
+		{name: "openapi", path: "/openapi/v2", served: " /openapi/v2"},
+		{name: "discovery", path: "/apis/example.com/v1", served: " /apis/example.com/v1"},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			served = ""
+			req := httptest.NewRequest(http.MethodGet, test.path, nil)
+			if test.header != "" {
+				req.Header.Set("X-Partition", test.header)
+			}
+			rw := httptest.NewRecorder()
+			handler.ServeHTTP(rw, req)
+
+			if test.message == "" {
+				assert.Equal(t, http.StatusOK, rw.Code)
+				assert.Equal(t, test.served, served)
+				return
+			}
+			assert.Equal(t, http.StatusBadRequest, rw.Code)
+			assert.Empty(t, served)
+			status := &metav1.Status{}
+			if assert.NoError(t, json.Unmarshal(rw.Body.Bytes(), status)) {
+				assert.Equal(t, metav1.StatusReasonBadRequest, status.Reason)
+				assert.Contains(t, status.Message, test.message)
+			}
+		})
+	}
+
+	// the RequestInfo of the API server takes precedence when the middleware runs inside the handler chain
+	served = ""
+	req := httptest.NewRequest(http.MethodGet, "/apis/example.com/v1/things", nil)
+	req = req.WithContext(request.WithRequestInfo(req.Context(), &request.RequestInfo{}))
+	rw := httptest.NewRecorder()
+	handler.ServeHTTP(rw, req)
+	assert.Equal(t, http.StatusOK, rw.Code)
+	assert.Equal(t, " /apis/example.com/v1/things", served)
+}
+
+func TestIndexAdvisor(t *testing.T) {
+	store := newTestStore(t, WithIndexAdvisor(IndexAdvisorConfig{
+		MinQueries: 2,
+		MinAverage: time.Nanosecond,
+	}))
+	g := store.db.(*GormDB)
+
+	for i, app := range []string{"one", "two"} {
+		_, err := store.Create(context.Background(), &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      fmt.Sprintf("pod%d", i),
+				Namespace: "test-namespace",
+				Labels: map[string]string{
+					"app.kubernetes.io/name": app,
+				},
+			},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	opts := storage.ListOptions{
+		Predicate: storage.SelectionPredicate{
+			Label:    labels.SelectorFromSet(labels.Set{"app.kubernetes.io/name": "two"}),
+			GetAttrs: storage.DefaultNamespaceScopedAttr,
+		},
+	}
+	list := func() {
+		t.Helper()
+		result, err := store.List(context.Background(), "", opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pods := result.(*corev1.PodList).Items
+		if assert.Len(t, pods, 1) {
+			assert.Equal(t, "pod1", pods[0].Name)
+		}
+	}
+
+	list()
+	assert.Empty(t, g.IndexRecommendations())
+	list()
+
+	recommendations := g.IndexRecommendations()
+	if !assert.Len(t, recommendations, 1) {
+		return
+	}
+	assert.Equal(t, SelectorTypeLabel, recommendations[0].Type)
+	assert.Equal(t, "app.kubernetes.io/name", recommendations[0].Key)
+	assert.Equal(t, uint64(2), recommendations[0].Queries)
+
+	if err := g.CreateIndex(context.Background(), recommendations[0]); err != nil {
+		t.Fatal(err)
+	}
+	assert.Empty(t, g.IndexRecommendations())
+
+	// a restarted server finds the column
+	g.columns = map[string]bool{}
+	if err := g.refreshColumns(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	assert.True(t, g.columns[recommendations[0].Column])
+	list()
+}
+
+func TestSequenceTable(t *testing.T) {
+	store := newTestStore(t, WithIDAllocator(NewSequenceTable()))
+	g := store.db.(*GormDB)
+	ctx := context.Background()
+
+	create := func(name string) string {
+		t.Helper()
+		pod := &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: "test-namespace",
+			},
+		}
+		if _, err := store.Create(ctx, pod); err != nil {
+			t.Fatal(err)
+		}
+		return pod.ResourceVersion
+	}
+
+	assert.Equal(t, "1", create("pod1"))
+
+	// a rolled back write gives its ID back
+	err := g.Transaction(ctx, func(ctx context.Context) error {
+		if err := g.Insert(ctx, &Record{Name: "pod2", Namespace: "test-namespace"}); err != nil {
+			return err
+		}
+		return errors.New("rollback")
+	})
+	assert.EqualError(t, err, "rollback")
+	assert.Equal(t, "2", create("pod2"))
+
+	// IDs of fill records are never allocated
+	g.fill(ctx, 5)
+	assert.Equal(t, "6", create("pod3"))
+}
+
+func TestAfterCommit(t *testing.T) {
+	store := newTestStore(t)
+	g := store.db.(*GormDB)
+
+	var committed []string
+	err := g.Transaction(context.Background(), func(ctx context.Context) error {
+		g.afterCommit(ctx, func() {
+			committed = append(committed, "outer")
+		})
+		err := g.Transaction(ctx, func(ctx context.Context) error {
+			g.afterCommit(ctx, func() {
+				committed = append(committed, "nested")
+			})
+			return nil
+		})
+		assert.Empty(t, committed, "hooks must not run before the outer transaction commits")
+		return err
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"outer", "nested"}, committed)
+
+	committed = nil
+	err = g.Transaction(context.Background(), func(ctx context.Context) error {
+		g.afterCommit(ctx, func() {
+			committed = append(committed, "rolled back")
+		})
+		return errors.New("rollback")
+	})
+	assert.Error(t, err)
+	assert.Empty(t, committed)
+}
+
+func TestGlobalSequence(t *testing.T) {
+	db := newTestDB(t, "pod")
+	if err := db.Table("configmap").AutoMigrate(&Record{}); err != nil {
+		t.Fatal(err)
+	}
+	pods, err := NewStrategy(scheme.Scheme, &corev1.Pod{}, "pod", db, nil, false, WithGlobalSequence())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(pods.Destroy)
+	configMaps, err := NewStrategy(scheme.Scheme, &corev1.ConfigMap{}, "configmap", db, nil, false, WithGlobalSequence())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(configMaps.Destroy)
+
+	ctx := context.Background()
+	meta := metav1.ObjectMeta{
+		Name:      "test-name",
+		Namespace: "test-namespace",
+	}
+	pod := &corev1.Pod{ObjectMeta: meta}
+	if _, err := pods.Create(ctx, pod); err != nil {
+		t.Fatal(err)
+	}
+	configMap := &corev1.ConfigMap{ObjectMeta: meta}
+	if _, err := configMaps.Create(ctx, configMap); err != nil {
+		t.Fatal(err)
+	}
+	pod.Spec.NodeName = "test"
+	if _, err := pods.Update(ctx, pod); err != nil {
+		t.Fatal(err)
+	}
+
+	// resource versions are per table, sequences are not
+	assert.Equal(t, "1", configMap.ResourceVersion)
+	assert.Equal(t, "2", pod.ResourceVersion)
+	assert.Equal(t, "2", configMap.Annotations[GlobalSequenceAnnotation])
+	assert.Equal(t, "3", pod.Annotations[GlobalSequenceAnnotation])
+
+	got, err := pods.Get(ctx, "test-namespace", "test-name")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "3", got.GetAnnotations()[GlobalSequenceAnnotation])
+}
+
+func TestFieldSelectorPushdown(t *testing.T) {
+	store := newTestStore(t)
+	ctx := context.Background()
+	for name, nodeName := range map[string]string{"pod-a": "a", "pod-b": "b", "pod-none": ""} {
+		pod := &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: "test-namespace",
+			},
+			Spec: corev1.PodSpec{
+				NodeName: nodeName,
+			},
+		}
+		if _, err := store.Create(ctx, pod); err != nil {
+			t.Fatal(err)
+		}
+		if nodeName != "" {
+			pod.Status.Phase = corev1.PodRunning
+			if _, err := store.UpdateStatus(ctx, pod); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	// numbers and booleans compare as the strings Kubernetes formats them as
+	for name, priority := range map[string]int32{"pod-a": 10, "pod-b": 1000} {
+		obj, err := store.Get(ctx, "test-namespace", name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pod := obj.(*corev1.Pod)
+		pod.Spec.Priority = &priority
+		pod.Spec.EnableServiceLinks = &[]bool{name == "pod-a"}[0]
+		if _, err := store.Update(ctx, pod); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for selector, expected := range map[string][]string{
+		"spec.priority=10":                         {"pod-a"},
+		"spec.priority!=10":                        {"pod-b", "pod-none"},
+		"spec.enableServiceLinks=true":             {"pod-a"},
+		"spec.enableServiceLinks=false":            {"pod-b"},
+		"spec.nodeName=a":                          {"pod-a"},
+		"spec.nodeName==b":                         {"pod-b"},
+		"spec.nodeName!=a":                         {"pod-b", "pod-none"},
+		"spec.nodeName=":                           {"pod-none"},
+		"spec.nodeName!=":                          {"pod-a", "pod-b"},
+		"status.phase=Running":                     {"pod-a", "pod-b"},
+		"status.phase=Running,spec.nodeName!=b":    {"pod-a"},
+		"spec.nodeName!=a,spec.nodeName!=b":        {"pod-none"},
+		"metadata.name=pod-a,status.phase=Pending": nil,
+	} {
+		records, _, err := store.db.Get(ctx, Criteria{
+			Namespace:     strptr("test-namespace"),
+			FieldSelector: fields.ParseSelectorOrDie(selector),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var names []string
+		for _, record := range records {
+			names = append(names, record.Name)
+		}
+		assert.ElementsMatch(t, expected, names, selector)
+	}
+}
+
+func TestFieldIndexes(t *testing.T) {
+	store := newTestStore(t, WithFieldIndexes("spec.nodeName", "status.phase", "spec.priority", "spec.enableServiceLinks"))
+	g := store.db.(*GormDB)
+	ctx := context.Background()
+	for _, field := range []string{"spec.nodeName", "status.phase", "spec.priority", "spec.enableServiceLinks"} {
+		assert.True(t, g.hasSelectorColumn(selectorColumnName(SelectorTypeField, field)), field)
+	}
+
+	for name, nodeName := range map[string]string{"pod-a": "a", "pod-none": ""} {
+		pod := &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: "test-namespace",
+			},
+			Spec: corev1.PodSpec{
+				NodeName:           nodeName,
+				Priority:           &[]int32{int32(len(nodeName))}[0],
+				EnableServiceLinks: &[]bool{nodeName != ""}[0],
+			},
+		}
+		if _, err := store.Create(ctx, pod); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for selector, expected := range map[string][]string{
+		"spec.nodeName=a":               {"pod-a"},
+		"spec.nodeName=":                {"pod-none"},
+		"spec.nodeName!=a":              {"pod-none"},
+		"spec.priority=1":               {"pod-a"},
+		"spec.enableServiceLinks=true":  {"pod-a"},
+		"spec.enableServiceLinks=false": {"pod-none"},
+	} {
+		records, _, err := store.db.Get(ctx, Criteria{
+			Namespace:     strptr("test-namespace"),
+			FieldSelector: fields.ParseSelectorOrDie(selector),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var names []string
+		for _, record := range records {
+			names = append(names, record.Name)
+		}
+		assert.ElementsMatch(t, expected, names, selector)
+	}
+
+	// a restarted server finds the columns instead of creating them again, and creates the indexes that are missing
+	index := g.selectorIndexName(selectorColumnName(SelectorTypeField, "spec.nodeName"))
+	if err := g.db.Migrator().DropIndex("pod", index); err != nil {
+		t.Fatal(err)
+	}
+	restarted, err := NewStrategy(scheme.Scheme, &corev1.Pod{}, "pod", g.db, nil, false, WithFieldIndexes("spec.nodeName"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	restarted.Destroy()
+	assert.True(t, g.db.Migrator().HasIndex("pod", index))
+
+	_, err = NewStrategy(scheme.Scheme, &corev1.Pod{}, "pod", g.db, nil, false, WithFieldIndexes("metadata.name"))
+	assert.ErrorContains(t, err, "can't be indexed")
+}
+
+func TestFieldIndexStatementsMySQL(t *testing.T) {
+	db, err := gorm.Open(mysql.New(mysql.Config{
+		DSN:                       "mink@tcp(127.0.0.1:3306)/mink",
+		SkipInitializeWithVersion: true,
+	}), &gorm.Config{DryRun: true, DisableAutomaticPing: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	g := &GormDB{db: db, tableName: "pod"}
+
+	column := selectorColumnName(SelectorTypeField, "spec.nodeName")
+	statements := g.indexStatements(SelectorTypeField, "spec.nodeName", column)
+	if assert.Len(t, statements, 2) {
+		assert.Contains(t, statements[0], "VARCHAR(255) GENERATED ALWAYS AS (LEFT(JSON_UNQUOTE(JSON_EXTRACT(`data`,'$.\"spec\".\"nodeName\"')),255))")
+		assert.Contains(t, statements[1], g.selectorIndexName(column))
+	}
+
+	assert.False(t, g.truncatedSelectorColumn(SelectorTypeField, strings.Repeat("é", mysqlFieldColumnLength)))
+	assert.True(t, g.truncatedSelectorColumn(SelectorTypeField, strings.Repeat("é", mysqlFieldColumnLength+1)))
+	assert.False(t, g.truncatedSelectorColumn(SelectorTypeLabel, strings.Repeat("é", mysqlFieldColumnLength+1)))
+}
+
+func TestPreviousIndexStatementsSQLServer(t *testing.T) {
+	db, err := gorm.Open(sqlserver.Open("sqlserver://mink@127.0.0.1:1433?database=mink"),
+		&gorm.Config{DryRun: true, DisableAutomaticPing: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	index, statements := previousIndexStatements(db, "pod")
+	assert.Equal(t, "idx_pod_previous", index)
+	assert.Equal(t, []string{
+		`DROP INDEX "idx_pod_previous" ON "pod"`,
+		`CREATE UNIQUE INDEX "idx_pod_previous" ON "pod" ("previous") WHERE "previous" IS NOT NULL`,
+	}, statements)
+}
+
+func TestListResourceVersionMatch(t *testing.T) {
+	store := newTestStore(t)
+	ctx := context.Background()
+
+	var pods []*corev1.Pod
+	for _, name := range []string{"pod1", "pod2"} {
+		pod := &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: "test-namespace",
+			},
+		}
+		if _, err := store.Create(ctx, pod); err != nil {
+			t.Fatal(err)
+		}
+		pods = append(pods, pod)
+	}
+	pods[0].Spec.NodeName = "test"
+	if _, err := store.Update(ctx, pods[0]); err != nil {
+		t.Fatal(err)
+	}
+
+	list := func(opts storage.ListOptions) (*corev1.PodList, error) {
+		opts.Predicate = storage.Everything
+		result, err := store.List(ctx, "test-namespace", opts)
+		if err != nil {
+			return nil, err
+		}
+		return result.(*corev1.PodList), nil
+	}
+
+	exact, err := list(storage.ListOptions{ResourceVersion: "2", ResourceVersionMatch: metav1.ResourceVersionMatchExact})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "2", exact.ResourceVersion)
+	if assert.Len(t, exact.Items, 2) {
+		assert.Equal(t, "1", exact.Items[0].ResourceVersion)
+		assert.Empty(t, exact.Items[0].Spec.NodeName)
+	}
+
+	notOlder, err := list(storage.ListOptions{ResourceVersion: "1", ResourceVersionMatch: metav1.ResourceVersionMatchNotOlderThan})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "3", notOlder.ResourceVersion)
+
+	_, err = list(storage.ListOptions{ResourceVersion: "10", ResourceVersionMatch: metav1.ResourceVersionMatchNotOlderThan})
+	assert.True(t, storage.IsTooLargeResourceVersion(err), "expected too large resource version, got %v", err)
+	_, err = list(storage.ListOptions{ResourceVersion: "10", ResourceVersionMatch: metav1.ResourceVersionMatchExact})
+	assert.True(t, storage.IsTooLargeResourceVersion(err), "expected too large resource version, got %v", err)
+
+	// every page of a paginated list is read at the resource version of the first
+	first, err := store.List(ctx, "test-namespace", storage.ListOptions{
+		Predicate: storage.SelectionPredicate{Label: labels.Everything(), Field: fields.Everything(), GetAttrs: storage.DefaultNamespaceScopedAttr, Limit: 1},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Create(ctx, &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "pod3",
+			Namespace: "test-namespace",
+		},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	second, err := store.List(ctx, "test-namespace", storage.ListOptions{
+		Predicate: storage.SelectionPredicate{
+			Label:    labels.Everything(),
+			Field:    fields.Everything(),
+			GetAttrs: storage.DefaultNamespaceScopedAttr,
+			Limit:    10,
+			Continue: first.GetContinue(),
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, first.GetResourceVersion(), second.GetResourceVersion())
+	assert.Len(t, second.(*corev1.PodList).Items, 1)
+
+	g := store.db.(*GormDB)
+	g.compactionLock.Lock()
+	g.compaction = 3
+	g.compactionLock.Unlock()
+	_, err = list(storage.ListOptions{ResourceVersion: "2", ResourceVersionMatch: metav1.ResourceVersionMatchExact})
+	assert.True(t, apierrors.IsResourceExpired(err), "expected expired resource version, got %v", err)
+}
+
+func TestPatch(t *testing.T) {
+	store := newTestStore(t)
+	ctx := context.Background()
+
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "pod1",
+			Namespace: "test-namespace",
+		},
+	}
+	if _, err := store.Create(ctx, pod); err != nil {
+		t.Fatal(err)
+	}
+
+	calls := 0
+	patched, err := store.Patch(ctx, "test-namespace", "pod1", func(existing minktypes.Object) (minktypes.Object, error) {
+		calls++
+		if calls == 1 {
+			// another write gets in between
+			concurrent := existing.DeepCopyObject().(*corev1.Pod)
+			concurrent.Spec.NodeName = "test"
+			if _, err := store.Update(ctx, concurrent); err != nil {
+				return nil, err
+			}
+		}
+		obj := existing.DeepCopyObject().(*corev1.Pod)
+		obj.Labels = map[string]string{"patched": "true"}
+		return obj, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 2, calls)
+	assert.Equal(t, "test", patched.(*corev1.Pod).Spec.NodeName)
+	assert.Equal(t, "true", patched.GetLabels()["patched"])
+
+	// a patch setting an old resource version fails like an update
+	calls = 0
+	_, err = store.Patch(ctx, "test-namespace", "pod1", func(existing minktypes.Object) (minktypes.Object, error) {
+		calls++
+		obj := existing.DeepCopyObject().(*corev1.Pod)
+		obj.ResourceVersion = pod.ResourceVersion
+		return obj, nil
+	})
+	assert.True(t, apierrors.IsConflict(err), "expected conflict, got %v", err)
+	assert.Equal(t, 1, calls)
+}
+
+func TestDeleteCollection(t *testing.T) {
+	store := newTestStore(t)
+	ctx := context.Background()
+
+	for _, pod := range []*corev1.Pod{
+		{ObjectMeta: metav1.ObjectMeta{Name: "pod1", Namespace: "test-namespace", Labels: map[string]string{"app": "test"}}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "pod2", Namespace: "test-namespace", Labels: map[string]string{"app": "test"},
+			Finalizers: []string{"test"}}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "pod3", Namespace: "test-namespace", Labels: map[string]string{"app": "other"}}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "pod4", Namespace: "test-namespace", Labels: map[string]string{"app": "test"}}},
+	} {
+		if _, err := store.Create(ctx, pod); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	deleted, err := store.DeleteCollection(ctx, "test-namespace", strategy.DeleteCollectionOptions{
+		ListOptions: storage.ListOptions{
+			Predicate: storage.SelectionPredicate{
+				Label:    labels.SelectorFromSet(labels.Set{"app": "test"}),
+				Field:    fields.Everything(),
+				GetAttrs: storage.DefaultNamespaceScopedAttr,
+			},
+		},
+		Check: func(_ context.Context, obj minktypes.Object) (bool, error) {
+			return obj.GetName() != "pod4", nil
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	items := deleted.(*corev1.PodList).Items
+	if assert.Len(t, items, 2) {
+		// the removal records are written together, in the order of the objects
+		first, _ := strconv.Atoi(items[0].ResourceVersion)
+		second, _ := strconv.Atoi(items[1].ResourceVersion)
+		assert.Equal(t, first+1, second)
+		assert.False(t, items[0].DeletionTimestamp.IsZero())
+	}
+
+	_, err = store.Get(ctx, "test-namespace", "pod1")
+	assert.True(t, apierrors.IsNotFound(err), "expected not found, got %v", err)
+
+	// objects with finalizers are only marked as being deleted
+	pod2, err := store.Get(ctx, "test-namespace", "pod2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.False(t, pod2.GetDeletionTimestamp().IsZero())
+
+	for _, name := range []string{"pod3", "pod4"} {
+		obj, err := store.Get(ctx, "test-namespace", name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.True(t, obj.GetDeletionTimestamp().IsZero())
+	}
+}
+
+func TestGetRevision(t *testing.T) {
+	store := newTestStore(t)
+	ctx := context.Background()
+
+	created, err := store.Create(ctx, &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "pod1",
+			Namespace: "test-namespace",
+			Labels:    map[string]string{"a": "b"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pod := created.DeepCopyObject().(*corev1.Pod)
+	pod.Labels = map[string]string{"c": "d"}
+	pod.Spec.NodeName = "test"
+	updated, err := store.Update(ctx, pod)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	revision, err := store.GetRevision(ctx, "test-namespace", "pod1", created.GetResourceVersion())
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, created.GetResourceVersion(), revision.GetResourceVersion())
+	assert.Equal(t, map[string]string{"a": "b"}, revision.GetLabels())
+
+	changes, err := strategy.DiffObjects(revision, updated)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []strategy.Change{
+		{Op: strategy.ChangeReplace, Path: "/metadata/generation", From: int64(1), To: int64(2)},
+		{Op: strategy.ChangeRemove, Path: "/metadata/labels/a", From: "b"},
+		{Op: strategy.ChangeAdd, Path: "/metadata/labels/c", To: "d"},
+		{Op: strategy.ChangeReplace, Path: "/metadata/resourceVersion", From: created.GetResourceVersion(),
+			To: updated.GetResourceVersion()},
+		{Op: strategy.ChangeAdd, Path: "/spec/nodeName", To: "test"},
+	}, changes)
+
+	_, err = store.GetRevision(ctx, "test-namespace", "pod2", updated.GetResourceVersion())
+	assert.True(t, apierrors.IsNotFound(err), "expected not found, got %v", err)
+}
+
+func TestWatchOverflow(t *testing.T) {
+	for _, policy := range []WatchOverflowPolicy{WatchOverflowResync, WatchOverflowClose} {
+		t.Run(string(policy), func(t *testing.T) {
+			store := newTestStore(t, WithWatchBuffer(2, policy))
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			overflows := metrics.WatchOverflows.WithLabelValues("pod")
+			before, err := testutil.GetCounterMetricValue(overflows)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			w, err := store.Watch(ctx, "", storage.ListOptions{Predicate: storage.Everything})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// nothing reads the watch while the pods are created, so it falls behind
+			for i := 0; i < 20; i++ {
+				if _, err := store.Create(ctx, &corev1.Pod{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      fmt.Sprintf("pod%d", i),
+						Namespace: "test-namespace",
+					},
+				}); err != nil {
+					t.Fatal(err)
+				}
+			}
+			assert.Eventually(t, func() bool {
+				after, err := testutil.GetCounterMetricValue(overflows)
+				return err == nil && after > before
+			}, 10*time.Second, 10*time.Millisecond)
+
+			var last watch.Event
+			for event := range w {
+				last = event
+			}
+			if policy == WatchOverflowResync {
+				assert.Equal(t, watch.Error, last.Type)
+				assert.True(t, apierrors.IsResourceExpired(apierrors.FromObject(last.Object)))
+			} else {
+				assert.Equal(t, watch.Added, last.Type)
+			}
+		})
+	}
+}
+
+func TestWatchCache(t *testing.T) {
+	ctx := context.Background()
+	store := newTestStore(t, withWatchCache(&watchCacheBudget{}))
+	g := store.db.(*GormDB)
+
+	createPod := func(t *testing.T, store *Strategy, name string) {
+		if _, err := store.Create(ctx, &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: "test-namespace",
+			},
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	listPods := func(t *testing.T, store *Strategy, resourceVersion string) []string {
+		list, err := store.List(ctx, "", storage.ListOptions{
+			ResourceVersion: resourceVersion,
+			Predicate:       storage.Everything,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var names []string
+		for _, pod := range list.(*corev1.PodList).Items {
+			names = append(names, pod.Name)
+		}
+		return names
+	}
+
+	createPod(t, store, "pod1")
+	createPod(t, store, "pod2")
+	assert.Equal(t, []string{"pod1", "pod2"}, listPods(t, store, "0"))
+
+	// the watch loop keeps the loaded cache current
+	createPod(t, store, "pod3")
+	assert.Eventually(t, func() bool {
+		return len(listPods(t, store, "0")) == 3
+	}, 10*time.Second, 10*time.Millisecond)
+
+	// lists of resource version 0 and new watches are served from the cache, other lists from the database
+	if err := g.db.Exec("DELETE FROM pod").Error; err != nil {
+		t.Fatal(err)
+	}
+	assert.Empty(t, listPods(t, store, ""))
+	assert.Equal(t, []string{"pod1", "pod2", "pod3"}, listPods(t, store, "0"))
+	events := watchEvents(t, store, "", 3)
+	assert.Equal(t, watch.Added, events[2].Type)
+	assert.Equal(t, "pod3", events[2].Object.(*corev1.Pod).Name)
+
+	// paged lists with field selectors are read from the database, the selector applies before the limit
+	list, err := store.List(ctx, "", storage.ListOptions{
+		ResourceVersion: "0",
+		Predicate: storage.SelectionPredicate{
+			Label: labels.Everything(),
+			Field: fields.OneTermEqualSelector("spec.nodeName", "nomatch"),
+			Limit: 1,
+		},
+	})
+	if assert.NoError(t, err) {
+		assert.Empty(t, list.(*corev1.PodList).Items)
+	}
+
+	t.Run("limit", func(t *testing.T) {
+		// a table over the budget isn't cached
+		limited := newTestStore(t, withWatchCache(&watchCacheBudget{max: 1}))
+		createPod(t, limited, "pod1")
+		assert.Equal(t, []string{"pod1"}, listPods(t, limited, "0"))
+		assert.True(t, limited.db.(*GormDB).cache.disabled)
+	})
+}
+
+func TestWatchSnapshot(t *testing.T) {
+	ctx := context.Background()
+	store := newTestStore(t, WithWatchSnapshotTTL(time.Hour))
+	g := store.db.(*GormDB)
+
+	createPod := func(name, app string) *corev1.Pod {
+		obj, err := store.Create(ctx, &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: "test-namespace",
+				Labels:    map[string]string{"app": app},
+			},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return obj.(*corev1.Pod)
+	}
+
+	pod1 := createPod("pod1", "a")
+	createPod("pod2", "a")
+
+	// the watch asking for the snapshot going away doesn't fail it
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	snapshot, err := g.getSnapshot(canceled)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Len(t, snapshot.records, 2)
+
+	// changes after the snapshot are applied before filtering, pod1 no longer matching is removed
+	pod1.Labels["app"] = "b"
+	if _, err := store.Update(ctx, pod1); err != nil {
+		t.Fatal(err)
+	}
+	createPod("pod3", "a")
+	createPod("pod4", "b")
+
+	watchCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	c, err := store.Watch(watchCtx, "", toLabels(t, metav1.LabelSelector{MatchLabels: map[string]string{"app": "a"}}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var events []string
+	for len(events) < 4 {
+		select {
+		case event := <-c:
+			pod := event.Object.(*corev1.Pod)
+			events = append(events, string(event.Type)+" "+pod.Name+" "+pod.Labels["app"])
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out waiting for watch events, got %v", events)
+		}
+	}
+	assert.Equal(t, []string{"ADDED pod1 a", "ADDED pod2 a", "DELETED pod1 a", "ADDED pod3 a"}, events)
+	assert.Same(t, snapshot, g.snapshot)
+}
+
+func TestMerge(t *testing.T) {
+	store := newTestStore(t)
+	ctx := request.WithNamespace(context.Background(), "test-namespace")
+
+	base, err := store.Create(ctx, &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "pod1",
+			Namespace: "test-namespace",
+			Labels:    map[string]string{"a": "b"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	current := base.DeepCopyObject().(*corev1.Pod)
+	current.Labels["a"] = "c"
+	current.Spec.NodeName = "test"
+	if _, err := store.Update(ctx, current); err != nil {
+		t.Fatal(err)
+	}
+
+	merge := strategy.NewMerge(store)
+
+	modified := base.DeepCopyObject().(*corev1.Pod)
+	modified.Labels["x"] = "y"
+	modified.Spec.Hostname = "host"
+	result, err := merge.Merge(ctx, "pod1", base.GetResourceVersion(), modified)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Empty(t, result.Conflicts)
+	merged := &corev1.Pod{}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(result.Object, merged); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, map[string]string{"a": "c", "x": "y"}, merged.Labels)
+	assert.Equal(t, "test", merged.Spec.NodeName)
+	assert.Equal(t, "host", merged.Spec.Hostname)
+	assert.Equal(t, "2", merged.ResourceVersion)
+
+	modified = base.DeepCopyObject().(*corev1.Pod)
+	modified.Spec.NodeName = "other"
+	result, err = merge.Merge(ctx, "pod1", base.GetResourceVersion(), modified)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []strategy.Conflict{{
+		Path:     "/spec/nodeName",
+		Modified: "other",
+		Current:  "test",
+		Removed:  []string{"base"},
+	}}, result.Conflicts)
+}
+
+// xorTransformer is a test transformer that "encrypts" data by xoring it with a key.
+type xorTransformer byte
+
+func (x xorTransformer) xor(data []byte) []byte {
+	result := make([]byte, len(data))
+	for i, b := range data {
+		result[i] = b ^ byte(x)
+	}
+	return result
+}
+
+func (x xorTransformer) TransformFromStorage(_ context.Context, data []byte, _ value.Context) ([]byte, bool, error) {
+	return x.xor(data), false, nil
+}
+
+func (x xorTransformer) TransformToStorage(_ context.Context, data []byte, _ value.Context) ([]byte, error) {
+	return x.xor(data), nil
+}
+
+type testPartitionKeys struct {
+	lock sync.Mutex
+	keys map[string]value.Transformer
+}
+
+func (k *testPartitionKeys) TransformerForPartition(_ context.Context, partitionID string) (value.Transformer, error) {
+	k.lock.Lock()
+	defer k.lock.Unlock()
+	t, ok := k.keys[partitionID]
+	if !ok {
+		return nil, ErrKeyDestroyed
+	}
+	return t, nil
+}
+
+func TestPartitionKeys(t *testing.T) {
+	keys := &testPartitionKeys{keys: map[string]value.Transformer{"tenant1": xorTransformer(1), "tenant2": xorTransformer(2)}}
+	store := newTestStore(t, WithPartitionKeys(keys))
+	ctx := context.Background()
+
+	for _, partition := range []string{"tenant1", "tenant2"} {
+		_, err := store.Create(ContextWithPartitionID(ctx, partition), &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      partition,
+				Namespace: "test-namespace",
+			},
+			Spec: corev1.PodSpec{NodeName: partition},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var data []byte
+	if err := store.db.(*GormDB).db.Table("pod").Select("data").Where("name = ?", "tenant1").Row().Scan(&data); err != nil {
+		t.Fatal(err)
+	}
+	assert.NotContains(t, string(data), "nodeName")
+
+	pod, err := store.Get(ContextWithPartitionID(ctx, "tenant1"), "test-namespace", "tenant1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "tenant1", pod.(*corev1.Pod).Spec.NodeName)
+
+	// crypto-shred tenant1
+	keys.lock.Lock()
+	delete(keys.keys, "tenant1")
+	keys.lock.Unlock()
+
+	_, err = store.Get(ContextWithPartitionID(ctx, "tenant1"), "test-namespace", "tenant1")
+	assert.True(t, apierrors.IsNotFound(err), "expected not found, got %v", err)
+
+	// writes treat the shredded object as absent too
+	shredded := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "tenant1", Namespace: "test-namespace", ResourceVersion: pod.GetResourceVersion()}}
+	_, err = store.Update(ContextWithPartitionID(ctx, "tenant1"), shredded)
+	assert.True(t, apierrors.IsNotFound(err), "expected not found, got %v", err)
+
+	list, err := store.List(ctx, "", storage.ListOptions{Predicate: storage.Everything})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pods := list.(*corev1.PodList).Items
+	if assert.Len(t, pods, 1) {
+		assert.Equal(t, "tenant2", pods[0].Spec.NodeName)
+	}
+}
+
+func TestHealth(t *testing.T) {
+	store := newTestStore(t)
+
+	health := store.Health(context.Background())
+	assert.Equal(t, "pod", health.Table)
+	assert.True(t, health.GCEnabled)
+	assert.True(t, health.Healthy(), "expected healthy table, got %+v", health)
+
+	store.db.(*GormDB).recordGC(errors.New("gc failed"))
+	health = store.Health(context.Background())
+	assert.False(t, health.Healthy())
+	assert.Equal(t, "gc failed", health.LastGCError)
+}
+
+func TestQueue(t *testing.T) {
+	store := newTestStore(t)
+	f := &Factory{DB: store.db.(*GormDB).db, AutoMigrate: true}
+	queue, err := f.NewQueue("test", WithJobPollInterval(10*time.Millisecond), WithJobRetryBackoff(time.Millisecond, time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a job enqueued in a transaction that is rolled back is never run
+	err = f.Transaction(context.Background(), func(ctx context.Context) error {
+		if _, err := store.Create(ctx, &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "rolled-back", Namespace: "default"}}); err != nil {
+			return err
+		}
+		if _, err := queue.Enqueue(ctx, "rolled-back"); err != nil {
+			return err
+		}
+		return errors.New("roll back")
+	})
+	assert.EqualError(t, err, "roll back")
+	_, err = store.Get(context.Background(), "default", "rolled-back")
+	assert.True(t, apierrors.IsNotFound(err), "expected not found, got %v", err)
+
+	_, err = queue.Enqueue(context.Background(), "ok")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = queue.Enqueue(context.Background(), "fail", WithMaxAttempts(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ran := make(chan string, 10)
+	go queue.Run(ctx, func(_ context.Context, job *Job) error {
+		var payload string
+		if err := job.Unmarshal(&payload); err != nil {
+			return err
+		}
+		ran <- payload
+		if payload == "fail" {
+			return errors.New("failed")
+		}
+		return nil
+	})
+
+	var payloads []string
+	for len(payloads) < 3 {
+		select {
+		case payload := <-ran:
+			payloads = append(payloads, payload)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("jobs did not run, ran %v", payloads)
+		}
+	}
+	assert.ElementsMatch(t, []string{"ok", "fail", "fail"}, payloads)
+
+	var dead []Job
+	for i := 0; i < 50 && len(dead) == 0; i++ {
+		time.Sleep(10 * time.Millisecond)
+		dead, err = queue.DeadLetters(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if assert.Len(t, dead, 1) {
+		assert.Equal(t, "failed", dead[0].LastError)
+		assert.NoError(t, queue.Retry(context.Background(), dead[0].ID))
+	}
+	select {
+	case payload := <-ran:
+		assert.Equal(t, "fail", payload)
+	case <-time.After(5 * time.Second):
+		t.Fatal("retried job did not run")
+	}
+}
+
+func TestPublisher(t *testing.T) {
+	store := newTestStore(t)
+	f := &Factory{DB: store.db.(*GormDB).db, AutoMigrate: true, strategies: []*Strategy{store}}
+
+	events := make(chan ChangeEvent, 10)
+	publisher, err := f.NewPublisher("test", SinkFunc(func(_ context.Context, batch []ChangeEvent) error {
+		for _, event := range batch {
+			events <- event
+		}
+		return nil
+	}), WithPublishPollInterval(10*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go publisher.Run(ctx)
+
+	// a new publisher starts at the end of the table
+	var cursors int64
+	for i := 0; i < 100 && cursors == 0; i++ {
+		time.Sleep(10 * time.Millisecond)
+		f.DB.Table(publishCursorsTableName).Count(&cursors)
+	}
+
+	pod, err := store.Create(context.Background(), &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "test-name", Namespace: "test-namespace"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := metav1.Now()
+	pod.SetDeletionTimestamp(&now)
+	_, err = store.Delete(context.Background(), pod)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, expected := range []watch.EventType{watch.Added, watch.Deleted} {
+		select {
+		case event := <-events:
+			assert.Equal(t, expected, event.Type)
+			assert.Equal(t, "pod", event.Table)
+			assert.Contains(t, string(event.Object), `"name":"test-name"`)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("%s event was not published", expected)
+		}
+	}
+}
+
+func TestConsumer(t *testing.T) {
+	store := newTestStore(t)
+	f := &Factory{DB: store.db.(*GormDB).db, AutoMigrate: true, strategies: []*Strategy{store}}
+	ctx := context.Background()
+
+	consumer, err := f.NewConsumer("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// a new consumer starts at the end of the table
+	batch, err := consumer.Next(ctx, "pod", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Empty(t, batch.Events)
+
+	for _, name := range []string{"pod1", "pod2"} {
+		if _, err := store.Create(ctx, &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "test-namespace"}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// changes are read again until they are committed
+	for i := 0; i < 2; i++ {
+		batch, err = consumer.Next(ctx, "pod", 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if assert.Len(t, batch.Events, 1) {
+			assert.Equal(t, watch.Added, batch.Events[0].Type)
+			assert.Contains(t, string(batch.Events[0].Object), `"name":"pod1"`)
+		}
+	}
+	if err := consumer.Commit(ctx, "pod", batch.Position); err != nil {
+		t.Fatal(err)
+	}
+
+	// the position is kept for the next instance of the consumer
+	consumer, err = f.NewConsumer("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	batch, err = consumer.Next(ctx, "pod", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if assert.Len(t, batch.Events, 1) {
+		assert.Contains(t, string(batch.Events[0].Object), `"name":"pod2"`)
+	}
+
+	_, err = consumer.Next(ctx, "missing", 10)
+	assert.ErrorIs(t, err, ErrUnknownTable)
+}
+
+func TestConsumerHandler(t *testing.T) {
+	store := newTestStore(t)
+	f := &Factory{DB: store.db.(*GormDB).db, AutoMigrate: true, strategies: []*Strategy{store}}
+	handler, err := NewConsumerHandler(ConsumerHandlerConfig{
+		Factory:   f,
+		Resources: map[string]schema.GroupResource{"pod": {Resource: "pods"}},
+		// alice may read the changes of pods and commit them as the consumer "alice" only
+		Authorizer: authorizer.AuthorizerFunc(func(_ context.Context, a authorizer.Attributes) (authorizer.Decision, string, error) {
+			if a.GetUser().GetName() == "alice" && (a.GetVerb() == "watch" || a.GetName() == "alice") {
+				return authorizer.DecisionAllow, "", nil
+			}
+			return authorizer.DecisionDeny, "not allowed", nil
+		}),
+		Scrubbers: map[string]strategy.Scrubber{"pod": strategy.NewPrefixScrubber("secret.")},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	serve := func(method, userName, partitionID, query string) *httptest.ResponseRecorder {
+		ctx := ContextWithPartitionID(context.Background(), partitionID)
+		if userName != "" {
+			ctx = request.WithUser(ctx, &user.DefaultInfo{Name: userName})
+		}
+		rw := httptest.NewRecorder()
+		handler.ServeHTTP(rw, httptest.NewRequest(method, "/changes?"+query, nil).WithContext(ctx))
+		return rw
+	}
+
+	assert.Equal(t, http.StatusUnauthorized, serve(http.MethodGet, "", "", "consumer=alice&table=pod").Code)
+	assert.Equal(t, http.StatusForbidden, serve(http.MethodGet, "bob", "", "consumer=bob&table=pod").Code)
+	assert.Equal(t, http.StatusNotFound, serve(http.MethodGet, "alice", "", "consumer=alice&table=mink_migrations").Code)
+	// the cursors start at the end of the table
+	for _, partitionID := range []string{"", "p1"} {
+		assert.Equal(t, http.StatusOK, serve(http.MethodGet, "alice", partitionID, "consumer=alice&table=pod").Code)
+	}
+
+	ctx := context.Background()
+	for _, partitionID := range []string{"p1", "p2"} {
+		if _, err := store.Create(ContextWithPartitionID(ctx, partitionID), &corev1.Pod{ObjectMeta: metav1.ObjectMeta{
+			Name:        "pod-" + partitionID,
+			Namespace:   "test-namespace",
+			Annotations: map[string]string{"secret.acorn.io/token": "hidden"},
+		}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	batch := readBatch(t, serve(http.MethodGet, "alice", "p1", "consumer=alice&table=pod"))
+	if assert.Len(t, batch.Events, 1) {
+		assert.Contains(t, string(batch.Events[0].Object), `"name":"pod-p1"`)
+		assert.NotContains(t, string(batch.Events[0].Object), "hidden")
+	}
+	assert.Len(t, readBatch(t, serve(http.MethodGet, "alice", "", "consumer=alice&table=pod")).Events, 2)
+
+	// commits are authorized for the consumer
+	position := fmt.Sprintf("&position=%d", batch.Position)
+	assert.Equal(t, http.StatusForbidden, serve(http.MethodPost, "alice", "p1", "consumer=bob&table=pod"+position).Code)
+	assert.Equal(t, http.StatusNoContent, serve(http.MethodPost, "alice", "p1", "consumer=alice&table=pod"+position).Code)
+	assert.Empty(t, readBatch(t, serve(http.MethodGet, "alice", "p1", "consumer=alice&table=pod")).Events)
+	assert.Len(t, readBatch(t, serve(http.MethodGet, "alice", "", "consumer=alice&table=pod")).Events, 2)
+}
+
+func readBatch(t *testing.T, rw *httptest.ResponseRecorder) *ChangeBatch {
+	batch := &ChangeBatch{}
+	if err := json.Unmarshal(rw.Body.Bytes(), batch); err != nil {
+		t.Fatalf("reading %q: %v", rw.Body.String(), err)
+	}
+	return batch
+}
+
+// testBackend is a Backend storing tables in an in-memory sqlite database through GormDB, it only exercises the
+// plumbing of backends.
+type testBackend struct {
+	t      *testing.T
+	tables []string
+}
+
+func (b *testBackend) NewDB(tableName string, gvk schema.GroupVersionKind) (DB, error) {
+	b.tables = append(b.tables, tableName)
+	return NewDB(tableName, gvk, newTestDB(b.t, tableName), nil), nil
+}
+
+func (b *testBackend) Ping(context.Context) error {
+	return nil
+}
+
+func TestBackend(t *testing.T) {
+	backend := &testBackend{t: t}
+	RegisterBackend("test-backend://", func(dsn string) (Backend, error) {
+		assert.Equal(t, "tables", dsn)
+		return backend, nil
+	})
+
+	f, err := NewFactory(scheme.Scheme, "test-backend://tables")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := f.NewDBStrategy(&corev1.Pod{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(s.Destroy)
+	assert.Equal(t, []string{"pod"}, backend.tables)
+
+	created, err := s.Create(context.Background(), &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-name",
+			Namespace: "test-namespace",
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	obj, err := s.Get(context.Background(), "test-namespace", "test-name")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, created.GetUID(), obj.GetUID())
+
+	_, err = f.NewQueue("test")
+	assert.ErrorIs(t, err, ErrSQLRequired)
+	assert.NoError(t, f.Check(httptest.NewRequest(http.MethodGet, "/healthz", nil)))
+}
+
+func TestMigrations(t *testing.T) {
+	ctx := context.Background()
+	var runs []string
+	migration := func(name string, err error) Migration {
+		return Migration{
+			Name: name,
+			Migrate: func(_ context.Context, tx *gorm.DB) error {
+				runs = append(runs, name)
+				if err != nil {
+					return err
+				}
+				return tx.Exec("CREATE TABLE " + name + " (id INTEGER)").Error
+			},
+		}
+	}
+	f := &Factory{DB: newTestDB(t, "pod"), AutoMigrate: true}
+	f.migrations = []Migration{migration("one", nil), migration("two", nil)}
+
+	// a dry run is rolled back
+	ran, err := f.Migrate(ctx, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []string{"one", "two"}, ran)
+	history, err := f.MigrationHistory(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Empty(t, history)
+	assert.False(t, f.DB.Migrator().HasTable("one"))
+
+	ran, err = f.Migrate(ctx, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []string{"one", "two"}, ran)
+	assert.True(t, f.DB.Migrator().HasTable("two"))
+
+	// only new migrations run, a failed one isn't recorded
+	runs = nil
+	f.migrations = append(f.migrations, migration("three", errors.New("backfill failed")))
+	_, err = f.Migrate(ctx, false)
+	assert.ErrorContains(t, err, "migration three failed: backfill failed")
+	assert.Equal(t, []string{"three"}, runs)
+
+	history, err = f.MigrationHistory(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, record := range history {
+		names = append(names, record.Name)
+	}
+	assert.Equal(t, []string{"one", "two"}, names)
+}
+
+func TestReEncrypt(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDB(t, "pod")
+	podKind := schema.GroupKind{Kind: "Pod"}
+	key := func(name string, x xorTransformer) value.PrefixTransformer {
+		return value.PrefixTransformer{Prefix: []byte(name + ":"), Transformer: x}
+	}
+	newStore := func(keys ...value.PrefixTransformer) *Strategy {
+		t.Helper()
+		var transformers map[schema.GroupKind]value.Transformer
+		if len(keys) > 0 {
+			transformers = map[schema.GroupKind]value.Transformer{podKind: value.NewPrefixTransformers(nil, keys...)}
+		}
+		s, err := NewStrategy(scheme.Scheme, &corev1.Pod{}, "pod", db, transformers, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(s.Destroy)
+		return s
+	}
+	create := func(store *Strategy, name string) {
+		t.Helper()
+		_, err := store.Create(ctx, &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "test-namespace"},
+			Spec:       corev1.PodSpec{NodeName: name},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// one pod written before encryption was configured, one encrypted with the key being rotated out
+	create(newStore(), "plain")
+	create(newStore(key("old", 1)), "old")
+
+	rotated := newStore(key("new", 2), key("old", 1))
+	f := &Factory{DB: db, strategies: []*Strategy{rotated}}
+	result, err := f.ReEncrypt(ctx, corev1.SchemeGroupVersion.WithKind("Pod"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if assert.Len(t, result, 1) {
+		assert.Equal(t, 2, result[0].Records)
+		assert.Equal(t, 2, result[0].ReEncrypted)
+	}
+
+	// nothing is left to re-encrypt and the old key can be removed
+	result, err = f.ReEncrypt(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 0, result[0].ReEncrypted)
+	store := newStore(key("new", 2))
+	for _, name := range []string{"plain", "old"} {
+		pod, err := store.Get(ctx, "test-namespace", name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, name, pod.(*corev1.Pod).Spec.NodeName)
+	}
+
+	_, err = f.ReEncrypt(ctx, corev1.SchemeGroupVersion.WithKind("Node"))
+	assert.ErrorContains(t, err, "no table of the factory stores Node")
+}
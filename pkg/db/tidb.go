@@ -0,0 +1,69 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"gorm.io/gorm"
+)
+
+const (
+	tidbMaxRetries   = 5
+	tidbRetryBackoff = 20 * time.Millisecond
+	// tidbTableOptions makes TiDB hand out auto increment IDs in order across all TiDB servers, which watches rely
+	// on. By default every server caches its own range of IDs. The integer primary key is clustered by default.
+	tidbTableOptions = "AUTO_ID_CACHE=1"
+)
+
+// TiDB error codes for transactions that failed because of a conflict and can be run again.
+var tidbRetryableErrors = map[uint16]bool{
+	// deadlock found when trying to get lock
+	1213: true,
+	// transaction retry error
+	8022: true,
+	// information schema changed
+	8028: true,
+	// write conflict
+	9007: true,
+}
+
+// WithTiDB adapts the strategy to TiDB, which is used through the MySQL driver: transactions that TiDB aborts because
+// of an optimistic locking conflict are retried. NewFactory applies it to tidb:// DSNs.
+func WithTiDB() StrategyOption {
+	return func(s *Strategy) {
+		if g, ok := s.db.(*GormDB); ok {
+			g.tidb = true
+		}
+	}
+}
+
+func isTiDBRetryable(err error) bool {
+	var mysqlErr *mysql.MySQLError
+	return errors.As(err, &mysqlErr) && tidbRetryableErrors[mysqlErr.Number]
+}
+
+// retry runs do again when TiDB aborts it because of a transaction conflict. Work that is part of an outer transaction
+// is retried by the outer transaction instead, as the conflict aborts all of it.
+func (g *GormDB) retry(ctx context.Context, do func() error) error {
+	if !g.tidb {
+		return do()
+	}
+	if _, ok := ctx.Value(dbKey{}).(*gorm.DB); ok {
+		return do()
+	}
+
+	for attempt := 1; ; attempt++ {
+		err := do()
+		if err == nil || attempt > tidbMaxRetries || !isTiDBRetryable(err) {
+			return err
+		}
+		g.log.Debugf("Retrying transaction on [%s] after conflict, attempt %d: %v", g.tableName, attempt, err)
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(time.Duration(attempt) * tidbRetryBackoff):
+		}
+	}
+}
@@ -4,7 +4,7 @@ import (
 	"context"
 	"time"
 
-	"gorm.io/datatypes"
+	"github.com/acorn-io/mink/pkg/datatypes"
 	"k8s.io/apimachinery/pkg/fields"
 	"k8s.io/apimachinery/pkg/labels"
 )
@@ -30,12 +30,44 @@ type Record struct {
 	Data        datatypes.JSON
 	Status      datatypes.JSON
 	PartitionID string `gorm:"index:,composite:idx_ns_name_id"`
+	// GlobalSequence is set for tables created WithGlobalSequence, see GlobalSequenceAnnotation. Only those tables
+	// have the column, see globalSequenceColumn.
+	GlobalSequence uint `gorm:"-:migration"`
+	// CreatedBy and UpdatedBy are set for tables created WithUserTracking, see CreatedByAnnotation. Only those tables
+	// have the columns, see userColumns.
+	CreatedBy string `gorm:"-:migration"`
+	UpdatedBy string `gorm:"-:migration"`
+
+	// InitialState is set on records sent by Watch to describe the state of the world at the start of the watch,
+	// as opposed to records that are events that happened after it started.
+	InitialState bool `gorm:"-"`
+	// Overflowed is set on the last record sent by a Watch that fell too far behind, see WatchOverflowPolicy.
+	Overflowed bool `gorm:"-"`
+
+	// shredded is set on records read from a partition whose key has been destroyed, see ErrKeyDestroyed.
+	shredded bool
+}
+
+// globalSequenceColumn is the column of Record added to the tables created WithGlobalSequence when they start.
+type globalSequenceColumn struct {
+	GlobalSequence uint `gorm:"not null;default:0"`
+}
+
+// userColumns are the columns of Record added to the tables created WithUserTracking when they start. Like the name
+// and namespace they may be NULL, as some databases store empty strings as NULL.
+type userColumns struct {
+	CreatedBy string `gorm:"default:''"`
+	UpdatedBy string `gorm:"default:''"`
 }
 
 type WatchCriteria struct {
-	Name          string
-	Namespace     *string
-	After         uint
+	Name      string
+	Namespace *string
+	// After is non-inclusive. If zero, the watch starts by sending the current state of every matching object.
+	// Otherwise, every change after After is replayed in order.
+	After uint
+	// Namespaces restricts a watch across all namespaces to the given namespaces if not nil.
+	Namespaces    []string
 	LabelSelector labels.Selector
 	FieldSelector fields.Selector
 	PartitionID   string
@@ -44,6 +76,8 @@ type WatchCriteria struct {
 type Criteria struct {
 	Name      string
 	Namespace *string
+	// Namespaces restricts a query across all namespaces to the given namespaces if not nil.
+	Namespaces []string
 	// After is non-inclusive
 	After uint
 	// Before is inclusive
@@ -57,12 +91,39 @@ type Criteria struct {
 	PartitionID       string
 
 	ignoreCompactionCheck bool
+	// cached is set for lists that may be served from the watch cache, see WithWatchCache.
+	cached bool
+	// continued is set if After is the position of the previous page of a list rather than a resource version, only
+	// Before is checked against the compaction.
+	continued bool
 }
 
+// DB is the table of records a Strategy stores its objects in. GormDB implements it on SQL databases, other
+// backends implement it to be used through a Backend or NewStrategyForDB.
+//
+// A table is an append-only log: every write of an object is a new record whose ID is the resource version of that
+// write, and IDs increase in commit order across all the objects of the table. Records of the same object are
+// chained through Previous.
 type DB interface {
+	// Transaction runs do in a transaction, the DB methods called with the context given to do are part of it.
+	// Transactions started within do are part of the outer one.
 	Transaction(ctx context.Context, do func(ctx context.Context) error) error
+	// Watch sends the records matching criteria until ctx is done, then closes the channel. Records without a name
+	// are bookmarks with the latest resource version.
 	Watch(ctx context.Context, criteria WatchCriteria) (chan Record, error)
+	// Get returns the latest record at Before, or at the latest resource version if Before is zero, of every object
+	// matching criteria, ordered by ID, along with the latest resource version of the table.
 	Get(ctx context.Context, criteria Criteria) ([]Record, uint, error)
+	// Insert appends rec, setting its ID. It fails with errtypes.ErrDuplicateEntry if another record has the same
+	// Previous, which the strategy reports as a conflict.
 	Insert(ctx context.Context, rec *Record) error
+	// Start is called once when the strategy is created, the DB runs until ctx is done.
 	Start(ctx context.Context) error
 }
+
+// BatchInserter is implemented by a DB that can append several records in one write, Strategy.DeleteCollection writes
+// its removal records with it.
+type BatchInserter interface {
+	// InsertBatch appends recs like Insert does, in order.
+	InsertBatch(ctx context.Context, recs []*Record) error
+}
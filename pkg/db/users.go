@@ -0,0 +1,48 @@
+package db
+
+import (
+	"context"
+
+	"k8s.io/apiserver/pkg/endpoints/request"
+)
+
+const (
+	// CreatedByAnnotation is the name of the user that created an object, for strategies created WithUserTracking.
+	CreatedByAnnotation = "mink.acorn.io/created-by"
+	// UpdatedByAnnotation is the name of the user that wrote the current version of an object, for strategies created
+	// WithUserTracking. Deleting an object is a write, so for deleted objects it is the user that deleted it.
+	UpdatedByAnnotation = "mink.acorn.io/updated-by"
+)
+
+// WithUserTracking records the user of the request that wrote each version of an object in the created_by and
+// updated_by columns of its record, which are added to the table when it starts, and serves them in the CreatedByAnnotation and UpdatedByAnnotation. Unlike audit
+// logging it is kept with the history of the object, so GetRevision tells who wrote each revision. Writes made
+// without a user, such as those of controllers calling the strategy directly, and writes made before the option was
+// enabled record no user.
+func WithUserTracking() StrategyOption {
+	return func(s *Strategy) {
+		s.trackUsers = true
+		if g, ok := s.db.(*GormDB); ok {
+			g.userColumns = true
+		}
+	}
+}
+
+// setUsers sets the users of a record written by the request of ctx, existing is the previous version of the object
+// or nil if it is created.
+func (s *Strategy) setUsers(ctx context.Context, rec, existing *Record) {
+	if existing != nil {
+		rec.CreatedBy = existing.CreatedBy
+	}
+	if !s.trackUsers {
+		return
+	}
+	var name string
+	if u, ok := request.UserFrom(ctx); ok {
+		name = u.GetName()
+	}
+	if existing == nil {
+		rec.CreatedBy = name
+	}
+	rec.UpdatedBy = name
+}
@@ -0,0 +1,67 @@
+package db
+
+// WatchOverflowPolicy is what happens to a watch whose buffer is full because its client reads events slower than
+// the table is written.
+type WatchOverflowPolicy string
+
+const (
+	// WatchOverflowResync ends the watch with a 410 Expired error, which makes clients such as informers list again
+	// and watch from the resource version of the list.
+	WatchOverflowResync WatchOverflowPolicy = "resync"
+	// WatchOverflowClose ends the watch without an error, clients watch again from the last resource version they
+	// received.
+	WatchOverflowClose WatchOverflowPolicy = "close"
+)
+
+// defaultWatchBufferSize is large enough for a watch to ride out bursts of thousands of writes, such as a
+// DeleteCollection or a controller reconciling every object, without being resynced. The buffer only grows while its
+// client is behind, watches that keep up cost nothing.
+const defaultWatchBufferSize = 10000
+
+// bufferRecords reads the records of a subscription into a buffer of up to size records, so the broadcaster, which sends
+// to every subscription while holding its lock, never waits on a watch whose client is slow. When the buffer is full
+// overflow is called to close the subscription, and the returned channel ends with the buffered records followed by
+// a record with Overflowed set.
+func bufferRecords(in <-chan Record, size int, overflow func()) chan Record {
+	out := make(chan Record)
+	go func() {
+		defer close(out)
+
+		// the queue grows as needed, most watches keep up and never buffer more than a few records
+		var queue []Record
+		for in != nil || len(queue) > 0 {
+			var (
+				send chan<- Record
+				next Record
+			)
+			if len(queue) > 0 {
+				send = out
+				next = queue[0]
+			}
+
+			select {
+			case rec, ok := <-in:
+				if !ok {
+					in = nil
+					continue
+				}
+				if len(queue) == size {
+					overflow()
+					for _, rec := range queue {
+						out <- rec
+					}
+					out <- Record{Overflowed: true}
+					return
+				}
+				queue = append(queue, rec)
+			case send <- next:
+				queue[0] = Record{}
+				queue = queue[1:]
+				if len(queue) == 0 {
+					queue = nil
+				}
+			}
+		}
+	}()
+	return out
+}
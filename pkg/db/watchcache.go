@@ -0,0 +1,231 @@
+package db
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+// cachedRecordOverhead is the approximate memory taken by a cached record besides its variable length fields.
+const cachedRecordOverhead = 300
+
+// WithWatchCache keeps the latest state of every object of the tables of the factory in memory, so that new watches
+// and lists with resource version 0 that aren't paged and don't select fields, such as those of informers reconnecting after a restart, are served from memory
+// rather than by reading the matching records from the database. The cache of a table is loaded on first use and kept
+// current by its watch loop, a list with resource version 0 may then miss the writes of the last couple of seconds,
+// as Kubernetes allows.
+//
+// maxBytes bounds the memory of the caches of all the tables together, zero means no limit. A table whose cache
+// would go over the limit drops it and is read from the database from then on. Tables with partition keys are never
+// cached, so that destroying a key makes their records unreadable right away, see WithPartitionKeys.
+func WithWatchCache(maxBytes int64) FactoryOption {
+	return WithStrategyOptions(withWatchCache(&watchCacheBudget{max: maxBytes}))
+}
+
+func withWatchCache(budget *watchCacheBudget) StrategyOption {
+	return func(s *Strategy) {
+		if g, ok := s.db.(*GormDB); ok {
+			g.cache = &watchCache{budget: budget}
+		}
+	}
+}
+
+// watchCacheBudget is the memory shared by the caches of the tables of a factory.
+type watchCacheBudget struct {
+	max  int64
+	used atomic.Int64
+}
+
+// reserve reserves n bytes, it returns false if that would go over the budget. Releasing memory always succeeds.
+func (b *watchCacheBudget) reserve(n int64) bool {
+	if b.used.Add(n) > b.max && b.max > 0 && n > 0 {
+		b.used.Add(-n)
+		return false
+	}
+	return true
+}
+
+// watchCache is the latest state of every live object of a table, see WithWatchCache.
+type watchCache struct {
+	budget *watchCacheBudget
+
+	lock sync.RWMutex
+	// seen is the ID of the last record read by the watch loop, the resource version of the cache once it is loaded.
+	seen    uint
+	loaded  bool
+	records map[string]Record
+	size    int64
+	// disabled is set once the cache went over the budget.
+	disabled bool
+}
+
+func cachedRecordSize(rec Record) int64 {
+	return int64(len(rec.Metadata)+len(rec.Data)+len(rec.Status)+len(rec.Kind)+len(rec.Version)+len(rec.APIGroup)+
+		2*(len(rec.Name)+len(rec.Namespace)+len(rec.PartitionID))+len(rec.UID)+len(rec.CreatedBy)+len(rec.UpdatedBy)) +
+		cachedRecordOverhead
+}
+
+// watchCache returns the cache of the table, or nil if it has none.
+func (g *GormDB) watchCache() *watchCache {
+	if g.cache == nil || g.partitionKeys != nil {
+		return nil
+	}
+	return g.cache
+}
+
+// applyToCache updates the cache with a record read by the watch loop. It must be called before the record is
+// broadcast, so that watches started from the cache get every record the cache doesn't include.
+func (g *GormDB) applyToCache(rec Record) {
+	c := g.watchCache()
+	if c == nil {
+		return
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if rec.ID > c.seen {
+		c.seen = rec.ID
+	}
+	if !c.loaded || rec.Name == "" {
+		return
+	}
+
+	key := snapshotKey(rec)
+	var size int64
+	if previous, ok := c.records[key]; ok {
+		size = -cachedRecordSize(previous)
+	}
+	if rec.Removed == nil {
+		size += cachedRecordSize(rec)
+	}
+	if !c.budget.reserve(size) {
+		g.disableCache(c)
+		return
+	}
+	c.size += size
+	if rec.Removed == nil {
+		c.records[key] = rec
+	} else {
+		delete(c.records, key)
+	}
+}
+
+// disableCache drops the records of c, it must be called while holding the lock of c.
+func (g *GormDB) disableCache(c *watchCache) {
+	g.log.Warnf("The watch cache of [%s] went over its limit of %d bytes, [%s] is read from the database from now on",
+		g.tableName, c.budget.max, g.tableName)
+	c.budget.reserve(-c.size)
+	c.records = nil
+	c.size = 0
+	c.loaded = false
+	c.disabled = true
+}
+
+// loadCache reads the latest state of the table at the ID the watch loop got to into the cache, unless it is loaded.
+// The watch loop waits for it to finish.
+func (g *GormDB) loadCache(ctx context.Context, c *watchCache) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.loaded || c.disabled {
+		return nil
+	}
+
+	var (
+		records       = map[string]Record{}
+		size          int64
+		before, after = c.seen, uint(0)
+	)
+	for {
+		resp, newBefore, err := g.Get(ctx, Criteria{
+			After:                 after,
+			Before:                before,
+			Limit:                 1000,
+			ignoreCompactionCheck: true,
+		})
+		if err != nil {
+			c.budget.reserve(-size)
+			return err
+		}
+		for _, rec := range resp {
+			recordSize := cachedRecordSize(rec)
+			if !c.budget.reserve(recordSize) {
+				c.size = size
+				g.disableCache(c)
+				return nil
+			}
+			size += recordSize
+			records[snapshotKey(rec)] = rec
+		}
+		if len(resp) < 1000 {
+			break
+		}
+		before = newBefore
+		after = resp[len(resp)-1].ID
+	}
+
+	c.records = records
+	c.size = size
+	c.loaded = true
+	g.log.Debugf("Loaded %d records of [%s] into the watch cache at %d", len(records), g.tableName, c.seen)
+	return nil
+}
+
+// cachedRecords returns the cached records matching criteria ordered by ID, along with the resource version of the
+// cache. It returns false if the table isn't cached.
+func (g *GormDB) cachedRecords(ctx context.Context, criteria WatchCriteria) ([]Record, uint, bool, error) {
+	c := g.watchCache()
+	if c == nil {
+		return nil, 0, false, nil
+	}
+	if err := g.loadCache(ctx, c); err != nil {
+		return nil, 0, false, err
+	}
+
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	if !c.loaded {
+		return nil, 0, false, nil
+	}
+
+	var result []Record
+	for _, rec := range c.records {
+		if snapshotMatches(rec, criteria) {
+			result = append(result, rec)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].ID < result[j].ID
+	})
+	return result, c.seen, true, nil
+}
+
+// initializeWatchFromCache sends the cached records matching criteria, it returns false if the table isn't cached.
+func (g *GormDB) initializeWatchFromCache(ctx context.Context, criteria WatchCriteria, result chan<- Record) (bool, error) {
+	records, _, ok, err := g.cachedRecords(ctx, criteria)
+	if !ok || err != nil {
+		return ok, err
+	}
+	for _, record := range records {
+		record.InitialState = true
+		result <- record
+	}
+	return true, nil
+}
+
+// getCached serves a Get from the cache, see Criteria.cached, it returns false if the table isn't cached. Criteria
+// with a limit or a field selector are never cached, the selector must apply before the limit.
+func (g *GormDB) getCached(ctx context.Context, criteria Criteria) ([]Record, uint, bool, error) {
+	if criteria.Limit > 0 || criteria.FieldSelector != nil && !criteria.FieldSelector.Empty() {
+		return nil, 0, false, nil
+	}
+	return g.cachedRecords(ctx, WatchCriteria{
+		Name:          criteria.Name,
+		Namespace:     criteria.Namespace,
+		Namespaces:    criteria.Namespaces,
+		LabelSelector: criteria.LabelSelector,
+		PartitionID:   criteria.PartitionID,
+	})
+}
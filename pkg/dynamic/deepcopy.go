@@ -0,0 +1,66 @@
+package dynamic
+
+import (
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func (in *ResourceDefinition) DeepCopyInto(out *ResourceDefinition) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	out.Status = in.Status
+}
+
+func (in *ResourceDefinition) DeepCopy() *ResourceDefinition {
+	if in == nil {
+		return nil
+	}
+	out := new(ResourceDefinition)
+	in.DeepCopyInto(out)
+	return out
+}
+
+func (in *ResourceDefinition) DeepCopyObject() runtime.Object {
+	return in.DeepCopy()
+}
+
+func (in *ResourceDefinitionSpec) DeepCopyInto(out *ResourceDefinitionSpec) {
+	*out = *in
+	if in.ShortNames != nil {
+		out.ShortNames = make([]string, len(in.ShortNames))
+		copy(out.ShortNames, in.ShortNames)
+	}
+	if in.Categories != nil {
+		out.Categories = make([]string, len(in.Categories))
+		copy(out.Categories, in.Categories)
+	}
+	if in.Schema != nil {
+		out.Schema = in.Schema.DeepCopy()
+	}
+}
+
+func (in *ResourceDefinitionList) DeepCopyInto(out *ResourceDefinitionList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		out.Items = make([]ResourceDefinition, len(in.Items))
+		for i := range in.Items {
+			in.Items[i].DeepCopyInto(&out.Items[i])
+		}
+	}
+}
+
+func (in *ResourceDefinitionList) DeepCopy() *ResourceDefinitionList {
+	if in == nil {
+		return nil
+	}
+	out := new(ResourceDefinitionList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+func (in *ResourceDefinitionList) DeepCopyObject() runtime.Object {
+	return in.DeepCopy()
+}
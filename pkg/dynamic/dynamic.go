@@ -0,0 +1,162 @@
+package dynamic
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/acorn-io/mink/pkg/stores"
+	"github.com/acorn-io/mink/pkg/strategy"
+	"k8s.io/apimachinery/pkg/api/validation"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	utilvalidation "k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/apiserver/pkg/registry/rest"
+	openapierrors "k8s.io/kube-openapi/pkg/validation/errors"
+	"k8s.io/kube-openapi/pkg/validation/spec"
+	"k8s.io/kube-openapi/pkg/validation/strfmt"
+	"k8s.io/kube-openapi/pkg/validation/validate"
+)
+
+// NewStore returns the storage of resource definitions, persisted by s, which is typically created by a
+// db.Factory. The types must have been added to the scheme with AddToScheme. The resources are served by an
+// Installer watching s.
+func NewStore(scheme *runtime.Scheme, s strategy.CompleteStrategy) rest.Storage {
+	return stores.NewBuilder(scheme, &ResourceDefinition{}).
+		ClusterScoped().
+		WithCompleteCRUD(s).
+		WithPrepareCreate(definitionPreparer{}).
+		WithPrepareUpdate(definitionPreparer{}).
+		WithValidateCreate(definitionValidator{}).
+		WithValidateUpdate(definitionValidator{}).Build()
+}
+
+type definitionPreparer struct{}
+
+func (definitionPreparer) PrepareForCreate(_ context.Context, obj runtime.Object) {
+	obj.(*ResourceDefinition).Status = ResourceDefinitionStatus{}
+}
+
+func (definitionPreparer) PrepareForUpdate(_ context.Context, obj, old runtime.Object) {
+	obj.(*ResourceDefinition).Status = old.(*ResourceDefinition).Status
+}
+
+type definitionValidator struct{}
+
+func (definitionValidator) Validate(_ context.Context, obj runtime.Object) (result field.ErrorList) {
+	def := obj.(*ResourceDefinition)
+	specPath := field.NewPath("spec")
+
+	if name := def.Spec.Plural + "." + def.Spec.Group; def.Name != name {
+		result = append(result, field.Invalid(field.NewPath("metadata", "name"), def.Name, "must be "+name))
+	}
+	if !strings.Contains(def.Spec.Group, ".") {
+		result = append(result, field.Invalid(specPath.Child("group"), def.Spec.Group, "must contain a dot"))
+	}
+	for _, msg := range utilvalidation.IsDNS1123Subdomain(def.Spec.Group) {
+		result = append(result, field.Invalid(specPath.Child("group"), def.Spec.Group, msg))
+	}
+	for _, msg := range utilvalidation.IsDNS1035Label(def.Spec.Version) {
+		result = append(result, field.Invalid(specPath.Child("version"), def.Spec.Version, msg))
+	}
+	for _, msg := range utilvalidation.IsDNS1035Label(def.Spec.Plural) {
+		result = append(result, field.Invalid(specPath.Child("plural"), def.Spec.Plural, msg))
+	}
+	if def.Spec.Singular != "" {
+		for _, msg := range utilvalidation.IsDNS1035Label(def.Spec.Singular) {
+			result = append(result, field.Invalid(specPath.Child("singular"), def.Spec.Singular, msg))
+		}
+	}
+	if def.Spec.Kind == "" {
+		result = append(result, field.Required(specPath.Child("kind"), ""))
+	} else {
+		for _, msg := range utilvalidation.IsDNS1035Label(strings.ToLower(def.Spec.Kind)) {
+			result = append(result, field.Invalid(specPath.Child("kind"), def.Spec.Kind, msg))
+		}
+	}
+	if _, err := parseSchema(def.Spec.Schema); err != nil {
+		result = append(result, field.Invalid(specPath.Child("schema"), "", err.Error()))
+	}
+	return result
+}
+
+// ValidateUpdate rejects changes to the type of the resource, the objects stored as the old type would no longer be
+// readable.
+func (v definitionValidator) ValidateUpdate(ctx context.Context, obj, old runtime.Object) field.ErrorList {
+	result := v.Validate(ctx, obj)
+	def, oldDef := obj.(*ResourceDefinition), old.(*ResourceDefinition)
+	specPath := field.NewPath("spec")
+	result = append(result, validation.ValidateImmutableField(def.Spec.Version, oldDef.Spec.Version, specPath.Child("version"))...)
+	result = append(result, validation.ValidateImmutableField(def.Spec.Kind, oldDef.Spec.Kind, specPath.Child("kind"))...)
+	result = append(result, validation.ValidateImmutableField(def.Spec.Namespaced, oldDef.Spec.Namespaced, specPath.Child("namespaced"))...)
+	return result
+}
+
+func parseSchema(raw *runtime.RawExtension) (*spec.Schema, error) {
+	if raw == nil || len(raw.Raw) == 0 {
+		return nil, nil
+	}
+	result := &spec.Schema{}
+	if err := json.Unmarshal(raw.Raw, result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (d *ResourceDefinition) groupVersionKind() schema.GroupVersionKind {
+	return schema.GroupVersionKind{Group: d.Spec.Group, Version: d.Spec.Version, Kind: d.Spec.Kind}
+}
+
+// objectPreparer restores the type of objects. The API server converts request bodies to the internal version,
+// which clears the apiVersion and kind of unstructured objects.
+type objectPreparer struct {
+	gvk schema.GroupVersionKind
+}
+
+func (p objectPreparer) PrepareForCreate(_ context.Context, obj runtime.Object) {
+	obj.GetObjectKind().SetGroupVersionKind(p.gvk)
+}
+
+func (p objectPreparer) PrepareForUpdate(_ context.Context, obj, _ runtime.Object) {
+	obj.GetObjectKind().SetGroupVersionKind(p.gvk)
+}
+
+// objectValidator validates objects against the schema of their definition.
+type objectValidator struct {
+	validator *validate.SchemaValidator
+}
+
+func newObjectValidator(def *ResourceDefinition) (*objectValidator, error) {
+	s, err := parseSchema(def.Spec.Schema)
+	if err != nil || s == nil {
+		return nil, err
+	}
+	return &objectValidator{
+		validator: validate.NewSchemaValidator(s, nil, "", strfmt.Default),
+	}, nil
+}
+
+func (v *objectValidator) Validate(_ context.Context, obj runtime.Object) (result field.ErrorList) {
+	if v == nil {
+		return nil
+	}
+	u, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		return field.ErrorList{field.InternalError(nil, fmt.Errorf("unexpected type %T", obj))}
+	}
+	for _, err := range v.validator.Validate(u.Object).Errors {
+		if validationErr, ok := err.(*openapierrors.Validation); ok && validationErr.Name != "" {
+			result = append(result, field.Invalid(field.NewPath(validationErr.Name), validationErr.Value, err.Error()))
+		} else {
+			result = append(result, field.Invalid(field.NewPath(""), "", err.Error()))
+		}
+	}
+	return result
+}
+
+func (v *objectValidator) ValidateUpdate(ctx context.Context, obj, _ runtime.Object) field.ErrorList {
+	return v.Validate(ctx, obj)
+}
@@ -0,0 +1,324 @@
+package dynamic
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/acorn-io/mink/pkg/apigroup"
+	"github.com/acorn-io/mink/pkg/db"
+	"github.com/acorn-io/mink/pkg/logging"
+	"github.com/acorn-io/mink/pkg/stores"
+	"github.com/acorn-io/mink/pkg/strategy"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/version"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/apiserver/pkg/registry/rest"
+	genericapiserver "k8s.io/apiserver/pkg/server"
+	"k8s.io/apiserver/pkg/storage"
+	"k8s.io/client-go/util/retry"
+)
+
+// Installer serves the resources defined by ResourceDefinitions on a running API server. Every group of defined
+// resources gets its own scheme, so definitions never change the scheme the server was created with. Objects are
+// stored in tables of the factory named after the resource, deleting a definition stops serving the resource but
+// keeps the table, a new definition of the same resource serves the objects again. Groups the server serves itself
+// can't be defined.
+type Installer struct {
+	factory     *db.Factory
+	definitions strategy.CompleteStrategy
+	server      *genericapiserver.GenericAPIServer
+
+	lock      sync.Mutex
+	resources map[string]*resource
+	installed map[string]bool
+}
+
+type resource struct {
+	definition *ResourceDefinition
+	strategy   strategy.CompleteStrategy
+}
+
+// NewInstaller returns an installer of the definitions stored in definitions, the strategy the definition store was
+// created with, on server. The OpenAPI definitions of the server must include those of GetOpenAPIDefinitions.
+func NewInstaller(factory *db.Factory, definitions strategy.CompleteStrategy, server *genericapiserver.GenericAPIServer) *Installer {
+	return &Installer{
+		factory:     factory,
+		definitions: definitions,
+		server:      server,
+		resources:   map[string]*resource{},
+		installed:   map[string]bool{},
+	}
+}
+
+// Start watches the definitions until ctx is done.
+func (i *Installer) Start(ctx context.Context) {
+	go func() {
+		for {
+			if err := i.watch(ctx); err != nil && ctx.Err() == nil {
+				logging.Default().Errorf("Failed to watch resource definitions: %v", err)
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(time.Second):
+			}
+		}
+	}()
+}
+
+func (i *Installer) watch(ctx context.Context) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	events, err := i.definitions.Watch(ctx, "", storage.ListOptions{
+		Predicate: storage.Everything,
+	})
+	if err != nil {
+		return err
+	}
+
+	for event := range events {
+		switch event.Type {
+		case watch.Added, watch.Modified:
+			def := event.Object.(*ResourceDefinition)
+			if def.DeletionTimestamp.IsZero() {
+				i.apply(ctx, def)
+			} else {
+				i.remove(def)
+			}
+		case watch.Deleted:
+			i.remove(event.Object.(*ResourceDefinition))
+		case watch.Error:
+			return apierrors.FromObject(event.Object)
+		}
+	}
+	return nil
+}
+
+func (i *Installer) apply(ctx context.Context, def *ResourceDefinition) {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+
+	// definitions that failed to install are retried when they or another definition of the group change
+	existing := i.resources[def.Name]
+	if existing != nil && equality.Semantic.DeepEqual(existing.definition.Spec, def.Spec) {
+		return
+	}
+
+	err := i.checkConflicts(def)
+	if err == nil && existing == nil {
+		existing = &resource{}
+		existing.strategy, err = i.newStrategy(def)
+	}
+	if err == nil {
+		existing.definition = def
+		i.resources[def.Name] = existing
+		err = i.installGroup(def.Spec.Group)
+	}
+	if err != nil {
+		logging.Default().Errorf("Failed to serve resource definition [%s]: %v", def.Name, err)
+	}
+	i.setStatus(ctx, def, err)
+}
+
+func (i *Installer) remove(def *ResourceDefinition) {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+
+	existing := i.resources[def.Name]
+	if existing == nil {
+		return
+	}
+	delete(i.resources, def.Name)
+	existing.strategy.Destroy()
+	if err := i.installGroup(def.Spec.Group); err != nil {
+		logging.Default().Errorf("Failed to stop serving resource definition [%s]: %v", def.Name, err)
+	}
+}
+
+func (i *Installer) checkConflicts(def *ResourceDefinition) error {
+	if !i.installed[def.Spec.Group] && len(i.webServices(def.Spec.Group)) > 0 {
+		return fmt.Errorf("group %s is served by the server", def.Spec.Group)
+	}
+	for name, other := range i.resources {
+		if name != def.Name && other.definition.groupVersionKind() == def.groupVersionKind() {
+			return fmt.Errorf("kind %s is defined by %s", def.Spec.Kind, name)
+		}
+	}
+	return nil
+}
+
+func (i *Installer) setStatus(ctx context.Context, def *ResourceDefinition, installErr error) {
+	status := ResourceDefinitionStatus{Served: installErr == nil}
+	if installErr != nil {
+		status.Message = installErr.Error()
+	}
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		obj, err := i.definitions.Get(ctx, "", def.Name)
+		if err != nil {
+			return err
+		}
+		current := obj.(*ResourceDefinition)
+		if current.UID != def.UID || current.Status == status {
+			return nil
+		}
+		current.Status = status
+		_, err = i.definitions.UpdateStatus(ctx, current)
+		return err
+	})
+	if err != nil && !apierrors.IsNotFound(err) {
+		logging.Default().Errorf("Failed to update status of resource definition [%s]: %v", def.Name, err)
+	}
+}
+
+// tableName is unique per resource so that kinds of the same name in different groups don't share a table.
+func tableName(def *ResourceDefinition) string {
+	return def.Spec.Plural + "_" + strings.NewReplacer(".", "_", "-", "_").Replace(def.Spec.Group)
+}
+
+func addUnstructured(scheme *runtime.Scheme, gvk schema.GroupVersionKind) {
+	scheme.AddKnownTypeWithName(gvk, &unstructured.Unstructured{})
+	scheme.AddKnownTypeWithName(gvk.GroupVersion().WithKind(gvk.Kind+"List"), &unstructured.UnstructuredList{})
+	metav1.AddToGroupVersion(scheme, gvk.GroupVersion())
+}
+
+func (i *Installer) newStrategy(def *ResourceDefinition) (strategy.CompleteStrategy, error) {
+	scheme := runtime.NewScheme()
+	addUnstructured(scheme, def.groupVersionKind())
+	obj := &unstructured.Unstructured{}
+	obj.SetGroupVersionKind(def.groupVersionKind())
+	return i.factory.NewDBStrategyForScheme(scheme, obj, tableName(def))
+}
+
+func (i *Installer) newStore(r *resource) (rest.Storage, error) {
+	def := r.definition
+	obj := &unstructured.Unstructured{}
+	obj.SetGroupVersionKind(def.groupVersionKind())
+
+	singular := def.Spec.Singular
+	if singular == "" {
+		singular = strings.ToLower(def.Spec.Kind)
+	}
+	b := stores.NewBuilder(r.strategy.Scheme(), obj).
+		WithCompleteCRUD(r.strategy).
+		WithDeleteCollection(r.strategy).
+		WithPrepareCreate(objectPreparer{gvk: def.groupVersionKind()}).
+		WithPrepareUpdate(objectPreparer{gvk: def.groupVersionKind()}).
+		WithSingularName(singular).
+		WithShortNames(def.Spec.ShortNames...).
+		WithCategories(def.Spec.Categories...)
+	if def.Spec.Namespaced {
+		b = b.Namespaced()
+	} else {
+		b = b.ClusterScoped()
+	}
+
+	validator, err := newObjectValidator(def)
+	if err != nil {
+		return nil, err
+	}
+	if validator != nil {
+		b = b.WithValidateCreate(validator).WithValidateUpdate(validator)
+	}
+	return b.Build(), nil
+}
+
+// installGroup (re)installs all resources of the group. The API server can't add resources to an installed group,
+// so the group is removed and installed again with the current resources.
+func (i *Installer) installGroup(group string) error {
+	versions := map[string]map[string]rest.Storage{}
+	var gvks []schema.GroupVersionKind
+	for _, r := range i.resources {
+		def := r.definition
+		if def.Spec.Group != group {
+			continue
+		}
+		store, err := i.newStore(r)
+		if err != nil {
+			return err
+		}
+		if versions[def.Spec.Version] == nil {
+			versions[def.Spec.Version] = map[string]rest.Storage{}
+		}
+		versions[def.Spec.Version][def.Spec.Plural] = store
+		gvks = append(gvks, def.groupVersionKind())
+	}
+
+	if i.installed[group] {
+		i.uninstallGroup(group)
+		delete(i.installed, group)
+	}
+	if len(versions) == 0 {
+		return nil
+	}
+
+	var (
+		preferred string
+		opts      []apigroup.Option
+	)
+	for v := range versions {
+		if preferred == "" || version.CompareKubeAwareVersionStrings(v, preferred) > 0 {
+			preferred = v
+		}
+	}
+	for v, stores := range versions {
+		if v != preferred {
+			opts = append(opts, apigroup.WithVersion(v, stores))
+		}
+	}
+
+	apiGroup, err := apigroup.ForStores(func(scheme *runtime.Scheme) error {
+		for _, gvk := range gvks {
+			addUnstructured(scheme, gvk)
+		}
+		// the API server decodes request options as v1 options
+		metav1.AddToGroupVersion(scheme, schema.GroupVersion{Version: "v1"})
+		return nil
+	}, versions[preferred], schema.GroupVersion{Group: group, Version: preferred}, opts...)
+	if err != nil {
+		return err
+	}
+	if err := i.server.InstallAPIGroups(apiGroup); err != nil {
+		// InstallAPIGroups may have installed some of the group before failing
+		i.uninstallGroup(group)
+		return err
+	}
+	i.installed[group] = true
+	return nil
+}
+
+func (i *Installer) webServices(group string) (result []string) {
+	prefix := "/apis/" + group
+	for _, ws := range i.server.Handler.GoRestfulContainer.RegisteredWebServices() {
+		if root := ws.RootPath(); root == prefix || strings.HasPrefix(root, prefix+"/") {
+			result = append(result, root)
+		}
+	}
+	return result
+}
+
+func (i *Installer) uninstallGroup(group string) {
+	container := i.server.Handler.GoRestfulContainer
+	roots := i.webServices(group)
+	for _, ws := range container.RegisteredWebServices() {
+		for _, root := range roots {
+			if ws.RootPath() == root {
+				if err := container.Remove(ws); err != nil {
+					logging.Default().Errorf("Failed to remove %s: %v", root, err)
+				}
+			}
+		}
+	}
+	i.server.DiscoveryGroupManager.RemoveGroup(group)
+	if i.server.AggregatedDiscoveryGroupManager != nil {
+		i.server.AggregatedDiscoveryGroupManager.RemoveGroup(group)
+	}
+}
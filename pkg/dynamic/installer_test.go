@@ -0,0 +1,193 @@
+package dynamic
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/acorn-io/mink/pkg/db"
+	"github.com/acorn-io/mink/pkg/server"
+	"github.com/acorn-io/mink/pkg/strategy"
+	"github.com/emicklei/go-restful/v3"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/runtime/serializer"
+)
+
+func newTestInstaller(t *testing.T) (*Installer, strategy.CompleteStrategy) {
+	scheme := runtime.NewScheme()
+	if err := AddToScheme(scheme, schema.GroupVersion{Group: "definitions.test", Version: "v1"}); err != nil {
+		t.Fatal(err)
+	}
+	metav1.AddToGroupVersion(scheme, schema.GroupVersion{Version: "v1"})
+
+	f, err := db.NewFactory(scheme, "sqlite://file:"+t.Name()+"?mode=memory&cache=shared")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = f.SQLDB.Close()
+	})
+	definitions, err := f.NewDBStrategy(&ResourceDefinition{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(definitions.Destroy)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = listener.Close()
+	})
+	codecs := serializer.NewCodecFactory(scheme)
+	// the self-signed serving certificate is written to a temporary directory instead of the package directory
+	opts := server.DefaultOpts()
+	opts.SecureServing.ServerCert.CertDirectory = t.TempDir()
+	s, err := server.New(&server.Config{
+		Name:           "installer-test",
+		Scheme:         scheme,
+		CodecFactory:   &codecs,
+		Listener:       listener,
+		OpenAPIConfig:  GetOpenAPIDefinitions,
+		DefaultOptions: opts,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewInstaller(f, definitions, s.GenericAPIServer), definitions
+}
+
+func newDefinition(group, kind, plural string) *ResourceDefinition {
+	return &ResourceDefinition{
+		ObjectMeta: metav1.ObjectMeta{Name: plural + "." + group},
+		Spec: ResourceDefinitionSpec{
+			Group:      group,
+			Version:    "v1",
+			Kind:       kind,
+			Plural:     plural,
+			Namespaced: true,
+		},
+	}
+}
+
+func waitForStatus(t *testing.T, definitions strategy.CompleteStrategy, name string, served bool) ResourceDefinitionStatus {
+	var status ResourceDefinitionStatus
+	assert.Eventually(t, func() bool {
+		obj, err := definitions.Get(context.Background(), "", name)
+		if err != nil {
+			return false
+		}
+		status = obj.(*ResourceDefinition).Status
+		return status.Served == served && (served || status.Message != "")
+	}, 5*time.Second, 10*time.Millisecond, name)
+	return status
+}
+
+// deleteDefinition deletes the definition like the delete of the API does, at its current resource version as the
+// installer updates its status.
+func deleteDefinition(t *testing.T, definitions strategy.CompleteStrategy, name string) {
+	obj, err := definitions.Get(context.Background(), "", name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	obj.SetDeletionTimestamp(&metav1.Time{Time: time.Now()})
+	if _, err := definitions.Delete(context.Background(), obj); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestInstaller(t *testing.T) {
+	installer, definitions := newTestInstaller(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	installer.Start(ctx)
+
+	// a definition is served in its group
+	widgets, err := definitions.Create(ctx, newDefinition("widgets.test", "Widget", "widgets"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitForStatus(t, definitions, widgets.GetName(), true)
+	assert.ElementsMatch(t, []string{"/apis/widgets.test", "/apis/widgets.test/v1"}, installer.webServices("widgets.test"))
+
+	// another resource of the group is served next to it
+	gadgets, err := definitions.Create(ctx, newDefinition("widgets.test", "Gadget", "gadgets"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitForStatus(t, definitions, gadgets.GetName(), true)
+	installer.lock.Lock()
+	assert.Len(t, installer.resources, 2)
+	installer.lock.Unlock()
+
+	// deleting a definition stops serving its resource, the group is served as long as it has resources
+	deleteDefinition(t, definitions, gadgets.GetName())
+	assert.Eventually(t, func() bool {
+		installer.lock.Lock()
+		defer installer.lock.Unlock()
+		return installer.resources[gadgets.GetName()] == nil
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.ElementsMatch(t, []string{"/apis/widgets.test", "/apis/widgets.test/v1"}, installer.webServices("widgets.test"))
+
+	deleteDefinition(t, definitions, widgets.GetName())
+	assert.Eventually(t, func() bool {
+		installer.lock.Lock()
+		defer installer.lock.Unlock()
+		return len(installer.webServices("widgets.test")) == 0 && !installer.installed["widgets.test"]
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestInstallerConflicts(t *testing.T) {
+	installer, definitions := newTestInstaller(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	installer.Start(ctx)
+
+	widgets, err := definitions.Create(ctx, newDefinition("widgets.test", "Widget", "widgets"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitForStatus(t, definitions, widgets.GetName(), true)
+
+	// a second definition of the same kind isn't served
+	duplicate, err := definitions.Create(ctx, newDefinition("widgets.test", "Widget", "otherwidgets"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	status := waitForStatus(t, definitions, duplicate.GetName(), false)
+	assert.Contains(t, status.Message, "kind Widget is defined by widgets.widgets.test")
+
+	// groups the server serves itself can't be defined
+	installer.lock.Lock()
+	installer.server.Handler.GoRestfulContainer.Add(newWebService("/apis/builtin.test/v1"))
+	installer.lock.Unlock()
+	builtin, err := definitions.Create(ctx, newDefinition("builtin.test", "Builtin", "builtins"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	status = waitForStatus(t, definitions, builtin.GetName(), false)
+	assert.Contains(t, status.Message, "group builtin.test is served by the server")
+
+	// once the conflicting definition is gone, the duplicate is served when it changes
+	deleteDefinition(t, definitions, widgets.GetName())
+	obj, err := definitions.Get(ctx, "", duplicate.GetName())
+	if err != nil {
+		t.Fatal(err)
+	}
+	obj.(*ResourceDefinition).Spec.ShortNames = []string{"ow"}
+	if _, err := definitions.Update(ctx, obj); err != nil {
+		t.Fatal(err)
+	}
+	waitForStatus(t, definitions, duplicate.GetName(), true)
+}
+
+func newWebService(root string) *restful.WebService {
+	ws := new(restful.WebService)
+	ws.Path(root)
+	return ws
+}
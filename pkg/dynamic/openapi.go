@@ -0,0 +1,101 @@
+package dynamic
+
+import (
+	"k8s.io/kube-openapi/pkg/common"
+	"k8s.io/kube-openapi/pkg/validation/spec"
+)
+
+const pkgPath = "github.com/acorn-io/mink/pkg/dynamic."
+
+const unstructuredType = "k8s.io/apimachinery/pkg/apis/meta/v1/unstructured.Unstructured"
+
+// GetOpenAPIDefinitions returns the OpenAPI definitions of the ResourceDefinition types, merge them into the
+// definitions of the server's OpenAPIConfig when serving resource definitions. The resources they define share a
+// single untyped definition, which the API server requires to install them, they are not published in the OpenAPI
+// documents of the server.
+func GetOpenAPIDefinitions(ref common.ReferenceCallback) map[string]common.OpenAPIDefinition {
+	return map[string]common.OpenAPIDefinition{
+		unstructuredType: {
+			Schema: spec.Schema{
+				SchemaProps: spec.SchemaProps{
+					Description: "An object of a resource defined by a ResourceDefinition.",
+					Type:        []string{"object"},
+				},
+				VendorExtensible: spec.VendorExtensible{
+					Extensions: spec.Extensions{"x-kubernetes-preserve-unknown-fields": true},
+				},
+			},
+		},
+		pkgPath + "ResourceDefinition": {
+			Schema: spec.Schema{
+				SchemaProps: spec.SchemaProps{
+					Description: "ResourceDefinition defines a resource type served by the running server.",
+					Type:        []string{"object"},
+					Properties: map[string]spec.Schema{
+						"kind":       *spec.StringProperty(),
+						"apiVersion": *spec.StringProperty(),
+						"metadata":   {SchemaProps: spec.SchemaProps{Default: map[string]interface{}{}, Ref: ref("k8s.io/apimachinery/pkg/apis/meta/v1.ObjectMeta")}},
+						"spec":       {SchemaProps: spec.SchemaProps{Default: map[string]interface{}{}, Ref: ref(pkgPath + "ResourceDefinitionSpec")}},
+						"status":     {SchemaProps: spec.SchemaProps{Default: map[string]interface{}{}, Ref: ref(pkgPath + "ResourceDefinitionStatus")}},
+					},
+				},
+			},
+			Dependencies: []string{pkgPath + "ResourceDefinitionSpec", pkgPath + "ResourceDefinitionStatus", "k8s.io/apimachinery/pkg/apis/meta/v1.ObjectMeta"},
+		},
+		pkgPath + "ResourceDefinitionList": {
+			Schema: spec.Schema{
+				SchemaProps: spec.SchemaProps{
+					Type: []string{"object"},
+					Properties: map[string]spec.Schema{
+						"kind":       *spec.StringProperty(),
+						"apiVersion": *spec.StringProperty(),
+						"metadata":   {SchemaProps: spec.SchemaProps{Default: map[string]interface{}{}, Ref: ref("k8s.io/apimachinery/pkg/apis/meta/v1.ListMeta")}},
+						"items": *spec.ArrayProperty(&spec.Schema{
+							SchemaProps: spec.SchemaProps{Default: map[string]interface{}{}, Ref: ref(pkgPath + "ResourceDefinition")},
+						}),
+					},
+					Required: []string{"items"},
+				},
+			},
+			Dependencies: []string{pkgPath + "ResourceDefinition", "k8s.io/apimachinery/pkg/apis/meta/v1.ListMeta"},
+		},
+		pkgPath + "ResourceDefinitionSpec": {
+			Schema: spec.Schema{
+				SchemaProps: spec.SchemaProps{
+					Type: []string{"object"},
+					Properties: map[string]spec.Schema{
+						"group":      *spec.StringProperty(),
+						"version":    *spec.StringProperty(),
+						"kind":       *spec.StringProperty(),
+						"plural":     *spec.StringProperty(),
+						"singular":   *spec.StringProperty(),
+						"shortNames": *spec.ArrayProperty(spec.StringProperty()),
+						"categories": *spec.ArrayProperty(spec.StringProperty()),
+						"namespaced": *spec.BooleanProperty(),
+						"schema": {
+							SchemaProps: spec.SchemaProps{
+								Description: "Schema is the OpenAPI v3 schema objects are validated against.",
+								Type:        []string{"object"},
+							},
+							VendorExtensible: spec.VendorExtensible{
+								Extensions: spec.Extensions{"x-kubernetes-preserve-unknown-fields": true},
+							},
+						},
+					},
+					Required: []string{"group", "version", "kind", "plural"},
+				},
+			},
+		},
+		pkgPath + "ResourceDefinitionStatus": {
+			Schema: spec.Schema{
+				SchemaProps: spec.SchemaProps{
+					Type: []string{"object"},
+					Properties: map[string]spec.Schema{
+						"served":  *spec.BooleanProperty().WithDescription("Served is true once the resource is served."),
+						"message": *spec.StringProperty().WithDescription("Message explains why the resource isn't served."),
+					},
+				},
+			},
+		},
+	}
+}
@@ -0,0 +1,52 @@
+package dynamic
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// AddToScheme registers the ResourceDefinition types in the group version the embedder serves them in.
+func AddToScheme(scheme *runtime.Scheme, gv schema.GroupVersion) error {
+	scheme.AddKnownTypes(gv, &ResourceDefinition{}, &ResourceDefinitionList{})
+	return nil
+}
+
+// ResourceDefinition defines a resource type served by the running server, much like a CustomResourceDefinition.
+// Its name must be "<plural>.<group>".
+type ResourceDefinition struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   ResourceDefinitionSpec   `json:"spec,omitempty"`
+	Status ResourceDefinitionStatus `json:"status,omitempty"`
+}
+
+type ResourceDefinitionSpec struct {
+	Group    string `json:"group"`
+	Version  string `json:"version"`
+	Kind     string `json:"kind"`
+	Plural   string `json:"plural"`
+	Singular string `json:"singular,omitempty"`
+	// ShortNames and Categories are published in discovery, for kubectl.
+	ShortNames []string `json:"shortNames,omitempty"`
+	Categories []string `json:"categories,omitempty"`
+	Namespaced bool     `json:"namespaced,omitempty"`
+	// Schema is the OpenAPI v3 schema objects are validated against on create and update. Without it any object is
+	// accepted.
+	Schema *runtime.RawExtension `json:"schema,omitempty"`
+}
+
+type ResourceDefinitionStatus struct {
+	// Served is true once the resource is served.
+	Served bool `json:"served,omitempty"`
+	// Message explains why the resource isn't served.
+	Message string `json:"message,omitempty"`
+}
+
+type ResourceDefinitionList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+
+	Items []ResourceDefinition `json:"items"`
+}
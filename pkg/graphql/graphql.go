@@ -0,0 +1,481 @@
+// Package graphql serves a read-only GraphQL gateway over the resources of the server, for user interfaces that
+// prefer GraphQL to the list and watch protocol of Kubernetes. Every resource that can be listed gets a query field
+// listing its objects and, under its singular name, a query field getting one, and a subscription field streaming its
+// changes from a watch. Like the dashboard, the gateway queries the server through its loopback client as the
+// requesting user, so users only see the objects they are allowed to read.
+//
+// The schema is generated from the discovery and the OpenAPI v3 schemas the server publishes, and is rebuilt with the
+// discovery every loopback.DiscoveryTTL. Objects are typed by their OpenAPI schema, the parts the schema leaves open,
+// such as maps, are of the JSON scalar and returned whole. The schema can be introspected, so GraphQL tools can be used
+// with the gateway.
+package graphql
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/acorn-io/mink/pkg/loopback"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/apiserver/pkg/server"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/openapi3"
+)
+
+// maxLimit is the largest page a list query returns.
+const maxLimit = 1000
+
+// Request is a GraphQL request, posted as JSON or passed as the query, variables and operationName query parameters
+// of a GET.
+type Request struct {
+	Query         string         `json:"query"`
+	Variables     map[string]any `json:"variables,omitempty"`
+	OperationName string         `json:"operationName,omitempty"`
+}
+
+// Response is a GraphQL response. Data has the fields that could be resolved, Errors the errors of the others.
+type Response struct {
+	Data   any     `json:"data"`
+	Errors []Error `json:"errors,omitempty"`
+}
+
+type Error struct {
+	Message string `json:"message"`
+	// Path is the response key of the root field that failed.
+	Path []string `json:"path,omitempty"`
+}
+
+// resource is a resource served by the gateway.
+type resource struct {
+	gvr        schema.GroupVersionResource
+	namespaced bool
+	watch      bool
+}
+
+// Gateway serves the GraphQL endpoint. Its handler answers 503 until PostStart ran.
+type Gateway struct {
+	loopback *loopback.Client
+
+	lock   sync.Mutex
+	schema *schemaDef
+	built  time.Time
+}
+
+func New() *Gateway {
+	return &Gateway{
+		loopback: loopback.New("GraphQL gateway"),
+	}
+}
+
+// PostStart takes the loopback client configuration of the server, it must be called from the post start hook of the
+// server, see server.Config.PostStartFunc.
+func (g *Gateway) PostStart(ctx server.PostStartHookContext) error {
+	return g.loopback.PostStart(ctx)
+}
+
+// currentSchema returns the schema, built again once it is older than the discovery.
+func (g *Gateway) currentSchema() (*schemaDef, error) {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	if g.schema != nil && time.Since(g.built) < loopback.DiscoveryTTL {
+		return g.schema, nil
+	}
+
+	lists, err := g.loopback.PreferredResources()
+	if err != nil {
+		return nil, err
+	}
+	client, err := g.loopback.Discovery()
+	if err != nil {
+		return nil, err
+	}
+	g.schema = buildSchema(lists, openapi3.NewRoot(client.OpenAPIV3()))
+	g.built = time.Now()
+	return g.schema, nil
+}
+
+// Handler returns the handler of the endpoint, to be added to server.Config.PathHandlers at a path such as /graphql,
+// which the authorizer must allow as a non-resource URL. Queries are answered with JSON. Subscriptions are streamed as
+// server-sent events, a next event with a response for every change and a complete event when the watch ends.
+//
+// The query fields of a resource, such as pods or deployments_apps, are named after the resource, followed by its
+// group for groups other than the core group, with dots and dashes replaced by underscores. Their types are named after
+// the OpenAPI schemas, such as io_k8s_api_core_v1_Pod:
+//
+//   - pods(namespace, labelSelector, fieldSelector, limit, continue) lists the objects, as a list with items, continue
+//     and resourceVersion fields.
+//   - pod(name, namespace) gets an object. Resources whose singular name is their name have no get field.
+//
+// The subscription field pods(namespace, labelSelector, fieldSelector, resourceVersion) streams the changes, as events
+// with type and object fields.
+//
+// Fragments are supported, directives and mutations are not.
+func (g *Gateway) Handler() http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		userConfig, err := g.loopback.ForRequest(req)
+		var s *schemaDef
+		if err == nil {
+			s, err = g.currentSchema()
+		}
+		if err != nil {
+			code := http.StatusInternalServerError
+			var status apierrors.APIStatus
+			if errors.As(err, &status) && status.Status().Code != 0 {
+				code = int(status.Status().Code)
+			}
+			writeResponse(rw, code, &Response{Errors: []Error{{Message: err.Error()}}})
+			return
+		}
+		client, err := dynamic.NewForConfig(userConfig)
+		if err != nil {
+			writeResponse(rw, http.StatusInternalServerError, &Response{Errors: []Error{{Message: err.Error()}}})
+			return
+		}
+
+		gqlReq, err := readRequest(rw, req)
+		if err != nil {
+			writeResponse(rw, http.StatusBadRequest, &Response{Errors: []Error{{Message: err.Error()}}})
+			return
+		}
+		operations, err := parse(gqlReq.Query)
+		if err == nil {
+			var op operation
+			if op, err = selectOperation(operations, gqlReq.OperationName); err == nil {
+				err = execute(rw, req, s, client, op, gqlReq.Variables)
+			}
+		}
+		if err != nil {
+			writeResponse(rw, http.StatusBadRequest, &Response{Errors: []Error{{Message: err.Error()}}})
+		}
+	})
+}
+
+func readRequest(rw http.ResponseWriter, req *http.Request) (*Request, error) {
+	switch req.Method {
+	case http.MethodGet:
+		query := req.URL.Query()
+		result := &Request{
+			Query:         query.Get("query"),
+			OperationName: query.Get("operationName"),
+		}
+		if variables := query.Get("variables"); variables != "" {
+			if err := json.Unmarshal([]byte(variables), &result.Variables); err != nil {
+				return nil, fmt.Errorf("invalid variables: %w", err)
+			}
+		}
+		return result, nil
+	case http.MethodPost:
+		result := &Request{}
+		if err := json.NewDecoder(http.MaxBytesReader(rw, req.Body, 1<<20)).Decode(result); err != nil {
+			return nil, fmt.Errorf("invalid request: %w", err)
+		}
+		return result, nil
+	}
+	return nil, fmt.Errorf("only GET and POST are supported")
+}
+
+func writeResponse(rw http.ResponseWriter, code int, resp *Response) {
+	rw.Header().Set("Content-Type", "application/json")
+	rw.WriteHeader(code)
+	_ = json.NewEncoder(rw).Encode(resp)
+}
+
+// execute runs op, it only returns errors of the request, errors of fields are part of the response.
+func execute(rw http.ResponseWriter, req *http.Request, s *schemaDef, client dynamic.Interface, op operation, variables map[string]any) error {
+	vars := map[string]any{}
+	for name, value := range op.defaults {
+		vars[name] = value
+	}
+	for name, value := range variables {
+		vars[name] = value
+	}
+
+	rootName := queryType
+	if op.typ == "subscription" {
+		rootName = subscriptionType
+	}
+	root, ok := s.types[rootName]
+	if !ok {
+		return fmt.Errorf("the schema has no %s type", rootName)
+	}
+	if err := s.validate(named(rootName), op.selections, ""); err != nil {
+		return err
+	}
+
+	if op.typ == "subscription" {
+		if len(op.selections) != 1 || op.selections[0].name == "__typename" {
+			return fmt.Errorf("subscriptions must select exactly one field")
+		}
+		f := op.selections[0]
+		subscribe(rw, req, s, client, s.watches[f.name], root.field(f.name), f, args(f, vars))
+		return nil
+	}
+
+	data := object{}
+	resp := &Response{}
+	for _, f := range op.selections {
+		var (
+			value any
+			err   error
+		)
+		def := s.rootField(root, f.name)
+		switch {
+		case f.name == "__typename":
+			value = queryType
+		case f.name == "__schema":
+			value, _ = s.introspect()
+		case f.name == "__type":
+			var name string
+			if name, err = args(f, vars).string("name"); err == nil {
+				_, types := s.introspect()
+				if t, ok := types[name]; ok {
+					value = t
+				}
+			}
+		case s.lists[f.name] != nil:
+			value, err = list(req.Context(), client, s.lists[f.name], args(f, vars))
+		case s.gets[f.name] != nil:
+			value, err = get(req.Context(), client, s.gets[f.name], args(f, vars))
+		}
+		if err == nil && def != nil {
+			value, err = s.project(value, def.typ, f.selections)
+		}
+		if err != nil {
+			resp.Errors = append(resp.Errors, Error{Message: err.Error(), Path: []string{f.key()}})
+			value = nil
+		}
+		data = append(data, entry{key: f.key(), value: value})
+	}
+	resp.Data = data
+	writeResponse(rw, http.StatusOK, resp)
+	return nil
+}
+
+func fieldName(name, group string) string {
+	if group != "" {
+		name += "_" + group
+	}
+	return strings.NewReplacer(".", "_", "-", "_").Replace(name)
+}
+
+// arguments are the arguments of a root field with the variables resolved.
+type arguments map[string]any
+
+func args(f field, variables map[string]any) arguments {
+	return resolve(f.args, variables).(map[string]any)
+}
+
+func (a arguments) string(name string) (string, error) {
+	switch v := a[name].(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	}
+	return "", fmt.Errorf("argument %s must be a string", name)
+}
+
+func (a arguments) int(name string) (int64, error) {
+	switch v := a[name].(type) {
+	case nil:
+		return 0, nil
+	case int64:
+		return v, nil
+	case float64:
+		if v == float64(int64(v)) {
+			return int64(v), nil
+		}
+	}
+	return 0, fmt.Errorf("argument %s must be an integer", name)
+}
+
+// listOptions returns the namespace and list options given by the arguments. The namespace and selectors are always
+// allowed, the other options only if they are listed in allowed.
+func (a arguments) listOptions(allowed ...string) (string, metav1.ListOptions, error) {
+	var (
+		namespace string
+		opts      metav1.ListOptions
+		err       error
+	)
+	for name := range a {
+		if name != "namespace" && name != "labelSelector" && name != "fieldSelector" && !sets.New(allowed...).Has(name) {
+			return "", opts, fmt.Errorf("unknown argument %s", name)
+		}
+		var value string
+		switch name {
+		case "namespace":
+			namespace, err = a.string(name)
+		case "labelSelector":
+			opts.LabelSelector, err = a.string(name)
+		case "fieldSelector":
+			opts.FieldSelector, err = a.string(name)
+		case "limit":
+			opts.Limit, err = a.int(name)
+			if err == nil && (opts.Limit < 0 || opts.Limit > maxLimit) {
+				err = fmt.Errorf("limit must be between 1 and %d", maxLimit)
+			}
+		case "continue", "resourceVersion":
+			value, err = a.string(name)
+			if name == "continue" {
+				opts.Continue = value
+			} else {
+				opts.ResourceVersion = value
+			}
+		}
+		if err != nil {
+			return "", opts, err
+		}
+	}
+	return namespace, opts, nil
+}
+
+func resourceFor(client dynamic.Interface, r *resource, namespace string) dynamic.ResourceInterface {
+	if r.namespaced && namespace != "" {
+		return client.Resource(r.gvr).Namespace(namespace)
+	}
+	return client.Resource(r.gvr)
+}
+
+func list(ctx context.Context, client dynamic.Interface, r *resource, a arguments) (any, error) {
+	namespace, opts, err := a.listOptions("limit", "continue")
+	if err != nil {
+		return nil, err
+	}
+	if opts.Limit == 0 {
+		opts.Limit = maxLimit
+	}
+	result, err := resourceFor(client, r, namespace).List(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	items := make([]any, 0, len(result.Items))
+	for _, item := range result.Items {
+		items = append(items, item.Object)
+	}
+	return map[string]any{
+		"items":           items,
+		"continue":        result.GetContinue(),
+		"resourceVersion": result.GetResourceVersion(),
+	}, nil
+}
+
+func get(ctx context.Context, client dynamic.Interface, r *resource, a arguments) (any, error) {
+	name, err := a.string("name")
+	if err != nil {
+		return nil, err
+	}
+	namespace, err := a.string("namespace")
+	if err != nil {
+		return nil, err
+	}
+	for arg := range a {
+		if arg != "name" && arg != "namespace" {
+			return nil, fmt.Errorf("unknown argument %s", arg)
+		}
+	}
+	if name == "" {
+		return nil, fmt.Errorf("the name argument is required")
+	}
+	obj, err := resourceFor(client, r, namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+	return obj.Object, nil
+}
+
+// subscribe streams the changes of r, selected by the field f of definition def, as server-sent events until the watch
+// ends or the client goes away.
+func subscribe(rw http.ResponseWriter, req *http.Request, s *schemaDef, client dynamic.Interface, r *resource, def *fieldDef, f field, a arguments) {
+	send := func(event, data string) {
+		_, _ = fmt.Fprintf(rw, "event: %s\ndata: %s\n\n", event, data)
+		_ = http.NewResponseController(rw).Flush()
+	}
+	sendResponse := func(resp *Response) {
+		data, _ := json.Marshal(resp)
+		send("next", string(data))
+	}
+
+	namespace, opts, err := a.listOptions("resourceVersion")
+	var w watch.Interface
+	if err == nil {
+		w, err = resourceFor(client, r, namespace).Watch(req.Context(), opts)
+	}
+	if err != nil {
+		writeResponse(rw, http.StatusOK, &Response{Errors: []Error{{Message: err.Error(), Path: []string{f.key()}}}})
+		return
+	}
+	defer w.Stop()
+
+	rw.Header().Set("Content-Type", "text/event-stream")
+	rw.Header().Set("Cache-Control", "no-cache")
+	rw.WriteHeader(http.StatusOK)
+	_ = http.NewResponseController(rw).Flush()
+
+	for event := range w.ResultChan() {
+		var value any = map[string]any{
+			"type":   string(event.Type),
+			"object": event.Object,
+		}
+		if event.Type == watch.Error {
+			sendResponse(&Response{Errors: []Error{{Message: apierrors.FromObject(event.Object).Error(), Path: []string{f.key()}}}})
+			break
+		}
+		// the object is converted to its JSON fields to be projected
+		data, err := json.Marshal(value)
+		if err == nil {
+			value = nil
+			err = json.Unmarshal(data, &value)
+		}
+		if err == nil {
+			value, err = s.project(value, def.typ, f.selections)
+		}
+		if err != nil {
+			sendResponse(&Response{Errors: []Error{{Message: err.Error(), Path: []string{f.key()}}}})
+			break
+		}
+		sendResponse(&Response{Data: object{{key: f.key(), value: value}}})
+	}
+	send("complete", "")
+}
+
+// object is a JSON object whose fields are kept in order, as GraphQL responses list fields in the order they were
+// selected.
+type object []entry
+
+type entry struct {
+	key   string
+	value any
+}
+
+func (o object) MarshalJSON() ([]byte, error) {
+	buf := &bytes.Buffer{}
+	buf.WriteByte('{')
+	for i, e := range o {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		key, err := json.Marshal(e.key)
+		if err != nil {
+			return nil, err
+		}
+		value, err := json.Marshal(e.value)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,266 @@
+package graphql
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/kube-openapi/pkg/spec3"
+)
+
+func TestParse(t *testing.T) {
+	operations, err := parse(`
+		# the pods of a namespace
+		query Pods($ns: String! = "default", $limit: Int) {
+			running: pods(namespace: $ns, labelSelector: "app=web", limit: $limit) {
+				items { metadata { name } }
+			}
+		}
+		subscription Watch { pods(resourceVersion: "10") { type } }
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Len(t, operations, 2)
+
+	op, err := selectOperation(operations, "Pods")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "query", op.typ)
+	assert.Equal(t, map[string]any{"ns": "default"}, op.defaults)
+	if assert.Len(t, op.selections, 1) {
+		f := op.selections[0]
+		assert.Equal(t, "running", f.key())
+		assert.Equal(t, "pods", f.name)
+		assert.Equal(t, map[string]any{"namespace": "default", "labelSelector": "app=web", "limit": int64(5)},
+			map[string]any(args(f, map[string]any{"ns": "default", "limit": int64(5)})))
+	}
+
+	_, err = selectOperation(operations, "")
+	assert.Error(t, err)
+
+	for _, invalid := range []string{
+		`{ pods { ...fields } }`,
+		`mutation { pods }`,
+		`{ pods(namespace: ) { items } }`,
+		`{ pods { items }`,
+		`{ pods(namespace: "unterminated) }`,
+	} {
+		_, err := parse(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
+
+func TestParseFragments(t *testing.T) {
+	operations, err := parse(`
+		query {
+			pods {
+				items { ...Meta metadata { labels } ... on Pod { spec { nodeName } } }
+			}
+		}
+		fragment Meta on Pod { metadata { name } }
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	items := operations[0].selections[0].selections[0]
+	if assert.Len(t, items.selections, 2) {
+		metadata := items.selections[0]
+		assert.Equal(t, "metadata", metadata.name)
+		// the fields selected by the fragment and the field itself are merged
+		assert.Equal(t, []string{"name", "labels"}, []string{metadata.selections[0].name, metadata.selections[1].name})
+		assert.Equal(t, "spec", items.selections[1].name)
+	}
+
+	for _, invalid := range []string{
+		`{ pods { ...Unknown } }`,
+		`{ pods { ...A } } fragment A on Pod { items { ...A } }`,
+		`{ pods { ...A } } fragment A on Pod { items } fragment A on Pod { items }`,
+		`{ pods { ...A } } fragment A { items }`,
+	} {
+		_, err := parse(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
+
+// fakeRoot serves the OpenAPI documents of group versions.
+type fakeRoot map[schema.GroupVersion]*spec3.OpenAPI
+
+func (r fakeRoot) GroupVersions() ([]schema.GroupVersion, error) {
+	var result []schema.GroupVersion
+	for gv := range r {
+		result = append(result, gv)
+	}
+	return result, nil
+}
+
+func (r fakeRoot) GVSpec(gv schema.GroupVersion) (*spec3.OpenAPI, error) {
+	if doc, ok := r[gv]; ok {
+		return doc, nil
+	}
+	return nil, fmt.Errorf("no OpenAPI document for %s", gv)
+}
+
+func (r fakeRoot) GVSpecAsMap(schema.GroupVersion) (map[string]any, error) {
+	return nil, errors.New("not implemented")
+}
+
+const coreV1Spec = `{
+	"openapi": "3.0.0",
+	"components": {
+		"schemas": {
+			"io.k8s.api.core.v1.Pod": {
+				"type": "object",
+				"x-kubernetes-group-version-kind": [{"group": "", "version": "v1", "kind": "Pod"}],
+				"properties": {
+					"kind": {"type": "string"},
+					"metadata": {"allOf": [{"$ref": "#/components/schemas/io.k8s.apimachinery.pkg.apis.meta.v1.ObjectMeta"}]},
+					"spec": {"$ref": "#/components/schemas/io.k8s.api.core.v1.PodSpec"}
+				}
+			},
+			"io.k8s.apimachinery.pkg.apis.meta.v1.ObjectMeta": {
+				"type": "object",
+				"properties": {
+					"name": {"type": "string"},
+					"labels": {"type": "object", "additionalProperties": {"type": "string"}}
+				}
+			},
+			"io.k8s.api.core.v1.PodSpec": {
+				"type": "object",
+				"properties": {
+					"nodeName": {"type": "string"},
+					"priority": {"type": "integer"},
+					"containers": {"type": "array", "items": {"$ref": "#/components/schemas/io.k8s.api.core.v1.Container"}}
+				}
+			},
+			"io.k8s.api.core.v1.Container": {
+				"type": "object",
+				"properties": {
+					"name": {"type": "string"},
+					"ports": {"type": "array", "items": {"type": "object", "properties": {"containerPort": {"type": "integer"}}}}
+				}
+			}
+		}
+	}
+}`
+
+func newTestSchema(t *testing.T) *schemaDef {
+	doc := &spec3.OpenAPI{}
+	if err := json.Unmarshal([]byte(coreV1Spec), doc); err != nil {
+		t.Fatal(err)
+	}
+	return buildSchema([]*metav1.APIResourceList{
+		{
+			GroupVersion: "v1",
+			APIResources: []metav1.APIResource{
+				{Name: "pods", SingularName: "pod", Namespaced: true, Kind: "Pod", Verbs: []string{"get", "list", "watch"}},
+				{Name: "pods/status", Namespaced: true, Kind: "Pod", Verbs: []string{"get"}},
+			},
+		},
+		{
+			GroupVersion: "widgets.test/v1",
+			APIResources: []metav1.APIResource{
+				{Name: "widgets", Kind: "Widget", Verbs: []string{"list"}},
+			},
+		},
+	}, fakeRoot{{Version: "v1"}: doc})
+}
+
+func TestSchema(t *testing.T) {
+	s := newTestSchema(t)
+
+	var fields []string
+	for _, f := range s.types[queryType].fields {
+		fields = append(fields, f.name)
+	}
+	assert.Equal(t, []string{"pod", "pods", "widgets_widgets_test"}, fields)
+	assert.Equal(t, "io_k8s_api_core_v1_Pod", s.types[queryType].field("pod").typ.named())
+	// objects without an OpenAPI schema are JSON
+	assert.Equal(t, scalarJSON, s.types["widgets_test_v1_Widget_List"].field("items").typ.named())
+	assert.NotNil(t, s.types[subscriptionType].field("pods"))
+
+	operations, err := parse(`{ pods(namespace: "default") {
+		items { __typename metadata { name labels } spec { nodeName priority containers { ports { containerPort } } } }
+		continue
+	} }`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := operations[0].selections[0]
+	if err := s.validate(named(queryType), operations[0].selections, ""); err != nil {
+		t.Fatal(err)
+	}
+	projected, err := s.project(map[string]any{
+		"items": []any{
+			map[string]any{
+				"kind":     "Pod",
+				"metadata": map[string]any{"name": "web", "namespace": "default", "labels": map[string]any{"app": "web"}},
+				"spec": map[string]any{
+					"nodeName":   "node1",
+					"priority":   int64(10),
+					"containers": []any{map[string]any{"name": "web", "ports": []any{map[string]any{"containerPort": 80}}}},
+				},
+			},
+		},
+		"continue": "",
+	}, s.types[queryType].field(f.name).typ, f.selections)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := json.Marshal(projected)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, `{"items":[{"__typename":"io_k8s_api_core_v1_Pod","metadata":{"name":"web","labels":{"app":"web"}},`+
+		`"spec":{"nodeName":"node1","priority":10,"containers":[{"ports":[{"containerPort":80}]}]}}],"continue":""}`,
+		string(data))
+
+	for query, message := range map[string]string{
+		`{ pods { items { metadata { namespace } } } }`: "field namespace is not defined on type io_k8s_apimachinery_pkg_apis_meta_v1_ObjectMeta",
+		`{ pods { items { metadata } } }`:               "pods.items.metadata is of type io_k8s_apimachinery_pkg_apis_meta_v1_ObjectMeta, its fields must be selected",
+		`{ pods { continue { name } } }`:                "pods.continue is of type String, which has no fields to select",
+		`{ pods(name: "web") { continue } }`:            "unknown argument name of pods",
+		`{ pod { kind } }`:                              "the argument name of pod is required",
+		`{ widget_widgets_test { items } }`:             "field widget_widgets_test is not defined on type Query",
+	} {
+		operations, err := parse(query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.EqualError(t, s.validate(named(queryType), operations[0].selections, ""), message, query)
+	}
+}
+
+func TestIntrospection(t *testing.T) {
+	s := newTestSchema(t)
+	operations, err := parse(`
+		query {
+			__schema { queryType { name } subscriptionType { name } mutationType { name } }
+			__type(name: "io_k8s_api_core_v1_PodSpec") { kind fields { name type { ...TypeRef } } }
+		}
+		fragment TypeRef on __Type { kind name ofType { kind name } }
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rw := httptest.NewRecorder()
+	if err := execute(rw, httptest.NewRequest(http.MethodPost, "/graphql", nil), s, nil, operations[0], nil); err != nil {
+		t.Fatal(err)
+	}
+	assert.JSONEq(t, `{"data": {
+		"__schema": {"queryType": {"name": "Query"}, "subscriptionType": {"name": "Subscription"}, "mutationType": null},
+		"__type": {"kind": "OBJECT", "fields": [
+			{"name": "containers", "type": {"kind": "LIST", "name": null, "ofType": {"kind": "OBJECT", "name": "io_k8s_api_core_v1_Container"}}},
+			{"name": "nodeName", "type": {"kind": "SCALAR", "name": "String", "ofType": null}},
+			{"name": "priority", "type": {"kind": "SCALAR", "name": "Int", "ofType": null}}
+		]}
+	}}`, rw.Body.String())
+}
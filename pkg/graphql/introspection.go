@@ -0,0 +1,203 @@
+package graphql
+
+import (
+	"sort"
+)
+
+// introspectionFields are the fields of Query that introspect the schema, they aren't listed as fields of Query.
+var introspectionFields = map[string]*fieldDef{
+	"__schema": {
+		name: "__schema",
+		typ:  nonNull(named("__Schema")),
+	},
+	"__type": {
+		name: "__type",
+		args: []*argDef{{name: "name", typ: nonNull(named(scalarString))}},
+		typ:  named("__Type"),
+	},
+}
+
+// addIntrospectionTypes adds the types of the introspection of the schema, as defined by the GraphQL specification.
+func addIntrospectionTypes(types map[string]*namedType) {
+	str, boolean := named(scalarString), named(scalarBoolean)
+	includeDeprecated := []*argDef{{name: "includeDeprecated", typ: boolean, defaultValue: "false"}}
+	deprecation := []*fieldDef{
+		{name: "isDeprecated", typ: nonNull(boolean)},
+		{name: "deprecationReason", typ: str},
+	}
+
+	for _, t := range []*namedType{
+		{
+			kind: kindObject,
+			name: "__Schema",
+			fields: []*fieldDef{
+				{name: "description", typ: str},
+				{name: "types", typ: nonNull(listOf(nonNull(named("__Type"))))},
+				{name: "queryType", typ: nonNull(named("__Type"))},
+				{name: "mutationType", typ: named("__Type")},
+				{name: "subscriptionType", typ: named("__Type")},
+				{name: "directives", typ: nonNull(listOf(nonNull(named("__Directive"))))},
+			},
+		},
+		{
+			kind: kindObject,
+			name: "__Type",
+			fields: []*fieldDef{
+				{name: "kind", typ: nonNull(named("__TypeKind"))},
+				{name: "name", typ: str},
+				{name: "description", typ: str},
+				{name: "specifiedByURL", typ: str},
+				{name: "fields", args: includeDeprecated, typ: listOf(nonNull(named("__Field")))},
+				{name: "interfaces", typ: listOf(nonNull(named("__Type")))},
+				{name: "possibleTypes", typ: listOf(nonNull(named("__Type")))},
+				{name: "enumValues", args: includeDeprecated, typ: listOf(nonNull(named("__EnumValue")))},
+				{name: "inputFields", args: includeDeprecated, typ: listOf(nonNull(named("__InputValue")))},
+				{name: "ofType", typ: named("__Type")},
+				{name: "isOneOf", typ: boolean},
+			},
+		},
+		{
+			kind: kindObject,
+			name: "__Field",
+			fields: append([]*fieldDef{
+				{name: "name", typ: nonNull(str)},
+				{name: "description", typ: str},
+				{name: "args", args: includeDeprecated, typ: nonNull(listOf(nonNull(named("__InputValue"))))},
+				{name: "type", typ: nonNull(named("__Type"))},
+			}, deprecation...),
+		},
+		{
+			kind: kindObject,
+			name: "__InputValue",
+			fields: append([]*fieldDef{
+				{name: "name", typ: nonNull(str)},
+				{name: "description", typ: str},
+				{name: "type", typ: nonNull(named("__Type"))},
+				{name: "defaultValue", typ: str},
+			}, deprecation...),
+		},
+		{
+			kind: kindObject,
+			name: "__EnumValue",
+			fields: append([]*fieldDef{
+				{name: "name", typ: nonNull(str)},
+				{name: "description", typ: str},
+			}, deprecation...),
+		},
+		{
+			kind: kindObject,
+			name: "__Directive",
+			fields: []*fieldDef{
+				{name: "name", typ: nonNull(str)},
+				{name: "description", typ: str},
+				{name: "isRepeatable", typ: nonNull(boolean)},
+				{name: "locations", typ: nonNull(listOf(nonNull(named("__DirectiveLocation"))))},
+				{name: "args", args: includeDeprecated, typ: nonNull(listOf(nonNull(named("__InputValue"))))},
+			},
+		},
+		{
+			kind:       kindEnum,
+			name:       "__TypeKind",
+			enumValues: []string{"SCALAR", "OBJECT", "INTERFACE", "UNION", "ENUM", "INPUT_OBJECT", "LIST", "NON_NULL"},
+		},
+		{
+			kind: kindEnum,
+			name: "__DirectiveLocation",
+			enumValues: []string{"QUERY", "MUTATION", "SUBSCRIPTION", "FIELD", "FRAGMENT_DEFINITION", "FRAGMENT_SPREAD",
+				"INLINE_FRAGMENT", "VARIABLE_DEFINITION", "SCHEMA", "SCALAR", "OBJECT", "FIELD_DEFINITION",
+				"ARGUMENT_DEFINITION", "INTERFACE", "UNION", "ENUM", "ENUM_VALUE", "INPUT_OBJECT",
+				"INPUT_FIELD_DEFINITION"},
+		},
+	} {
+		types[t.name] = t
+	}
+}
+
+// introspect returns the values of the __Schema of s and of its __Types by name. Types refer to each other, so the
+// values are a graph that can only be walked by a selection.
+func (s *schemaDef) introspect() (map[string]any, map[string]map[string]any) {
+	s.once.Do(func() {
+		s.typeValues = map[string]map[string]any{}
+		for name := range s.types {
+			s.typeValues[name] = map[string]any{}
+		}
+
+		var typeValue func(t *typeRef) map[string]any
+		typeValue = func(t *typeRef) map[string]any {
+			if t.kind == kindList || t.kind == kindNonNull {
+				return map[string]any{"kind": t.kind, "ofType": typeValue(t.ofType)}
+			}
+			return s.typeValues[t.name]
+		}
+		inputValues := func(args []*argDef) []any {
+			result := []any{}
+			for _, a := range args {
+				var defaultValue any
+				if a.defaultValue != "" {
+					defaultValue = a.defaultValue
+				}
+				result = append(result, map[string]any{
+					"name":         a.name,
+					"description":  nilIfEmpty(a.description),
+					"type":         typeValue(a.typ),
+					"defaultValue": defaultValue,
+					"isDeprecated": false,
+				})
+			}
+			return result
+		}
+
+		names := make([]string, 0, len(s.types))
+		for name := range s.types {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		types := make([]any, 0, len(names))
+		for _, name := range names {
+			t, value := s.types[name], s.typeValues[name]
+			value["kind"] = t.kind
+			value["name"] = t.name
+			value["description"] = nilIfEmpty(t.description)
+			switch t.kind {
+			case kindObject:
+				fields := []any{}
+				for _, f := range t.fields {
+					fields = append(fields, map[string]any{
+						"name":         f.name,
+						"description":  nilIfEmpty(f.description),
+						"args":         inputValues(f.args),
+						"type":         typeValue(f.typ),
+						"isDeprecated": false,
+					})
+				}
+				value["fields"] = fields
+				value["interfaces"] = []any{}
+			case kindEnum:
+				values := []any{}
+				for _, v := range t.enumValues {
+					values = append(values, map[string]any{"name": v, "isDeprecated": false})
+				}
+				value["enumValues"] = values
+			}
+			types = append(types, value)
+		}
+
+		s.schemaValue = map[string]any{
+			"types":      types,
+			"queryType":  s.typeValues[queryType],
+			"directives": []any{},
+		}
+		if _, ok := s.types[subscriptionType]; ok {
+			s.schemaValue["subscriptionType"] = s.typeValues[subscriptionType]
+		}
+	})
+	return s.schemaValue, s.typeValues
+}
+
+func nilIfEmpty(s string) any {
+	if s == "" {
+		return nil
+	}
+	return s
+}
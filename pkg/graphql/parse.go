@@ -0,0 +1,627 @@
+package graphql
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// The parser supports the subset of GraphQL the gateway executes: query and subscription operations with variables,
+// fields with aliases, arguments and selections, and fragments. Fragments are expanded when the document is parsed,
+// their type conditions are ignored as the schema has no interfaces or unions. Directives and mutations are rejected.
+
+type operation struct {
+	typ        string
+	name       string
+	defaults   map[string]any
+	selections []field
+}
+
+type field struct {
+	alias      string
+	name       string
+	args       map[string]any
+	selections []field
+
+	// spread is the name of the fragment of a fragment spread, inline the selections of an inline fragment, until the
+	// document is expanded
+	spread string
+	inline []field
+}
+
+// key is the key of the field in the response.
+func (f field) key() string {
+	if f.alias != "" {
+		return f.alias
+	}
+	return f.name
+}
+
+// variable is a reference to a variable in an argument value.
+type variable string
+
+// resolve replaces the variables in v with their values.
+func resolve(v any, variables map[string]any) any {
+	switch v := v.(type) {
+	case variable:
+		return variables[string(v)]
+	case []any:
+		result := make([]any, len(v))
+		for i, item := range v {
+			result[i] = resolve(item, variables)
+		}
+		return result
+	case map[string]any:
+		result := make(map[string]any, len(v))
+		for key, item := range v {
+			result[key] = resolve(item, variables)
+		}
+		return result
+	}
+	return v
+}
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenPunctuator
+	tokenName
+	tokenInt
+	tokenFloat
+	tokenString
+)
+
+type token struct {
+	kind  tokenKind
+	value string
+	pos   int
+}
+
+type parser struct {
+	src string
+	pos int
+	tok token
+
+	fragments map[string][]field
+}
+
+// parse parses the operations of a document.
+func parse(src string) ([]operation, error) {
+	p := &parser{src: src, fragments: map[string][]field{}}
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+
+	var operations []operation
+	for p.tok.kind != tokenEOF {
+		if p.tok.kind == tokenName && p.tok.value == "fragment" {
+			if err := p.fragment(); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		op, err := p.operation()
+		if err != nil {
+			return nil, err
+		}
+		operations = append(operations, op)
+	}
+	if len(operations) == 0 {
+		return nil, fmt.Errorf("the document has no operation")
+	}
+	for i := range operations {
+		selections, err := p.expand(operations[i].selections, map[string]bool{})
+		if err != nil {
+			return nil, err
+		}
+		operations[i].selections = selections
+	}
+	return operations, nil
+}
+
+// expand replaces the fragments of selections by the fields they select, and merges the fields with the same
+// response key, as fragments often select the same fields. visiting are the fragments being expanded.
+func (p *parser) expand(selections []field, visiting map[string]bool) ([]field, error) {
+	var (
+		result []field
+		index  = map[string]int{}
+		merged = map[int]bool{}
+	)
+	add := func(f field) {
+		if i, ok := index[f.key()]; ok {
+			result[i].selections = append(result[i].selections, f.selections...)
+			merged[i] = true
+			return
+		}
+		index[f.key()] = len(result)
+		result = append(result, f)
+	}
+
+	for _, f := range selections {
+		var (
+			fields []field
+			err    error
+		)
+		switch {
+		case f.spread != "":
+			fragment, ok := p.fragments[f.spread]
+			if !ok {
+				return nil, fmt.Errorf("unknown fragment %s", f.spread)
+			}
+			if visiting[f.spread] {
+				return nil, fmt.Errorf("fragment %s spreads itself", f.spread)
+			}
+			visiting[f.spread] = true
+			fields, err = p.expand(fragment, visiting)
+			delete(visiting, f.spread)
+		case f.inline != nil:
+			fields, err = p.expand(f.inline, visiting)
+		default:
+			if f.selections != nil {
+				f.selections, err = p.expand(f.selections, visiting)
+			}
+			fields = []field{f}
+		}
+		if err != nil {
+			return nil, err
+		}
+		for _, f := range fields {
+			add(f)
+		}
+	}
+
+	// the selections of merged fields have been expanded, this merges their fields
+	for i := range merged {
+		selections, err := p.expand(result[i].selections, visiting)
+		if err != nil {
+			return nil, err
+		}
+		result[i].selections = selections
+	}
+	return result, nil
+}
+
+// selectOperation returns the operation named name, which may only be empty if the document has one operation.
+func selectOperation(operations []operation, name string) (operation, error) {
+	if name == "" {
+		if len(operations) != 1 {
+			return operation{}, fmt.Errorf("the operation name is required for documents with several operations")
+		}
+		return operations[0], nil
+	}
+	for _, op := range operations {
+		if op.name == name {
+			return op, nil
+		}
+	}
+	return operation{}, fmt.Errorf("unknown operation %q", name)
+}
+
+func (p *parser) errorf(format string, args ...any) error {
+	line := strings.Count(p.src[:p.tok.pos], "\n") + 1
+	column := p.tok.pos - strings.LastIndex(p.src[:p.tok.pos], "\n")
+	return fmt.Errorf("syntax error at %d:%d: %s", line, column, fmt.Sprintf(format, args...))
+}
+
+func (p *parser) operation() (operation, error) {
+	op := operation{typ: "query"}
+	if p.tok.kind == tokenName {
+		switch p.tok.value {
+		case "query", "subscription":
+			op.typ = p.tok.value
+		case "mutation":
+			return op, p.errorf("mutations are not supported")
+		default:
+			return op, p.errorf("unexpected %q", p.tok.value)
+		}
+		if err := p.next(); err != nil {
+			return op, err
+		}
+		if p.tok.kind == tokenName {
+			op.name = p.tok.value
+			if err := p.next(); err != nil {
+				return op, err
+			}
+		}
+		if p.is("(") {
+			defaults, err := p.variableDefinitions()
+			if err != nil {
+				return op, err
+			}
+			op.defaults = defaults
+		}
+	}
+
+	selections, err := p.selectionSet()
+	op.selections = selections
+	return op, err
+}
+
+// fragment reads the definition of a fragment.
+func (p *parser) fragment() error {
+	if err := p.next(); err != nil {
+		return err
+	}
+	name, err := p.name()
+	if err != nil {
+		return err
+	}
+	if name == "on" {
+		return p.errorf("expected the name of the fragment")
+	}
+	if err := p.typeCondition(); err != nil {
+		return err
+	}
+	if p.is("@") {
+		return p.errorf("directives are not supported")
+	}
+	if _, ok := p.fragments[name]; ok {
+		return p.errorf("fragment %s is defined twice", name)
+	}
+	selections, err := p.selectionSet()
+	if err != nil {
+		return err
+	}
+	p.fragments[name] = selections
+	return nil
+}
+
+// typeCondition skips the type condition of a fragment.
+func (p *parser) typeCondition() error {
+	if p.tok.kind != tokenName || p.tok.value != "on" {
+		return p.errorf("expected a type condition")
+	}
+	if err := p.next(); err != nil {
+		return err
+	}
+	_, err := p.name()
+	return err
+}
+
+// variableDefinitions returns the default values of the variables, their types aren't checked.
+func (p *parser) variableDefinitions() (map[string]any, error) {
+	defaults := map[string]any{}
+	if err := p.expect("("); err != nil {
+		return nil, err
+	}
+	for !p.is(")") {
+		if err := p.expect("$"); err != nil {
+			return nil, err
+		}
+		name, err := p.name()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(":"); err != nil {
+			return nil, err
+		}
+		if err := p.skipType(); err != nil {
+			return nil, err
+		}
+		if p.is("=") {
+			if err := p.next(); err != nil {
+				return nil, err
+			}
+			value, err := p.value()
+			if err != nil {
+				return nil, err
+			}
+			defaults[name] = value
+		}
+	}
+	return defaults, p.next()
+}
+
+func (p *parser) skipType() error {
+	if p.is("[") {
+		if err := p.next(); err != nil {
+			return err
+		}
+		if err := p.skipType(); err != nil {
+			return err
+		}
+		if err := p.expect("]"); err != nil {
+			return err
+		}
+	} else if _, err := p.name(); err != nil {
+		return err
+	}
+	if p.is("!") {
+		return p.next()
+	}
+	return nil
+}
+
+func (p *parser) selectionSet() ([]field, error) {
+	if err := p.expect("{"); err != nil {
+		return nil, err
+	}
+	var fields []field
+	for !p.is("}") {
+		if p.is("...") {
+			f, err := p.fragmentSelection()
+			if err != nil {
+				return nil, err
+			}
+			fields = append(fields, f)
+			continue
+		}
+		f, err := p.field()
+		if err != nil {
+			return nil, err
+		}
+		fields = append(fields, f)
+	}
+	if len(fields) == 0 {
+		return nil, p.errorf("empty selection")
+	}
+	return fields, p.next()
+}
+
+// fragmentSelection reads a fragment spread or an inline fragment.
+func (p *parser) fragmentSelection() (field, error) {
+	var f field
+	if err := p.expect("..."); err != nil {
+		return f, err
+	}
+	if p.tok.kind == tokenName && p.tok.value != "on" {
+		f.spread = p.tok.value
+		if err := p.next(); err != nil {
+			return f, err
+		}
+	} else {
+		if p.tok.kind == tokenName {
+			if err := p.typeCondition(); err != nil {
+				return f, err
+			}
+		}
+		if p.is("@") {
+			return f, p.errorf("directives are not supported")
+		}
+		selections, err := p.selectionSet()
+		if err != nil {
+			return f, err
+		}
+		f.inline = selections
+	}
+	if p.is("@") {
+		return f, p.errorf("directives are not supported")
+	}
+	return f, nil
+}
+
+func (p *parser) field() (field, error) {
+	var f field
+	name, err := p.name()
+	if err != nil {
+		return f, err
+	}
+	f.name = name
+	if p.is(":") {
+		if err := p.next(); err != nil {
+			return f, err
+		}
+		if f.name, err = p.name(); err != nil {
+			return f, err
+		}
+		f.alias = name
+	}
+	if p.is("(") {
+		if f.args, err = p.arguments(); err != nil {
+			return f, err
+		}
+	}
+	if p.is("@") {
+		return f, p.errorf("directives are not supported")
+	}
+	if p.is("{") {
+		f.selections, err = p.selectionSet()
+	}
+	return f, err
+}
+
+func (p *parser) arguments() (map[string]any, error) {
+	args := map[string]any{}
+	if err := p.expect("("); err != nil {
+		return nil, err
+	}
+	for !p.is(")") {
+		name, err := p.name()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(":"); err != nil {
+			return nil, err
+		}
+		if args[name], err = p.value(); err != nil {
+			return nil, err
+		}
+	}
+	return args, p.next()
+}
+
+func (p *parser) value() (any, error) {
+	tok := p.tok
+	switch {
+	case p.is("$"):
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		name, err := p.name()
+		return variable(name), err
+	case p.is("["):
+		list := []any{}
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		for !p.is("]") {
+			item, err := p.value()
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, item)
+		}
+		return list, p.next()
+	case p.is("{"):
+		obj := map[string]any{}
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		for !p.is("}") {
+			name, err := p.name()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect(":"); err != nil {
+				return nil, err
+			}
+			if obj[name], err = p.value(); err != nil {
+				return nil, err
+			}
+		}
+		return obj, p.next()
+	}
+
+	var value any
+	switch tok.kind {
+	case tokenInt:
+		i, err := strconv.ParseInt(tok.value, 10, 64)
+		if err != nil {
+			return nil, p.errorf("invalid integer %s", tok.value)
+		}
+		value = i
+	case tokenFloat:
+		f, err := strconv.ParseFloat(tok.value, 64)
+		if err != nil {
+			return nil, p.errorf("invalid float %s", tok.value)
+		}
+		value = f
+	case tokenString:
+		value = tok.value
+	case tokenName:
+		switch tok.value {
+		case "true":
+			value = true
+		case "false":
+			value = false
+		case "null":
+			value = nil
+		default:
+			// enum values are passed on as strings
+			value = tok.value
+		}
+	default:
+		return nil, p.errorf("expected a value")
+	}
+	return value, p.next()
+}
+
+func (p *parser) name() (string, error) {
+	if p.tok.kind != tokenName {
+		return "", p.errorf("expected a name")
+	}
+	name := p.tok.value
+	return name, p.next()
+}
+
+func (p *parser) is(punctuator string) bool {
+	return p.tok.kind == tokenPunctuator && p.tok.value == punctuator
+}
+
+func (p *parser) expect(punctuator string) error {
+	if !p.is(punctuator) {
+		if p.tok.kind == tokenEOF {
+			return p.errorf("expected %q, got the end of the document", punctuator)
+		}
+		return p.errorf("expected %q", punctuator)
+	}
+	return p.next()
+}
+
+// next reads the next token, skipping white space, commas and comments.
+func (p *parser) next() error {
+	for p.pos < len(p.src) {
+		c := p.src[p.pos]
+		if c == '#' {
+			for p.pos < len(p.src) && p.src[p.pos] != '\n' {
+				p.pos++
+			}
+		} else if c == ',' || c == ' ' || c == '\t' || c == '\n' || c == '\r' {
+			p.pos++
+		} else {
+			break
+		}
+	}
+
+	start := p.pos
+	p.tok = token{pos: start}
+	if p.pos >= len(p.src) {
+		p.tok.kind = tokenEOF
+		return nil
+	}
+
+	c := p.src[p.pos]
+	switch {
+	case strings.HasPrefix(p.src[p.pos:], "..."):
+		p.pos += 3
+		p.tok.kind, p.tok.value = tokenPunctuator, "..."
+	case strings.IndexByte("{}()[]:$=!@|&", c) >= 0:
+		p.pos++
+		p.tok.kind, p.tok.value = tokenPunctuator, string(c)
+	case isNameChar(c) && (c < '0' || c > '9'):
+		for p.pos < len(p.src) && isNameChar(p.src[p.pos]) {
+			p.pos++
+		}
+		p.tok.kind, p.tok.value = tokenName, p.src[start:p.pos]
+	case c == '-' || c >= '0' && c <= '9':
+		p.pos++
+		p.tok.kind = tokenInt
+		for p.pos < len(p.src) {
+			c := p.src[p.pos]
+			if c == '.' || c == 'e' || c == 'E' || (c == '+' || c == '-') && p.tok.kind == tokenFloat {
+				p.tok.kind = tokenFloat
+			} else if c < '0' || c > '9' {
+				break
+			}
+			p.pos++
+		}
+		p.tok.value = p.src[start:p.pos]
+	case c == '"':
+		return p.string()
+	default:
+		return p.errorf("unexpected character %q", c)
+	}
+	return nil
+}
+
+// string reads a string token. Its escape sequences are those of JSON, block strings are not supported.
+func (p *parser) string() error {
+	if strings.HasPrefix(p.src[p.pos:], `"""`) {
+		return p.errorf("block strings are not supported")
+	}
+	start := p.pos
+	p.pos++
+	for p.pos < len(p.src) && p.src[p.pos] != '"' {
+		if p.src[p.pos] == '\\' {
+			p.pos++
+		} else if p.src[p.pos] == '\n' {
+			break
+		}
+		p.pos++
+	}
+	if p.pos >= len(p.src) || p.src[p.pos] != '"' {
+		return p.errorf("unterminated string")
+	}
+	p.pos++
+	p.tok.kind = tokenString
+	if err := json.Unmarshal([]byte(p.src[start:p.pos]), &p.tok.value); err != nil {
+		return p.errorf("invalid string %s", p.src[start:p.pos])
+	}
+	return nil
+}
+
+func isNameChar(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
@@ -0,0 +1,567 @@
+package graphql
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/client-go/openapi3"
+	"k8s.io/kube-openapi/pkg/spec3"
+	"k8s.io/kube-openapi/pkg/validation/spec"
+)
+
+// The kinds of GraphQL types, as named by introspection.
+const (
+	kindScalar  = "SCALAR"
+	kindObject  = "OBJECT"
+	kindEnum    = "ENUM"
+	kindList    = "LIST"
+	kindNonNull = "NON_NULL"
+)
+
+// The scalars of the schema. JSON is any JSON value, it is used for the parts of objects the OpenAPI schemas leave
+// open, such as maps, and is returned whole.
+const (
+	scalarString  = "String"
+	scalarInt     = "Int"
+	scalarFloat   = "Float"
+	scalarBoolean = "Boolean"
+	scalarJSON    = "JSON"
+)
+
+const (
+	queryType        = "Query"
+	subscriptionType = "Subscription"
+)
+
+// typeRef is the type of a field or argument: a named type, or a list or non-null wrapper of another type.
+type typeRef struct {
+	kind   string
+	name   string
+	ofType *typeRef
+}
+
+func named(name string) *typeRef {
+	return &typeRef{name: name}
+}
+
+func listOf(t *typeRef) *typeRef {
+	return &typeRef{kind: kindList, ofType: t}
+}
+
+func nonNull(t *typeRef) *typeRef {
+	return &typeRef{kind: kindNonNull, ofType: t}
+}
+
+// named returns the name of the named type t wraps.
+func (t *typeRef) named() string {
+	for t.ofType != nil {
+		t = t.ofType
+	}
+	return t.name
+}
+
+type namedType struct {
+	kind        string
+	name        string
+	description string
+	// fields are the fields of objects.
+	fields []*fieldDef
+	// enumValues are the values of enums.
+	enumValues []string
+}
+
+func (t *namedType) field(name string) *fieldDef {
+	for _, f := range t.fields {
+		if f.name == name {
+			return f
+		}
+	}
+	return nil
+}
+
+type fieldDef struct {
+	name        string
+	description string
+	args        []*argDef
+	typ         *typeRef
+}
+
+func (f *fieldDef) arg(name string) *argDef {
+	for _, a := range f.args {
+		if a.name == name {
+			return a
+		}
+	}
+	return nil
+}
+
+type argDef struct {
+	name        string
+	description string
+	typ         *typeRef
+	// defaultValue is the default value as a GraphQL literal, if any.
+	defaultValue string
+}
+
+// schemaDef is the schema of the gateway. The root fields of Query and Subscription are backed by resources.
+type schemaDef struct {
+	types map[string]*namedType
+	// gets, lists and watches are the resources of the root fields that get, list and watch objects.
+	gets    map[string]*resource
+	lists   map[string]*resource
+	watches map[string]*resource
+
+	once sync.Once
+	// typeValues and schemaValue are the introspection of the schema, see introspect.
+	typeValues  map[string]map[string]any
+	schemaValue map[string]any
+}
+
+func newSchema() *schemaDef {
+	s := &schemaDef{
+		types:   map[string]*namedType{},
+		gets:    map[string]*resource{},
+		lists:   map[string]*resource{},
+		watches: map[string]*resource{},
+	}
+	for name, description := range map[string]string{
+		scalarString:  "A UTF-8 string.",
+		scalarInt:     "An integer. Integers of Kubernetes objects may not fit in 32 bits.",
+		scalarFloat:   "A floating point number.",
+		scalarBoolean: "true or false.",
+		scalarJSON:    "Any JSON value, returned whole.",
+	} {
+		s.types[name] = &namedType{kind: kindScalar, name: name, description: description}
+	}
+	addIntrospectionTypes(s.types)
+	return s
+}
+
+// rootField returns the definition of the field of the root type, including the introspection fields of Query.
+func (s *schemaDef) rootField(root *namedType, name string) *fieldDef {
+	if root.name == queryType {
+		if f := introspectionFields[name]; f != nil {
+			return f
+		}
+	}
+	return root.field(name)
+}
+
+// validate checks that selections are a valid selection of fields of a value of type t. path is the path of the
+// value in the response, used in the errors.
+func (s *schemaDef) validate(t *typeRef, selections []field, path string) error {
+	typ := s.types[t.named()]
+	if typ.kind != kindObject {
+		if len(selections) > 0 {
+			return fmt.Errorf("%s is of type %s, which has no fields to select", path, typ.name)
+		}
+		return nil
+	}
+	if len(selections) == 0 {
+		return fmt.Errorf("%s is of type %s, its fields must be selected", path, typ.name)
+	}
+
+	for _, f := range selections {
+		fieldPath := f.key()
+		if path != "" {
+			fieldPath = path + "." + fieldPath
+		}
+		if f.name == "__typename" {
+			if len(f.args) > 0 || len(f.selections) > 0 {
+				return fmt.Errorf("%s has no arguments or fields", fieldPath)
+			}
+			continue
+		}
+		def := typ.field(f.name)
+		if path == "" {
+			def = s.rootField(typ, f.name)
+		}
+		if def == nil {
+			return fmt.Errorf("field %s is not defined on type %s", f.name, typ.name)
+		}
+		for name := range f.args {
+			if def.arg(name) == nil {
+				return fmt.Errorf("unknown argument %s of %s", name, fieldPath)
+			}
+		}
+		for _, a := range def.args {
+			if _, ok := f.args[a.name]; !ok && a.typ.kind == kindNonNull {
+				return fmt.Errorf("the argument %s of %s is required", a.name, fieldPath)
+			}
+		}
+		if err := s.validate(def.typ, f.selections, fieldPath); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// project returns the fields of value, a value of type t, selected by selections, in their order. The selections
+// must have been validated.
+func (s *schemaDef) project(value any, t *typeRef, selections []field) (any, error) {
+	if value == nil {
+		return nil, nil
+	}
+	if t.kind == kindNonNull {
+		return s.project(value, t.ofType, selections)
+	}
+	if t.kind == kindList {
+		items, ok := value.([]any)
+		if !ok {
+			return nil, fmt.Errorf("expected a list, got %T", value)
+		}
+		result := make([]any, 0, len(items))
+		for _, item := range items {
+			projected, err := s.project(item, t.ofType, selections)
+			if err != nil {
+				return nil, err
+			}
+			result = append(result, projected)
+		}
+		return result, nil
+	}
+
+	typ := s.types[t.name]
+	if typ.kind != kindObject {
+		return value, nil
+	}
+	fields, ok := value.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("expected an object of type %s, got %T", typ.name, value)
+	}
+	result := make(object, 0, len(selections))
+	for _, f := range selections {
+		if f.name == "__typename" {
+			result = append(result, entry{key: f.key(), value: typ.name})
+			continue
+		}
+		projected, err := s.project(fields[f.name], typ.field(f.name).typ, f.selections)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, entry{key: f.key(), value: projected})
+	}
+	return result, nil
+}
+
+// schemaBuilder builds the schema from the resources of the server and the OpenAPI schemas of their group versions.
+type schemaBuilder struct {
+	schema *schemaDef
+	root   openapi3.Root
+	specs  map[schema.GroupVersion]*spec3.OpenAPI
+	// listFields, getFields and watchFields are the root fields by name
+	listFields  map[string]*fieldDef
+	getFields   map[string]*fieldDef
+	watchFields map[string]*fieldDef
+}
+
+// buildSchema returns the schema of the resources of lists that can be listed. The objects are typed by their OpenAPI
+// schema in root, objects without one are typed as JSON.
+func buildSchema(lists []*metav1.APIResourceList, root openapi3.Root) *schemaDef {
+	b := &schemaBuilder{
+		schema:      newSchema(),
+		root:        root,
+		specs:       map[schema.GroupVersion]*spec3.OpenAPI{},
+		listFields:  map[string]*fieldDef{},
+		getFields:   map[string]*fieldDef{},
+		watchFields: map[string]*fieldDef{},
+	}
+
+	for _, list := range lists {
+		gv, err := schema.ParseGroupVersion(list.GroupVersion)
+		if err != nil {
+			continue
+		}
+		for _, apiResource := range list.APIResources {
+			verbs := sets.New(apiResource.Verbs...)
+			if strings.Contains(apiResource.Name, "/") || !verbs.Has("list") {
+				continue
+			}
+			r := &resource{
+				gvr:        gv.WithResource(apiResource.Name),
+				namespaced: apiResource.Namespaced,
+				watch:      verbs.Has("watch"),
+			}
+			b.addResource(r, gv.WithKind(apiResource.Kind), apiResource)
+		}
+	}
+
+	// resources whose singular name is their name have no get field
+	for name := range b.getFields {
+		if b.listFields[name] != nil {
+			delete(b.getFields, name)
+			delete(b.schema.gets, name)
+		}
+	}
+	b.schema.types[queryType] = &namedType{
+		kind:   kindObject,
+		name:   queryType,
+		fields: sortedFields(b.listFields, b.getFields),
+	}
+	if len(b.watchFields) > 0 {
+		b.schema.types[subscriptionType] = &namedType{
+			kind:   kindObject,
+			name:   subscriptionType,
+			fields: sortedFields(b.watchFields),
+		}
+	}
+	return b.schema
+}
+
+func sortedFields(fieldSets ...map[string]*fieldDef) []*fieldDef {
+	var result []*fieldDef
+	for _, set := range fieldSets {
+		for _, f := range set {
+			result = append(result, f)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].name < result[j].name
+	})
+	return result
+}
+
+func (b *schemaBuilder) addResource(r *resource, gvk schema.GroupVersionKind, apiResource metav1.APIResource) {
+	objType := b.kindType(gvk)
+	// the list and event types are named after the object type, or the kind for objects typed as JSON
+	baseName := objType.named()
+	if baseName == scalarJSON {
+		baseName = typeName(strings.Join([]string{gvk.Group, gvk.Version, gvk.Kind}, "."))
+		baseName = strings.TrimPrefix(baseName, "_")
+	}
+
+	var scopeArgs []*argDef
+	if r.namespaced {
+		scopeArgs = append(scopeArgs, &argDef{
+			name:        "namespace",
+			description: "The namespace of the objects, all namespaces if not set.",
+			typ:         named(scalarString),
+		})
+	}
+	selectorArgs := slices.Concat(scopeArgs, []*argDef{
+		{name: "labelSelector", description: "Selects the objects by their labels.", typ: named(scalarString)},
+		{name: "fieldSelector", description: "Selects the objects by their fields.", typ: named(scalarString)},
+	})
+
+	listName := fieldName(apiResource.Name, gvk.Group)
+	listType := b.addType(&namedType{
+		kind:        kindObject,
+		name:        baseName + "_List",
+		description: fmt.Sprintf("A page of %s.", apiResource.Name),
+		fields: []*fieldDef{
+			{name: "items", typ: nonNull(listOf(nonNull(objType)))},
+			{name: "continue", description: "The continue argument of the next page, empty on the last page.",
+				typ: named(scalarString)},
+			{name: "resourceVersion", typ: named(scalarString)},
+		},
+	})
+	b.schema.lists[listName] = r
+	b.listFields[listName] = &fieldDef{
+		name:        listName,
+		description: fmt.Sprintf("Lists the %s of %s.", apiResource.Name, gvk.GroupVersion()),
+		args: slices.Concat(selectorArgs, []*argDef{
+			{name: "limit", description: fmt.Sprintf("The size of the page, at most %d.", maxLimit), typ: named(scalarInt)},
+			{name: "continue", description: "The continue field of the previous page.", typ: named(scalarString)},
+		}),
+		typ: nonNull(listType),
+	}
+
+	if sets.New(apiResource.Verbs...).Has("get") {
+		singular := apiResource.SingularName
+		if singular == "" {
+			singular = strings.ToLower(apiResource.Kind)
+		}
+		getName := fieldName(singular, gvk.Group)
+		b.schema.gets[getName] = r
+		b.getFields[getName] = &fieldDef{
+			name:        getName,
+			description: fmt.Sprintf("Gets a %s of %s.", singular, gvk.GroupVersion()),
+			args:        slices.Concat([]*argDef{{name: "name", typ: nonNull(named(scalarString))}}, scopeArgs),
+			typ:         objType,
+		}
+	}
+
+	if r.watch {
+		eventType := b.addType(&namedType{
+			kind:        kindObject,
+			name:        baseName + "_Event",
+			description: fmt.Sprintf("A change of %s.", apiResource.Name),
+			fields: []*fieldDef{
+				{name: "type", description: "ADDED, MODIFIED, DELETED or BOOKMARK.", typ: nonNull(named(scalarString))},
+				{name: "object", typ: objType},
+			},
+		})
+		b.schema.watches[listName] = r
+		b.watchFields[listName] = &fieldDef{
+			name:        listName,
+			description: fmt.Sprintf("Streams the changes of the %s of %s.", apiResource.Name, gvk.GroupVersion()),
+			args: slices.Concat(selectorArgs, []*argDef{
+				{name: "resourceVersion", description: "The resource version to stream the changes after.",
+					typ: named(scalarString)},
+			}),
+			typ: nonNull(eventType),
+		}
+	}
+}
+
+func (b *schemaBuilder) addType(t *namedType) *typeRef {
+	if _, ok := b.schema.types[t.name]; !ok {
+		b.schema.types[t.name] = t
+	}
+	return named(t.name)
+}
+
+// spec returns the OpenAPI document of gv, nil if it can't be read.
+func (b *schemaBuilder) spec(gv schema.GroupVersion) *spec3.OpenAPI {
+	doc, ok := b.specs[gv]
+	if !ok {
+		doc, _ = b.root.GVSpec(gv)
+		if doc != nil && doc.Components == nil {
+			doc = nil
+		}
+		b.specs[gv] = doc
+	}
+	return doc
+}
+
+// kindType returns the type of the objects of gvk.
+func (b *schemaBuilder) kindType(gvk schema.GroupVersionKind) *typeRef {
+	doc := b.spec(gvk.GroupVersion())
+	if doc == nil {
+		return named(scalarJSON)
+	}
+	names := make([]string, 0, len(doc.Components.Schemas))
+	for name := range doc.Components.Schemas {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if hasGVK(doc.Components.Schemas[name], gvk) {
+			return b.component(doc, name)
+		}
+	}
+	return named(scalarJSON)
+}
+
+func hasGVK(s *spec.Schema, gvk schema.GroupVersionKind) bool {
+	gvks, _ := s.Extensions["x-kubernetes-group-version-kind"].([]any)
+	for _, v := range gvks {
+		m, _ := v.(map[string]any)
+		if m["group"] == gvk.Group && m["version"] == gvk.Version && m["kind"] == gvk.Kind {
+			return true
+		}
+	}
+	return false
+}
+
+// component returns the type of the schema of the document named name.
+func (b *schemaBuilder) component(doc *spec3.OpenAPI, name string) *typeRef {
+	typName := typeName(name)
+	if _, ok := b.schema.types[typName]; ok {
+		return named(typName)
+	}
+	s, ok := doc.Components.Schemas[name]
+	if !ok {
+		return named(scalarJSON)
+	}
+	return b.typeOf(doc, s, typName)
+}
+
+// typeOf returns the type of s. Objects with properties get a type called name, unless they are a reference.
+func (b *schemaBuilder) typeOf(doc *spec3.OpenAPI, s *spec.Schema, name string) *typeRef {
+	if s == nil {
+		return named(scalarJSON)
+	}
+	if ref := s.Ref.String(); ref != "" {
+		return b.component(doc, strings.TrimPrefix(ref, "#/components/schemas/"))
+	}
+	if len(s.AllOf) == 1 {
+		return b.typeOf(doc, &s.AllOf[0], name)
+	}
+	if intOrString, _ := s.Extensions["x-kubernetes-int-or-string"].(bool); intOrString {
+		return named(scalarJSON)
+	}
+
+	switch {
+	case s.Type.Contains("string"):
+		return named(scalarString)
+	case s.Type.Contains("integer"):
+		return named(scalarInt)
+	case s.Type.Contains("number"):
+		return named(scalarFloat)
+	case s.Type.Contains("boolean"):
+		return named(scalarBoolean)
+	case s.Type.Contains("array"):
+		if s.Items == nil || s.Items.Schema == nil {
+			return listOf(named(scalarJSON))
+		}
+		return listOf(b.typeOf(doc, s.Items.Schema, name+"_item"))
+	case len(s.Properties) > 0:
+		return b.object(doc, s, name)
+	}
+	return named(scalarJSON)
+}
+
+func (b *schemaBuilder) object(doc *spec3.OpenAPI, s *spec.Schema, name string) *typeRef {
+	if _, ok := b.schema.types[name]; ok {
+		return named(name)
+	}
+	t := &namedType{
+		kind:        kindObject,
+		name:        name,
+		description: s.Description,
+	}
+	// added before its fields, which may refer to it
+	b.schema.types[name] = t
+
+	properties := make([]string, 0, len(s.Properties))
+	for property := range s.Properties {
+		properties = append(properties, property)
+	}
+	sort.Strings(properties)
+	for _, property := range properties {
+		// properties that aren't GraphQL names can't be selected, they are still returned by JSON parents
+		if !isName(property) || strings.HasPrefix(property, "__") {
+			continue
+		}
+		propertySchema := s.Properties[property]
+		t.fields = append(t.fields, &fieldDef{
+			name:        property,
+			description: propertySchema.Description,
+			typ:         b.typeOf(doc, &propertySchema, name+"_"+property),
+		})
+	}
+	if len(t.fields) == 0 {
+		delete(b.schema.types, name)
+		return named(scalarJSON)
+	}
+	return named(name)
+}
+
+// typeName returns the GraphQL name of an OpenAPI schema name, such as io_k8s_api_core_v1_Pod.
+func typeName(name string) string {
+	return strings.Map(func(r rune) rune {
+		if r < 128 && isNameChar(byte(r)) {
+			return r
+		}
+		return '_'
+	}, name)
+}
+
+func isName(s string) bool {
+	if s == "" || s[0] >= '0' && s[0] <= '9' {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if !isNameChar(s[i]) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,71 @@
+package health
+
+import (
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func (in *HealthState) DeepCopyInto(out *HealthState) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+func (in *HealthState) DeepCopy() *HealthState {
+	if in == nil {
+		return nil
+	}
+	out := new(HealthState)
+	in.DeepCopyInto(out)
+	return out
+}
+
+func (in *HealthState) DeepCopyObject() runtime.Object {
+	return in.DeepCopy()
+}
+
+func (in *HealthStateStatus) DeepCopyInto(out *HealthStateStatus) {
+	*out = *in
+	if in.Tables != nil {
+		out.Tables = make([]TableHealth, len(in.Tables))
+		for i := range in.Tables {
+			in.Tables[i].DeepCopyInto(&out.Tables[i])
+		}
+	}
+	if in.Delegates != nil {
+		out.Delegates = make([]DelegateHealth, len(in.Delegates))
+		copy(out.Delegates, in.Delegates)
+	}
+}
+
+func (in *TableHealth) DeepCopyInto(out *TableHealth) {
+	*out = *in
+	if in.LastGCTime != nil {
+		out.LastGCTime = in.LastGCTime.DeepCopy()
+	}
+}
+
+func (in *HealthStateList) DeepCopyInto(out *HealthStateList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		out.Items = make([]HealthState, len(in.Items))
+		for i := range in.Items {
+			in.Items[i].DeepCopyInto(&out.Items[i])
+		}
+	}
+}
+
+func (in *HealthStateList) DeepCopy() *HealthStateList {
+	if in == nil {
+		return nil
+	}
+	out := new(HealthStateList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+func (in *HealthStateList) DeepCopyObject() runtime.Object {
+	return in.DeepCopy()
+}
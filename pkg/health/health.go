@@ -0,0 +1,122 @@
+package health
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+
+	"github.com/acorn-io/mink/pkg/db"
+	"github.com/acorn-io/mink/pkg/stores"
+	"github.com/acorn-io/mink/pkg/types"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apiserver/pkg/registry/rest"
+	"k8s.io/apiserver/pkg/server/healthz"
+	"k8s.io/apiserver/pkg/storage"
+)
+
+// Name is the name of the only HealthState served.
+const Name = "default"
+
+// TableHealthSource returns the state of storage tables, *db.Factory is one.
+type TableHealthSource interface {
+	TableHealth(ctx context.Context) []db.TableHealth
+}
+
+// NewStore returns the read-only storage of healthstates, a single HealthState named default that is computed on
+// every request from the tables of the sources and the checks of delegates, such as remote servers the stores
+// delegate to. The HealthState types must have been added to the scheme with AddToScheme.
+func NewStore(scheme *runtime.Scheme, tables []TableHealthSource, delegates ...healthz.HealthChecker) rest.Storage {
+	strategy := &Strategy{
+		Tables:    tables,
+		Delegates: delegates,
+	}
+	return stores.NewBuilder(scheme, &HealthState{}).
+		WithGet(strategy).
+		WithList(strategy).Build()
+}
+
+type Strategy struct {
+	Tables    []TableHealthSource
+	Delegates []healthz.HealthChecker
+}
+
+func (s *Strategy) NamespaceScoped() bool {
+	return false
+}
+
+func (s *Strategy) Get(ctx context.Context, _, name string) (types.Object, error) {
+	if name != Name {
+		return nil, apierrors.NewNotFound(schema.GroupResource{Resource: "healthstates"}, name)
+	}
+	return s.state(ctx)
+}
+
+func (s *Strategy) List(ctx context.Context, _ string, _ storage.ListOptions) (types.ObjectList, error) {
+	state, err := s.state(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &HealthStateList{
+		Items: []HealthState{*state},
+	}, nil
+}
+
+func (s *Strategy) state(ctx context.Context) (*HealthState, error) {
+	state := &HealthState{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: Name,
+		},
+		Status: HealthStateStatus{
+			Healthy: true,
+		},
+	}
+
+	for _, source := range s.Tables {
+		for _, table := range source.TableHealth(ctx) {
+			health := TableHealth{
+				Name:                     table.Table,
+				Healthy:                  table.Healthy(),
+				Error:                    table.Error,
+				LatestResourceVersion:    strconv.FormatUint(uint64(table.LatestID), 10),
+				CompactedResourceVersion: strconv.FormatUint(uint64(table.CompactedID), 10),
+				GCEnabled:                table.GCEnabled,
+				LastGCError:              table.LastGCError,
+			}
+			if !table.LastGC.IsZero() {
+				health.LastGCTime = &metav1.Time{Time: table.LastGC}
+			}
+			state.Status.Healthy = state.Status.Healthy && health.Healthy
+			state.Status.Tables = append(state.Status.Tables, health)
+		}
+	}
+
+	for _, delegate := range s.Delegates {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, "/healthz", nil)
+		if err != nil {
+			return nil, err
+		}
+		health := DelegateHealth{
+			Name:      delegate.Name(),
+			Available: true,
+		}
+		if err := delegate.Check(req); err != nil {
+			health.Available = false
+			health.Error = err.Error()
+		}
+		state.Status.Healthy = state.Status.Healthy && health.Available
+		state.Status.Delegates = append(state.Status.Delegates, health)
+	}
+
+	return state, nil
+}
+
+func (s *Strategy) New() types.Object {
+	return &HealthState{}
+}
+
+func (s *Strategy) NewList() types.ObjectList {
+	return &HealthStateList{}
+}
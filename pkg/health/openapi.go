@@ -0,0 +1,139 @@
+package health
+
+import (
+	"k8s.io/kube-openapi/pkg/common"
+	"k8s.io/kube-openapi/pkg/validation/spec"
+)
+
+const pkgPath = "github.com/acorn-io/mink/pkg/health."
+
+// GetOpenAPIDefinitions returns the OpenAPI definitions of the HealthState types, merge them into the definitions
+// of the server's OpenAPIConfig when serving healthstates.
+func GetOpenAPIDefinitions(ref common.ReferenceCallback) map[string]common.OpenAPIDefinition {
+	return map[string]common.OpenAPIDefinition{
+		pkgPath + "HealthState":       healthState(ref),
+		pkgPath + "HealthStateList":   healthStateList(ref),
+		pkgPath + "HealthStateStatus": healthStateStatus(ref),
+		pkgPath + "TableHealth":       tableHealth(ref),
+		pkgPath + "DelegateHealth":    delegateHealth(),
+	}
+}
+
+func property(typ, description string) spec.Schema {
+	return spec.Schema{
+		SchemaProps: spec.SchemaProps{
+			Description: description,
+			Type:        []string{typ},
+			Default:     defaultFor(typ),
+		},
+	}
+}
+
+func defaultFor(typ string) interface{} {
+	switch typ {
+	case "boolean":
+		return false
+	case "string":
+		return ""
+	}
+	return nil
+}
+
+func refProperty(ref common.ReferenceCallback, name string) spec.Schema {
+	return spec.Schema{
+		SchemaProps: spec.SchemaProps{
+			Default: map[string]interface{}{},
+			Ref:     ref(name),
+		},
+	}
+}
+
+func arrayProperty(ref common.ReferenceCallback, name string) spec.Schema {
+	return spec.Schema{
+		SchemaProps: spec.SchemaProps{
+			Type: []string{"array"},
+			Items: &spec.SchemaOrArray{
+				Schema: &spec.Schema{
+					SchemaProps: spec.SchemaProps{
+						Default: map[string]interface{}{},
+						Ref:     ref(name),
+					},
+				},
+			},
+		},
+	}
+}
+
+func object(description string, required []string, properties map[string]spec.Schema, dependencies ...string) common.OpenAPIDefinition {
+	return common.OpenAPIDefinition{
+		Schema: spec.Schema{
+			SchemaProps: spec.SchemaProps{
+				Description: description,
+				Type:        []string{"object"},
+				Properties:  properties,
+				Required:    required,
+			},
+		},
+		Dependencies: dependencies,
+	}
+}
+
+func healthState(ref common.ReferenceCallback) common.OpenAPIDefinition {
+	return object("HealthState summarizes the health of the server, its storage and the services it delegates to.", nil,
+		map[string]spec.Schema{
+			"kind":       property("string", "Kind is a string value representing the REST resource this object represents."),
+			"apiVersion": property("string", "APIVersion defines the versioned schema of this representation of an object."),
+			"metadata":   refProperty(ref, "k8s.io/apimachinery/pkg/apis/meta/v1.ObjectMeta"),
+			"status":     refProperty(ref, pkgPath+"HealthStateStatus"),
+		},
+		pkgPath+"HealthStateStatus", "k8s.io/apimachinery/pkg/apis/meta/v1.ObjectMeta")
+}
+
+func healthStateList(ref common.ReferenceCallback) common.OpenAPIDefinition {
+	return object("", []string{"items"},
+		map[string]spec.Schema{
+			"kind":       property("string", "Kind is a string value representing the REST resource this object represents."),
+			"apiVersion": property("string", "APIVersion defines the versioned schema of this representation of an object."),
+			"metadata":   refProperty(ref, "k8s.io/apimachinery/pkg/apis/meta/v1.ListMeta"),
+			"items":      arrayProperty(ref, pkgPath+"HealthState"),
+		},
+		pkgPath+"HealthState", "k8s.io/apimachinery/pkg/apis/meta/v1.ListMeta")
+}
+
+func healthStateStatus(ref common.ReferenceCallback) common.OpenAPIDefinition {
+	return object("", []string{"healthy"},
+		map[string]spec.Schema{
+			"healthy":   property("boolean", "Healthy is true if all tables and delegates are healthy."),
+			"tables":    arrayProperty(ref, pkgPath+"TableHealth"),
+			"delegates": arrayProperty(ref, pkgPath+"DelegateHealth"),
+		},
+		pkgPath+"DelegateHealth", pkgPath+"TableHealth")
+}
+
+func tableHealth(ref common.ReferenceCallback) common.OpenAPIDefinition {
+	return object("", []string{"name", "healthy", "gcEnabled"},
+		map[string]spec.Schema{
+			"name":                     property("string", ""),
+			"healthy":                  property("boolean", ""),
+			"error":                    property("string", "Error is set if the table could not be queried."),
+			"latestResourceVersion":    property("string", ""),
+			"compactedResourceVersion": property("string", ""),
+			"gcEnabled":                property("boolean", ""),
+			"lastGCTime": {
+				SchemaProps: spec.SchemaProps{
+					Ref: ref("k8s.io/apimachinery/pkg/apis/meta/v1.Time"),
+				},
+			},
+			"lastGCError": property("string", ""),
+		},
+		"k8s.io/apimachinery/pkg/apis/meta/v1.Time")
+}
+
+func delegateHealth() common.OpenAPIDefinition {
+	return object("", []string{"name", "available"},
+		map[string]spec.Schema{
+			"name":      property("string", ""),
+			"available": property("boolean", ""),
+			"error":     property("string", ""),
+		})
+}
@@ -0,0 +1,53 @@
+package health
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// AddToScheme registers the HealthState types in the group version the embedder serves them in.
+func AddToScheme(scheme *runtime.Scheme, gv schema.GroupVersion) error {
+	scheme.AddKnownTypes(gv, &HealthState{}, &HealthStateList{})
+	return nil
+}
+
+// HealthState summarizes the health of the server, its storage and the services it delegates to.
+type HealthState struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Status HealthStateStatus `json:"status,omitempty"`
+}
+
+type HealthStateStatus struct {
+	// Healthy is true if all tables and delegates are healthy.
+	Healthy   bool             `json:"healthy"`
+	Tables    []TableHealth    `json:"tables,omitempty"`
+	Delegates []DelegateHealth `json:"delegates,omitempty"`
+}
+
+type TableHealth struct {
+	Name    string `json:"name"`
+	Healthy bool   `json:"healthy"`
+	// Error is set if the table could not be queried.
+	Error                    string       `json:"error,omitempty"`
+	LatestResourceVersion    string       `json:"latestResourceVersion,omitempty"`
+	CompactedResourceVersion string       `json:"compactedResourceVersion,omitempty"`
+	GCEnabled                bool         `json:"gcEnabled"`
+	LastGCTime               *metav1.Time `json:"lastGCTime,omitempty"`
+	LastGCError              string       `json:"lastGCError,omitempty"`
+}
+
+type DelegateHealth struct {
+	Name      string `json:"name"`
+	Available bool   `json:"available"`
+	Error     string `json:"error,omitempty"`
+}
+
+type HealthStateList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+
+	Items []HealthState `json:"items"`
+}
@@ -0,0 +1,233 @@
+// Package logging defines the logger of mink components, so that embedders decide the format, level and destination
+// of their logs. Components that aren't given a logger log to Default, which is the standard logrus logger unless
+// SetDefault replaced it.
+package logging
+
+import (
+	"fmt"
+	"sync/atomic"
+
+	"github.com/go-logr/logr"
+	"github.com/sirupsen/logrus"
+)
+
+// Logger logs printf style messages at four levels. Its implementations must be safe for concurrent use.
+type Logger interface {
+	Debugf(format string, args ...any)
+	Infof(format string, args ...any)
+	Warnf(format string, args ...any)
+	Errorf(format string, args ...any)
+	// WithValues returns a logger adding the key value pairs to every message, such as the table or the component
+	// the messages are about.
+	WithValues(keysAndValues ...any) Logger
+}
+
+var defaultLogger atomic.Pointer[Logger]
+
+// SetDefault replaces the logger of the components that weren't given one, including those already created.
+func SetDefault(logger Logger) {
+	defaultLogger.Store(&logger)
+}
+
+// Default returns the logger of the components that weren't given one. It logs to the logger last passed to
+// SetDefault at the time of each message, or to the standard logrus logger.
+func Default() Logger {
+	return deferred{}
+}
+
+func current() Logger {
+	if logger := defaultLogger.Load(); logger != nil && *logger != nil {
+		return *logger
+	}
+	return Logrus(logrus.StandardLogger())
+}
+
+// deferred logs to the default logger at the time of each message.
+type deferred struct {
+	values []any
+}
+
+func (d deferred) logger() Logger {
+	if len(d.values) == 0 {
+		return current()
+	}
+	return current().WithValues(d.values...)
+}
+
+func (d deferred) Debugf(format string, args ...any) { d.logger().Debugf(format, args...) }
+func (d deferred) Infof(format string, args ...any)  { d.logger().Infof(format, args...) }
+func (d deferred) Warnf(format string, args ...any)  { d.logger().Warnf(format, args...) }
+func (d deferred) Errorf(format string, args ...any) { d.logger().Errorf(format, args...) }
+
+func (d deferred) WithValues(keysAndValues ...any) Logger {
+	return deferred{values: append(d.values[:len(d.values):len(d.values)], keysAndValues...)}
+}
+
+// leveled is implemented by loggers whose level can be changed while they are in use.
+type leveled interface {
+	level() (logrus.Level, bool)
+	setLevel(level logrus.Level) bool
+}
+
+// Level returns the level of logger, and false if its level can't be changed, see SetLevel.
+func Level(logger Logger) (logrus.Level, bool) {
+	if l, ok := logger.(leveled); ok {
+		return l.level()
+	}
+	return 0, false
+}
+
+// SetLevel changes the level of logger and returns true if it can, which is the case for the loggers returned by
+// Logrus and Default while it logs to one of them. The levels of loggers returned by Logr are up to their sink.
+func SetLevel(logger Logger, level logrus.Level) bool {
+	if l, ok := logger.(leveled); ok {
+		return l.setLevel(level)
+	}
+	return false
+}
+
+func (d deferred) level() (logrus.Level, bool) {
+	return Level(current())
+}
+
+func (d deferred) setLevel(level logrus.Level) bool {
+	return SetLevel(current(), level)
+}
+
+// Logrus returns a logger writing to a logrus logger or entry, values are added as fields.
+func Logrus(logger logrus.FieldLogger) Logger {
+	return logrusLogger{logger: logger}
+}
+
+type logrusLogger struct {
+	logger logrus.FieldLogger
+}
+
+func (l logrusLogger) Debugf(format string, args ...any) { l.logger.Debugf(format, args...) }
+func (l logrusLogger) Infof(format string, args ...any)  { l.logger.Infof(format, args...) }
+func (l logrusLogger) Warnf(format string, args ...any)  { l.logger.Warnf(format, args...) }
+func (l logrusLogger) Errorf(format string, args ...any) { l.logger.Errorf(format, args...) }
+
+func (l logrusLogger) base() *logrus.Logger {
+	switch logger := l.logger.(type) {
+	case *logrus.Logger:
+		return logger
+	case *logrus.Entry:
+		return logger.Logger
+	}
+	return nil
+}
+
+func (l logrusLogger) level() (logrus.Level, bool) {
+	if logger := l.base(); logger != nil {
+		return logger.GetLevel(), true
+	}
+	return 0, false
+}
+
+func (l logrusLogger) setLevel(level logrus.Level) bool {
+	if logger := l.base(); logger != nil {
+		logger.SetLevel(level)
+		return true
+	}
+	return false
+}
+
+func (l logrusLogger) WithValues(keysAndValues ...any) Logger {
+	fields := make(logrus.Fields, (len(keysAndValues)+1)/2)
+	for i := 0; i < len(keysAndValues); i += 2 {
+		var value any
+		if i+1 < len(keysAndValues) {
+			value = keysAndValues[i+1]
+		}
+		fields[fmt.Sprint(keysAndValues[i])] = value
+	}
+	return logrusLogger{logger: l.logger.WithFields(fields)}
+}
+
+// Logr returns a logger writing to a logr logger, such as the logger of controller-runtime or klog. Debug messages
+// are logged at V(1). logr has no warning level, warnings are logged at V(0) like info messages.
+func Logr(logger logr.Logger) Logger {
+	return logrLogger{logger: logger}
+}
+
+// Discard returns a logger that drops every message.
+func Discard() Logger {
+	return Logr(logr.Discard())
+}
+
+type logrLogger struct {
+	logger logr.Logger
+}
+
+func (l logrLogger) Debugf(format string, args ...any) {
+	if l.logger.V(1).Enabled() {
+		l.logger.V(1).Info(fmt.Sprintf(format, args...))
+	}
+}
+
+func (l logrLogger) Infof(format string, args ...any) {
+	if l.logger.Enabled() {
+		l.logger.Info(fmt.Sprintf(format, args...))
+	}
+}
+
+func (l logrLogger) Warnf(format string, args ...any) {
+	l.Infof(format, args...)
+}
+
+func (l logrLogger) Errorf(format string, args ...any) {
+	l.logger.Error(nil, fmt.Sprintf(format, args...))
+}
+
+func (l logrLogger) WithValues(keysAndValues ...any) Logger {
+	return logrLogger{logger: l.logger.WithValues(keysAndValues...)}
+}
+
+// ToLogr returns a logr logger writing to logger, for libraries logging with logr or klog. Messages at V(0) are logged
+// as info messages and more verbose ones as debug messages, logr names are added as the value "logger".
+func ToLogr(logger Logger) logr.Logger {
+	return logr.New(logrSink{logger: logger})
+}
+
+type logrSink struct {
+	logger Logger
+}
+
+func (s logrSink) Init(logr.RuntimeInfo) {}
+
+func (s logrSink) Enabled(int) bool {
+	return true
+}
+
+func (s logrSink) Info(level int, msg string, keysAndValues ...any) {
+	logger := s.logger
+	if len(keysAndValues) > 0 {
+		logger = logger.WithValues(keysAndValues...)
+	}
+	if level > 0 {
+		logger.Debugf("%s", msg)
+	} else {
+		logger.Infof("%s", msg)
+	}
+}
+
+func (s logrSink) Error(err error, msg string, keysAndValues ...any) {
+	logger := s.logger
+	if len(keysAndValues) > 0 {
+		logger = logger.WithValues(keysAndValues...)
+	}
+	if err != nil {
+		logger.Errorf("%s: %v", msg, err)
+	} else {
+		logger.Errorf("%s", msg)
+	}
+}
+
+func (s logrSink) WithValues(keysAndValues ...any) logr.LogSink {
+	return logrSink{logger: s.logger.WithValues(keysAndValues...)}
+}
+
+func (s logrSink) WithName(name string) logr.LogSink {
+	return logrSink{logger: s.logger.WithValues("logger", name)}
+}
@@ -0,0 +1,52 @@
+package logging
+
+import (
+	"testing"
+
+	"github.com/go-logr/logr/funcr"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDefault(t *testing.T) {
+	var lines []string
+	logger := Logr(funcr.New(func(prefix, args string) {
+		lines = append(lines, args)
+	}, funcr.Options{Verbosity: 1}))
+
+	table := Default().WithValues("table", "pod")
+
+	SetDefault(logger)
+	defer SetDefault(nil)
+
+	table.Infof("Loaded %d records", 2)
+	Default().Debugf("Compacted")
+	ToLogr(Default()).WithName("klog").Error(nil, "Failed", "id", 1)
+
+	assert.Equal(t, []string{
+		`"level"=0 "msg"="Loaded 2 records" "table"="pod"`,
+		`"level"=1 "msg"="Compacted"`,
+		`"msg"="Failed" "error"=null "logger"="klog" "id"=1`,
+	}, lines)
+}
+
+func TestSetLevel(t *testing.T) {
+	base := logrus.New()
+	logger := Logrus(base).WithValues("table", "pod")
+
+	assert.True(t, SetLevel(logger, logrus.DebugLevel))
+	assert.Equal(t, logrus.DebugLevel, base.GetLevel())
+	level, ok := Level(logger)
+	assert.True(t, ok)
+	assert.Equal(t, logrus.DebugLevel, level)
+
+	// the default logger changes the level of the logger it currently logs to
+	SetDefault(Logrus(base))
+	defer SetDefault(nil)
+	assert.True(t, SetLevel(Default(), logrus.WarnLevel))
+	assert.Equal(t, logrus.WarnLevel, base.GetLevel())
+
+	_, ok = Level(Discard())
+	assert.False(t, ok)
+	assert.False(t, SetLevel(Discard(), logrus.DebugLevel))
+}
@@ -0,0 +1,117 @@
+// Package loopback gives the handlers served next to the API, such as the dashboard and the GraphQL gateway, clients
+// of the server that act as the user of a request, and a discovery of the resources the server serves that is cached
+// across requests.
+package loopback
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apiserver/pkg/endpoints/request"
+	"k8s.io/apiserver/pkg/server"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/discovery/cached/memory"
+	"k8s.io/client-go/rest"
+)
+
+// DiscoveryTTL is how long the discovery is cached, resources installed at runtime are seen after at most this long.
+const DiscoveryTTL = 30 * time.Second
+
+// Client is the loopback client of a server. Its methods fail with 503 until PostStart ran.
+type Client struct {
+	name string
+
+	lock      sync.Mutex
+	config    *rest.Config
+	discovery discovery.CachedDiscoveryInterface
+	refreshed time.Time
+}
+
+// New returns the loopback client of the handler called name, which is used in the errors returned while the server
+// is starting.
+func New(name string) *Client {
+	return &Client{name: name}
+}
+
+// PostStart takes the loopback client configuration of the server, it must be called from the post start hook of the
+// server, see server.Config.PostStartFunc.
+func (c *Client) PostStart(ctx server.PostStartHookContext) error {
+	config := rest.CopyConfig(ctx.LoopbackClientConfig)
+	client, err := discovery.NewDiscoveryClientForConfig(config)
+	if err != nil {
+		return err
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.config = config
+	c.discovery = memory.NewMemCacheClient(client)
+	c.refreshed = time.Now()
+	return nil
+}
+
+func (c *Client) starting() error {
+	return apierrors.NewServiceUnavailable(fmt.Sprintf("the %s is starting", c.name))
+}
+
+// Config returns the configuration of the loopback client, which acts as the server itself.
+func (c *Client) Config() (*rest.Config, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.config == nil {
+		return nil, c.starting()
+	}
+	return rest.CopyConfig(c.config), nil
+}
+
+// ForRequest returns the configuration of a client impersonating the user of req.
+func (c *Client) ForRequest(req *http.Request) (*rest.Config, error) {
+	config, err := c.Config()
+	if err != nil {
+		return nil, err
+	}
+	user, ok := request.UserFrom(req.Context())
+	if !ok {
+		return nil, apierrors.NewUnauthorized("no user found for request")
+	}
+	config.Impersonate = rest.ImpersonationConfig{
+		UserName: user.GetName(),
+		UID:      user.GetUID(),
+		Groups:   user.GetGroups(),
+		Extra:    user.GetExtra(),
+	}
+	return config, nil
+}
+
+// Discovery returns the cached discovery client of the server, invalidated every DiscoveryTTL. The discovery is done
+// as the server, the resources are not filtered by what the user can access.
+func (c *Client) Discovery() (discovery.CachedDiscoveryInterface, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.discovery == nil {
+		return nil, c.starting()
+	}
+	if time.Since(c.refreshed) > DiscoveryTTL {
+		c.discovery.Invalidate()
+		c.refreshed = time.Now()
+	}
+	return c.discovery, nil
+}
+
+// PreferredResources returns the resources of the preferred version of every group, see
+// discovery.ServerPreferredResources. Groups that fail to be discovered are left out.
+func (c *Client) PreferredResources() ([]*metav1.APIResourceList, error) {
+	client, err := c.Discovery()
+	if err != nil {
+		return nil, err
+	}
+	lists, err := client.ServerPreferredResources()
+	if err != nil && len(lists) == 0 {
+		return nil, err
+	}
+	return lists, nil
+}
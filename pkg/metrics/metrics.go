@@ -0,0 +1,130 @@
+// Package metrics defines the metrics of the mink storage layer. They are registered with the legacy registry of
+// component-base, which the generic API server serves on /metrics, and labeled with the table they are about.
+package metrics
+
+import (
+	"k8s.io/component-base/metrics"
+	"k8s.io/component-base/metrics/legacyregistry"
+)
+
+const (
+	namespace = "mink"
+	subsystem = "storage"
+)
+
+var (
+	// AbortedOperations counts storage operations that were aborted because the request they ran for timed out or
+	// its client went away.
+	AbortedOperations = metrics.NewCounterVec(
+		&metrics.CounterOpts{
+			Namespace:      namespace,
+			Subsystem:      subsystem,
+			Name:           "aborted_operations_total",
+			Help:           "Number of storage operations aborted because the request deadline passed or the client disconnected.",
+			StabilityLevel: metrics.ALPHA,
+		},
+		[]string{"table", "operation", "reason"},
+	)
+
+	// OperationDuration is the latency of inserts, gets and the initial read of watches, failed ones included.
+	OperationDuration = metrics.NewHistogramVec(
+		&metrics.HistogramOpts{
+			Namespace:      namespace,
+			Subsystem:      subsystem,
+			Name:           "operation_duration_seconds",
+			Help:           "Latency of storage operations by table and operation.",
+			Buckets:        metrics.ExponentialBuckets(0.001, 2, 15),
+			StabilityLevel: metrics.ALPHA,
+		},
+		[]string{"table", "operation"},
+	)
+
+	// CompactionDuration is the time a garbage collection run took to mark the superseded records of a table as
+	// garbage.
+	CompactionDuration = metrics.NewHistogramVec(
+		&metrics.HistogramOpts{
+			Namespace:      namespace,
+			Subsystem:      subsystem,
+			Name:           "compaction_duration_seconds",
+			Help:           "Duration of the compaction of a table.",
+			Buckets:        metrics.ExponentialBuckets(0.01, 2, 15),
+			StabilityLevel: metrics.ALPHA,
+		},
+		[]string{"table"},
+	)
+
+	// CompactedRows counts the records marked as garbage by compaction.
+	CompactedRows = metrics.NewCounterVec(
+		&metrics.CounterOpts{
+			Namespace:      namespace,
+			Subsystem:      subsystem,
+			Name:           "compacted_rows_total",
+			Help:           "Number of records marked as garbage by compaction.",
+			StabilityLevel: metrics.ALPHA,
+		},
+		[]string{"table"},
+	)
+
+	// DeletedRows counts the garbage records deleted from the database.
+	DeletedRows = metrics.NewCounterVec(
+		&metrics.CounterOpts{
+			Namespace:      namespace,
+			Subsystem:      subsystem,
+			Name:           "deleted_rows_total",
+			Help:           "Number of garbage records deleted from the database.",
+			StabilityLevel: metrics.ALPHA,
+		},
+		[]string{"table"},
+	)
+
+	// Watchers is the number of open watches.
+	Watchers = metrics.NewGaugeVec(
+		&metrics.GaugeOpts{
+			Namespace:      namespace,
+			Subsystem:      subsystem,
+			Name:           "watchers",
+			Help:           "Number of open watches by table.",
+			StabilityLevel: metrics.ALPHA,
+		},
+		[]string{"table"},
+	)
+
+	// WatchOverflows counts the watches closed because their client read events slower than the table was written
+	// and their buffer filled up.
+	WatchOverflows = metrics.NewCounterVec(
+		&metrics.CounterOpts{
+			Namespace:      namespace,
+			Subsystem:      subsystem,
+			Name:           "watch_overflows_total",
+			Help:           "Number of watches closed because their event buffer was full.",
+			StabilityLevel: metrics.ALPHA,
+		},
+		[]string{"table"},
+	)
+
+	// BroadcastPending is the number of records the watch loop read but hasn't handed to the watches yet. It grows
+	// when a watch consumes its events slower than the table is written, which holds back every watch of the table.
+	BroadcastPending = metrics.NewGaugeVec(
+		&metrics.GaugeOpts{
+			Namespace:      namespace,
+			Subsystem:      subsystem,
+			Name:           "broadcast_pending_records",
+			Help:           "Number of records read by the watch loop that have not been sent to the watches yet.",
+			StabilityLevel: metrics.ALPHA,
+		},
+		[]string{"table"},
+	)
+)
+
+func init() {
+	legacyregistry.MustRegister(
+		AbortedOperations,
+		OperationDuration,
+		CompactionDuration,
+		CompactedRows,
+		DeletedRows,
+		Watchers,
+		WatchOverflows,
+		BroadcastPending,
+	)
+}
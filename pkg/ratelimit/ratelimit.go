@@ -0,0 +1,182 @@
+package ratelimit
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apiserver/pkg/authentication/user"
+	"k8s.io/apiserver/pkg/endpoints/handlers/responsewriters"
+	"k8s.io/apiserver/pkg/endpoints/request"
+	"k8s.io/client-go/kubernetes/scheme"
+)
+
+const (
+	VerbClassRead  = "read"
+	VerbClassWatch = "watch"
+	VerbClassWrite = "write"
+
+	defaultIdleTimeout = 10 * time.Minute
+)
+
+// Rate is the token bucket configuration for a single verb class.
+type Rate struct {
+	// QPS is the rate at which tokens are refilled. A QPS of zero disables limiting for the class.
+	QPS float64
+	// Burst is the bucket size. If zero, the ceiling of QPS is used.
+	Burst int
+}
+
+type Options struct {
+	// Rates is the limit per user, resource and verb class. Verb classes without an entry are not limited.
+	Rates map[string]Rate
+	// ExemptUsers are user names that are never limited.
+	ExemptUsers []string
+	// ExemptGroups are groups whose members are never limited.
+	ExemptGroups []string
+	// IdleTimeout is how long an unused bucket is kept. If zero, ten minutes is used.
+	IdleTimeout time.Duration
+}
+
+// DefaultOptions returns moderate limits that exempt cluster admins.
+func DefaultOptions() Options {
+	return Options{
+		Rates: map[string]Rate{
+			VerbClassRead:  {QPS: 50, Burst: 100},
+			VerbClassWatch: {QPS: 5, Burst: 20},
+			VerbClassWrite: {QPS: 20, Burst: 40},
+		},
+		ExemptGroups: []string{user.SystemPrivilegedGroup},
+	}
+}
+
+type bucket struct {
+	limiter  *rate.Limiter
+	lastUsed time.Time
+}
+
+// Limiter is a token bucket rate limiter keyed by user, group/resource and verb class. It must run after
+// authentication and request info resolution, see server.Config.AuthenticatedMiddleware.
+type Limiter struct {
+	lock         sync.Mutex
+	opts         Options
+	exemptUsers  sets.Set[string]
+	exemptGroups sets.Set[string]
+	buckets      map[string]*bucket
+	lastSweep    time.Time
+	now          func() time.Time
+}
+
+func New(opts Options) *Limiter {
+	l := &Limiter{
+		buckets: map[string]*bucket{},
+		now:     time.Now,
+	}
+	l.SetOptions(opts)
+	return l
+}
+
+// SetOptions replaces the limits at runtime. Existing buckets are dropped so the new limits apply immediately.
+func (l *Limiter) SetOptions(opts Options) {
+	if opts.IdleTimeout <= 0 {
+		opts.IdleTimeout = defaultIdleTimeout
+	}
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.opts = opts
+	l.exemptUsers = sets.New(opts.ExemptUsers...)
+	l.exemptGroups = sets.New(opts.ExemptGroups...)
+	l.buckets = map[string]*bucket{}
+}
+
+// Middleware returns 429 Too Many Requests with a Retry-After header and a Status for requests over the limit.
+func (l *Limiter) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if retryAfter, ok := l.allow(req); !ok {
+			err := apierrors.NewTooManyRequests(fmt.Sprintf("Too many requests, please try again in %d seconds.", retryAfter), retryAfter)
+			responsewriters.ErrorNegotiated(err, scheme.Codecs, schema.GroupVersion{Version: "v1"}, w, req)
+			return
+		}
+		next.ServeHTTP(w, req)
+	})
+}
+
+func (l *Limiter) allow(req *http.Request) (int, bool) {
+	info, ok := request.RequestInfoFrom(req.Context())
+	if !ok || !info.IsResourceRequest {
+		return 0, true
+	}
+	u, ok := request.UserFrom(req.Context())
+	if !ok {
+		return 0, true
+	}
+
+	class := VerbClass(info.Verb)
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	if l.exemptUsers.Has(u.GetName()) || l.exemptGroups.HasAny(u.GetGroups()...) {
+		return 0, true
+	}
+
+	r, ok := l.opts.Rates[class]
+	if !ok || r.QPS <= 0 {
+		return 0, true
+	}
+
+	now := l.now()
+	l.sweep(now)
+
+	key := u.GetName() + "/" + info.APIGroup + "/" + info.Resource + "/" + class
+	b, ok := l.buckets[key]
+	if !ok {
+		burst := r.Burst
+		if burst <= 0 {
+			burst = int(math.Ceil(r.QPS))
+		}
+		b = &bucket{
+			limiter: rate.NewLimiter(rate.Limit(r.QPS), burst),
+		}
+		l.buckets[key] = b
+	}
+	b.lastUsed = now
+
+	reservation := b.limiter.ReserveN(now, 1)
+	if delay := reservation.DelayFrom(now); delay > 0 {
+		reservation.CancelAt(now)
+		return int(math.Ceil(delay.Seconds())), false
+	}
+	return 0, true
+}
+
+func (l *Limiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < l.opts.IdleTimeout {
+		return
+	}
+	l.lastSweep = now
+	for key, b := range l.buckets {
+		if now.Sub(b.lastUsed) > l.opts.IdleTimeout {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// VerbClass groups request verbs that share a bucket.
+func VerbClass(verb string) string {
+	switch verb {
+	case "get", "list":
+		return VerbClassRead
+	case "watch":
+		return VerbClassWatch
+	default:
+		return VerbClassWrite
+	}
+}
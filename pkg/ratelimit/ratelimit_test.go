@@ -0,0 +1,122 @@
+package ratelimit
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apiserver/pkg/authentication/user"
+	"k8s.io/apiserver/pkg/endpoints/request"
+)
+
+// newTestLimiter returns a limiter whose clock only moves when the returned func is called.
+func newTestLimiter(opts Options) (*Limiter, func(time.Duration)) {
+	now := time.Unix(0, 0)
+	l := New(opts)
+	l.now = func() time.Time {
+		return now
+	}
+	return l, func(d time.Duration) {
+		now = now.Add(d)
+	}
+}
+
+// serve sends a request of u with verb to resource through the middleware of l and returns the response.
+func serve(l *Limiter, u user.Info, verb, resource string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/apis/example.com/v1/"+resource, nil)
+	ctx := request.WithUser(req.Context(), u)
+	ctx = request.WithRequestInfo(ctx, &request.RequestInfo{
+		IsResourceRequest: true,
+		Verb:              verb,
+		APIGroup:          "example.com",
+		Resource:          resource,
+	})
+	rw := httptest.NewRecorder()
+	l.Middleware(http.HandlerFunc(func(rw http.ResponseWriter, _ *http.Request) {
+		rw.WriteHeader(http.StatusOK)
+	})).ServeHTTP(rw, req.WithContext(ctx))
+	return rw
+}
+
+func TestVerbClassBuckets(t *testing.T) {
+	l, _ := newTestLimiter(Options{
+		Rates: map[string]Rate{
+			VerbClassRead:  {QPS: 1, Burst: 2},
+			VerbClassWrite: {QPS: 1},
+		},
+		ExemptGroups: []string{user.SystemPrivilegedGroup},
+	})
+	alice := &user.DefaultInfo{Name: "alice"}
+
+	// reads share a bucket of two
+	assert.Equal(t, http.StatusOK, serve(l, alice, "get", "things").Code)
+	assert.Equal(t, http.StatusOK, serve(l, alice, "list", "things").Code)
+	assert.Equal(t, http.StatusTooManyRequests, serve(l, alice, "get", "things").Code)
+
+	// writes, other resources and other users have buckets of their own, watches aren't limited
+	assert.Equal(t, http.StatusOK, serve(l, alice, "create", "things").Code)
+	assert.Equal(t, http.StatusTooManyRequests, serve(l, alice, "delete", "things").Code)
+	assert.Equal(t, http.StatusOK, serve(l, alice, "get", "others").Code)
+	assert.Equal(t, http.StatusOK, serve(l, &user.DefaultInfo{Name: "bob"}, "get", "things").Code)
+	for i := 0; i < 10; i++ {
+		assert.Equal(t, http.StatusOK, serve(l, alice, "watch", "things").Code)
+	}
+
+	// exempt users are never limited
+	admin := &user.DefaultInfo{Name: "admin", Groups: []string{user.SystemPrivilegedGroup}}
+	for i := 0; i < 10; i++ {
+		assert.Equal(t, http.StatusOK, serve(l, admin, "get", "things").Code)
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	l, advance := newTestLimiter(Options{Rates: map[string]Rate{VerbClassRead: {QPS: 0.25, Burst: 1}}})
+	alice := &user.DefaultInfo{Name: "alice"}
+
+	assert.Equal(t, http.StatusOK, serve(l, alice, "get", "things").Code)
+	rw := serve(l, alice, "get", "things")
+	assert.Equal(t, http.StatusTooManyRequests, rw.Code)
+	assert.Equal(t, "4", rw.Header().Get("Retry-After"))
+
+	// the rejection is a Status, like the errors of the API
+	status := &metav1.Status{}
+	if assert.NoError(t, json.Unmarshal(rw.Body.Bytes(), status)) {
+		assert.Equal(t, "Status", status.Kind)
+		assert.Equal(t, metav1.StatusReasonTooManyRequests, status.Reason)
+		assert.Equal(t, int32(http.StatusTooManyRequests), status.Code)
+		if assert.NotNil(t, status.Details) {
+			assert.Equal(t, int32(4), status.Details.RetryAfterSeconds)
+		}
+	}
+
+	// rejected requests don't use tokens, the bucket refills over time
+	advance(2 * time.Second)
+	assert.Equal(t, "2", serve(l, alice, "get", "things").Header().Get("Retry-After"))
+	advance(2 * time.Second)
+	assert.Equal(t, http.StatusOK, serve(l, alice, "get", "things").Code)
+}
+
+func TestSetOptions(t *testing.T) {
+	l, _ := newTestLimiter(Options{Rates: map[string]Rate{VerbClassRead: {QPS: 1}}})
+	alice := &user.DefaultInfo{Name: "alice"}
+
+	assert.Equal(t, http.StatusOK, serve(l, alice, "get", "things").Code)
+	assert.Equal(t, http.StatusTooManyRequests, serve(l, alice, "get", "things").Code)
+
+	// new limits apply to the next request, with full buckets
+	l.SetOptions(Options{Rates: map[string]Rate{VerbClassRead: {QPS: 1, Burst: 3}}})
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, http.StatusOK, serve(l, alice, "get", "things").Code)
+	}
+	assert.Equal(t, http.StatusTooManyRequests, serve(l, alice, "get", "things").Code)
+
+	// a class without a rate isn't limited
+	l.SetOptions(Options{ExemptUsers: []string{"bob"}})
+	for i := 0; i < 10; i++ {
+		assert.Equal(t, http.StatusOK, serve(l, alice, "get", "things").Code)
+	}
+}
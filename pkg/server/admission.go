@@ -0,0 +1,75 @@
+package server
+
+import (
+	"context"
+	"errors"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apiserver/pkg/admission"
+)
+
+// AdmissionFunc admits a request for a resource, returning an error rejects it. Errors that aren't API status errors
+// are returned to the client as Forbidden.
+type AdmissionFunc func(ctx context.Context, a admission.Attributes) error
+
+// NewMutatingAdmission returns an admission plugin calling mutate for the given operations on resource and its
+// subresources, or for every operation if none are given. mutate may change the object of the request, a.GetObject(),
+// in place. Mutating plugins run before every validating plugin.
+func NewMutatingAdmission(resource schema.GroupResource, mutate AdmissionFunc, operations ...admission.Operation) admission.Interface {
+	return &mutatingAdmission{
+		funcAdmission: newFuncAdmission(resource, mutate, operations),
+	}
+}
+
+// NewValidatingAdmission returns an admission plugin calling validate for the given operations on resource and its
+// subresources, or for every operation if none are given. validate must not change the request.
+func NewValidatingAdmission(resource schema.GroupResource, validate AdmissionFunc, operations ...admission.Operation) admission.Interface {
+	return &validatingAdmission{
+		funcAdmission: newFuncAdmission(resource, validate, operations),
+	}
+}
+
+type funcAdmission struct {
+	*admission.Handler
+	resource schema.GroupResource
+	fn       AdmissionFunc
+}
+
+func newFuncAdmission(resource schema.GroupResource, fn AdmissionFunc, operations []admission.Operation) funcAdmission {
+	if len(operations) == 0 {
+		operations = []admission.Operation{admission.Create, admission.Update, admission.Delete, admission.Connect}
+	}
+	return funcAdmission{
+		Handler:  admission.NewHandler(operations...),
+		resource: resource,
+		fn:       fn,
+	}
+}
+
+func (f *funcAdmission) admit(ctx context.Context, a admission.Attributes) error {
+	if a.GetResource().GroupResource() != f.resource {
+		return nil
+	}
+	err := f.fn(ctx, a)
+	if status := apierrors.APIStatus(nil); err == nil || errors.As(err, &status) {
+		return err
+	}
+	return admission.NewForbidden(a, err)
+}
+
+type mutatingAdmission struct {
+	funcAdmission
+}
+
+func (m *mutatingAdmission) Admit(ctx context.Context, a admission.Attributes, _ admission.ObjectInterfaces) error {
+	return m.admit(ctx, a)
+}
+
+type validatingAdmission struct {
+	funcAdmission
+}
+
+func (v *validatingAdmission) Validate(ctx context.Context, a admission.Attributes, _ admission.ObjectInterfaces) error {
+	return v.admit(ctx, a)
+}
@@ -0,0 +1,80 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apiserver/pkg/admission"
+	"k8s.io/apiserver/pkg/authentication/user"
+)
+
+var configMaps = schema.GroupResource{Resource: "configmaps"}
+
+func attributes(resource schema.GroupResource, operation admission.Operation, obj *corev1.ConfigMap) admission.Attributes {
+	return admission.NewAttributesRecord(obj, nil, schema.GroupVersionKind{Version: "v1", Kind: "ConfigMap"}, "default",
+		obj.Name, resource.WithVersion("v1"), "", operation, nil, false, &user.DefaultInfo{Name: "alice"})
+}
+
+// admit runs the admission chain of plugins on a, the way the API server does, mutating plugins first.
+func admit(plugins []admission.Interface, a admission.Attributes) error {
+	chain := admission.NewChainHandler(plugins...)
+	if err := chain.Admit(context.Background(), a, nil); err != nil {
+		return err
+	}
+	return chain.Validate(context.Background(), a, nil)
+}
+
+func TestAdmission(t *testing.T) {
+	var calls []string
+	plugins := []admission.Interface{
+		NewValidatingAdmission(configMaps, func(_ context.Context, a admission.Attributes) error {
+			cm := a.GetObject().(*corev1.ConfigMap)
+			calls = append(calls, "validate "+cm.Name+" "+cm.Labels["mutated"])
+			switch cm.Name {
+			case "plain":
+				return errors.New("plain errors are forbidden")
+			case "status":
+				return apierrors.NewConflict(configMaps, cm.Name, errors.New("status errors are kept"))
+			}
+			return nil
+		}),
+		NewMutatingAdmission(configMaps, func(_ context.Context, a admission.Attributes) error {
+			cm := a.GetObject().(*corev1.ConfigMap)
+			calls = append(calls, "mutate "+cm.Name)
+			cm.Labels = map[string]string{"mutated": "true"}
+			return nil
+		}, admission.Create),
+	}
+	newConfigMap := func(name string) *corev1.ConfigMap {
+		return &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"}}
+	}
+
+	// mutation runs before validation, although the validating plugin comes first
+	cm := newConfigMap("one")
+	assert.NoError(t, admit(plugins, attributes(configMaps, admission.Create, cm)))
+	assert.Equal(t, []string{"mutate one", "validate one true"}, calls)
+	assert.Equal(t, "true", cm.Labels["mutated"])
+
+	// the mutating plugin only handles creates
+	calls = nil
+	assert.NoError(t, admit(plugins, attributes(configMaps, admission.Update, newConfigMap("one"))))
+	assert.Equal(t, []string{"validate one "}, calls)
+
+	// other resources aren't admitted by either plugin
+	calls = nil
+	assert.NoError(t, admit(plugins, attributes(schema.GroupResource{Resource: "secrets"}, admission.Create, newConfigMap("plain"))))
+	assert.Empty(t, calls)
+
+	// errors that aren't API status errors are forbidden, status errors are returned as they are
+	err := admit(plugins, attributes(configMaps, admission.Create, newConfigMap("plain")))
+	assert.True(t, apierrors.IsForbidden(err), "expected forbidden, got %v", err)
+	assert.Contains(t, err.Error(), "plain errors are forbidden")
+	err = admit(plugins, attributes(configMaps, admission.Create, newConfigMap("status")))
+	assert.True(t, apierrors.IsConflict(err), "expected conflict, got %v", err)
+}
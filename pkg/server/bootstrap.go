@@ -0,0 +1,56 @@
+package server
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/acorn-io/mink/pkg/client"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apiserver/pkg/server"
+	"k8s.io/client-go/util/retry"
+	kclient "sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// BootstrapFunc seeds objects, such as default namespaces, built-in roles or settings, once the stores are installed.
+// It runs on every start of every server, so it must be idempotent.
+type BootstrapFunc func(ctx context.Context, c kclient.WithWatch) error
+
+// CreateIfMissing returns a BootstrapFunc that creates objs unless they already exist. Existing objects are left
+// as they are, so changes made to them by users are kept.
+func CreateIfMissing(objs ...kclient.Object) BootstrapFunc {
+	return func(ctx context.Context, c kclient.WithWatch) error {
+		for _, obj := range objs {
+			if err := c.Create(ctx, obj.DeepCopyObject().(kclient.Object)); err != nil && !apierrors.IsAlreadyExists(err) {
+				return fmt.Errorf("failed to create %s %s: %w", obj.GetObjectKind().GroupVersionKind().Kind, kclient.ObjectKeyFromObject(obj), err)
+			}
+		}
+		return nil
+	}
+}
+
+func isTransient(err error) bool {
+	return apierrors.IsConflict(err) || apierrors.IsServerTimeout(err) || apierrors.IsTimeout(err) ||
+		apierrors.IsTooManyRequests(err) || apierrors.IsServiceUnavailable(err)
+}
+
+// bootstrap runs the Bootstrap funcs of the config with a loopback client. Transient errors are retried, the server
+// doesn't report ready while they run, and the post start hook exits the process if one of them fails.
+func (c *Config) bootstrap(context server.PostStartHookContext) error {
+	if len(c.Bootstrap) == 0 {
+		return nil
+	}
+	cli, err := client.New(context.LoopbackClientConfig, c.Scheme)
+	if err != nil {
+		return err
+	}
+	for i, bootstrap := range c.Bootstrap {
+		err := retry.OnError(retry.DefaultBackoff, isTransient, func() error {
+			return bootstrap(context, cli)
+		})
+		if err != nil {
+			return fmt.Errorf("bootstrap %d failed: %w", i, err)
+		}
+	}
+	c.Logger.Debugf("Ran %d bootstrap funcs", len(c.Bootstrap))
+	return nil
+}
@@ -0,0 +1,52 @@
+package server
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	kclient "sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestCreateIfMissing(t *testing.T) {
+	ctx := context.Background()
+	c := fake.NewClientBuilder().WithScheme(scheme.Scheme).Build()
+	defaults := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "defaults", Namespace: "default"},
+		Data:       map[string]string{"mode": "default"},
+	}
+	bootstrap := CreateIfMissing(&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "default"}}, defaults)
+
+	if err := bootstrap(ctx, c); err != nil {
+		t.Fatal(err)
+	}
+	// the objects given aren't changed by the client
+	assert.Empty(t, defaults.ResourceVersion)
+
+	cm := &corev1.ConfigMap{}
+	if err := c.Get(ctx, kclient.ObjectKeyFromObject(defaults), cm); err != nil {
+		t.Fatal(err)
+	}
+	cm.Data["mode"] = "custom"
+	if err := c.Update(ctx, cm); err != nil {
+		t.Fatal(err)
+	}
+
+	// running again on the next start succeeds and keeps the changes of users
+	if err := bootstrap(ctx, c); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Get(ctx, kclient.ObjectKeyFromObject(defaults), cm); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "custom", cm.Data["mode"])
+	namespaces := &corev1.NamespaceList{}
+	if err := c.List(ctx, namespaces); err != nil {
+		t.Fatal(err)
+	}
+	assert.Len(t, namespaces.Items, 1)
+}
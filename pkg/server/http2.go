@@ -0,0 +1,50 @@
+package server
+
+import (
+	"net/http"
+	"time"
+
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+)
+
+// HTTP2Options tune the HTTP/2 servers, zero values keep the defaults. The HTTPS listener is served by the generic API
+// server, which only takes MaxConcurrentStreams and sizes its other settings from it. The plain HTTP listener only
+// serves HTTP/1 unless H2C is set, the other options then apply to it in full.
+type HTTP2Options struct {
+	// H2C makes the plain HTTP listener accept HTTP/2 without TLS, on connections that start with the HTTP/2 preface
+	// or upgrade to it. Only enable it when the listener is reached through a trusted network or a proxy that speaks
+	// h2c, such as a service mesh sidecar.
+	H2C bool
+	// MaxConcurrentStreams is the number of requests a client can have in flight on one connection, watches included.
+	// Clients opening more streams fail with "INTERNAL_ERROR; stream ID". The default is 100 on the HTTPS listener
+	// and, with H2C, 250 on the HTTP listener.
+	MaxConcurrentStreams uint32
+	// MaxReadFrameSize is the largest frame the server reads, between 16KiB and 16MiB.
+	MaxReadFrameSize uint32
+	// MaxUploadBufferPerStream and MaxUploadBufferPerConnection are the flow control windows of the server, the
+	// amount of request bodies buffered per stream and per connection before clients must wait.
+	MaxUploadBufferPerStream     int32
+	MaxUploadBufferPerConnection int32
+	// IdleTimeout closes connections without streams after this long.
+	IdleTimeout time.Duration
+}
+
+func (o *HTTP2Options) server() *http2.Server {
+	return &http2.Server{
+		MaxConcurrentStreams:         o.MaxConcurrentStreams,
+		MaxReadFrameSize:             o.MaxReadFrameSize,
+		MaxUploadBufferPerStream:     o.MaxUploadBufferPerStream,
+		MaxUploadBufferPerConnection: o.MaxUploadBufferPerConnection,
+		IdleTimeout:                  o.IdleTimeout,
+	}
+}
+
+// h2cHandler serves HTTP/2 without TLS on the plain HTTP listener if H2C is set. Other requests, and all of them if
+// it isn't, are served as HTTP/1.
+func (o *HTTP2Options) h2cHandler(handler http.Handler) http.Handler {
+	if o == nil || !o.H2C {
+		return handler
+	}
+	return h2c.NewHandler(handler, o.server())
+}
@@ -0,0 +1,58 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/http2"
+)
+
+func TestH2CHandler(t *testing.T) {
+	handler := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.Header().Set("X-Proto", req.Proto)
+	})
+	// h2c clients send the HTTP/2 preface on a plain connection
+	h2cClient := &http.Client{Transport: &http2.Transport{
+		AllowHTTP: true,
+		DialTLSContext: func(ctx context.Context, network, addr string, _ *tls.Config) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, network, addr)
+		},
+	}}
+
+	for _, test := range []struct {
+		name string
+		opts *HTTP2Options
+		h2c  bool
+	}{
+		{name: "no options"},
+		{name: "h2c not enabled", opts: &HTTP2Options{MaxConcurrentStreams: 10}},
+		{name: "h2c enabled", opts: &HTTP2Options{H2C: true, MaxConcurrentStreams: 10}, h2c: true},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			server := httptest.NewServer(test.opts.h2cHandler(handler))
+			defer server.Close()
+
+			// HTTP/1 is always served
+			resp, err := http.Get(server.URL)
+			if assert.NoError(t, err) {
+				resp.Body.Close()
+				assert.Equal(t, "HTTP/1.1", resp.Header.Get("X-Proto"))
+			}
+
+			resp, err = h2cClient.Get(server.URL)
+			if !test.h2c {
+				assert.Error(t, err)
+				return
+			}
+			if assert.NoError(t, err) {
+				resp.Body.Close()
+				assert.Equal(t, "HTTP/2.0", resp.Header.Get("X-Proto"))
+			}
+		})
+	}
+}
@@ -5,16 +5,26 @@ import (
 	"fmt"
 	"net"
 	"net/http"
-
-	"github.com/sirupsen/logrus"
+	"os"
+	"time"
+
+	"github.com/acorn-io/mink/pkg/apigroup"
+	"github.com/acorn-io/mink/pkg/authz"
+	"github.com/acorn-io/mink/pkg/logging"
+	"github.com/acorn-io/mink/pkg/strategy"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/serializer"
 	"k8s.io/apimachinery/pkg/util/errors"
 	"k8s.io/apimachinery/pkg/util/sets"
+	utilwaitgroup "k8s.io/apimachinery/pkg/util/waitgroup"
+	"k8s.io/apimachinery/pkg/version"
+	"k8s.io/apiserver/pkg/admission"
 	"k8s.io/apiserver/pkg/authentication/authenticator"
 	"k8s.io/apiserver/pkg/authentication/request/anonymous"
 	"k8s.io/apiserver/pkg/authentication/request/union"
 	"k8s.io/apiserver/pkg/authorization/authorizer"
+	authorizerunion "k8s.io/apiserver/pkg/authorization/union"
 	"k8s.io/apiserver/pkg/endpoints/openapi"
 	"k8s.io/apiserver/pkg/server"
 	"k8s.io/apiserver/pkg/server/filters"
@@ -22,6 +32,7 @@ import (
 	"k8s.io/apiserver/pkg/server/options"
 	"k8s.io/client-go/kubernetes/scheme"
 	"k8s.io/client-go/rest"
+	"k8s.io/klog/v2"
 	openapicommon "k8s.io/kube-openapi/pkg/common"
 	netutils "k8s.io/utils/net"
 )
@@ -37,29 +48,81 @@ type Server struct {
 }
 
 type Config struct {
-	Name                  string
-	Version               string
-	Authenticator         authenticator.Request
-	Authorization         authorizer.Authorizer
-	HTTPListenPort        int
-	Listener              net.Listener
-	HTTPSListenPort       int
-	LongRunningVerbs      []string
-	LongRunningResources  []string
-	OpenAPIConfig         openapicommon.GetOpenAPIDefinitions
-	Scheme                *runtime.Scheme
-	CodecFactory          *serializer.CodecFactory
-	APIGroups             []*server.APIGroupInfo
-	Middleware            []func(http.Handler) http.Handler
-	PostStartFunc         server.PostStartHookFunc
+	Name                 string
+	Version              string
+	Authenticator        authenticator.Request
+	Authorization        authorizer.Authorizer
+	HTTPListenPort       int
+	Listener             net.Listener
+	HTTPSListenPort      int
+	LongRunningVerbs     []string
+	LongRunningResources []string
+	OpenAPIConfig        openapicommon.GetOpenAPIDefinitions
+	Scheme               *runtime.Scheme
+	CodecFactory         *serializer.CodecFactory
+	APIGroups            []*server.APIGroupInfo
+	Middleware           []func(http.Handler) http.Handler
+	// AuthenticatedMiddleware run inside the API server handler chain after the request has been authenticated and
+	// authorized, so the request user and RequestInfo are available from the request context.
+	AuthenticatedMiddleware []func(http.Handler) http.Handler
+	// PathHandlers are served at the given paths behind the API server's authentication and authorization, the
+	// authorizer must allow the path as a non-resource URL.
+	PathHandlers  map[string]http.Handler
+	PostStartFunc server.PostStartHookFunc
+	// Bootstrap run in order with an in-process client after the stores are installed, to seed objects the server
+	// needs. They run before PostStartFunc, the server isn't ready until they succeed and exits if one fails.
+	Bootstrap             []BootstrapFunc
 	SupportAPIAggregation bool
 	DefaultOptions        *options.RecommendedOptions
 	AuditConfig           *options.AuditOptions
 	IgnoreStartFailure    bool
 	ReadinessCheckers     []healthz.HealthChecker
+	// UseInClusterDelegation authenticates requests with TokenReviews and authorizes them with SubjectAccessReviews
+	// against the API server of the cluster the server runs in, caching the answers, as aggregated API servers do.
+	// Authenticator and Authorization, if set, are consulted before the cluster, Authorization must return
+	// DecisionNoOpinion for requests it leaves to the cluster.
+	UseInClusterDelegation bool
+	// RequestTimeout is the deadline of requests that are not long running, it is passed on to storage through the
+	// request context. The default is one minute.
+	RequestTimeout time.Duration
+	// VersionInfo, if set, is served on /version, typically with the git commit, build date and platform of the
+	// embedding binary. GitVersion defaults to Version and the Go version, compiler and platform to those of the
+	// running binary. Major and Minor are always the Kubernetes version the server is compatible with.
+	VersionInfo *version.Info
+	// ShutdownGracePeriod is how long the server drains requests when the context of Run is canceled. It stops
+	// accepting connections, ends watches so clients resume them from their last resource version elsewhere, and
+	// waits for the requests in flight for up to this long before closing the remaining connections. The default is
+	// ten seconds, a negative value closes connections immediately.
+	ShutdownGracePeriod time.Duration
+	// WatchTerminators, such as the db.Factory of the storage of the server, end their watches with a final bookmark
+	// when the context of Run is canceled, before the server stops serving, so that clients resume them from the
+	// resource version they got to. The server waits for the watches to end within the shutdown grace period. Watches
+	// of other storage are ended by the server without a final bookmark.
+	WatchTerminators []strategy.WatchTerminator
+	// Admission plugins admit, mutate or reject the creates, updates, deletes and connects of every resource, in order,
+	// before the strategy of the resource runs. See NewMutatingAdmission and NewValidatingAdmission for plugins of a
+	// single resource.
+	Admission []admission.Interface
+	// GroupPriorities sets the priority of groups in aggregated discovery, keyed by group name. Clients list groups
+	// with a higher priority first, groups that aren't listed get apigroup.DefaultGroupPriority.
+	GroupPriorities map[string]int
+	// HTTP2, if set, tunes the HTTP/2 servers of both listeners and can enable HTTP/2 without TLS on the plain HTTP
+	// listener, see HTTP2Options.
+	HTTP2 *HTTP2Options
+	// Logger, if set, receives the logs of the server. The default is logging.Default.
+	Logger logging.Logger
+	// RedirectKlog sends what the generic API server and other Kubernetes libraries log with klog to Logger. klog is
+	// global, so this changes the logger of klog for the whole process, and the last server created with it wins.
+	RedirectKlog bool
+	// AuthorizationDecisions, if set, publishes the decisions of the authorizer of the server, including those of the
+	// cluster with UseInClusterDelegation, from the time the server starts until it stops.
+	AuthorizationDecisions *authz.DecisionPublisher
 }
 
 func (c *Config) complete() {
+	if c.Logger == nil {
+		c.Logger = logging.Default()
+	}
 	if c.HTTPListenPort == 0 {
 		c.HTTPListenPort = 8080
 	}
@@ -79,6 +142,9 @@ func (c *Config) complete() {
 	if c.Name == "" {
 		c.Name = "mink"
 	}
+	if c.ShutdownGracePeriod == 0 {
+		c.ShutdownGracePeriod = 10 * time.Second
+	}
 	if c.DefaultOptions == nil {
 		c.DefaultOptions = DefaultOpts()
 		if c.AuditConfig != nil {
@@ -100,12 +166,26 @@ func DefaultOpts() *options.RecommendedOptions {
 
 func New(config *Config) (*Server, error) {
 	config.complete()
+	if config.RedirectKlog {
+		klog.SetLogger(logging.ToLogr(config.Logger))
+	}
 
 	opts := config.DefaultOptions
 	opts.SecureServing.Listener = config.Listener
 	opts.SecureServing.BindPort = config.HTTPSListenPort
+	if config.HTTP2 != nil && config.HTTP2.MaxConcurrentStreams > 0 {
+		opts.SecureServing.HTTP2MaxStreamsPerConnection = int(config.HTTP2.MaxConcurrentStreams)
+	}
 	opts.Authentication.SkipInClusterLookup = !config.SupportAPIAggregation
 	opts.Authentication.RemoteKubeConfigFileOptional = !config.SupportAPIAggregation
+	if config.UseInClusterDelegation {
+		opts.Authentication.SkipInClusterLookup = false
+		opts.Authentication.RemoteKubeConfigFileOptional = false
+		if opts.Authorization == nil {
+			opts.Authorization = options.NewDelegatingAuthorizationOptions()
+		}
+		opts.Authorization.RemoteKubeConfigFileOptional = false
+	}
 
 	if err := opts.SecureServing.MaybeDefaultWithSelfSignedCerts("localhost", nil, []net.IP{netutils.ParseIPSloppy("127.0.0.1")}); err != nil {
 		return nil, fmt.Errorf("error creating self-signed certificates: %v", err)
@@ -135,19 +215,66 @@ func New(config *Config) (*Server, error) {
 	if err := options.NewServerRunOptions().ApplyTo(&serverConfig.Config); err != nil {
 		return nil, err
 	}
+	if config.RequestTimeout > 0 {
+		serverConfig.RequestTimeout = config.RequestTimeout
+	}
+	if config.ShutdownGracePeriod > 0 {
+		// watches are told about the shutdown, on both listeners, and end cleanly
+		serverConfig.ShutdownWatchTerminationGracePeriod = config.ShutdownGracePeriod
+	}
+	if len(config.Admission) > 0 {
+		serverConfig.AdmissionControl = admission.NewChainHandler(config.Admission...)
+	}
+	if config.VersionInfo != nil {
+		serverConfig.EffectiveVersion = newVersionInfo(serverConfig.EffectiveVersion, *config.VersionInfo, config.Version)
+	}
 
 	if config.Authenticator != nil {
-		serverConfig.Authentication.Authenticator = union.New(config.Authenticator, anonymous.NewAuthenticator(nil))
+		if config.UseInClusterDelegation {
+			serverConfig.Authentication.Authenticator = union.New(config.Authenticator, serverConfig.Authentication.Authenticator)
+		} else {
+			serverConfig.Authentication.Authenticator = union.New(config.Authenticator, anonymous.NewAuthenticator(nil))
+		}
 	}
 	if config.Authorization != nil {
-		serverConfig.Authorization.Authorizer = config.Authorization
+		if config.UseInClusterDelegation {
+			serverConfig.Authorization.Authorizer = authorizerunion.New(config.Authorization, serverConfig.Authorization.Authorizer)
+		} else {
+			serverConfig.Authorization.Authorizer = config.Authorization
+		}
+	}
+	if config.AuthorizationDecisions != nil && serverConfig.Authorization.Authorizer != nil {
+		serverConfig.Authorization.Authorizer = config.AuthorizationDecisions.Authorizer(serverConfig.Authorization.Authorizer)
+		serverConfig.AddPostStartHookOrDie("authorization-decisions", func(context server.PostStartHookContext) error {
+			go config.AuthorizationDecisions.Start(context)
+			return nil
+		})
+	}
+
+	scoper, scoped := config.Authorization.(authz.ListScoper)
+	if len(config.AuthenticatedMiddleware) > 0 || scoped {
+		serverConfig.BuildHandlerChainFunc = func(apiHandler http.Handler, c *server.Config) http.Handler {
+			for i := len(config.AuthenticatedMiddleware) - 1; i >= 0; i-- {
+				apiHandler = config.AuthenticatedMiddleware[i](apiHandler)
+			}
+			handler := server.DefaultBuildHandlerChain(apiHandler, c)
+			if scoped {
+				// the list scope is set while the request is authorized, which happens inside the chain
+				handler = scoper.ListScopeMiddleware(handler)
+			}
+			return handler
+		}
 	}
 
-	if config.PostStartFunc != nil {
+	if len(config.Bootstrap) > 0 || config.PostStartFunc != nil {
 		serverConfig.AddPostStartHookOrDie(config.Name, func(context server.PostStartHookContext) error {
-			err := config.PostStartFunc(context)
+			err := config.bootstrap(context)
+			if err == nil && config.PostStartFunc != nil {
+				err = config.PostStartFunc(context)
+			}
 			if err != nil {
-				logrus.Fatalf("failed to run post startup hook: %v", err)
+				config.Logger.Errorf("failed to run post startup hook: %v", err)
+				os.Exit(1)
 			}
 			return err
 		})
@@ -178,6 +305,10 @@ func New(config *Config) (*Server, error) {
 
 	result.GenericAPIServer = server
 
+	for path, handler := range config.PathHandlers {
+		server.Handler.NonGoRestfulMux.Handle(path, handler)
+	}
+
 	for _, apiGroup := range config.APIGroups {
 		legacy := false
 		for _, gv := range apiGroup.PrioritizedVersions {
@@ -190,8 +321,11 @@ func New(config *Config) (*Server, error) {
 			if err := server.InstallLegacyAPIGroup("/api", apiGroup); err != nil {
 				return nil, err
 			}
-		} else if err := server.InstallAPIGroups(apiGroup); err != nil {
-			return nil, err
+		} else {
+			if err := server.InstallAPIGroups(apiGroup); err != nil {
+				return nil, err
+			}
+			setDiscoveryPriorities(server, apiGroup, config.GroupPriorities)
 		}
 	}
 
@@ -202,12 +336,13 @@ func (s *Server) Handler(ctx context.Context) http.Handler {
 	readyServer := s.GenericAPIServer.PrepareRun()
 
 	go func() {
-		err := readyServer.Run(ctx.Done())
+		err := readyServer.Run(s.terminateWatches(ctx))
 		if err != nil {
 			if s.config.IgnoreStartFailure {
-				logrus.Errorf("Failed to run api server: %v", err)
+				s.config.Logger.Errorf("Failed to run api server: %v", err)
 			} else {
-				logrus.Fatalf("Failed to run api server: %v", err)
+				s.config.Logger.Errorf("Failed to run api server: %v", err)
+				os.Exit(1)
 			}
 		}
 	}()
@@ -225,6 +360,7 @@ func (s *Server) Handler(ctx context.Context) http.Handler {
 func (s *Server) Run(ctx context.Context) error {
 	address := fmt.Sprintf("0.0.0.0:%d", s.config.HTTPListenPort)
 	handler := s.Handler(ctx)
+	handler = s.config.HTTP2.h2cHandler(handler)
 
 	httpServer := &http.Server{
 		Handler: handler,
@@ -232,24 +368,94 @@ func (s *Server) Run(ctx context.Context) error {
 	}
 
 	go func() {
-		logrus.Infof("Listening on %s", address)
-		if err := httpServer.ListenAndServe(); err != nil {
+		s.config.Logger.Infof("Listening on %s", address)
+		if err := httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
 			if s.config.IgnoreStartFailure {
-				logrus.Errorf("Failed to run http api server: %v", err)
+				s.config.Logger.Errorf("Failed to run http api server: %v", err)
 			} else {
-				logrus.Fatalf("Failed to run http api server: %v", err)
+				s.config.Logger.Errorf("Failed to run http api server: %v", err)
+				os.Exit(1)
 			}
 		}
 	}()
 
 	go func() {
 		<-ctx.Done()
-		httpServer.Close()
+		s.shutdown(httpServer)
 	}()
 
 	return nil
 }
 
+// shutdown stops httpServer gracefully within the shutdown grace period, then closes the connections left.
+func (s *Server) shutdown(httpServer *http.Server) {
+	if s.config.ShutdownGracePeriod < 0 {
+		_ = httpServer.Close()
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), s.config.ShutdownGracePeriod)
+	defer cancel()
+	if err := httpServer.Shutdown(ctx); err != nil {
+		s.config.Logger.Warnf("Closing http connections still active after %s: %v", s.config.ShutdownGracePeriod, err)
+		_ = httpServer.Close()
+	}
+}
+
+// terminateWatches returns a channel that is closed once the watches of the watch terminators have ended after ctx is
+// canceled, or the shutdown grace period has passed. New watches are rejected with a Retry-After meanwhile.
+func (s *Server) terminateWatches(ctx context.Context) <-chan struct{} {
+	stop := make(chan struct{})
+	go func() {
+		defer close(stop)
+		<-ctx.Done()
+
+		watches := s.GenericAPIServer.WatchRequestWaitGroup
+		if len(s.config.WatchTerminators) == 0 || s.config.ShutdownGracePeriod <= 0 || watches == nil {
+			return
+		}
+		for _, terminator := range s.config.WatchTerminators {
+			terminator.TerminateWatches()
+		}
+		before, after, err := watches.Wait(func(int) (utilwaitgroup.RateLimiter, context.Context, context.CancelFunc) {
+			ctx, cancel := context.WithTimeout(context.Background(), s.config.ShutdownGracePeriod)
+			return unlimited{}, ctx, cancel
+		})
+		if err != nil {
+			s.config.Logger.Warnf("%d of %d watches still active after %s", after, before, s.config.ShutdownGracePeriod)
+		}
+	}()
+	return stop
+}
+
+// unlimited lets the watches end as fast as they can, the clients were told where to resume them.
+type unlimited struct{}
+
+func (unlimited) Wait(context.Context) error {
+	return nil
+}
+
+// setDiscoveryPriorities orders the versions of a group in aggregated discovery as they are ordered in the
+// APIGroupInfo, by default the API server orders them by name only, and gives the group its priority in
+// groupPriorities.
+func setDiscoveryPriorities(server *server.GenericAPIServer, apiGroup *server.APIGroupInfo, groupPriorities map[string]int) {
+	if server.AggregatedDiscoveryGroupManager == nil {
+		return
+	}
+	for i, gv := range apiGroup.PrioritizedVersions {
+		groupPriority, ok := groupPriorities[gv.Group]
+		if !ok {
+			groupPriority = apigroup.DefaultGroupPriority
+		}
+		if len(apiGroup.VersionedResourcesStorageMap[gv.Version]) == 0 {
+			continue
+		}
+		server.AggregatedDiscoveryGroupManager.SetGroupVersionPriority(metav1.GroupVersion{
+			Group:   gv.Group,
+			Version: gv.Version,
+		}, groupPriority, len(apiGroup.PrioritizedVersions)-i)
+	}
+}
+
 func generateDummyKubeconfig() *rest.Config {
 	return &rest.Config{}
 }
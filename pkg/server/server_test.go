@@ -0,0 +1,218 @@
+package server
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/acorn-io/mink/pkg/apigroup"
+	"github.com/acorn-io/mink/pkg/strategy"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	utilwaitgroup "k8s.io/apimachinery/pkg/util/waitgroup"
+	discoveryendpoint "k8s.io/apiserver/pkg/endpoints/discovery/aggregated"
+	"k8s.io/apiserver/pkg/registry/rest"
+	"k8s.io/apiserver/pkg/server"
+)
+
+// startHTTPServer serves handler on a local port and returns the server and its URL.
+func startHTTPServer(t *testing.T, handler http.Handler) (*http.Server, string) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	httpServer := &http.Server{Handler: handler}
+	go func() {
+		_ = httpServer.Serve(listener)
+	}()
+	t.Cleanup(func() {
+		_ = httpServer.Close()
+	})
+	return httpServer, "http://" + listener.Addr().String()
+}
+
+func TestShutdownGracePeriodDefault(t *testing.T) {
+	config := &Config{}
+	config.complete()
+	assert.Equal(t, 10*time.Second, config.ShutdownGracePeriod)
+
+	config = &Config{ShutdownGracePeriod: -1}
+	config.complete()
+	assert.Equal(t, time.Duration(-1), config.ShutdownGracePeriod)
+}
+
+func TestShutdown(t *testing.T) {
+	for _, test := range []struct {
+		name        string
+		gracePeriod time.Duration
+		// finish is how long the request in flight takes to finish once shutdown started
+		finish    time.Duration
+		completed bool
+	}{
+		{name: "drains requests in flight", gracePeriod: 5 * time.Second, finish: 100 * time.Millisecond, completed: true},
+		{name: "closes connections after the grace period", gracePeriod: 100 * time.Millisecond, finish: time.Hour},
+		{name: "closes connections immediately", gracePeriod: -1, finish: time.Hour},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			started := make(chan struct{})
+			release := make(chan struct{})
+			defer close(release)
+			httpServer, url := startHTTPServer(t, http.HandlerFunc(func(rw http.ResponseWriter, _ *http.Request) {
+				close(started)
+				select {
+				case <-release:
+				case <-time.After(test.finish):
+				}
+				rw.WriteHeader(http.StatusOK)
+			}))
+
+			result := make(chan error, 1)
+			go func() {
+				resp, err := http.Get(url)
+				if err == nil {
+					resp.Body.Close()
+				}
+				result <- err
+			}()
+			<-started
+
+			s := &Server{config: &Config{ShutdownGracePeriod: test.gracePeriod}}
+			s.config.complete()
+			start := time.Now()
+			s.shutdown(httpServer)
+			elapsed := time.Since(start)
+
+			select {
+			case err := <-result:
+				if test.completed {
+					assert.NoError(t, err)
+					assert.GreaterOrEqual(t, elapsed, test.finish)
+				} else {
+					assert.Error(t, err)
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatal("timed out waiting for the request")
+			}
+			if test.gracePeriod > 0 {
+				assert.Less(t, elapsed, test.gracePeriod+time.Second)
+			} else {
+				assert.Less(t, elapsed, time.Second)
+			}
+
+			// the listener is closed either way
+			_, err := http.Get(url)
+			assert.Error(t, err)
+		})
+	}
+}
+
+type terminatorFunc func()
+
+func (f terminatorFunc) TerminateWatches() {
+	f()
+}
+
+func TestTerminateWatches(t *testing.T) {
+	for _, test := range []struct {
+		name        string
+		gracePeriod time.Duration
+		// ends is whether the watch ends when it is told to terminate
+		ends bool
+	}{
+		{name: "waits for watches to end", gracePeriod: 5 * time.Second, ends: true},
+		{name: "stops waiting after the grace period", gracePeriod: 100 * time.Millisecond},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			watches := &utilwaitgroup.RateLimitedSafeWaitGroup{}
+			if err := watches.Add(1); err != nil {
+				t.Fatal(err)
+			}
+			defer func() {
+				if !test.ends {
+					watches.Done()
+				}
+			}()
+
+			var terminated bool
+			s := &Server{
+				config: &Config{
+					ShutdownGracePeriod: test.gracePeriod,
+					WatchTerminators: []strategy.WatchTerminator{terminatorFunc(func() {
+						terminated = true
+						if test.ends {
+							go func() {
+								time.Sleep(100 * time.Millisecond)
+								watches.Done()
+							}()
+						}
+					})},
+				},
+				GenericAPIServer: &server.GenericAPIServer{WatchRequestWaitGroup: watches},
+			}
+			s.config.complete()
+
+			ctx, cancel := context.WithCancel(context.Background())
+			stop := s.terminateWatches(ctx)
+			select {
+			case <-stop:
+				t.Fatal("stopped before the context was canceled")
+			case <-time.After(50 * time.Millisecond):
+			}
+
+			start := time.Now()
+			cancel()
+			select {
+			case <-stop:
+			case <-time.After(5 * time.Second):
+				t.Fatal("timed out waiting for the watches to be terminated")
+			}
+			assert.True(t, terminated)
+			assert.GreaterOrEqual(t, time.Since(start), 100*time.Millisecond)
+		})
+	}
+}
+
+type priority struct {
+	group, version int
+}
+
+// priorityRecorder records the priorities set on an aggregated discovery manager.
+type priorityRecorder struct {
+	discoveryendpoint.ResourceManager
+	priorities map[metav1.GroupVersion]priority
+}
+
+func (p *priorityRecorder) SetGroupVersionPriority(gv metav1.GroupVersion, groupPriority, versionPriority int) {
+	p.priorities[gv] = priority{group: groupPriority, version: versionPriority}
+}
+
+func TestSetDiscoveryPriorities(t *testing.T) {
+	group := func(name string, versions ...string) *server.APIGroupInfo {
+		info := &server.APIGroupInfo{VersionedResourcesStorageMap: map[string]map[string]rest.Storage{}}
+		for _, version := range versions {
+			info.PrioritizedVersions = append(info.PrioritizedVersions, schema.GroupVersion{Group: name, Version: version})
+			info.VersionedResourcesStorageMap[version] = map[string]rest.Storage{"things": nil}
+		}
+		return info
+	}
+
+	// two servers in one process prioritize the same group independently
+	first := &priorityRecorder{priorities: map[metav1.GroupVersion]priority{}}
+	setDiscoveryPriorities(&server.GenericAPIServer{AggregatedDiscoveryGroupManager: first},
+		group("example.com", "v1", "v1beta1"), map[string]int{"example.com": 2000})
+	second := &priorityRecorder{priorities: map[metav1.GroupVersion]priority{}}
+	setDiscoveryPriorities(&server.GenericAPIServer{AggregatedDiscoveryGroupManager: second},
+		group("example.com", "v1beta1", "v1"), nil)
+
+	assert.Equal(t, map[metav1.GroupVersion]priority{
+		{Group: "example.com", Version: "v1"}:      {group: 2000, version: 2},
+		{Group: "example.com", Version: "v1beta1"}: {group: 2000, version: 1},
+	}, first.priorities)
+	assert.Equal(t, map[metav1.GroupVersion]priority{
+		{Group: "example.com", Version: "v1beta1"}: {group: apigroup.DefaultGroupPriority, version: 2},
+		{Group: "example.com", Version: "v1"}:      {group: apigroup.DefaultGroupPriority, version: 1},
+	}, second.priorities)
+}
@@ -0,0 +1,40 @@
+package server
+
+import (
+	"fmt"
+	"runtime"
+
+	apimachineryversion "k8s.io/apimachinery/pkg/util/version"
+	"k8s.io/apimachinery/pkg/version"
+	utilversion "k8s.io/apiserver/pkg/util/version"
+)
+
+// versionInfo serves the version information of the embedder on /version. The API server serves the info of the
+// binary version, the Kubernetes version the server is compatible with, so the info is attached to it.
+type versionInfo struct {
+	utilversion.EffectiveVersion
+	info version.Info
+}
+
+func newVersionInfo(effective utilversion.EffectiveVersion, info version.Info, gitVersion string) versionInfo {
+	if info.GitVersion == "" {
+		info.GitVersion = gitVersion
+	}
+	if info.GoVersion == "" {
+		info.GoVersion = runtime.Version()
+	}
+	if info.Compiler == "" {
+		info.Compiler = runtime.Compiler
+	}
+	if info.Platform == "" {
+		info.Platform = fmt.Sprintf("%s/%s", runtime.GOOS, runtime.GOARCH)
+	}
+	return versionInfo{
+		EffectiveVersion: effective,
+		info:             info,
+	}
+}
+
+func (v versionInfo) BinaryVersion() *apimachineryversion.Version {
+	return v.EffectiveVersion.BinaryVersion().WithInfo(v.info)
+}
@@ -0,0 +1,87 @@
+package settings
+
+import (
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func (in *Settings) DeepCopyInto(out *Settings) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+}
+
+func (in *Settings) DeepCopy() *Settings {
+	if in == nil {
+		return nil
+	}
+	out := new(Settings)
+	in.DeepCopyInto(out)
+	return out
+}
+
+func (in *Settings) DeepCopyObject() runtime.Object {
+	return in.DeepCopy()
+}
+
+func (in *SettingsSpec) DeepCopyInto(out *SettingsSpec) {
+	*out = *in
+	if in.GC != nil {
+		out.GC = new(GCSettings)
+		in.GC.DeepCopyInto(out.GC)
+	}
+	if in.RateLimits != nil {
+		out.RateLimits = make(map[string]RateLimit, len(in.RateLimits))
+		for k, v := range in.RateLimits {
+			out.RateLimits[k] = v
+		}
+	}
+	out.BookmarkIntervalSeconds = copyInt64(in.BookmarkIntervalSeconds)
+	if in.Tables != nil {
+		out.Tables = make(map[string]TableSettings, len(in.Tables))
+		for k, v := range in.Tables {
+			v.SlowQueryThresholdMillis = copyInt64(v.SlowQueryThresholdMillis)
+			out.Tables[k] = v
+		}
+	}
+}
+
+func (in *GCSettings) DeepCopyInto(out *GCSettings) {
+	*out = *in
+	out.CompactRetain = copyInt64(in.CompactRetain)
+	out.DeleteRetain = copyInt64(in.DeleteRetain)
+	out.IntervalSeconds = copyInt64(in.IntervalSeconds)
+}
+
+func copyInt64(in *int64) *int64 {
+	if in == nil {
+		return nil
+	}
+	out := *in
+	return &out
+}
+
+func (in *SettingsList) DeepCopyInto(out *SettingsList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		out.Items = make([]Settings, len(in.Items))
+		for i := range in.Items {
+			in.Items[i].DeepCopyInto(&out.Items[i])
+		}
+	}
+}
+
+func (in *SettingsList) DeepCopy() *SettingsList {
+	if in == nil {
+		return nil
+	}
+	out := new(SettingsList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+func (in *SettingsList) DeepCopyObject() runtime.Object {
+	return in.DeepCopy()
+}
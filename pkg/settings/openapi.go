@@ -0,0 +1,101 @@
+package settings
+
+import (
+	"k8s.io/kube-openapi/pkg/common"
+	"k8s.io/kube-openapi/pkg/validation/spec"
+)
+
+const pkgPath = "github.com/acorn-io/mink/pkg/settings."
+
+// GetOpenAPIDefinitions returns the OpenAPI definitions of the Settings types, merge them into the definitions of the
+// server's OpenAPIConfig when serving settings.
+func GetOpenAPIDefinitions(ref common.ReferenceCallback) map[string]common.OpenAPIDefinition {
+	return map[string]common.OpenAPIDefinition{
+		pkgPath + "Settings": {
+			Schema: spec.Schema{
+				SchemaProps: spec.SchemaProps{
+					Description: "Settings tune a running server. Unset fields keep the value the server was started with.",
+					Type:        []string{"object"},
+					Properties: map[string]spec.Schema{
+						"kind":       *spec.StringProperty(),
+						"apiVersion": *spec.StringProperty(),
+						"metadata":   {SchemaProps: spec.SchemaProps{Default: map[string]interface{}{}, Ref: ref("k8s.io/apimachinery/pkg/apis/meta/v1.ObjectMeta")}},
+						"spec":       {SchemaProps: spec.SchemaProps{Default: map[string]interface{}{}, Ref: ref(pkgPath + "SettingsSpec")}},
+					},
+				},
+			},
+			Dependencies: []string{pkgPath + "SettingsSpec", "k8s.io/apimachinery/pkg/apis/meta/v1.ObjectMeta"},
+		},
+		pkgPath + "SettingsList": {
+			Schema: spec.Schema{
+				SchemaProps: spec.SchemaProps{
+					Type: []string{"object"},
+					Properties: map[string]spec.Schema{
+						"kind":       *spec.StringProperty(),
+						"apiVersion": *spec.StringProperty(),
+						"metadata":   {SchemaProps: spec.SchemaProps{Default: map[string]interface{}{}, Ref: ref("k8s.io/apimachinery/pkg/apis/meta/v1.ListMeta")}},
+						"items": *spec.ArrayProperty(&spec.Schema{
+							SchemaProps: spec.SchemaProps{Default: map[string]interface{}{}, Ref: ref(pkgPath + "Settings")},
+						}),
+					},
+					Required: []string{"items"},
+				},
+			},
+			Dependencies: []string{pkgPath + "Settings", "k8s.io/apimachinery/pkg/apis/meta/v1.ListMeta"},
+		},
+		pkgPath + "SettingsSpec": {
+			Schema: spec.Schema{
+				SchemaProps: spec.SchemaProps{
+					Type: []string{"object"},
+					Properties: map[string]spec.Schema{
+						"logLevel": *spec.StringProperty().WithDescription("LogLevel is the logrus level, such as info or debug."),
+						"gc":       {SchemaProps: spec.SchemaProps{Ref: ref(pkgPath + "GCSettings")}},
+						"rateLimits": *spec.MapProperty(&spec.Schema{
+							SchemaProps: spec.SchemaProps{Default: map[string]interface{}{}, Ref: ref(pkgPath + "RateLimit")},
+						}).WithDescription("RateLimits are the per user rate limits of the verb classes read, watch and write."),
+						"bookmarkIntervalSeconds": *spec.Int64Property().WithDescription("BookmarkIntervalSeconds is the time between bookmarks sent to watches."),
+						"tables": *spec.MapProperty(&spec.Schema{
+							SchemaProps: spec.SchemaProps{Default: map[string]interface{}{}, Ref: ref(pkgPath + "TableSettings")},
+						}).WithDescription("Tables tune the tables named by the keys, such as the logs of their SQL queries."),
+					},
+				},
+			},
+			Dependencies: []string{pkgPath + "GCSettings", pkgPath + "RateLimit", pkgPath + "TableSettings"},
+		},
+		pkgPath + "GCSettings": {
+			Schema: spec.Schema{
+				SchemaProps: spec.SchemaProps{
+					Type: []string{"object"},
+					Properties: map[string]spec.Schema{
+						"compactRetain":   *spec.Int64Property().WithDescription("CompactRetain is the number of records kept when a table is compacted, zero disables garbage collection."),
+						"deleteRetain":    *spec.Int64Property().WithDescription("DeleteRetain is the number of compacted records kept before they are deleted, zero disables deletion."),
+						"intervalSeconds": *spec.Int64Property().WithDescription("IntervalSeconds is the time between garbage collection runs."),
+					},
+				},
+			},
+		},
+		pkgPath + "TableSettings": {
+			Schema: spec.Schema{
+				SchemaProps: spec.SchemaProps{
+					Type: []string{"object"},
+					Properties: map[string]spec.Schema{
+						"sqlLogLevel":              *spec.StringProperty().WithDescription("SQLLogLevel is the logrus level of the logs of the SQL queries of the table, it replaces LogLevel for them: error logs failed queries, info slow queries too and trace every query."),
+						"slowQueryThresholdMillis": *spec.Int64Property().WithDescription("SlowQueryThresholdMillis is how long a query of the table takes before it is logged as slow."),
+					},
+				},
+			},
+		},
+		pkgPath + "RateLimit": {
+			Schema: spec.Schema{
+				SchemaProps: spec.SchemaProps{
+					Type: []string{"object"},
+					Properties: map[string]spec.Schema{
+						"qps":   *spec.Float64Property().WithDescription("QPS is the rate at which requests are allowed, zero disables limiting."),
+						"burst": *spec.Int32Property().WithDescription("Burst is the number of requests allowed at once, by default the ceiling of QPS."),
+					},
+					Required: []string{"qps"},
+				},
+			},
+		},
+	}
+}
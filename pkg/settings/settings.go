@@ -0,0 +1,239 @@
+package settings
+
+import (
+	"context"
+	"time"
+
+	"github.com/acorn-io/mink/pkg/db"
+	"github.com/acorn-io/mink/pkg/logging"
+	"github.com/acorn-io/mink/pkg/ratelimit"
+	"github.com/acorn-io/mink/pkg/stores"
+	"github.com/acorn-io/mink/pkg/strategy"
+	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/apiserver/pkg/registry/rest"
+	"k8s.io/apiserver/pkg/storage"
+)
+
+// Name is the name of the only Settings object, objects with other names are rejected.
+const Name = "default"
+
+// NewStore returns the storage of settings, persisted by s, which is typically created by a db.Factory. The Settings
+// types must have been added to the scheme with AddToScheme. Changes are applied by a Reconfigurer watching s.
+func NewStore(scheme *runtime.Scheme, s strategy.CompleteStrategy) rest.Storage {
+	return stores.NewBuilder(scheme, &Settings{}).
+		ClusterScoped().
+		WithCompleteCRUD(s).
+		WithValidateCreate(validator{}).
+		WithValidateUpdate(validator{}).Build()
+}
+
+type validator struct{}
+
+func (validator) Validate(_ context.Context, obj runtime.Object) (result field.ErrorList) {
+	settings := obj.(*Settings)
+	if settings.Name != Name {
+		result = append(result, field.Invalid(field.NewPath("metadata", "name"), settings.Name, "must be "+Name))
+	}
+
+	specPath := field.NewPath("spec")
+	if settings.Spec.LogLevel != "" {
+		if _, err := logrus.ParseLevel(settings.Spec.LogLevel); err != nil {
+			result = append(result, field.Invalid(specPath.Child("logLevel"), settings.Spec.LogLevel, err.Error()))
+		}
+	}
+	if gc := settings.Spec.GC; gc != nil {
+		result = append(result, nonNegative(specPath.Child("gc", "compactRetain"), gc.CompactRetain)...)
+		result = append(result, nonNegative(specPath.Child("gc", "deleteRetain"), gc.DeleteRetain)...)
+		result = append(result, nonNegative(specPath.Child("gc", "intervalSeconds"), gc.IntervalSeconds)...)
+	}
+	result = append(result, nonNegative(specPath.Child("bookmarkIntervalSeconds"), settings.Spec.BookmarkIntervalSeconds)...)
+	for table, tableSettings := range settings.Spec.Tables {
+		path := specPath.Child("tables").Key(table)
+		if tableSettings.SQLLogLevel != "" {
+			if _, err := logrus.ParseLevel(tableSettings.SQLLogLevel); err != nil {
+				result = append(result, field.Invalid(path.Child("sqlLogLevel"), tableSettings.SQLLogLevel, err.Error()))
+			}
+		}
+		result = append(result, nonNegative(path.Child("slowQueryThresholdMillis"), tableSettings.SlowQueryThresholdMillis)...)
+	}
+	for class, limit := range settings.Spec.RateLimits {
+		path := specPath.Child("rateLimits").Key(class)
+		switch class {
+		case ratelimit.VerbClassRead, ratelimit.VerbClassWatch, ratelimit.VerbClassWrite:
+		default:
+			result = append(result, field.NotSupported(path, class,
+				[]string{ratelimit.VerbClassRead, ratelimit.VerbClassWatch, ratelimit.VerbClassWrite}))
+		}
+		if limit.QPS < 0 {
+			result = append(result, field.Invalid(path.Child("qps"), limit.QPS, "must not be negative"))
+		}
+		if limit.Burst < 0 {
+			result = append(result, field.Invalid(path.Child("burst"), limit.Burst, "must not be negative"))
+		}
+	}
+	return result
+}
+
+func (v validator) ValidateUpdate(ctx context.Context, obj, _ runtime.Object) field.ErrorList {
+	return v.Validate(ctx, obj)
+}
+
+func nonNegative(path *field.Path, value *int64) field.ErrorList {
+	if value != nil && *value < 0 {
+		return field.ErrorList{field.Invalid(path, *value, "must not be negative")}
+	}
+	return nil
+}
+
+// RuntimeSettable is changed with the garbage collection and bookmark settings, *db.Factory is one.
+type RuntimeSettable interface {
+	SetRuntimeSettings(settings db.RuntimeSettings)
+}
+
+// TableRuntimeSettable is a RuntimeSettable that also changes the settings of a single table, *db.Factory is one.
+// Table settings are only applied to a Reconfigurer.Tables that implements it.
+type TableRuntimeSettable interface {
+	SetTableRuntimeSettings(table string, settings db.RuntimeSettings) bool
+}
+
+// Reconfigurer applies the Settings object to the running server whenever it changes. Settings that are unset, or
+// the object being deleted, restore the configuration the server was started with.
+type Reconfigurer struct {
+	// Watcher is the strategy the settings store was created with.
+	Watcher strategy.Watcher
+	// Tables, if set, get the garbage collection and bookmark settings, and the table settings if it is a
+	// TableRuntimeSettable.
+	Tables RuntimeSettable
+	// Limiter, if set, gets the rate limits. RateLimits are the options it was created with.
+	Limiter    *ratelimit.Limiter
+	RateLimits ratelimit.Options
+	// Logger, if set, logs the changes and gets the log level, the default is logging.Default. The log level is only
+	// applied to loggers that support it, see logging.SetLevel.
+	Logger logging.Logger
+
+	logLevel logrus.Level
+}
+
+func (r *Reconfigurer) logger() logging.Logger {
+	if r.Logger == nil {
+		return logging.Default()
+	}
+	return r.Logger
+}
+
+// Start watches the settings until ctx is done.
+func (r *Reconfigurer) Start(ctx context.Context) {
+	r.logLevel, _ = logging.Level(r.logger())
+	go func() {
+		for {
+			r.watch(ctx)
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(time.Second):
+			}
+		}
+	}()
+}
+
+func (r *Reconfigurer) watch(ctx context.Context) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	events, err := r.Watcher.Watch(ctx, "", storage.ListOptions{
+		Predicate: storage.Everything,
+	})
+	if err != nil {
+		r.logger().Errorf("Failed to watch settings: %v", err)
+		return
+	}
+	for event := range events {
+		settings, ok := event.Object.(*Settings)
+		if !ok || settings.Name != Name {
+			continue
+		}
+		switch event.Type {
+		case watch.Added, watch.Modified:
+			r.Apply(settings.Spec)
+		case watch.Deleted:
+			r.Apply(SettingsSpec{})
+		}
+	}
+}
+
+// Apply changes the running server to spec.
+func (r *Reconfigurer) Apply(spec SettingsSpec) {
+	level := r.logLevel
+	if spec.LogLevel != "" {
+		if l, err := logrus.ParseLevel(spec.LogLevel); err == nil {
+			level = l
+		}
+	}
+	if current, ok := logging.Level(r.logger()); !ok {
+		if spec.LogLevel != "" {
+			r.logger().Warnf("Not setting log level to %s, the level of the logger can't be changed", level)
+		}
+	} else if level != current {
+		r.logger().Infof("Setting log level to %s", level)
+		logging.SetLevel(r.logger(), level)
+	}
+
+	if r.Tables != nil {
+		var runtimeSettings db.RuntimeSettings
+		if gc := spec.GC; gc != nil {
+			if gc.CompactRetain != nil {
+				compactRetain := uint(*gc.CompactRetain)
+				runtimeSettings.CompactRetain = &compactRetain
+			}
+			if gc.DeleteRetain != nil {
+				deleteRetain := int(*gc.DeleteRetain)
+				runtimeSettings.DeleteRetain = &deleteRetain
+			}
+			runtimeSettings.GCInterval = seconds(gc.IntervalSeconds)
+		}
+		runtimeSettings.BookmarkInterval = seconds(spec.BookmarkIntervalSeconds)
+		r.Tables.SetRuntimeSettings(runtimeSettings)
+
+		if tables, ok := r.Tables.(TableRuntimeSettable); ok {
+			for table, tableSettings := range spec.Tables {
+				tableRuntimeSettings := runtimeSettings
+				if level, err := logrus.ParseLevel(tableSettings.SQLLogLevel); err == nil {
+					tableRuntimeSettings.SQLLogLevel = &level
+				}
+				if millis := tableSettings.SlowQueryThresholdMillis; millis != nil {
+					threshold := time.Duration(*millis) * time.Millisecond
+					tableRuntimeSettings.SlowQueryThreshold = &threshold
+				}
+				if !tables.SetTableRuntimeSettings(table, tableRuntimeSettings) {
+					r.logger().Warnf("Not applying the settings of table %s, there is no such table", table)
+				}
+			}
+		}
+	}
+
+	if r.Limiter != nil {
+		opts := r.RateLimits
+		opts.Rates = make(map[string]ratelimit.Rate, len(r.RateLimits.Rates)+len(spec.RateLimits))
+		for class, rate := range r.RateLimits.Rates {
+			opts.Rates[class] = rate
+		}
+		for class, limit := range spec.RateLimits {
+			opts.Rates[class] = ratelimit.Rate{
+				QPS:   limit.QPS,
+				Burst: int(limit.Burst),
+			}
+		}
+		r.Limiter.SetOptions(opts)
+	}
+}
+
+func seconds(s *int64) *time.Duration {
+	if s == nil {
+		return nil
+	}
+	d := time.Duration(*s) * time.Second
+	return &d
+}
@@ -0,0 +1,249 @@
+package settings
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/acorn-io/mink/pkg/db"
+	"github.com/acorn-io/mink/pkg/logging"
+	"github.com/acorn-io/mink/pkg/ratelimit"
+	"github.com/sirupsen/logrus"
+	logrustest "github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apiserver/pkg/authentication/user"
+	"k8s.io/apiserver/pkg/endpoints/request"
+)
+
+func int64ptr(i int64) *int64 {
+	return &i
+}
+
+func TestValidate(t *testing.T) {
+	for _, test := range []struct {
+		name     string
+		settings *Settings
+		fields   []string
+	}{
+		{
+			name:     "valid",
+			settings: &Settings{ObjectMeta: metav1.ObjectMeta{Name: Name}, Spec: SettingsSpec{LogLevel: "debug"}},
+		},
+		{
+			name:     "name",
+			settings: &Settings{ObjectMeta: metav1.ObjectMeta{Name: "other"}},
+			fields:   []string{"metadata.name"},
+		},
+		{
+			name: "negative",
+			settings: &Settings{ObjectMeta: metav1.ObjectMeta{Name: Name}, Spec: SettingsSpec{
+				GC: &GCSettings{
+					CompactRetain:   int64ptr(-1),
+					DeleteRetain:    int64ptr(-1),
+					IntervalSeconds: int64ptr(-1),
+				},
+				BookmarkIntervalSeconds: int64ptr(-1),
+				RateLimits:              map[string]RateLimit{ratelimit.VerbClassRead: {QPS: -1, Burst: -1}},
+				Tables:                  map[string]TableSettings{"things": {SlowQueryThresholdMillis: int64ptr(-1)}},
+			}},
+			fields: []string{
+				"spec.gc.compactRetain",
+				"spec.gc.deleteRetain",
+				"spec.gc.intervalSeconds",
+				"spec.bookmarkIntervalSeconds",
+				"spec.tables[things].slowQueryThresholdMillis",
+				"spec.rateLimits[read].qps",
+				"spec.rateLimits[read].burst",
+			},
+		},
+		{
+			name: "levels",
+			settings: &Settings{ObjectMeta: metav1.ObjectMeta{Name: Name}, Spec: SettingsSpec{
+				LogLevel: "loud",
+				Tables:   map[string]TableSettings{"things": {SQLLogLevel: "quiet"}},
+			}},
+			fields: []string{"spec.logLevel", "spec.tables[things].sqlLogLevel"},
+		},
+		{
+			name: "unknown rate class",
+			settings: &Settings{ObjectMeta: metav1.ObjectMeta{Name: Name}, Spec: SettingsSpec{
+				RateLimits: map[string]RateLimit{"delete": {QPS: 1}},
+			}},
+			fields: []string{"spec.rateLimits[delete]"},
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			var fields []string
+			for _, err := range (validator{}).Validate(context.Background(), test.settings) {
+				fields = append(fields, err.Field)
+			}
+			assert.Equal(t, test.fields, fields)
+		})
+	}
+}
+
+// tablesRecorder records runtime settings, it has a single table named things.
+type tablesRecorder struct {
+	lock     sync.Mutex
+	settings db.RuntimeSettings
+	tables   map[string]db.RuntimeSettings
+}
+
+func (r *tablesRecorder) SetRuntimeSettings(settings db.RuntimeSettings) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.settings = settings
+	r.tables = map[string]db.RuntimeSettings{}
+}
+
+func (r *tablesRecorder) SetTableRuntimeSettings(table string, settings db.RuntimeSettings) bool {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if table != "things" {
+		return false
+	}
+	r.tables[table] = settings
+	return true
+}
+
+func (r *tablesRecorder) get() (db.RuntimeSettings, map[string]db.RuntimeSettings) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.settings, r.tables
+}
+
+// allowed returns how many of n reads of alice the limiter lets through.
+func allowed(l *ratelimit.Limiter, n int) (result int) {
+	handler := l.Middleware(http.HandlerFunc(func(rw http.ResponseWriter, _ *http.Request) {
+		rw.WriteHeader(http.StatusOK)
+	}))
+	for i := 0; i < n; i++ {
+		req := httptest.NewRequest(http.MethodGet, "/apis/example.com/v1/things", nil)
+		ctx := request.WithUser(req.Context(), &user.DefaultInfo{Name: "alice"})
+		ctx = request.WithRequestInfo(ctx, &request.RequestInfo{IsResourceRequest: true, Verb: "get", Resource: "things"})
+		rw := httptest.NewRecorder()
+		handler.ServeHTTP(rw, req.WithContext(ctx))
+		if rw.Code == http.StatusOK {
+			result++
+		}
+	}
+	return result
+}
+
+func newTestReconfigurer() (*Reconfigurer, *tablesRecorder, *logrustest.Hook) {
+	logger, hook := logrustest.NewNullLogger()
+	logger.SetLevel(logrus.InfoLevel)
+	rateLimits := ratelimit.Options{Rates: map[string]ratelimit.Rate{ratelimit.VerbClassRead: {QPS: 0.001}}}
+	tables := &tablesRecorder{}
+	return &Reconfigurer{
+		Tables:     tables,
+		Limiter:    ratelimit.New(rateLimits),
+		RateLimits: rateLimits,
+		Logger:     logging.Logrus(logger),
+	}, tables, hook
+}
+
+func TestApply(t *testing.T) {
+	r, tables, hook := newTestReconfigurer()
+	r.logLevel = logrus.InfoLevel
+
+	r.Apply(SettingsSpec{
+		LogLevel:                "debug",
+		GC:                      &GCSettings{CompactRetain: int64ptr(5)},
+		BookmarkIntervalSeconds: int64ptr(10),
+		RateLimits:              map[string]RateLimit{ratelimit.VerbClassRead: {QPS: 0.001, Burst: 3}},
+		Tables: map[string]TableSettings{
+			"things":  {SQLLogLevel: "trace", SlowQueryThresholdMillis: int64ptr(100)},
+			"missing": {SQLLogLevel: "trace"},
+		},
+	})
+	level, _ := logging.Level(r.Logger)
+	assert.Equal(t, logrus.DebugLevel, level)
+	settings, tableSettings := tables.get()
+	assert.Equal(t, uint(5), *settings.CompactRetain)
+	assert.Equal(t, 10*time.Second, *settings.BookmarkInterval)
+	if things, ok := tableSettings["things"]; assert.True(t, ok) {
+		assert.Equal(t, uint(5), *things.CompactRetain)
+		assert.Equal(t, logrus.TraceLevel, *things.SQLLogLevel)
+		assert.Equal(t, 100*time.Millisecond, *things.SlowQueryThreshold)
+	}
+	assert.Equal(t, 3, allowed(r.Limiter, 5))
+
+	// settings of tables that don't exist are reported and skipped
+	var warnings []string
+	for _, entry := range hook.AllEntries() {
+		if entry.Level == logrus.WarnLevel {
+			warnings = append(warnings, entry.Message)
+		}
+	}
+	assert.Equal(t, []string{"Not applying the settings of table missing, there is no such table"}, warnings)
+
+	// empty settings restore the configuration the server was started with
+	r.Apply(SettingsSpec{})
+	level, _ = logging.Level(r.Logger)
+	assert.Equal(t, logrus.InfoLevel, level)
+	settings, tableSettings = tables.get()
+	assert.Equal(t, db.RuntimeSettings{}, settings)
+	assert.Empty(t, tableSettings)
+	assert.Equal(t, 1, allowed(r.Limiter, 5))
+}
+
+func TestReconfigurerDelete(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := AddToScheme(scheme, schema.GroupVersion{Group: "mink.acorn.io", Version: "v1"}); err != nil {
+		t.Fatal(err)
+	}
+	f, err := db.NewFactory(scheme, "sqlite://file:"+t.Name()+"?mode=memory&cache=shared")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = f.SQLDB.Close()
+	})
+	store, err := f.NewDBStrategy(&Settings{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	r, tables, _ := newTestReconfigurer()
+	r.Watcher = store
+	r.Start(ctx)
+
+	level := func() logrus.Level {
+		level, _ := logging.Level(r.Logger)
+		return level
+	}
+	compactRetain := func() *uint {
+		settings, _ := tables.get()
+		return settings.CompactRetain
+	}
+
+	created, err := store.Create(ctx, &Settings{
+		ObjectMeta: metav1.ObjectMeta{Name: Name},
+		Spec:       SettingsSpec{LogLevel: "debug", GC: &GCSettings{CompactRetain: int64ptr(5)}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Eventually(t, func() bool {
+		return level() == logrus.DebugLevel && compactRetain() != nil
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// deleting the settings restores the configuration the server was started with
+	now := metav1.Now()
+	created.SetDeletionTimestamp(&now)
+	if _, err := store.Delete(ctx, created); err != nil {
+		t.Fatal(err)
+	}
+	assert.Eventually(t, func() bool {
+		return level() == logrus.InfoLevel && compactRetain() == nil
+	}, 5*time.Second, 10*time.Millisecond)
+}
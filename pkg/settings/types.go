@@ -0,0 +1,66 @@
+package settings
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// AddToScheme registers the Settings types in the group version the embedder serves them in.
+func AddToScheme(scheme *runtime.Scheme, gv schema.GroupVersion) error {
+	scheme.AddKnownTypes(gv, &Settings{}, &SettingsList{})
+	return nil
+}
+
+// Settings tune a running server. Unset fields keep the value the server was started with.
+type Settings struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec SettingsSpec `json:"spec,omitempty"`
+}
+
+type SettingsSpec struct {
+	// LogLevel is the logrus level, such as info or debug.
+	LogLevel string `json:"logLevel,omitempty"`
+	// GC tunes the garbage collection of every table.
+	GC *GCSettings `json:"gc,omitempty"`
+	// RateLimits are the per user rate limits of the verb classes read, watch and write. They replace the configured
+	// limits of the classes they name.
+	RateLimits map[string]RateLimit `json:"rateLimits,omitempty"`
+	// BookmarkIntervalSeconds is the time between bookmarks sent to watches.
+	BookmarkIntervalSeconds *int64 `json:"bookmarkIntervalSeconds,omitempty"`
+	// Tables tune the tables named by the keys, such as the logs of their SQL queries.
+	Tables map[string]TableSettings `json:"tables,omitempty"`
+}
+
+type TableSettings struct {
+	// SQLLogLevel is the logrus level of the logs of the SQL queries of the table, it replaces LogLevel for them:
+	// error logs failed queries, info slow queries too and trace every query.
+	SQLLogLevel string `json:"sqlLogLevel,omitempty"`
+	// SlowQueryThresholdMillis is how long a query of the table takes before it is logged as slow.
+	SlowQueryThresholdMillis *int64 `json:"slowQueryThresholdMillis,omitempty"`
+}
+
+type GCSettings struct {
+	// CompactRetain is the number of records kept when a table is compacted, zero disables garbage collection.
+	CompactRetain *int64 `json:"compactRetain,omitempty"`
+	// DeleteRetain is the number of compacted records kept before they are deleted, zero disables deletion.
+	DeleteRetain *int64 `json:"deleteRetain,omitempty"`
+	// IntervalSeconds is the time between garbage collection runs.
+	IntervalSeconds *int64 `json:"intervalSeconds,omitempty"`
+}
+
+type RateLimit struct {
+	// QPS is the rate at which requests are allowed, zero disables limiting.
+	QPS float64 `json:"qps"`
+	// Burst is the number of requests allowed at once, by default the ceiling of QPS.
+	Burst int32 `json:"burst,omitempty"`
+}
+
+type SettingsList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+
+	Items []Settings `json:"items"`
+}
@@ -2,12 +2,16 @@ package stores
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/acorn-io/mink/pkg/strategy"
 	"github.com/acorn-io/mink/pkg/types"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/sets"
 	"k8s.io/apiserver/pkg/registry/rest"
 	kclient "sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
 )
 
 type Builder struct {
@@ -33,6 +37,29 @@ type Builder struct {
 	NameValidator     strategy.NameValidator
 
 	ValidateDeleter strategy.ValidateDeleter
+
+	DeleteCollection strategy.CollectionDeleter
+	BackgroundDelete *strategy.BackgroundDeleteOptions
+
+	NamespaceScoper strategy.NamespaceScoper
+	Scrubber        strategy.Scrubber
+	Decorator       strategy.Decorator
+	ListConcurrency strategy.ListConcurrency
+
+	WatchCoalesceDelay time.Duration
+	WatchRate          strategy.WatchRate
+
+	SingularName   string
+	ShortNames     []string
+	Categories     []string
+	StorageVersion runtime.GroupVersioner
+	Verbs          []string
+}
+
+type scope bool
+
+func (s scope) NamespaceScoped() bool {
+	return bool(s)
 }
 
 func NewBuilder(scheme *runtime.Scheme, obj kclient.Object) *Builder {
@@ -67,6 +94,7 @@ func (b Builder) WithGet(get strategy.Getter) *Builder {
 	return &b
 }
 
+// WithCompleteCRUD serves every verb but deletecollection with complete, which is enabled with WithDeleteCollection.
 func (b Builder) WithCompleteCRUD(complete strategy.CompleteCRUD) *Builder {
 	return b.WithCreate(complete).
 		WithGet(complete).
@@ -126,12 +154,112 @@ func (b Builder) WithDelete(deleter strategy.Deleter) *Builder {
 	return &b
 }
 
+// WithDeleteCollection enables deletecollection for the store. The matching objects are deleted by the
+// DeleteCollection method of the strategy before the request returns. WithBackgroundDeleteCollection takes precedence.
+func (b Builder) WithDeleteCollection(deleter strategy.CollectionDeleter) *Builder {
+	b.DeleteCollection = deleter
+	return &b
+}
+
+// WithBackgroundDeleteCollection enables deletecollection for the store. The request is acknowledged immediately and
+// the matching objects are deleted in batches in the background using the configured List and Delete strategies. The
+// store built is a *ReadWriteWatchDeleteCollectionStore, whose DeleteJobs serve the progress of the deletions.
+func (b Builder) WithBackgroundDeleteCollection(opts strategy.BackgroundDeleteOptions) *Builder {
+	b.BackgroundDelete = &opts
+	return &b
+}
+
+// ClusterScoped registers the resource as cluster scoped regardless of the defaulting done by the adapters.
+func (b Builder) ClusterScoped() *Builder {
+	b.NamespaceScoper = scope(false)
+	return &b
+}
+
+// Namespaced registers the resource as namespace scoped regardless of the defaulting done by the adapters.
+func (b Builder) Namespaced() *Builder {
+	b.NamespaceScoper = scope(true)
+	return &b
+}
+
+// WithSingularName overrides the singular name published in discovery, which defaults to the lowercase kind.
+func (b Builder) WithSingularName(name string) *Builder {
+	b.SingularName = name
+	return &b
+}
+
+func (b Builder) WithShortNames(shortNames ...string) *Builder {
+	b.ShortNames = shortNames
+	return &b
+}
+
+func (b Builder) WithCategories(categories ...string) *Builder {
+	b.Categories = categories
+	return &b
+}
+
+// WithStorageVersion publishes a storageVersionHash in discovery for the given storage version.
+func (b Builder) WithStorageVersion(gv schema.GroupVersion) *Builder {
+	b.StorageVersion = gv
+	return &b
+}
+
+// WithVerbs limits the verbs served by the store to the given list. Strategies for verbs not in the list are
+// dropped before the store is built, so discovery only reports the verbs that are actually served.
+func (b Builder) WithVerbs(verbs ...string) *Builder {
+	b.Verbs = verbs
+	return &b
+}
+
+// WithScrubber sets the Scrubber applied to every object returned by Get, List and Watch.
+func (b Builder) WithScrubber(scrubber strategy.Scrubber) *Builder {
+	b.Scrubber = scrubber
+	return &b
+}
+
+// WithDecorator sets the Decorator applied to every object returned by Get, List and Watch. If it is a
+// strategy.Undecorator, it also removes the decorated fields from the objects written by Create, Update and Patch.
+func (b Builder) WithDecorator(decorator strategy.Decorator) *Builder {
+	b.Decorator = decorator
+	return &b
+}
+
+// WithListConcurrency decorates the items of large lists in parallel, for decorators that are CPU bound.
+func (b Builder) WithListConcurrency(concurrency strategy.ListConcurrency) *Builder {
+	b.ListConcurrency = concurrency
+	return &b
+}
+
+// WithWatchCoalesceDelay collapses Modified events for the same object sent to a watch within delay into the latest
+// one.
+func (b Builder) WithWatchCoalesceDelay(delay time.Duration) *Builder {
+	b.WatchCoalesceDelay = delay
+	return &b
+}
+
+// WithWatchRate limits the events sent to each watch to rate, see strategy.WatchRate.
+func (b Builder) WithWatchRate(rate strategy.WatchRate) *Builder {
+	b.WatchRate = rate
+	return &b
+}
+
 func (b Builder) WithDestroy(destroy strategy.Destroyer) *Builder {
 	b.Destroy = destroy
 	return &b
 }
 
+// Build returns the store for the configured strategies. It panics if the combination of strategies is not
+// supported.
 func (b Builder) Build() rest.Storage {
+	store := b.build()
+	if verbs := b.ServedVerbs(); !verbsMatch(store, verbs) {
+		panic(fmt.Sprintf("%T serves verbs %v, expected %v", store, storeVerbs(store), verbs))
+	}
+	return store
+}
+
+func (b Builder) build() rest.Storage {
+	b.restrictVerbs()
+
 	var (
 		getSet    = b.Get != nil
 		createSet = b.Create != nil
@@ -141,6 +269,48 @@ func (b Builder) Build() rest.Storage {
 		watchSet  = b.Watch != nil
 	)
 
+	b.checkScope()
+
+	if b.BackgroundDelete != nil {
+		if createSet && getSet && listSet && updateSet && deleteSet && watchSet {
+			listAdapter, deleteAdapter := b.listAdapter(), b.deleteAdapter()
+			return &ReadWriteWatchDeleteCollectionStore{
+				SingularNameAdapter:     b.getSingularNameAdapter(),
+				CreateAdapter:           b.createAdapter(),
+				GetAdapter:              b.getAdapter(),
+				ListAdapter:             listAdapter,
+				PatchAdapter:            b.patchAdapter(),
+				DeleteAdapter:           deleteAdapter,
+				WatchAdapter:            b.watchAdapter(),
+				BackgroundDeleteAdapter: strategy.NewBackgroundDelete(listAdapter, deleteAdapter, *b.BackgroundDelete),
+				DestroyAdapter:          b.destroyAdapter(),
+				TableAdapter:            b.tableAdapter(),
+			}
+		}
+		panic(fmt.Sprintf("background delete collection with createSet=%v, getSet=%v, listSet=%v, updateSet=%v, "+
+			"deleteSet=%v, watchSet=%v combination is not currently supported, PRs welcomed!", createSet, getSet, listSet,
+			updateSet, deleteSet, watchSet))
+	}
+	if b.DeleteCollection != nil {
+		if createSet && getSet && listSet && updateSet && deleteSet && watchSet {
+			listAdapter, deleteAdapter := b.listAdapter(), b.deleteAdapter()
+			return &ReadWriteWatchSyncDeleteCollectionStore{
+				SingularNameAdapter:     b.getSingularNameAdapter(),
+				CreateAdapter:           b.createAdapter(),
+				GetAdapter:              b.getAdapter(),
+				ListAdapter:             listAdapter,
+				PatchAdapter:            b.patchAdapter(),
+				DeleteAdapter:           deleteAdapter,
+				WatchAdapter:            b.watchAdapter(),
+				DeleteCollectionAdapter: strategy.NewDeleteCollection(listAdapter, deleteAdapter, b.DeleteCollection),
+				DestroyAdapter:          b.destroyAdapter(),
+				TableAdapter:            b.tableAdapter(),
+			}
+		}
+		panic(fmt.Sprintf("delete collection with createSet=%v, getSet=%v, listSet=%v, updateSet=%v, deleteSet=%v, "+
+			"watchSet=%v combination is not currently supported, PRs welcomed!", createSet, getSet, listSet, updateSet,
+			deleteSet, watchSet))
+	}
 	if createSet && getSet && !listSet && !updateSet && !deleteSet && !watchSet {
 		return &CreateGetStore{
 			SingularNameAdapter: b.getSingularNameAdapter(),
@@ -225,7 +395,7 @@ func (b Builder) Build() rest.Storage {
 			CreateAdapter:       b.createAdapter(),
 			GetAdapter:          b.getAdapter(),
 			ListAdapter:         b.listAdapter(),
-			UpdateAdapter:       b.updateAdapter(),
+			PatchAdapter:        b.patchAdapter(),
 			DeleteAdapter:       b.deleteAdapter(),
 			WatchAdapter:        b.watchAdapter(),
 			DestroyAdapter:      b.destroyAdapter(),
@@ -251,7 +421,7 @@ func (b Builder) Build() rest.Storage {
 			GetAdapter:          b.getAdapter(),
 			ListAdapter:         b.listAdapter(),
 			DeleteAdapter:       b.deleteAdapter(),
-			UpdateAdapter:       b.updateAdapter(),
+			PatchAdapter:        b.patchAdapter(),
 			DestroyAdapter:      b.destroyAdapter(),
 			TableAdapter:        b.tableAdapter(),
 		}
@@ -261,7 +431,7 @@ func (b Builder) Build() rest.Storage {
 			SingularNameAdapter: b.getSingularNameAdapter(),
 			GetAdapter:          b.getAdapter(),
 			ListAdapter:         b.listAdapter(),
-			UpdateAdapter:       b.updateAdapter(),
+			PatchAdapter:        b.patchAdapter(),
 			DeleteAdapter:       b.deleteAdapter(),
 			DestroyAdapter:      b.destroyAdapter(),
 			TableAdapter:        b.tableAdapter(),
@@ -272,7 +442,7 @@ func (b Builder) Build() rest.Storage {
 			SingularNameAdapter: b.getSingularNameAdapter(),
 			GetAdapter:          b.getAdapter(),
 			ListAdapter:         b.listAdapter(),
-			UpdateAdapter:       b.updateAdapter(),
+			PatchAdapter:        b.patchAdapter(),
 			DeleteAdapter:       b.deleteAdapter(),
 			WatchAdapter:        b.watchAdapter(),
 			DestroyAdapter:      b.destroyAdapter(),
@@ -305,8 +475,61 @@ func (b Builder) Build() rest.Storage {
 		watchSet))
 }
 
+func (b *Builder) restrictVerbs() {
+	if len(b.Verbs) == 0 {
+		return
+	}
+	verbs := sets.New(b.Verbs...)
+	if !verbs.Has("get") {
+		b.Get = nil
+	}
+	if !verbs.Has("list") {
+		b.List = nil
+	}
+	if !verbs.Has("watch") {
+		b.Watch = nil
+	}
+	if !verbs.Has("create") {
+		b.Create = nil
+	}
+	if !verbs.HasAny("update", "patch") {
+		b.Update = nil
+	}
+	if !verbs.Has("delete") {
+		b.Delete = nil
+	}
+	if !verbs.Has("deletecollection") {
+		b.DeleteCollection = nil
+		b.BackgroundDelete = nil
+	}
+}
+
+// checkScope panics if the scope set with ClusterScoped or Namespaced disagrees with the scope declared by the object
+// or one of the strategies, because the resource would be registered with a scope it doesn't behave as.
+func (b Builder) checkScope() {
+	if b.NamespaceScoper == nil {
+		return
+	}
+	expected := b.NamespaceScoper.NamespaceScoped()
+	for _, s := range []any{b.obj, b.Create, b.Get, b.List, b.Update, b.Delete, b.Watch} {
+		if s == nil {
+			continue
+		}
+		if scoper, ok := s.(strategy.NamespaceScoper); ok && scoper.NamespaceScoped() != expected {
+			panic(fmt.Sprintf("%T is registered with NamespaceScoped=%v but %T reports NamespaceScoped=%v",
+				b.obj, expected, s, scoper.NamespaceScoped()))
+		}
+	}
+}
+
 func (b Builder) watchAdapter() *strategy.WatchAdapter {
-	return strategy.NewWatch(b.Watch)
+	watch := strategy.NewWatch(b.Watch)
+	watch.NamespaceScoper = b.NamespaceScoper
+	watch.Scrubber = b.Scrubber
+	watch.Decorator = b.Decorator
+	watch.CoalesceDelay = b.WatchCoalesceDelay
+	watch.Rate = b.WatchRate
+	return watch
 }
 
 type newer struct {
@@ -322,7 +545,9 @@ func (b Builder) newAdapter() *strategy.NewAdapter {
 }
 
 func (b Builder) scoperAdapter() *strategy.ScoperAdapter {
-	return strategy.NewScoper(&newer{obj: b.obj})
+	scoper := strategy.NewScoper(&newer{obj: b.obj})
+	scoper.NamespaceScoper = b.NamespaceScoper
+	return scoper
 }
 
 func (b Builder) createAdapter() *strategy.CreateAdapter {
@@ -331,6 +556,8 @@ func (b Builder) createAdapter() *strategy.CreateAdapter {
 	create.Warner = b.WarningsOnCreator
 	create.Validator = b.Validator
 	create.NameValidator = b.NameValidator
+	create.NamespaceScoper = b.NamespaceScoper
+	create.Decorator = b.Decorator
 	return create
 }
 
@@ -341,16 +568,48 @@ func (b Builder) updateAdapter() *strategy.UpdateAdapter {
 	update.ValidateUpdater = b.ValidateUpdater
 	if b.Create != nil {
 		update.CreateAdapter = b.createAdapter()
+	} else {
+		update.CreateAdapter.NamespaceScoper = b.NamespaceScoper
+		update.CreateAdapter.Decorator = b.Decorator
 	}
 	return update
 }
 
+// patchAdapter serves patches natively if the update strategy is a strategy.Patcher.
+func (b Builder) patchAdapter() *strategy.PatchAdapter {
+	patch := strategy.NewPatch(b.scheme, b.Update)
+	patch.UpdateAdapter = b.updateAdapter()
+	return patch
+}
+
 func (b Builder) getSingularNameAdapter() *strategy.SingularNameAdapter {
-	return strategy.NewSingularNameAdapter(b.obj, b.scheme)
+	singularName := strategy.NewSingularNameAdapter(b.obj, b.scheme)
+	singularName.Singular = b.SingularName
+	singularName.ShortNameList = b.ShortNames
+	singularName.CategoryList = b.Categories
+	singularName.StorageVersioner = b.storageVersion()
+	return singularName
+}
+
+// storageVersion defaults the storage version to the version of the object for stores whose objects can be
+// created and read back, which are the ones that persist them. Stores that serve the same type at several versions
+// should set it with WithStorageVersion, so that every version publishes the same storageVersionHash.
+func (b Builder) storageVersion() runtime.GroupVersioner {
+	if b.StorageVersion != nil || b.Create == nil || b.Get == nil {
+		return b.StorageVersion
+	}
+	gvk, err := apiutil.GVKForObject(b.obj, b.scheme)
+	if err != nil {
+		return nil
+	}
+	return gvk.GroupVersion()
 }
 
 func (b Builder) getAdapter() *strategy.GetAdapter {
-	return strategy.NewGet(b.Get)
+	get := strategy.NewGet(b.Get)
+	get.Scrubber = b.Scrubber
+	get.Decorator = b.Decorator
+	return get
 }
 
 func (b Builder) destroyAdapter() *strategy.DestroyAdapter {
@@ -362,7 +621,12 @@ func (b Builder) tableAdapter() *strategy.TableAdapter {
 }
 
 func (b Builder) listAdapter() *strategy.ListAdapter {
-	return strategy.NewList(b.List)
+	list := strategy.NewList(b.List)
+	list.NamespaceScoper = b.NamespaceScoper
+	list.Scrubber = b.Scrubber
+	list.Decorator = b.Decorator
+	list.Concurrency = b.ListConcurrency
+	return list
 }
 
 func (b Builder) deleteAdapter() *strategy.DeleteAdapter {
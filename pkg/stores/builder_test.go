@@ -0,0 +1,257 @@
+package stores
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/acorn-io/mink/pkg/db"
+	"github.com/acorn-io/mink/pkg/strategy"
+	"github.com/acorn-io/mink/pkg/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/apiserver/pkg/endpoints/request"
+	"k8s.io/apiserver/pkg/registry/rest"
+	"k8s.io/apiserver/pkg/storage"
+	"k8s.io/client-go/kubernetes/scheme"
+)
+
+type podStrategy struct{}
+
+func (podStrategy) New() types.Object {
+	return &corev1.Pod{}
+}
+
+func (podStrategy) NewList() types.ObjectList {
+	return &corev1.PodList{}
+}
+
+func (podStrategy) Get(context.Context, string, string) (types.Object, error) {
+	return nil, nil
+}
+
+func (podStrategy) Create(context.Context, types.Object) (types.Object, error) {
+	return nil, nil
+}
+
+func (podStrategy) Update(context.Context, types.Object) (types.Object, error) {
+	return nil, nil
+}
+
+func (podStrategy) Delete(context.Context, types.Object) (types.Object, error) {
+	return nil, nil
+}
+
+func (podStrategy) List(context.Context, string, storage.ListOptions) (types.ObjectList, error) {
+	return nil, nil
+}
+
+func (podStrategy) DeleteCollection(context.Context, string, strategy.DeleteCollectionOptions) (types.ObjectList, error) {
+	return nil, nil
+}
+
+func (podStrategy) Watch(context.Context, string, storage.ListOptions) (<-chan watch.Event, error) {
+	return nil, nil
+}
+
+func TestBuildServesConfiguredVerbs(t *testing.T) {
+	for _, verbs := range [][]string{
+		{"create"},
+		{"get"},
+		{"list"},
+		{"create", "get"},
+		{"get", "list"},
+		{"list", "watch"},
+		{"delete", "get", "list"},
+		{"get", "list", "watch"},
+		{"create", "delete", "get", "list"},
+		{"delete", "get", "list", "watch"},
+		{"create", "delete", "get", "list", "watch"},
+		{"delete", "get", "list", "patch", "update"},
+		{"create", "delete", "get", "list", "patch", "update"},
+		{"delete", "get", "list", "patch", "update", "watch"},
+		{"create", "delete", "get", "list", "patch", "update", "watch"},
+		{"create", "delete", "deletecollection", "get", "list", "patch", "update", "watch"},
+	} {
+		b := NewBuilder(scheme.Scheme, &corev1.Pod{}).
+			WithCompleteCRUD(podStrategy{}).
+			WithBackgroundDeleteCollection(strategy.BackgroundDeleteOptions{}).
+			WithVerbs(verbs...)
+		assert.Equal(t, verbs, b.ServedVerbs())
+		assert.Equal(t, verbs, storeVerbs(b.Build()))
+	}
+}
+
+func TestBuildDeleteCollection(t *testing.T) {
+	// deletecollection is opt-in
+	store := NewBuilder(scheme.Scheme, &corev1.Pod{}).WithCompleteCRUD(podStrategy{}).Build()
+	assert.NotContains(t, storeVerbs(store), "deletecollection")
+
+	store = NewBuilder(scheme.Scheme, &corev1.Pod{}).
+		WithCompleteCRUD(podStrategy{}).
+		WithDeleteCollection(podStrategy{}).Build()
+	assert.IsType(t, &ReadWriteWatchSyncDeleteCollectionStore{}, store)
+	assert.Contains(t, storeVerbs(store), "deletecollection")
+
+	store = NewBuilder(scheme.Scheme, &corev1.Pod{}).
+		WithCompleteCRUD(podStrategy{}).
+		WithBackgroundDeleteCollection(strategy.BackgroundDeleteOptions{}).Build()
+	assert.IsType(t, &ReadWriteWatchDeleteCollectionStore{}, store)
+}
+
+func TestBackgroundDeleteJobs(t *testing.T) {
+	factory, err := db.NewFactory(scheme.Scheme, "sqlite://file:"+t.Name()+"?mode=memory&cache=shared")
+	require.NoError(t, err)
+	pods, err := factory.NewDBStrategy(&corev1.Pod{})
+	require.NoError(t, err)
+	t.Cleanup(pods.Destroy)
+
+	store := NewBuilder(scheme.Scheme, &corev1.Pod{}).
+		WithCompleteCRUD(pods).
+		WithBackgroundDeleteCollection(strategy.BackgroundDeleteOptions{BatchSize: 2}).Build()
+	jobs := store.(*ReadWriteWatchDeleteCollectionStore).DeleteJobs()
+
+	ctx := request.WithNamespace(context.Background(), "default")
+	for i := 0; i < 5; i++ {
+		_, err := store.(rest.Creater).Create(ctx, &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: fmt.Sprintf("pod-%d", i)},
+		}, nil, &metav1.CreateOptions{})
+		require.NoError(t, err)
+	}
+
+	result, err := store.(rest.CollectionDeleter).DeleteCollection(ctx, nil, nil, nil)
+	require.NoError(t, err)
+	id := string(result.(*metav1.Status).Details.UID)
+
+	var status *metav1.Status
+	assert.Eventually(t, func() bool {
+		obj, err := jobs.(rest.Getter).Get(ctx, id, &metav1.GetOptions{})
+		require.NoError(t, err)
+		status = obj.(*metav1.Status)
+		return status.Code == http.StatusOK
+	}, 10*time.Second, 10*time.Millisecond)
+	assert.Equal(t, metav1.StatusSuccess, status.Status)
+	assert.Equal(t, "finished, 5 deleted and 0 failed", status.Message)
+	assert.Empty(t, status.Details.Causes)
+
+	// jobs are only found in the namespace they were started in
+	_, err = jobs.(rest.Getter).Get(request.WithNamespace(context.Background(), "other"), id, &metav1.GetOptions{})
+	assert.True(t, apierrors.IsNotFound(err))
+	_, err = jobs.(rest.Getter).Get(ctx, "unknown", &metav1.GetOptions{})
+	assert.True(t, apierrors.IsNotFound(err))
+}
+
+func TestBackgroundDeleteDestroy(t *testing.T) {
+	factory, err := db.NewFactory(scheme.Scheme, "sqlite://file:"+t.Name()+"?mode=memory&cache=shared")
+	require.NoError(t, err)
+	t.Cleanup(factory.Destroy)
+	pods, err := factory.NewDBStrategy(&corev1.Pod{})
+	require.NoError(t, err)
+	t.Cleanup(pods.Destroy)
+
+	store := NewBuilder(scheme.Scheme, &corev1.Pod{}).
+		WithCompleteCRUD(pods).
+		WithBackgroundDeleteCollection(strategy.BackgroundDeleteOptions{
+			BatchSize:      1,
+			ItemsPerSecond: 1,
+			Retention:      50 * time.Millisecond,
+		}).Build().(*ReadWriteWatchDeleteCollectionStore)
+
+	ctx := request.WithNamespace(context.Background(), "default")
+	for i := 0; i < 5; i++ {
+		_, err := store.Create(ctx, &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: fmt.Sprintf("pod-%d", i)},
+		}, nil, &metav1.CreateOptions{})
+		require.NoError(t, err)
+	}
+
+	// jobs outlive the request that started them
+	reqCtx, cancel := context.WithCancel(ctx)
+	result, err := store.DeleteCollection(reqCtx, nil, nil, nil)
+	require.NoError(t, err)
+	cancel()
+	id := string(result.(*metav1.Status).Details.UID)
+	assert.Eventually(t, func() bool {
+		job, _ := store.Job(id)
+		return job.Deleted > 0
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// but not the store, which stops them and rejects new ones
+	destroyed := make(chan struct{})
+	go func() {
+		store.Destroy()
+		close(destroyed)
+	}()
+	select {
+	case <-destroyed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the jobs to stop")
+	}
+	job, ok := store.Job(id)
+	require.True(t, ok)
+	assert.True(t, job.Done())
+	assert.Less(t, job.Deleted, 5)
+	assert.Equal(t, 1, job.Failed)
+
+	_, err = store.DeleteCollection(ctx, nil, nil, nil)
+	assert.True(t, apierrors.IsServiceUnavailable(err), "expected service unavailable, got %v", err)
+
+	// finished jobs expire after the retention without new jobs being started
+	assert.Eventually(t, func() bool {
+		_, ok := store.Job(id)
+		return !ok && len(store.Jobs()) == 0
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestDecoratedFieldsAreNotPersisted(t *testing.T) {
+	factory, err := db.NewFactory(scheme.Scheme, "sqlite://file:"+t.Name()+"?mode=memory&cache=shared")
+	require.NoError(t, err)
+	pods, err := factory.NewDBStrategy(&corev1.Pod{})
+	require.NoError(t, err)
+	t.Cleanup(pods.Destroy)
+
+	const annotation = "example.com/url"
+	store := NewBuilder(scheme.Scheme, &corev1.Pod{}).
+		WithCompleteCRUD(pods).
+		WithDecorator(strategy.DecoratorFuncs{
+			DecorateFunc: func(_ context.Context, obj runtime.Object) {
+				pod := obj.(*corev1.Pod)
+				metav1.SetMetaDataAnnotation(&pod.ObjectMeta, annotation, "https://example.com/"+pod.Name)
+			},
+			UndecorateFunc: func(_ context.Context, obj runtime.Object) {
+				delete(obj.(*corev1.Pod).Annotations, annotation)
+			},
+		}).Build()
+
+	ctx := request.WithNamespace(context.Background(), "default")
+	_, err = store.(rest.Creater).Create(ctx, &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "pod",
+			Annotations: map[string]string{annotation: "sent by the client"},
+		},
+	}, nil, &metav1.CreateOptions{})
+	require.NoError(t, err)
+	stored, err := pods.Get(ctx, "default", "pod")
+	require.NoError(t, err)
+	assert.NotContains(t, stored.GetAnnotations(), annotation)
+
+	// a decorated object read and written back keeps the decoration out of storage
+	obj, err := store.(rest.Getter).Get(ctx, "pod", &metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.com/pod", obj.(*corev1.Pod).Annotations[annotation])
+	obj.(*corev1.Pod).Labels = map[string]string{"updated": "true"}
+	_, _, err = store.(rest.Updater).Update(ctx, "pod", rest.DefaultUpdatedObjectInfo(obj), nil, nil, false, &metav1.UpdateOptions{})
+	require.NoError(t, err)
+
+	stored, err = pods.Get(ctx, "default", "pod")
+	require.NoError(t, err)
+	assert.Equal(t, "true", stored.GetLabels()["updated"])
+	assert.NotContains(t, stored.GetAnnotations(), annotation)
+}
@@ -18,7 +18,7 @@ func NewComplete(scheme *runtime.Scheme, s strategy.CompleteStrategy) rest.Stora
 type Complete struct {
 	*strategy.SingularNameAdapter
 	*strategy.CreateAdapter
-	*strategy.UpdateAdapter
+	*strategy.PatchAdapter
 	*strategy.GetAdapter
 	*strategy.ListAdapter
 	*strategy.DeleteAdapter
@@ -39,7 +39,7 @@ func newComplete(scheme *runtime.Scheme, s strategy.CompleteStrategy) (*Complete
 	return &Complete{
 		SingularNameAdapter: strategy.NewSingularNameAdapter(s.New(), scheme),
 		CreateAdapter:       strategy.NewCreate(scheme, s),
-		UpdateAdapter:       strategy.NewUpdate(scheme, s),
+		PatchAdapter:        strategy.NewPatch(scheme, s),
 		GetAdapter:          strategy.NewGet(s),
 		ListAdapter:         strategy.NewList(s),
 		DeleteAdapter:       strategy.NewDelete(scheme, s),
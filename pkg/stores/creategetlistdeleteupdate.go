@@ -21,7 +21,7 @@ type CreateGetListDeleteUpdateStore struct {
 	*strategy.ListAdapter
 	*strategy.DeleteAdapter
 	*strategy.DestroyAdapter
-	*strategy.UpdateAdapter
+	*strategy.PatchAdapter
 	*strategy.TableAdapter
 }
 
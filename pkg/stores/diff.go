@@ -0,0 +1,63 @@
+package stores
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/acorn-io/mink/pkg/strategy"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apiserver/pkg/registry/rest"
+)
+
+var _ rest.Connecter = (*Diff)(nil)
+
+// Diff is a read-only subresource returning the changes of an object between two resource versions, given by the
+// from and to query parameters. Without to, the diff is to the current version of the object.
+type Diff struct {
+	diff *strategy.DiffAdapter
+}
+
+func NewDiff(revisions strategy.RevisionGetter) rest.Storage {
+	return &Diff{
+		diff: strategy.NewDiff(revisions),
+	}
+}
+
+// NewDiffWithScrubber returns a Diff that scrubs both revisions before comparing them, like the scrubber of the
+// store of the resource.
+func NewDiffWithScrubber(revisions strategy.RevisionGetter, scrubber strategy.Scrubber) rest.Storage {
+	diff := strategy.NewDiff(revisions)
+	diff.Scrubber = scrubber
+	return &Diff{
+		diff: diff,
+	}
+}
+
+func (d *Diff) New() runtime.Object {
+	return d.diff.New()
+}
+
+func (d *Diff) Destroy() {
+}
+
+func (d *Diff) ConnectMethods() []string {
+	return []string{http.MethodGet}
+}
+
+func (d *Diff) NewConnectOptions() (runtime.Object, bool, string) {
+	return nil, false, ""
+}
+
+func (d *Diff) Connect(ctx context.Context, id string, _ runtime.Object, r rest.Responder) (http.Handler, error) {
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		query := req.URL.Query()
+		diff, err := d.diff.Diff(ctx, id, query.Get("from"), query.Get("to"))
+		if err != nil {
+			r.Error(err)
+			return
+		}
+		rw.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(rw).Encode(diff)
+	}), nil
+}
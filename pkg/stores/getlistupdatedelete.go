@@ -14,9 +14,10 @@ var (
 )
 
 type GetListUpdateDeleteStore struct {
+	noCreate
 	*strategy.SingularNameAdapter
 	*strategy.GetAdapter
-	*strategy.UpdateAdapter
+	*strategy.PatchAdapter
 	*strategy.ListAdapter
 	*strategy.DeleteAdapter
 	*strategy.DestroyAdapter
@@ -15,9 +15,10 @@ var (
 )
 
 type GetListUpdateDeleteWatchStore struct {
+	noCreate
 	*strategy.SingularNameAdapter
 	*strategy.GetAdapter
-	*strategy.UpdateAdapter
+	*strategy.PatchAdapter
 	*strategy.ListAdapter
 	*strategy.DeleteAdapter
 	*strategy.WatchAdapter
@@ -0,0 +1,76 @@
+package stores
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/acorn-io/mink/pkg/strategy"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apiserver/pkg/registry/rest"
+)
+
+// maxMergeBodySize is the size limit of the object posted to Merge, the same as the limit of the API server.
+const maxMergeBodySize = 3 * 1024 * 1024
+
+var _ rest.Connecter = (*Merge)(nil)
+
+// Merge is a subresource doing a three-way merge of the object posted to it, a client's modification of the revision
+// given by the base query parameter, with the current version of the object. It returns the merged object, and 409
+// Conflict with the conflicting fields if the client and another writer changed the same field differently.
+type Merge struct {
+	merge *strategy.MergeAdapter
+}
+
+func NewMerge(revisions strategy.RevisionGetter) rest.Storage {
+	return &Merge{
+		merge: strategy.NewMerge(revisions),
+	}
+}
+
+// NewMergeWithScrubber returns a Merge that scrubs the revisions before merging them, like the scrubber of the store
+// of the resource.
+func NewMergeWithScrubber(revisions strategy.RevisionGetter, scrubber strategy.Scrubber) rest.Storage {
+	merge := strategy.NewMerge(revisions)
+	merge.Scrubber = scrubber
+	return &Merge{
+		merge: merge,
+	}
+}
+
+func (m *Merge) New() runtime.Object {
+	return m.merge.New()
+}
+
+func (m *Merge) Destroy() {
+}
+
+func (m *Merge) ConnectMethods() []string {
+	return []string{http.MethodPost}
+}
+
+func (m *Merge) NewConnectOptions() (runtime.Object, bool, string) {
+	return nil, false, ""
+}
+
+func (m *Merge) Connect(ctx context.Context, id string, _ runtime.Object, r rest.Responder) (http.Handler, error) {
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		modified := m.merge.New()
+		if err := json.NewDecoder(http.MaxBytesReader(rw, req.Body, maxMergeBodySize)).Decode(modified); err != nil {
+			r.Error(apierrors.NewBadRequest("invalid object: " + err.Error()))
+			return
+		}
+
+		result, err := m.merge.Merge(ctx, id, req.URL.Query().Get("base"), modified)
+		if err != nil {
+			r.Error(err)
+			return
+		}
+		rw.Header().Set("Content-Type", "application/json")
+		if len(result.Conflicts) > 0 {
+			rw.WriteHeader(http.StatusConflict)
+		}
+		_ = json.NewEncoder(rw).Encode(result)
+	}), nil
+}
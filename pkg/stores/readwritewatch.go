@@ -20,7 +20,7 @@ type ReadWriteWatchStore struct {
 	*strategy.CreateAdapter
 	*strategy.GetAdapter
 	*strategy.ListAdapter
-	*strategy.UpdateAdapter
+	*strategy.PatchAdapter
 	*strategy.DeleteAdapter
 	*strategy.WatchAdapter
 	*strategy.DestroyAdapter
@@ -0,0 +1,46 @@
+package stores
+
+import (
+	"github.com/acorn-io/mink/pkg/strategy"
+	"k8s.io/apiserver/pkg/registry/rest"
+)
+
+var (
+	_ rest.Getter             = (*ReadWriteWatchDeleteCollectionStore)(nil)
+	_ rest.Lister             = (*ReadWriteWatchDeleteCollectionStore)(nil)
+	_ rest.Updater            = (*ReadWriteWatchDeleteCollectionStore)(nil)
+	_ rest.Watcher            = (*ReadWriteWatchDeleteCollectionStore)(nil)
+	_ rest.Creater            = (*ReadWriteWatchDeleteCollectionStore)(nil)
+	_ rest.CollectionDeleter  = (*ReadWriteWatchDeleteCollectionStore)(nil)
+	_ rest.RESTDeleteStrategy = (*ReadWriteWatchDeleteCollectionStore)(nil)
+	_ strategy.Base           = (*ReadWriteWatchDeleteCollectionStore)(nil)
+)
+
+type ReadWriteWatchDeleteCollectionStore struct {
+	*strategy.SingularNameAdapter
+	*strategy.CreateAdapter
+	*strategy.GetAdapter
+	*strategy.ListAdapter
+	*strategy.PatchAdapter
+	*strategy.DeleteAdapter
+	*strategy.WatchAdapter
+	*strategy.BackgroundDeleteAdapter
+	*strategy.DestroyAdapter
+	*strategy.TableAdapter
+}
+
+func (r *ReadWriteWatchDeleteCollectionStore) NamespaceScoped() bool {
+	return r.ListAdapter.NamespaceScoped()
+}
+
+// Destroy stops the running delete jobs before destroying the strategy they delete from.
+func (r *ReadWriteWatchDeleteCollectionStore) Destroy() {
+	r.BackgroundDeleteAdapter.Destroy()
+	r.DestroyAdapter.Destroy()
+}
+
+// DeleteJobs returns the storage of the status of the deletecollection jobs of the store, to be installed as a
+// subresource of it, such as pods/deletejobs, see strategy.DeleteJobs.
+func (r *ReadWriteWatchDeleteCollectionStore) DeleteJobs() rest.Storage {
+	return strategy.NewDeleteJobs(r.BackgroundDeleteAdapter)
+}
@@ -0,0 +1,34 @@
+package stores
+
+import (
+	"github.com/acorn-io/mink/pkg/strategy"
+	"k8s.io/apiserver/pkg/registry/rest"
+)
+
+var (
+	_ rest.Getter             = (*ReadWriteWatchSyncDeleteCollectionStore)(nil)
+	_ rest.Lister             = (*ReadWriteWatchSyncDeleteCollectionStore)(nil)
+	_ rest.Updater            = (*ReadWriteWatchSyncDeleteCollectionStore)(nil)
+	_ rest.Watcher            = (*ReadWriteWatchSyncDeleteCollectionStore)(nil)
+	_ rest.Creater            = (*ReadWriteWatchSyncDeleteCollectionStore)(nil)
+	_ rest.CollectionDeleter  = (*ReadWriteWatchSyncDeleteCollectionStore)(nil)
+	_ rest.RESTDeleteStrategy = (*ReadWriteWatchSyncDeleteCollectionStore)(nil)
+	_ strategy.Base           = (*ReadWriteWatchSyncDeleteCollectionStore)(nil)
+)
+
+type ReadWriteWatchSyncDeleteCollectionStore struct {
+	*strategy.SingularNameAdapter
+	*strategy.CreateAdapter
+	*strategy.GetAdapter
+	*strategy.ListAdapter
+	*strategy.PatchAdapter
+	*strategy.DeleteAdapter
+	*strategy.WatchAdapter
+	*strategy.DeleteCollectionAdapter
+	*strategy.DestroyAdapter
+	*strategy.TableAdapter
+}
+
+func (r *ReadWriteWatchSyncDeleteCollectionStore) NamespaceScoped() bool {
+	return r.ListAdapter.NamespaceScoped()
+}
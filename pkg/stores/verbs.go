@@ -0,0 +1,77 @@
+package stores
+
+import (
+	"slices"
+
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apiserver/pkg/registry/rest"
+)
+
+// noCreate hides the Create method that the UpdateAdapter inherits from its CreateAdapter. Stores that serve update
+// but not create embed it, otherwise the API server finds a rest.Creater and advertises and serves create.
+type noCreate struct{}
+
+func (noCreate) Create() {}
+
+// ServedVerbs returns the verbs of the store built by the builder, which are the verbs published in discovery.
+func (b Builder) ServedVerbs() []string {
+	b.restrictVerbs()
+
+	verbs := sets.New[string]()
+	if b.Get != nil {
+		verbs.Insert("get")
+	}
+	if b.List != nil {
+		verbs.Insert("list")
+	}
+	if b.Watch != nil {
+		verbs.Insert("watch")
+	}
+	if b.Create != nil {
+		verbs.Insert("create")
+	}
+	if b.Update != nil {
+		verbs.Insert("update", "patch")
+	}
+	if b.Delete != nil {
+		verbs.Insert("delete")
+	}
+	if b.DeleteCollection != nil || b.BackgroundDelete != nil {
+		verbs.Insert("deletecollection")
+	}
+	return sets.List(verbs)
+}
+
+// storeVerbs returns the verbs the API server derives from the interfaces the store implements.
+func storeVerbs(store rest.Storage) []string {
+	verbs := sets.New[string]()
+	if _, ok := store.(rest.Getter); ok {
+		verbs.Insert("get")
+	}
+	if _, ok := store.(rest.Lister); ok {
+		verbs.Insert("list")
+	}
+	if _, ok := store.(rest.Watcher); ok {
+		verbs.Insert("watch")
+	}
+	if _, ok := store.(rest.Creater); ok {
+		verbs.Insert("create")
+	}
+	if _, ok := store.(rest.Updater); ok {
+		verbs.Insert("update")
+	}
+	if _, ok := store.(rest.Patcher); ok {
+		verbs.Insert("patch")
+	}
+	if _, ok := store.(rest.GracefulDeleter); ok {
+		verbs.Insert("delete")
+	}
+	if _, ok := store.(rest.CollectionDeleter); ok {
+		verbs.Insert("deletecollection")
+	}
+	return sets.List(verbs)
+}
+
+func verbsMatch(store rest.Storage, expected []string) bool {
+	return slices.Equal(storeVerbs(store), expected)
+}
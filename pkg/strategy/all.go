@@ -9,6 +9,7 @@ type CompleteStrategy interface {
 	Getter
 	Lister
 	Deleter
+	CollectionDeleter
 	Watcher
 
 	Destroy()
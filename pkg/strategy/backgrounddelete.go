@@ -0,0 +1,380 @@
+package strategy
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metainternalversion "k8s.io/apimachinery/pkg/apis/meta/internalversion"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	ktypes "k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/uuid"
+	"k8s.io/apiserver/pkg/endpoints/request"
+	"k8s.io/apiserver/pkg/registry/rest"
+)
+
+const (
+	defaultBackgroundDeleteBatchSize = 100
+	defaultBackgroundDeleteRetention = time.Hour
+	maxDeleteJobErrors               = 10
+)
+
+var (
+	_ rest.CollectionDeleter = (*BackgroundDeleteAdapter)(nil)
+	_ rest.Getter            = (*DeleteJobs)(nil)
+	_ rest.Scoper            = (*DeleteJobs)(nil)
+
+	_ rest.GroupVersionKindProvider = (*DeleteJobs)(nil)
+)
+
+// DeleteJobFailedCause is the type of the causes of a DeleteJobs status, one per object that failed to be deleted.
+const DeleteJobFailedCause metav1.CauseType = "DeleteFailed"
+
+// BackgroundDeleteOptions controls how a BackgroundDeleteAdapter works through a collection.
+type BackgroundDeleteOptions struct {
+	// BatchSize is the number of objects listed and deleted per batch. If zero, 100 is used.
+	BatchSize int64
+	// ItemsPerSecond limits the rate at which objects are deleted. If zero, deletion is not rate limited.
+	ItemsPerSecond float64
+	// Retention is how long the status of a finished job is kept. If zero, one hour is used.
+	Retention time.Duration
+}
+
+// DeleteJobStatus reports the progress of a single background collection deletion.
+type DeleteJobStatus struct {
+	ID        string
+	Namespace string
+	Deleted   int
+	Failed    int
+	Errors    []string
+	Started   time.Time
+	Finished  *time.Time
+}
+
+// Done returns true if the job has finished processing the collection.
+func (d DeleteJobStatus) Done() bool {
+	return d.Finished != nil
+}
+
+// BackgroundDeleteAdapter implements rest.CollectionDeleter by acknowledging the request immediately and
+// deleting the matching objects in rate limited batches from a background goroutine. Each object is deleted
+// through the DeleteAdapter so validation, preconditions and finalizers behave as they do for a single delete.
+//
+// Jobs outlive the request that started them but not the adapter, Destroy, which the API server calls when it
+// shuts down, stops the jobs still running.
+type BackgroundDeleteAdapter struct {
+	lister  *ListAdapter
+	deleter *DeleteAdapter
+	opts    BackgroundDeleteOptions
+
+	// ctx is canceled by Destroy, running tracks the jobs that haven't returned yet.
+	ctx     context.Context
+	cancel  context.CancelFunc
+	running sync.WaitGroup
+
+	jobsLock sync.Mutex
+	jobs     map[string]*DeleteJobStatus
+}
+
+func NewBackgroundDelete(lister *ListAdapter, deleter *DeleteAdapter, opts BackgroundDeleteOptions) *BackgroundDeleteAdapter {
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = defaultBackgroundDeleteBatchSize
+	}
+	if opts.Retention <= 0 {
+		opts.Retention = defaultBackgroundDeleteRetention
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	return &BackgroundDeleteAdapter{
+		lister:  lister,
+		deleter: deleter,
+		opts:    opts,
+		ctx:     ctx,
+		cancel:  cancel,
+		jobs:    map[string]*DeleteJobStatus{},
+	}
+}
+
+// Destroy stops the running jobs and waits for them to return. Later deletecollection requests are rejected.
+func (a *BackgroundDeleteAdapter) Destroy() {
+	a.jobsLock.Lock()
+	a.cancel()
+	a.jobsLock.Unlock()
+	a.running.Wait()
+}
+
+// Job returns the status of the job with the given ID. Jobs are forgotten once they have been finished for
+// longer than the configured retention.
+func (a *BackgroundDeleteAdapter) Job(id string) (DeleteJobStatus, bool) {
+	a.jobsLock.Lock()
+	defer a.jobsLock.Unlock()
+	a.expireJobs(time.Now())
+	job, ok := a.jobs[id]
+	if !ok {
+		return DeleteJobStatus{}, false
+	}
+	return copyJob(job), true
+}
+
+// Jobs returns the status of all known jobs, oldest first.
+func (a *BackgroundDeleteAdapter) Jobs() []DeleteJobStatus {
+	a.jobsLock.Lock()
+	defer a.jobsLock.Unlock()
+	a.expireJobs(time.Now())
+	result := make([]DeleteJobStatus, 0, len(a.jobs))
+	for _, job := range a.jobs {
+		result = append(result, copyJob(job))
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Started.Before(result[j].Started)
+	})
+	return result
+}
+
+func (a *BackgroundDeleteAdapter) DeleteCollection(ctx context.Context, deleteValidation rest.ValidateObjectFunc, options *metav1.DeleteOptions, listOptions *metainternalversion.ListOptions) (runtime.Object, error) {
+	if options == nil {
+		options = metav1.NewDeleteOptions(0)
+	} else {
+		options = options.DeepCopy()
+	}
+	if listOptions == nil {
+		listOptions = &metainternalversion.ListOptions{}
+	} else {
+		listOptions = listOptions.DeepCopy()
+	}
+
+	if len(options.DryRun) != 0 && options.DryRun[0] == metav1.DryRunAll {
+		return &metav1.Status{
+			Status: metav1.StatusSuccess,
+			Code:   http.StatusOK,
+		}, nil
+	}
+
+	ns, _ := request.NamespaceFrom(ctx)
+	job, ok := a.newJob(ns)
+	if !ok {
+		return nil, apierrors.NewServiceUnavailable("the server is shutting down")
+	}
+
+	// The request context is canceled as soon as the response is written, so the job keeps only its values and is
+	// canceled with the adapter instead.
+	jobCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	stop := context.AfterFunc(a.ctx, cancel)
+	go func() {
+		defer a.running.Done()
+		defer stop()
+		defer cancel()
+		a.run(jobCtx, job, deleteValidation, options, listOptions)
+	}()
+
+	details := &metav1.StatusDetails{
+		UID: ktypes.UID(job.ID),
+	}
+	if info, ok := request.RequestInfoFrom(ctx); ok {
+		details.Group = info.APIGroup
+		details.Kind = info.Resource
+	}
+
+	return &metav1.Status{
+		Status:  metav1.StatusSuccess,
+		Code:    http.StatusAccepted,
+		Message: fmt.Sprintf("deletion of collection scheduled as job %s", job.ID),
+		Details: details,
+	}, nil
+}
+
+// newJob registers a running job, it returns false once the adapter is destroyed.
+func (a *BackgroundDeleteAdapter) newJob(namespace string) (*DeleteJobStatus, bool) {
+	a.jobsLock.Lock()
+	defer a.jobsLock.Unlock()
+	if a.ctx.Err() != nil {
+		return nil, false
+	}
+
+	now := time.Now()
+	a.expireJobs(now)
+
+	job := &DeleteJobStatus{
+		ID:        string(uuid.NewUUID()),
+		Namespace: namespace,
+		Started:   now,
+	}
+	a.jobs[job.ID] = job
+	a.running.Add(1)
+	return job, true
+}
+
+// expireJobs forgets the jobs finished for longer than the retention, the caller must hold jobsLock.
+func (a *BackgroundDeleteAdapter) expireJobs(now time.Time) {
+	for id, job := range a.jobs {
+		if job.Finished != nil && now.Sub(*job.Finished) > a.opts.Retention {
+			delete(a.jobs, id)
+		}
+	}
+}
+
+func (a *BackgroundDeleteAdapter) run(ctx context.Context, job *DeleteJobStatus, deleteValidation rest.ValidateObjectFunc, options *metav1.DeleteOptions, listOptions *metainternalversion.ListOptions) {
+	defer func() {
+		a.jobsLock.Lock()
+		now := time.Now()
+		job.Finished = &now
+		a.jobsLock.Unlock()
+	}()
+
+	var (
+		limiter *rate.Limiter
+		// objects that are updated rather than removed (finalizers) show up again on later pages
+		seen = map[ktypes.UID]bool{}
+	)
+	if a.opts.ItemsPerSecond > 0 {
+		limiter = rate.NewLimiter(rate.Limit(a.opts.ItemsPerSecond), int(a.opts.BatchSize))
+	}
+
+	for {
+		batchOptions := listOptions.DeepCopy()
+		batchOptions.Limit = a.opts.BatchSize
+
+		list, err := a.lister.List(ctx, batchOptions)
+		if err != nil {
+			a.recordError(job, err)
+			return
+		}
+
+		items, err := meta.ExtractList(list)
+		if err != nil {
+			a.recordError(job, err)
+			return
+		}
+
+		for _, item := range items {
+			m, err := meta.Accessor(item)
+			if err != nil {
+				a.recordError(job, err)
+				continue
+			}
+			if seen[m.GetUID()] {
+				continue
+			}
+			seen[m.GetUID()] = true
+
+			if limiter != nil {
+				if err := limiter.Wait(ctx); err != nil {
+					a.recordError(job, err)
+					return
+				}
+			}
+
+			itemCtx := request.WithNamespace(ctx, m.GetNamespace())
+			if _, _, err := a.deleter.Delete(itemCtx, m.GetName(), deleteValidation, options); err != nil && !apierrors.IsNotFound(err) {
+				a.recordError(job, fmt.Errorf("deleting %s/%s: %w", m.GetNamespace(), m.GetName(), err))
+				continue
+			}
+
+			a.jobsLock.Lock()
+			job.Deleted++
+			a.jobsLock.Unlock()
+		}
+
+		listMeta, err := meta.ListAccessor(list)
+		if err != nil {
+			a.recordError(job, err)
+			return
+		}
+		if listMeta.GetContinue() == "" {
+			return
+		}
+		listOptions.Continue = listMeta.GetContinue()
+	}
+}
+
+func (a *BackgroundDeleteAdapter) recordError(job *DeleteJobStatus, err error) {
+	a.jobsLock.Lock()
+	defer a.jobsLock.Unlock()
+	job.Failed++
+	if len(job.Errors) < maxDeleteJobErrors {
+		job.Errors = append(job.Errors, err.Error())
+	}
+}
+
+// DeleteJobs serves the status of the jobs of a BackgroundDeleteAdapter as a subresource of the collection, such as
+// pods/deletejobs, named by the ID of the job the deletecollection request returned as the UID of its details:
+//
+//	GET /api/v1/namespaces/default/pods/<job ID>/deletejobs
+//
+// The status is a metav1.Status with code 202 while the job runs and 200 once it finished. Its message counts the
+// deleted and failed objects, its causes are the first errors. Jobs are only found in the namespace they were
+// started in.
+type DeleteJobs struct {
+	adapter *BackgroundDeleteAdapter
+}
+
+func NewDeleteJobs(adapter *BackgroundDeleteAdapter) *DeleteJobs {
+	return &DeleteJobs{
+		adapter: adapter,
+	}
+}
+
+func (d *DeleteJobs) New() runtime.Object {
+	return &metav1.Status{}
+}
+
+func (d *DeleteJobs) Destroy() {
+}
+
+// GroupVersionKind returns the kind of metav1.Status, which isn't registered in the group of the collection.
+func (d *DeleteJobs) GroupVersionKind(schema.GroupVersion) schema.GroupVersionKind {
+	return metav1.SchemeGroupVersion.WithKind("Status")
+}
+
+func (d *DeleteJobs) NamespaceScoped() bool {
+	return d.adapter.lister.NamespaceScoped()
+}
+
+func (d *DeleteJobs) Get(ctx context.Context, name string, _ *metav1.GetOptions) (runtime.Object, error) {
+	ns, _ := request.NamespaceFrom(ctx)
+	job, ok := d.adapter.Job(name)
+	if !ok || job.Namespace != ns {
+		resource := schema.GroupResource{Resource: "deletejobs"}
+		if info, ok := request.RequestInfoFrom(ctx); ok {
+			resource = schema.GroupResource{Group: info.APIGroup, Resource: info.Resource + "/" + info.Subresource}
+		}
+		return nil, apierrors.NewNotFound(resource, name)
+	}
+
+	status := &metav1.Status{
+		Status:  metav1.StatusSuccess,
+		Code:    http.StatusAccepted,
+		Message: fmt.Sprintf("deleting, %d deleted and %d failed so far", job.Deleted, job.Failed),
+		Details: &metav1.StatusDetails{
+			UID: ktypes.UID(job.ID),
+		},
+	}
+	if job.Done() {
+		status.Code = http.StatusOK
+		status.Message = fmt.Sprintf("finished, %d deleted and %d failed", job.Deleted, job.Failed)
+	}
+	if info, ok := request.RequestInfoFrom(ctx); ok {
+		status.Details.Group = info.APIGroup
+		status.Details.Kind = info.Resource
+	}
+	for _, err := range job.Errors {
+		status.Details.Causes = append(status.Details.Causes, metav1.StatusCause{
+			Type:    DeleteJobFailedCause,
+			Message: err,
+		})
+	}
+	return status, nil
+}
+
+func copyJob(job *DeleteJobStatus) DeleteJobStatus {
+	result := *job
+	result.Errors = append([]string(nil), job.Errors...)
+	return result
+}
@@ -0,0 +1,86 @@
+package strategy
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/watch"
+)
+
+type coalesceDelayKey struct{}
+
+// WithWatchCoalesceDelay overrides the coalesce delay of the WatchAdapter for watches started with the returned
+// context. A delay of zero disables coalescing for the watch.
+func WithWatchCoalesceDelay(ctx context.Context, delay time.Duration) context.Context {
+	return context.WithValue(ctx, coalesceDelayKey{}, delay)
+}
+
+func coalesceDelay(ctx context.Context, def time.Duration) time.Duration {
+	if delay, ok := ctx.Value(coalesceDelayKey{}).(time.Duration); ok {
+		return delay
+	}
+	return def
+}
+
+// coalesceEvents holds Modified events back for up to delay and collapses events for the same object into the
+// latest one. Any other event sends the held back events first, so events are never reordered relative to each
+// other and additions, deletions, bookmarks and errors are not delayed.
+func coalesceEvents(delay time.Duration, c <-chan watch.Event) <-chan watch.Event {
+	if delay <= 0 {
+		return c
+	}
+	result := make(chan watch.Event)
+	go func() {
+		defer close(result)
+
+		var (
+			pending []watch.Event
+			timer   <-chan time.Time
+		)
+		flush := func() {
+			for _, event := range pending {
+				result <- event
+			}
+			pending = nil
+			timer = nil
+		}
+
+		for {
+			select {
+			case event, ok := <-c:
+				if !ok {
+					flush()
+					return
+				}
+				key, keyed := eventKey(event)
+				if event.Type != watch.Modified || !keyed {
+					flush()
+					result <- event
+					continue
+				}
+				// The newer event moves to the end so resource versions stay in order.
+				pending = slices.DeleteFunc(pending, func(e watch.Event) bool {
+					k, _ := eventKey(e)
+					return k == key
+				})
+				pending = append(pending, event)
+				if timer == nil {
+					timer = time.After(delay)
+				}
+			case <-timer:
+				flush()
+			}
+		}
+	}()
+	return result
+}
+
+func eventKey(event watch.Event) (string, bool) {
+	m, err := meta.Accessor(event.Object)
+	if err != nil {
+		return "", false
+	}
+	return m.GetNamespace() + "/" + m.GetName(), true
+}
@@ -0,0 +1,59 @@
+package strategy
+
+import (
+	"sync"
+)
+
+// defaultMinParallelItems is the default ListConcurrency.MinItems.
+const defaultMinParallelItems = 100
+
+// ListConcurrency transforms the items of large lists in parallel, for translators and decorators that are CPU bound.
+// The items keep their order.
+type ListConcurrency struct {
+	// Workers is the number of goroutines the items are split between, lists are transformed serially if it is less
+	// than two.
+	Workers int
+	// MinItems is the length from which lists are transformed in parallel, 100 if zero. Shorter lists aren't worth
+	// the goroutines.
+	MinItems int
+}
+
+// Chunks returns the number of contiguous ranges Run splits n items into.
+func (c ListConcurrency) Chunks(n int) int {
+	minItems := c.MinItems
+	if minItems == 0 {
+		minItems = defaultMinParallelItems
+	}
+	if c.Workers < 2 || n < minItems {
+		return 1
+	}
+	return min(c.Workers, n)
+}
+
+// Run calls fn with the chunk index and the bounds of every range of the n items, each range on its own goroutine,
+// and returns the first error.
+func (c ListConcurrency) Run(n int, fn func(chunk, start, end int) error) error {
+	chunks := c.Chunks(n)
+	if chunks == 1 {
+		return fn(0, 0, n)
+	}
+
+	var (
+		wg   sync.WaitGroup
+		errs = make([]error, chunks)
+	)
+	for i := 0; i < chunks; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = fn(i, i*n/chunks, (i+1)*n/chunks)
+		}(i)
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+package strategy
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func TestListConcurrencyRun(t *testing.T) {
+	c := ListConcurrency{Workers: 4, MinItems: 10}
+	assert.Equal(t, 1, c.Chunks(9))
+	assert.Equal(t, 4, c.Chunks(10))
+	assert.Equal(t, 1, ListConcurrency{Workers: 4}.Chunks(99))
+	assert.Equal(t, 1, ListConcurrency{Workers: 1, MinItems: 1}.Chunks(10))
+
+	// the ranges cover the items in order, without gaps
+	var (
+		lock   sync.Mutex
+		ranges = map[int][2]int{}
+	)
+	assert.NoError(t, c.Run(10, func(chunk, start, end int) error {
+		lock.Lock()
+		defer lock.Unlock()
+		ranges[chunk] = [2]int{start, end}
+		return nil
+	}))
+	assert.Equal(t, map[int][2]int{0: {0, 2}, 1: {2, 5}, 2: {5, 7}, 3: {7, 10}}, ranges)
+
+	// the error of the earliest failing range is returned
+	err := c.Run(10, func(chunk, _, _ int) error {
+		if chunk == 0 {
+			time.Sleep(10 * time.Millisecond)
+		}
+		if chunk%2 == 0 {
+			return errors.New("chunk " + strconv.Itoa(chunk))
+		}
+		return nil
+	})
+	assert.EqualError(t, err, "chunk 0")
+}
+
+func TestDecorateListConcurrency(t *testing.T) {
+	list := &corev1.ConfigMapList{}
+	for i := 0; i < 200; i++ {
+		list.Items = append(list.Items, corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: strconv.Itoa(i)}})
+	}
+
+	var (
+		lock                  sync.Mutex
+		inFlight, maxInFlight int
+	)
+	decorator := DecoratorFunc(func(_ context.Context, obj runtime.Object) {
+		lock.Lock()
+		inFlight++
+		maxInFlight = max(maxInFlight, inFlight)
+		lock.Unlock()
+		defer func() {
+			lock.Lock()
+			inFlight--
+			lock.Unlock()
+		}()
+		time.Sleep(time.Millisecond)
+		cm := obj.(*corev1.ConfigMap)
+		cm.Data = map[string]string{"decorated": cm.Name}
+	})
+
+	if err := decorateList(context.Background(), decorator, ListConcurrency{Workers: 4, MinItems: 100}, list); err != nil {
+		t.Fatal(err)
+	}
+	assert.Greater(t, maxInFlight, 1)
+	assert.LessOrEqual(t, maxInFlight, 4)
+	assert.Len(t, list.Items, 200)
+	for i, item := range list.Items {
+		assert.Equal(t, strconv.Itoa(i), item.Name)
+		assert.Equal(t, item.Name, item.Data["decorated"])
+	}
+
+	// lists that aren't lists fail
+	assert.Error(t, decorateList(context.Background(), decorator, ListConcurrency{}, &corev1.ConfigMap{}))
+}
@@ -65,6 +65,9 @@ type CreateAdapter struct {
 	Validator         Validator
 	NameValidator     NameValidator
 	PrepareForCreater PrepareForCreator
+	NamespaceScoper   NamespaceScoper
+	// Decorator, if it is an Undecorator, removes the decorated fields from the objects written, see Undecorator.
+	Decorator Decorator
 }
 
 func (a *CreateAdapter) New() runtime.Object {
@@ -108,6 +111,7 @@ func (a *CreateAdapter) Create(ctx context.Context, obj runtime.Object, createVa
 }
 
 func (a *CreateAdapter) PrepareForCreate(ctx context.Context, obj runtime.Object) {
+	undecorate(ctx, getDecorator(a.Decorator, a.strategy), obj)
 	if a.PrepareForCreater != nil {
 		a.PrepareForCreater.PrepareForCreate(ctx, obj)
 	} else if o, ok := a.strategy.(PrepareForCreator); ok {
@@ -158,11 +162,5 @@ func (a *CreateAdapter) Canonicalize(obj runtime.Object) {
 }
 
 func (a *CreateAdapter) NamespaceScoped() bool {
-	if o, ok := a.strategy.(NamespaceScoper); ok {
-		return o.NamespaceScoped()
-	}
-	if o, ok := a.strategy.New().(NamespaceScoper); ok {
-		return o.NamespaceScoped()
-	}
-	return true
+	return namespaceScoped(a.NamespaceScoper, a.strategy)
 }
@@ -0,0 +1,95 @@
+package strategy
+
+import (
+	"context"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+)
+
+// Decorator sets derived fields, such as URLs computed from the object or details of the objects it references, on
+// an object read from storage before it is returned by Get, List and Watch. Decorate may modify obj in place. It runs
+// before the Scrubber, so it can use the fields the Scrubber removes.
+//
+// Clients that write back an object they read, such as with a Get followed by an Update, send the fields back. They
+// are only kept out of storage, and don't have to be kept in sync with what they are derived from, if the Decorator
+// is also an Undecorator.
+type Decorator interface {
+	Decorate(ctx context.Context, obj runtime.Object)
+}
+
+// Undecorator removes the fields a Decorator sets from the objects written by Create, Update and Patch, before they
+// are validated and stored. Undecorate may modify obj in place.
+type Undecorator interface {
+	Undecorate(ctx context.Context, obj runtime.Object)
+}
+
+type DecoratorFunc func(ctx context.Context, obj runtime.Object)
+
+func (d DecoratorFunc) Decorate(ctx context.Context, obj runtime.Object) {
+	d(ctx, obj)
+}
+
+// DecoratorFuncs is a Decorator and Undecorator made of a function each.
+type DecoratorFuncs struct {
+	DecorateFunc   func(ctx context.Context, obj runtime.Object)
+	UndecorateFunc func(ctx context.Context, obj runtime.Object)
+}
+
+func (d DecoratorFuncs) Decorate(ctx context.Context, obj runtime.Object) {
+	d.DecorateFunc(ctx, obj)
+}
+
+func (d DecoratorFuncs) Undecorate(ctx context.Context, obj runtime.Object) {
+	d.UndecorateFunc(ctx, obj)
+}
+
+func getDecorator(override Decorator, strategy any) Decorator {
+	if override != nil {
+		return override
+	}
+	if d, ok := strategy.(Decorator); ok {
+		return d
+	}
+	return nil
+}
+
+func undecorate(ctx context.Context, decorator Decorator, obj runtime.Object) {
+	if u, ok := decorator.(Undecorator); ok {
+		u.Undecorate(ctx, obj)
+	}
+}
+
+func decorateList(ctx context.Context, decorator Decorator, concurrency ListConcurrency, list runtime.Object) error {
+	if decorator == nil {
+		return nil
+	}
+	items, err := meta.ExtractList(list)
+	if err != nil {
+		return err
+	}
+	return concurrency.Run(len(items), func(_, start, end int) error {
+		for _, obj := range items[start:end] {
+			decorator.Decorate(ctx, obj)
+		}
+		return nil
+	})
+}
+
+func decorateEvents(ctx context.Context, decorator Decorator, c <-chan watch.Event) <-chan watch.Event {
+	if decorator == nil {
+		return c
+	}
+	result := make(chan watch.Event)
+	go func() {
+		defer close(result)
+		for event := range c {
+			if event.Type != watch.Error && event.Type != watch.Bookmark {
+				decorator.Decorate(ctx, event.Object)
+			}
+			result <- event
+		}
+	}()
+	return result
+}
@@ -57,24 +57,7 @@ func (a *DeleteAdapter) Delete(ctx context.Context, name string, deleteValidatio
 	if options == nil {
 		options = metav1.NewDeleteOptions(0)
 	}
-	var preconditions storage.Preconditions
-	if options.Preconditions != nil {
-		preconditions.UID = options.Preconditions.UID
-		preconditions.ResourceVersion = options.Preconditions.ResourceVersion
-		if err := preconditions.Check(name, obj); err != nil {
-			return nil, false, err
-		}
-	}
-
-	if deleteValidation != nil {
-		err = deleteValidation(ctx, obj)
-		if err != nil {
-			return nil, false, err
-		}
-	}
-
-	_, _, err = rest.BeforeDelete(a, ctx, obj, options)
-	if err != nil {
+	if err := a.check(ctx, obj, deleteValidation, options); err != nil {
 		return nil, false, err
 	}
 
@@ -83,10 +66,8 @@ func (a *DeleteAdapter) Delete(ctx context.Context, name string, deleteValidatio
 		return tObj, false, nil
 	}
 
-	if a.ValidateDeleter != nil {
-		if err := a.ValidateDeleter.ValidateDelete(ctx, tObj); err != nil {
-			return nil, false, err
-		}
+	if err := a.validateDelete(ctx, tObj); err != nil {
+		return nil, false, err
 	}
 
 	now := metav1.Now()
@@ -99,3 +80,42 @@ func (a *DeleteAdapter) Delete(ctx context.Context, name string, deleteValidatio
 	newObj, err := a.strategy.Delete(ctx, tObj)
 	return newObj, true, err
 }
+
+// check runs the preconditions of options and the validation of the API server on a delete of obj.
+func (a *DeleteAdapter) check(ctx context.Context, obj runtime.Object, deleteValidation rest.ValidateObjectFunc, options *metav1.DeleteOptions) error {
+	if options.Preconditions != nil {
+		preconditions := storage.Preconditions{
+			UID:             options.Preconditions.UID,
+			ResourceVersion: options.Preconditions.ResourceVersion,
+		}
+		if err := preconditions.Check(obj.(types.Object).GetName(), obj); err != nil {
+			return err
+		}
+	}
+
+	if deleteValidation != nil {
+		if err := deleteValidation(ctx, obj); err != nil {
+			return err
+		}
+	}
+
+	_, _, err := rest.BeforeDelete(a, ctx, obj, options)
+	return err
+}
+
+func (a *DeleteAdapter) validateDelete(ctx context.Context, obj types.Object) error {
+	if a.ValidateDeleter != nil {
+		if err := a.ValidateDeleter.ValidateDelete(ctx, obj); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validate runs every check of a delete of obj, which isn't being deleted yet.
+func (a *DeleteAdapter) validate(ctx context.Context, obj types.Object, deleteValidation rest.ValidateObjectFunc, options *metav1.DeleteOptions) error {
+	if err := a.check(ctx, obj, deleteValidation, options); err != nil {
+		return err
+	}
+	return a.validateDelete(ctx, obj)
+}
@@ -0,0 +1,171 @@
+package strategy
+
+import (
+	"context"
+
+	"github.com/acorn-io/mink/pkg/types"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metainternalversion "k8s.io/apimachinery/pkg/apis/meta/internalversion"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apiserver/pkg/endpoints/request"
+	"k8s.io/apiserver/pkg/registry/rest"
+	"k8s.io/apiserver/pkg/storage"
+)
+
+// DeleteCollectionOptions select the objects deleted by CollectionDeleter.DeleteCollection.
+type DeleteCollectionOptions struct {
+	storage.ListOptions
+	// Check is called with every matching object that isn't already being deleted, before its deletion timestamp is
+	// set. It returns false to leave the object alone, or an error to abort the deletion of the collection.
+	Check func(ctx context.Context, obj types.Object) (bool, error)
+}
+
+type CollectionDeleter interface {
+	Lister
+	Deleter
+
+	// DeleteCollection deletes the objects matching opts and returns them as they were deleted. Objects with
+	// finalizers are only marked as being deleted, as by Delete.
+	DeleteCollection(ctx context.Context, namespace string, opts DeleteCollectionOptions) (types.ObjectList, error)
+}
+
+// DeleteEach deletes the objects matching opts one at a time with the List and Delete methods of s, for strategies
+// that can't do better. It stops at the first error, leaving the objects before it deleted.
+func DeleteEach(ctx context.Context, s interface {
+	Lister
+	Deleter
+}, namespace string, opts DeleteCollectionOptions) (types.ObjectList, error) {
+	list, err := s.List(ctx, namespace, opts.ListOptions)
+	if err != nil {
+		return nil, err
+	}
+	items, err := meta.ExtractList(list)
+	if err != nil {
+		return nil, err
+	}
+
+	now := metav1.Now()
+	deleted := make([]runtime.Object, 0, len(items))
+	for _, item := range items {
+		obj := item.(types.Object)
+		if !obj.GetDeletionTimestamp().IsZero() {
+			continue
+		}
+		if opts.Check != nil {
+			if ok, err := opts.Check(ctx, obj); err != nil {
+				return nil, err
+			} else if !ok {
+				continue
+			}
+		}
+		obj.SetDeletionTimestamp(&now)
+		newObj, err := s.Delete(ctx, obj)
+		if apierrors.IsNotFound(err) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		if newObj != nil {
+			deleted = append(deleted, newObj)
+		}
+	}
+
+	result := s.NewList()
+	return result, meta.SetList(result, deleted)
+}
+
+var _ rest.CollectionDeleter = (*DeleteCollectionAdapter)(nil)
+
+// DeleteCollectionAdapter implements rest.CollectionDeleter with the DeleteCollection method of the strategy, the
+// matching objects are deleted before the request returns. Each object is validated as the DeleteAdapter validates
+// a single delete. See BackgroundDeleteAdapter for collections too large to delete in one request.
+type DeleteCollectionAdapter struct {
+	lister   *ListAdapter
+	deleter  *DeleteAdapter
+	strategy CollectionDeleter
+}
+
+func NewDeleteCollection(lister *ListAdapter, deleter *DeleteAdapter, strategy CollectionDeleter) *DeleteCollectionAdapter {
+	return &DeleteCollectionAdapter{
+		lister:   lister,
+		deleter:  deleter,
+		strategy: strategy,
+	}
+}
+
+func (a *DeleteCollectionAdapter) DeleteCollection(ctx context.Context, deleteValidation rest.ValidateObjectFunc, options *metav1.DeleteOptions, listOptions *metainternalversion.ListOptions) (runtime.Object, error) {
+	if options == nil {
+		options = metav1.NewDeleteOptions(0)
+	}
+	if listOptions == nil {
+		listOptions = &metainternalversion.ListOptions{}
+	}
+
+	label := labels.Everything()
+	if listOptions.LabelSelector != nil {
+		label = listOptions.LabelSelector
+	}
+	field := fields.Everything()
+	if listOptions.FieldSelector != nil {
+		field = listOptions.FieldSelector
+	}
+	scope := ListScopeFromContext(ctx)
+	p := a.lister.predicate(label, field)
+	p.Label = scope.labelSelector(p.Label)
+
+	if len(options.DryRun) != 0 && options.DryRun[0] == metav1.DryRunAll {
+		return a.dryRun(ctx, p, deleteValidation, options, listOptions)
+	}
+
+	ns, _ := request.NamespaceFrom(ctx)
+	list, err := a.strategy.DeleteCollection(ctx, ns, DeleteCollectionOptions{
+		ListOptions: storage.ListOptions{
+			ResourceVersion:      listOptions.ResourceVersion,
+			ResourceVersionMatch: listOptions.ResourceVersionMatch,
+			Predicate:            p,
+		},
+		Check: func(ctx context.Context, obj types.Object) (bool, error) {
+			if !scope.AllowsNamespace(obj.GetNamespace()) {
+				return false, nil
+			}
+			return true, a.deleter.validate(ctx, obj, deleteValidation, options)
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return list, scrubList(ctx, getScrubber(a.lister.Scrubber, a.lister.strategy), list)
+}
+
+// dryRun validates the deletion of the objects matching p and returns them without deleting them.
+func (a *DeleteCollectionAdapter) dryRun(ctx context.Context, p storage.SelectionPredicate, deleteValidation rest.ValidateObjectFunc, options *metav1.DeleteOptions, listOptions *metainternalversion.ListOptions) (runtime.Object, error) {
+	listOptions = listOptions.DeepCopy()
+	listOptions.Limit = 0
+	list, err := a.lister.ListPredicate(ctx, p, listOptions)
+	if err != nil {
+		return nil, err
+	}
+	items, err := meta.ExtractList(list)
+	if err != nil {
+		return nil, err
+	}
+
+	now := metav1.Now()
+	result := make([]runtime.Object, 0, len(items))
+	for _, item := range items {
+		obj := item.(types.Object)
+		if !obj.GetDeletionTimestamp().IsZero() {
+			continue
+		}
+		if err := a.deleter.validate(ctx, obj, deleteValidation, options); err != nil {
+			return nil, err
+		}
+		obj.SetDeletionTimestamp(&now)
+		result = append(result, obj)
+	}
+	return list, meta.SetList(list, result)
+}
@@ -0,0 +1,184 @@
+package strategy
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/acorn-io/mink/pkg/types"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apiserver/pkg/endpoints/request"
+)
+
+// RevisionGetter is implemented by strategies that keep the history of their objects.
+type RevisionGetter interface {
+	Getter
+
+	// GetRevision returns the object as it was at resourceVersion. It fails with NotFound if the object didn't exist
+	// at that resource version, and with Gone if the history of that resource version has been compacted away.
+	GetRevision(ctx context.Context, namespace, name, resourceVersion string) (types.Object, error)
+	New() types.Object
+}
+
+// Diff is the difference between two revisions of an object.
+type Diff struct {
+	Name         string   `json:"name"`
+	Namespace    string   `json:"namespace,omitempty"`
+	FromRevision string   `json:"fromRevision"`
+	ToRevision   string   `json:"toRevision"`
+	Changes      []Change `json:"changes"`
+}
+
+// Change is a field that differs between two revisions of an object. Path is a JSON pointer to the field.
+type Change struct {
+	Op   string `json:"op"`
+	Path string `json:"path"`
+	From any    `json:"from,omitempty"`
+	To   any    `json:"to,omitempty"`
+}
+
+const (
+	ChangeAdd     = "add"
+	ChangeRemove  = "remove"
+	ChangeReplace = "replace"
+)
+
+type DiffAdapter struct {
+	Scrubber Scrubber
+	strategy RevisionGetter
+}
+
+func NewDiff(strategy RevisionGetter) *DiffAdapter {
+	return &DiffAdapter{
+		strategy: strategy,
+	}
+}
+
+func (a *DiffAdapter) New() types.Object {
+	return a.strategy.New()
+}
+
+// Diff returns the changes of the object between the resource versions from and to. An empty to is the current
+// version of the object.
+func (a *DiffAdapter) Diff(ctx context.Context, name, from, to string) (*Diff, error) {
+	if from == "" {
+		return nil, apierrors.NewBadRequest("the resource version to diff from is required")
+	}
+
+	ns, _ := request.NamespaceFrom(ctx)
+	fromObj, err := a.strategy.GetRevision(ctx, ns, name, from)
+	if err != nil {
+		return nil, err
+	}
+	var toObj types.Object
+	if to == "" {
+		toObj, err = a.strategy.Get(ctx, ns, name)
+	} else {
+		toObj, err = a.strategy.GetRevision(ctx, ns, name, to)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if scrubber := getScrubber(a.Scrubber, a.strategy); scrubber != nil {
+		scrubber.Scrub(ctx, fromObj)
+		scrubber.Scrub(ctx, toObj)
+	}
+
+	changes, err := DiffObjects(fromObj, toObj)
+	if err != nil {
+		return nil, err
+	}
+	return &Diff{
+		Name:         name,
+		Namespace:    ns,
+		FromRevision: fromObj.GetResourceVersion(),
+		ToRevision:   toObj.GetResourceVersion(),
+		Changes:      changes,
+	}, nil
+}
+
+// DiffObjects returns the changes between from and to, ordered by path. Lists are compared by index.
+func DiffObjects(from, to runtime.Object) ([]Change, error) {
+	fromData, err := runtime.DefaultUnstructuredConverter.ToUnstructured(from)
+	if err != nil {
+		return nil, err
+	}
+	toData, err := runtime.DefaultUnstructuredConverter.ToUnstructured(to)
+	if err != nil {
+		return nil, err
+	}
+	changes := diffValues(nil, "", fromData, toData)
+	if changes == nil {
+		changes = []Change{}
+	}
+	return changes, nil
+}
+
+func diffValues(changes []Change, path string, from, to any) []Change {
+	switch from := from.(type) {
+	case map[string]any:
+		if to, ok := to.(map[string]any); ok {
+			return diffMaps(changes, path, from, to)
+		}
+	case []any:
+		if to, ok := to.([]any); ok {
+			return diffSlices(changes, path, from, to)
+		}
+	}
+	if reflect.DeepEqual(from, to) {
+		return changes
+	}
+	return append(changes, Change{Op: ChangeReplace, Path: path, From: from, To: to})
+}
+
+func diffMaps(changes []Change, path string, from, to map[string]any) []Change {
+	keys := make([]string, 0, len(from)+len(to))
+	for key := range from {
+		keys = append(keys, key)
+	}
+	for key := range to {
+		if _, ok := from[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		keyPath := path + "/" + escapePointer(key)
+		fromValue, inFrom := from[key]
+		toValue, inTo := to[key]
+		switch {
+		case !inTo:
+			changes = append(changes, Change{Op: ChangeRemove, Path: keyPath, From: fromValue})
+		case !inFrom:
+			changes = append(changes, Change{Op: ChangeAdd, Path: keyPath, To: toValue})
+		default:
+			changes = diffValues(changes, keyPath, fromValue, toValue)
+		}
+	}
+	return changes
+}
+
+func diffSlices(changes []Change, path string, from, to []any) []Change {
+	for i := 0; i < max(len(from), len(to)); i++ {
+		indexPath := path + "/" + strconv.Itoa(i)
+		switch {
+		case i >= len(to):
+			changes = append(changes, Change{Op: ChangeRemove, Path: indexPath, From: from[i]})
+		case i >= len(from):
+			changes = append(changes, Change{Op: ChangeAdd, Path: indexPath, To: to[i]})
+		default:
+			changes = diffValues(changes, indexPath, from[i], to[i])
+		}
+	}
+	return changes
+}
+
+// escapePointer escapes a key for use in a JSON pointer, see RFC 6901.
+func escapePointer(key string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(key, "~", "~0"), "/", "~1")
+}
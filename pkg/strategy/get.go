@@ -23,10 +23,22 @@ func NewGet(strategy Getter) *GetAdapter {
 }
 
 type GetAdapter struct {
-	strategy Getter
+	strategy  Getter
+	Scrubber  Scrubber
+	Decorator Decorator
 }
 
 func (a *GetAdapter) Get(ctx context.Context, name string, options *metav1.GetOptions) (runtime.Object, error) {
 	ns, _ := request.NamespaceFrom(ctx)
-	return a.strategy.Get(ctx, ns, name)
+	obj, err := a.strategy.Get(ctx, ns, name)
+	if err != nil {
+		return nil, err
+	}
+	if decorator := getDecorator(a.Decorator, a.strategy); decorator != nil {
+		decorator.Decorate(ctx, obj)
+	}
+	if scrubber := getScrubber(a.Scrubber, a.strategy); scrubber != nil {
+		scrubber.Scrub(ctx, obj)
+	}
+	return obj, nil
 }
@@ -31,7 +31,12 @@ type Lister interface {
 
 type ListAdapter struct {
 	*TableAdapter
-	strategy Lister
+	strategy        Lister
+	NamespaceScoper NamespaceScoper
+	Scrubber        Scrubber
+	Decorator       Decorator
+	// Concurrency, if set, decorates the items of large lists in parallel.
+	Concurrency ListConcurrency
 }
 
 func NewList(strategy Lister) *ListAdapter {
@@ -58,13 +63,7 @@ func (l *ListAdapter) List(ctx context.Context, options *metainternalversion.Lis
 }
 
 func (l *ListAdapter) NamespaceScoped() bool {
-	if o, ok := l.strategy.(NamespaceScoper); ok {
-		return o.NamespaceScoped()
-	}
-	if o, ok := l.strategy.New().(NamespaceScoper); ok {
-		return o.NamespaceScoped()
-	}
-	return true
+	return namespaceScoped(l.NamespaceScoper, l.strategy)
 }
 
 func (l *ListAdapter) predicate(label labels.Selector, field fields.Selector) storage.SelectionPredicate {
@@ -89,19 +88,37 @@ func (l *ListAdapter) ListPredicate(ctx context.Context, p storage.SelectionPred
 	}
 	p.Limit = options.Limit
 	p.Continue = options.Continue
+	scope := ListScopeFromContext(ctx)
+	p.Label = scope.labelSelector(p.Label)
 	ns, _ := request.NamespaceFrom(ctx)
 	storageOpts := storage.ListOptions{
 		ResourceVersion:      options.ResourceVersion,
 		ResourceVersionMatch: options.ResourceVersionMatch,
 		Predicate:            p,
 	}
+	var (
+		list types.ObjectList
+		err  error
+	)
 	if name, ok := p.MatchesSingle(); ok {
 		if gtl, ok := l.strategy.(GetToLister); ok {
-			return gtl.GetToList(ctx, ns, name)
+			list, err = gtl.GetToList(ctx, ns, name)
 		}
 	}
+	if list == nil && err == nil {
+		list, err = l.strategy.List(ctx, ns, storageOpts)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := scope.filterList(list); err != nil {
+		return nil, err
+	}
+	if err := decorateList(ctx, getDecorator(l.Decorator, l.strategy), l.Concurrency, list); err != nil {
+		return nil, err
+	}
 
-	return l.strategy.List(ctx, ns, storageOpts)
+	return list, scrubList(ctx, getScrubber(l.Scrubber, l.strategy), list)
 }
 
 func (l *ListAdapter) NewList() runtime.Object {
@@ -0,0 +1,143 @@
+package strategy
+
+import (
+	"context"
+	"slices"
+	"sync/atomic"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+)
+
+// ListScope constrains a list or watch beyond what the request asked for, typically to what the requesting user is
+// authorized to see. Strategies that can should push the scope down into their query, the list and watch adapters
+// also filter results so strategies that ignore it never return objects outside the scope.
+type ListScope struct {
+	// Namespaces limits a cluster wide list or watch to the given namespaces. A nil slice doesn't restrict
+	// namespaces, an empty slice matches nothing.
+	Namespaces []string
+	// LabelSelector is added to the label selector of the request.
+	LabelSelector labels.Selector
+}
+
+type (
+	listScopeKey     struct{}
+	listScopeSlotKey struct{}
+)
+
+// listScopeSlot holds the scope set by SetListScope and whether a strategy or adapter has read it.
+type listScopeSlot struct {
+	scope    atomic.Pointer[ListScope]
+	consumed atomic.Bool
+}
+
+func WithListScope(ctx context.Context, scope *ListScope) context.Context {
+	if scope == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, listScopeKey{}, scope)
+}
+
+// WithListScopeSlot returns a context whose list scope can be set by SetListScope after the context was created. It
+// lets authorizers, which can't change the context of the request they authorize, scope the request.
+func WithListScopeSlot(ctx context.Context) context.Context {
+	return context.WithValue(ctx, listScopeSlotKey{}, &listScopeSlot{})
+}
+
+// SetListScope sets the list scope of a context created by WithListScopeSlot. It returns false if ctx has no slot, in
+// which case the scope can't be enforced.
+func SetListScope(ctx context.Context, scope *ListScope) bool {
+	slot, ok := ctx.Value(listScopeSlotKey{}).(*listScopeSlot)
+	if !ok {
+		return false
+	}
+	slot.scope.Store(scope)
+	return true
+}
+
+// ListScopeFromContext returns the list scope of ctx. Reading a scope set by SetListScope marks it consumed, the
+// list, watch and delete collection adapters read it before calling the strategy.
+func ListScopeFromContext(ctx context.Context) *ListScope {
+	if scope, ok := ctx.Value(listScopeKey{}).(*ListScope); ok {
+		return scope
+	}
+	if slot, ok := ctx.Value(listScopeSlotKey{}).(*listScopeSlot); ok {
+		scope := slot.scope.Load()
+		if scope != nil {
+			slot.consumed.Store(true)
+		}
+		return scope
+	}
+	return nil
+}
+
+// ListScopeEnforced returns false if a list scope was set by SetListScope but nothing has read it yet, which means the
+// storage serving the request doesn't know about list scopes and would return everything.
+func ListScopeEnforced(ctx context.Context) bool {
+	slot, ok := ctx.Value(listScopeSlotKey{}).(*listScopeSlot)
+	return !ok || slot.scope.Load() == nil || slot.consumed.Load()
+}
+
+// AllowsNamespace returns true if objects in the namespace are within the scope.
+func (l *ListScope) AllowsNamespace(namespace string) bool {
+	return l == nil || l.Namespaces == nil || slices.Contains(l.Namespaces, namespace)
+}
+
+func (l *ListScope) labelSelector(selector labels.Selector) labels.Selector {
+	if l == nil || l.LabelSelector == nil {
+		return selector
+	}
+	reqs, selectable := l.LabelSelector.Requirements()
+	if !selectable {
+		return labels.Nothing()
+	}
+	if selector == nil {
+		selector = labels.Everything()
+	}
+	return selector.Add(reqs...)
+}
+
+func (l *ListScope) filterList(list runtime.Object) error {
+	if l == nil || l.Namespaces == nil {
+		return nil
+	}
+	items, err := meta.ExtractList(list)
+	if err != nil {
+		return err
+	}
+	filtered := make([]runtime.Object, 0, len(items))
+	for _, item := range items {
+		m, err := meta.Accessor(item)
+		if err != nil {
+			return err
+		}
+		if l.AllowsNamespace(m.GetNamespace()) {
+			filtered = append(filtered, item)
+		}
+	}
+	if len(filtered) == len(items) {
+		return nil
+	}
+	return meta.SetList(list, filtered)
+}
+
+func (l *ListScope) filterEvents(c <-chan watch.Event) <-chan watch.Event {
+	if l == nil || l.Namespaces == nil {
+		return c
+	}
+	result := make(chan watch.Event)
+	go func() {
+		defer close(result)
+		for event := range c {
+			if event.Type != watch.Error && event.Type != watch.Bookmark {
+				if m, err := meta.Accessor(event.Object); err == nil && !l.AllowsNamespace(m.GetNamespace()) {
+					continue
+				}
+			}
+			result <- event
+		}
+	}()
+	return result
+}
@@ -0,0 +1,152 @@
+package strategy
+
+import (
+	"context"
+	"reflect"
+	"sort"
+
+	"github.com/acorn-io/mink/pkg/types"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apiserver/pkg/endpoints/request"
+)
+
+// MergeResult is the result of a three-way merge of a modified revision of an object with its current version. If
+// there are conflicts, Object has the current value of every conflicting field.
+type MergeResult struct {
+	Object    map[string]any `json:"object"`
+	Conflicts []Conflict     `json:"conflicts,omitempty"`
+}
+
+// Conflict is a field changed both by the client and since the revision the client modified, to different values.
+// Path is a JSON pointer to the field, Removed lists the versions in which the field doesn't exist.
+type Conflict struct {
+	Path     string   `json:"path"`
+	Base     any      `json:"base,omitempty"`
+	Modified any      `json:"modified,omitempty"`
+	Current  any      `json:"current,omitempty"`
+	Removed  []string `json:"removed,omitempty"`
+}
+
+type MergeAdapter struct {
+	Scrubber Scrubber
+	strategy RevisionGetter
+}
+
+func NewMerge(strategy RevisionGetter) *MergeAdapter {
+	return &MergeAdapter{
+		strategy: strategy,
+	}
+}
+
+func (a *MergeAdapter) New() types.Object {
+	return a.strategy.New()
+}
+
+// Merge merges the changes from the revision base of the object to modified with the changes from base to the
+// current version of the object.
+func (a *MergeAdapter) Merge(ctx context.Context, name, base string, modified types.Object) (*MergeResult, error) {
+	if base == "" {
+		return nil, apierrors.NewBadRequest("the resource version of the base of the merge is required")
+	}
+	if modified.GetName() != "" && modified.GetName() != name {
+		return nil, apierrors.NewBadRequest("the name of the object does not match the name in the URL")
+	}
+
+	ns, _ := request.NamespaceFrom(ctx)
+	baseObj, err := a.strategy.GetRevision(ctx, ns, name, base)
+	if err != nil {
+		return nil, err
+	}
+	current, err := a.strategy.Get(ctx, ns, name)
+	if err != nil {
+		return nil, err
+	}
+
+	if scrubber := getScrubber(a.Scrubber, a.strategy); scrubber != nil {
+		scrubber.Scrub(ctx, baseObj)
+		scrubber.Scrub(ctx, current)
+	}
+
+	return MergeObjects(baseObj, modified, current)
+}
+
+// MergeObjects is a three-way merge of modified and current, which both derive from base. Fields are merged
+// recursively, lists are merged as a whole.
+func MergeObjects(base, modified, current runtime.Object) (*MergeResult, error) {
+	var data [3]map[string]any
+	for i, obj := range []runtime.Object{base, modified, current} {
+		m, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+		if err != nil {
+			return nil, err
+		}
+		data[i] = m
+	}
+
+	result := &MergeResult{}
+	merged, _ := merge3(result, "", data[0], data[1], data[2], true, true, true)
+	result.Object = merged.(map[string]any)
+	return result, nil
+}
+
+// merge3 returns the merge of a field and whether it exists, the in* arguments are whether it exists in each version.
+func merge3(result *MergeResult, path string, base, modified, current any, inBase, inModified, inCurrent bool) (any, bool) {
+	switch {
+	case inModified == inCurrent && reflect.DeepEqual(modified, current):
+		return current, inCurrent
+	case inBase == inModified && reflect.DeepEqual(base, modified):
+		return current, inCurrent
+	case inBase == inCurrent && reflect.DeepEqual(base, current):
+		return modified, inModified
+	}
+
+	baseMap, baseOK := base.(map[string]any)
+	modifiedMap, modifiedOK := modified.(map[string]any)
+	currentMap, currentOK := current.(map[string]any)
+	if (baseOK || !inBase) && modifiedOK && currentOK {
+		return mergeMaps(result, path, baseMap, modifiedMap, currentMap), true
+	}
+
+	conflict := Conflict{
+		Path:     path,
+		Base:     base,
+		Modified: modified,
+		Current:  current,
+	}
+	for _, version := range []struct {
+		name   string
+		exists bool
+	}{{"base", inBase}, {"modified", inModified}, {"current", inCurrent}} {
+		if !version.exists {
+			conflict.Removed = append(conflict.Removed, version.name)
+		}
+	}
+	result.Conflicts = append(result.Conflicts, conflict)
+	return current, inCurrent
+}
+
+func mergeMaps(result *MergeResult, path string, base, modified, current map[string]any) map[string]any {
+	keys := map[string]struct{}{}
+	for _, m := range []map[string]any{base, modified, current} {
+		for key := range m {
+			keys[key] = struct{}{}
+		}
+	}
+	sorted := make([]string, 0, len(keys))
+	for key := range keys {
+		sorted = append(sorted, key)
+	}
+	sort.Strings(sorted)
+
+	merged := map[string]any{}
+	for _, key := range sorted {
+		baseValue, inBase := base[key]
+		modifiedValue, inModified := modified[key]
+		currentValue, inCurrent := current[key]
+		if value, ok := merge3(result, path+"/"+escapePointer(key), baseValue, modifiedValue, currentValue, inBase,
+			inModified, inCurrent); ok {
+			merged[key] = value
+		}
+	}
+	return merged
+}
@@ -0,0 +1,168 @@
+package strategy
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"golang.org/x/time/rate"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/watch"
+)
+
+// WatchRate limits the events sent to a single watch, so one chatty resource doesn't saturate the link of a slow
+// client. Events beyond the limit are read from the strategy as they come and held back on the server, so the buffer of
+// the strategy never fills because of the limit. Held back modifications of an object collapse into the latest one,
+// so a watch of objects that change faster than the limit stays bounded by the number of objects rather than the
+// number of writes. A watch that still holds back more than MaxPending events is ended with a 410 Expired error,
+// clients list again and watch from there.
+type WatchRate struct {
+	// EventsPerSecond is the sustained rate of events. Zero disables the limit.
+	EventsPerSecond float64
+	// Burst is the number of events that can be sent at once. If zero, the ceiling of EventsPerSecond is used.
+	Burst int
+	// MaxPending is the most events held back. If zero, 10000 is used.
+	MaxPending int
+}
+
+type watchRateKey struct{}
+
+// WithWatchRate overrides the rate limit of the WatchAdapter for watches started with the returned context. A zero
+// rate disables the limit for the watch.
+func WithWatchRate(ctx context.Context, rate WatchRate) context.Context {
+	return context.WithValue(ctx, watchRateKey{}, rate)
+}
+
+func watchRate(ctx context.Context, def WatchRate) WatchRate {
+	if rate, ok := ctx.Value(watchRateKey{}).(WatchRate); ok {
+		return rate
+	}
+	return def
+}
+
+// defaultMaxPacedEvents matches the default watch buffer of the db strategy, a paced watch holds back no more than an
+// unpaced one buffers.
+const defaultMaxPacedEvents = 10000
+
+// paceEvents sends the events of c no faster than limit. Errors are not held back, they end the watch anyway.
+func paceEvents(ctx context.Context, limit WatchRate, c <-chan watch.Event) <-chan watch.Event {
+	if limit.EventsPerSecond <= 0 {
+		return c
+	}
+	burst := limit.Burst
+	if burst <= 0 {
+		burst = int(limit.EventsPerSecond)
+		if float64(burst) < limit.EventsPerSecond {
+			burst++
+		}
+	}
+	maxPending := limit.MaxPending
+	if maxPending <= 0 {
+		maxPending = defaultMaxPacedEvents
+	}
+	limiter := rate.NewLimiter(rate.Limit(limit.EventsPerSecond), burst)
+
+	result := make(chan watch.Event)
+	go func() {
+		defer close(result)
+		defer func() {
+			// the watch was stopped or expired, let the strategy finish
+			go func() {
+				for range c {
+				}
+			}()
+		}()
+
+		var (
+			pending []watch.Event
+			// ready is set once the limiter allows the first pending event to be sent
+			ready bool
+			timer *time.Timer
+			wait  <-chan time.Time
+		)
+		defer func() {
+			if timer != nil {
+				timer.Stop()
+			}
+		}()
+
+		for c != nil || len(pending) > 0 {
+			if len(pending) > 0 && !ready && wait == nil {
+				if pending[0].Type == watch.Error {
+					ready = true
+				} else {
+					timer = time.NewTimer(limiter.Reserve().Delay())
+					wait = timer.C
+				}
+			}
+
+			var send chan<- watch.Event
+			if ready && len(pending) > 0 {
+				send = result
+			}
+			var next watch.Event
+			if send != nil {
+				next = pending[0]
+			}
+
+			select {
+			case event, ok := <-c:
+				if !ok {
+					c = nil
+					continue
+				}
+				pending = holdBack(pending, event)
+				if len(pending) > maxPending {
+					status := apierrors.NewResourceExpired("the watch fell too far behind its rate limit, list and watch again").Status()
+					select {
+					case result <- watch.Event{Type: watch.Error, Object: &status}:
+					case <-ctx.Done():
+					}
+					return
+				}
+			case <-wait:
+				wait = nil
+				ready = true
+			case send <- next:
+				pending[0] = watch.Event{}
+				pending = pending[1:]
+				ready = false
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return result
+}
+
+// holdBack adds event to the pending events. A modification replaces the pending addition or modification of the same
+// object and a bookmark replaces the pending bookmark. The newer event moves to the end, so resource versions stay in
+// order and a client resuming from the last one it got misses nothing.
+func holdBack(pending []watch.Event, event watch.Event) []watch.Event {
+	switch event.Type {
+	case watch.Bookmark:
+		pending = slices.DeleteFunc(pending, func(e watch.Event) bool {
+			return e.Type == watch.Bookmark
+		})
+	case watch.Modified:
+		key, keyed := eventKey(event)
+		if !keyed {
+			break
+		}
+		for i := len(pending) - 1; i >= 0; i-- {
+			if pending[i].Type == watch.Bookmark || pending[i].Type == watch.Error {
+				continue
+			}
+			if k, _ := eventKey(pending[i]); k != key {
+				continue
+			}
+			if pending[i].Type == watch.Added || pending[i].Type == watch.Modified {
+				// an object the client hasn't seen yet is still added
+				event.Type = pending[i].Type
+				pending = slices.Delete(pending, i, i+1)
+			}
+			break
+		}
+	}
+	return append(pending, event)
+}
@@ -0,0 +1,68 @@
+package strategy
+
+import (
+	"context"
+
+	"github.com/acorn-io/mink/pkg/types"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apiserver/pkg/endpoints/request"
+	"k8s.io/apiserver/pkg/registry/rest"
+)
+
+// PatchFunc returns the object replacing existing, the latest version of the object being patched.
+type PatchFunc func(existing types.Object) (types.Object, error)
+
+// Patcher is implemented by updaters that can apply a patch to the latest version of an object themselves. Patch
+// reads the object, calls patch with it and writes the result, and when another write got in between it must call
+// patch again with the new version rather than fail with a conflict. A result with a different resource version than
+// the object passed to patch comes from a patch that sets one, it must fail with a conflict like an update.
+type Patcher interface {
+	Updater
+
+	Patch(ctx context.Context, namespace, name string, patch PatchFunc) (types.Object, error)
+}
+
+var _ rest.Updater = (*PatchAdapter)(nil)
+
+// PatchAdapter serves patch requests through the Patch method of the strategy if it implements Patcher, so concurrent
+// patches of an object don't fail with conflicts. Every other update, and every patch of a strategy that isn't a
+// Patcher, is served by the UpdateAdapter.
+type PatchAdapter struct {
+	*UpdateAdapter
+	patcher Patcher
+}
+
+func NewPatch(schema *runtime.Scheme, strategy Updater) *PatchAdapter {
+	patcher, _ := strategy.(Patcher)
+	return &PatchAdapter{
+		UpdateAdapter: NewUpdate(schema, strategy),
+		patcher:       patcher,
+	}
+}
+
+func (a *PatchAdapter) Update(ctx context.Context, name string, objInfo rest.UpdatedObjectInfo, createValidation rest.ValidateObjectFunc, updateValidation rest.ValidateObjectUpdateFunc, forceAllowCreate bool, options *metav1.UpdateOptions) (runtime.Object, bool, error) {
+	if a.patcher == nil || a.status || !isPatch(ctx) || len(options.DryRun) != 0 && options.DryRun[0] == metav1.DryRunAll {
+		return a.UpdateAdapter.Update(ctx, name, objInfo, createValidation, updateValidation, forceAllowCreate, options)
+	}
+
+	ns, _ := request.NamespaceFrom(ctx)
+	obj, err := a.patcher.Patch(ctx, ns, name, func(existing types.Object) (types.Object, error) {
+		obj, err := a.updatedObject(ctx, existing, objInfo, updateValidation)
+		if err != nil {
+			return nil, err
+		}
+		return obj.(types.Object), nil
+	})
+	if apierrors.IsNotFound(err) && forceAllowCreate {
+		// server-side apply creates missing objects
+		return a.UpdateAdapter.Update(ctx, name, objInfo, createValidation, updateValidation, forceAllowCreate, options)
+	}
+	return obj, false, err
+}
+
+func isPatch(ctx context.Context) bool {
+	info, ok := request.RequestInfoFrom(ctx)
+	return ok && info.Verb == "patch"
+}
@@ -0,0 +1,328 @@
+package remote
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"syscall"
+
+	"github.com/acorn-io/mink/pkg/db"
+	"github.com/acorn-io/mink/pkg/logging"
+	"github.com/acorn-io/mink/pkg/strategy"
+	"github.com/acorn-io/mink/pkg/types"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+var _ strategy.CompleteStrategy = (*Buffered)(nil)
+
+const (
+	opCreate       = "create"
+	opUpdate       = "update"
+	opUpdateStatus = "updateStatus"
+	opDelete       = "delete"
+
+	// BufferedAnnotation is set on the objects returned for writes that were buffered, its value is the ID of the job
+	// replaying the write. It is removed from the objects passed to Buffered again.
+	BufferedAnnotation = "remote.mink.acorn.io/buffered"
+)
+
+// WriteConflict is a buffered write the remote rejected when it was replayed, typically because the object was changed
+// or deleted in the meantime.
+type WriteConflict struct {
+	// Op is create, update, updateStatus or delete.
+	Op     string
+	Object types.Object
+	Err    error
+}
+
+// ConflictReporter is called for every buffered write the remote rejected. The write is dropped afterwards.
+type ConflictReporter func(ctx context.Context, conflict WriteConflict)
+
+func logConflict(_ context.Context, conflict WriteConflict) {
+	logging.Default().Errorf("Dropping buffered %s of %s/%s rejected by the remote: %v", conflict.Op,
+		conflict.Object.GetNamespace(), conflict.Object.GetName(), conflict.Err)
+}
+
+type bufferedWrite struct {
+	Op     string          `json:"op"`
+	Object json.RawMessage `json:"object"`
+	// Recreate is set on a create that was buffered after a buffered delete of the same object, the remote object is
+	// deleted before it is created again.
+	Recreate bool `json:"recreate,omitempty"`
+}
+
+// coalesceKey keys the buffered writes of an object, status updates are kept apart from the writes of the object
+// itself as neither replaces the other.
+func coalesceKey(op string, obj types.Object) string {
+	if op == opUpdateStatus {
+		return "status/" + obj.GetNamespace() + "/" + obj.GetName()
+	}
+	return "object/" + obj.GetNamespace() + "/" + obj.GetName()
+}
+
+// merge returns the write replacing pending and write, which was made after it, or nil if they cancel out.
+func merge(pending, write bufferedWrite, obj types.Object) (*bufferedWrite, error) {
+	if write.Op == opUpdateStatus {
+		return &write, nil
+	}
+	switch pending.Op {
+	case opCreate:
+		switch write.Op {
+		case opUpdate:
+			return &bufferedWrite{Op: opCreate, Object: write.Object, Recreate: pending.Recreate}, nil
+		case opDelete:
+			if pending.Recreate {
+				return &write, nil
+			}
+			return nil, nil
+		}
+	case opUpdate:
+		if write.Op != opCreate {
+			return &write, nil
+		}
+	case opDelete:
+		if write.Op == opCreate {
+			return &bufferedWrite{Op: opCreate, Object: write.Object, Recreate: true}, nil
+		}
+		return nil, apierrors.NewNotFound(schema.GroupResource{}, obj.GetName())
+	}
+	return nil, apierrors.NewAlreadyExists(schema.GroupResource{}, obj.GetName())
+}
+
+// Buffered reads from a remote strategy, usually a Remote, and queues writes in a db.Queue while the remote can't be
+// reached. Writes that timed out are not queued, the remote may have applied them. Queued writes are replayed by Run
+// once the remote is back, writes the remote rejects then are passed to the ConflictReporter.
+//
+// A write that is queued is returned to the caller as it was passed in with the BufferedAnnotation added, it has
+// neither the UID nor the resource version the remote will assign. The queued writes of an object are coalesced, so
+// only the latest state of the object is replayed, and updates and deletes are replayed against the resource version
+// the remote object has by then, replacing the changes made to it since. The queue is meant for a single Buffered
+// and should have a single worker and allow enough attempts to outlast the expected outages.
+type Buffered struct {
+	strategy.CompleteStrategy
+
+	queue    *db.Queue
+	reporter ConflictReporter
+}
+
+type BufferedOption func(*Buffered)
+
+// WithConflictReporter sets the reporter of rejected buffered writes, they are logged by default.
+func WithConflictReporter(reporter ConflictReporter) BufferedOption {
+	return func(b *Buffered) {
+		b.reporter = reporter
+	}
+}
+
+func NewBuffered(remote strategy.CompleteStrategy, queue *db.Queue, opts ...BufferedOption) *Buffered {
+	b := &Buffered{
+		CompleteStrategy: remote,
+		queue:            queue,
+		reporter:         logConflict,
+	}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(b)
+		}
+	}
+	return b
+}
+
+// Run replays the buffered writes until ctx is done.
+func (b *Buffered) Run(ctx context.Context) {
+	b.queue.Run(ctx, b.replay)
+}
+
+func (b *Buffered) Create(ctx context.Context, obj types.Object) (types.Object, error) {
+	obj = withoutBufferedAnnotation(obj)
+	result, err := b.CompleteStrategy.Create(ctx, obj)
+	return b.bufferIfUnreachable(ctx, opCreate, obj, result, err)
+}
+
+func (b *Buffered) Update(ctx context.Context, obj types.Object) (types.Object, error) {
+	obj = withoutBufferedAnnotation(obj)
+	result, err := b.CompleteStrategy.Update(ctx, obj)
+	return b.bufferIfUnreachable(ctx, opUpdate, obj, result, err)
+}
+
+func (b *Buffered) UpdateStatus(ctx context.Context, obj types.Object) (types.Object, error) {
+	obj = withoutBufferedAnnotation(obj)
+	result, err := b.CompleteStrategy.UpdateStatus(ctx, obj)
+	return b.bufferIfUnreachable(ctx, opUpdateStatus, obj, result, err)
+}
+
+func (b *Buffered) Delete(ctx context.Context, obj types.Object) (types.Object, error) {
+	obj = withoutBufferedAnnotation(obj)
+	result, err := b.CompleteStrategy.Delete(ctx, obj)
+	return b.bufferIfUnreachable(ctx, opDelete, obj, result, err)
+}
+
+// DeleteCollection deletes the objects one at a time so the deletes are buffered like any other.
+func (b *Buffered) DeleteCollection(ctx context.Context, namespace string, opts strategy.DeleteCollectionOptions) (types.ObjectList, error) {
+	return strategy.DeleteEach(ctx, b, namespace, opts)
+}
+
+func (b *Buffered) bufferIfUnreachable(ctx context.Context, op string, obj, result types.Object, err error) (types.Object, error) {
+	if err == nil || ctx.Err() != nil || !isUnreachable(err) {
+		return result, err
+	}
+
+	data, marshalErr := json.Marshal(obj)
+	if marshalErr != nil {
+		return nil, marshalErr
+	}
+	write := bufferedWrite{Op: op, Object: data}
+	job, queueErr := b.queue.Coalesce(ctx, coalesceKey(op, obj), write, func(pending *db.Job) (any, error) {
+		var pendingWrite bufferedWrite
+		if err := pending.Unmarshal(&pendingWrite); err != nil {
+			return nil, err
+		}
+		merged, err := merge(pendingWrite, write, obj)
+		if merged == nil {
+			// untyped nil drops the pending write
+			return nil, err
+		}
+		return merged, err
+	})
+	if apierrors.IsNotFound(queueErr) || apierrors.IsAlreadyExists(queueErr) {
+		return nil, queueErr
+	} else if queueErr != nil {
+		return nil, fmt.Errorf("remote unreachable (%v) and buffering the %s failed: %w", err, op, queueErr)
+	}
+	logging.Default().Debugf("Buffered %s of %s/%s, remote unreachable: %v", op, obj.GetNamespace(), obj.GetName(), err)
+
+	buffered := obj.DeepCopyObject().(types.Object)
+	annotations := buffered.GetAnnotations()
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	annotations[BufferedAnnotation] = ""
+	if job != nil {
+		annotations[BufferedAnnotation] = strconv.FormatUint(uint64(job.ID), 10)
+	}
+	buffered.SetAnnotations(annotations)
+	return buffered, nil
+}
+
+func withoutBufferedAnnotation(obj types.Object) types.Object {
+	if _, ok := obj.GetAnnotations()[BufferedAnnotation]; !ok {
+		return obj
+	}
+	obj = obj.DeepCopyObject().(types.Object)
+	annotations := obj.GetAnnotations()
+	delete(annotations, BufferedAnnotation)
+	obj.SetAnnotations(annotations)
+	return obj
+}
+
+// isUnreachable returns true for errors that mean the write didn't reach the remote, as opposed to the remote
+// rejecting it or the write timing out, in which case the remote may have applied it.
+func isUnreachable(err error) bool {
+	if apierrors.IsServiceUnavailable(err) {
+		return true
+	}
+	var status apierrors.APIStatus
+	if errors.As(err, &status) {
+		return false
+	}
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return true
+	}
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && opErr.Op == "dial" {
+		return true
+	}
+	return errors.Is(err, syscall.ECONNREFUSED)
+}
+
+// isRetryable returns true for errors of a replayed write that is retried. Unlike the first attempt a replay that
+// timed out is retried too, a replay writes the latest state of the object and can be repeated.
+func isRetryable(err error) bool {
+	return isUnreachable(err) || apierrors.IsServerTimeout(err) || apierrors.IsTimeout(err) ||
+		errors.Is(err, context.DeadlineExceeded) || isNetTimeout(err)
+}
+
+func isNetTimeout(err error) bool {
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+func (b *Buffered) replay(ctx context.Context, job *db.Job) error {
+	var write bufferedWrite
+	if err := job.Unmarshal(&write); err != nil {
+		logging.Default().Errorf("Dropping invalid buffered write %d: %v", job.ID, err)
+		return nil
+	}
+	obj := b.New()
+	if err := json.Unmarshal(write.Object, obj); err != nil {
+		logging.Default().Errorf("Dropping invalid buffered write %d: %v", job.ID, err)
+		return nil
+	}
+
+	var err error
+	switch write.Op {
+	case opCreate:
+		if write.Recreate {
+			err = b.deleteRemote(ctx, obj)
+		}
+		if err == nil {
+			_, err = b.CompleteStrategy.Create(ctx, obj)
+		}
+	case opUpdate:
+		if err = b.rebase(ctx, obj); err == nil {
+			_, err = b.CompleteStrategy.Update(ctx, obj)
+		}
+	case opUpdateStatus:
+		if err = b.rebase(ctx, obj); err == nil {
+			_, err = b.CompleteStrategy.UpdateStatus(ctx, obj)
+		}
+	case opDelete:
+		err = b.deleteRemote(ctx, obj)
+	default:
+		logging.Default().Errorf("Dropping buffered write %d with unknown op %s", job.ID, write.Op)
+		return nil
+	}
+	if err == nil {
+		return nil
+	}
+	if isRetryable(err) {
+		// retried by the queue with backoff
+		return err
+	}
+	b.reporter(ctx, WriteConflict{Op: write.Op, Object: obj, Err: err})
+	return nil
+}
+
+// rebase sets the resource version of obj to the one the remote object has now, so that a replayed write replaces
+// the changes made since it was buffered instead of conflicting with them. A write to an object that was deleted and
+// created again in the meantime is a conflict.
+func (b *Buffered) rebase(ctx context.Context, obj types.Object) error {
+	current, err := b.CompleteStrategy.Get(ctx, obj.GetNamespace(), obj.GetName())
+	if err != nil {
+		return err
+	}
+	if obj.GetUID() != "" && obj.GetUID() != current.GetUID() {
+		return apierrors.NewConflict(schema.GroupResource{}, obj.GetName(),
+			fmt.Errorf("the object was recreated with UID %s", current.GetUID()))
+	}
+	obj.SetResourceVersion(current.GetResourceVersion())
+	return nil
+}
+
+// deleteRemote deletes the remote object, an object that is already gone counts as deleted.
+func (b *Buffered) deleteRemote(ctx context.Context, obj types.Object) error {
+	toDelete := obj.DeepCopyObject().(types.Object)
+	err := b.rebase(ctx, toDelete)
+	if err == nil {
+		_, err = b.CompleteStrategy.Delete(ctx, toDelete)
+	}
+	if apierrors.IsNotFound(err) {
+		return nil
+	}
+	return err
+}
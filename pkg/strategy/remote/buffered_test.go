@@ -0,0 +1,319 @@
+package remote
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/acorn-io/mink/pkg/db"
+	"github.com/acorn-io/mink/pkg/strategy"
+	"github.com/acorn-io/mink/pkg/types"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	k8stypes "k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+)
+
+// fakeRemote keeps config maps in memory and fails every call with err while it is set.
+type fakeRemote struct {
+	strategy.CompleteStrategy
+
+	lock    sync.Mutex
+	err     error
+	objs    map[string]*corev1.ConfigMap
+	version int
+}
+
+func newFakeRemote() *fakeRemote {
+	return &fakeRemote{objs: map[string]*corev1.ConfigMap{}}
+}
+
+func (f *fakeRemote) setErr(err error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.err = err
+}
+
+func (f *fakeRemote) get(name string) *corev1.ConfigMap {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if obj, ok := f.objs[name]; ok {
+		return obj.DeepCopy()
+	}
+	return nil
+}
+
+func (f *fakeRemote) put(obj *corev1.ConfigMap) *corev1.ConfigMap {
+	f.version++
+	obj = obj.DeepCopy()
+	obj.ResourceVersion = strconv.Itoa(f.version)
+	f.objs[obj.Name] = obj
+	return obj.DeepCopy()
+}
+
+func (f *fakeRemote) New() types.Object {
+	return &corev1.ConfigMap{}
+}
+
+func (f *fakeRemote) Get(_ context.Context, _, name string) (types.Object, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if f.err != nil {
+		return nil, f.err
+	}
+	obj, ok := f.objs[name]
+	if !ok {
+		return nil, apierrors.NewNotFound(schema.GroupResource{Resource: "configmaps"}, name)
+	}
+	return obj.DeepCopy(), nil
+}
+
+func (f *fakeRemote) Create(_ context.Context, obj types.Object) (types.Object, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if f.err != nil {
+		return nil, f.err
+	}
+	if _, ok := f.objs[obj.GetName()]; ok {
+		return nil, apierrors.NewAlreadyExists(schema.GroupResource{Resource: "configmaps"}, obj.GetName())
+	}
+	cm := obj.(*corev1.ConfigMap).DeepCopy()
+	cm.UID = k8stypes.UID("uid-" + strconv.Itoa(f.version+1))
+	return f.put(cm), nil
+}
+
+func (f *fakeRemote) update(obj types.Object) (*corev1.ConfigMap, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	existing, ok := f.objs[obj.GetName()]
+	if !ok {
+		return nil, apierrors.NewNotFound(schema.GroupResource{Resource: "configmaps"}, obj.GetName())
+	}
+	if existing.ResourceVersion != obj.GetResourceVersion() {
+		return nil, apierrors.NewConflict(schema.GroupResource{Resource: "configmaps"}, obj.GetName(), nil)
+	}
+	return existing, nil
+}
+
+func (f *fakeRemote) Update(_ context.Context, obj types.Object) (types.Object, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	existing, err := f.update(obj)
+	if err != nil {
+		return nil, err
+	}
+	cm := obj.(*corev1.ConfigMap).DeepCopy()
+	cm.UID = existing.UID
+	return f.put(cm), nil
+}
+
+func (f *fakeRemote) UpdateStatus(_ context.Context, obj types.Object) (types.Object, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	existing, err := f.update(obj)
+	if err != nil {
+		return nil, err
+	}
+	// config maps have no status, labels stand in for it
+	cm := existing.DeepCopy()
+	cm.Labels = obj.GetLabels()
+	return f.put(cm), nil
+}
+
+func (f *fakeRemote) Delete(_ context.Context, obj types.Object) (types.Object, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	existing, err := f.update(obj)
+	if err != nil {
+		return nil, err
+	}
+	delete(f.objs, obj.GetName())
+	return existing, nil
+}
+
+func newTestBuffered(t *testing.T, remote *fakeRemote, opts ...BufferedOption) (*Buffered, *db.Factory) {
+	f, err := db.NewFactory(scheme.Scheme, "sqlite://file:"+t.Name()+"?mode=memory&cache=shared")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = f.SQLDB.Close()
+	})
+	queue, err := f.NewQueue("buffered", db.WithJobPollInterval(10*time.Millisecond),
+		db.WithJobRetryBackoff(time.Millisecond, time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewBuffered(remote, queue, opts...), f
+}
+
+func pendingJobs(t *testing.T, f *db.Factory) int64 {
+	var count int64
+	if err := f.DB.Table("mink_jobs").Where("dead = ?", false).Count(&count).Error; err != nil {
+		t.Fatal(err)
+	}
+	return count
+}
+
+func TestBufferedReplay(t *testing.T) {
+	remote := newFakeRemote()
+	conflicts := make(chan WriteConflict, 10)
+	buffered, f := newTestBuffered(t, remote, WithConflictReporter(func(_ context.Context, conflict WriteConflict) {
+		conflicts <- conflict
+	}))
+	ctx := context.Background()
+
+	existing, err := buffered.Create(ctx, &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "existing"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.NotContains(t, existing.GetAnnotations(), BufferedAnnotation)
+
+	remote.setErr(&net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED})
+
+	// a create followed by updates is replayed as a single create of the latest state
+	created, err := buffered.Create(ctx, &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "created"}, Data: map[string]string{"v": "1"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Contains(t, created.GetAnnotations(), BufferedAnnotation)
+	assert.Empty(t, created.GetResourceVersion())
+	created.(*corev1.ConfigMap).Data["v"] = "2"
+	_, err = buffered.Update(ctx, created)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a create followed by a delete cancels out
+	short, err := buffered.Create(ctx, &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "short"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = buffered.Delete(ctx, short)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// updates of an existing object are coalesced and a status update is kept apart
+	existing.(*corev1.ConfigMap).Data = map[string]string{"v": "1"}
+	existing, err = buffered.Update(ctx, existing)
+	if err != nil {
+		t.Fatal(err)
+	}
+	existing.(*corev1.ConfigMap).Data["v"] = "2"
+	existing, err = buffered.Update(ctx, existing)
+	if err != nil {
+		t.Fatal(err)
+	}
+	existing.SetLabels(map[string]string{"status": "ready"})
+	_, err = buffered.UpdateStatus(ctx, existing)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.EqualValues(t, 3, pendingJobs(t, f))
+
+	// the object is changed by someone else before the remote is reachable again, the replayed update is rebased
+	// onto it instead of conflicting
+	remote.lock.Lock()
+	remote.put(remote.objs["existing"])
+	remote.lock.Unlock()
+	remote.setErr(nil)
+
+	runCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go buffered.Run(runCtx)
+
+	assert.Eventually(t, func() bool {
+		return pendingJobs(t, f) == 0
+	}, 5*time.Second, 10*time.Millisecond)
+
+	assert.Empty(t, conflicts)
+
+	if obj := remote.get("created"); assert.NotNil(t, obj) {
+		assert.Equal(t, "2", obj.Data["v"])
+		assert.NotContains(t, obj.Annotations, BufferedAnnotation)
+	}
+	assert.Nil(t, remote.get("short"))
+	if obj := remote.get("existing"); assert.NotNil(t, obj) {
+		assert.Equal(t, "2", obj.Data["v"])
+		assert.Equal(t, map[string]string{"status": "ready"}, obj.Labels)
+		assert.NotContains(t, obj.Annotations, BufferedAnnotation)
+	}
+
+	// a write after a buffered delete fails like it would against the remote
+	remote.setErr(&net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED})
+	_, err = buffered.Delete(ctx, existing)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = buffered.Update(ctx, existing)
+	assert.True(t, apierrors.IsNotFound(err), "expected not found, got %v", err)
+}
+
+func TestBufferedReplayConflict(t *testing.T) {
+	remote := newFakeRemote()
+	conflicts := make(chan WriteConflict, 1)
+	buffered, _ := newTestBuffered(t, remote, WithConflictReporter(func(_ context.Context, conflict WriteConflict) {
+		conflicts <- conflict
+	}))
+	ctx := context.Background()
+
+	obj, err := buffered.Create(ctx, &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "recreated"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	remote.setErr(&net.DNSError{Err: "no such host", Name: "remote", IsNotFound: true})
+	_, err = buffered.Update(ctx, obj)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the object is deleted and created again before the update is replayed
+	remote.lock.Lock()
+	delete(remote.objs, "recreated")
+	remote.lock.Unlock()
+	remote.setErr(nil)
+	_, err = remote.Create(ctx, &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "recreated"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	runCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go buffered.Run(runCtx)
+
+	select {
+	case conflict := <-conflicts:
+		assert.Equal(t, opUpdate, conflict.Op)
+		assert.True(t, apierrors.IsConflict(conflict.Err), "expected conflict, got %v", conflict.Err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("conflict was not reported")
+	}
+}
+
+func TestBufferedTimeoutNotBuffered(t *testing.T) {
+	remote := newFakeRemote()
+	buffered, f := newTestBuffered(t, remote)
+	ctx := context.Background()
+
+	for _, err := range []error{
+		apierrors.NewTimeoutError("timed out", 1),
+		apierrors.NewServerTimeout(schema.GroupResource{Resource: "configmaps"}, "create", 1),
+		context.DeadlineExceeded,
+		&net.OpError{Op: "read", Net: "tcp", Err: syscall.ETIMEDOUT},
+	} {
+		remote.setErr(err)
+		_, createErr := buffered.Create(ctx, &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "timeout"}})
+		assert.ErrorIs(t, createErr, err)
+	}
+	assert.Zero(t, pendingJobs(t, f))
+}
@@ -0,0 +1,296 @@
+package remote
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/acorn-io/mink/pkg/strategy"
+	"github.com/acorn-io/mink/pkg/types"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/selection"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/apiserver/pkg/storage"
+)
+
+var _ strategy.CompleteStrategy = (*Multi)(nil)
+
+const (
+	// ClusterLabel is set on every object returned by Multi to the name of the cluster it came from.
+	ClusterLabel = "mink.acorn.io/cluster"
+	// ClusterSeparator separates the cluster name prefix from the name of the object in that cluster.
+	ClusterSeparator = "."
+)
+
+// Multi presents the objects of one type in many clusters as if they were stored in one. Objects are named
+// "<cluster>.<name>" and labeled with ClusterLabel, writes are routed to the cluster in the name prefix. Lists and
+// watches span all clusters. Resource versions are only meaningful to the cluster they came from, so lists are not
+// paginated and have no resource version, and watches always start with the current state of every cluster.
+type Multi struct {
+	obj      types.Object
+	objList  types.ObjectList
+	scheme   *runtime.Scheme
+	clusters map[string]strategy.CompleteStrategy
+	names    []string
+}
+
+// NewMulti returns a strategy spanning clusters, keyed by cluster name. Cluster names must not contain
+// ClusterSeparator.
+func NewMulti(obj types.Object, scheme *runtime.Scheme, clusters map[string]strategy.CompleteStrategy) (*Multi, error) {
+	m := &Multi{
+		obj:      obj,
+		objList:  types.MustGetListType(obj, scheme),
+		scheme:   scheme,
+		clusters: clusters,
+	}
+	for name := range clusters {
+		if name == "" || strings.Contains(name, ClusterSeparator) {
+			return nil, fmt.Errorf("invalid cluster name %q", name)
+		}
+		m.names = append(m.names, name)
+	}
+	sort.Strings(m.names)
+	return m, nil
+}
+
+func (m *Multi) New() types.Object {
+	return m.obj.DeepCopyObject().(types.Object)
+}
+
+func (m *Multi) NewList() types.ObjectList {
+	return m.objList.DeepCopyObject().(types.ObjectList)
+}
+
+func (m *Multi) Scheme() *runtime.Scheme {
+	return m.scheme
+}
+
+func (m *Multi) Destroy() {
+	for _, cluster := range m.clusters {
+		cluster.Destroy()
+	}
+}
+
+// split returns the cluster of a prefixed name and the name in that cluster.
+func (m *Multi) split(name string) (string, strategy.CompleteStrategy, string, error) {
+	clusterName, remoteName, ok := strings.Cut(name, ClusterSeparator)
+	cluster := m.clusters[clusterName]
+	if !ok || cluster == nil {
+		return "", nil, "", apierrors.NewBadRequest(fmt.Sprintf("name %q does not start with a known cluster name and %q", name, ClusterSeparator))
+	}
+	return clusterName, cluster, remoteName, nil
+}
+
+func decorate(clusterName string, obj types.Object) {
+	obj.SetName(clusterName + ClusterSeparator + obj.GetName())
+	objLabels := obj.GetLabels()
+	if objLabels == nil {
+		objLabels = map[string]string{}
+	}
+	objLabels[ClusterLabel] = clusterName
+	obj.SetLabels(objLabels)
+}
+
+func undecorate(remoteName string, obj types.Object) types.Object {
+	obj = obj.DeepCopyObject().(types.Object)
+	obj.SetName(remoteName)
+	objLabels := obj.GetLabels()
+	delete(objLabels, ClusterLabel)
+	obj.SetLabels(objLabels)
+	return obj
+}
+
+func (m *Multi) Get(ctx context.Context, namespace, name string) (types.Object, error) {
+	clusterName, cluster, remoteName, err := m.split(name)
+	if err != nil {
+		return nil, err
+	}
+	obj, err := cluster.Get(ctx, namespace, remoteName)
+	if err != nil {
+		return nil, err
+	}
+	decorate(clusterName, obj)
+	return obj, nil
+}
+
+// write routes obj to the cluster in its name and returns the result as named by Multi.
+func (m *Multi) write(obj types.Object, do func(cluster strategy.CompleteStrategy, obj types.Object) (types.Object, error)) (types.Object, error) {
+	clusterName, cluster, remoteName, err := m.split(obj.GetName())
+	if err != nil {
+		return nil, err
+	}
+	if label := obj.GetLabels()[ClusterLabel]; label != "" && label != clusterName {
+		return nil, apierrors.NewBadRequest(fmt.Sprintf("label %s=%s does not match the cluster %s of the name", ClusterLabel, label, clusterName))
+	}
+	result, err := do(cluster, undecorate(remoteName, obj))
+	if err != nil {
+		return nil, err
+	}
+	decorate(clusterName, result)
+	return result, nil
+}
+
+func (m *Multi) Create(ctx context.Context, obj types.Object) (types.Object, error) {
+	return m.write(obj, func(cluster strategy.CompleteStrategy, obj types.Object) (types.Object, error) {
+		return cluster.Create(ctx, obj)
+	})
+}
+
+func (m *Multi) Update(ctx context.Context, obj types.Object) (types.Object, error) {
+	return m.write(obj, func(cluster strategy.CompleteStrategy, obj types.Object) (types.Object, error) {
+		return cluster.Update(ctx, obj)
+	})
+}
+
+func (m *Multi) UpdateStatus(ctx context.Context, obj types.Object) (types.Object, error) {
+	return m.write(obj, func(cluster strategy.CompleteStrategy, obj types.Object) (types.Object, error) {
+		return cluster.UpdateStatus(ctx, obj)
+	})
+}
+
+func (m *Multi) Delete(ctx context.Context, obj types.Object) (types.Object, error) {
+	return m.write(obj, func(cluster strategy.CompleteStrategy, obj types.Object) (types.Object, error) {
+		return cluster.Delete(ctx, obj)
+	})
+}
+
+func (m *Multi) DeleteCollection(ctx context.Context, namespace string, opts strategy.DeleteCollectionOptions) (types.ObjectList, error) {
+	return strategy.DeleteEach(ctx, m, namespace, opts)
+}
+
+// remoteOptions returns the options passed to every cluster. Requirements on the cluster label and on names only
+// make sense to Multi, they are removed and applied by matches instead.
+func remoteOptions(opts storage.ListOptions) storage.ListOptions {
+	opts.ResourceVersion = ""
+	opts.ResourceVersionMatch = ""
+	opts.Predicate.Limit = 0
+	opts.Predicate.Continue = ""
+
+	if opts.Predicate.Label != nil {
+		requirements, _ := opts.Predicate.Label.Requirements()
+		remote := labels.NewSelector()
+		for _, requirement := range requirements {
+			if requirement.Key() != ClusterLabel {
+				remote = remote.Add(requirement)
+			}
+		}
+		opts.Predicate.Label = remote
+	}
+
+	if opts.Predicate.Field != nil {
+		var remote []fields.Selector
+		for _, requirement := range opts.Predicate.Field.Requirements() {
+			if requirement.Field == "metadata.name" {
+				continue
+			}
+			if requirement.Operator == selection.NotEquals {
+				remote = append(remote, fields.OneTermNotEqualSelector(requirement.Field, requirement.Value))
+			} else {
+				remote = append(remote, fields.OneTermEqualSelector(requirement.Field, requirement.Value))
+			}
+		}
+		opts.Predicate.Field = fields.AndSelectors(remote...)
+	}
+	return opts
+}
+
+// matches evaluates the label and name requirements removed by remoteOptions on a decorated object.
+func matches(opts storage.ListOptions, obj types.Object) bool {
+	if opts.Predicate.Label != nil && !opts.Predicate.Label.Matches(labels.Set(obj.GetLabels())) {
+		return false
+	}
+	if opts.Predicate.Field != nil {
+		for _, requirement := range opts.Predicate.Field.Requirements() {
+			if requirement.Field != "metadata.name" {
+				continue
+			}
+			if (requirement.Operator == selection.NotEquals) == (obj.GetName() == requirement.Value) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func (m *Multi) List(ctx context.Context, namespace string, opts storage.ListOptions) (types.ObjectList, error) {
+	remoteOpts := remoteOptions(opts)
+
+	var items []runtime.Object
+	for _, clusterName := range m.names {
+		list, err := m.clusters[clusterName].List(ctx, namespace, remoteOpts)
+		if err != nil {
+			return nil, fmt.Errorf("listing cluster %s: %w", clusterName, err)
+		}
+		err = meta.EachListItem(list, func(item runtime.Object) error {
+			obj := item.(types.Object)
+			decorate(clusterName, obj)
+			if matches(opts, obj) {
+				items = append(items, obj)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	result := m.NewList()
+	return result, meta.SetList(result, items)
+}
+
+func (m *Multi) Watch(ctx context.Context, namespace string, opts storage.ListOptions) (<-chan watch.Event, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	remoteOpts := remoteOptions(opts)
+
+	var inputs []<-chan watch.Event
+	for _, clusterName := range m.names {
+		events, err := m.clusters[clusterName].Watch(ctx, namespace, remoteOpts)
+		if err != nil {
+			cancel()
+			return nil, fmt.Errorf("watching cluster %s: %w", clusterName, err)
+		}
+		inputs = append(inputs, events)
+	}
+
+	result := make(chan watch.Event)
+	var wg sync.WaitGroup
+	for i, events := range inputs {
+		wg.Add(1)
+		go func(clusterName string, events <-chan watch.Event) {
+			defer wg.Done()
+			// the watch of every cluster ends with the others, a partial view would silently miss changes
+			defer cancel()
+			for event := range events {
+				switch event.Type {
+				case watch.Bookmark:
+					continue
+				case watch.Added, watch.Modified, watch.Deleted:
+					obj, ok := event.Object.(types.Object)
+					if !ok {
+						continue
+					}
+					decorate(clusterName, obj)
+					if !matches(opts, obj) {
+						continue
+					}
+				}
+				select {
+				case result <- event:
+				case <-ctx.Done():
+				}
+			}
+		}(m.names[i], events)
+	}
+	go func() {
+		wg.Wait()
+		cancel()
+		close(result)
+	}()
+	return result, nil
+}
@@ -0,0 +1,184 @@
+package remote
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/acorn-io/mink/pkg/db"
+	"github.com/acorn-io/mink/pkg/strategy"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/apiserver/pkg/storage"
+	"k8s.io/client-go/kubernetes/scheme"
+)
+
+func newTestMulti(t *testing.T, clusterNames ...string) (*Multi, map[string]strategy.CompleteStrategy) {
+	clusters := map[string]strategy.CompleteStrategy{}
+	for _, name := range clusterNames {
+		f, err := db.NewFactory(scheme.Scheme, "sqlite://file:"+t.Name()+"-"+name+"?mode=memory&cache=shared")
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() {
+			_ = f.SQLDB.Close()
+		})
+		clusters[name], err = f.NewDBStrategy(&corev1.ConfigMap{})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	multi, err := NewMulti(&corev1.ConfigMap{}, scheme.Scheme, clusters)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return multi, clusters
+}
+
+func listNames(t *testing.T, multi *Multi, opts storage.ListOptions) (result []string) {
+	t.Helper()
+	list, err := multi.List(context.Background(), "default", opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, item := range list.(*corev1.ConfigMapList).Items {
+		assert.Equal(t, item.Name[:1], item.Labels[ClusterLabel])
+		result = append(result, item.Name)
+	}
+	return result
+}
+
+func TestMultiRouting(t *testing.T) {
+	_, err := NewMulti(&corev1.ConfigMap{}, scheme.Scheme, map[string]strategy.CompleteStrategy{"a.b": nil})
+	assert.Error(t, err)
+
+	multi, clusters := newTestMulti(t, "a", "b")
+	ctx := context.Background()
+
+	// writes go to the cluster of the name prefix, without the prefix and the cluster label
+	for _, name := range []string{"b.one", "a.one", "b.two"} {
+		created, err := multi.Create(ctx, &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: "default",
+			Labels:    map[string]string{"app": name[2:]},
+		}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, name, created.GetName())
+		assert.Equal(t, name[:1], created.GetLabels()[ClusterLabel])
+	}
+	remote, err := clusters["b"].Get(ctx, "default", "two")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.NotContains(t, remote.GetLabels(), ClusterLabel)
+
+	_, err = multi.Create(ctx, &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "c.one", Namespace: "default"}})
+	assert.True(t, apierrors.IsBadRequest(err), "expected bad request, got %v", err)
+	_, err = multi.Create(ctx, &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{
+		Name:      "a.three",
+		Namespace: "default",
+		Labels:    map[string]string{ClusterLabel: "b"},
+	}})
+	assert.True(t, apierrors.IsBadRequest(err), "expected bad request, got %v", err)
+
+	obj, err := multi.Get(ctx, "default", "a.one")
+	if err != nil {
+		t.Fatal(err)
+	}
+	obj.(*corev1.ConfigMap).Data = map[string]string{"updated": "true"}
+	updated, err := multi.Update(ctx, obj)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "a.one", updated.GetName())
+	remote, err = clusters["a"].Get(ctx, "default", "one")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "true", remote.(*corev1.ConfigMap).Data["updated"])
+	_, err = clusters["b"].Get(ctx, "default", "three")
+	assert.True(t, apierrors.IsNotFound(err))
+
+	// lists merge the clusters in order of their names, selectors on the cluster label and names apply to the result
+	assert.Equal(t, []string{"a.one", "b.one", "b.two"}, listNames(t, multi, storage.ListOptions{Predicate: storage.Everything}))
+	assert.Equal(t, []string{"b.one", "b.two"}, listNames(t, multi, storage.ListOptions{Predicate: storage.SelectionPredicate{
+		Label:    labels.SelectorFromSet(labels.Set{ClusterLabel: "b"}),
+		Field:    fields.Everything(),
+		GetAttrs: storage.DefaultNamespaceScopedAttr,
+	}}))
+	assert.Equal(t, []string{"b.one"}, listNames(t, multi, storage.ListOptions{Predicate: storage.SelectionPredicate{
+		Label:    labels.Everything(),
+		Field:    fields.OneTermEqualSelector("metadata.name", "b.one"),
+		GetAttrs: storage.DefaultNamespaceScopedAttr,
+	}}))
+	assert.Equal(t, []string{"a.one", "b.one"}, listNames(t, multi, storage.ListOptions{Predicate: storage.SelectionPredicate{
+		Label:    labels.SelectorFromSet(labels.Set{"app": "one"}),
+		Field:    fields.Everything(),
+		GetAttrs: storage.DefaultNamespaceScopedAttr,
+	}}))
+
+	now := metav1.Now()
+	updated.SetDeletionTimestamp(&now)
+	_, err = multi.Delete(ctx, updated)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = multi.Get(ctx, "default", "a.one")
+	assert.True(t, apierrors.IsNotFound(err), "expected not found, got %v", err)
+	assert.Equal(t, []string{"b.one", "b.two"}, listNames(t, multi, storage.ListOptions{Predicate: storage.Everything}))
+}
+
+func TestMultiWatch(t *testing.T) {
+	multi, _ := newTestMulti(t, "a", "b")
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	events, err := multi.Watch(ctx, "default", storage.ListOptions{Predicate: storage.SelectionPredicate{
+		Label:    labels.SelectorFromSet(labels.Set{ClusterLabel: "b"}),
+		Field:    fields.Everything(),
+		GetAttrs: storage.DefaultNamespaceScopedAttr,
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the watch fans in the events of every cluster, filtered by the cluster label
+	for _, name := range []string{"a.one", "b.one", "a.two", "b.two"} {
+		if _, err := multi.Create(ctx, &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var names []string
+	for len(names) < 2 {
+		select {
+		case event := <-events:
+			assert.Equal(t, watch.Added, event.Type)
+			cm := event.Object.(*corev1.ConfigMap)
+			assert.Equal(t, "b", cm.Labels[ClusterLabel])
+			names = append(names, cm.Name)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out waiting for events, got %v", names)
+		}
+	}
+	assert.Equal(t, []string{"b.one", "b.two"}, names)
+
+	// the watch ends once the watches of the clusters end
+	cancel()
+	for {
+		select {
+		case _, ok := <-events:
+			if !ok {
+				return
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for the watch to end")
+		}
+	}
+}
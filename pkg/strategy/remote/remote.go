@@ -73,6 +73,10 @@ func (r *Remote) Delete(ctx context.Context, obj types.Object) (types.Object, er
 	return obj, r.c.Delete(ctx, obj)
 }
 
+func (r *Remote) DeleteCollection(ctx context.Context, namespace string, opts strategy.DeleteCollectionOptions) (types.ObjectList, error) {
+	return strategy.DeleteEach(ctx, r, namespace, opts)
+}
+
 func (r *Remote) Watch(ctx context.Context, namespace string, opts storage.ListOptions) (<-chan watch.Event, error) {
 	list := r.NewList().(types.ObjectList)
 	listOpts := strategy.ToListOpts(namespace, opts)
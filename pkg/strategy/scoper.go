@@ -5,7 +5,8 @@ type Scoper interface {
 }
 
 type ScoperAdapter struct {
-	strategy Newer
+	strategy        Newer
+	NamespaceScoper NamespaceScoper
 }
 
 func NewScoper(strategy Newer) *ScoperAdapter {
@@ -16,12 +17,22 @@ func NewScoper(strategy Newer) *ScoperAdapter {
 
 func (s *ScoperAdapter) NamespaceScoped() bool {
 	if s != nil {
-		if o, ok := s.strategy.(NamespaceScoper); ok {
-			return o.NamespaceScoped()
-		}
-		if o, ok := s.strategy.New().(NamespaceScoper); ok {
-			return o.NamespaceScoped()
-		}
+		return namespaceScoped(s.NamespaceScoper, s.strategy)
+	}
+	return true
+}
+
+// namespaceScoped determines the scope of a resource from the explicit override, then the strategy, then the object.
+// Resources that don't declare a scope anywhere are namespaced.
+func namespaceScoped(override NamespaceScoper, strategy Newer) bool {
+	if override != nil {
+		return override.NamespaceScoped()
+	}
+	if o, ok := strategy.(NamespaceScoper); ok {
+		return o.NamespaceScoped()
+	}
+	if o, ok := strategy.New().(NamespaceScoper); ok {
+		return o.NamespaceScoped()
 	}
 	return true
 }
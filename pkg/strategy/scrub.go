@@ -0,0 +1,87 @@
+package strategy
+
+import (
+	"context"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+)
+
+// Scrubber strips or rewrites internal fields, such as bookkeeping annotations and labels, from an object before it
+// is returned from the API. Scrub may modify obj in place.
+type Scrubber interface {
+	Scrub(ctx context.Context, obj runtime.Object)
+}
+
+type ScrubberFunc func(ctx context.Context, obj runtime.Object)
+
+func (s ScrubberFunc) Scrub(ctx context.Context, obj runtime.Object) {
+	s(ctx, obj)
+}
+
+// NewPrefixScrubber returns a Scrubber that removes all annotations and labels whose key starts with one of the
+// given prefixes.
+func NewPrefixScrubber(prefixes ...string) Scrubber {
+	return ScrubberFunc(func(_ context.Context, obj runtime.Object) {
+		m, err := meta.Accessor(obj)
+		if err != nil {
+			return
+		}
+		m.SetAnnotations(removePrefixed(m.GetAnnotations(), prefixes))
+		m.SetLabels(removePrefixed(m.GetLabels(), prefixes))
+	})
+}
+
+func removePrefixed(data map[string]string, prefixes []string) map[string]string {
+	for key := range data {
+		for _, prefix := range prefixes {
+			if strings.HasPrefix(key, prefix) {
+				delete(data, key)
+				break
+			}
+		}
+	}
+	if len(data) == 0 {
+		return nil
+	}
+	return data
+}
+
+func getScrubber(override Scrubber, strategy any) Scrubber {
+	if override != nil {
+		return override
+	}
+	if s, ok := strategy.(Scrubber); ok {
+		return s
+	}
+	return nil
+}
+
+func scrubList(ctx context.Context, scrubber Scrubber, list runtime.Object) error {
+	if scrubber == nil {
+		return nil
+	}
+	return meta.EachListItem(list, func(obj runtime.Object) error {
+		scrubber.Scrub(ctx, obj)
+		return nil
+	})
+}
+
+func scrubEvents(ctx context.Context, scrubber Scrubber, c <-chan watch.Event) <-chan watch.Event {
+	if scrubber == nil {
+		return c
+	}
+	result := make(chan watch.Event)
+	go func() {
+		defer close(result)
+		for event := range c {
+			if event.Type != watch.Error && event.Type != watch.Bookmark {
+				scrubber.Scrub(ctx, event.Object)
+			}
+			result <- event
+		}
+	}()
+	return result
+}
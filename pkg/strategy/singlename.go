@@ -4,12 +4,27 @@ import (
 	"strings"
 
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apiserver/pkg/registry/rest"
 	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
 )
 
+var (
+	_ rest.SingularNameProvider   = (*SingularNameAdapter)(nil)
+	_ rest.ShortNamesProvider     = (*SingularNameAdapter)(nil)
+	_ rest.CategoriesProvider     = (*SingularNameAdapter)(nil)
+	_ rest.StorageVersionProvider = (*SingularNameAdapter)(nil)
+)
+
+// SingularNameAdapter provides the discovery metadata of a resource. Every field is optional, by default the
+// singular name is the lowercase kind and no short names, categories or storage version hash are published.
 type SingularNameAdapter struct {
 	Object runtime.Object
 	Scheme *runtime.Scheme
+
+	Singular         string
+	ShortNameList    []string
+	CategoryList     []string
+	StorageVersioner runtime.GroupVersioner
 }
 
 func NewSingularNameAdapter(obj runtime.Object, scheme *runtime.Scheme) *SingularNameAdapter {
@@ -20,9 +35,25 @@ func NewSingularNameAdapter(obj runtime.Object, scheme *runtime.Scheme) *Singula
 }
 
 func (s *SingularNameAdapter) GetSingularName() string {
+	if s.Singular != "" {
+		return s.Singular
+	}
 	name, err := apiutil.GVKForObject(s.Object, s.Scheme)
 	if err != nil {
 		panic(err)
 	}
 	return strings.ToLower(name.Kind)
 }
+
+func (s *SingularNameAdapter) ShortNames() []string {
+	return s.ShortNameList
+}
+
+func (s *SingularNameAdapter) Categories() []string {
+	return s.CategoryList
+}
+
+// StorageVersion is used by the API server to compute the storageVersionHash published in discovery.
+func (s *SingularNameAdapter) StorageVersion() runtime.GroupVersioner {
+	return s.StorageVersioner
+}
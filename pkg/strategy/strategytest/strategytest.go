@@ -0,0 +1,430 @@
+// Package strategytest provides a conformance suite for strategy.CompleteStrategy implementations. Backends that
+// replace or wrap db.Strategy can run it to prove they follow the same semantics for conflicts, resource versions,
+// pagination, selectors and watches.
+package strategytest
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/acorn-io/mink/pkg/strategy"
+	"github.com/acorn-io/mink/pkg/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/apiserver/pkg/storage"
+)
+
+const (
+	namespace    = "strategytest"
+	eventTimeout = 10 * time.Second
+)
+
+// NewStrategyFunc returns a new strategy backed by empty storage. It is called once for each test case.
+type NewStrategyFunc func(t *testing.T) strategy.CompleteStrategy
+
+// Run runs the conformance suite against the strategies returned by newStrategy. The strategy may store any
+// namespaced type, the suite only relies on object metadata.
+func Run(t *testing.T, newStrategy NewStrategyFunc) {
+	cases := []struct {
+		name string
+		test func(t *testing.T, s strategy.CompleteStrategy)
+	}{
+		{"CreateAndGet", testCreateAndGet},
+		{"CreateAlreadyExists", testCreateAlreadyExists},
+		{"GetNotFound", testGetNotFound},
+		{"UpdateConflict", testUpdateConflict},
+		{"UpdateNotFound", testUpdateNotFound},
+		{"ResourceVersion", testResourceVersion},
+		{"Delete", testDelete},
+		{"Pagination", testPagination},
+		{"LabelSelector", testLabelSelector},
+		{"Namespace", testNamespace},
+		{"WatchOrdering", testWatchOrdering},
+		{"WatchFromResourceVersion", testWatchFromResourceVersion},
+		{"Compaction", testCompaction},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			s := newStrategy(t)
+			t.Cleanup(s.Destroy)
+			c.test(t, s)
+		})
+	}
+}
+
+func everything() storage.SelectionPredicate {
+	return storage.SelectionPredicate{
+		Label:    labels.Everything(),
+		Field:    fields.Everything(),
+		GetAttrs: storage.DefaultNamespaceScopedAttr,
+	}
+}
+
+func newObject(s strategy.CompleteStrategy, namespace, name string, labels map[string]string) types.Object {
+	obj := s.New()
+	obj.SetNamespace(namespace)
+	obj.SetName(name)
+	obj.SetLabels(labels)
+	return obj
+}
+
+func create(t *testing.T, s strategy.CompleteStrategy, namespace, name string, labels map[string]string) types.Object {
+	t.Helper()
+	obj, err := s.Create(context.Background(), newObject(s, namespace, name, labels))
+	require.NoError(t, err)
+	return obj
+}
+
+func update(t *testing.T, s strategy.CompleteStrategy, obj types.Object, value string) types.Object {
+	t.Helper()
+	obj = obj.DeepCopyObject().(types.Object)
+	obj.SetAnnotations(map[string]string{"strategytest": value})
+	result, err := s.Update(context.Background(), obj)
+	require.NoError(t, err)
+	return result
+}
+
+func remove(t *testing.T, s strategy.CompleteStrategy, obj types.Object) types.Object {
+	t.Helper()
+	obj = obj.DeepCopyObject().(types.Object)
+	now := metav1.Now()
+	obj.SetDeletionTimestamp(&now)
+	result, err := s.Delete(context.Background(), obj)
+	require.NoError(t, err)
+	return result
+}
+
+func resourceVersion(t *testing.T, obj metav1.Common) uint64 {
+	t.Helper()
+	rv, err := strconv.ParseUint(obj.GetResourceVersion(), 10, 64)
+	require.NoError(t, err, "resource versions must be unsigned integers")
+	return rv
+}
+
+func names(t *testing.T, list types.ObjectList) (result []string) {
+	t.Helper()
+	require.NoError(t, meta.EachListItem(list, func(obj runtime.Object) error {
+		m, err := meta.Accessor(obj)
+		if err != nil {
+			return err
+		}
+		result = append(result, m.GetName())
+		return nil
+	}))
+	return result
+}
+
+func list(t *testing.T, s strategy.CompleteStrategy, namespace string, opts storage.ListOptions) types.ObjectList {
+	t.Helper()
+	if opts.Predicate.Label == nil {
+		opts.Predicate = everything()
+	}
+	result, err := s.List(context.Background(), namespace, opts)
+	require.NoError(t, err)
+	return result
+}
+
+func watchEvents(t *testing.T, s strategy.CompleteStrategy, resourceVersion string, count int) []watch.Event {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	c, err := s.Watch(ctx, namespace, storage.ListOptions{
+		ResourceVersion: resourceVersion,
+		Predicate:       everything(),
+	})
+	require.NoError(t, err)
+	return collect(t, c, count)
+}
+
+func collect(t *testing.T, c <-chan watch.Event, count int) []watch.Event {
+	t.Helper()
+	var events []watch.Event
+	for len(events) < count {
+		select {
+		case event, ok := <-c:
+			require.True(t, ok, "watch closed after %d of %d events", len(events), count)
+			if event.Type == watch.Bookmark {
+				continue
+			}
+			require.NotEqual(t, watch.Error, event.Type, "unexpected error event: %v", event.Object)
+			events = append(events, event)
+		case <-time.After(eventTimeout):
+			t.Fatalf("timed out waiting for watch events, got %d of %d", len(events), count)
+		}
+	}
+	return events
+}
+
+func eventName(t *testing.T, event watch.Event) string {
+	t.Helper()
+	m, err := meta.Accessor(event.Object)
+	require.NoError(t, err)
+	return m.GetName()
+}
+
+func testCreateAndGet(t *testing.T, s strategy.CompleteStrategy) {
+	created := create(t, s, namespace, "a", map[string]string{"app": "a"})
+	assert.NotEmpty(t, created.GetUID())
+	assert.NotEmpty(t, created.GetResourceVersion())
+	assert.False(t, created.GetCreationTimestamp().Time.IsZero())
+
+	obj, err := s.Get(context.Background(), namespace, "a")
+	require.NoError(t, err)
+	assert.Equal(t, created.GetUID(), obj.GetUID())
+	assert.Equal(t, created.GetResourceVersion(), obj.GetResourceVersion())
+	assert.Equal(t, "a", obj.GetLabels()["app"])
+}
+
+func testCreateAlreadyExists(t *testing.T, s strategy.CompleteStrategy) {
+	create(t, s, namespace, "a", nil)
+	_, err := s.Create(context.Background(), newObject(s, namespace, "a", nil))
+	assert.True(t, apierrors.IsAlreadyExists(err), "expected AlreadyExists, got %v", err)
+
+	// the same name in another namespace is a different object
+	create(t, s, namespace+"-other", "a", nil)
+}
+
+func testGetNotFound(t *testing.T, s strategy.CompleteStrategy) {
+	_, err := s.Get(context.Background(), namespace, "missing")
+	assert.True(t, apierrors.IsNotFound(err), "expected NotFound, got %v", err)
+}
+
+func testUpdateConflict(t *testing.T, s strategy.CompleteStrategy) {
+	created := create(t, s, namespace, "a", nil)
+	update(t, s, created, "first")
+
+	stale := created.DeepCopyObject().(types.Object)
+	stale.SetAnnotations(map[string]string{"strategytest": "second"})
+	_, err := s.Update(context.Background(), stale)
+	assert.True(t, apierrors.IsConflict(err), "expected Conflict, got %v", err)
+
+	obj, err := s.Get(context.Background(), namespace, "a")
+	require.NoError(t, err)
+	assert.Equal(t, "first", obj.GetAnnotations()["strategytest"])
+}
+
+func testUpdateNotFound(t *testing.T, s strategy.CompleteStrategy) {
+	obj := newObject(s, namespace, "missing", nil)
+	obj.SetResourceVersion("1")
+	_, err := s.Update(context.Background(), obj)
+	assert.True(t, apierrors.IsNotFound(err), "expected NotFound, got %v", err)
+}
+
+func testResourceVersion(t *testing.T, s strategy.CompleteStrategy) {
+	a := create(t, s, namespace, "a", nil)
+	b := create(t, s, namespace, "b", nil)
+	updated := update(t, s, a, "value")
+
+	assert.Greater(t, resourceVersion(t, b), resourceVersion(t, a))
+	assert.Greater(t, resourceVersion(t, updated), resourceVersion(t, b))
+
+	objs := list(t, s, namespace, storage.ListOptions{})
+	listMeta, err := meta.ListAccessor(objs)
+	require.NoError(t, err)
+	assert.GreaterOrEqual(t, resourceVersion(t, listMeta), resourceVersion(t, updated))
+}
+
+func testDelete(t *testing.T, s strategy.CompleteStrategy) {
+	created := create(t, s, namespace, "a", nil)
+	remove(t, s, created)
+
+	_, err := s.Get(context.Background(), namespace, "a")
+	assert.True(t, apierrors.IsNotFound(err), "expected NotFound, got %v", err)
+	assert.Empty(t, names(t, list(t, s, namespace, storage.ListOptions{})))
+
+	// the name can be reused once the object is gone
+	recreated := create(t, s, namespace, "a", nil)
+	assert.NotEqual(t, created.GetUID(), recreated.GetUID())
+}
+
+func testPagination(t *testing.T, s strategy.CompleteStrategy) {
+	var expected []string
+	for i := 0; i < 5; i++ {
+		name := "obj-" + strconv.Itoa(i)
+		create(t, s, namespace, name, nil)
+		expected = append(expected, name)
+	}
+
+	var (
+		result []string
+		token  string
+	)
+	for pages := 0; ; pages++ {
+		require.Less(t, pages, 5, "too many pages")
+
+		opts := storage.ListOptions{
+			Predicate: everything(),
+		}
+		opts.Predicate.Limit = 2
+		opts.Predicate.Continue = token
+		page := list(t, s, namespace, opts)
+
+		pageNames := names(t, page)
+		assert.LessOrEqual(t, len(pageNames), 2)
+		result = append(result, pageNames...)
+
+		listMeta, err := meta.ListAccessor(page)
+		require.NoError(t, err)
+		if listMeta.GetContinue() == "" {
+			break
+		}
+		token = listMeta.GetContinue()
+	}
+
+	assert.ElementsMatch(t, expected, result)
+}
+
+func testLabelSelector(t *testing.T, s strategy.CompleteStrategy) {
+	create(t, s, namespace, "a", map[string]string{"app": "a", "tier": "web"})
+	create(t, s, namespace, "b", map[string]string{"app": "b", "tier": "web"})
+	create(t, s, namespace, "c", map[string]string{"app": "c"})
+
+	for selector, expected := range map[string][]string{
+		"app=a":         {"a"},
+		"tier=web":      {"a", "b"},
+		"app!=a":        {"b", "c"},
+		"app in (a,c)":  {"a", "c"},
+		"!tier":         {"c"},
+		"tier,app=b":    {"b"},
+		"app notin (a)": {"b", "c"},
+		"app=missing":   nil,
+	} {
+		sel, err := labels.Parse(selector)
+		require.NoError(t, err)
+
+		opts := storage.ListOptions{
+			Predicate: everything(),
+		}
+		opts.Predicate.Label = sel
+		assert.ElementsMatch(t, expected, names(t, list(t, s, namespace, opts)), "selector %q", selector)
+	}
+}
+
+func testNamespace(t *testing.T, s strategy.CompleteStrategy) {
+	create(t, s, namespace, "a", nil)
+	create(t, s, namespace+"-other", "b", nil)
+
+	assert.ElementsMatch(t, []string{"a"}, names(t, list(t, s, namespace, storage.ListOptions{})))
+	assert.ElementsMatch(t, []string{"b"}, names(t, list(t, s, namespace+"-other", storage.ListOptions{})))
+	assert.ElementsMatch(t, []string{"a", "b"}, names(t, list(t, s, "", storage.ListOptions{})))
+}
+
+func testWatchOrdering(t *testing.T, s strategy.CompleteStrategy) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// watch from the exact resource version of a list, an empty list may report "0" which means any version
+	create(t, s, namespace, "seed", nil)
+	initial := list(t, s, namespace, storage.ListOptions{})
+	listMeta, err := meta.ListAccessor(initial)
+	require.NoError(t, err)
+
+	c, err := s.Watch(ctx, namespace, storage.ListOptions{
+		ResourceVersion: listMeta.GetResourceVersion(),
+		Predicate:       everything(),
+	})
+	require.NoError(t, err)
+
+	a := create(t, s, namespace, "a", nil)
+	a = update(t, s, a, "value")
+	create(t, s, namespace, "b", nil)
+	remove(t, s, a)
+
+	events := collect(t, c, 4)
+	assert.Equal(t, watch.Added, events[0].Type)
+	assert.Equal(t, "a", eventName(t, events[0]))
+	assert.Equal(t, watch.Modified, events[1].Type)
+	assert.Equal(t, "a", eventName(t, events[1]))
+	assert.Equal(t, watch.Added, events[2].Type)
+	assert.Equal(t, "b", eventName(t, events[2]))
+	assert.Equal(t, watch.Deleted, events[3].Type)
+	assert.Equal(t, "a", eventName(t, events[3]))
+
+	assertIncreasing(t, events)
+}
+
+func testWatchFromResourceVersion(t *testing.T, s strategy.CompleteStrategy) {
+	a := create(t, s, namespace, "a", nil)
+	update(t, s, a, "value")
+	create(t, s, namespace, "b", nil)
+
+	for _, rv := range []string{"", "0"} {
+		events := watchEvents(t, s, rv, 2)
+		for _, event := range events {
+			assert.Equal(t, watch.Added, event.Type, "resource version %q", rv)
+		}
+		byName := map[string]types.Object{}
+		for _, event := range events {
+			byName[eventName(t, event)] = event.Object.(types.Object)
+		}
+		require.Contains(t, byName, "a")
+		require.Contains(t, byName, "b")
+		assert.Equal(t, "value", byName["a"].GetAnnotations()["strategytest"], "initial state must be the latest state")
+	}
+
+	events := watchEvents(t, s, a.GetResourceVersion(), 2)
+	assert.Equal(t, watch.Modified, events[0].Type)
+	assert.Equal(t, "a", eventName(t, events[0]))
+	assert.Equal(t, watch.Added, events[1].Type)
+	assert.Equal(t, "b", eventName(t, events[1]))
+	assertIncreasing(t, events)
+
+	_, err := s.Watch(context.Background(), namespace, storage.ListOptions{
+		ResourceVersion: "invalid",
+		Predicate:       everything(),
+	})
+	assert.True(t, apierrors.IsBadRequest(err), "expected BadRequest, got %v", err)
+}
+
+// testCompaction checks that watching from a resource version that may have been compacted either replays every
+// change after it in order or fails with 410 Gone, so clients know to relist.
+func testCompaction(t *testing.T, s strategy.CompleteStrategy) {
+	a := create(t, s, namespace, "a", nil)
+	for i := 0; i < 3; i++ {
+		a = update(t, s, a, strconv.Itoa(i))
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	c, err := s.Watch(ctx, namespace, storage.ListOptions{
+		ResourceVersion: "1",
+		Predicate:       everything(),
+	})
+	if err != nil {
+		assert.True(t, apierrors.IsResourceExpired(err) || apierrors.IsGone(err), "expected Gone, got %v", err)
+		return
+	}
+
+	// depending on the first resource version of the backend the create may or may not be replayed
+	var events []watch.Event
+	for len(events) < 4 {
+		events = append(events, collect(t, c, 1)...)
+		if events[len(events)-1].Object.(types.Object).GetAnnotations()["strategytest"] == "2" {
+			break
+		}
+	}
+	assert.Equal(t, "2", events[len(events)-1].Object.(types.Object).GetAnnotations()["strategytest"])
+	assertIncreasing(t, events)
+}
+
+func assertIncreasing(t *testing.T, events []watch.Event) {
+	t.Helper()
+	var last uint64
+	for _, event := range events {
+		rv := resourceVersion(t, event.Object.(types.Object))
+		assert.Greater(t, rv, last, "watch events must be ordered by resource version")
+		last = rv
+	}
+}
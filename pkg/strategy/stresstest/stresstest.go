@@ -0,0 +1,396 @@
+// Package stresstest runs concurrent writes against a strategy while many watches are started and randomly
+// cancelled, checking that no events are missed, resource versions only increase and no goroutines are leaked.
+package stresstest
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"runtime"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/acorn-io/mink/pkg/strategy"
+	"github.com/acorn-io/mink/pkg/types"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/apiserver/pkg/storage"
+)
+
+type Options struct {
+	// Writers is the number of goroutines creating, updating and deleting objects. Defaults to 4.
+	Writers int
+	// Objects is the number of object names each writer cycles through. Defaults to 5.
+	Objects int
+	// Watchers is the number of goroutines repeatedly starting and cancelling watches. Defaults to 8.
+	Watchers int
+	// Duration is how long writes are generated for. Defaults to 2 seconds.
+	Duration time.Duration
+	// Settle is how long to wait for watches to catch up and goroutines to exit after the writes stopped.
+	// Defaults to 30 seconds.
+	Settle time.Duration
+	// Namespace the objects are created in. Defaults to "stresstest".
+	Namespace string
+	// Seed for the random choices, a failing run can be reproduced with the seed it logged. Defaults to the
+	// current time.
+	Seed int64
+}
+
+func (o Options) complete() Options {
+	if o.Writers <= 0 {
+		o.Writers = 4
+	}
+	if o.Objects <= 0 {
+		o.Objects = 5
+	}
+	if o.Watchers <= 0 {
+		o.Watchers = 8
+	}
+	if o.Duration <= 0 {
+		o.Duration = 2 * time.Second
+	}
+	if o.Settle <= 0 {
+		o.Settle = 30 * time.Second
+	}
+	if o.Namespace == "" {
+		o.Namespace = "stresstest"
+	}
+	if o.Seed == 0 {
+		o.Seed = time.Now().UnixNano()
+	}
+	return o
+}
+
+type harness struct {
+	t    *testing.T
+	s    strategy.CompleteStrategy
+	opts Options
+
+	lock    sync.Mutex
+	written map[string]string
+}
+
+// Run stresses s with the given options. The strategy must be backed by empty storage and is not destroyed, so
+// goroutines it runs for its whole lifetime are not reported as leaks.
+func Run(t *testing.T, s strategy.CompleteStrategy, opts Options) {
+	opts = opts.complete()
+	t.Logf("stress test seed %d", opts.Seed)
+
+	h := &harness{
+		t:       t,
+		s:       s,
+		opts:    opts,
+		written: map[string]string{},
+	}
+
+	baseline := runtime.NumGoroutine()
+
+	// The verifier watch runs from before the first write until every write has been seen
+	verifierCtx, cancelVerifier := context.WithCancel(context.Background())
+	c, err := h.watchFromList(verifierCtx)
+	if err != nil {
+		cancelVerifier()
+		t.Fatalf("starting verifier watch: %v", err)
+	}
+	v := h.startVerifier(c)
+
+	ctx, cancel := context.WithTimeout(context.Background(), opts.Duration)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	for i := 0; i < opts.Writers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			h.write(ctx, rand.New(rand.NewSource(opts.Seed+int64(i))), i)
+		}(i)
+	}
+	for i := 0; i < opts.Watchers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			h.watch(ctx, rand.New(rand.NewSource(opts.Seed+int64(opts.Writers+i))))
+		}(i)
+	}
+	wg.Wait()
+
+	t.Logf("verifying %d writes", len(h.written))
+	v.verify()
+	cancelVerifier()
+	v.wait()
+
+	h.checkGoroutines(baseline)
+}
+
+func (h *harness) watchFromList(ctx context.Context) (<-chan watch.Event, error) {
+	list, err := h.s.List(ctx, h.opts.Namespace, storage.ListOptions{
+		Predicate: everything(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	listMeta, err := meta.ListAccessor(list)
+	if err != nil {
+		return nil, err
+	}
+	if listMeta.GetResourceVersion() == "" || listMeta.GetResourceVersion() == "0" {
+		// an exact resource version is needed to see every change, make sure there is one
+		if _, err := h.s.Create(ctx, h.newObject("seed")); err != nil && !apierrors.IsAlreadyExists(err) {
+			return nil, err
+		}
+		return h.watchFromList(ctx)
+	}
+	return h.s.Watch(ctx, h.opts.Namespace, storage.ListOptions{
+		ResourceVersion: listMeta.GetResourceVersion(),
+		Predicate:       everything(),
+	})
+}
+
+func everything() storage.SelectionPredicate {
+	return storage.SelectionPredicate{
+		Label:    labels.Everything(),
+		Field:    fields.Everything(),
+		GetAttrs: storage.DefaultNamespaceScopedAttr,
+	}
+}
+
+func (h *harness) newObject(name string) types.Object {
+	obj := h.s.New()
+	obj.SetNamespace(h.opts.Namespace)
+	obj.SetName(name)
+	return obj
+}
+
+func (h *harness) record(obj types.Object, op string) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	h.written[obj.GetResourceVersion()] = op + " " + obj.GetName()
+}
+
+// write randomly creates, updates and deletes the objects owned by one writer. Each writer has its own names, so
+// conflicts only come from the strategy itself and are treated as failures.
+func (h *harness) write(ctx context.Context, r *rand.Rand, writer int) {
+	objects := map[string]types.Object{}
+	for ctx.Err() == nil {
+		name := fmt.Sprintf("writer-%d-%d", writer, r.Intn(h.opts.Objects))
+		existing := objects[name]
+
+		var (
+			obj types.Object
+			err error
+			op  string
+		)
+		switch {
+		case existing == nil:
+			op = "create"
+			obj, err = h.s.Create(ctx, h.newObject(name))
+		case r.Intn(3) == 0:
+			op = "delete"
+			existing = existing.DeepCopyObject().(types.Object)
+			now := metav1.Now()
+			existing.SetDeletionTimestamp(&now)
+			obj, err = h.s.Delete(ctx, existing)
+		default:
+			op = "update"
+			existing = existing.DeepCopyObject().(types.Object)
+			existing.SetAnnotations(map[string]string{"stresstest": strconv.Itoa(r.Int())})
+			obj, err = h.s.Update(ctx, existing)
+		}
+		if ctx.Err() != nil {
+			// the write may or may not have happened, so it can't be verified
+			return
+		}
+		if err != nil {
+			h.t.Errorf("%s %s: %v", op, name, err)
+			return
+		}
+
+		h.record(obj, op)
+		if op == "delete" {
+			delete(objects, name)
+		} else {
+			objects[name] = obj
+		}
+	}
+}
+
+// watch repeatedly starts watches and cancels them after a random time, checking the order of the events received
+// and that the watch channel is closed after it is cancelled.
+func (h *harness) watch(ctx context.Context, r *rand.Rand) {
+	for ctx.Err() == nil {
+		watchCtx, cancel := context.WithTimeout(ctx, time.Duration(r.Int63n(int64(200*time.Millisecond))))
+		c, err := h.watchFromList(watchCtx)
+		if err != nil {
+			cancel()
+			if watchCtx.Err() == nil {
+				h.t.Errorf("starting watch: %v", err)
+				return
+			}
+			continue
+		}
+
+		var last uint64
+		for event := range c {
+			if event.Type == watch.Error {
+				h.t.Errorf("watch error: %v", event.Object)
+				continue
+			}
+			rv := h.resourceVersion(event.Object)
+			if rv <= last {
+				h.t.Errorf("watch resource version went from %d to %d", last, rv)
+			}
+			last = rv
+			if watchCtx.Err() != nil {
+				break
+			}
+		}
+		cancel()
+		h.drain(c)
+	}
+}
+
+func (h *harness) resourceVersion(obj any) uint64 {
+	m, err := meta.Accessor(obj)
+	if err != nil {
+		h.t.Errorf("watch event without metadata: %v", err)
+		return 0
+	}
+	rv, err := strconv.ParseUint(m.GetResourceVersion(), 10, 64)
+	if err != nil {
+		h.t.Errorf("invalid resource version %q: %v", m.GetResourceVersion(), err)
+	}
+	return rv
+}
+
+// verifier reads a watch that runs for the whole test and records the resource version of every event.
+type verifier struct {
+	h    *harness
+	lock sync.Mutex
+	seen map[string]bool
+	done chan struct{}
+}
+
+func (h *harness) startVerifier(c <-chan watch.Event) *verifier {
+	v := &verifier{
+		h:    h,
+		seen: map[string]bool{},
+		done: make(chan struct{}),
+	}
+	go v.run(c)
+	return v
+}
+
+func (v *verifier) run(c <-chan watch.Event) {
+	defer close(v.done)
+	var last uint64
+	for event := range c {
+		if event.Type == watch.Bookmark {
+			continue
+		}
+		if event.Type == watch.Error {
+			v.h.t.Errorf("verifier watch error: %v", event.Object)
+			continue
+		}
+		rv := v.h.resourceVersion(event.Object)
+		if rv <= last {
+			v.h.t.Errorf("verifier watch resource version went from %d to %d", last, rv)
+		}
+		last = rv
+		v.lock.Lock()
+		v.seen[strconv.FormatUint(rv, 10)] = true
+		v.lock.Unlock()
+	}
+}
+
+func (v *verifier) missing() map[string]string {
+	v.h.lock.Lock()
+	defer v.h.lock.Unlock()
+	v.lock.Lock()
+	defer v.lock.Unlock()
+
+	result := map[string]string{}
+	for rv, op := range v.h.written {
+		if !v.seen[rv] {
+			result[rv] = op
+		}
+	}
+	return result
+}
+
+// verify waits until the verifier watch has seen every write.
+func (v *verifier) verify() {
+	deadline := time.Now().Add(v.h.opts.Settle)
+	for {
+		missing := v.missing()
+		if len(missing) == 0 {
+			return
+		}
+		select {
+		case <-v.done:
+			v.h.t.Errorf("verifier watch closed with %d events missing, for example %v", len(missing),
+				firstN(missing, 5))
+			return
+		default:
+		}
+		if time.Now().After(deadline) {
+			v.h.t.Errorf("verifier watch missed %d of %d events, for example %v", len(missing), len(v.h.written),
+				firstN(missing, 5))
+			return
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+// wait waits for the verifier watch to be closed after it was cancelled.
+func (v *verifier) wait() {
+	select {
+	case <-v.done:
+	case <-time.After(v.h.opts.Settle):
+		v.h.t.Errorf("verifier watch not closed %s after it was cancelled", v.h.opts.Settle)
+	}
+}
+
+func firstN(m map[string]string, n int) (result []string) {
+	for rv, op := range m {
+		if len(result) == n {
+			break
+		}
+		result = append(result, op+"@"+rv)
+	}
+	return result
+}
+
+// drain reads c until it is closed, failing if a cancelled watch is not closed in time.
+func (h *harness) drain(c <-chan watch.Event) {
+	timeout := time.After(h.opts.Settle)
+	for {
+		select {
+		case _, ok := <-c:
+			if !ok {
+				return
+			}
+		case <-timeout:
+			h.t.Errorf("watch channel not closed %s after it was cancelled", h.opts.Settle)
+			return
+		}
+	}
+}
+
+func (h *harness) checkGoroutines(baseline int) {
+	deadline := time.Now().Add(h.opts.Settle)
+	for runtime.NumGoroutine() > baseline {
+		if time.Now().After(deadline) {
+			buf := make([]byte, 1<<20)
+			buf = buf[:runtime.Stack(buf, true)]
+			h.t.Errorf("%d goroutines leaked:\n%s", runtime.NumGoroutine()-baseline, buf)
+			return
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
@@ -29,9 +29,9 @@ func getListType(obj kclient.Object, scheme *runtime.Scheme) kclient.ObjectList
 	return objList.(kclient.ObjectList)
 }
 
-func NewSimpleTranslationStrategy(translator SimpleTranslator, strategy strategy.CompleteStrategy) strategy.CompleteStrategy {
+func NewSimpleTranslationStrategy(translator SimpleTranslator, strategy strategy.CompleteStrategy, opts ...Option) strategy.CompleteStrategy {
 	pubType := translator.ToPublic(strategy.New())
-	return NewTranslationStrategy(NewSimpleTranslator(translator, pubType, strategy.Scheme()), strategy)
+	return NewTranslationStrategy(NewSimpleTranslator(translator, pubType, strategy.Scheme()), strategy, opts...)
 }
 
 func NewSimpleTranslator(translator SimpleTranslator, pubType mtypes.Object, scheme *runtime.Scheme) Translator {
@@ -0,0 +1,240 @@
+package translation
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/acorn-io/mink/pkg/types"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/apiserver/pkg/storage"
+)
+
+// MultiNamespaceTranslator can optionally be implemented by a Translator when a single public namespace maps to
+// more than one private namespace. If implemented, it is used instead of ListOpts for List and Watch.
+//
+// Lists are not merged, the items of the private namespaces follow each other in the order of the names of the
+// namespaces, each namespace's items in the order of the strategy. Lists have the smallest resource version of the
+// lists of the namespaces, which were listed one after the other, so a watch started from it may repeat changes of
+// the namespaces listed later that are already in the list, but doesn't miss any. If there are no private namespaces
+// the list is empty and has no resource version, and the watch sends nothing until it is stopped.
+type MultiNamespaceTranslator interface {
+	ListNamespaces(ctx context.Context, namespace string, opts storage.ListOptions) ([]string, storage.ListOptions, error)
+}
+
+type multiNamespaceContinue struct {
+	Namespace string `json:"ns,omitempty"`
+	Continue  string `json:"c,omitempty"`
+}
+
+func encodeMultiNamespaceContinue(namespace, cont string) (string, error) {
+	data, err := json.Marshal(multiNamespaceContinue{
+		Namespace: namespace,
+		Continue:  cont,
+	})
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+func decodeMultiNamespaceContinue(cont string) (result multiNamespaceContinue, _ error) {
+	data, err := base64.RawURLEncoding.DecodeString(cont)
+	if err != nil {
+		return result, apierrors.NewBadRequest("invalid continue token: " + err.Error())
+	}
+	if err := json.Unmarshal(data, &result); err != nil {
+		return result, apierrors.NewBadRequest("invalid continue token: " + err.Error())
+	}
+	return result, nil
+}
+
+// listNamespaces lists each private namespace in order, filling the page from as many namespaces as needed. The
+// continue token records the namespace the page stopped in and the continue token of that namespace's list.
+func (t *Strategy) listNamespaces(ctx context.Context, namespaces []string, opts storage.ListOptions) (types.ObjectList, error) {
+	namespaces = append([]string(nil), namespaces...)
+	sort.Strings(namespaces)
+
+	var (
+		start         int
+		innerContinue string
+		limit         = opts.Predicate.Limit
+		items         []runtime.Object
+		cont          string
+		rv            string
+	)
+
+	if opts.Predicate.Continue != "" {
+		token, err := decodeMultiNamespaceContinue(opts.Predicate.Continue)
+		if err != nil {
+			return nil, err
+		}
+		start = sort.SearchStrings(namespaces, token.Namespace)
+		if start < len(namespaces) && namespaces[start] == token.Namespace {
+			innerContinue = token.Continue
+		}
+	}
+
+	if len(namespaces) == 0 {
+		return t.strategy.NewList(), nil
+	}
+
+	for i := start; i < len(namespaces); i++ {
+		nsOpts := opts
+		nsOpts.Predicate.Continue = innerContinue
+		innerContinue = ""
+		if limit > 0 {
+			nsOpts.Predicate.Limit = limit - int64(len(items))
+		}
+
+		list, err := t.strategy.List(ctx, namespaces[i], nsOpts)
+		if err != nil {
+			return nil, err
+		}
+		if err := meta.EachListItem(list, func(obj runtime.Object) error {
+			items = append(items, obj)
+			return nil
+		}); err != nil {
+			return nil, err
+		}
+		rv = minResourceVersion(rv, list.GetResourceVersion())
+
+		if list.GetContinue() != "" {
+			cont, err = encodeMultiNamespaceContinue(namespaces[i], list.GetContinue())
+			if err != nil {
+				return nil, err
+			}
+			break
+		}
+		if limit > 0 && int64(len(items)) >= limit {
+			if i+1 < len(namespaces) {
+				cont, err = encodeMultiNamespaceContinue(namespaces[i+1], "")
+				if err != nil {
+					return nil, err
+				}
+			}
+			break
+		}
+	}
+
+	list := t.strategy.NewList()
+	if err := meta.SetList(list, items); err != nil {
+		return nil, err
+	}
+	list.SetContinue(cont)
+	list.SetResourceVersion(rv)
+	return list, nil
+}
+
+// watchNamespaces merges the watches of each private namespace into a single stream. Bookmarks are only sent for
+// resource versions every namespace's watch has reached so that a client resuming from a bookmark can't miss an
+// event from a slower watch.
+func (t *Strategy) watchNamespaces(ctx context.Context, namespaces []string, opts storage.ListOptions) (<-chan watch.Event, error) {
+	if len(namespaces) == 0 {
+		// there is nothing to watch, but the watch only ends when it is stopped, like the watch of an empty namespace
+		result := make(chan watch.Event)
+		go func() {
+			<-ctx.Done()
+			close(result)
+		}()
+		return result, nil
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+
+	var (
+		result    = make(chan watch.Event)
+		merged    = make(chan indexedEvent)
+		watches   = make([]<-chan watch.Event, 0, len(namespaces))
+		wg        sync.WaitGroup
+		bookmarks = make([]uint64, len(namespaces))
+	)
+
+	for _, namespace := range namespaces {
+		w, err := t.strategy.Watch(ctx, namespace, opts)
+		if err != nil {
+			cancel()
+			for _, w := range watches {
+				go drain(w)
+			}
+			return nil, err
+		}
+		watches = append(watches, w)
+	}
+
+	for i, w := range watches {
+		wg.Add(1)
+		go func(i int, w <-chan watch.Event) {
+			defer wg.Done()
+			for event := range w {
+				merged <- indexedEvent{index: i, event: event}
+			}
+		}(i, w)
+	}
+
+	go func() {
+		wg.Wait()
+		cancel()
+		close(merged)
+	}()
+
+	go func() {
+		defer close(result)
+		var lastBookmark uint64
+
+		for e := range merged {
+			if e.event.Type != watch.Bookmark {
+				result <- e.event
+				continue
+			}
+
+			m, err := meta.Accessor(e.event.Object)
+			if err != nil {
+				continue
+			}
+			rv, err := strconv.ParseUint(m.GetResourceVersion(), 10, 64)
+			if err != nil {
+				continue
+			}
+			bookmarks[e.index] = rv
+
+			least := bookmarks[0]
+			for _, b := range bookmarks[1:] {
+				least = min(least, b)
+			}
+			if least > lastBookmark {
+				lastBookmark = least
+				m.SetResourceVersion(strconv.FormatUint(least, 10))
+				result <- e.event
+			}
+		}
+	}()
+
+	return result, nil
+}
+
+type indexedEvent struct {
+	index int
+	event watch.Event
+}
+
+func drain(c <-chan watch.Event) {
+	for range c {
+	}
+}
+
+// minResourceVersion returns the smaller of two resource versions, ignoring those that aren't numbers.
+func minResourceVersion(a, b string) string {
+	ai, aErr := strconv.ParseUint(a, 10, 64)
+	bi, bErr := strconv.ParseUint(b, 10, 64)
+	if aErr != nil || (bErr == nil && bi < ai) {
+		return b
+	}
+	return a
+}
@@ -0,0 +1,79 @@
+package translation
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/acorn-io/mink/pkg/strategy"
+	"github.com/acorn-io/mink/pkg/types"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apiserver/pkg/storage"
+)
+
+// namespaceStrategy lists one config map named after the namespace, at the resource version of the namespace.
+type namespaceStrategy struct {
+	strategy.CompleteStrategy
+	resourceVersions map[string]string
+}
+
+func (n *namespaceStrategy) NewList() types.ObjectList {
+	return &corev1.ConfigMapList{}
+}
+
+func (n *namespaceStrategy) List(_ context.Context, namespace string, _ storage.ListOptions) (types.ObjectList, error) {
+	return &corev1.ConfigMapList{
+		ListMeta: metav1.ListMeta{ResourceVersion: n.resourceVersions[namespace]},
+		Items:    []corev1.ConfigMap{{ObjectMeta: metav1.ObjectMeta{Name: namespace, Namespace: namespace}}},
+	}, nil
+}
+
+func TestListNamespaces(t *testing.T) {
+	s := &Strategy{strategy: &namespaceStrategy{resourceVersions: map[string]string{"a": "12", "b": "10", "c": "11"}}}
+	ctx := context.Background()
+
+	// the items follow the order of the namespaces, the list has the resource version of the earliest list
+	list, err := s.listNamespaces(ctx, []string{"c", "a", "b"}, storage.ListOptions{Predicate: storage.Everything})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, item := range list.(*corev1.ConfigMapList).Items {
+		names = append(names, item.Name)
+	}
+	assert.Equal(t, []string{"a", "b", "c"}, names)
+	assert.Equal(t, "10", list.GetResourceVersion())
+
+	list, err = s.listNamespaces(ctx, nil, storage.ListOptions{Predicate: storage.Everything})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Empty(t, list.(*corev1.ConfigMapList).Items)
+	assert.Empty(t, list.GetResourceVersion())
+}
+
+func TestWatchNoNamespaces(t *testing.T) {
+	s := &Strategy{strategy: &namespaceStrategy{}}
+	ctx, cancel := context.WithCancel(context.Background())
+
+	// the watch stays open until it is stopped
+	events, err := s.watchNamespaces(ctx, nil, storage.ListOptions{Predicate: storage.Everything})
+	if err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case event, ok := <-events:
+		t.Fatalf("unexpected event %v, open %v", event, ok)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	cancel()
+	select {
+	case _, ok := <-events:
+		assert.False(t, ok)
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the watch to end")
+	}
+}
@@ -27,18 +27,38 @@ type Translator interface {
 	NewPublicList() types.ObjectList
 }
 
-func NewTranslationStrategy(translator Translator, strategy strategy.CompleteStrategy) *Strategy {
-	return &Strategy{
+type Option func(*Strategy)
+
+// WithListConcurrency translates large lists in parallel, split in contiguous ranges given to separate ToPublic
+// calls. It is only correct for translators that translate every object on its own.
+func WithListConcurrency(concurrency strategy.ListConcurrency) Option {
+	return func(t *Strategy) {
+		t.listConcurrency = concurrency
+	}
+}
+
+func NewTranslationStrategy(translator Translator, strategy strategy.CompleteStrategy, opts ...Option) *Strategy {
+	t := &Strategy{
 		strategy:   strategy,
 		translator: translator,
 		pubGVK:     types.MustGetGVK(translator.NewPublic(), strategy.Scheme()),
 	}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(t)
+		}
+	}
+	return t
 }
 
 type Strategy struct {
-	strategy   strategy.CompleteStrategy
-	translator Translator
-	pubGVK     schema.GroupVersionKind
+	strategy        strategy.CompleteStrategy
+	translator      Translator
+	pubGVK          schema.GroupVersionKind
+	listConcurrency strategy.ListConcurrency
+	// sharedUIDs keeps the UIDs of the translated objects, which are otherwise suffixed so that they differ from the
+	// UIDs of the objects they are translated from.
+	sharedUIDs bool
 }
 
 func (t *Strategy) toPublicObjects(ctx context.Context, objs ...runtime.Object) ([]types.Object, error) {
@@ -52,7 +72,7 @@ func (t *Strategy) toPublicObjects(ctx context.Context, objs ...runtime.Object)
 		return nil, err
 	}
 	for _, obj := range result {
-		if uids[obj.GetUID()] {
+		if !t.sharedUIDs && uids[obj.GetUID()] {
 			obj.SetUID(obj.GetUID() + "-p")
 		}
 
@@ -122,7 +142,9 @@ func (t *Strategy) fromPublic(ctx context.Context, obj types.Object) (types.Obje
 	if err != nil {
 		return nil, err
 	}
-	newObj.SetUID(ktypes.UID(strings.TrimSuffix(string(newObj.GetUID()), "-p")))
+	if !t.sharedUIDs {
+		newObj.SetUID(ktypes.UID(strings.TrimSuffix(string(newObj.GetUID()), "-p")))
+	}
 	return newObj, nil
 }
 
@@ -166,14 +188,20 @@ func (t *Strategy) toPublicList(ctx context.Context, obj types.ObjectList) (type
 		return nil, err
 	}
 
-	publicItems := make([]runtime.Object, 0, len(items))
-	objs, err := t.toPublicObjects(ctx, items...)
+	chunks := make([][]types.Object, t.listConcurrency.Chunks(len(items)))
+	err = t.listConcurrency.Run(len(items), func(chunk, start, end int) (err error) {
+		chunks[chunk], err = t.toPublicObjects(ctx, items[start:end]...)
+		return err
+	})
 	if err != nil {
 		return nil, err
 	}
 
-	for _, obj := range objs {
-		publicItems = append(publicItems, obj)
+	publicItems := make([]runtime.Object, 0, len(items))
+	for _, objs := range chunks {
+		for _, obj := range objs {
+			publicItems = append(publicItems, obj)
+		}
 	}
 
 	err = meta.SetList(publicList, publicItems)
@@ -187,6 +215,18 @@ func (t *Strategy) toPublicList(ctx context.Context, obj types.ObjectList) (type
 }
 
 func (t *Strategy) List(ctx context.Context, namespace string, opts storage.ListOptions) (types.ObjectList, error) {
+	if multi, ok := t.translator.(MultiNamespaceTranslator); ok {
+		namespaces, opts, err := t.translateListNamespaces(ctx, multi, namespace, opts)
+		if err != nil {
+			return nil, err
+		}
+		o, err := t.listNamespaces(ctx, namespaces, opts)
+		if err != nil {
+			return nil, err
+		}
+		return t.toPublicList(ctx, o)
+	}
+
 	namespace, opts, err := t.translateListOpts(ctx, namespace, opts)
 	if err != nil {
 		return nil, err
@@ -215,7 +255,27 @@ func (t *Strategy) Delete(ctx context.Context, obj types.Object) (types.Object,
 	return deletedObj, err
 }
 
+func (t *Strategy) DeleteCollection(ctx context.Context, namespace string, opts strategy.DeleteCollectionOptions) (types.ObjectList, error) {
+	return strategy.DeleteEach(ctx, t, namespace, opts)
+}
+
 func (t *Strategy) translateListOpts(ctx context.Context, namespace string, opts storage.ListOptions) (string, storage.ListOptions, error) {
+	opts, err := t.translateFieldSelector(ctx, namespace, opts)
+	if err != nil {
+		return "", storage.ListOptions{}, err
+	}
+	return t.translator.ListOpts(ctx, namespace, opts)
+}
+
+func (t *Strategy) translateListNamespaces(ctx context.Context, multi MultiNamespaceTranslator, namespace string, opts storage.ListOptions) ([]string, storage.ListOptions, error) {
+	opts, err := t.translateFieldSelector(ctx, namespace, opts)
+	if err != nil {
+		return nil, storage.ListOptions{}, err
+	}
+	return multi.ListNamespaces(ctx, namespace, opts)
+}
+
+func (t *Strategy) translateFieldSelector(ctx context.Context, namespace string, opts storage.ListOptions) (storage.ListOptions, error) {
 	if opts.Predicate.Field != nil {
 		var err error
 		opts.Predicate.Field, err = opts.Predicate.Field.Transform(func(field, value string) (newField, newValue string, err error) {
@@ -229,22 +289,33 @@ func (t *Strategy) translateListOpts(ctx context.Context, namespace string, opts
 			return field, value, nil
 		})
 		if err != nil {
-			return "", storage.ListOptions{}, err
+			return storage.ListOptions{}, err
 		}
 	}
 
-	return t.translator.ListOpts(ctx, namespace, opts)
+	return opts, nil
 }
 
 func (t *Strategy) Watch(ctx context.Context, namespace string, opts storage.ListOptions) (<-chan watch.Event, error) {
-	namespace, newOpts, err := t.translateListOpts(ctx, namespace, opts)
-	if err != nil {
-		return nil, err
-	}
-
-	w, err := t.strategy.Watch(ctx, namespace, newOpts)
-	if err != nil {
-		return nil, err
+	var w <-chan watch.Event
+	if multi, ok := t.translator.(MultiNamespaceTranslator); ok {
+		namespaces, newOpts, err := t.translateListNamespaces(ctx, multi, namespace, opts)
+		if err != nil {
+			return nil, err
+		}
+		w, err = t.watchNamespaces(ctx, namespaces, newOpts)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		namespace, newOpts, err := t.translateListOpts(ctx, namespace, opts)
+		if err != nil {
+			return nil, err
+		}
+		w, err = t.strategy.Watch(ctx, namespace, newOpts)
+		if err != nil {
+			return nil, err
+		}
 	}
 
 	result := make(chan watch.Event)
@@ -0,0 +1,85 @@
+package translation
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync/atomic"
+	"testing"
+
+	"github.com/acorn-io/mink/pkg/strategy"
+	"github.com/acorn-io/mink/pkg/types"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	ktypes "k8s.io/apimachinery/pkg/types"
+)
+
+// secretTranslator translates config maps to secrets of the same name, and fails for the config map named fail.
+type secretTranslator struct {
+	Translator
+	calls atomic.Int32
+}
+
+func (s *secretTranslator) ToPublic(_ context.Context, objs ...runtime.Object) (result []types.Object, _ error) {
+	s.calls.Add(1)
+	for _, obj := range objs {
+		cm := obj.(*corev1.ConfigMap)
+		if cm.Name == "fail" {
+			return nil, errors.New("failed to translate " + cm.Name)
+		}
+		result = append(result, &corev1.Secret{ObjectMeta: *cm.ObjectMeta.DeepCopy()})
+	}
+	return result, nil
+}
+
+func (s *secretTranslator) NewPublicList() types.ObjectList {
+	return &corev1.SecretList{}
+}
+
+func TestToPublicListConcurrency(t *testing.T) {
+	translator := &secretTranslator{}
+	s := &Strategy{
+		translator:      translator,
+		pubGVK:          corev1.SchemeGroupVersion.WithKind("Secret"),
+		listConcurrency: strategy.ListConcurrency{Workers: 4, MinItems: 100},
+	}
+	list := &corev1.ConfigMapList{ListMeta: metav1.ListMeta{ResourceVersion: "10", Continue: "next"}}
+	for i := 0; i < 150; i++ {
+		name := strconv.Itoa(i)
+		list.Items = append(list.Items, corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: name, UID: ktypes.UID(name)}})
+	}
+
+	// every worker translates a contiguous range, the items keep their order
+	result, err := s.toPublicList(context.Background(), list)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, int32(4), translator.calls.Load())
+	secrets := result.(*corev1.SecretList)
+	assert.Equal(t, "10", secrets.ResourceVersion)
+	assert.Equal(t, "next", secrets.Continue)
+	if assert.Len(t, secrets.Items, 150) {
+		for i, secret := range secrets.Items {
+			assert.Equal(t, strconv.Itoa(i), secret.Name)
+			assert.Equal(t, ktypes.UID(strconv.Itoa(i)+"-p"), secret.UID)
+			assert.Equal(t, "Secret", secret.Kind)
+		}
+	}
+
+	// an error of any range fails the whole list
+	list.Items[120].Name = "fail"
+	_, err = s.toPublicList(context.Background(), list)
+	assert.EqualError(t, err, "failed to translate fail")
+
+	// short lists are translated at once
+	translator.calls.Store(0)
+	list.Items = list.Items[:99]
+	result, err = s.toPublicList(context.Background(), list)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, int32(1), translator.calls.Load())
+	assert.Len(t, result.(*corev1.SecretList).Items, 99)
+}
@@ -0,0 +1,101 @@
+package translation
+
+import (
+	"context"
+
+	"github.com/acorn-io/mink/pkg/strategy"
+	mtypes "github.com/acorn-io/mink/pkg/types"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apiserver/pkg/storage"
+)
+
+// Converter converts in, an object of one version of a kind, into out, an empty object of another version.
+type Converter interface {
+	Convert(ctx context.Context, in, out runtime.Object) error
+}
+
+type ConverterFunc func(ctx context.Context, in, out runtime.Object) error
+
+func (f ConverterFunc) Convert(ctx context.Context, in, out runtime.Object) error {
+	return f(ctx, in, out)
+}
+
+// SchemeConverter converts objects with the conversion functions registered in the scheme, such as the generated
+// conversions of a type to and from its internal version.
+func SchemeConverter(scheme *runtime.Scheme) Converter {
+	return ConverterFunc(func(_ context.Context, in, out runtime.Object) error {
+		return scheme.Convert(in, out, nil)
+	})
+}
+
+// NewVersionStrategy serves obj, another version of the kind stored by hub. Objects are converted to the version of
+// hub before they are written and back to the version of obj when they are read, so every version shares the objects,
+// resource versions and UIDs of hub, and only the hub version is ever stored.
+func NewVersionStrategy(obj mtypes.Object, hub strategy.CompleteStrategy, converter Converter, opts ...Option) *Strategy {
+	t := NewTranslationStrategy(&versionTranslator{
+		obj:       obj,
+		objList:   newVersionList(obj, hub.Scheme()),
+		hub:       hub,
+		hubGVK:    mtypes.MustGetGVK(hub.New(), hub.Scheme()),
+		converter: converter,
+	}, hub, opts...)
+	t.sharedUIDs = true
+	return t
+}
+
+func newVersionList(obj mtypes.Object, scheme *runtime.Scheme) mtypes.ObjectList {
+	if u, ok := obj.(*unstructured.Unstructured); ok {
+		list := &unstructured.UnstructuredList{}
+		list.SetGroupVersionKind(u.GroupVersionKind().GroupVersion().WithKind(u.GetKind() + "List"))
+		return list
+	}
+	return getListType(obj, scheme)
+}
+
+type versionTranslator struct {
+	obj       mtypes.Object
+	objList   mtypes.ObjectList
+	hub       strategy.CompleteStrategy
+	hubGVK    schema.GroupVersionKind
+	converter Converter
+}
+
+func (v *versionTranslator) FromPublicName(_ context.Context, namespace, name string) (string, string, error) {
+	return namespace, name, nil
+}
+
+func (v *versionTranslator) ListOpts(_ context.Context, namespace string, opts storage.ListOptions) (string, storage.ListOptions, error) {
+	return namespace, opts, nil
+}
+
+func (v *versionTranslator) NewPublic() mtypes.Object {
+	return v.obj.DeepCopyObject().(mtypes.Object)
+}
+
+func (v *versionTranslator) NewPublicList() mtypes.ObjectList {
+	return v.objList.DeepCopyObject().(mtypes.ObjectList)
+}
+
+func (v *versionTranslator) FromPublic(ctx context.Context, obj runtime.Object) (mtypes.Object, error) {
+	hub := v.hub.New()
+	if err := v.converter.Convert(ctx, obj, hub); err != nil {
+		return nil, err
+	}
+	// converters filling unstructured objects from scratch may drop their kind
+	hub.GetObjectKind().SetGroupVersionKind(v.hubGVK)
+	return hub, nil
+}
+
+func (v *versionTranslator) ToPublic(ctx context.Context, objs ...runtime.Object) ([]mtypes.Object, error) {
+	result := make([]mtypes.Object, 0, len(objs))
+	for _, obj := range objs {
+		public := v.NewPublic()
+		if err := v.converter.Convert(ctx, obj, public); err != nil {
+			return nil, err
+		}
+		result = append(result, public)
+	}
+	return result, nil
+}
@@ -66,6 +66,7 @@ func (a *UpdateAdapter) AllowCreateOnUpdate() bool {
 }
 
 func (a *UpdateAdapter) PrepareForUpdate(ctx context.Context, obj, old runtime.Object) {
+	undecorate(ctx, getDecorator(a.Decorator, a.strategy), obj)
 	if a.PrepareForUpdater != nil {
 		a.PrepareForUpdater.PrepareForUpdate(ctx, obj, old)
 	} else if o, ok := a.strategy.(PrepareForUpdater); ok {
@@ -112,17 +113,13 @@ func (a *UpdateAdapter) update(ctx context.Context, status bool, name string, ob
 		return nil, false, err
 	}
 
-	// Given the existing object, get the new object
-	obj, err := objInfo.UpdatedObject(ctx, existing)
-	if err != nil {
-		return nil, false, err
-	}
-
-	if obj.(types.Object).GetResourceVersion() == "" && existing.(types.Object).GetResourceVersion() != "" {
-		obj.(types.Object).SetResourceVersion(existing.(types.Object).GetResourceVersion())
-	}
-
 	if doCreate {
+		// Given the existing object, get the new object
+		obj, err := objInfo.UpdatedObject(ctx, existing)
+		if err != nil {
+			return nil, false, err
+		}
+
 		if objectMeta, err := meta.Accessor(obj); err == nil {
 			rest.FillObjectMetaSystemFields(objectMeta)
 			if objectMeta.GetName() == "" {
@@ -152,18 +149,11 @@ func (a *UpdateAdapter) update(ctx context.Context, status bool, name string, ob
 		return newObj, true, err
 	}
 
-	if err := rest.BeforeUpdate(a, ctx, obj, existing); err != nil {
+	obj, err := a.updatedObject(ctx, existing, objInfo, updateValidation)
+	if err != nil {
 		return nil, false, err
 	}
 
-	// at this point we have a fully formed object.  It is time to call the validators that the apiserver
-	// handling chain wants to enforce.
-	if updateValidation != nil {
-		if err := updateValidation(ctx, obj.DeepCopyObject(), existing.DeepCopyObject()); err != nil {
-			return nil, false, err
-		}
-	}
-
 	if len(options.DryRun) != 0 && options.DryRun[0] == metav1.DryRunAll {
 		return obj, false, nil
 	}
@@ -177,6 +167,33 @@ func (a *UpdateAdapter) update(ctx context.Context, status bool, name string, ob
 	return newObj, false, err
 }
 
+// updatedObject returns the object replacing existing, after the update strategy and the validation of the API server
+// ran on it.
+func (a *UpdateAdapter) updatedObject(ctx context.Context, existing runtime.Object, objInfo rest.UpdatedObjectInfo, updateValidation rest.ValidateObjectUpdateFunc) (runtime.Object, error) {
+	// Given the existing object, get the new object
+	obj, err := objInfo.UpdatedObject(ctx, existing)
+	if err != nil {
+		return nil, err
+	}
+
+	if obj.(types.Object).GetResourceVersion() == "" && existing.(types.Object).GetResourceVersion() != "" {
+		obj.(types.Object).SetResourceVersion(existing.(types.Object).GetResourceVersion())
+	}
+
+	if err := rest.BeforeUpdate(a, ctx, obj, existing); err != nil {
+		return nil, err
+	}
+
+	// at this point we have a fully formed object.  It is time to call the validators that the apiserver
+	// handling chain wants to enforce.
+	if updateValidation != nil {
+		if err := updateValidation(ctx, obj.DeepCopyObject(), existing.DeepCopyObject()); err != nil {
+			return nil, err
+		}
+	}
+	return obj, nil
+}
+
 func (a *UpdateAdapter) qualifiedResourceFromContext(ctx context.Context) schema.GroupResource {
 	if info, ok := genericapirequest.RequestInfoFrom(ctx); ok {
 		return schema.GroupResource{Group: info.APIGroup, Resource: info.Resource}
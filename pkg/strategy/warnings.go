@@ -0,0 +1,64 @@
+package strategy
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"k8s.io/apiserver/pkg/warning"
+)
+
+// AddWarning attaches a warning to the request in ctx. The warning is returned to the client as a standard
+// Warning header regardless of the verb being served. Duplicate warnings are only sent once. If ctx does not
+// belong to an API request, the warning is dropped.
+func AddWarning(ctx context.Context, text string) {
+	warning.AddWarning(ctx, "", text)
+}
+
+// AddWarningf is AddWarning with the text built from a format string.
+func AddWarningf(ctx context.Context, format string, args ...any) {
+	AddWarning(ctx, fmt.Sprintf(format, args...))
+}
+
+// WarningCollector records the warnings added to a context. It is useful when calling strategies outside of an
+// API request, for example from tests or from another strategy that wants to inspect the warnings before
+// passing them on.
+type WarningCollector struct {
+	lock     sync.Mutex
+	warnings []string
+	parent   context.Context
+}
+
+// WithWarningCollector returns a context that records the warnings added to it in the returned collector. If ctx
+// already belongs to an API request, the warnings are passed on to it as well.
+func WithWarningCollector(ctx context.Context) (context.Context, *WarningCollector) {
+	c := &WarningCollector{
+		parent: ctx,
+	}
+	return warning.WithWarningRecorder(ctx, c), c
+}
+
+func (w *WarningCollector) AddWarning(agent, text string) {
+	if text == "" {
+		return
+	}
+
+	w.lock.Lock()
+	for _, existing := range w.warnings {
+		if existing == text {
+			w.lock.Unlock()
+			return
+		}
+	}
+	w.warnings = append(w.warnings, text)
+	w.lock.Unlock()
+
+	warning.AddWarning(w.parent, agent, text)
+}
+
+// Warnings returns the warnings recorded so far in the order they were added.
+func (w *WarningCollector) Warnings() []string {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	return append([]string(nil), w.warnings...)
+}
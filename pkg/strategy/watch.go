@@ -2,6 +2,7 @@ package strategy
 
 import (
 	"context"
+	"time"
 
 	"github.com/acorn-io/mink/pkg/types"
 	metainternalversion "k8s.io/apimachinery/pkg/apis/meta/internalversion"
@@ -37,8 +38,32 @@ type Watcher interface {
 	New() types.Object
 }
 
+// WatchProgressRequester is implemented by strategies that send a bookmark with the latest resource version to their
+// watches on request, like etcd answers the watch progress requests of Kubernetes, so that clients resuming from
+// bookmarks don't wait for the next periodic one. WatchAdapter requests progress for every watch that allows
+// bookmarks, so a new watch learns the latest resource version even if nothing changes.
+type WatchProgressRequester interface {
+	RequestWatchProgress(ctx context.Context) error
+}
+
+// WatchTerminator is implemented by storage that ends its watches cleanly when the server shuts down, rather than
+// leaving them to be cut off. Each watch that allows bookmarks first gets one with the resource version it got to, so
+// that its client resumes it from there on another server instead of listing again, see server.Config.WatchTerminators.
+type WatchTerminator interface {
+	TerminateWatches()
+}
+
 type WatchAdapter struct {
-	strategy Watcher
+	strategy        Watcher
+	NamespaceScoper NamespaceScoper
+	Scrubber        Scrubber
+	Decorator       Decorator
+	// CoalesceDelay, if set, holds Modified events back for up to this long and only sends the latest one for
+	// each object, which reduces churn for clients watching objects that change rapidly. It can be overridden per
+	// watch with WithWatchCoalesceDelay.
+	CoalesceDelay time.Duration
+	// Rate, if set, limits the events sent to each watch. It can be overridden per watch with WithWatchRate.
+	Rate WatchRate
 }
 
 func NewWatch(strategy Watcher) *WatchAdapter {
@@ -67,6 +92,8 @@ func (w *WatchAdapter) Watch(ctx context.Context, options *metainternalversion.L
 }
 
 func (w *WatchAdapter) WatchPredicate(ctx context.Context, p storage.SelectionPredicate, resourceVersion string) (watch.Interface, error) {
+	scope := ListScopeFromContext(ctx)
+	p.Label = scope.labelSelector(p.Label)
 	storageOpts := storage.ListOptions{ResourceVersion: resourceVersion, Predicate: p, Recursive: true}
 
 	ns, _ := request.NamespaceFrom(ctx)
@@ -76,21 +103,24 @@ func (w *WatchAdapter) WatchPredicate(ctx context.Context, p storage.SelectionPr
 		cancel()
 		return nil, err
 	}
+	if requester, ok := w.strategy.(WatchProgressRequester); ok && p.AllowWatchBookmarks {
+		if err := requester.RequestWatchProgress(ctx); err != nil {
+			cancel()
+			return nil, err
+		}
+	}
 
 	return &watchResult{
 		cancel: cancel,
-		c:      c,
+		c: paceEvents(ctx, watchRate(ctx, w.Rate),
+			scrubEvents(ctx, getScrubber(w.Scrubber, w.strategy),
+				decorateEvents(ctx, getDecorator(w.Decorator, w.strategy),
+					coalesceEvents(coalesceDelay(ctx, w.CoalesceDelay), scope.filterEvents(c))))),
 	}, nil
 }
 
 func (w *WatchAdapter) NamespaceScoped() bool {
-	if o, ok := w.strategy.(NamespaceScoper); ok {
-		return o.NamespaceScoped()
-	}
-	if o, ok := w.strategy.New().(NamespaceScoper); ok {
-		return o.NamespaceScoped()
-	}
-	return true
+	return namespaceScoped(w.NamespaceScoper, w.strategy)
 }
 
 func (w *WatchAdapter) predicate(label labels.Selector, field fields.Selector) storage.SelectionPredicate {
@@ -0,0 +1,205 @@
+package validator
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+const tagName = "validate"
+
+// StructTags validates objects using rules declared in `validate` struct tags. Rules are separated by commas:
+//
+//	required        the field must not be the zero value
+//	min=N, max=N    bounds for numbers, or for the length of strings, slices and maps
+//	enum=a|b|c      the value must be one of the listed values
+//	pattern=REGEX   strings must match the regular expression, it must be the last rule as it may contain commas
+//
+// Apart from required, rules are only checked for fields that are set. Field paths in errors use the json names of
+// the fields.
+var StructTags = &structTags{}
+
+type structTags struct {
+	patterns sync.Map
+}
+
+func (s *structTags) Validate(ctx context.Context, obj runtime.Object) field.ErrorList {
+	return s.validate(reflect.ValueOf(obj), nil)
+}
+
+func (s *structTags) ValidateUpdate(ctx context.Context, obj, old runtime.Object) field.ErrorList {
+	return s.validate(reflect.ValueOf(obj), nil)
+}
+
+func (s *structTags) validate(v reflect.Value, path *field.Path) (result field.ErrorList) {
+	for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+
+	switch v.Kind() {
+	case reflect.Struct:
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if !f.IsExported() {
+				continue
+			}
+			fieldPath := path
+			if name := jsonName(f); name != "" {
+				fieldPath = path.Child(name)
+			}
+			if tag, ok := f.Tag.Lookup(tagName); ok {
+				result = append(result, s.checkRules(v.Field(i), fieldPath, tag)...)
+			}
+			result = append(result, s.validate(v.Field(i), fieldPath)...)
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			result = append(result, s.validate(v.Index(i), path.Index(i))...)
+		}
+	case reflect.Map:
+		iter := v.MapRange()
+		for iter.Next() {
+			result = append(result, s.validate(iter.Value(), path.Key(fmt.Sprint(iter.Key().Interface())))...)
+		}
+	}
+
+	return result
+}
+
+func jsonName(f reflect.StructField) string {
+	if f.Anonymous {
+		return ""
+	}
+	name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+	if name == "-" {
+		return ""
+	}
+	if name == "" {
+		return f.Name
+	}
+	return name
+}
+
+func (s *structTags) checkRules(v reflect.Value, path *field.Path, tag string) (result field.ErrorList) {
+	for tag != "" {
+		var rule string
+		if strings.HasPrefix(tag, "pattern=") {
+			rule, tag = tag, ""
+		} else {
+			rule, tag, _ = strings.Cut(tag, ",")
+		}
+
+		key, arg, _ := strings.Cut(strings.TrimSpace(rule), "=")
+		if key == "required" {
+			if v.IsZero() {
+				result = append(result, field.Required(path, ""))
+				return result
+			}
+			continue
+		}
+		if v.IsZero() {
+			continue
+		}
+
+		value := indirect(v)
+		switch key {
+		case "min", "max":
+			result = append(result, checkBound(value, path, key, arg)...)
+		case "enum":
+			allowed := strings.Split(arg, "|")
+			actual := fmt.Sprint(value.Interface())
+			found := false
+			for _, a := range allowed {
+				if a == actual {
+					found = true
+					break
+				}
+			}
+			if !found {
+				result = append(result, field.NotSupported(path, actual, allowed))
+			}
+		case "pattern":
+			if value.Kind() != reflect.String {
+				continue
+			}
+			re, err := s.pattern(arg)
+			if err != nil {
+				result = append(result, field.InternalError(path, err))
+			} else if !re.MatchString(value.String()) {
+				result = append(result, field.Invalid(path, value.String(), fmt.Sprintf("must match the pattern %s", arg)))
+			}
+		default:
+			result = append(result, field.InternalError(path, fmt.Errorf("unknown validation rule %q", key)))
+		}
+	}
+	return result
+}
+
+func indirect(v reflect.Value) reflect.Value {
+	for v.Kind() == reflect.Pointer && !v.IsNil() {
+		v = v.Elem()
+	}
+	return v
+}
+
+func checkBound(v reflect.Value, path *field.Path, key, arg string) field.ErrorList {
+	bound, err := strconv.ParseFloat(arg, 64)
+	if err != nil {
+		return field.ErrorList{field.InternalError(path, fmt.Errorf("invalid %s %q: %w", key, arg, err))}
+	}
+
+	var (
+		actual float64
+		length bool
+	)
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		actual = float64(v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		actual = float64(v.Uint())
+	case reflect.Float32, reflect.Float64:
+		actual = v.Float()
+	case reflect.String, reflect.Slice, reflect.Map, reflect.Array:
+		actual = float64(v.Len())
+		length = true
+	default:
+		return nil
+	}
+
+	if key == "min" && actual < bound {
+		if length {
+			return field.ErrorList{field.Invalid(path, v.Interface(), fmt.Sprintf("must have at least %s items or characters", arg))}
+		}
+		return field.ErrorList{field.Invalid(path, v.Interface(), fmt.Sprintf("must be greater than or equal to %s", arg))}
+	}
+	if key == "max" && actual > bound {
+		if length {
+			return field.ErrorList{field.Invalid(path, v.Interface(), fmt.Sprintf("must have at most %s items or characters", arg))}
+		}
+		return field.ErrorList{field.Invalid(path, v.Interface(), fmt.Sprintf("must be less than or equal to %s", arg))}
+	}
+	return nil
+}
+
+func (s *structTags) pattern(pattern string) (*regexp.Regexp, error) {
+	if re, ok := s.patterns.Load(pattern); ok {
+		return re.(*regexp.Regexp), nil
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	s.patterns.Store(pattern, re)
+	return re, nil
+}
@@ -0,0 +1,66 @@
+package validator
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+type testSpec struct {
+	Name     string   `json:"name" validate:"required,max=5"`
+	Replicas int      `json:"replicas,omitempty" validate:"min=1,max=10"`
+	Mode     string   `json:"mode,omitempty" validate:"enum=fast|slow"`
+	Image    string   `json:"image,omitempty" validate:"pattern=^[a-z]+(:[0-9]{1,3})?$"`
+	Ports    []string `json:"ports,omitempty" validate:"max=1"`
+}
+
+type testObject struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec testSpec `json:"spec,omitempty"`
+}
+
+func (t *testObject) DeepCopyObject() runtime.Object {
+	c := *t
+	return &c
+}
+
+func TestStructTags(t *testing.T) {
+	errs := StructTags.Validate(context.Background(), &testObject{
+		Spec: testSpec{
+			Name: "name",
+		},
+	})
+	assert.Empty(t, errs)
+
+	errs = StructTags.Validate(context.Background(), &testObject{
+		Spec: testSpec{
+			Image: "nginx:1,2",
+		},
+	})
+	assert.Len(t, errs, 2)
+	assert.Equal(t, field.ErrorTypeRequired, errs[0].Type)
+	assert.Equal(t, "spec.name", errs[0].Field)
+	assert.Equal(t, "spec.image", errs[1].Field)
+
+	errs = StructTags.Validate(context.Background(), &testObject{
+		Spec: testSpec{
+			Name:     "too-long",
+			Replicas: 11,
+			Mode:     "medium",
+			Image:    "nginx:123",
+			Ports:    []string{"80", "443"},
+		},
+	})
+	if assert.Len(t, errs, 4) {
+		assert.Equal(t, "spec.name", errs[0].Field)
+		assert.Equal(t, "spec.replicas", errs[1].Field)
+		assert.Equal(t, field.ErrorTypeNotSupported, errs[2].Type)
+		assert.Equal(t, "spec.ports", errs[3].Field)
+	}
+}
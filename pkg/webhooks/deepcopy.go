@@ -0,0 +1,91 @@
+package webhooks
+
+import (
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func (in *WebhookSubscription) DeepCopyInto(out *WebhookSubscription) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+func (in *WebhookSubscription) DeepCopy() *WebhookSubscription {
+	if in == nil {
+		return nil
+	}
+	out := new(WebhookSubscription)
+	in.DeepCopyInto(out)
+	return out
+}
+
+func (in *WebhookSubscription) DeepCopyObject() runtime.Object {
+	return in.DeepCopy()
+}
+
+func (in *WebhookSubscriptionSpec) DeepCopyInto(out *WebhookSubscriptionSpec) {
+	*out = *in
+	if in.Namespaces != nil {
+		out.Namespaces = make([]string, len(in.Namespaces))
+		copy(out.Namespaces, in.Namespaces)
+	}
+	if in.Selector != nil {
+		out.Selector = in.Selector.DeepCopy()
+	}
+	if in.EventTypes != nil {
+		out.EventTypes = make([]string, len(in.EventTypes))
+		copy(out.EventTypes, in.EventTypes)
+	}
+	if in.Groups != nil {
+		out.Groups = make([]string, len(in.Groups))
+		copy(out.Groups, in.Groups)
+	}
+	if in.Extra != nil {
+		out.Extra = make(map[string][]string, len(in.Extra))
+		for key, values := range in.Extra {
+			if values != nil {
+				out.Extra[key] = make([]string, len(values))
+				copy(out.Extra[key], values)
+			} else {
+				out.Extra[key] = nil
+			}
+		}
+	}
+}
+
+func (in *WebhookSubscriptionStatus) DeepCopyInto(out *WebhookSubscriptionStatus) {
+	*out = *in
+	if in.LastDeliveryTime != nil {
+		out.LastDeliveryTime = in.LastDeliveryTime.DeepCopy()
+	}
+	if in.LastFailureTime != nil {
+		out.LastFailureTime = in.LastFailureTime.DeepCopy()
+	}
+}
+
+func (in *WebhookSubscriptionList) DeepCopyInto(out *WebhookSubscriptionList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		out.Items = make([]WebhookSubscription, len(in.Items))
+		for i := range in.Items {
+			in.Items[i].DeepCopyInto(&out.Items[i])
+		}
+	}
+}
+
+func (in *WebhookSubscriptionList) DeepCopy() *WebhookSubscriptionList {
+	if in == nil {
+		return nil
+	}
+	out := new(WebhookSubscriptionList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+func (in *WebhookSubscriptionList) DeepCopyObject() runtime.Object {
+	return in.DeepCopy()
+}
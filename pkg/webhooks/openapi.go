@@ -0,0 +1,93 @@
+package webhooks
+
+import (
+	"k8s.io/kube-openapi/pkg/common"
+	"k8s.io/kube-openapi/pkg/validation/spec"
+)
+
+const pkgPath = "github.com/acorn-io/mink/pkg/webhooks."
+
+// GetOpenAPIDefinitions returns the OpenAPI definitions of the WebhookSubscription types, merge them into the
+// definitions of the server's OpenAPIConfig when serving webhook subscriptions.
+func GetOpenAPIDefinitions(ref common.ReferenceCallback) map[string]common.OpenAPIDefinition {
+	return map[string]common.OpenAPIDefinition{
+		pkgPath + "WebhookSubscription": {
+			Schema: spec.Schema{
+				SchemaProps: spec.SchemaProps{
+					Description: "WebhookSubscription subscribes a URL to the changes of the objects of one kind, delivered as CloudEvents.",
+					Type:        []string{"object"},
+					Properties: map[string]spec.Schema{
+						"kind":       *spec.StringProperty(),
+						"apiVersion": *spec.StringProperty(),
+						"metadata":   {SchemaProps: spec.SchemaProps{Default: map[string]interface{}{}, Ref: ref("k8s.io/apimachinery/pkg/apis/meta/v1.ObjectMeta")}},
+						"spec":       {SchemaProps: spec.SchemaProps{Default: map[string]interface{}{}, Ref: ref(pkgPath + "WebhookSubscriptionSpec")}},
+						"status":     {SchemaProps: spec.SchemaProps{Default: map[string]interface{}{}, Ref: ref(pkgPath + "WebhookSubscriptionStatus")}},
+					},
+				},
+			},
+			Dependencies: []string{pkgPath + "WebhookSubscriptionSpec", pkgPath + "WebhookSubscriptionStatus", "k8s.io/apimachinery/pkg/apis/meta/v1.ObjectMeta"},
+		},
+		pkgPath + "WebhookSubscriptionList": {
+			Schema: spec.Schema{
+				SchemaProps: spec.SchemaProps{
+					Type: []string{"object"},
+					Properties: map[string]spec.Schema{
+						"kind":       *spec.StringProperty(),
+						"apiVersion": *spec.StringProperty(),
+						"metadata":   {SchemaProps: spec.SchemaProps{Default: map[string]interface{}{}, Ref: ref("k8s.io/apimachinery/pkg/apis/meta/v1.ListMeta")}},
+						"items": *spec.ArrayProperty(&spec.Schema{
+							SchemaProps: spec.SchemaProps{Default: map[string]interface{}{}, Ref: ref(pkgPath + "WebhookSubscription")},
+						}),
+					},
+					Required: []string{"items"},
+				},
+			},
+			Dependencies: []string{pkgPath + "WebhookSubscription", "k8s.io/apimachinery/pkg/apis/meta/v1.ListMeta"},
+		},
+		pkgPath + "WebhookSubscriptionSpec": {
+			Schema: spec.Schema{
+				SchemaProps: spec.SchemaProps{
+					Type: []string{"object"},
+					Properties: map[string]spec.Schema{
+						"url":   *spec.StringProperty().WithDescription("URL is the http or https URL events are posted to."),
+						"group": *spec.StringProperty().WithDescription("Group is the API group of the objects whose changes are delivered."),
+						"kind":  *spec.StringProperty().WithDescription("Kind is the kind of the objects whose changes are delivered."),
+						"namespaces": *spec.ArrayProperty(spec.StringProperty()).
+							WithDescription("Namespaces, if set, limits the events to objects in these namespaces."),
+						"selector": {SchemaProps: spec.SchemaProps{
+							Description: "Selector, if set, limits the events to objects with matching labels.",
+							Ref:         ref("k8s.io/apimachinery/pkg/apis/meta/v1.LabelSelector"),
+						}},
+						"eventTypes": *spec.ArrayProperty(spec.StringProperty()).
+							WithDescription("EventTypes, if set, limits the events to these types, ADDED, MODIFIED or DELETED."),
+						"secret":   *spec.StringProperty().WithDescription("Secret is the key of the HMAC-SHA256 signature sent in the X-Mink-Signature header. It is never returned."),
+						"username": *spec.StringProperty().WithDescription("Username is the name of the user that created the subscription, set by the server."),
+						"uid":      *spec.StringProperty().WithDescription("UID is the UID of the user that created the subscription, set by the server."),
+						"groups": *spec.ArrayProperty(spec.StringProperty()).
+							WithDescription("Groups are the groups of the user that created the subscription, set by the server."),
+						"extra": *spec.MapProperty(spec.ArrayProperty(spec.StringProperty())).
+							WithDescription("Extra is the extra information of the user that created the subscription, set by the server."),
+						"partitionID": *spec.StringProperty().WithDescription("PartitionID is the partition the subscription was created in, set by the server."),
+					},
+					Required: []string{"url", "kind"},
+				},
+			},
+			Dependencies: []string{"k8s.io/apimachinery/pkg/apis/meta/v1.LabelSelector"},
+		},
+		pkgPath + "WebhookSubscriptionStatus": {
+			Schema: spec.Schema{
+				SchemaProps: spec.SchemaProps{
+					Type: []string{"object"},
+					Properties: map[string]spec.Schema{
+						"delivered":           *spec.Int64Property().WithDescription("Delivered is the number of events delivered."),
+						"consecutiveFailures": *spec.Int32Property().WithDescription("ConsecutiveFailures is the number of failed attempts since the last delivered event."),
+						"lastDeliveryTime":    {SchemaProps: spec.SchemaProps{Ref: ref("k8s.io/apimachinery/pkg/apis/meta/v1.Time")}},
+						"lastFailureTime":     {SchemaProps: spec.SchemaProps{Ref: ref("k8s.io/apimachinery/pkg/apis/meta/v1.Time")}},
+						"lastError":           *spec.StringProperty(),
+					},
+				},
+			},
+			Dependencies: []string{"k8s.io/apimachinery/pkg/apis/meta/v1.Time"},
+		},
+	}
+}
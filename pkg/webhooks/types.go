@@ -0,0 +1,67 @@
+package webhooks
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// AddToScheme registers the WebhookSubscription types in the group version the embedder serves them in.
+func AddToScheme(scheme *runtime.Scheme, gv schema.GroupVersion) error {
+	scheme.AddKnownTypes(gv, &WebhookSubscription{}, &WebhookSubscriptionList{})
+	return nil
+}
+
+// WebhookSubscription subscribes a URL to the changes of the objects of one kind. Every change is posted to the URL as
+// a CloudEvent in structured JSON mode.
+type WebhookSubscription struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   WebhookSubscriptionSpec   `json:"spec,omitempty"`
+	Status WebhookSubscriptionStatus `json:"status,omitempty"`
+}
+
+type WebhookSubscriptionSpec struct {
+	// URL is the http or https URL events are posted to.
+	URL string `json:"url"`
+	// Group and Kind are the type of the objects whose changes are delivered.
+	Group string `json:"group,omitempty"`
+	Kind  string `json:"kind"`
+	// Namespaces, if set, limits the events to objects in these namespaces.
+	Namespaces []string `json:"namespaces,omitempty"`
+	// Selector, if set, limits the events to objects with matching labels.
+	Selector *metav1.LabelSelector `json:"selector,omitempty"`
+	// EventTypes, if set, limits the events to these types, ADDED, MODIFIED or DELETED.
+	EventTypes []string `json:"eventTypes,omitempty"`
+	// Secret, if set, is the key of the HMAC-SHA256 signature of the body sent in the X-Mink-Signature header. It is
+	// never returned by the API, updates that leave it empty keep the current secret.
+	Secret string `json:"secret,omitempty"`
+
+	// Username, UID, Groups and Extra are the user that created the subscription, set by the server. Events are only
+	// delivered for objects this user may watch.
+	Username string              `json:"username,omitempty"`
+	UID      string              `json:"uid,omitempty"`
+	Groups   []string            `json:"groups,omitempty"`
+	Extra    map[string][]string `json:"extra,omitempty"`
+	// PartitionID is the partition the subscription was created in, set by the server. If set, only the changes of
+	// objects in this partition are delivered.
+	PartitionID string `json:"partitionID,omitempty"`
+}
+
+type WebhookSubscriptionStatus struct {
+	// Delivered is the number of events delivered.
+	Delivered int64 `json:"delivered,omitempty"`
+	// ConsecutiveFailures is the number of failed attempts since the last delivered event.
+	ConsecutiveFailures int32        `json:"consecutiveFailures,omitempty"`
+	LastDeliveryTime    *metav1.Time `json:"lastDeliveryTime,omitempty"`
+	LastFailureTime     *metav1.Time `json:"lastFailureTime,omitempty"`
+	LastError           string       `json:"lastError,omitempty"`
+}
+
+type WebhookSubscriptionList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+
+	Items []WebhookSubscription `json:"items"`
+}
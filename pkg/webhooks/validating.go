@@ -0,0 +1,386 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/acorn-io/mink/pkg/logging"
+	admissionv1 "k8s.io/api/admission/v1"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	ktypes "k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/uuid"
+	"k8s.io/apiserver/pkg/admission"
+	"k8s.io/apiserver/pkg/warning"
+	"k8s.io/utils/ptr"
+)
+
+const defaultWebhookTimeout = 10 * time.Second
+
+var _ admission.ValidationInterface = (*ValidatingWebhooks)(nil)
+
+// ValidatingWebhooks is an admission plugin calling validating webhooks configured in Go, as they are configured in a
+// ValidatingWebhookConfiguration for the resources of a Kubernetes cluster. Add it to server.Config.Admission to
+// validate the creates, updates, deletes and connects of the resources served by mink.
+//
+// The webhooks must be reached by an HTTPS URL and accept admission.k8s.io/v1 AdmissionReviews. Rules and the object
+// selector are supported, the namespace selector, match conditions and services as client configs are not. Matching
+// webhooks are called in parallel, the request is rejected if any of them denies it.
+type ValidatingWebhooks struct {
+	webhooks []*validatingWebhook
+}
+
+type validatingWebhook struct {
+	admissionregistrationv1.ValidatingWebhook
+	url            string
+	client         *http.Client
+	objectSelector labels.Selector
+}
+
+// NewValidatingWebhooks returns the plugin calling webhooks. It fails if a webhook uses a feature the plugin doesn't
+// support.
+func NewValidatingWebhooks(webhooks ...admissionregistrationv1.ValidatingWebhook) (*ValidatingWebhooks, error) {
+	result := &ValidatingWebhooks{}
+	for _, webhook := range webhooks {
+		w, err := newValidatingWebhook(webhook)
+		if err != nil {
+			return nil, fmt.Errorf("webhook %q: %w", webhook.Name, err)
+		}
+		result.webhooks = append(result.webhooks, w)
+	}
+	return result, nil
+}
+
+func newValidatingWebhook(webhook admissionregistrationv1.ValidatingWebhook) (*validatingWebhook, error) {
+	if webhook.ClientConfig.Service != nil || webhook.ClientConfig.URL == nil {
+		return nil, fmt.Errorf("only webhooks with a URL are supported")
+	}
+	u, err := url.Parse(*webhook.ClientConfig.URL)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "https" {
+		return nil, fmt.Errorf("the URL must use https")
+	}
+	if webhook.NamespaceSelector != nil && (len(webhook.NamespaceSelector.MatchLabels) > 0 || len(webhook.NamespaceSelector.MatchExpressions) > 0) {
+		return nil, fmt.Errorf("namespace selectors are not supported")
+	}
+	if len(webhook.MatchConditions) > 0 {
+		return nil, fmt.Errorf("match conditions are not supported")
+	}
+	if !slices.Contains(webhook.AdmissionReviewVersions, "v1") {
+		return nil, fmt.Errorf("the webhook must accept admission.k8s.io/v1 AdmissionReviews")
+	}
+
+	objectSelector := labels.Everything()
+	if webhook.ObjectSelector != nil {
+		if objectSelector, err = metav1.LabelSelectorAsSelector(webhook.ObjectSelector); err != nil {
+			return nil, err
+		}
+	}
+
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	if len(webhook.ClientConfig.CABundle) > 0 {
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(webhook.ClientConfig.CABundle) {
+			return nil, fmt.Errorf("the CA bundle has no certificates")
+		}
+	}
+	timeout := defaultWebhookTimeout
+	if webhook.TimeoutSeconds != nil {
+		timeout = time.Duration(*webhook.TimeoutSeconds) * time.Second
+	}
+
+	return &validatingWebhook{
+		ValidatingWebhook: webhook,
+		url:               u.String(),
+		client: &http.Client{
+			Timeout: timeout,
+			Transport: &http.Transport{
+				Proxy:           http.ProxyFromEnvironment,
+				TLSClientConfig: tlsConfig,
+			},
+		},
+		objectSelector: objectSelector,
+	}, nil
+}
+
+func (v *ValidatingWebhooks) Handles(admission.Operation) bool {
+	return len(v.webhooks) > 0
+}
+
+func (v *ValidatingWebhooks) Validate(ctx context.Context, a admission.Attributes, _ admission.ObjectInterfaces) error {
+	var matching []*validatingWebhook
+	for _, w := range v.webhooks {
+		if w.matches(a) {
+			if a.IsDryRun() && !w.dryRunSafe() {
+				return apierrors.NewBadRequest(fmt.Sprintf("webhook %q has side effects and does not support dry run", w.Name))
+			}
+			matching = append(matching, w)
+		}
+	}
+	if len(matching) == 0 {
+		return nil
+	}
+
+	review, err := newAdmissionReview(a)
+	if err != nil {
+		return apierrors.NewInternalError(err)
+	}
+
+	errs := make([]error, len(matching))
+	var wg sync.WaitGroup
+	for i, w := range matching {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = w.validate(ctx, review)
+		}()
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (w *validatingWebhook) dryRunSafe() bool {
+	return w.SideEffects != nil && (*w.SideEffects == admissionregistrationv1.SideEffectClassNone ||
+		*w.SideEffects == admissionregistrationv1.SideEffectClassNoneOnDryRun)
+}
+
+// matches returns whether a rule and the object selector of the webhook match the request.
+func (w *validatingWebhook) matches(a admission.Attributes) bool {
+	obj := a.GetObject()
+	if a.GetOperation() == admission.Delete {
+		obj = a.GetOldObject()
+	}
+	if !w.objectSelector.Empty() {
+		objLabels, err := objectLabels(obj)
+		if err != nil || !w.objectSelector.Matches(labels.Set(objLabels)) {
+			return false
+		}
+	}
+
+	resource := a.GetResource()
+	resourceName := resource.Resource
+	if a.GetSubresource() != "" {
+		resourceName += "/" + a.GetSubresource()
+	}
+	for _, rule := range w.Rules {
+		if matchesRule(rule, string(a.GetOperation()), resource.Group, resource.Version, resourceName, a.GetNamespace()) {
+			return true
+		}
+	}
+	return false
+}
+
+func objectLabels(obj runtime.Object) (map[string]string, error) {
+	if obj == nil {
+		return nil, nil
+	}
+	accessor, err := meta.Accessor(obj)
+	if err != nil {
+		return nil, err
+	}
+	return accessor.GetLabels(), nil
+}
+
+func matchesRule(rule admissionregistrationv1.RuleWithOperations, operation, group, version, resource, namespace string) bool {
+	matchesAny := func(value string, allowed []string) bool {
+		return slices.Contains(allowed, "*") || slices.Contains(allowed, value)
+	}
+	if !slices.ContainsFunc(rule.Operations, func(op admissionregistrationv1.OperationType) bool {
+		return op == admissionregistrationv1.OperationAll || string(op) == operation
+	}) {
+		return false
+	}
+	if !matchesAny(group, rule.APIGroups) || !matchesAny(version, rule.APIVersions) {
+		return false
+	}
+	if rule.Scope != nil {
+		switch *rule.Scope {
+		case admissionregistrationv1.ClusterScope:
+			if namespace != "" {
+				return false
+			}
+		case admissionregistrationv1.NamespacedScope:
+			if namespace == "" {
+				return false
+			}
+		}
+	}
+
+	name, subresource, _ := strings.Cut(resource, "/")
+	for _, allowed := range rule.Resources {
+		allowedName, allowedSubresource, _ := strings.Cut(allowed, "/")
+		switch {
+		case allowed == "*/*":
+			return true
+		case allowedName == "*" && allowedSubresource == subresource:
+			return true
+		case allowedName == name && (allowedSubresource == subresource || allowedSubresource == "*"):
+			return true
+		}
+	}
+	return false
+}
+
+func newAdmissionReview(a admission.Attributes) (*admissionv1.AdmissionReview, error) {
+	kind := a.GetKind()
+	gvk := metav1.GroupVersionKind{Group: kind.Group, Version: kind.Version, Kind: kind.Kind}
+	resource := a.GetResource()
+	gvr := metav1.GroupVersionResource{Group: resource.Group, Version: resource.Version, Resource: resource.Resource}
+
+	request := &admissionv1.AdmissionRequest{
+		UID:             ktypes.UID(uuid.NewUUID()),
+		Kind:            gvk,
+		Resource:        gvr,
+		SubResource:     a.GetSubresource(),
+		RequestKind:     &gvk,
+		RequestResource: &gvr,
+		Name:            a.GetName(),
+		Namespace:       a.GetNamespace(),
+		Operation:       admissionv1.Operation(a.GetOperation()),
+		DryRun:          ptr.To(a.IsDryRun()),
+	}
+	if user := a.GetUserInfo(); user != nil {
+		request.UserInfo = authenticationv1.UserInfo{
+			Username: user.GetName(),
+			UID:      user.GetUID(),
+			Groups:   user.GetGroups(),
+		}
+		for key, values := range user.GetExtra() {
+			if request.UserInfo.Extra == nil {
+				request.UserInfo.Extra = map[string]authenticationv1.ExtraValue{}
+			}
+			request.UserInfo.Extra[key] = values
+		}
+	}
+
+	var err error
+	if request.Object.Raw, err = encodeObject(a.GetObject(), a); err != nil {
+		return nil, err
+	}
+	if request.OldObject.Raw, err = encodeObject(a.GetOldObject(), a); err != nil {
+		return nil, err
+	}
+	if options := a.GetOperationOptions(); options != nil {
+		if request.Options.Raw, err = json.Marshal(options); err != nil {
+			return nil, err
+		}
+	}
+
+	return &admissionv1.AdmissionReview{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: admissionv1.SchemeGroupVersion.String(),
+			Kind:       "AdmissionReview",
+		},
+		Request: request,
+	}, nil
+}
+
+// encodeObject encodes obj as JSON with the apiVersion and kind of the request, which typed objects don't carry.
+func encodeObject(obj runtime.Object, a admission.Attributes) ([]byte, error) {
+	if obj == nil {
+		return nil, nil
+	}
+	data, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+	if err != nil {
+		return nil, err
+	}
+	data["apiVersion"], data["kind"] = a.GetKind().ToAPIVersionAndKind()
+	return json.Marshal(data)
+}
+
+// validate calls the webhook, it returns the error rejecting the request if the webhook denies it, or fails and its
+// failure policy is Fail.
+func (w *validatingWebhook) validate(ctx context.Context, review *admissionv1.AdmissionReview) error {
+	response, err := w.call(ctx, review)
+	if err != nil {
+		if w.FailurePolicy != nil && *w.FailurePolicy == admissionregistrationv1.Ignore {
+			logging.Default().Warnf("Failed calling webhook %q, ignoring the failure: %v", w.Name, err)
+			return nil
+		}
+		return apierrors.NewInternalError(fmt.Errorf("failed calling webhook %q: %w", w.Name, err))
+	}
+
+	for _, warn := range response.Warnings {
+		warning.AddWarning(ctx, "", warn)
+	}
+	if response.Allowed {
+		return nil
+	}
+
+	status := metav1.Status{
+		Status: metav1.StatusFailure,
+		Code:   http.StatusForbidden,
+		Reason: metav1.StatusReasonForbidden,
+	}
+	message := "no reason given"
+	if result := response.Result; result != nil {
+		if result.Code >= 400 {
+			status.Code = result.Code
+		}
+		if result.Reason != "" {
+			status.Reason = result.Reason
+		}
+		if result.Message != "" {
+			message = result.Message
+		}
+		status.Details = result.Details
+	}
+	status.Message = fmt.Sprintf("admission webhook %q denied the request: %s", w.Name, message)
+	return &apierrors.StatusError{ErrStatus: status}
+}
+
+func (w *validatingWebhook) call(ctx context.Context, review *admissionv1.AdmissionReview) (*admissionv1.AdmissionResponse, error) {
+	body, err := json.Marshal(review)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+
+	result := &admissionv1.AdmissionReview{}
+	if err := json.NewDecoder(resp.Body).Decode(result); err != nil {
+		return nil, fmt.Errorf("invalid AdmissionReview: %w", err)
+	}
+	if result.Response == nil {
+		return nil, fmt.Errorf("the AdmissionReview has no response")
+	}
+	if result.Response.UID != review.Request.UID {
+		return nil, fmt.Errorf("the response UID %q does not match the request UID %q", result.Response.UID, review.Request.UID)
+	}
+	return result.Response, nil
+}
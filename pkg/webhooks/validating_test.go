@@ -0,0 +1,84 @@
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	admissionv1 "k8s.io/api/admission/v1"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apiserver/pkg/admission"
+	"k8s.io/apiserver/pkg/authentication/user"
+	"k8s.io/utils/ptr"
+)
+
+func TestValidatingWebhooks(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		review := &admissionv1.AdmissionReview{}
+		if err := json.NewDecoder(req.Body).Decode(review); err != nil {
+			http.Error(rw, err.Error(), http.StatusBadRequest)
+			return
+		}
+		pod := &corev1.Pod{}
+		if err := json.Unmarshal(review.Request.Object.Raw, pod); err != nil {
+			http.Error(rw, err.Error(), http.StatusBadRequest)
+			return
+		}
+		review.Response = &admissionv1.AdmissionResponse{
+			UID:     review.Request.UID,
+			Allowed: pod.Spec.NodeName != "forbidden" && pod.Kind == "Pod",
+			Result:  &metav1.Status{Message: "node " + pod.Spec.NodeName + " is forbidden"},
+		}
+		_ = json.NewEncoder(rw).Encode(review)
+	}))
+	defer server.Close()
+
+	webhooks, err := NewValidatingWebhooks(admissionregistrationv1.ValidatingWebhook{
+		Name: "nodes.example.com",
+		ClientConfig: admissionregistrationv1.WebhookClientConfig{
+			URL:      ptr.To(server.URL),
+			CABundle: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}),
+		},
+		Rules: []admissionregistrationv1.RuleWithOperations{{
+			Operations: []admissionregistrationv1.OperationType{admissionregistrationv1.Create},
+			Rule: admissionregistrationv1.Rule{
+				APIGroups:   []string{""},
+				APIVersions: []string{"*"},
+				Resources:   []string{"pods"},
+			},
+		}},
+		ObjectSelector:          &metav1.LabelSelector{MatchLabels: map[string]string{"validate": "true"}},
+		SideEffects:             ptr.To(admissionregistrationv1.SideEffectClassNone),
+		AdmissionReviewVersions: []string{"v1"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	validate := func(nodeName string, podLabels map[string]string, operation admission.Operation) error {
+		pod := &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default", Labels: podLabels},
+			Spec:       corev1.PodSpec{NodeName: nodeName},
+		}
+		return webhooks.Validate(context.Background(), admission.NewAttributesRecord(pod, nil,
+			corev1.SchemeGroupVersion.WithKind("Pod"), "default", "test", corev1.SchemeGroupVersion.WithResource("pods"),
+			"", operation, &metav1.CreateOptions{}, false, &user.DefaultInfo{Name: "test"}), nil)
+	}
+
+	validated := map[string]string{"validate": "true"}
+	assert.NoError(t, validate("allowed", validated, admission.Create))
+	err = validate("forbidden", validated, admission.Create)
+	assert.True(t, apierrors.IsForbidden(err))
+	assert.ErrorContains(t, err, `admission webhook "nodes.example.com" denied the request: node forbidden is forbidden`)
+
+	// requests the rules or the object selector don't match aren't sent to the webhook
+	assert.NoError(t, validate("forbidden", nil, admission.Create))
+	assert.NoError(t, validate("forbidden", validated, admission.Update))
+}
@@ -0,0 +1,513 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/acorn-io/mink/pkg/db"
+	"github.com/acorn-io/mink/pkg/logging"
+	"github.com/acorn-io/mink/pkg/stores"
+	"github.com/acorn-io/mink/pkg/strategy"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/apiserver/pkg/authentication/user"
+	"k8s.io/apiserver/pkg/authorization/authorizer"
+	"k8s.io/apiserver/pkg/endpoints/request"
+	"k8s.io/apiserver/pkg/registry/rest"
+	"k8s.io/apiserver/pkg/storage"
+	"k8s.io/client-go/util/retry"
+)
+
+const (
+	// SignatureHeader carries the hex encoded HMAC-SHA256 of the request body, prefixed with "sha256=".
+	SignatureHeader = "X-Mink-Signature"
+	// EventTypePrefix is followed by added, modified or deleted in the type of the CloudEvents.
+	EventTypePrefix = "io.acorn.mink.object."
+
+	defaultSource = "mink"
+	kind          = "WebhookSubscription"
+)
+
+// NewStore returns the storage of webhook subscriptions, persisted by s, which is typically created by a db.Factory.
+// The types must have been added to the scheme with AddToScheme. Secrets are scrubbed from every object returned and
+// the status is maintained by the Dispatcher, it can't be changed through the API. The user and partition of the
+// request creating a subscription are recorded in it, events are delivered with the permissions of that user.
+func NewStore(scheme *runtime.Scheme, s strategy.CompleteStrategy) rest.Storage {
+	return stores.NewBuilder(scheme, &WebhookSubscription{}).
+		ClusterScoped().
+		WithCompleteCRUD(s).
+		WithPrepareCreate(preparer{}).
+		WithPrepareUpdate(preparer{}).
+		WithValidateCreate(validator{}).
+		WithValidateUpdate(validator{}).
+		WithScrubber(strategy.ScrubberFunc(scrubSecret)).Build()
+}
+
+func scrubSecret(_ context.Context, obj runtime.Object) {
+	if sub, ok := obj.(*WebhookSubscription); ok {
+		sub.Spec.Secret = ""
+	}
+}
+
+type preparer struct{}
+
+func (preparer) PrepareForCreate(ctx context.Context, obj runtime.Object) {
+	sub := obj.(*WebhookSubscription)
+	sub.Status = WebhookSubscriptionStatus{}
+	sub.Spec.Username, sub.Spec.UID, sub.Spec.Groups, sub.Spec.Extra = "", "", nil, nil
+	if u, ok := request.UserFrom(ctx); ok {
+		sub.Spec.Username = u.GetName()
+		sub.Spec.UID = u.GetUID()
+		sub.Spec.Groups = u.GetGroups()
+		sub.Spec.Extra = u.GetExtra()
+	}
+	sub.Spec.PartitionID = db.PartitionIDFromContext(ctx)
+}
+
+func (preparer) PrepareForUpdate(_ context.Context, obj, old runtime.Object) {
+	sub, oldSub := obj.(*WebhookSubscription), old.(*WebhookSubscription)
+	sub.Status = oldSub.Status
+	sub.Spec.Username = oldSub.Spec.Username
+	sub.Spec.UID = oldSub.Spec.UID
+	sub.Spec.Groups = oldSub.Spec.Groups
+	sub.Spec.Extra = oldSub.Spec.Extra
+	sub.Spec.PartitionID = oldSub.Spec.PartitionID
+	// clients never see the secret, so an update of an object they read would otherwise remove it
+	if sub.Spec.Secret == "" {
+		sub.Spec.Secret = oldSub.Spec.Secret
+	}
+}
+
+type validator struct{}
+
+func (validator) Validate(_ context.Context, obj runtime.Object) (result field.ErrorList) {
+	spec := obj.(*WebhookSubscription).Spec
+	specPath := field.NewPath("spec")
+
+	if u, err := url.Parse(spec.URL); err != nil {
+		result = append(result, field.Invalid(specPath.Child("url"), spec.URL, err.Error()))
+	} else if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		result = append(result, field.Invalid(specPath.Child("url"), spec.URL, "must be an absolute http or https URL"))
+	}
+	if spec.Kind == "" {
+		result = append(result, field.Required(specPath.Child("kind"), ""))
+	}
+	if spec.Selector != nil {
+		if _, err := metav1.LabelSelectorAsSelector(spec.Selector); err != nil {
+			result = append(result, field.Invalid(specPath.Child("selector"), spec.Selector, err.Error()))
+		}
+	}
+	eventTypes := []string{string(watch.Added), string(watch.Modified), string(watch.Deleted)}
+	for i, eventType := range spec.EventTypes {
+		if !slices.Contains(eventTypes, eventType) {
+			result = append(result, field.NotSupported(specPath.Child("eventTypes").Index(i), eventType, eventTypes))
+		}
+	}
+	return result
+}
+
+func (v validator) ValidateUpdate(ctx context.Context, obj, _ runtime.Object) field.ErrorList {
+	return v.Validate(ctx, obj)
+}
+
+// CloudEvent is a CloudEvents 1.0 event in structured JSON mode, as posted to subscribers.
+type CloudEvent struct {
+	SpecVersion     string          `json:"specversion"`
+	ID              string          `json:"id"`
+	Source          string          `json:"source"`
+	Type            string          `json:"type"`
+	Subject         string          `json:"subject,omitempty"`
+	Time            time.Time       `json:"time"`
+	DataContentType string          `json:"datacontenttype"`
+	Data            json.RawMessage `json:"data"`
+}
+
+// delivery is the payload of the job delivering an event to a subscription.
+type delivery struct {
+	Subscription string     `json:"subscription"`
+	UID          types.UID  `json:"uid"`
+	Event        CloudEvent `json:"event"`
+}
+
+// Dispatcher delivers the changes published by a db.Publisher to the matching webhook subscriptions. Every delivery
+// is a job in a db.Queue, so failed deliveries are retried with backoff and eventually dead-lettered independently of
+// each other. Changes of WebhookSubscriptions themselves are never delivered, status updates would trigger more.
+//
+// A change is only delivered to a subscription if the user that created the subscription may watch the resource of
+// the object in its namespace, and the object is in the partition of the subscription, if it has one. The objects
+// are scrubbed as they are for the watches of the resource before they are delivered.
+type Dispatcher struct {
+	factory       *db.Factory
+	subscriptions strategy.CompleteStrategy
+	publisher     *db.Publisher
+	queue         *db.Queue
+	client        *http.Client
+	source        string
+	authorizer    authorizer.Authorizer
+	resources     map[string]schema.GroupResource
+	scrubbers     map[string]strategy.Scrubber
+	queueOpts     []db.QueueOption
+	publishOpts   []db.PublisherOption
+}
+
+type DispatcherOption func(*Dispatcher)
+
+// defaultClient posts events with a timeout and doesn't follow redirects, the URLs are chosen by the users creating
+// subscriptions.
+var defaultClient = &http.Client{
+	Timeout: 30 * time.Second,
+	CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	},
+}
+
+// WithHTTPClient sets the client events are posted with. The default client times out after 30 seconds and doesn't
+// follow redirects, a client set here should do the same.
+func WithHTTPClient(client *http.Client) DispatcherOption {
+	return func(d *Dispatcher) {
+		d.client = client
+	}
+}
+
+// WithAuthorizer sets the authorizer checking that the users that created subscriptions may watch the objects whose
+// changes are delivered to them. It is required.
+func WithAuthorizer(authorizer authorizer.Authorizer) DispatcherOption {
+	return func(d *Dispatcher) {
+		d.authorizer = authorizer
+	}
+}
+
+// WithResources sets the tables whose changes are delivered, mapped to the resource the user that created a
+// subscription must be allowed to watch to get the changes of the table. Changes of other tables are not delivered.
+func WithResources(resources map[string]schema.GroupResource) DispatcherOption {
+	return func(d *Dispatcher) {
+		d.resources = resources
+	}
+}
+
+// WithScrubbers sets the scrubbers, keyed by table, that remove fields from the objects of the changes of the table
+// before they are delivered, like the Scrubber of the watches of the resource, see db.Factory.ScrubChanges. They are
+// called with the user that created the subscription in the context.
+func WithScrubbers(scrubbers map[string]strategy.Scrubber) DispatcherOption {
+	return func(d *Dispatcher) {
+		d.scrubbers = scrubbers
+	}
+}
+
+// WithSource sets the source attribute of the events, "mink" by default.
+func WithSource(source string) DispatcherOption {
+	return func(d *Dispatcher) {
+		d.source = source
+	}
+}
+
+// WithQueueOptions sets the options of the queue of deliveries, such as the retry backoff and maximum attempts.
+func WithQueueOptions(opts ...db.QueueOption) DispatcherOption {
+	return func(d *Dispatcher) {
+		d.queueOpts = append(d.queueOpts, opts...)
+	}
+}
+
+// WithPublisherOptions sets the options of the publisher of the changes.
+func WithPublisherOptions(opts ...db.PublisherOption) DispatcherOption {
+	return func(d *Dispatcher) {
+		d.publishOpts = append(d.publishOpts, opts...)
+	}
+}
+
+// NewDispatcher returns a dispatcher of the changes of the tables of factory to the subscriptions stored in
+// subscriptions, which is typically a strategy of the same factory. The tables and the authorizer must be set with
+// WithResources and WithAuthorizer.
+func NewDispatcher(factory *db.Factory, subscriptions strategy.CompleteStrategy, opts ...DispatcherOption) (*Dispatcher, error) {
+	d := &Dispatcher{
+		factory:       factory,
+		subscriptions: subscriptions,
+		client:        defaultClient,
+		source:        defaultSource,
+	}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(d)
+		}
+	}
+	if d.authorizer == nil {
+		return nil, errors.New("the webhook dispatcher requires an authorizer")
+	}
+
+	var err error
+	d.queue, err = factory.NewQueue("webhooks", d.queueOpts...)
+	if err != nil {
+		return nil, err
+	}
+	d.publisher, err = factory.NewPublisher("webhooks", db.SinkFunc(d.publish), d.publishOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// Run publishes and delivers events until ctx is done.
+func (d *Dispatcher) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		d.publisher.Run(ctx)
+	}()
+	go func() {
+		defer wg.Done()
+		d.queue.Run(ctx, d.deliver)
+	}()
+	wg.Wait()
+}
+
+// publish enqueues a delivery for every subscription matching every event whose user may watch the object.
+func (d *Dispatcher) publish(ctx context.Context, events []db.ChangeEvent) error {
+	list, err := d.subscriptions.List(ctx, "", storage.ListOptions{Predicate: storage.Everything})
+	if err != nil {
+		return err
+	}
+	subs := list.(*WebhookSubscriptionList).Items
+	if len(subs) == 0 {
+		return nil
+	}
+
+	// decisions caches the authorization decisions of the batch by subscription, resource and namespace
+	decisions := map[string]bool{}
+	for _, event := range events {
+		resource, ok := d.resources[event.Table]
+		if !ok {
+			continue
+		}
+		obj := &unstructured.Unstructured{}
+		if err := obj.UnmarshalJSON(event.Object); err != nil {
+			return err
+		}
+		gvk := obj.GroupVersionKind()
+		if gvk.Kind == kind {
+			continue
+		}
+
+		for i := range subs {
+			sub := &subs[i]
+			if !matches(sub, gvk, obj, event) {
+				continue
+			}
+			key := fmt.Sprintf("%s/%s/%s", sub.UID, resource, obj.GetNamespace())
+			allowed, ok := decisions[key]
+			if !ok {
+				if allowed, err = d.authorize(ctx, sub, resource, obj.GetNamespace()); err != nil {
+					return err
+				}
+				decisions[key] = allowed
+			}
+			if !allowed {
+				continue
+			}
+
+			data, err := d.scrub(ctx, sub, event)
+			if err != nil {
+				return err
+			}
+			_, err = d.queue.Enqueue(ctx, delivery{
+				Subscription: sub.Name,
+				UID:          sub.UID,
+				Event:        d.newEvent(event, obj, data),
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// subscriber returns the user that created sub.
+func subscriber(sub *WebhookSubscription) user.Info {
+	return &user.DefaultInfo{
+		Name:   sub.Spec.Username,
+		UID:    sub.Spec.UID,
+		Groups: sub.Spec.Groups,
+		Extra:  sub.Spec.Extra,
+	}
+}
+
+// authorize returns whether the user that created sub may watch resource in namespace. Subscriptions without a user,
+// which weren't created through the store, get nothing.
+func (d *Dispatcher) authorize(ctx context.Context, sub *WebhookSubscription, resource schema.GroupResource, namespace string) (bool, error) {
+	if sub.Spec.Username == "" {
+		return false, nil
+	}
+	decision, _, err := d.authorizer.Authorize(ctx, authorizer.AttributesRecord{
+		User:            subscriber(sub),
+		Verb:            "watch",
+		Namespace:       namespace,
+		APIGroup:        resource.Group,
+		Resource:        resource.Resource,
+		ResourceRequest: true,
+	})
+	if err != nil {
+		return false, fmt.Errorf("authorizing webhook subscription [%s]: %w", sub.Name, err)
+	}
+	return decision == authorizer.DecisionAllow, nil
+}
+
+// scrub returns the object of event scrubbed for the user that created sub.
+func (d *Dispatcher) scrub(ctx context.Context, sub *WebhookSubscription, event db.ChangeEvent) (json.RawMessage, error) {
+	scrubber := d.scrubbers[event.Table]
+	if scrubber == nil {
+		return event.Object, nil
+	}
+	ctx = request.WithUser(ctx, subscriber(sub))
+	if sub.Spec.PartitionID != "" {
+		ctx = db.ContextWithPartitionID(ctx, sub.Spec.PartitionID)
+	}
+	scrubbed := []db.ChangeEvent{event}
+	if err := d.factory.ScrubChanges(ctx, scrubber, scrubbed); err != nil {
+		return nil, err
+	}
+	return scrubbed[0].Object, nil
+}
+
+func matches(sub *WebhookSubscription, gvk schema.GroupVersionKind, obj *unstructured.Unstructured, event db.ChangeEvent) bool {
+	if !sub.DeletionTimestamp.IsZero() || sub.Spec.Group != gvk.Group || sub.Spec.Kind != gvk.Kind {
+		return false
+	}
+	if sub.Spec.PartitionID != "" && sub.Spec.PartitionID != event.PartitionID {
+		return false
+	}
+	if len(sub.Spec.Namespaces) > 0 && !slices.Contains(sub.Spec.Namespaces, obj.GetNamespace()) {
+		return false
+	}
+	if len(sub.Spec.EventTypes) > 0 && !slices.Contains(sub.Spec.EventTypes, string(event.Type)) {
+		return false
+	}
+	if sub.Spec.Selector != nil {
+		selector, err := metav1.LabelSelectorAsSelector(sub.Spec.Selector)
+		if err != nil || !selector.Matches(labels.Set(obj.GetLabels())) {
+			return false
+		}
+	}
+	return true
+}
+
+func (d *Dispatcher) newEvent(event db.ChangeEvent, obj *unstructured.Unstructured, data json.RawMessage) CloudEvent {
+	subject := obj.GetName()
+	if ns := obj.GetNamespace(); ns != "" {
+		subject = ns + "/" + subject
+	}
+	return CloudEvent{
+		SpecVersion:     "1.0",
+		ID:              fmt.Sprintf("%s-%d", event.Table, event.ResourceVersion),
+		Source:          d.source,
+		Type:            EventTypePrefix + strings.ToLower(string(event.Type)),
+		Subject:         subject,
+		Time:            time.Now().UTC(),
+		DataContentType: "application/json",
+		Data:            data,
+	}
+}
+
+// deliver posts the event of a job to its subscription and records the outcome in the subscription's status.
+func (d *Dispatcher) deliver(ctx context.Context, job *db.Job) error {
+	var payload delivery
+	if err := job.Unmarshal(&payload); err != nil {
+		logging.Default().Errorf("Dropping invalid webhook delivery %d: %v", job.ID, err)
+		return nil
+	}
+
+	obj, err := d.subscriptions.Get(ctx, "", payload.Subscription)
+	if apierrors.IsNotFound(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	sub := obj.(*WebhookSubscription)
+	if sub.UID != payload.UID || !sub.DeletionTimestamp.IsZero() {
+		// the subscription was deleted, and possibly recreated, since the event was enqueued
+		return nil
+	}
+
+	deliveryErr := d.post(ctx, sub, payload.Event)
+	if err := d.recordDelivery(ctx, payload, deliveryErr); err != nil {
+		logging.Default().Errorf("Failed to update status of webhook subscription [%s]: %v", payload.Subscription, err)
+	}
+	return deliveryErr
+}
+
+func (d *Dispatcher) post(ctx context.Context, sub *WebhookSubscription, event CloudEvent) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, sub.Spec.URL, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/cloudevents+json; charset=utf-8")
+	if sub.Spec.Secret != "" {
+		req.Header.Set(SignatureHeader, Sign([]byte(sub.Spec.Secret), data))
+	}
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook %s returned %s", sub.Spec.URL, resp.Status)
+	}
+	return nil
+}
+
+// Sign returns the value of the SignatureHeader of body, receivers compare it to their own with hmac.Equal.
+func Sign(secret, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func (d *Dispatcher) recordDelivery(ctx context.Context, payload delivery, deliveryErr error) error {
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		obj, err := d.subscriptions.Get(ctx, "", payload.Subscription)
+		if err != nil {
+			return err
+		}
+		sub := obj.(*WebhookSubscription)
+		if sub.UID != payload.UID {
+			return nil
+		}
+
+		now := metav1.Now()
+		if deliveryErr == nil {
+			sub.Status.Delivered++
+			sub.Status.ConsecutiveFailures = 0
+			sub.Status.LastDeliveryTime = &now
+		} else {
+			sub.Status.ConsecutiveFailures++
+			sub.Status.LastFailureTime = &now
+			sub.Status.LastError = deliveryErr.Error()
+		}
+		_, err = d.subscriptions.UpdateStatus(ctx, sub)
+		return err
+	})
+}
@@ -0,0 +1,285 @@
+package webhooks
+
+import (
+	"context"
+	"crypto/hmac"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/acorn-io/mink/pkg/db"
+	"github.com/acorn-io/mink/pkg/strategy"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apiserver/pkg/authentication/user"
+	"k8s.io/apiserver/pkg/authorization/authorizer"
+	"k8s.io/apiserver/pkg/endpoints/request"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+)
+
+// receiver records the events posted to each path, failing the requests to /broken and the first one to /flaky and
+// redirecting the requests to /redirect.
+type receiver struct {
+	lock     sync.Mutex
+	events   map[string][]CloudEvent
+	requests map[string]int
+}
+
+func (r *receiver) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		http.Error(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.requests[req.URL.Path]++
+	if req.URL.Path == "/redirect" {
+		http.Redirect(rw, req, "/signed", http.StatusFound)
+		return
+	}
+	if req.URL.Path == "/broken" || (req.URL.Path == "/flaky" && r.requests[req.URL.Path] == 1) {
+		http.Error(rw, "unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	if req.URL.Path == "/signed" && !hmac.Equal([]byte(req.Header.Get(SignatureHeader)), []byte(Sign([]byte("secret"), body))) {
+		http.Error(rw, "bad signature", http.StatusUnauthorized)
+		return
+	}
+
+	var event CloudEvent
+	if err := json.Unmarshal(body, &event); err != nil {
+		http.Error(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+	r.events[req.URL.Path] = append(r.events[req.URL.Path], event)
+}
+
+func (r *receiver) get(path string) ([]CloudEvent, int) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return append([]CloudEvent(nil), r.events[path]...), r.requests[path]
+}
+
+// testAuthorizer allows alice to watch pods in the default namespace and carol in the tenant namespace.
+var testAuthorizer = authorizer.AuthorizerFunc(func(_ context.Context, a authorizer.Attributes) (authorizer.Decision, string, error) {
+	namespaces := map[string]string{"alice": "default", "carol": "tenant"}
+	if a.GetVerb() == "watch" && a.GetResource() == "pods" && namespaces[a.GetUser().GetName()] == a.GetNamespace() {
+		return authorizer.DecisionAllow, "", nil
+	}
+	return authorizer.DecisionNoOpinion, "", nil
+})
+
+func TestPrepare(t *testing.T) {
+	ctx := request.WithUser(db.ContextWithPartitionID(context.Background(), "p1"), &user.DefaultInfo{
+		Name:   "alice",
+		UID:    "1",
+		Groups: []string{"users"},
+	})
+	sub := &WebhookSubscription{Spec: WebhookSubscriptionSpec{Username: "bob", Groups: []string{"system:masters"}}}
+	preparer{}.PrepareForCreate(ctx, sub)
+	assert.Equal(t, "alice", sub.Spec.Username)
+	assert.Equal(t, "1", sub.Spec.UID)
+	assert.Equal(t, []string{"users"}, sub.Spec.Groups)
+	assert.Equal(t, "p1", sub.Spec.PartitionID)
+
+	// updates can't change the user or partition
+	updated := &WebhookSubscription{Spec: WebhookSubscriptionSpec{Username: "bob", Groups: []string{"system:masters"}}}
+	preparer{}.PrepareForUpdate(context.Background(), updated, sub)
+	assert.Equal(t, sub.Spec, updated.Spec)
+}
+
+func TestDispatcher(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	if err := AddToScheme(scheme, schema.GroupVersion{Group: "mink.acorn.io", Version: "v1"}); err != nil {
+		t.Fatal(err)
+	}
+	f, err := db.NewFactory(scheme, "sqlite://file:"+t.Name()+"?mode=memory&cache=shared")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = f.SQLDB.Close()
+	})
+	subscriptions, err := f.NewDBStrategy(&WebhookSubscription{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pods, err := f.NewDBStrategy(&corev1.Pod{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	recv := &receiver{events: map[string][]CloudEvent{}, requests: map[string]int{}}
+	server := httptest.NewServer(recv)
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	for _, spec := range []WebhookSubscriptionSpec{
+		{URL: server.URL + "/signed", Kind: "Pod", EventTypes: []string{"ADDED"}, Secret: "secret", Username: "alice"},
+		{URL: server.URL + "/flaky", Kind: "Pod", Username: "alice"},
+		{URL: server.URL + "/broken", Kind: "Pod", Username: "alice"},
+		{URL: server.URL + "/configmaps", Kind: "ConfigMap", Username: "alice"},
+		{URL: server.URL + "/other", Kind: "Pod", Namespaces: []string{"other"}, Username: "alice"},
+		{URL: server.URL + "/redirect", Kind: "Pod", EventTypes: []string{"ADDED"}, Username: "alice"},
+		{URL: server.URL + "/denied", Kind: "Pod", Username: "bob"},
+		{URL: server.URL + "/anonymous", Kind: "Pod"},
+		{URL: server.URL + "/partitioned", Kind: "Pod", Username: "carol", PartitionID: "p1"},
+	} {
+		_, err := subscriptions.Create(ctx, &WebhookSubscription{
+			ObjectMeta: metav1.ObjectMeta{Name: strings.TrimPrefix(spec.URL, server.URL+"/")},
+			Spec:       spec,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	_, err = NewDispatcher(f, subscriptions)
+	assert.Error(t, err, "expected an error without an authorizer")
+
+	dispatcher, err := NewDispatcher(f, subscriptions,
+		WithAuthorizer(testAuthorizer),
+		WithResources(map[string]schema.GroupResource{"pod": {Resource: "pods"}}),
+		WithScrubbers(map[string]strategy.Scrubber{"pod": strategy.ScrubberFunc(func(ctx context.Context, obj runtime.Object) {
+			u, _ := request.UserFrom(ctx)
+			obj.(*corev1.Pod).Annotations = map[string]string{"scrubbed-for": u.GetName()}
+		})}),
+		WithSource("test"),
+		WithQueueOptions(db.WithJobPollInterval(10*time.Millisecond), db.WithJobRetryBackoff(10*time.Millisecond, 10*time.Millisecond),
+			db.WithJobMaxAttempts(2)),
+		WithPublisherOptions(db.WithPublishPollInterval(10*time.Millisecond)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	go dispatcher.Run(ctx)
+
+	// the publisher starts at the end of the table, changes made before that aren't delivered
+	var cursors int64
+	for i := 0; i < 100 && cursors == 0; i++ {
+		time.Sleep(10 * time.Millisecond)
+		f.DB.Table("mink_publish_cursors").Where("name = ?", "webhooks/pod").Count(&cursors)
+	}
+
+	pod, err := pods.Create(ctx, &corev1.Pod{ObjectMeta: metav1.ObjectMeta{
+		Name:        "test",
+		Namespace:   "default",
+		Annotations: map[string]string{"secret": "value"},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// pods in the tenant namespace only go to carol, in her partition
+	for _, partitionID := range []string{"p2", "p1"} {
+		_, err := pods.Create(db.ContextWithPartitionID(ctx, partitionID), &corev1.Pod{ObjectMeta: metav1.ObjectMeta{
+			Name:      partitionID,
+			Namespace: "tenant",
+		}})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	pod.(*corev1.Pod).Labels = map[string]string{"updated": "true"}
+	if _, err := pods.Update(ctx, pod); err != nil {
+		t.Fatal(err)
+	}
+
+	waitFor := func(description string, done func() bool) {
+		t.Helper()
+		for i := 0; i < 500; i++ {
+			if done() {
+				return
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		t.Fatalf("timed out waiting for %s", description)
+	}
+	status := func(name string) WebhookSubscriptionStatus {
+		obj, err := subscriptions.Get(ctx, "", name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return obj.(*WebhookSubscription).Status
+	}
+
+	// the signed subscription only gets the addition
+	waitFor("the signed delivery", func() bool {
+		return status("signed").Delivered == 1
+	})
+	events, requests := recv.get("/signed")
+	assert.Equal(t, 1, requests)
+	if assert.Len(t, events, 1) {
+		assert.Equal(t, EventTypePrefix+"added", events[0].Type)
+		assert.Equal(t, "test", events[0].Source)
+		assert.Equal(t, "default/test", events[0].Subject)
+		delivered := &corev1.Pod{}
+		if assert.NoError(t, json.Unmarshal(events[0].Data, delivered)) {
+			assert.Equal(t, pod.(*corev1.Pod).UID, delivered.UID)
+			// the object is scrubbed for the user of the subscription
+			assert.Equal(t, map[string]string{"scrubbed-for": "alice"}, delivered.Annotations)
+		}
+	}
+
+	// the failed attempt of the flaky subscription is retried
+	waitFor("the flaky deliveries", func() bool {
+		return status("flaky").Delivered == 2
+	})
+	events, requests = recv.get("/flaky")
+	assert.Len(t, events, 2)
+	assert.Equal(t, 3, requests)
+	assert.Zero(t, status("flaky").ConsecutiveFailures)
+
+	// the partitioned subscription only gets the pod of its partition
+	waitFor("the partitioned delivery", func() bool {
+		return status("partitioned").Delivered == 1
+	})
+	events, _ = recv.get("/partitioned")
+	if assert.Len(t, events, 1) {
+		assert.Equal(t, "tenant/p1", events[0].Subject)
+	}
+
+	// deliveries to the broken subscription and redirects are dead-lettered after their attempts
+	queue, err := f.NewQueue("webhooks")
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitFor("the broken deliveries to be dead-lettered", func() bool {
+		dead, err := queue.DeadLetters(ctx)
+		return err == nil && len(dead) == 3
+	})
+	_, requests = recv.get("/broken")
+	assert.Equal(t, 4, requests)
+	waitFor("the broken subscription status", func() bool {
+		return status("broken").ConsecutiveFailures == 4
+	})
+	broken := status("broken")
+	assert.Zero(t, broken.Delivered)
+	assert.Contains(t, broken.LastError, "503")
+	assert.NotNil(t, broken.LastFailureTime)
+
+	// redirects aren't followed
+	_, requests = recv.get("/redirect")
+	assert.Equal(t, 2, requests)
+	assert.Contains(t, status("redirect").LastError, "302")
+	events, _ = recv.get("/signed")
+	assert.Len(t, events, 1)
+
+	// subscriptions to other kinds and namespaces, and of users that may not watch the pods, get nothing
+	for _, path := range []string{"/configmaps", "/other", "/denied", "/anonymous"} {
+		_, requests = recv.get(path)
+		assert.Zero(t, requests, path)
+	}
+}